@@ -20,9 +20,12 @@ type Config struct {
 }
 
 type ModelConfig struct {
-	APIKey  string `yaml:"api_key"`
-	BaseURL string `yaml:"base_url"`
-	Model   string `yaml:"model"`
+	APIKey         string  `yaml:"api_key"`
+	BaseURL        string  `yaml:"base_url"`
+	Model          string  `yaml:"model"`
+	EmbeddingModel string  `yaml:"embedding_model,omitempty"`
+	InputPrice     float64 `yaml:"input_price,omitempty"`  // 每百万输入 token 的价格，用于估算费用
+	OutputPrice    float64 `yaml:"output_price,omitempty"` // 每百万输出 token 的价格
 }
 
 type Message struct {
@@ -41,6 +44,7 @@ type ToolCall struct {
 type Client struct {
 	config Config
 	provider AIProvider
+	usage    *usageMeter
 }
 
 type AIProvider interface {
@@ -49,20 +53,22 @@ type AIProvider interface {
 }
 
 func NewClient(config Config) *Client {
+	usage := &usageMeter{}
 	var provider AIProvider
 	
 	switch config.Provider {
 	case ProviderZhipu:
-		provider = NewZhipuProvider(config.Models[ProviderZhipu])
+		provider = newZhipuProvider(config.Models[ProviderZhipu], usage)
 	case ProviderDeepseek:
-		provider = NewDeepseekProvider(config.Models[ProviderDeepseek])
+		provider = newDeepseekProvider(config.Models[ProviderDeepseek], usage)
 	default:
-		provider = NewZhipuProvider(config.Models[ProviderZhipu])
+		provider = newZhipuProvider(config.Models[ProviderZhipu], usage)
 	}
 
 	return &Client{
 		config:   config,
 		provider: provider,
+		usage:    usage,
 	}
 }
 
@@ -88,9 +94,9 @@ func (c *Client) SwitchProvider(provider Provider) error {
 	
 	switch provider {
 	case ProviderZhipu:
-		c.provider = NewZhipuProvider(modelConfig)
+		c.provider = newZhipuProvider(modelConfig, c.usage)
 	case ProviderDeepseek:
-		c.provider = NewDeepseekProvider(modelConfig)
+		c.provider = newDeepseekProvider(modelConfig, c.usage)
 	default:
 		return fmt.Errorf("unsupported provider: %s", provider)
 	}
@@ -12,6 +12,7 @@ import (
 type DeepseekProvider struct {
 	config ModelConfig
 	client *http.Client
+	usage  *usageMeter
 }
 
 type DeepseekRequest struct {
@@ -49,6 +50,10 @@ type DeepseekResponse struct {
 }
 
 func NewDeepseekProvider(config ModelConfig) *DeepseekProvider {
+	return newDeepseekProvider(config, nil)
+}
+
+func newDeepseekProvider(config ModelConfig, usage *usageMeter) *DeepseekProvider {
 	if config.BaseURL == "" {
 		config.BaseURL = "https://api.deepseek.com"
 	}
@@ -59,6 +64,7 @@ func NewDeepseekProvider(config ModelConfig) *DeepseekProvider {
 	return &DeepseekProvider{
 		config: config,
 		client: &http.Client{},
+		usage:  usage,
 	}
 }
 
@@ -117,6 +123,7 @@ func (d *DeepseekProvider) Chat(ctx context.Context, messages []Message, tools [
 		return "", nil, fmt.Errorf("no choices in response")
 	}
 
+	d.usage.add(deepseekResp.Usage.PromptTokens, deepseekResp.Usage.CompletionTokens)
 	choice := deepseekResp.Choices[0]
 	return choice.Message.Content, choice.Message.ToolCalls, nil
 }
@@ -0,0 +1,124 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+)
+
+// ErrEmbeddingsUnsupported 当前提供商不支持向量嵌入
+var ErrEmbeddingsUnsupported = errors.New("embeddings not supported by current provider")
+
+// EmbeddingProvider 支持向量嵌入的提供商
+type EmbeddingProvider interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	EmbeddingModel() string
+}
+
+type ZhipuEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type ZhipuEmbeddingResponse struct {
+	Model string `json:"model"`
+	Data  []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// 智谱单次请求最多的文本条数
+const zhipuEmbeddingBatch = 64
+
+// Embed 使用当前提供商生成文本向量
+func (c *Client) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embedder, ok := c.provider.(EmbeddingProvider)
+	if !ok {
+		return nil, ErrEmbeddingsUnsupported
+	}
+	return embedder.Embed(ctx, texts)
+}
+
+// EmbeddingModel 当前提供商的向量模型名，不支持时返回空字符串
+func (c *Client) EmbeddingModel() string {
+	if embedder, ok := c.provider.(EmbeddingProvider); ok {
+		return string(c.config.Provider) + "/" + embedder.EmbeddingModel()
+	}
+	return ""
+}
+
+func (z *ZhipuProvider) EmbeddingModel() string {
+	if z.config.EmbeddingModel != "" {
+		return z.config.EmbeddingModel
+	}
+	return "embedding-3"
+}
+
+func (z *ZhipuProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += zhipuEmbeddingBatch {
+		end := start + zhipuEmbeddingBatch
+		if end > len(texts) {
+			end = len(texts)
+		}
+		batch, err := z.embedBatch(ctx, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+func (z *ZhipuProvider) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	jsonData, err := json.Marshal(ZhipuEmbeddingRequest{Model: z.EmbeddingModel(), Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", z.config.BaseURL+"/embeddings", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+z.config.APIKey)
+
+	resp, err := z.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var embeddingResp ZhipuEmbeddingResponse
+	if err := json.Unmarshal(body, &embeddingResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if len(embeddingResp.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(embeddingResp.Data))
+	}
+
+	sort.Slice(embeddingResp.Data, func(i, j int) bool {
+		return embeddingResp.Data[i].Index < embeddingResp.Data[j].Index
+	})
+	vectors := make([][]float32, len(texts))
+	for i, item := range embeddingResp.Data {
+		vectors[i] = item.Embedding
+	}
+	return vectors, nil
+}
@@ -0,0 +1,69 @@
+package ai
+
+import "sync"
+
+// Usage 累计的模型调用用量
+type Usage struct {
+	Calls            int `json:"calls"`
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// TotalTokens 输入与输出 token 之和
+func (u Usage) TotalTokens() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// Add 合并两段用量
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		Calls:            u.Calls + other.Calls,
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+	}
+}
+
+// Sub 返回两次快照之间的用量
+func (u Usage) Sub(before Usage) Usage {
+	return Usage{
+		Calls:            u.Calls - before.Calls,
+		PromptTokens:     u.PromptTokens - before.PromptTokens,
+		CompletionTokens: u.CompletionTokens - before.CompletionTokens,
+	}
+}
+
+// usageMeter 在提供商之间共享的用量计数，切换提供商后继续累计
+type usageMeter struct {
+	mu    sync.Mutex
+	usage Usage
+}
+
+func (m *usageMeter) add(promptTokens, completionTokens int) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.usage.Calls++
+	m.usage.PromptTokens += promptTokens
+	m.usage.CompletionTokens += completionTokens
+}
+
+func (m *usageMeter) snapshot() Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.usage
+}
+
+// Usage 返回本客户端累计的用量快照
+func (c *Client) Usage() Usage {
+	return c.usage.snapshot()
+}
+
+// Cost 按当前提供商配置的单价（每百万 token）估算用量费用，未配置单价时为0
+func (c *Client) Cost(u Usage) float64 {
+	model := c.config.Models[c.config.Provider]
+	return (float64(u.PromptTokens)*model.InputPrice + float64(u.CompletionTokens)*model.OutputPrice) / 1e6
+}
@@ -12,6 +12,7 @@ import (
 type ZhipuProvider struct {
 	config ModelConfig
 	client *http.Client
+	usage  *usageMeter
 }
 
 type ZhipuRequest struct {
@@ -49,6 +50,10 @@ type ZhipuResponse struct {
 }
 
 func NewZhipuProvider(config ModelConfig) *ZhipuProvider {
+	return newZhipuProvider(config, nil)
+}
+
+func newZhipuProvider(config ModelConfig, usage *usageMeter) *ZhipuProvider {
 	if config.BaseURL == "" {
 		config.BaseURL = "https://open.bigmodel.cn/api/paas/v4"
 	}
@@ -59,6 +64,7 @@ func NewZhipuProvider(config ModelConfig) *ZhipuProvider {
 	return &ZhipuProvider{
 		config: config,
 		client: &http.Client{},
+		usage:  usage,
 	}
 }
 
@@ -117,6 +123,7 @@ func (z *ZhipuProvider) Chat(ctx context.Context, messages []Message, tools []ma
 		return "", nil, fmt.Errorf("no choices in response")
 	}
 
+	z.usage.add(zhipuResp.Usage.PromptTokens, zhipuResp.Usage.CompletionTokens)
 	choice := zhipuResp.Choices[0]
 	return choice.Message.Content, choice.Message.ToolCalls, nil
 }
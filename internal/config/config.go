@@ -51,6 +51,8 @@ type WritingConfig struct {
 	ShowWordCount       bool     `yaml:"show_word_count"`        // 显示字数统计
 	RememberContext     bool     `yaml:"remember_context"`       // 记住上下文
 	MaxContextLength    int      `yaml:"max_context_length"`     // 最大上下文长度
+	UseEmbeddings       bool     `yaml:"use_embeddings"`         // 检索时使用模型提供商的向量嵌入
+	DisableAutoCommit   bool     `yaml:"disable_auto_commit"`    // 关闭每轮对话后的 git 自动提交
 }
 
 func Load() (*Config, error) {
@@ -166,6 +168,10 @@ func createDefaultConfig(configDir, configFile string) error {
 			AllowedCommands: []string{"ls", "cat", "grep", "find", "git"},
 			SafeMode:        true,
 		},
+		Writing: WritingConfig{
+			TargetWordsPerDay: 3000,
+			ShowWordCount:     true,
+		},
 	}
 	
 	data, err := yaml.Marshal(defaultConfig)
@@ -16,6 +16,8 @@ type Manager struct {
 	rl          *readline.Instance
 	historyFile string
 	commands    []string
+	modelName   string
+	novelLabel  string // 当前小说和章节，显示在模型名之后
 }
 
 // 预定义的命令列表（用于自动补全）
@@ -23,6 +25,11 @@ var builtinCommands = []string{
 	"/help", "/clear", "/status", "/sessions", "/new", "/switch", "/config", "/exit", "/quit",
 	"/config show", "/config path", "/config set", "/config edit",
 	"/switch zhipu", "/switch deepseek",
+	"/import", "/export", "/progress", "/decide", "/generate",
+	"/export epub", "/export markdown", "/export txt",
+	"/generate chapters",
+	"/history", "/history init", "/revert",
+	"/novel", "/novel list", "/novel open", "/novel new", "/novel info",
 }
 
 func NewManager() (*Manager, error) {
@@ -51,9 +58,33 @@ func NewManager() (*Manager, error) {
 				readline.PcItem("zhipu.api_key"),
 				readline.PcItem("deepseek.api_key"),
 				readline.PcItem("ai.provider"),
+				readline.PcItem("writing.target_words_per_day"),
+				readline.PcItem("writing.show_word_count"),
+				readline.PcItem("writing.auto_commit"),
 			),
 			readline.PcItem("edit"),
 		),
+		readline.PcItem("/import"),
+		readline.PcItem("/export",
+			readline.PcItem("epub"),
+			readline.PcItem("markdown"),
+			readline.PcItem("txt"),
+		),
+		readline.PcItem("/progress"),
+		readline.PcItem("/decide"),
+		readline.PcItem("/generate",
+			readline.PcItem("chapters"),
+		),
+		readline.PcItem("/history",
+			readline.PcItem("init"),
+		),
+		readline.PcItem("/revert"),
+		readline.PcItem("/novel",
+			readline.PcItem("list"),
+			readline.PcItem("open"),
+			readline.PcItem("new"),
+			readline.PcItem("info"),
+		),
 		readline.PcItem("/exit"),
 		readline.PcItem("/quit"),
 	)
@@ -96,8 +127,22 @@ func (m *Manager) SetPrompt(prompt string) {
 
 // 设置模型提示符
 func (m *Manager) SetModelPrompt(modelName string) {
-	prompt := fmt.Sprintf("\033[36m[%s] ❯ \033[0m", modelName)
-	m.rl.SetPrompt(prompt)
+	m.modelName = modelName
+	m.refreshPrompt()
+}
+
+// SetNovelPrompt 设置提示符中的当前小说和章节，为空时不显示
+func (m *Manager) SetNovelPrompt(label string) {
+	m.novelLabel = label
+	m.refreshPrompt()
+}
+
+func (m *Manager) refreshPrompt() {
+	if m.novelLabel == "" {
+		m.rl.SetPrompt(fmt.Sprintf("\033[36m[%s] ❯ \033[0m", m.modelName))
+		return
+	}
+	m.rl.SetPrompt(fmt.Sprintf("\033[36m[%s] \033[35m%s\033[36m ❯ \033[0m", m.modelName, m.novelLabel))
 }
 
 func (m *Manager) ReadLine() (string, error) {
@@ -0,0 +1,331 @@
+package novel
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+// DefaultAbsenceChapters 默认超过多少章未出场视为久未登场
+const DefaultAbsenceChapters = 10
+
+// 生成章节上下文时，最近多少章内出场过的角色视为仍在场
+const recentAppearanceChapters = 10
+
+// CharacterAppearance 角色的出场统计
+type CharacterAppearance struct {
+	Name          string
+	Status        string
+	FirstAppeared int
+	LastAppeared  int
+	Chapters      []int // 出场的章节
+	Absent        int   // 距最新章节已缺席的章数
+}
+
+// AppearanceReport 角色出场报告
+type AppearanceReport struct {
+	LatestChapter int
+	AbsenceLimit  int
+	Characters    []CharacterAppearance
+	Absent        []CharacterAppearance // 缺席超过 AbsenceLimit 章的在世角色
+	NeverAppeared []string              // 已登记但正文中从未出现的角色
+}
+
+// SetCharacterAliases 设置角色的别名与称谓（如“林师兄”“少主”“老祖”），下次同步时重新扫描全部章节
+func (nm *NovelManager) SetCharacterAliases(name string, aliases []string) error {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	if nm.novelData == nil {
+		return fmt.Errorf("novel project not initialized")
+	}
+	char, ok := nm.novelData.Characters[name]
+	if !ok {
+		return fmt.Errorf("character not found: %s", name)
+	}
+	char.Aliases = normalizeAliases(name, aliases)
+	return nm.SaveProject()
+}
+
+// AppearanceReport 同步章节文件后统计角色出场情况，absenceLimit<=0 时使用默认值
+func (nm *NovelManager) AppearanceReport(absenceLimit int) (*AppearanceReport, error) {
+	if _, err := nm.SyncChapterStats(); err != nil {
+		return nil, err
+	}
+	if absenceLimit <= 0 {
+		absenceLimit = DefaultAbsenceChapters
+	}
+
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	if nm.novelData == nil {
+		return nil, fmt.Errorf("novel project not initialized")
+	}
+	report := &AppearanceReport{AbsenceLimit: absenceLimit}
+	appearances := make(map[string][]int)
+	for _, chapter := range nm.novelData.Chapters {
+		if chapter.CastDigest == "" {
+			continue
+		}
+		if chapter.Number > report.LatestChapter {
+			report.LatestChapter = chapter.Number
+		}
+		for _, name := range chapter.Characters {
+			appearances[name] = append(appearances[name], chapter.Number)
+		}
+	}
+
+	for _, name := range sortedCharacterNames(nm.novelData.Characters) {
+		char := nm.novelData.Characters[name]
+		chapters := appearances[name]
+		if len(chapters) == 0 {
+			report.NeverAppeared = append(report.NeverAppeared, name)
+			continue
+		}
+		sort.Ints(chapters)
+		entry := CharacterAppearance{
+			Name:          name,
+			Status:        char.Status,
+			FirstAppeared: chapters[0],
+			LastAppeared:  chapters[len(chapters)-1],
+			Chapters:      chapters,
+			Absent:        report.LatestChapter - chapters[len(chapters)-1],
+		}
+		report.Characters = append(report.Characters, entry)
+		if entry.Absent > absenceLimit && !char.IsDead() {
+			report.Absent = append(report.Absent, entry)
+		}
+	}
+	sort.SliceStable(report.Characters, func(i, j int) bool {
+		return report.Characters[i].FirstAppeared < report.Characters[j].FirstAppeared
+	})
+	sort.SliceStable(report.Absent, func(i, j int) bool {
+		return report.Absent[i].Absent > report.Absent[j].Absent
+	})
+	sort.Strings(report.NeverAppeared)
+	return report, nil
+}
+
+// Format 格式化角色出场报告
+func (r *AppearanceReport) Format() string {
+	var result strings.Builder
+	result.WriteString("👥 === 角色出场统计 ===\n\n")
+	if r.LatestChapter == 0 {
+		result.WriteString("还没有可扫描的章节正文\n")
+		return result.String()
+	}
+	result.WriteString(fmt.Sprintf("已扫描至第%d章\n\n", r.LatestChapter))
+
+	for _, entry := range r.Characters {
+		line := fmt.Sprintf("• %s: 第%d章 - 第%d章，出场 %d 章", entry.Name, entry.FirstAppeared, entry.LastAppeared, len(entry.Chapters))
+		if entry.Status == CharacterDead {
+			line += "（已死亡）"
+		}
+		result.WriteString(line + "\n")
+	}
+
+	if len(r.Absent) > 0 {
+		result.WriteString(fmt.Sprintf("\n⏳ 超过 %d 章未出场:\n", r.AbsenceLimit))
+		for _, entry := range r.Absent {
+			result.WriteString(fmt.Sprintf("• %s: 最后出场于第%d章，已缺席 %d 章\n", entry.Name, entry.LastAppeared, entry.Absent))
+		}
+	}
+	if len(r.NeverAppeared) > 0 {
+		result.WriteString(fmt.Sprintf("\n💤 尚未在正文中出现: %s\n", strings.Join(r.NeverAppeared, "、")))
+	}
+	return result.String()
+}
+
+// castMatcher 按名字和别名识别章节出场角色
+type castMatcher struct {
+	terms     []string            // 名字与别名，长的在前
+	owners    map[string][]string // 名字或别名 -> 角色
+	signature string              // 角色表指纹，角色或别名变化时所有章节需重新扫描
+}
+
+// newCastMatcher 调用方需持有锁
+func (nm *NovelManager) newCastMatcher() *castMatcher {
+	matcher := &castMatcher{owners: make(map[string][]string)}
+	names := sortedCharacterNames(nm.novelData.Characters)
+	var signature strings.Builder
+	for _, name := range names {
+		matcher.addTerm(name, name)
+		signature.WriteString(name)
+		for _, alias := range nm.novelData.Characters[name].Aliases {
+			matcher.addTerm(alias, name)
+			signature.WriteString("|" + alias)
+		}
+		signature.WriteString("\n")
+	}
+	sort.Slice(matcher.terms, func(i, j int) bool {
+		li, lj := len([]rune(matcher.terms[i])), len([]rune(matcher.terms[j]))
+		if li != lj {
+			return li > lj
+		}
+		return matcher.terms[i] < matcher.terms[j]
+	})
+	matcher.signature = signature.String()
+	return matcher
+}
+
+func (m *castMatcher) addTerm(term, owner string) {
+	term = strings.TrimSpace(term)
+	if term == "" {
+		return
+	}
+	if _, ok := m.owners[term]; !ok {
+		m.terms = append(m.terms, term)
+	}
+	if !containsString(m.owners[term], owner) {
+		m.owners[term] = append(m.owners[term], owner)
+	}
+}
+
+// digest 正文与角色表的指纹，用于跳过未变化的章节
+func (m *castMatcher) digest(text string) string {
+	h := fnv.New64a()
+	h.Write([]byte(m.signature))
+	h.Write([]byte{0})
+	h.Write([]byte(text))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// termHit 正文中一次名字或别名的出现
+type termHit struct {
+	term   string
+	owners []string
+	pos    int // 字节偏移
+}
+
+// locate 找出正文中所有名字与别名的位置，按出现顺序排列；长的优先，已匹配的部分不再重复匹配
+func (m *castMatcher) locate(text string) []termHit {
+	hits := make([]termHit, 0)
+	masked := text
+	for _, term := range m.terms {
+		if !strings.Contains(masked, term) {
+			continue
+		}
+		for offset := 0; ; {
+			idx := strings.Index(masked[offset:], term)
+			if idx < 0 {
+				break
+			}
+			hits = append(hits, termHit{term: term, owners: m.owners[term], pos: offset + idx})
+			offset += idx + len(term)
+		}
+		// 已匹配的部分用占位符覆盖，避免长名中的短名重复计数
+		masked = strings.ReplaceAll(masked, term, strings.Repeat("\x00", len(term)))
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].pos < hits[j].pos })
+	return hits
+}
+
+// scan 返回正文中出场的角色，按首次出现的位置排序。
+// 多个角色共用的称谓（如两位“师兄”）只在其中恰好一位本章被直接提到时才计入
+func (m *castMatcher) scan(text string) []string {
+	hits := m.locate(text)
+	cast := make([]string, 0)
+	for _, hit := range hits {
+		if len(hit.owners) == 1 && !containsString(cast, hit.owners[0]) {
+			cast = append(cast, hit.owners[0])
+		}
+	}
+	// 共用称谓按其首次出现的位置插入
+	result := make([]string, 0, len(cast))
+	for _, hit := range hits {
+		name := hit.owners[0]
+		if len(hit.owners) > 1 {
+			name = resolveShared(hit.owners, cast)
+		}
+		if name != "" && !containsString(result, name) {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// resolveShared 共用称谓的所属角色：候选中恰好一位直接出场时归于该角色
+func resolveShared(owners, present []string) string {
+	found := ""
+	for _, owner := range owners {
+		if containsString(present, owner) {
+			if found != "" {
+				return ""
+			}
+			found = owner
+		}
+	}
+	return found
+}
+
+// updateCast 正文或角色表变化时重新扫描章节出场角色，返回是否有变化。调用方需持有锁
+func (m *castMatcher) updateCast(chapter *Chapter, text string) bool {
+	digest := m.digest(text)
+	if chapter.CastDigest == digest {
+		return false
+	}
+	chapter.CastDigest = digest
+	chapter.Characters = m.scan(text)
+	return true
+}
+
+// refreshAppearances 根据各章出场角色更新角色的首次和最近出场章节，调用方需持有锁。
+// 首次出场章节仍等于上次扫描结果（或未设置）时跟随扫描更新；手动设定的登场章节保持不变，交给一致性检查比对
+func (nm *NovelManager) refreshAppearances(previousFirst map[string]int) {
+	first := nm.scannedFirstAppearances()
+	last := make(map[string]int)
+	for _, chapter := range nm.novelData.Chapters {
+		if chapter.CastDigest == "" {
+			continue
+		}
+		for _, name := range chapter.Characters {
+			if chapter.Number > last[name] {
+				last[name] = chapter.Number
+			}
+		}
+	}
+	for name, char := range nm.novelData.Characters {
+		if char.FirstAppeared == 0 || char.FirstAppeared == previousFirst[name] {
+			char.FirstAppeared = first[name]
+		}
+		char.LastAppeared = last[name]
+	}
+}
+
+// scannedFirstAppearances 各角色在已扫描章节中的首次出场章节，调用方需持有锁
+func (nm *NovelManager) scannedFirstAppearances() map[string]int {
+	first := make(map[string]int)
+	for _, chapter := range nm.novelData.Chapters {
+		if chapter.CastDigest == "" {
+			continue
+		}
+		for _, name := range chapter.Characters {
+			if old, ok := first[name]; !ok || chapter.Number < old {
+				first[name] = chapter.Number
+			}
+		}
+	}
+	return first
+}
+
+// recentlyAppeared 角色在第 chapterNum 章是否仍在场：已经登场，且尚未统计出场或最近若干章内出场过
+func recentlyAppeared(char *Character, chapterNum int) bool {
+	if char.FirstAppeared > chapterNum {
+		return false
+	}
+	return char.LastAppeared == 0 || char.LastAppeared >= chapterNum-recentAppearanceChapters
+}
+
+// normalizeAliases 去除空白、重复以及与本名相同的别名
+func normalizeAliases(name string, aliases []string) []string {
+	result := make([]string, 0, len(aliases))
+	for _, alias := range aliases {
+		alias = strings.TrimSpace(alias)
+		if alias != "" && alias != name && !containsString(result, alias) {
+			result = append(result, alias)
+		}
+	}
+	return result
+}
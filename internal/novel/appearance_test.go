@@ -0,0 +1,125 @@
+package novel
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newAppearanceManager 两位角色共用“师兄”的称谓，林动另有专属称谓“林师兄”
+func newAppearanceManager(t *testing.T) *NovelManager {
+	t.Helper()
+	nm := newTestManager(t)
+	characters := []*Character{
+		{Name: "林动", Aliases: []string{"林师兄", "师兄"}},
+		{Name: "林琅天", Aliases: []string{"师兄"}},
+		{Name: "苏柔"},
+	}
+	for _, char := range characters {
+		if _, err := nm.AddCharacter(char); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return nm
+}
+
+func TestCastMatcherScan(t *testing.T) {
+	nm := newAppearanceManager(t)
+	matcher := nm.newCastMatcher()
+
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "共用称谓归于本章唯一直接出场的候选", text: "师兄来了。苏柔看向林动。", want: []string{"林动", "苏柔"}},
+		{name: "两位候选都出场时共用称谓不归属", text: "师兄来了。林琅天看了林动一眼。", want: []string{"林琅天", "林动"}},
+		{name: "候选都未直接出场时不计入", text: "师兄来了。苏柔起身相迎。", want: []string{"苏柔"}},
+		{name: "长称谓优先匹配", text: "林师兄来了。", want: []string{"林动"}},
+		{name: "按首次出现的位置排序", text: "苏柔说林琅天到了。", want: []string{"苏柔", "林琅天"}},
+		{name: "没有角色", text: "夜色正浓。", want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matcher.scan(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("scan(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveShared(t *testing.T) {
+	tests := []struct {
+		name    string
+		owners  []string
+		present []string
+		want    string
+	}{
+		{name: "恰好一位出场", owners: []string{"林动", "林琅天"}, present: []string{"苏柔", "林动"}, want: "林动"},
+		{name: "两位都出场", owners: []string{"林动", "林琅天"}, present: []string{"林琅天", "林动"}, want: ""},
+		{name: "都未出场", owners: []string{"林动", "林琅天"}, present: []string{"苏柔"}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveShared(tt.owners, tt.present); got != tt.want {
+				t.Errorf("resolveShared() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCastMatcherSignature(t *testing.T) {
+	nm := newAppearanceManager(t)
+	before := nm.newCastMatcher().digest("正文")
+	if err := nm.SetCharacterAliases("苏柔", []string{" 柔儿 ", "苏柔", "柔儿", ""}); err != nil {
+		t.Fatal(err)
+	}
+	if got := nm.novelData.Characters["苏柔"].Aliases; !reflect.DeepEqual(got, []string{"柔儿"}) {
+		t.Errorf("Aliases = %v, want [柔儿]", got)
+	}
+	// 别名变化后所有章节都要重新扫描
+	if after := nm.newCastMatcher().digest("正文"); after == before {
+		t.Error("digest unchanged after aliases changed")
+	}
+}
+
+func TestRefreshAppearances(t *testing.T) {
+	nm := newAppearanceManager(t)
+	if _, err := nm.AddCharacter(&Character{Name: "岩老", FirstAppeared: 1}); err != nil {
+		t.Fatal(err)
+	}
+	writeTestChapter(t, nm, 1, "夜色正浓。")
+	writeTestChapter(t, nm, 2, "林动推开门。")
+	writeTestChapter(t, nm, 3, "林动与岩老说话。苏柔在一旁。")
+	if _, err := nm.SyncChapterStats(); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(name string, first, last int) {
+		t.Helper()
+		char := nm.novelData.Characters[name]
+		if char.FirstAppeared != first || char.LastAppeared != last {
+			t.Errorf("%s appeared %d-%d, want %d-%d", name, char.FirstAppeared, char.LastAppeared, first, last)
+		}
+	}
+	check("林动", 2, 3)
+	check("苏柔", 3, 3)
+	check("岩老", 1, 3) // 手动设定的登场章节保持不变
+	check("林琅天", 0, 0)
+
+	// 删掉第二章的出场后，跟随扫描的首次出场章节随之更新，手动设定的仍然不变
+	writeTestChapter(t, nm, 2, "夜色正浓。")
+	writeTestChapter(t, nm, 3, "林动与苏柔说话。")
+	if _, err := nm.SyncChapterStats(); err != nil {
+		t.Fatal(err)
+	}
+	check("林动", 3, 3)
+	check("岩老", 1, 0)
+
+	report, err := nm.AppearanceReport(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.LatestChapter != 3 || !reflect.DeepEqual(report.NeverAppeared, []string{"岩老", "林琅天"}) {
+		t.Errorf("report latest = %d, never appeared = %v", report.LatestChapter, report.NeverAppeared)
+	}
+}
@@ -0,0 +1,532 @@
+package novel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/AiNovelTools/internal/ai"
+)
+
+// 批量生成状态
+const (
+	BatchRunning         = "running"
+	BatchCompleted       = "completed"
+	BatchFailed          = "failed"
+	BatchInterrupted     = "interrupted"
+	BatchBudgetExhausted = "budget_exhausted"
+)
+
+// 批量生成中单章的状态
+const (
+	BatchChapterPending = "pending"
+	BatchChapterDone    = "done"
+	BatchChapterSkipped = "skipped" // 正文已存在且未要求覆盖
+	BatchChapterFailed  = "failed"
+)
+
+// 单章摘要的最大字数
+const chapterSummaryRunes = 300
+
+// ErrBudgetExceeded 批量生成的 token 或费用预算已用完
+var ErrBudgetExceeded = errors.New("generation budget exceeded")
+
+// BatchOptions 批量生成选项
+type BatchOptions struct {
+	From        int
+	To          int
+	TargetWords int
+	MaxTokens   int     // token 预算，0 表示不限
+	MaxCost     float64 // 费用预算，0 表示不限；按模型配置的单价估算
+	Overwrite   bool
+	Progress    func(message string) // 进度通知，可为空
+}
+
+// BatchChapter 批量生成中单章的记录
+type BatchChapter struct {
+	Chapter    int       `json:"chapter"`
+	Status     string    `json:"status"`
+	Beat       string    `json:"beat,omitempty"`
+	AutoBeat   bool      `json:"auto_beat,omitempty"` // 没有章节概要，由前情推出的大纲
+	Summary    string    `json:"summary,omitempty"`   // 生成后的本章摘要，作为下一章的前情提要
+	Words      int       `json:"words,omitempty"`
+	Issues     int       `json:"issues,omitempty"`
+	Usage      ai.Usage  `json:"usage"`
+	Cost       float64   `json:"cost,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at,omitempty"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+}
+
+// BatchRun 批量生成的检查点；每章结束后写入 pipeline/batch_FFF-TTT.json，同一范围再次运行即从中断处继续
+type BatchRun struct {
+	From        int             `json:"from"`
+	To          int             `json:"to"`
+	Status      string          `json:"status"`
+	TargetWords int             `json:"target_words,omitempty"`
+	MaxTokens   int             `json:"max_tokens,omitempty"`
+	MaxCost     float64         `json:"max_cost,omitempty"`
+	Usage       ai.Usage        `json:"usage"`
+	Cost        float64         `json:"cost,omitempty"`
+	Chapters    []*BatchChapter `json:"chapters"`
+	LastError   string          `json:"last_error,omitempty"`
+	StartedAt   time.Time       `json:"started_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	FinishedAt  time.Time       `json:"finished_at,omitempty"`
+}
+
+// RunBatch 依次生成 From 到 To 章：每章走完整的生成流水线，生成后概括本章作为下一章的前情提要。
+// 每章结束都会保存检查点和运行报告；失败、取消或预算用尽时停止，再次运行同一范围即从中断的章节继续
+func (nm *NovelManager) RunBatch(ctx context.Context, client *ai.Client, opts BatchOptions) (*BatchRun, error) {
+	if client == nil {
+		return nil, fmt.Errorf("AI client not available")
+	}
+	return nm.runBatch(ctx, client, opts)
+}
+
+func (nm *NovelManager) runBatch(ctx context.Context, model chatModel, opts BatchOptions) (*BatchRun, error) {
+	if opts.From <= 0 || opts.To < opts.From {
+		return nil, fmt.Errorf("invalid chapter range: %d-%d", opts.From, opts.To)
+	}
+	if !nm.HasProject() {
+		return nil, fmt.Errorf("novel project not initialized")
+	}
+
+	batch, err := nm.LoadBatchRun(opts.From, opts.To)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if batch == nil {
+		batch = newBatchRun(opts.From, opts.To)
+	}
+	// 预算和字数以本次运行的设置为准，便于续跑时追加预算
+	batch.TargetWords = opts.TargetWords
+	batch.MaxTokens = opts.MaxTokens
+	batch.MaxCost = opts.MaxCost
+	batch.Status = BatchRunning
+	batch.LastError = ""
+	batch.FinishedAt = time.Time{}
+
+	notify := func(format string, args ...interface{}) {
+		if opts.Progress != nil {
+			opts.Progress(fmt.Sprintf(format, args...))
+		}
+	}
+
+	recap := nm.chapterRecap(opts.From - 1)
+	for _, entry := range batch.Chapters {
+		if entry.Status == BatchChapterDone || entry.Status == BatchChapterSkipped {
+			if entry.Summary != "" {
+				recap = entry.Summary
+			}
+			continue
+		}
+		if err := batch.checkBudget(ai.Usage{}, 0); err != nil {
+			return batch, nm.stopBatch(batch, BatchBudgetExhausted, err)
+		}
+		if err := ctx.Err(); err != nil {
+			return batch, nm.stopBatch(batch, BatchInterrupted, err)
+		}
+
+		before := model.Usage()
+		err := nm.generateBatchChapter(ctx, model, batch, entry, recap, opts, notify)
+		used := model.Usage().Sub(before)
+		cost := model.Cost(used)
+		entry.Usage = entry.Usage.Add(used)
+		entry.Cost += cost
+		batch.Usage = batch.Usage.Add(used)
+		batch.Cost += cost
+
+		if errors.Is(err, ErrBudgetExceeded) {
+			// 本章停在最近的检查点，保持待生成，追加预算后从中断的阶段继续
+			notify("💰 预算用尽，第%d章停在检查点", entry.Chapter)
+			return batch, nm.stopBatch(batch, BatchBudgetExhausted, fmt.Errorf("chapter %d: %w", entry.Chapter, err))
+		}
+		if err != nil {
+			entry.Status = BatchChapterFailed
+			entry.Error = err.Error()
+			status := BatchFailed
+			if ctx.Err() != nil {
+				status = BatchInterrupted
+			}
+			notify("❌ 第%d章失败: %v", entry.Chapter, err)
+			return batch, nm.stopBatch(batch, status, fmt.Errorf("chapter %d: %w", entry.Chapter, err))
+		}
+
+		entry.Error = ""
+		entry.FinishedAt = time.Now()
+		if entry.Summary != "" {
+			recap = entry.Summary
+		}
+		if err := nm.saveBatchRun(batch); err != nil {
+			return batch, err
+		}
+	}
+
+	batch.Status = BatchCompleted
+	batch.FinishedAt = time.Now()
+	if err := nm.saveBatchRun(batch); err != nil {
+		return batch, err
+	}
+	return batch, nm.Flush()
+}
+
+// LoadBatchRun 读取某个范围的批量生成检查点，不存在时返回 os.ErrNotExist
+func (nm *NovelManager) LoadBatchRun(from, to int) (*BatchRun, error) {
+	data, err := os.ReadFile(nm.batchPath(from, to, ".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no batch run for chapters %d-%d: %w", from, to, os.ErrNotExist)
+		}
+		return nil, fmt.Errorf("failed to read batch state: %w", err)
+	}
+	var batch BatchRun
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return nil, fmt.Errorf("failed to parse batch state: %w", err)
+	}
+	return &batch, nil
+}
+
+// BatchReportPath 返回批量生成运行报告的路径
+func (nm *NovelManager) BatchReportPath(from, to int) string {
+	return nm.batchPath(from, to, "_report.md")
+}
+
+// SummarizeChapter 让模型概括一章的实际内容，供后续章节作为前情提要
+func (nm *NovelManager) SummarizeChapter(ctx context.Context, client *ai.Client, chapterNum int) (string, error) {
+	if client == nil {
+		return "", fmt.Errorf("AI client not available")
+	}
+	return nm.summarizeChapter(ctx, client, chapterNum)
+}
+
+func (nm *NovelManager) summarizeChapter(ctx context.Context, model chatModel, chapterNum int) (string, error) {
+	text, err := nm.ReadChapterText(chapterNum)
+	if err != nil {
+		return "", err
+	}
+
+	prompt := fmt.Sprintf(`请用不超过%d字概括小说第%d章的内容，包括发生的主要事件、人物处境和关系的变化，以及结尾留下的悬念。
+只输出摘要，不要评价。
+
+%s`, chapterSummaryRunes/2, chapterNum, truncateRunes(text, maxAICheckRunes))
+
+	response, _, err := model.Chat(ctx, []ai.Message{{Role: "user", Content: prompt}}, nil)
+	if err != nil {
+		return "", fmt.Errorf("chapter summary failed: %w", err)
+	}
+	summary := truncateRunes(strings.TrimSpace(cleanDraft(response)), chapterSummaryRunes)
+	if summary == "" {
+		return "", fmt.Errorf("chapter summary is empty")
+	}
+	return summary, nil
+}
+
+// Format 生成批量生成运行报告
+func (b *BatchRun) Format() string {
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("# 批量生成报告：第%d-%d章\n\n", b.From, b.To))
+	result.WriteString(fmt.Sprintf("- 状态: %s\n", batchStatusLabel(b.Status)))
+	result.WriteString(fmt.Sprintf("- 开始: %s\n", b.StartedAt.Format("2006-01-02 15:04:05")))
+	if !b.FinishedAt.IsZero() {
+		result.WriteString(fmt.Sprintf("- 结束: %s（用时 %s）\n", b.FinishedAt.Format("2006-01-02 15:04:05"), b.FinishedAt.Sub(b.StartedAt).Round(time.Second)))
+	}
+
+	done, skipped, words, issues := 0, 0, 0, 0
+	for _, entry := range b.Chapters {
+		switch entry.Status {
+		case BatchChapterDone:
+			done++
+			words += entry.Words
+			issues += entry.Issues
+		case BatchChapterSkipped:
+			skipped++
+		}
+	}
+	result.WriteString(fmt.Sprintf("- 完成: %d/%d 章", done, len(b.Chapters)))
+	if skipped > 0 {
+		result.WriteString(fmt.Sprintf("（跳过已有正文 %d 章）", skipped))
+	}
+	result.WriteString(fmt.Sprintf("，共 %d 字，检查问题 %d 个\n", words, issues))
+	result.WriteString(fmt.Sprintf("- 用量: %d 次调用，%d tokens（输入 %d / 输出 %d）", b.Usage.Calls, b.Usage.TotalTokens(), b.Usage.PromptTokens, b.Usage.CompletionTokens))
+	if b.MaxTokens > 0 {
+		result.WriteString(fmt.Sprintf("，预算 %d", b.MaxTokens))
+	}
+	result.WriteString("\n")
+	if b.Cost > 0 || b.MaxCost > 0 {
+		result.WriteString(fmt.Sprintf("- 费用: 约 %.2f", b.Cost))
+		if b.MaxCost > 0 {
+			result.WriteString(fmt.Sprintf("，预算 %.2f", b.MaxCost))
+		}
+		result.WriteString("\n")
+	}
+	if b.LastError != "" {
+		result.WriteString(fmt.Sprintf("- 停止原因: %s\n", b.LastError))
+	}
+
+	result.WriteString("\n| 章节 | 状态 | 字数 | 问题 | tokens | 备注 |\n|---|---|---|---|---|---|\n")
+	for _, entry := range b.Chapters {
+		note := entry.Error
+		if note == "" && entry.AutoBeat {
+			note = "无章节概要，大纲由前情推出"
+		}
+		result.WriteString(fmt.Sprintf("| 第%d章 | %s | %d | %d | %d | %s |\n", entry.Chapter, batchChapterStatusLabel(entry.Status),
+			entry.Words, entry.Issues, entry.Usage.TotalTokens(), strings.ReplaceAll(note, "|", "/")))
+	}
+
+	summaries := false
+	for _, entry := range b.Chapters {
+		if entry.Summary == "" {
+			continue
+		}
+		if !summaries {
+			result.WriteString("\n## 章节摘要\n")
+			summaries = true
+		}
+		result.WriteString(fmt.Sprintf("\n**第%d章** %s\n", entry.Chapter, entry.Summary))
+	}
+
+	if b.Status != BatchCompleted {
+		result.WriteString(fmt.Sprintf("\n再次运行 `/generate chapters %d-%d` 即从中断处继续。\n", b.From, b.To))
+	}
+	return result.String()
+}
+
+// generateBatchChapter 生成单章并写入摘要
+func (nm *NovelManager) generateBatchChapter(ctx context.Context, model chatModel, batch *BatchRun, entry *BatchChapter,
+	recap string, opts BatchOptions, notify func(string, ...interface{})) error {
+	if entry.StartedAt.IsZero() {
+		entry.StartedAt = time.Now()
+	}
+	entry.Status = BatchChapterPending
+
+	// 每次调用模型前把本章已用的量计入预算，用尽时在最近的检查点停止
+	before := model.Usage()
+	budget := func() error {
+		used := model.Usage().Sub(before)
+		return batch.checkBudget(used, model.Cost(used))
+	}
+
+	// 正文已存在且不是本流水线写的，不覆盖，只概括作为前情
+	run, runErr := nm.LoadPipelineRun(entry.Chapter)
+	if _, err := os.Stat(nm.ChapterFilePath(entry.Chapter)); err == nil && runErr != nil && !opts.Overwrite {
+		notify("⏭️ 第%d章正文已存在，跳过", entry.Chapter)
+		if err := budget(); err != nil {
+			return err
+		}
+		summary, err := nm.chapterSummary(ctx, model, entry.Chapter)
+		if err != nil {
+			return err
+		}
+		entry.Summary = summary
+		entry.Status = BatchChapterSkipped
+		return nil
+	}
+
+	if entry.Beat == "" {
+		if run != nil && run.Beat != "" {
+			entry.Beat = run.Beat
+		} else {
+			entry.Beat = nm.chapterBeat(entry.Chapter)
+		}
+	}
+	if entry.Beat == "" {
+		notify("🧭 第%d章没有章节概要，根据前情推出大纲", entry.Chapter)
+		if err := budget(); err != nil {
+			return err
+		}
+		beat, err := nm.suggestBeat(ctx, model, entry.Chapter, recap)
+		if err != nil {
+			return err
+		}
+		entry.Beat = beat
+		entry.AutoBeat = true
+		if err := nm.saveBatchRun(batch); err != nil {
+			return err
+		}
+	}
+
+	notify("✍️ 正在生成第%d章…", entry.Chapter)
+	run, err := nm.runPipeline(ctx, model, entry.Chapter, PipelineOptions{
+		Beat:        entry.Beat,
+		Recap:       recap,
+		TargetWords: opts.TargetWords,
+		Overwrite:   opts.Overwrite,
+		Budget:      budget,
+	})
+	if err != nil {
+		return err
+	}
+	entry.Words = run.WordCount
+	entry.Issues = run.IssueCount()
+
+	if entry.Summary == "" {
+		if err := budget(); err != nil {
+			return err
+		}
+		summary, err := nm.summarizeChapter(ctx, model, entry.Chapter)
+		if err != nil {
+			return err
+		}
+		entry.Summary = summary
+	}
+	entry.Status = BatchChapterDone
+	notify("✅ 第%d章完成：%d 字，检查问题 %d 个", entry.Chapter, entry.Words, entry.Issues)
+	return nil
+}
+
+// suggestBeat 章节没有概要时，根据前情与活跃情节线推出本章大纲
+func (nm *NovelManager) suggestBeat(ctx context.Context, model chatModel, chapterNum int, recap string) (string, error) {
+	nm.mutex.RLock()
+	if nm.novelData == nil {
+		nm.mutex.RUnlock()
+		return "", fmt.Errorf("novel project not initialized")
+	}
+	var plots strings.Builder
+	for name, plot := range nm.novelData.PlotLines {
+		if plot.StartChapter <= chapterNum && (plot.EndChapter == 0 || plot.EndChapter >= chapterNum) {
+			plots.WriteString(fmt.Sprintf("• %s（%s）: %s\n", name, plot.Status, plot.Description))
+		}
+	}
+	reminders := nm.foreshadowingReminders(chapterNum)
+	nm.mutex.RUnlock()
+
+	if recap == "" {
+		recap = "（无）"
+	}
+	prompt := fmt.Sprintf(`请为小说第%d章写一段本章大纲（100字以内），承接前情、推进活跃情节线。
+
+=== 前情提要 ===
+%s
+
+=== 活跃情节线 ===
+%s
+%s
+只输出大纲本身。`, chapterNum, recap, plots.String(), reminders)
+
+	response, _, err := model.Chat(ctx, []ai.Message{{Role: "user", Content: prompt}}, nil)
+	if err != nil {
+		return "", fmt.Errorf("beat suggestion failed: %w", err)
+	}
+	beat := strings.TrimSpace(cleanDraft(response))
+	if beat == "" {
+		return "", fmt.Errorf("suggested beat is empty")
+	}
+	return beat, nil
+}
+
+// chapterSummary 已有章节优先使用登记的概要，没有时再让模型概括
+func (nm *NovelManager) chapterSummary(ctx context.Context, model chatModel, chapterNum int) (string, error) {
+	if summary := nm.chapterBeat(chapterNum); summary != "" {
+		return summary, nil
+	}
+	return nm.summarizeChapter(ctx, model, chapterNum)
+}
+
+// chapterRecap 批量开始前一章的登记概要，作为第一章的前情提要
+func (nm *NovelManager) chapterRecap(chapterNum int) string {
+	if chapterNum <= 0 {
+		return ""
+	}
+	return nm.chapterBeat(chapterNum)
+}
+
+func (nm *NovelManager) chapterBeat(chapterNum int) string {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	if chapter := nm.findChapter(chapterNum); chapter != nil {
+		return strings.TrimSpace(chapter.Summary)
+	}
+	return ""
+}
+
+// stopBatch 记录停止原因，保存检查点与报告
+func (nm *NovelManager) stopBatch(batch *BatchRun, status string, err error) error {
+	batch.Status = status
+	batch.LastError = err.Error()
+	if saveErr := nm.saveBatchRun(batch); saveErr != nil {
+		return fmt.Errorf("%w (failed to save batch state: %v)", err, saveErr)
+	}
+	if flushErr := nm.Flush(); flushErr != nil {
+		return fmt.Errorf("%w (failed to save project: %v)", err, flushErr)
+	}
+	return err
+}
+
+// saveBatchRun 保存检查点，并同步更新运行报告
+func (nm *NovelManager) saveBatchRun(batch *BatchRun) error {
+	batch.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(batch, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch state: %w", err)
+	}
+	path := nm.batchPath(batch.From, batch.To, ".json")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create pipeline directory: %w", err)
+	}
+	if err := writeFileAtomic(path, data, false); err != nil {
+		return err
+	}
+	return writeFileAtomic(nm.BatchReportPath(batch.From, batch.To), []byte(batch.Format()), false)
+}
+
+func (nm *NovelManager) batchPath(from, to int, suffix string) string {
+	return filepath.Join(nm.projectPath, PipelineDir, fmt.Sprintf("batch_%03d-%03d%s", from, to, suffix))
+}
+
+func newBatchRun(from, to int) *BatchRun {
+	now := time.Now()
+	batch := &BatchRun{From: from, To: to, Status: BatchRunning, StartedAt: now, UpdatedAt: now}
+	for number := from; number <= to; number++ {
+		batch.Chapters = append(batch.Chapters, &BatchChapter{Chapter: number, Status: BatchChapterPending})
+	}
+	return batch
+}
+
+// checkBudget 检查预算，pending 和 pendingCost 为当前章节已用但尚未计入批次的用量
+func (b *BatchRun) checkBudget(pending ai.Usage, pendingCost float64) error {
+	tokens := b.Usage.Add(pending).TotalTokens()
+	if b.MaxTokens > 0 && tokens >= b.MaxTokens {
+		return fmt.Errorf("%w: used %d of %d tokens", ErrBudgetExceeded, tokens, b.MaxTokens)
+	}
+	if cost := b.Cost + pendingCost; b.MaxCost > 0 && cost >= b.MaxCost {
+		return fmt.Errorf("%w: cost %.2f of %.2f", ErrBudgetExceeded, cost, b.MaxCost)
+	}
+	return nil
+}
+
+func batchStatusLabel(status string) string {
+	switch status {
+	case BatchRunning:
+		return "进行中"
+	case BatchCompleted:
+		return "已完成"
+	case BatchFailed:
+		return "失败"
+	case BatchInterrupted:
+		return "已中断"
+	case BatchBudgetExhausted:
+		return "预算用尽"
+	}
+	return status
+}
+
+func batchChapterStatusLabel(status string) string {
+	switch status {
+	case BatchChapterPending:
+		return "待生成"
+	case BatchChapterDone:
+		return "✅ 完成"
+	case BatchChapterSkipped:
+		return "⏭️ 跳过"
+	case BatchChapterFailed:
+		return "❌ 失败"
+	}
+	return status
+}
@@ -0,0 +1,240 @@
+package novel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/AiNovelTools/internal/ai"
+)
+
+var (
+	stubBeatPrompt    = regexp.MustCompile(`请为小说第(\d+)章写一段本章大纲`)
+	stubSummaryPrompt = regexp.MustCompile(`概括小说第(\d+)章`)
+)
+
+// newBatchStub 离线模型桩：推出大纲、概括章节并按流水线提示词起草，各章的大纲与摘要带上章节号
+func newBatchStub() *stubChat {
+	return &stubChat{respond: func(prompt string) (string, error) {
+		if m := stubBeatPrompt.FindStringSubmatch(prompt); m != nil {
+			return fmt.Sprintf("第%s章大纲", m[1]), nil
+		}
+		if m := stubSummaryPrompt.FindStringSubmatch(prompt); m != nil {
+			return fmt.Sprintf("第%s章摘要", m[1]), nil
+		}
+		return stubPipelineReply(prompt)
+	}}
+}
+
+// promptsFor 返回包含 marker 的提示词
+func promptsFor(prompts []string, marker string) []string {
+	matched := make([]string, 0)
+	for _, prompt := range prompts {
+		if strings.Contains(prompt, marker) {
+			matched = append(matched, prompt)
+		}
+	}
+	return matched
+}
+
+func TestBatchCheckBudget(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxTokens   int
+		maxCost     float64
+		used        int // 批次已计入的 token
+		pending     int // 当前章节尚未计入的 token
+		pendingCost float64
+		wantErr     bool
+	}{
+		{name: "不限预算", used: 1000000},
+		{name: "低于token预算", maxTokens: 1000, used: 500, pending: 499},
+		{name: "达到token预算", maxTokens: 1000, used: 500, pending: 500, wantErr: true},
+		{name: "超过token预算", maxTokens: 1000, used: 1200, wantErr: true},
+		{name: "低于费用预算", maxCost: 1, used: 500, pendingCost: 0.49},
+		{name: "达到费用预算", maxCost: 1, used: 500, pendingCost: 0.5, wantErr: true},
+		{name: "超过费用预算", maxCost: 1, used: 2000, pendingCost: 0.1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch := &BatchRun{
+				MaxTokens: tt.maxTokens,
+				MaxCost:   tt.maxCost,
+				Usage:     ai.Usage{PromptTokens: tt.used},
+				Cost:      float64(tt.used) / 1000,
+			}
+			err := batch.checkBudget(ai.Usage{CompletionTokens: tt.pending}, tt.pendingCost)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkBudget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrBudgetExceeded) {
+				t.Errorf("checkBudget() error = %v, want ErrBudgetExceeded", err)
+			}
+		})
+	}
+}
+
+func TestRunBatchCarriesSummaries(t *testing.T) {
+	nm := newTestManager(t)
+	model := newBatchStub()
+
+	batch, err := nm.runBatch(context.Background(), model, BatchOptions{From: 1, To: 3, TargetWords: 2000})
+	if err != nil {
+		t.Fatalf("runBatch: %v", err)
+	}
+	if batch.Status != BatchCompleted {
+		t.Fatalf("Status = %q, want completed", batch.Status)
+	}
+	for _, entry := range batch.Chapters {
+		if entry.Status != BatchChapterDone || !entry.AutoBeat {
+			t.Errorf("chapter %d: status %q, auto beat %v", entry.Chapter, entry.Status, entry.AutoBeat)
+		}
+		if want := fmt.Sprintf("第%d章摘要", entry.Chapter); entry.Summary != want {
+			t.Errorf("chapter %d summary = %q, want %q", entry.Chapter, entry.Summary, want)
+		}
+		if _, err := nm.ReadChapterText(entry.Chapter); err != nil {
+			t.Errorf("chapter %d not written: %v", entry.Chapter, err)
+		}
+	}
+	if want := 15; batch.Usage.Calls != want {
+		t.Errorf("Usage.Calls = %d, want %d", batch.Usage.Calls, want)
+	}
+
+	// 每章的摘要作为下一章推出大纲和规划场景时的前情提要
+	for chapter := 2; chapter <= 3; chapter++ {
+		recap := fmt.Sprintf("第%d章摘要", chapter-1)
+		beats := promptsFor(model.prompts, fmt.Sprintf("请为小说第%d章写一段本章大纲", chapter))
+		if len(beats) != 1 || !strings.Contains(beats[0], recap) {
+			t.Errorf("chapter %d beat prompt lacks recap %q", chapter, recap)
+		}
+		plans := promptsFor(model.prompts, fmt.Sprintf("请为第%d章设计场景计划", chapter))
+		if len(plans) != 1 || !strings.Contains(plans[0], recap) {
+			t.Errorf("chapter %d plan prompt lacks recap %q", chapter, recap)
+		}
+	}
+}
+
+func TestRunBatchKeepsHandWrittenChapters(t *testing.T) {
+	nm := newTestManager(t)
+	handWritten := "林风在后山独自练剑。\n"
+	writeTestChapter(t, nm, 2, handWritten)
+	nm.novelData.Chapters = append(nm.novelData.Chapters, &Chapter{Number: 2, Title: "练剑", Summary: "林风在后山苦练剑法"})
+	model := newBatchStub()
+
+	batch, err := nm.runBatch(context.Background(), model, BatchOptions{From: 2, To: 3})
+	if err != nil {
+		t.Fatalf("runBatch: %v", err)
+	}
+	entry := batch.Chapters[0]
+	if entry.Status != BatchChapterSkipped || entry.Summary != "林风在后山苦练剑法" {
+		t.Errorf("chapter 2: status %q, summary %q", entry.Status, entry.Summary)
+	}
+	if text, err := nm.ReadChapterText(2); err != nil || text != handWritten {
+		t.Errorf("hand-written chapter = %q, %v", text, err)
+	}
+	if got := promptsFor(model.prompts, "第2章"); len(got) != 0 {
+		t.Errorf("model called %d times for the hand-written chapter", len(got))
+	}
+
+	// 登记的概要作为下一章的前情
+	beats := promptsFor(model.prompts, "请为小说第3章写一段本章大纲")
+	if len(beats) != 1 || !strings.Contains(beats[0], "林风在后山苦练剑法") {
+		t.Errorf("chapter 3 beat prompt does not follow the hand-written chapter")
+	}
+	if batch.Chapters[1].Status != BatchChapterDone {
+		t.Errorf("chapter 3 status = %q", batch.Chapters[1].Status)
+	}
+}
+
+func TestRunBatchResumesFromCheckpoint(t *testing.T) {
+	nm := newTestManager(t)
+	checkpoint := newBatchRun(1, 3)
+	checkpoint.Status = BatchInterrupted
+	checkpoint.Chapters[0].Status = BatchChapterDone
+	checkpoint.Chapters[0].Summary = "第1章摘要"
+	checkpoint.Chapters[1].Status = BatchChapterSkipped
+	checkpoint.Chapters[1].Summary = "第2章登记的概要"
+	if err := nm.saveBatchRun(checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	model := newBatchStub()
+
+	batch, err := nm.runBatch(context.Background(), model, BatchOptions{From: 1, To: 3})
+	if err != nil {
+		t.Fatalf("runBatch: %v", err)
+	}
+	if batch.Status != BatchCompleted || batch.Chapters[2].Status != BatchChapterDone {
+		t.Fatalf("Status = %q, chapter 3 status = %q", batch.Status, batch.Chapters[2].Status)
+	}
+	if batch.Chapters[0].Summary != "第1章摘要" || batch.Chapters[1].Summary != "第2章登记的概要" {
+		t.Errorf("finished entries changed: %+v, %+v", batch.Chapters[0], batch.Chapters[1])
+	}
+	for _, chapter := range []int{1, 2} {
+		if _, err := nm.ReadChapterText(chapter); err == nil {
+			t.Errorf("chapter %d regenerated", chapter)
+		}
+	}
+	if len(model.prompts) != 5 {
+		t.Errorf("got %d model calls, want 5 for chapter 3 only", len(model.prompts))
+	}
+	beats := promptsFor(model.prompts, "请为小说第3章写一段本章大纲")
+	if len(beats) != 1 || !strings.Contains(beats[0], "第2章登记的概要") {
+		t.Error("chapter 3 does not follow the last finished chapter")
+	}
+}
+
+func TestRunBatchBudgetExhausted(t *testing.T) {
+	nm := newTestManager(t)
+	model := newBatchStub()
+
+	// 每章 5 次调用、1000 token：第二章起草完第一个场景后达到 1500 的预算
+	batch, err := nm.runBatch(context.Background(), model, BatchOptions{From: 1, To: 3, MaxTokens: 1500})
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("runBatch error = %v, want ErrBudgetExceeded", err)
+	}
+	if batch.Status != BatchBudgetExhausted {
+		t.Errorf("Status = %q, want budget_exhausted", batch.Status)
+	}
+
+	saved, err := nm.LoadBatchRun(1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Status != BatchBudgetExhausted || !strings.Contains(saved.LastError, "chapter 2") {
+		t.Errorf("saved Status = %q, LastError = %q", saved.Status, saved.LastError)
+	}
+	if saved.Chapters[0].Status != BatchChapterDone || saved.Chapters[1].Status != BatchChapterPending {
+		t.Errorf("saved chapter statuses = %q, %q", saved.Chapters[0].Status, saved.Chapters[1].Status)
+	}
+	if tokens := saved.Usage.TotalTokens(); tokens != 1600 {
+		t.Errorf("saved usage = %d tokens, want 1600", tokens)
+	}
+	report, err := os.ReadFile(nm.BatchReportPath(1, 3))
+	if err != nil || !strings.Contains(string(report), "预算用尽") {
+		t.Errorf("report does not record the budget stop: %v", err)
+	}
+	run, err := nm.LoadPipelineRun(2)
+	if err != nil || run.Scenes[0].Draft == "" || run.Scenes[1].Draft != "" {
+		t.Fatalf("chapter 2 pipeline checkpoint = %+v, %v", run, err)
+	}
+
+	// 追加预算后从第二章的第二个场景继续
+	model = newBatchStub()
+	batch, err = nm.runBatch(context.Background(), model, BatchOptions{From: 1, To: 3})
+	if err != nil {
+		t.Fatalf("resumed runBatch: %v", err)
+	}
+	if batch.Status != BatchCompleted {
+		t.Errorf("Status = %q, want completed", batch.Status)
+	}
+	if got := promptsFor(model.prompts, "请为第2章设计场景计划"); len(got) != 0 {
+		t.Error("chapter 2 replanned after resuming")
+	}
+	if len(model.prompts) != 7 {
+		t.Errorf("got %d model calls, want scene 2 and summary of chapter 2 plus chapter 3", len(model.prompts))
+	}
+}
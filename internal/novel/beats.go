@@ -0,0 +1,472 @@
+package novel
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BeatTemplateDir 用户自定义节拍模板相对于配置目录的位置，其中的 *.yaml 与内置模板同名时覆盖内置模板
+const BeatTemplateDir = "templates/beats"
+
+// DefaultBeatTemplate 项目题材没有匹配的模板时使用的节拍模板
+const DefaultBeatTemplate = "three_act"
+
+// 既没有目标字数也没有章节时，大纲按该章节数估算
+const defaultOutlineChapters = 100
+
+// 节拍的写作进度
+const (
+	BeatPending = "pending" // 尚未写到
+	BeatPartial = "partial" // 部分章节已有正文
+	BeatWritten = "written" // 范围内的章节都已有正文
+	BeatSkipped = "skipped" // 后续章节已写，本节拍范围内仍有空缺
+)
+
+// BeatTemplate 节拍模板，描述各节拍在全书中的相对位置
+type BeatTemplate struct {
+	Name        string           `yaml:"name"`
+	Title       string           `yaml:"title"`
+	Description string           `yaml:"description"`
+	Genres      []string         `yaml:"genres,omitempty"` // 适用题材，用于未选模板时的默认推荐
+	Beats       []BeatDefinition `yaml:"beats"`
+	Source      string           `yaml:"-"` // 内置模板为 "builtin"，否则为文件路径
+}
+
+// BeatDefinition 模板中的一个节拍，Start/End 为在全书中的位置（百分比），两者相同表示落在单独一章
+type BeatDefinition struct {
+	Name        string  `yaml:"name"`
+	Description string  `yaml:"description"`
+	Start       float64 `yaml:"start"`
+	End         float64 `yaml:"end"`
+}
+
+// BookOutline 由节拍模板生成、映射到具体章节的全书大纲
+type BookOutline struct {
+	Template      string         `json:"template"`
+	Title         string         `json:"title"`
+	TotalChapters int            `json:"total_chapters"`
+	Beats         []*OutlineBeat `json:"beats"`
+}
+
+// OutlineBeat 大纲中的节拍
+type OutlineBeat struct {
+	Name         string `json:"name"`
+	Description  string `json:"description,omitempty"`
+	StartChapter int    `json:"start_chapter"`
+	EndChapter   int    `json:"end_chapter"`
+	Note         string `json:"note,omitempty"` // 作者对本节拍的具体安排
+}
+
+// Span 节拍所在的章节范围，如“第45章”“第11-20章”
+func (b OutlineBeat) Span() string {
+	if b.EndChapter == b.StartChapter {
+		return fmt.Sprintf("第%d章", b.StartChapter)
+	}
+	return fmt.Sprintf("第%d-%d章", b.StartChapter, b.EndChapter)
+}
+
+// BeatCoverage 节拍的写作进度
+type BeatCoverage struct {
+	OutlineBeat
+	Written     int    // 范围内已有正文的章节数
+	Status      string // BeatPending 等
+	NextChapter int    // 范围内第一个还没有正文的章节，写完时为 0
+}
+
+// OutlineCoverage 全书大纲的写作进度
+type OutlineCoverage struct {
+	Template      string
+	Title         string
+	TotalChapters int
+	LatestChapter int  // 已有正文的最后一章
+	Preview       bool // 项目尚未选用模板，按默认模板预估
+	Beats         []BeatCoverage
+}
+
+// LoadBeatTemplates 加载内置节拍模板和 userDir 中的自定义模板，无法解析的文件跳过并在 warnings 中说明
+func LoadBeatTemplates(userDir string) ([]*BeatTemplate, []string) {
+	files, warnings := readTemplateFiles("templates/beats", userDir)
+	templates := make(map[string]*BeatTemplate)
+	for _, file := range files {
+		template, err := parseBeatTemplate(file.data, file.name)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %v", file.source, err))
+			continue
+		}
+		template.Source = file.source
+		templates[template.Name] = template
+	}
+
+	result := make([]*BeatTemplate, 0, len(templates))
+	for _, template := range templates {
+		result = append(result, template)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, warnings
+}
+
+// parseBeatTemplate 解析并校验节拍模板，未写 name 时使用文件名
+func parseBeatTemplate(data []byte, fileName string) (*BeatTemplate, error) {
+	var template BeatTemplate
+	if err := yaml.Unmarshal(data, &template); err != nil {
+		return nil, fmt.Errorf("invalid yaml: %w", err)
+	}
+	if strings.TrimSpace(template.Name) == "" {
+		template.Name = strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	}
+	if template.Title == "" {
+		template.Title = template.Name
+	}
+	if len(template.Beats) == 0 {
+		return nil, fmt.Errorf("template %s has no beats", template.Name)
+	}
+	for i, beat := range template.Beats {
+		if strings.TrimSpace(beat.Name) == "" {
+			return nil, fmt.Errorf("beat %d has no name", i+1)
+		}
+		if beat.Start < 0 || beat.End > 100 || beat.Start > beat.End {
+			return nil, fmt.Errorf("beat %s: start/end must satisfy 0 <= start <= end <= 100", beat.Name)
+		}
+	}
+	return &template, nil
+}
+
+// FindBeatTemplate 按模板名或标题查找
+func FindBeatTemplate(templates []*BeatTemplate, name string) *BeatTemplate {
+	name = strings.TrimSpace(name)
+	for _, template := range templates {
+		if template.Name == name || template.Title == name {
+			return template
+		}
+	}
+	return nil
+}
+
+// SuggestBeatTemplate 按题材推荐模板，没有匹配时使用 DefaultBeatTemplate
+func SuggestBeatTemplate(templates []*BeatTemplate, genre string) *BeatTemplate {
+	genre = strings.TrimSpace(genre)
+	if genre != "" {
+		for _, template := range templates {
+			for _, g := range template.Genres {
+				if g != "" && (strings.Contains(genre, g) || strings.Contains(g, genre)) {
+					return template
+				}
+			}
+		}
+	}
+	if template := FindBeatTemplate(templates, DefaultBeatTemplate); template != nil {
+		return template
+	}
+	if len(templates) > 0 {
+		return templates[0]
+	}
+	return nil
+}
+
+// Instantiate 按全书章节数把节拍映射到章节范围
+func (t *BeatTemplate) Instantiate(totalChapters int) *BookOutline {
+	outline := &BookOutline{Template: t.Name, Title: t.Title, TotalChapters: totalChapters}
+	for _, beat := range t.Beats {
+		start, end := beatChapters(beat.Start, beat.End, totalChapters)
+		outline.Beats = append(outline.Beats, &OutlineBeat{
+			Name:         beat.Name,
+			Description:  beat.Description,
+			StartChapter: start,
+			EndChapter:   end,
+		})
+	}
+	return outline
+}
+
+// beatChapters 百分比位置对应的章节范围
+func beatChapters(start, end float64, total int) (int, int) {
+	if total <= 0 {
+		return 0, 0
+	}
+	if start == end {
+		chapter := clampInt(int(math.Round(start/100*float64(total))), 1, total)
+		return chapter, chapter
+	}
+	first := clampInt(int(math.Floor(start/100*float64(total)))+1, 1, total)
+	last := clampInt(int(math.Ceil(end/100*float64(total))), first, total)
+	return first, last
+}
+
+// ApplyBeatTemplate 用节拍模板生成项目大纲，已有大纲时需 overwrite
+func (nm *NovelManager) ApplyBeatTemplate(template *BeatTemplate, totalChapters int, overwrite bool) (*BookOutline, error) {
+	if totalChapters <= 0 {
+		return nil, fmt.Errorf("total chapters must be positive")
+	}
+
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	if nm.novelData == nil {
+		return nil, fmt.Errorf("novel project not initialized")
+	}
+	if existing := nm.novelData.Outline; existing != nil && !overwrite {
+		return nil, fmt.Errorf("project already uses outline template %s, set overwrite to replace it", existing.Title)
+	}
+	outline := template.Instantiate(totalChapters)
+	nm.novelData.Outline = outline
+	if err := nm.SaveProject(); err != nil {
+		return nil, err
+	}
+	return outline, nil
+}
+
+// AdjustBeat 调整大纲中某个节拍的章节范围或备注，chapter 为 0 时保持不变
+func (nm *NovelManager) AdjustBeat(name string, startChapter, endChapter int, note string) (*OutlineBeat, error) {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	if nm.novelData == nil {
+		return nil, fmt.Errorf("novel project not initialized")
+	}
+	if nm.novelData.Outline == nil {
+		return nil, fmt.Errorf("project has no outline, apply a beat template first")
+	}
+	var beat *OutlineBeat
+	for _, candidate := range nm.novelData.Outline.Beats {
+		if candidate.Name == strings.TrimSpace(name) {
+			beat = candidate
+			break
+		}
+	}
+	if beat == nil {
+		return nil, fmt.Errorf("beat not found: %s", name)
+	}
+
+	start, end := beat.StartChapter, beat.EndChapter
+	if startChapter > 0 {
+		start = startChapter
+		if endChapter <= 0 && end < start {
+			end = start
+		}
+	}
+	if endChapter > 0 {
+		end = endChapter
+	}
+	if start > end {
+		return nil, fmt.Errorf("start chapter %d is after end chapter %d", start, end)
+	}
+	beat.StartChapter, beat.EndChapter = start, end
+	if end > nm.novelData.Outline.TotalChapters {
+		nm.novelData.Outline.TotalChapters = end
+	}
+	if note != "" {
+		beat.Note = strings.TrimSpace(note)
+	}
+	if err := nm.SaveProject(); err != nil {
+		return nil, err
+	}
+	copied := *beat
+	return &copied, nil
+}
+
+// EstimateTotalChapters 按目标字数和已写章节的平均字数估算全书章节数，不低于已有章节数
+func (nm *NovelManager) EstimateTotalChapters() int {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	if nm.novelData == nil {
+		return defaultOutlineChapters
+	}
+	written, words := 0, 0
+	for _, chapter := range nm.novelData.Chapters {
+		if chapter.WordCount > 0 {
+			written++
+			words += chapter.WordCount
+		}
+	}
+	total := defaultOutlineChapters
+	if target := nm.novelData.TargetWords; target > 0 {
+		average := defaultChapterWords
+		if written > 0 {
+			average = words / written
+		}
+		total = (target + average - 1) / average
+	}
+	if count := len(nm.novelData.Chapters); count > total {
+		total = count
+	}
+	return total
+}
+
+// SuggestOutline 未选用模板时，按项目题材推荐模板并按估算的章节数生成大纲预览（不保存）
+func (nm *NovelManager) SuggestOutline(templates []*BeatTemplate) *BookOutline {
+	genre := ""
+	nm.mutex.RLock()
+	if nm.novelData != nil {
+		genre = nm.novelData.Genre
+	}
+	nm.mutex.RUnlock()
+
+	template := SuggestBeatTemplate(templates, genre)
+	if template == nil {
+		return nil
+	}
+	return template.Instantiate(nm.EstimateTotalChapters())
+}
+
+// ProjectOutline 返回项目大纲的副本，未选用模板时为 nil
+func (nm *NovelManager) ProjectOutline() *BookOutline {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	if nm.novelData == nil || nm.novelData.Outline == nil {
+		return nil
+	}
+	outline := *nm.novelData.Outline
+	outline.Beats = make([]*OutlineBeat, len(nm.novelData.Outline.Beats))
+	for i, beat := range nm.novelData.Outline.Beats {
+		copied := *beat
+		outline.Beats[i] = &copied
+	}
+	return &outline
+}
+
+// OutlineCoverage 同步章节文件后统计大纲各节拍的写作进度；outline 为空时使用项目大纲
+func (nm *NovelManager) OutlineCoverage(outline *BookOutline) (*OutlineCoverage, error) {
+	if _, err := nm.SyncChapterStats(); err != nil {
+		return nil, err
+	}
+
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	if nm.novelData == nil {
+		return nil, fmt.Errorf("novel project not initialized")
+	}
+	preview := outline != nil
+	if outline == nil {
+		outline = nm.novelData.Outline
+	}
+	if outline == nil {
+		return nil, fmt.Errorf("project has no outline, apply a beat template first")
+	}
+
+	written := make(map[int]bool)
+	report := &OutlineCoverage{
+		Template:      outline.Template,
+		Title:         outline.Title,
+		TotalChapters: outline.TotalChapters,
+		Preview:       preview,
+	}
+	for _, chapter := range nm.novelData.Chapters {
+		if chapter.WordCount > 0 {
+			written[chapter.Number] = true
+			if chapter.Number > report.LatestChapter {
+				report.LatestChapter = chapter.Number
+			}
+		}
+	}
+
+	for _, beat := range outline.Beats {
+		coverage := BeatCoverage{OutlineBeat: *beat}
+		for ch := beat.StartChapter; ch <= beat.EndChapter; ch++ {
+			if written[ch] {
+				coverage.Written++
+			} else if coverage.NextChapter == 0 {
+				coverage.NextChapter = ch
+			}
+		}
+		switch {
+		case coverage.NextChapter == 0:
+			coverage.Status = BeatWritten
+		case report.LatestChapter > beat.EndChapter:
+			coverage.Status = BeatSkipped
+		case coverage.Written > 0:
+			coverage.Status = BeatPartial
+		default:
+			coverage.Status = BeatPending
+		}
+		report.Beats = append(report.Beats, coverage)
+	}
+	return report, nil
+}
+
+// Unfinished 尚未写完的节拍，越过未写的排在前面
+func (r *OutlineCoverage) Unfinished() []BeatCoverage {
+	result := make([]BeatCoverage, 0)
+	for _, status := range []string{BeatSkipped, BeatPartial, BeatPending} {
+		for _, beat := range r.Beats {
+			if beat.Status == status {
+				result = append(result, beat)
+			}
+		}
+	}
+	return result
+}
+
+// Format 格式化大纲进度
+func (r *OutlineCoverage) Format() string {
+	labels := map[string]string{
+		BeatPending: "⏳",
+		BeatPartial: "✍️",
+		BeatWritten: "✅",
+		BeatSkipped: "⚠️",
+	}
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("📐 === 全书大纲：%s ===\n\n", r.Title))
+	if r.LatestChapter > 0 {
+		result.WriteString(fmt.Sprintf("全书规划 %d 章，正文已写到第%d章\n\n", r.TotalChapters, r.LatestChapter))
+	} else {
+		result.WriteString(fmt.Sprintf("全书规划 %d 章，尚未开始写正文\n\n", r.TotalChapters))
+	}
+
+	for _, beat := range r.Beats {
+		span := beat.Span()
+		line := fmt.Sprintf("%s %s（%s）", labelOr(labels, beat.Status), beat.Name, span)
+		switch beat.Status {
+		case BeatPending:
+			line += fmt.Sprintf(" 计划于%s，尚未写到", span)
+		case BeatPartial:
+			line += fmt.Sprintf(" 已写 %d/%d 章，下一章为第%d章", beat.Written, beat.EndChapter-beat.StartChapter+1, beat.NextChapter)
+		case BeatSkipped:
+			line += fmt.Sprintf(" 后续章节已写，但第%d章等仍是空缺", beat.NextChapter)
+		}
+		result.WriteString(line + "\n")
+		if beat.Note != "" {
+			result.WriteString(fmt.Sprintf("   安排: %s\n", beat.Note))
+		} else if beat.Description != "" && beat.Status != BeatWritten {
+			result.WriteString(fmt.Sprintf("   %s\n", beat.Description))
+		}
+	}
+	if r.TotalChapters > 0 && r.LatestChapter > r.TotalChapters {
+		result.WriteString(fmt.Sprintf("\n💡 正文已超过规划的 %d 章，可重新套用模板并指定新的总章数\n", r.TotalChapters))
+	}
+	return result.String()
+}
+
+// outlineBeatsAt 第 chapterNum 章所处的节拍，调用方需持有锁
+func (nm *NovelManager) outlineBeatsAt(chapterNum int) []*OutlineBeat {
+	beats := make([]*OutlineBeat, 0)
+	if nm.novelData == nil || nm.novelData.Outline == nil {
+		return beats
+	}
+	for _, beat := range nm.novelData.Outline.Beats {
+		if beat.StartChapter <= chapterNum && chapterNum <= beat.EndChapter {
+			beats = append(beats, beat)
+		}
+	}
+	return beats
+}
+
+// outlineBeatLines 本章所处节拍的说明，供章节上下文和生成提示使用，调用方需持有锁
+func (nm *NovelManager) outlineBeatLines(chapterNum int) string {
+	var lines strings.Builder
+	for _, beat := range nm.outlineBeatsAt(chapterNum) {
+		detail := beat.Description
+		if beat.Note != "" {
+			detail = beat.Note
+		}
+		lines.WriteString(fmt.Sprintf("• %s（%s）: %s\n", beat.Name, beat.Span(), detail))
+	}
+	return lines.String()
+}
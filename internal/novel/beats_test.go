@@ -0,0 +1,109 @@
+package novel
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBeatChapters(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end float64
+		total      int
+		wantStart  int
+		wantEnd    int
+	}{
+		{"开头区间", 0, 10, 100, 1, 10},
+		{"中间区间不与前一节拍重叠", 10, 25, 100, 11, 25},
+		{"单章节拍", 50, 50, 100, 50, 50},
+		{"单章节拍落在开头", 0, 0, 100, 1, 1},
+		{"单章节拍落在结尾", 100, 100, 100, 100, 100},
+		{"章节很少时向外取整", 10, 25, 7, 1, 2},
+		{"结尾区间不超过总章数", 95, 100, 7, 7, 7},
+		{"区间不足一章时至少占一章", 10, 11, 3, 1, 1},
+		{"没有章节", 20, 30, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := beatChapters(tt.start, tt.end, tt.total)
+			if start != tt.wantStart || end != tt.wantEnd {
+				t.Errorf("beatChapters(%v, %v, %d) = %d, %d, want %d, %d", tt.start, tt.end, tt.total, start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestBuiltinBeatTemplatesCoverWholeBook(t *testing.T) {
+	templates, warnings := LoadBeatTemplates("")
+	if len(warnings) > 0 {
+		t.Fatalf("warnings: %v", warnings)
+	}
+	if FindBeatTemplate(templates, DefaultBeatTemplate) == nil {
+		t.Fatalf("default template %s not found", DefaultBeatTemplate)
+	}
+	for _, template := range templates {
+		for _, total := range []int{12, 100, 500} {
+			outline := template.Instantiate(total)
+			if len(outline.Beats) == 0 {
+				t.Fatalf("%s: no beats", template.Name)
+			}
+			if first := outline.Beats[0]; first.StartChapter != 1 {
+				t.Errorf("%s/%d: first beat starts at chapter %d", template.Name, total, first.StartChapter)
+			}
+			if last := outline.Beats[len(outline.Beats)-1]; last.EndChapter != total {
+				t.Errorf("%s/%d: last beat ends at chapter %d", template.Name, total, last.EndChapter)
+			}
+			for _, beat := range outline.Beats {
+				if beat.StartChapter < 1 || beat.EndChapter > total || beat.StartChapter > beat.EndChapter {
+					t.Errorf("%s/%d: beat %s has invalid range %s", template.Name, total, beat.Name, beat.Span())
+				}
+			}
+		}
+	}
+}
+
+func TestOutlineCoverage(t *testing.T) {
+	nm := newTestManager(t)
+	for _, number := range []int{1, 2, 5} {
+		writeTestChapter(t, nm, number, "正文内容。")
+	}
+	outline := &BookOutline{Template: "test", Title: "测试", TotalChapters: 8, Beats: []*OutlineBeat{
+		{Name: "开端", StartChapter: 1, EndChapter: 2},
+		{Name: "发展", StartChapter: 3, EndChapter: 4},
+		{Name: "高潮", StartChapter: 5, EndChapter: 6},
+		{Name: "结局", StartChapter: 7, EndChapter: 8},
+	}}
+
+	coverage, err := nm.OutlineCoverage(outline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !coverage.Preview || coverage.LatestChapter != 5 {
+		t.Errorf("preview = %v, latest = %d, want true, 5", coverage.Preview, coverage.LatestChapter)
+	}
+	tests := []struct {
+		status  string
+		written int
+		next    int
+	}{
+		{BeatWritten, 2, 0},
+		{BeatSkipped, 0, 3},
+		{BeatPartial, 1, 6},
+		{BeatPending, 0, 7},
+	}
+	for i, tt := range tests {
+		beat := coverage.Beats[i]
+		if beat.Status != tt.status || beat.Written != tt.written || beat.NextChapter != tt.next {
+			t.Errorf("%s: status %s, written %d, next %d, want %s, %d, %d",
+				beat.Name, beat.Status, beat.Written, beat.NextChapter, tt.status, tt.written, tt.next)
+		}
+	}
+
+	names := make([]string, 0)
+	for _, beat := range coverage.Unfinished() {
+		names = append(names, beat.Name)
+	}
+	if want := []string{"发展", "高潮", "结局"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Unfinished() = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,106 @@
+package novel
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// ChaptersDir 章节正文目录
+const ChaptersDir = "chapters"
+
+var chapterFilePattern = regexp.MustCompile(`^chapter_(\d+)\.txt$`)
+
+// Volume 分卷信息
+type Volume struct {
+	Number       int    `json:"number"`
+	Title        string `json:"title"`
+	Intro        string `json:"intro,omitempty"` // 导入时分卷标题与该卷第一章之间的内容
+	StartChapter int    `json:"start_chapter"`
+	EndChapter   int    `json:"end_chapter"`
+}
+
+// ProjectPath 返回项目目录
+func (nm *NovelManager) ProjectPath() string {
+	return nm.projectPath
+}
+
+// HasProject 是否已加载小说项目
+func (nm *NovelManager) HasProject() bool {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	return nm.novelData != nil
+}
+
+// ChapterCount 返回项目已登记的章节数
+func (nm *NovelManager) ChapterCount() int {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	if nm.novelData == nil {
+		return 0
+	}
+	return len(nm.novelData.Chapters)
+}
+
+// ChapterFilePath 返回章节正文文件路径
+func (nm *NovelManager) ChapterFilePath(chapterNum int) string {
+	return filepath.Join(nm.projectPath, ChaptersDir, fmt.Sprintf("chapter_%03d.txt", chapterNum))
+}
+
+// ReadChapterText 读取章节正文
+func (nm *NovelManager) ReadChapterText(chapterNum int) (string, error) {
+	data, err := os.ReadFile(nm.ChapterFilePath(chapterNum))
+	if err != nil {
+		return "", fmt.Errorf("failed to read chapter %d: %w", chapterNum, err)
+	}
+	return string(data), nil
+}
+
+// writeChapterFile 写入章节正文
+func (nm *NovelManager) writeChapterFile(chapterNum int, content string) error {
+	if err := writeFileAtomic(nm.ChapterFilePath(chapterNum), []byte(content), false); err != nil {
+		return fmt.Errorf("failed to write chapter %d: %w", chapterNum, err)
+	}
+	return nil
+}
+
+// findChapter 按章节号查找已登记的章节，调用方需持有锁
+func (nm *NovelManager) findChapter(chapterNum int) *Chapter {
+	if nm.novelData == nil {
+		return nil
+	}
+	for _, chapter := range nm.novelData.Chapters {
+		if chapter.Number == chapterNum {
+			return chapter
+		}
+	}
+	return nil
+}
+
+// listChapterNumbers 列出 chapters/ 下已有正文文件的章节号，按升序排列，不读取正文
+func (nm *NovelManager) listChapterNumbers() ([]int, error) {
+	entries, err := os.ReadDir(filepath.Join(nm.projectPath, ChaptersDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chapters directory: %w", err)
+	}
+
+	numbers := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		match := chapterFilePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		number, _ := strconv.Atoi(match[1])
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
@@ -0,0 +1,128 @@
+package novel
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 角色状态
+const (
+	CharacterAlive   = "alive"
+	CharacterDead    = "dead"
+	CharacterMissing = "missing"
+)
+
+// AddCharacter 添加角色；同名角色已存在时用非空字段覆盖原有设定
+func (nm *NovelManager) AddCharacter(char *Character) (bool, error) {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	if nm.novelData == nil {
+		return false, fmt.Errorf("novel project not initialized")
+	}
+	char.Name = strings.TrimSpace(char.Name)
+	if char.Name == "" {
+		return false, fmt.Errorf("character name is required")
+	}
+	if nm.novelData.Characters == nil {
+		nm.novelData.Characters = make(map[string]*Character)
+	}
+
+	char.Aliases = normalizeAliases(char.Name, char.Aliases)
+
+	existing, exists := nm.novelData.Characters[char.Name]
+	if !exists {
+		if char.Relationships == nil {
+			char.Relationships = make(map[string]string)
+		}
+		nm.novelData.Characters[char.Name] = char
+		return false, nm.SaveProject()
+	}
+
+	mergeCharacter(existing, char)
+	return true, nm.SaveProject()
+}
+
+// GetCharacter 返回角色设定的副本
+func (nm *NovelManager) GetCharacter(name string) (*Character, bool) {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	if nm.novelData == nil {
+		return nil, false
+	}
+	char, ok := nm.novelData.Characters[name]
+	if !ok {
+		return nil, false
+	}
+	copied := *char
+	return &copied, true
+}
+
+// IsDead 角色是否已死亡
+func (c *Character) IsDead() bool {
+	return c.Status == CharacterDead
+}
+
+// sortedCharacterNames 按名字长度从长到短排序，匹配时优先长名，避免“林动天”被识别成“林动”
+func sortedCharacterNames(characters map[string]*Character) []string {
+	names := make([]string, 0, len(characters))
+	for name := range characters {
+		if strings.TrimSpace(name) != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		li, lj := len([]rune(names[i])), len([]rune(names[j]))
+		if li != lj {
+			return li > lj
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+func mergeCharacter(dst, src *Character) {
+	if src.Age > 0 {
+		dst.Age = src.Age
+	}
+	if src.Gender != "" {
+		dst.Gender = src.Gender
+	}
+	if src.Occupation != "" {
+		dst.Occupation = src.Occupation
+	}
+	if len(src.Personality) > 0 {
+		dst.Personality = src.Personality
+	}
+	if src.Appearance != "" {
+		dst.Appearance = src.Appearance
+	}
+	if src.Background != "" {
+		dst.Background = src.Background
+	}
+	if src.Status != "" {
+		dst.Status = src.Status
+	}
+	if src.DeathChapter > 0 {
+		dst.DeathChapter = src.DeathChapter
+	}
+	if src.Birth != nil {
+		dst.Birth = src.Birth
+	}
+	if src.FirstAppeared > 0 {
+		dst.FirstAppeared = src.FirstAppeared
+	}
+	for _, alias := range src.Aliases {
+		if !containsString(dst.Aliases, alias) {
+			dst.Aliases = append(dst.Aliases, alias)
+		}
+	}
+	if dst.Relationships == nil {
+		dst.Relationships = make(map[string]string)
+	}
+	for other, relation := range src.Relationships {
+		dst.Relationships[other] = relation
+	}
+}
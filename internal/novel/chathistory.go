@@ -0,0 +1,243 @@
+package novel
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// IntentDecision 用户通过 /decide 明确记录的创作决定
+const IntentDecision = "decision"
+
+// 上下文中最多列出的明确决定数
+const maxContextDecisions = 10
+
+// 提及类型
+const (
+	MentionCharacter = "character"
+	MentionSetting   = "setting"
+	MentionPlot      = "plot"
+)
+
+var chapterMentionPattern = regexp.MustCompile(`第\s*([0-9０-９零〇一二两三四五六七八九十百千]+)\s*章`)
+
+// TurnChapter 推断一轮对话所属的章节：优先取用户消息中提到的章节，否则取当前章节
+func (nm *NovelManager) TurnChapter(userMsg string) int {
+	if chapters := mentionedChapters(userMsg); len(chapters) > 0 {
+		return chapters[0]
+	}
+	return nm.CurrentChapterNumber()
+}
+
+// AddDecision 记录一条明确的创作决定
+func (nm *NovelManager) AddDecision(decision string, chapterNum int) (*ChatRecord, error) {
+	decision = strings.TrimSpace(decision)
+	if decision == "" {
+		return nil, fmt.Errorf("decision is empty")
+	}
+
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	if nm.novelData == nil {
+		return nil, fmt.Errorf("novel project not initialized")
+	}
+	now := time.Now()
+	record := ChatRecord{
+		ID:          nm.newChatID(now),
+		Timestamp:   now,
+		ChapterNum:  chapterNum,
+		UserMessage: decision,
+		Intent:      IntentDecision,
+		Mentions:    nm.extractMentions(decision),
+		Decisions:   []string{decision},
+	}
+	if err := nm.chatLog.put(record, false); err != nil {
+		return nil, err
+	}
+	nm.updateContentIndex(record)
+
+	return &record, nil
+}
+
+// DeleteChatRecord 删除一条聊天记录（追加删除标记，压缩时回收空间）
+func (nm *NovelManager) DeleteChatRecord(id string) error {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	entry, ok := nm.chatLog.entries[id]
+	if !ok {
+		return fmt.Errorf("chat record not found: %s", id)
+	}
+	if err := nm.chatLog.put(ChatRecord{ID: id, ChapterNum: entry.Chapter, Timestamp: time.Now()}, true); err != nil {
+		return err
+	}
+	nm.rebuildContentIndex()
+	return nil
+}
+
+// ListDecisions 按时间顺序列出第 chapterNum 章及之前明确记录的决定，chapterNum<=0 表示全部
+func (nm *NovelManager) ListDecisions(chapterNum int) []ChatRecord {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	return nm.explicitDecisions(chapterNum, 0)
+}
+
+// ChatRecordsMentioning 按角色、设定或情节线查找提到它的聊天记录，从新到旧，只读取命中的记录
+func (nm *NovelManager) ChatRecordsMentioning(kind, name string, limit int) ([]ChatRecord, error) {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	var index map[string][]string
+	switch kind {
+	case MentionCharacter:
+		index = nm.contentIndex.CharacterIndex
+	case MentionSetting:
+		index = nm.contentIndex.SettingIndex
+	case MentionPlot:
+		index = nm.contentIndex.PlotIndex
+	default:
+		return nil, fmt.Errorf("unknown mention kind: %s", kind)
+	}
+
+	ids := index[name]
+	records := make([]ChatRecord, 0)
+	for i := len(ids) - 1; i >= 0; i-- {
+		if limit > 0 && len(records) >= limit {
+			break
+		}
+		record, ok, err := nm.chatLog.get(ids[i])
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// FormatDecisions 格式化创作决定列表
+func FormatDecisions(records []ChatRecord) string {
+	if len(records) == 0 {
+		return "（还没有记录创作决定，使用 /decide <内容> 记录）\n"
+	}
+	var result strings.Builder
+	for i, record := range records {
+		chapter := "全书"
+		if record.ChapterNum > 0 {
+			chapter = fmt.Sprintf("第%d章", record.ChapterNum)
+		}
+		result.WriteString(fmt.Sprintf("%d. [%s %s] %s\n", i+1, record.Timestamp.Format("01-02 15:04"), chapter, strings.Join(record.Decisions, "；")))
+	}
+	return result.String()
+}
+
+// compactChatLog 调用方需持有锁
+func (nm *NovelManager) compactChatLog() (int64, error) {
+	reclaimed, err := nm.chatLog.compact()
+	if err != nil {
+		return reclaimed, err
+	}
+	return reclaimed, nm.saveChatIndex()
+}
+
+// saveChatIndex 把聊天记录索引交给写入协程；日志本身已落盘，索引丢失可从日志重建。调用方需持有锁
+func (nm *NovelManager) saveChatIndex() error {
+	if len(nm.chatLog.segments) == 0 {
+		return nil
+	}
+	data, err := nm.chatLog.indexData()
+	if err != nil {
+		return err
+	}
+	return nm.writer.submit(map[string][]byte{nm.chatLog.indexPath(): data})
+}
+
+// rebuildContentIndex 从聊天记录索引重建内容索引，调用方需持有锁
+func (nm *NovelManager) rebuildContentIndex() {
+	nm.contentIndex = newContentIndex()
+	for _, entry := range nm.chatLog.order {
+		nm.updateContentIndex(ChatRecord{ID: entry.ID, Mentions: entry.Mentions})
+	}
+}
+
+// explicitDecisions 调用方需持有锁；limit>0 时只保留最近的若干条
+func (nm *NovelManager) explicitDecisions(chapterNum, limit int) []ChatRecord {
+	records, err := nm.chatLog.find(func(entry *chatEntry) bool {
+		return entry.Intent == IntentDecision && (chapterNum <= 0 || entry.Chapter <= chapterNum)
+	}, limit)
+	if err != nil {
+		return nil
+	}
+	// 按时间顺序排列
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	return records
+}
+
+// newChatID 生成聊天记录ID，调用方需持有锁
+func (nm *NovelManager) newChatID(now time.Time) string {
+	id := fmt.Sprintf("chat_%d", now.UnixNano())
+	for seq := 2; nm.hasChatID(id); seq++ {
+		id = fmt.Sprintf("chat_%d_%d", now.UnixNano(), seq)
+	}
+	return id
+}
+
+func (nm *NovelManager) hasChatID(id string) bool {
+	_, ok := nm.chatLog.entries[id]
+	return ok
+}
+
+// dedupeChatIDs 旧版本按秒生成ID，同一秒内的多条记录会重复，升级时补上序号
+func (nm *NovelManager) dedupeChatIDs() {
+	seen := make(map[string]bool, len(nm.legacyChat))
+	for i := range nm.legacyChat {
+		base := nm.legacyChat[i].ID
+		if base == "" {
+			base = fmt.Sprintf("chat_%d", nm.legacyChat[i].Timestamp.UnixNano())
+		}
+		id := base
+		for seq := 2; seen[id]; seq++ {
+			id = fmt.Sprintf("%s_%d", base, seq)
+		}
+		seen[id] = true
+		nm.legacyChat[i].ID = id
+	}
+}
+
+// migrateLegacyChat 把旧版本的 chat_history.json 迁入按章节分段的日志
+func (nm *NovelManager) migrateLegacyChat() error {
+	lines := make([]chatLogLine, len(nm.legacyChat))
+	for i, record := range nm.legacyChat {
+		lines[i] = chatLogLine{ChatRecord: record}
+	}
+	if err := nm.chatLog.putAll(lines); err != nil {
+		return err
+	}
+	nm.legacyChat = nil
+	return nm.saveChatIndex()
+}
+
+func newContentIndex() *ContentIndex {
+	return &ContentIndex{
+		CharacterIndex: make(map[string][]string),
+		SettingIndex:   make(map[string][]string),
+		PlotIndex:      make(map[string][]string),
+	}
+}
+
+// mentionedChapters 提取文本中“第N章”形式的章节号，按出现顺序去重
+func mentionedChapters(text string) []int {
+	chapters := make([]int, 0)
+	for _, match := range chapterMentionPattern.FindAllStringSubmatch(text, -1) {
+		if n, ok := parseChineseNumber(match[1]); ok && n > 0 && !containsInt(chapters, n) {
+			chapters = append(chapters, n)
+		}
+	}
+	return chapters
+}
@@ -0,0 +1,510 @@
+package novel
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ChatDir 聊天记录目录，按章节分段保存为 JSONL
+const ChatDir = "chat"
+
+const (
+	chatIndexFile       = "index.json"
+	chatLogIndexVersion = 1
+	// 被覆盖或删除的记录占比超过该值且达到最小字节数时，加载后自动压缩
+	chatCompactRatio    = 0.3
+	chatCompactMinBytes = 64 * 1024
+)
+
+var chatSegmentPattern = regexp.MustCompile(`^chapter_(\d+)\.jsonl$`)
+
+// chatLogLine 日志中的一行：完整记录，或带 deleted 标记的删除记录。同一ID以最后一行为准
+type chatLogLine struct {
+	ChatRecord
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// chatEntry 记录在日志中的位置和元数据，常驻内存；正文按需读取
+type chatEntry struct {
+	ID        string    `json:"id"`
+	Chapter   int       `json:"chapter"`
+	Offset    int64     `json:"offset"`
+	Length    int       `json:"length"`
+	Timestamp time.Time `json:"timestamp"`
+	Intent    string    `json:"intent,omitempty"`
+	Mentions  Mentions  `json:"mentions"`
+}
+
+// chatSegment 单个章节分段的状态
+type chatSegment struct {
+	Size    int64 `json:"size"`
+	Garbage int64 `json:"garbage"` // 被覆盖或删除的记录占用的字节
+}
+
+// chatLogIndex chat/index.json 的内容
+type chatLogIndex struct {
+	Version  int                  `json:"version"`
+	Segments map[int]*chatSegment `json:"segments"`
+	Entries  []*chatEntry         `json:"entries"`
+}
+
+// chatLog 追加式聊天记录；调用方需持有 NovelManager 的锁（读取持读锁，写入持写锁）
+type chatLog struct {
+	dir      string
+	entries  map[string]*chatEntry
+	order    []*chatEntry // 按首次写入顺序
+	segments map[int]*chatSegment
+}
+
+func newChatLog(dir string) *chatLog {
+	return &chatLog{
+		dir:      dir,
+		entries:  make(map[string]*chatEntry),
+		order:    make([]*chatEntry, 0),
+		segments: make(map[int]*chatSegment),
+	}
+}
+
+// open 加载索引，并从各分段尾部补读索引之后追加的记录；索引不可用时全量重建
+func (l *chatLog) open() ([]string, error) {
+	l.reset()
+	notes := make([]string, 0)
+
+	onDisk, err := l.segmentSizes()
+	if err != nil {
+		return nil, err
+	}
+
+	if !l.loadIndex(onDisk) {
+		if len(onDisk) > 0 {
+			notes = append(notes, "聊天记录索引缺失或已过期，已从日志重建")
+		}
+		l.reset()
+	}
+
+	chapters := make([]int, 0, len(onDisk))
+	for chapter := range onDisk {
+		chapters = append(chapters, chapter)
+	}
+	sort.Ints(chapters)
+	for _, chapter := range chapters {
+		segment := l.segment(chapter)
+		if onDisk[chapter] == segment.Size {
+			continue
+		}
+		truncated, err := l.scanSegment(chapter, segment.Size)
+		if err != nil {
+			return nil, err
+		}
+		if truncated {
+			notes = append(notes, fmt.Sprintf("聊天记录 %s 末尾有未写完的记录，已截断", segmentName(chapter)))
+		}
+	}
+	return notes, nil
+}
+
+func (l *chatLog) reset() {
+	l.entries = make(map[string]*chatEntry)
+	l.order = make([]*chatEntry, 0)
+	l.segments = make(map[int]*chatSegment)
+}
+
+// loadIndex 读取索引文件，索引记录的分段比磁盘上更长或分段缺失时视为不可用
+func (l *chatLog) loadIndex(onDisk map[int]int64) bool {
+	data, err := os.ReadFile(filepath.Join(l.dir, chatIndexFile))
+	if err != nil {
+		return len(onDisk) == 0
+	}
+	var index chatLogIndex
+	if err := json.Unmarshal(data, &index); err != nil || index.Version != chatLogIndexVersion {
+		return false
+	}
+	for chapter, segment := range index.Segments {
+		if size, ok := onDisk[chapter]; !ok || size < segment.Size {
+			return false
+		}
+	}
+	for chapter, segment := range index.Segments {
+		l.segments[chapter] = segment
+	}
+	for _, entry := range index.Entries {
+		l.entries[entry.ID] = entry
+		l.order = append(l.order, entry)
+	}
+	return true
+}
+
+func (l *chatLog) segmentSizes() (map[int]int64, error) {
+	sizes := make(map[int]int64)
+	entries, err := os.ReadDir(l.dir)
+	if os.IsNotExist(err) {
+		return sizes, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chat directory: %w", err)
+	}
+	for _, entry := range entries {
+		match := chatSegmentPattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", entry.Name(), err)
+		}
+		chapter, _ := strconv.Atoi(match[1])
+		sizes[chapter] = info.Size()
+	}
+	return sizes, nil
+}
+
+// scanSegment 从 offset 开始读取分段；末尾不完整的一行会被截断
+func (l *chatLog) scanSegment(chapter int, offset int64) (bool, error) {
+	path := l.segmentPath(chapter)
+	file, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to open %s: %w", segmentName(chapter), err)
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return false, fmt.Errorf("failed to seek %s: %w", segmentName(chapter), err)
+	}
+
+	reader := bufio.NewReader(file)
+	torn := false
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			torn = len(line) > 0
+			break
+		}
+		if err != nil {
+			file.Close()
+			return false, fmt.Errorf("failed to read %s: %w", segmentName(chapter), err)
+		}
+		var parsed chatLogLine
+		if jsonErr := json.Unmarshal(line, &parsed); jsonErr != nil || parsed.ID == "" {
+			torn = true
+			break
+		}
+		l.apply(&parsed, chapter, offset, len(line))
+		offset += int64(len(line))
+	}
+	file.Close()
+
+	l.segment(chapter).Size = offset
+	if torn {
+		if err := os.Truncate(path, offset); err != nil {
+			return false, fmt.Errorf("failed to truncate %s: %w", segmentName(chapter), err)
+		}
+	}
+	return torn, nil
+}
+
+// apply 把日志行应用到内存索引
+func (l *chatLog) apply(line *chatLogLine, chapter int, offset int64, length int) {
+	if existing, ok := l.entries[line.ID]; ok {
+		l.segment(existing.Chapter).Garbage += int64(existing.Length)
+		if line.Deleted {
+			l.segment(chapter).Garbage += int64(length)
+			delete(l.entries, line.ID)
+			l.removeFromOrder(existing)
+			return
+		}
+		existing.Chapter, existing.Offset, existing.Length = chapter, offset, length
+		existing.Timestamp, existing.Intent, existing.Mentions = line.Timestamp, line.Intent, line.Mentions
+		return
+	}
+	if line.Deleted {
+		l.segment(chapter).Garbage += int64(length)
+		return
+	}
+	entry := &chatEntry{
+		ID:        line.ID,
+		Chapter:   chapter,
+		Offset:    offset,
+		Length:    length,
+		Timestamp: line.Timestamp,
+		Intent:    line.Intent,
+		Mentions:  line.Mentions,
+	}
+	l.entries[entry.ID] = entry
+	l.order = append(l.order, entry)
+}
+
+// put 追加一条记录（或删除标记）并落盘
+func (l *chatLog) put(record ChatRecord, deleted bool) error {
+	return l.putAll([]chatLogLine{{ChatRecord: record, Deleted: deleted}})
+}
+
+// putAll 按章节分段批量追加，每个分段只落盘一次
+func (l *chatLog) putAll(lines []chatLogLine) error {
+	byChapter := make(map[int][]*chatLogLine)
+	chapters := make([]int, 0)
+	for i := range lines {
+		chapter := lines[i].ChapterNum
+		if chapter < 0 {
+			chapter = 0
+		}
+		if _, ok := byChapter[chapter]; !ok {
+			chapters = append(chapters, chapter)
+		}
+		byChapter[chapter] = append(byChapter[chapter], &lines[i])
+	}
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create chat directory: %w", err)
+	}
+	for _, chapter := range chapters {
+		if err := l.appendSegment(chapter, byChapter[chapter]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *chatLog) appendSegment(chapter int, lines []*chatLogLine) error {
+	encoded := make([][]byte, len(lines))
+	for i, line := range lines {
+		data, err := json.Marshal(line)
+		if err != nil {
+			return fmt.Errorf("failed to marshal chat record: %w", err)
+		}
+		encoded[i] = append(data, '\n')
+	}
+
+	file, err := os.OpenFile(l.segmentPath(chapter), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", segmentName(chapter), err)
+	}
+	defer file.Close()
+
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("failed to seek %s: %w", segmentName(chapter), err)
+	}
+	writer := bufio.NewWriter(file)
+	for _, data := range encoded {
+		if _, err := writer.Write(data); err != nil {
+			// 写了一半的行在下次加载时截断
+			return fmt.Errorf("failed to append chat record: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to append chat record: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync %s: %w", segmentName(chapter), err)
+	}
+
+	for i, line := range lines {
+		l.apply(line, chapter, offset, len(encoded[i]))
+		offset += int64(len(encoded[i]))
+	}
+	l.segment(chapter).Size = offset
+	return nil
+}
+
+// get 按ID读取记录
+func (l *chatLog) get(id string) (ChatRecord, bool, error) {
+	entry, ok := l.entries[id]
+	if !ok {
+		return ChatRecord{}, false, nil
+	}
+	reader := l.newReader()
+	defer reader.close()
+	record, err := reader.read(entry)
+	return record, err == nil, err
+}
+
+// chatReader 一次扫描内按需打开并复用各分段的文件句柄，扫描结束后统一关闭
+type chatReader struct {
+	log   *chatLog
+	files map[int]*os.File
+}
+
+func (l *chatLog) newReader() *chatReader {
+	return &chatReader{log: l, files: make(map[int]*os.File)}
+}
+
+func (r *chatReader) close() {
+	for _, file := range r.files {
+		file.Close()
+	}
+	r.files = make(map[int]*os.File)
+}
+
+func (r *chatReader) read(entry *chatEntry) (ChatRecord, error) {
+	file, ok := r.files[entry.Chapter]
+	if !ok {
+		var err error
+		if file, err = os.Open(r.log.segmentPath(entry.Chapter)); err != nil {
+			return ChatRecord{}, fmt.Errorf("failed to open %s: %w", segmentName(entry.Chapter), err)
+		}
+		r.files[entry.Chapter] = file
+	}
+
+	data := make([]byte, entry.Length)
+	if _, err := file.ReadAt(data, entry.Offset); err != nil {
+		return ChatRecord{}, fmt.Errorf("failed to read chat record %s: %w", entry.ID, err)
+	}
+	var line chatLogLine
+	if err := json.Unmarshal(data, &line); err != nil {
+		return ChatRecord{}, fmt.Errorf("failed to parse chat record %s: %w", entry.ID, err)
+	}
+	return line.ChatRecord, nil
+}
+
+// find 按时间从新到旧筛选记录并读取，limit<=0 表示不限
+func (l *chatLog) find(match func(*chatEntry) bool, limit int) ([]ChatRecord, error) {
+	reader := l.newReader()
+	defer reader.close()
+
+	records := make([]ChatRecord, 0)
+	for i := len(l.order) - 1; i >= 0; i-- {
+		if limit > 0 && len(records) >= limit {
+			break
+		}
+		entry := l.order[i]
+		if match != nil && !match(entry) {
+			continue
+		}
+		record, err := reader.read(entry)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// findRecords 按时间从新到旧逐条读取并筛选记录内容，limit<=0 表示不限
+func (l *chatLog) findRecords(match func(ChatRecord) bool, limit int) ([]ChatRecord, error) {
+	reader := l.newReader()
+	defer reader.close()
+
+	records := make([]ChatRecord, 0)
+	for i := len(l.order) - 1; i >= 0; i-- {
+		if limit > 0 && len(records) >= limit {
+			break
+		}
+		record, err := reader.read(l.order[i])
+		if err != nil {
+			return nil, err
+		}
+		if match(record) {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// needsCompaction 是否值得压缩
+func (l *chatLog) needsCompaction() bool {
+	var size, garbage int64
+	for _, segment := range l.segments {
+		size += segment.Size
+		garbage += segment.Garbage
+	}
+	return garbage >= chatCompactMinBytes && float64(garbage) > float64(size)*chatCompactRatio
+}
+
+// compact 重写含有失效记录的分段，只保留每个ID的最新版本，返回回收的字节数
+func (l *chatLog) compact() (int64, error) {
+	byChapter := make(map[int][]*chatEntry)
+	for _, entry := range l.order {
+		byChapter[entry.Chapter] = append(byChapter[entry.Chapter], entry)
+	}
+
+	reader := l.newReader()
+	defer reader.close()
+
+	var reclaimed int64
+	for chapter, segment := range l.segments {
+		if segment.Garbage == 0 {
+			continue
+		}
+		entries := byChapter[chapter]
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Offset < entries[j].Offset })
+
+		data := make([]byte, 0, segment.Size-segment.Garbage)
+		offsets := make([]int64, len(entries))
+		for i, entry := range entries {
+			record, err := reader.read(entry)
+			if err != nil {
+				return reclaimed, err
+			}
+			line, err := json.Marshal(chatLogLine{ChatRecord: record})
+			if err != nil {
+				return reclaimed, fmt.Errorf("failed to marshal chat record: %w", err)
+			}
+			offsets[i] = int64(len(data))
+			data = append(append(data, line...), '\n')
+		}
+		// 替换分段文件前先关闭其句柄（Windows 上无法替换打开中的文件）
+		reader.close()
+		if err := writeFileAtomic(l.segmentPath(chapter), data, false); err != nil {
+			return reclaimed, err
+		}
+		for i, entry := range entries {
+			next := int64(len(data))
+			if i+1 < len(entries) {
+				next = offsets[i+1]
+			}
+			entry.Offset, entry.Length = offsets[i], int(next-offsets[i])
+		}
+		reclaimed += segment.Size - int64(len(data))
+		segment.Size, segment.Garbage = int64(len(data)), 0
+	}
+	return reclaimed, nil
+}
+
+// indexData 序列化内存索引
+func (l *chatLog) indexData() ([]byte, error) {
+	data, err := json.Marshal(chatLogIndex{
+		Version:  chatLogIndexVersion,
+		Segments: l.segments,
+		Entries:  l.order,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal chat index: %w", err)
+	}
+	return data, nil
+}
+
+func (l *chatLog) indexPath() string {
+	return filepath.Join(l.dir, chatIndexFile)
+}
+
+func (l *chatLog) segment(chapter int) *chatSegment {
+	segment, ok := l.segments[chapter]
+	if !ok {
+		segment = &chatSegment{}
+		l.segments[chapter] = segment
+	}
+	return segment
+}
+
+func (l *chatLog) removeFromOrder(target *chatEntry) {
+	for i, entry := range l.order {
+		if entry == target {
+			l.order = append(l.order[:i], l.order[i+1:]...)
+			return
+		}
+	}
+}
+
+func (l *chatLog) segmentPath(chapter int) string {
+	return filepath.Join(l.dir, segmentName(chapter))
+}
+
+func segmentName(chapter int) string {
+	return fmt.Sprintf("chapter_%03d.jsonl", chapter)
+}
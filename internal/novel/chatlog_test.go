@@ -0,0 +1,204 @@
+package novel
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// chatMessages 返回日志中每条记录的用户消息
+func chatMessages(t *testing.T, l *chatLog) map[string]string {
+	t.Helper()
+	records, err := l.find(nil, 0)
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	messages := make(map[string]string, len(records))
+	for _, record := range records {
+		messages[record.ID] = record.UserMessage
+	}
+	return messages
+}
+
+func TestChatLogTornLineRecovery(t *testing.T) {
+	tests := []struct {
+		name      string
+		tail      string
+		withIndex bool // 损坏发生在索引保存之后，只补读索引之后的部分
+	}{
+		{"写了一半的行", `{"id":"c3","user_message":"写到一半`, false},
+		{"无法解析的整行", "not json\n", false},
+		{"缺少ID的记录", `{"user_message":"没有ID"}` + "\n", false},
+		{"索引之后写了一半的行", `{"id":"c3","chapter_num":1,"user`, true},
+		{"索引之后无法解析的整行", "{{{\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			l := newChatLog(dir)
+			if _, err := l.open(); err != nil {
+				t.Fatal(err)
+			}
+			for _, id := range []string{"c1", "c2"} {
+				if err := l.put(ChatRecord{ID: id, ChapterNum: 1, UserMessage: "消息" + id}, false); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.withIndex {
+				data, err := l.indexData()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(l.indexPath(), data, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			validSize := l.segments[1].Size
+
+			file, err := os.OpenFile(l.segmentPath(1), os.O_APPEND|os.O_WRONLY, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			file.WriteString(tt.tail)
+			file.Close()
+
+			reopened := newChatLog(dir)
+			notes, err := reopened.open()
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			if !containsNote(notes, "已截断") {
+				t.Errorf("notes = %q, want a truncation note", notes)
+			}
+			want := map[string]string{"c1": "消息c1", "c2": "消息c2"}
+			if got := chatMessages(t, reopened); !reflect.DeepEqual(got, want) {
+				t.Errorf("records = %v, want %v", got, want)
+			}
+			if info, err := os.Stat(reopened.segmentPath(1)); err != nil || info.Size() != validSize {
+				t.Errorf("segment not truncated to %d bytes: %v, %v", validSize, info.Size(), err)
+			}
+
+			// 截断后继续追加，再次加载不应再有提示
+			if err := reopened.put(ChatRecord{ID: "c3", ChapterNum: 1, UserMessage: "消息c3"}, false); err != nil {
+				t.Fatal(err)
+			}
+			again := newChatLog(dir)
+			notes, err = again.open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if containsNote(notes, "已截断") {
+				t.Errorf("unexpected truncation after append: %q", notes)
+			}
+			want["c3"] = "消息c3"
+			if got := chatMessages(t, again); !reflect.DeepEqual(got, want) {
+				t.Errorf("records after append = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestChatLogCompact(t *testing.T) {
+	type op struct {
+		record  ChatRecord
+		deleted bool
+	}
+	seed := []op{
+		{record: ChatRecord{ID: "a", ChapterNum: 1, UserMessage: "第一版"}},
+		{record: ChatRecord{ID: "b", ChapterNum: 1, UserMessage: "待删除"}},
+		{record: ChatRecord{ID: "c", ChapterNum: 2, UserMessage: "第二章"}},
+	}
+
+	tests := []struct {
+		name        string
+		ops         []op
+		want        map[string]string
+		wantChapter map[string]int
+	}{
+		{
+			name:        "覆盖记录",
+			ops:         []op{{record: ChatRecord{ID: "a", ChapterNum: 1, UserMessage: "第二版"}}},
+			want:        map[string]string{"a": "第二版", "b": "待删除", "c": "第二章"},
+			wantChapter: map[string]int{"a": 1, "b": 1, "c": 2},
+		},
+		{
+			name:        "删除记录",
+			ops:         []op{{record: ChatRecord{ID: "b", ChapterNum: 1}, deleted: true}},
+			want:        map[string]string{"a": "第一版", "c": "第二章"},
+			wantChapter: map[string]int{"a": 1, "c": 2},
+		},
+		{
+			name:        "记录改到其他章节",
+			ops:         []op{{record: ChatRecord{ID: "a", ChapterNum: 2, UserMessage: "移到第二章"}}},
+			want:        map[string]string{"a": "移到第二章", "b": "待删除", "c": "第二章"},
+			wantChapter: map[string]int{"a": 2, "b": 1, "c": 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			l := newChatLog(dir)
+			if _, err := l.open(); err != nil {
+				t.Fatal(err)
+			}
+			for _, step := range append(append([]op{}, seed...), tt.ops...) {
+				if err := l.put(step.record, step.deleted); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			reclaimed, err := l.compact()
+			if err != nil {
+				t.Fatalf("compact: %v", err)
+			}
+			if reclaimed <= 0 {
+				t.Errorf("reclaimed = %d, want > 0", reclaimed)
+			}
+			for chapter, segment := range l.segments {
+				info, err := os.Stat(l.segmentPath(chapter))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if segment.Garbage != 0 || segment.Size != info.Size() {
+					t.Errorf("chapter %d segment = %+v, file size %d", chapter, *segment, info.Size())
+				}
+			}
+			if got := chatMessages(t, l); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("records = %v, want %v", got, tt.want)
+			}
+
+			// 不带索引重新扫描，压缩后的分段应与内存索引一致
+			reopened := newChatLog(dir)
+			notes, err := reopened.open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if containsNote(notes, "已截断") {
+				t.Errorf("unexpected truncation after compaction: %q", notes)
+			}
+			if got := chatMessages(t, reopened); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reopened records = %v, want %v", got, tt.want)
+			}
+			for id, chapter := range tt.wantChapter {
+				if entry := reopened.entries[id]; entry == nil || entry.Chapter != chapter {
+					t.Errorf("record %s entry = %+v, want chapter %d", id, entry, chapter)
+				}
+			}
+			if reopened.needsCompaction() {
+				t.Error("log still needs compaction")
+			}
+		})
+	}
+}
+
+func containsNote(notes []string, text string) bool {
+	for _, note := range notes {
+		if strings.Contains(note, text) {
+			return true
+		}
+	}
+	return false
+}
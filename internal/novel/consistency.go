@@ -0,0 +1,801 @@
+package novel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/AiNovelTools/internal/ai"
+)
+
+// 一致性问题类型
+const (
+	IssueDeadCharacter   = "dead_character"
+	IssueEarlyAppearance = "early_appearance"
+	IssueAge             = "age"
+	IssueAppearance      = "appearance"
+	IssueWorldRule       = "world_rule"
+	IssueRelationship    = "relationship"
+	IssueAI              = "ai"
+)
+
+// 问题严重程度
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+const (
+	// 送给模型检查的单章最大字符数
+	maxAICheckRunes = 8000
+	// 摘录的最大字符数
+	maxExcerptRunes = 60
+)
+
+// ConsistencyIssue 一致性问题，定位到章节与行号（行号从1开始）
+type ConsistencyIssue struct {
+	Type     string `json:"type"`
+	Severity string `json:"severity"`
+	Chapter  int    `json:"chapter"`
+	Line     int    `json:"line"`
+	Subject  string `json:"subject"` // 涉及的角色或设定
+	Message  string `json:"message"`
+	Excerpt  string `json:"excerpt"`
+}
+
+// ConsistencyReport 一致性检查报告
+type ConsistencyReport struct {
+	FromChapter int
+	ToChapter   int
+	Checked     []int
+	Missing     []int // 已登记但缺少正文文件的章节
+	Issues      []*ConsistencyIssue
+}
+
+var (
+	sentenceEndPattern = regexp.MustCompile(`[^。！？!?；;…]+[。！？!?；;…」』”"]*`)
+	agePattern         = regexp.MustCompile(`([0-9０-９]+|[零一二两三四五六七八九十百]+)(?:岁|周岁)(?:那年|时|的时候|之前|以前)?`)
+	quotedTermPattern  = regexp.MustCompile(`[「『“"《]([^」』”"》]+)[」』”"》]`)
+	ruleTermPattern    = regexp.MustCompile(`^(?:禁止|不存在|没有|不能出现|不可出现|不允许)[：:\s]*([^，。,；;、\s]+)$`)
+)
+
+// 关系称谓，较长的放在前面以便正则优先匹配
+var relationLabels = []string{
+	"未婚妻", "未婚夫", "师父", "师尊", "师傅", "师兄", "师姐", "师弟", "师妹", "徒弟", "弟子",
+	"父亲", "母亲", "哥哥", "姐姐", "弟弟", "妹妹", "兄长", "爷爷", "奶奶", "祖父", "祖母",
+	"妻子", "丈夫", "夫君", "夫人", "道侣", "恋人", "儿子", "女儿", "叔叔", "舅舅", "姑姑",
+	"仇人", "宿敌", "敌人", "对手", "好友", "挚友", "主人", "侍女", "护卫", "仆人", "孙子", "孙女",
+}
+
+// 同义称谓归一
+var relationSynonyms = map[string]string{
+	"师尊": "师父", "师傅": "师父",
+	"弟子": "徒弟",
+	"兄长": "哥哥",
+	"祖父": "爷爷", "祖母": "奶奶",
+	"夫君": "丈夫", "夫人": "妻子",
+	"挚友": "好友",
+	"宿敌": "仇人",
+}
+
+// 回忆、遗物等语境中提到已死角色不算复活
+var memoryCues = []string{
+	"回忆", "想起", "记得", "怀念", "思念", "当年", "曾经", "往昔", "生前", "临死", "死前",
+	"遗体", "尸体", "尸首", "遗骸", "坟", "墓", "灵位", "牌位", "遗言", "遗物", "遗志",
+	"祭", "已故", "亡魂", "在天之灵", "梦", "幻象", "幻影", "画像",
+}
+
+// 外貌特征：颜色 + 部位，同一部位出现不同颜色视为矛盾
+var appearanceColors = []string{"黑", "白", "银", "金", "红", "赤", "紫", "蓝", "青", "碧", "绿", "灰", "棕", "褐"}
+
+var appearanceFeatures = []struct {
+	Name     string
+	Suffixes []string
+}{
+	{Name: "发色", Suffixes: []string{"发", "色头发", "色长发", "色短发"}},
+	{Name: "瞳色", Suffixes: []string{"眸", "瞳", "色眼睛", "色眸子", "色瞳孔", "色的眼睛"}},
+}
+
+// CheckConsistency 对章节正文与项目设定进行本地一致性检查，from/to 为0时表示不限
+func (nm *NovelManager) CheckConsistency(fromChapter, toChapter int) (*ConsistencyReport, error) {
+	checker, numbers, err := nm.newProjectChecker()
+	if err != nil {
+		return nil, err
+	}
+
+	// 章节列表之外存在的正文文件也一并检查
+	if files, err := nm.listChapterNumbers(); err == nil {
+		numbers = append(numbers, files...)
+	}
+	numbers = uniqueSortedInts(numbers)
+
+	report := &ConsistencyReport{FromChapter: fromChapter, ToChapter: toChapter}
+	for _, number := range numbers {
+		if (fromChapter > 0 && number < fromChapter) || (toChapter > 0 && number > toChapter) {
+			continue
+		}
+		text, err := nm.ReadChapterText(number)
+		if err != nil {
+			report.Missing = append(report.Missing, number)
+			continue
+		}
+		checker.checkChapter(number, text)
+		report.Checked = append(report.Checked, number)
+	}
+	checker.checkRelationshipHistory()
+	checker.checkRelationshipGraph(fromChapter, toChapter)
+
+	report.Issues = checker.issues
+	sortIssues(report.Issues)
+	return report, nil
+}
+
+// CheckChapterText 对尚未写入文件的单章正文（如生成的草稿）进行本地一致性检查
+func (nm *NovelManager) CheckChapterText(chapterNum int, text string) (*ConsistencyReport, error) {
+	checker, _, err := nm.newProjectChecker()
+	if err != nil {
+		return nil, err
+	}
+
+	checker.checkChapter(chapterNum, text)
+	checker.checkRelationshipHistory()
+	checker.checkRelationshipGraph(chapterNum, chapterNum)
+
+	report := &ConsistencyReport{FromChapter: chapterNum, ToChapter: chapterNum, Checked: []int{chapterNum}, Issues: checker.issues}
+	sortIssues(report.Issues)
+	return report, nil
+}
+
+// newProjectChecker 基于当前设定的副本创建检查器，并返回已登记的章节号
+func (nm *NovelManager) newProjectChecker() (*consistencyChecker, []int, error) {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	if nm.novelData == nil {
+		return nil, nil, fmt.Errorf("novel project not initialized")
+	}
+	characters := make(map[string]*Character, len(nm.novelData.Characters))
+	for name, char := range nm.novelData.Characters {
+		copied := *char
+		characters[name] = &copied
+	}
+	numbers := make([]int, 0, len(nm.novelData.Chapters))
+	for _, chapter := range nm.novelData.Chapters {
+		numbers = append(numbers, chapter.Number)
+	}
+	checker := newConsistencyChecker(characters, forbiddenTerms(nm.novelData.WorldSettings),
+		copyTimeline(nm.novelData.Timeline), newRelationshipGraph(nm.novelData))
+	return checker, numbers, nil
+}
+
+// CheckChapterWithAI 让模型对单章进行语义层面的一致性检查（如世界观规则的隐性违背、性格突变）
+func (nm *NovelManager) CheckChapterWithAI(ctx context.Context, client *ai.Client, chapterNum int) ([]*ConsistencyIssue, error) {
+	if client == nil {
+		return nil, fmt.Errorf("AI client not available")
+	}
+	text, err := nm.ReadChapterText(chapterNum)
+	if err != nil {
+		return nil, err
+	}
+
+	nm.mutex.RLock()
+	if nm.novelData == nil {
+		nm.mutex.RUnlock()
+		return nil, fmt.Errorf("novel project not initialized")
+	}
+	settings := consistencySettingsSummary(nm.novelData)
+	nm.mutex.RUnlock()
+
+	var numbered strings.Builder
+	total := 0
+	for i, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		total += len([]rune(line))
+		if total > maxAICheckRunes {
+			numbered.WriteString("（后文过长已截断）\n")
+			break
+		}
+		numbered.WriteString(fmt.Sprintf("L%d: %s\n", i+1, line))
+	}
+
+	prompt := fmt.Sprintf(`你是小说编辑，负责检查第%d章正文与既有设定是否矛盾。
+
+【设定】
+%s
+【正文】（每行以行号开头）
+%s
+只报告与设定明确矛盾或前后文自相矛盾的地方，不要评价文笔。
+以JSON数组输出，不要输出其他内容，每项格式：
+{"line": 行号, "subject": "涉及的角色或设定", "message": "矛盾说明", "excerpt": "原文片段"}
+没有问题时输出 []`, chapterNum, settings, numbered.String())
+
+	response, _, err := client.Chat(ctx, []ai.Message{{Role: "user", Content: prompt}}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("AI consistency check failed: %w", err)
+	}
+
+	var found []struct {
+		Line    int    `json:"line"`
+		Subject string `json:"subject"`
+		Message string `json:"message"`
+		Excerpt string `json:"excerpt"`
+	}
+	if err := json.Unmarshal([]byte(extractJSONArray(response)), &found); err != nil {
+		return nil, fmt.Errorf("failed to parse AI response: %w", err)
+	}
+
+	issues := make([]*ConsistencyIssue, 0, len(found))
+	for _, item := range found {
+		if strings.TrimSpace(item.Message) == "" {
+			continue
+		}
+		issues = append(issues, &ConsistencyIssue{
+			Type:     IssueAI,
+			Severity: SeverityWarning,
+			Chapter:  chapterNum,
+			Line:     item.Line,
+			Subject:  item.Subject,
+			Message:  item.Message,
+			Excerpt:  excerpt(item.Excerpt),
+		})
+	}
+	return issues, nil
+}
+
+// Format 生成检查报告文本
+func (r *ConsistencyReport) Format() string {
+	var result strings.Builder
+	result.WriteString("🔍 === 一致性检查 ===\n\n")
+
+	if len(r.Checked) == 0 {
+		result.WriteString("没有可检查的章节正文\n")
+		return result.String()
+	}
+	result.WriteString(fmt.Sprintf("检查范围: 第%d章 - 第%d章（共 %d 章）\n", r.Checked[0], r.Checked[len(r.Checked)-1], len(r.Checked)))
+	if len(r.Missing) > 0 {
+		result.WriteString(fmt.Sprintf("⚠️ 缺少正文文件: %s\n", joinInts(r.Missing, ", ")))
+	}
+
+	errors, warnings := 0, 0
+	for _, issue := range r.Issues {
+		if issue.Severity == SeverityError {
+			errors++
+		} else {
+			warnings++
+		}
+	}
+	if len(r.Issues) == 0 {
+		result.WriteString("\n✅ 未发现矛盾\n")
+		return result.String()
+	}
+	result.WriteString(fmt.Sprintf("发现问题: %d 个错误, %d 个提醒\n", errors, warnings))
+
+	chapter := -1
+	for _, issue := range r.Issues {
+		if issue.Chapter != chapter {
+			chapter = issue.Chapter
+			if chapter == 0 {
+				result.WriteString("\n📄 故事开始时的设定\n")
+			} else {
+				result.WriteString(fmt.Sprintf("\n📄 第%d章\n", chapter))
+			}
+		}
+		icon := "⚠️"
+		if issue.Severity == SeverityError {
+			icon = "❌"
+		}
+		location := "全章"
+		if issue.Line > 0 {
+			location = fmt.Sprintf("第%d行", issue.Line)
+		}
+		result.WriteString(fmt.Sprintf("  %s [%s] %s: %s\n", icon, issueTypeLabel(issue.Type), location, issue.Message))
+		if issue.Excerpt != "" {
+			result.WriteString(fmt.Sprintf("      「%s」\n", issue.Excerpt))
+		}
+	}
+
+	return result.String()
+}
+
+// AddIssues 合并额外的问题（如模型检查结果）并重新排序
+func (r *ConsistencyReport) AddIssues(issues []*ConsistencyIssue) {
+	r.Issues = append(r.Issues, issues...)
+	sortIssues(r.Issues)
+}
+
+// consistencyChecker 逐章扫描时的检查状态
+type consistencyChecker struct {
+	characters map[string]*Character
+	names      []string
+	forbidden  map[string]string // 禁用词 -> 来源设定名
+	timeline   *Timeline
+	graph      *RelationshipGraph
+	relations  map[string][]relationObservation
+	pairRes    map[string]*regexp.Regexp
+	issues     []*ConsistencyIssue
+	reported   map[string]bool
+}
+
+// relationObservation 正文中出现的一次关系称谓：To 是 From 的 Label
+type relationObservation struct {
+	From, To string
+	Label    string
+	Chapter  int
+	Line     int
+	Excerpt  string
+}
+
+func newConsistencyChecker(characters map[string]*Character, forbidden map[string]string, timeline *Timeline, graph *RelationshipGraph) *consistencyChecker {
+	return &consistencyChecker{
+		characters: characters,
+		names:      sortedCharacterNames(characters),
+		forbidden:  forbidden,
+		timeline:   timeline,
+		graph:      graph,
+		relations:  make(map[string][]relationObservation),
+		pairRes:    make(map[string]*regexp.Regexp),
+		reported:   make(map[string]bool),
+	}
+}
+
+func (c *consistencyChecker) checkChapter(chapterNum int, text string) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lineNum := i + 1
+
+		for term, source := range c.forbidden {
+			if strings.Contains(line, term) {
+				c.add(&ConsistencyIssue{
+					Type:     IssueWorldRule,
+					Severity: SeverityError,
+					Chapter:  chapterNum,
+					Line:     lineNum,
+					Subject:  source,
+					Message:  fmt.Sprintf("出现了设定「%s」规则禁止的「%s」", source, term),
+					Excerpt:  excerptAround(line, term),
+				}, fmt.Sprintf("rule:%s:%d:%d", term, chapterNum, lineNum))
+			}
+		}
+
+		for _, sentence := range sentenceEndPattern.FindAllString(line, -1) {
+			names := findCharacterNames(sentence, c.names)
+			if len(names) == 0 {
+				continue
+			}
+			for _, name := range names {
+				c.checkPresence(chapterNum, lineNum, name, sentence)
+			}
+			if len(names) == 1 {
+				c.checkAge(chapterNum, lineNum, names[0], sentence)
+				c.checkAppearance(chapterNum, lineNum, names[0], sentence)
+			} else {
+				c.collectRelations(chapterNum, lineNum, names, sentence)
+			}
+		}
+	}
+}
+
+// checkPresence 检查已死角色再次出场、角色在首次登场章节之前出现
+func (c *consistencyChecker) checkPresence(chapterNum, lineNum int, name, sentence string) {
+	char := c.characters[name]
+
+	if char.FirstAppeared > 0 && chapterNum < char.FirstAppeared {
+		c.add(&ConsistencyIssue{
+			Type:     IssueEarlyAppearance,
+			Severity: SeverityWarning,
+			Chapter:  chapterNum,
+			Line:     lineNum,
+			Subject:  name,
+			Message:  fmt.Sprintf("%s 设定在第%d章首次登场，但此处已出现", name, char.FirstAppeared),
+			Excerpt:  excerptAround(sentence, name),
+		}, fmt.Sprintf("early:%s:%d", name, chapterNum))
+	}
+
+	if char.IsDead() && char.DeathChapter > 0 && chapterNum > char.DeathChapter && !containsAny(sentence, memoryCues) {
+		c.add(&ConsistencyIssue{
+			Type:     IssueDeadCharacter,
+			Severity: SeverityError,
+			Chapter:  chapterNum,
+			Line:     lineNum,
+			Subject:  name,
+			Message:  fmt.Sprintf("%s 已在第%d章死亡，此处却再次出场", name, char.DeathChapter),
+			Excerpt:  excerptAround(sentence, name),
+		}, fmt.Sprintf("dead:%s:%d", name, chapterNum))
+	}
+}
+
+// checkAge 只在句中仅出现一个角色时比对年龄，回忆往事（“十岁那年”）不算；
+// 时间线能推算出该章年龄时以推算值为准
+func (c *consistencyChecker) checkAge(chapterNum, lineNum int, name, sentence string) {
+	char := c.characters[name]
+	expected, basis := char.Age, "设定年龄为"
+	if age, ok := c.timeline.AgeAt(char, chapterNum); ok {
+		expected, basis = age, "按时间线此时应为"
+	}
+	if expected <= 0 {
+		return
+	}
+	for _, match := range agePattern.FindAllStringSubmatch(sentence, -1) {
+		if match[0] != match[1]+"岁" && match[0] != match[1]+"周岁" {
+			continue
+		}
+		age, ok := parseChineseNumber(match[1])
+		if !ok || age == expected {
+			continue
+		}
+		c.add(&ConsistencyIssue{
+			Type:     IssueAge,
+			Severity: SeverityWarning,
+			Chapter:  chapterNum,
+			Line:     lineNum,
+			Subject:  name,
+			Message:  fmt.Sprintf("%s %s %d 岁，正文写作 %d 岁", name, basis, expected, age),
+			Excerpt:  excerptAround(sentence, match[0]),
+		}, fmt.Sprintf("age:%s:%d:%d", name, chapterNum, age))
+	}
+}
+
+// checkAppearance 比对发色、瞳色等与设定中外貌描述不同的颜色
+func (c *consistencyChecker) checkAppearance(chapterNum, lineNum int, name, sentence string) {
+	char := c.characters[name]
+	if char.Appearance == "" {
+		return
+	}
+	for _, feature := range appearanceFeatures {
+		expected := featureColors(char.Appearance, feature.Suffixes)
+		if len(expected) == 0 {
+			continue
+		}
+		for color, term := range featureColors(sentence, feature.Suffixes) {
+			if expected[color] != "" {
+				continue
+			}
+			c.add(&ConsistencyIssue{
+				Type:     IssueAppearance,
+				Severity: SeverityWarning,
+				Chapter:  chapterNum,
+				Line:     lineNum,
+				Subject:  name,
+				Message:  fmt.Sprintf("%s 的%s设定为「%s」，正文写作「%s」", name, feature.Name, joinMapValues(expected), term),
+				Excerpt:  excerptAround(sentence, term),
+			}, fmt.Sprintf("look:%s:%d:%d:%s", name, chapterNum, lineNum, term))
+		}
+	}
+}
+
+// collectRelations 识别“A的师父B”“B是A的师父”，并与角色设定中的关系比对
+func (c *consistencyChecker) collectRelations(chapterNum, lineNum int, names []string, sentence string) {
+	for _, from := range names {
+		for _, to := range names {
+			if from == to {
+				continue
+			}
+			re := c.pairPattern(from, to)
+			for _, match := range re.FindAllStringSubmatch(sentence, -1) {
+				label := match[1]
+				if label == "" {
+					label = match[2]
+				}
+				obs := relationObservation{
+					From: from, To: to, Label: label,
+					Chapter: chapterNum, Line: lineNum,
+					Excerpt: excerptAround(sentence, match[0]),
+				}
+				c.relations[from+"\x00"+to] = append(c.relations[from+"\x00"+to], obs)
+
+				registered := ""
+				if edge := c.graph.At(from, to, chapterNum); edge != nil {
+					registered = edge.Type
+				}
+				known := relationLabelsIn(registered)
+				if len(known) == 0 || known[canonicalRelation(label)] {
+					continue
+				}
+				c.add(&ConsistencyIssue{
+					Type:     IssueRelationship,
+					Severity: SeverityWarning,
+					Chapter:  chapterNum,
+					Line:     lineNum,
+					Subject:  from + "/" + to,
+					Message:  fmt.Sprintf("设定中 %s 是 %s 的「%s」，正文称为「%s」", to, from, registered, label),
+					Excerpt:  obs.Excerpt,
+				}, fmt.Sprintf("rel:%s:%s:%d:%d", from, to, chapterNum, lineNum))
+			}
+		}
+	}
+}
+
+// checkRelationshipHistory 同一对角色在不同章节被冠以不同称谓；关系图中记录过的关系变化不算
+func (c *consistencyChecker) checkRelationshipHistory() {
+	for _, observations := range c.relations {
+		first := observations[0]
+		for _, obs := range observations[1:] {
+			if canonicalRelation(obs.Label) == canonicalRelation(first.Label) || obs.Chapter == first.Chapter {
+				continue
+			}
+			if before, after := c.graph.At(obs.From, obs.To, first.Chapter), c.graph.At(obs.From, obs.To, obs.Chapter); before != after {
+				continue
+			}
+			c.add(&ConsistencyIssue{
+				Type:     IssueRelationship,
+				Severity: SeverityWarning,
+				Chapter:  obs.Chapter,
+				Line:     obs.Line,
+				Subject:  obs.From + "/" + obs.To,
+				Message: fmt.Sprintf("%s 是 %s 的「%s」，但第%d章第%d行称为「%s」",
+					obs.To, obs.From, obs.Label, first.Chapter, first.Line, first.Label),
+				Excerpt: obs.Excerpt,
+			}, fmt.Sprintf("relhist:%s:%s:%s", obs.From, obs.To, canonicalRelation(obs.Label)))
+		}
+	}
+}
+
+// checkRelationshipGraph 关系图中双方称谓互相矛盾（A 称 B 为师父，B 却视 A 为仇人）
+func (c *consistencyChecker) checkRelationshipGraph(fromChapter, toChapter int) {
+	for _, conflict := range c.graph.Conflicts() {
+		if toChapter > 0 && conflict.Chapter > toChapter {
+			continue
+		}
+		chapter := conflict.Chapter
+		if chapter < fromChapter {
+			chapter = fromChapter
+		}
+		c.add(&ConsistencyIssue{
+			Type:     IssueRelationship,
+			Severity: conflict.Severity,
+			Chapter:  chapter,
+			Subject:  conflict.A + "/" + conflict.B,
+			Message:  "人物关系设定矛盾：" + conflict.Message,
+		}, fmt.Sprintf("graph:%s:%s:%d", conflict.A, conflict.B, conflict.Chapter))
+	}
+}
+
+func (c *consistencyChecker) pairPattern(from, to string) *regexp.Regexp {
+	key := from + "\x00" + to
+	if re, ok := c.pairRes[key]; ok {
+		return re
+	}
+	labels := strings.Join(relationLabels, "|")
+	a, b := regexp.QuoteMeta(from), regexp.QuoteMeta(to)
+	re := regexp.MustCompile(fmt.Sprintf(`%s的(%s)[，,、：:\s]?%s|%s(?:是|乃是|乃|正是|便是)%s的(%s)`, a, labels, b, b, a, labels))
+	c.pairRes[key] = re
+	return re
+}
+
+// add 记录问题，同一 key 只记录一次
+func (c *consistencyChecker) add(issue *ConsistencyIssue, key string) {
+	if c.reported[key] {
+		return
+	}
+	c.reported[key] = true
+	c.issues = append(c.issues, issue)
+}
+
+// findCharacterNames 返回句中出现的角色名，长名优先并遮蔽已匹配部分
+func findCharacterNames(sentence string, names []string) []string {
+	found := make([]string, 0)
+	masked := sentence
+	for _, name := range names {
+		if strings.Contains(masked, name) {
+			found = append(found, name)
+			masked = strings.ReplaceAll(masked, name, strings.Repeat("\x00", len([]rune(name))))
+		}
+	}
+	return found
+}
+
+// forbiddenTerms 从世界观规则中提取禁用词，如“禁止：枪械”“不存在「传送阵」”
+func forbiddenTerms(settings map[string]*WorldSetting) map[string]string {
+	terms := make(map[string]string)
+	for name, setting := range settings {
+		for _, rule := range setting.Rules {
+			rule = strings.TrimSpace(rule)
+			if !containsAny(rule, []string{"禁止", "不存在", "没有", "不能", "不可", "不允许", "不得"}) {
+				continue
+			}
+			if quoted := quotedTermPattern.FindAllStringSubmatch(rule, -1); len(quoted) > 0 {
+				for _, match := range quoted {
+					terms[strings.TrimSpace(match[1])] = name
+				}
+				continue
+			}
+			if match := ruleTermPattern.FindStringSubmatch(strings.TrimRight(rule, "。.")); match != nil {
+				terms[match[1]] = name
+			}
+		}
+	}
+	delete(terms, "")
+	return terms
+}
+
+// featureColors 提取文本中某一外貌部位的颜色，返回 颜色 -> 原文词
+func featureColors(text string, suffixes []string) map[string]string {
+	colors := make(map[string]string)
+	for _, color := range appearanceColors {
+		for _, suffix := range suffixes {
+			term := color + suffix
+			if strings.Contains(text, term) {
+				colors[color] = term
+				break
+			}
+		}
+	}
+	return colors
+}
+
+// relationLabelsIn 提取设定文本中出现的已知称谓（归一后）
+func relationLabelsIn(text string) map[string]bool {
+	labels := make(map[string]bool)
+	for _, label := range relationLabels {
+		if strings.Contains(text, label) {
+			labels[canonicalRelation(label)] = true
+		}
+	}
+	return labels
+}
+
+func canonicalRelation(label string) string {
+	if canonical, ok := relationSynonyms[label]; ok {
+		return canonical
+	}
+	return label
+}
+
+// consistencySettingsSummary 供模型检查使用的设定摘要，调用方需持有读锁
+func consistencySettingsSummary(project *NovelProject) string {
+	var summary strings.Builder
+	for _, name := range sortedCharacterNames(project.Characters) {
+		char := project.Characters[name]
+		summary.WriteString(fmt.Sprintf("角色 %s:", name))
+		if char.Age > 0 {
+			summary.WriteString(fmt.Sprintf(" 年龄%d;", char.Age))
+		}
+		if char.Gender != "" {
+			summary.WriteString(fmt.Sprintf(" 性别%s;", char.Gender))
+		}
+		if char.Appearance != "" {
+			summary.WriteString(fmt.Sprintf(" 外貌:%s;", char.Appearance))
+		}
+		if len(char.Personality) > 0 {
+			summary.WriteString(fmt.Sprintf(" 性格:%s;", strings.Join(char.Personality, "、")))
+		}
+		if char.IsDead() {
+			summary.WriteString(fmt.Sprintf(" 已于第%d章死亡;", char.DeathChapter))
+		}
+		for other, relation := range char.Relationships {
+			summary.WriteString(fmt.Sprintf(" %s是其%s;", other, relation))
+		}
+		summary.WriteString("\n")
+	}
+	for name, setting := range project.WorldSettings {
+		summary.WriteString(fmt.Sprintf("设定 %s（%s）: %s\n", name, setting.Category, setting.Description))
+		for _, rule := range setting.Rules {
+			summary.WriteString(fmt.Sprintf("  规则: %s\n", rule))
+		}
+	}
+	if summary.Len() == 0 {
+		return "（暂无设定）\n"
+	}
+	return summary.String()
+}
+
+// extractJSONArray 去掉模型输出中可能包裹的代码块和说明文字
+func extractJSONArray(text string) string {
+	start := strings.Index(text, "[")
+	end := strings.LastIndex(text, "]")
+	if start < 0 || end < start {
+		return "[]"
+	}
+	return text[start : end+1]
+}
+
+func sortIssues(issues []*ConsistencyIssue) {
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Chapter != issues[j].Chapter {
+			return issues[i].Chapter < issues[j].Chapter
+		}
+		if issues[i].Line != issues[j].Line {
+			return issues[i].Line < issues[j].Line
+		}
+		return issues[i].Message < issues[j].Message
+	})
+}
+
+func issueTypeLabel(issueType string) string {
+	switch issueType {
+	case IssueDeadCharacter:
+		return "死亡角色"
+	case IssueEarlyAppearance:
+		return "提前登场"
+	case IssueAge:
+		return "年龄"
+	case IssueAppearance:
+		return "外貌"
+	case IssueWorldRule:
+		return "世界观"
+	case IssueRelationship:
+		return "人物关系"
+	case IssueAI:
+		return "AI审读"
+	default:
+		return issueType
+	}
+}
+
+// excerptAround 截取关键词附近的原文
+func excerptAround(text, keyword string) string {
+	text = strings.TrimSpace(text)
+	runes := []rune(text)
+	if len(runes) <= maxExcerptRunes {
+		return text
+	}
+	start := 0
+	if idx := strings.Index(text, keyword); idx >= 0 {
+		start = len([]rune(text[:idx])) - maxExcerptRunes/3
+		if start < 0 {
+			start = 0
+		}
+	}
+	end := start + maxExcerptRunes
+	if end > len(runes) {
+		end = len(runes)
+		start = end - maxExcerptRunes
+	}
+	result := string(runes[start:end])
+	if start > 0 {
+		result = "…" + result
+	}
+	if end < len(runes) {
+		result += "…"
+	}
+	return result
+}
+
+func excerpt(text string) string {
+	return excerptAround(text, "")
+}
+
+func containsAny(text string, keywords []string) bool {
+	for _, keyword := range keywords {
+		if strings.Contains(text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func uniqueSortedInts(values []int) []int {
+	sort.Ints(values)
+	result := make([]int, 0, len(values))
+	for i, v := range values {
+		if i == 0 || v != values[i-1] {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func joinInts(values []int, sep string) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprintf("%d", v)
+	}
+	return strings.Join(parts, sep)
+}
+
+func joinMapValues(values map[string]string) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		parts = append(parts, v)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "、")
+}
@@ -0,0 +1,115 @@
+package novel
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCheckConsistency(t *testing.T) {
+	tests := []struct {
+		name       string
+		characters []*Character
+		rules      []string // 世界观设定「世界规则」的规则
+		chapters   map[int]string
+		want       []string // 类型:章:行
+	}{
+		{
+			name:       "已死角色再次出场",
+			characters: []*Character{{Name: "李四", Status: CharacterDead, DeathChapter: 2}},
+			chapters:   map[int]string{2: "李四倒下了。", 3: "天亮了。\n李四推门而入。"},
+			want:       []string{"dead_character:3:2"},
+		},
+		{
+			name:       "回忆中提到已死角色不算",
+			characters: []*Character{{Name: "李四", Status: CharacterDead, DeathChapter: 2}},
+			chapters:   map[int]string{3: "他想起李四生前的笑容。"},
+			want:       []string{},
+		},
+		{
+			name:       "首次登场之前出现",
+			characters: []*Character{{Name: "王五", FirstAppeared: 5}},
+			chapters:   map[int]string{2: "王五站在门口。", 5: "王五来了。"},
+			want:       []string{"early_appearance:2:1"},
+		},
+		{
+			name:       "年龄与设定不符，回忆往事不算",
+			characters: []*Character{{Name: "林风", Age: 16}},
+			chapters:   map[int]string{1: "林风十岁那年离开了家。\n林风今年十八岁。\n林风今年16岁。"},
+			want:       []string{"age:1:2"},
+		},
+		{
+			name:       "发色与设定不符",
+			characters: []*Character{{Name: "林风", Appearance: "银发蓝眸"}},
+			chapters:   map[int]string{1: "林风甩了甩银发。\n林风甩了甩黑发。"},
+			want:       []string{"appearance:1:2"},
+		},
+		{
+			name:     "出现世界观规则禁止的事物",
+			rules:    []string{"禁止：枪械"},
+			chapters: map[int]string{1: "他拔出长剑。\n他掏出枪械。"},
+			want:     []string{"world_rule:1:2"},
+		},
+		{
+			name: "称谓与登记的关系不符",
+			characters: []*Character{
+				{Name: "林风", Relationships: map[string]string{"张三": "师父"}},
+				{Name: "张三"},
+			},
+			chapters: map[int]string{1: "张三是林风的师尊。\n张三是林风的仇人。"},
+			want:     []string{"relationship:1:2"},
+		},
+		{
+			name:       "前后章节称谓不一致",
+			characters: []*Character{{Name: "林风"}, {Name: "张三"}},
+			chapters:   map[int]string{1: "张三是林风的师父。", 4: "林风的哥哥张三笑了。"},
+			want:       []string{"relationship:4:1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nm := newTestManager(t)
+			for _, char := range tt.characters {
+				if _, err := nm.AddCharacter(char); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if len(tt.rules) > 0 {
+				nm.novelData.WorldSettings["世界规则"] = &WorldSetting{Name: "世界规则", Rules: tt.rules}
+			}
+			for number, text := range tt.chapters {
+				writeTestChapter(t, nm, number, text)
+			}
+
+			report, err := nm.CheckConsistency(0, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, 0, len(report.Issues))
+			for _, issue := range report.Issues {
+				got = append(got, fmt.Sprintf("%s:%d:%d", issue.Type, issue.Chapter, issue.Line))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("issues = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForbiddenTerms(t *testing.T) {
+	tests := []struct {
+		rule string
+		want map[string]string
+	}{
+		{"禁止：枪械", map[string]string{"枪械": "规则"}},
+		{"世上不存在「传送阵」和「复活术」", map[string]string{"传送阵": "规则", "复活术": "规则"}},
+		{"没有电力。", map[string]string{"电力": "规则"}},
+		{"修士每日需打坐三个时辰", map[string]string{}},
+	}
+	for _, tt := range tests {
+		settings := map[string]*WorldSetting{"规则": {Name: "规则", Rules: []string{tt.rule}}}
+		if got := forbiddenTerms(settings); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("forbiddenTerms(%q) = %v, want %v", tt.rule, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,835 @@
+package novel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/AiNovelTools/internal/ai"
+)
+
+// 说话人的判定方式
+const (
+	SpeakerByTag         = "tag"         // 引号前后的“某某道”
+	SpeakerByAction      = "action"      // 同段叙述只提到一个角色
+	SpeakerByAlternation = "alternation" // 两人轮流对话中的无标注台词
+	SpeakerByAI          = "ai"          // 模型判断
+)
+
+// 台词一致性问题类型
+const (
+	VoiceSelfReference = "self_reference" // 自称与一贯用法不符
+	VoiceFormality     = "formality"      // 文白、雅俗与一贯语气不符
+	VoiceCatchphrase   = "catchphrase"    // 台词较多却没有出现口头禅
+)
+
+const (
+	// 建立说话习惯所需的最少台词数
+	minVoiceLines = 5
+	// 每个角色保留的代表台词数
+	maxKeyDialogues = 8
+	// 说话人在引号后最多隔几个字出现
+	speechTagRunes = 12
+	// 模型辅助判断时每章最多送出的未判定台词数
+	maxAIDialogueLines = 60
+)
+
+var (
+	quotePattern = regexp.MustCompile(`「([^」]*)」|『([^』]*)』|“([^”]*)”|"([^"]*)"`)
+	// 紧跟在名字后的说话动作，如“林动淡淡道”“萧炎笑着问”
+	speechVerbPattern = regexp.MustCompile(`^[^，。！？；：,.!?;:“”「」『』"]{0,6}?(?:说道|问道|喊道|叫道|答道|喝道|吼道|笑道|骂道|叹道|开口|回答|说|问|喊|叫|答|道)`)
+	// 引号前以说话动作或冒号结尾，说话人是这段叙述中最后提到的角色
+	speechLeadPattern = regexp.MustCompile(`(?:说道|问道|喊道|叫道|答道|喝道|吼道|笑道|骂道|叹道|说|问|喊|道)?\s*[：:，,]?\s*$`)
+	// 自称，长的在前
+	selfReferenceTerms = []string{"本少爷", "本小姐", "本姑娘", "小女子", "本座", "老夫", "在下", "本王", "本宫", "本官", "本少", "老子", "老娘", "洒家", "贫道", "贫僧", "妾身", "哀家", "寡人", "晚辈", "小的", "奴婢", "朕", "俺", "吾", "我"}
+	// 敬语与书面语
+	formalMarkers = []string{"您", "阁下", "在下", "不才", "承蒙", "冒昧", "劳烦", "有劳", "久仰", "告辞", "多谢", "请", "恕", "贵", "敝"}
+	// 口语、俚语与粗话
+	casualMarkers = []string{"他娘的", "卧槽", "老子", "咋", "啥", "呗", "嘛", "喂", "哈", "嘿", "靠", "咱", "呀", "啊"}
+)
+
+// DialogueLine 一句台词
+type DialogueLine struct {
+	Chapter   int    `json:"chapter"`
+	Paragraph int    `json:"paragraph"` // 第几段（不计空行）
+	Line      int    `json:"line"`
+	Speaker   string `json:"speaker,omitempty"`
+	Method    string `json:"method,omitempty"` // 说话人的判定方式
+	Text      string `json:"text"`
+}
+
+// DialogueReport 台词提取结果
+type DialogueReport struct {
+	FromChapter int
+	ToChapter   int
+	Checked     []int
+	Missing     []int
+	Lines       []*DialogueLine
+}
+
+// VoiceProfile 角色的说话习惯
+type VoiceProfile struct {
+	Lines         int           `json:"lines"`
+	AvgLength     float64       `json:"avg_length"`               // 平均每句字数
+	Formal        float64       `json:"formal"`                   // 含敬语或书面语的台词占比
+	Casual        float64       `json:"casual"`                   // 含口语或粗话的台词占比
+	SelfReference []PhraseCount `json:"self_reference,omitempty"` // 自称及次数
+	Catchphrases  []PhraseCount `json:"catchphrases,omitempty"`   // 口头禅
+}
+
+// VoiceIssue 台词与角色说话习惯不符之处
+type VoiceIssue struct {
+	Type      string `json:"type"`
+	Chapter   int    `json:"chapter"`
+	Paragraph int    `json:"paragraph"` // 0 表示全章
+	Line      int    `json:"line"`
+	Speaker   string `json:"speaker"`
+	Message   string `json:"message"`
+	Excerpt   string `json:"excerpt"`
+}
+
+// VoiceReport 台词一致性报告
+type VoiceReport struct {
+	FromChapter  int
+	ToChapter    int
+	Profiles     map[string]*VoiceProfile // 以检查范围之前的台词建立的说话习惯
+	Lines        map[string]int           // 检查范围内各角色的台词数
+	Unattributed int
+	Issues       []*VoiceIssue
+}
+
+// ExtractDialogues 提取章节中的台词并推断说话人，from/to 为0时表示不限
+func (nm *NovelManager) ExtractDialogues(fromChapter, toChapter int) (*DialogueReport, error) {
+	nm.mutex.RLock()
+	if nm.novelData == nil {
+		nm.mutex.RUnlock()
+		return nil, fmt.Errorf("novel project not initialized")
+	}
+	matcher := nm.newCastMatcher()
+	nm.mutex.RUnlock()
+
+	numbers, err := nm.listChapterNumbers()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DialogueReport{FromChapter: fromChapter, ToChapter: toChapter}
+	for _, number := range numbers {
+		if (fromChapter > 0 && number < fromChapter) || (toChapter > 0 && number > toChapter) {
+			continue
+		}
+		text, err := nm.ReadChapterText(number)
+		if err != nil {
+			report.Missing = append(report.Missing, number)
+			continue
+		}
+		report.Lines = append(report.Lines, matcher.extractDialogues(number, text)...)
+		report.Checked = append(report.Checked, number)
+	}
+	return report, nil
+}
+
+// AttributeDialoguesWithAI 让模型判断单章中启发式规则未能确定说话人的台词，返回新判定的句数
+func (nm *NovelManager) AttributeDialoguesWithAI(ctx context.Context, client *ai.Client, report *DialogueReport, chapterNum int) (int, error) {
+	if client == nil {
+		return 0, fmt.Errorf("AI client not available")
+	}
+	pending := make([]*DialogueLine, 0)
+	for _, line := range report.Lines {
+		if line.Chapter == chapterNum && line.Speaker == "" && len(pending) < maxAIDialogueLines {
+			pending = append(pending, line)
+		}
+	}
+	if len(pending) == 0 {
+		return 0, nil
+	}
+	text, err := nm.ReadChapterText(chapterNum)
+	if err != nil {
+		return 0, err
+	}
+
+	nm.mutex.RLock()
+	if nm.novelData == nil {
+		nm.mutex.RUnlock()
+		return 0, fmt.Errorf("novel project not initialized")
+	}
+	matcher := nm.newCastMatcher()
+	names := sortedCharacterNames(nm.novelData.Characters)
+	nm.mutex.RUnlock()
+
+	var numbered strings.Builder
+	total := 0
+	for i, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		total += utf8.RuneCountInString(line)
+		if total > maxAICheckRunes {
+			numbered.WriteString("（后文过长已截断）\n")
+			break
+		}
+		numbered.WriteString(fmt.Sprintf("L%d: %s\n", i+1, line))
+	}
+	var questions strings.Builder
+	for i, line := range pending {
+		questions.WriteString(fmt.Sprintf("%d. L%d 「%s」\n", i+1, line.Line, truncateRunes(line.Text, 60)))
+	}
+
+	prompt := fmt.Sprintf(`你是小说编辑，请判断第%d章中以下台词分别是谁说的。
+
+【角色】%s
+【正文】（每行以行号开头）
+%s
+【待判断的台词】
+%s
+只能从角色列表中选择，无法确定的跳过。
+以JSON数组输出，不要输出其他内容，每项格式：
+{"index": 台词序号, "speaker": "角色名"}`, chapterNum, strings.Join(names, "、"), numbered.String(), questions.String())
+
+	response, _, err := client.Chat(ctx, []ai.Message{{Role: "user", Content: prompt}}, nil)
+	if err != nil {
+		return 0, fmt.Errorf("AI dialogue attribution failed: %w", err)
+	}
+	var answers []struct {
+		Index   int    `json:"index"`
+		Speaker string `json:"speaker"`
+	}
+	if err := json.Unmarshal([]byte(extractJSONArray(response)), &answers); err != nil {
+		return 0, fmt.Errorf("failed to parse AI response: %w", err)
+	}
+
+	attributed := 0
+	for _, answer := range answers {
+		if answer.Index < 1 || answer.Index > len(pending) {
+			continue
+		}
+		// 模型可能回答别名，统一成本名
+		owners := matcher.owners[strings.TrimSpace(answer.Speaker)]
+		if len(owners) != 1 {
+			continue
+		}
+		line := pending[answer.Index-1]
+		if line.Speaker == "" {
+			line.Speaker = owners[0]
+			line.Method = SpeakerByAI
+			attributed++
+		}
+	}
+	return attributed, nil
+}
+
+// SaveDialogues 按提取结果更新角色的说话习惯与代表台词
+func (nm *NovelManager) SaveDialogues(report *DialogueReport) ([]string, error) {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	if nm.novelData == nil {
+		return nil, fmt.Errorf("novel project not initialized")
+	}
+	names := sortedCharacterNames(nm.novelData.Characters)
+	bySpeaker := groupDialogues(report.Lines)
+	updated := make([]string, 0)
+	for _, name := range names {
+		lines := bySpeaker[name]
+		if len(lines) == 0 {
+			continue
+		}
+		char := nm.novelData.Characters[name]
+		char.Voice = buildVoiceProfile(lines, names)
+		char.KeyDialogues = keyDialogues(lines, char.Voice)
+		updated = append(updated, name)
+	}
+	if len(updated) == 0 {
+		return updated, nil
+	}
+	return updated, nm.SaveProject()
+}
+
+// CheckVoice 以第 fromChapter 章之前的台词为基准，检查范围内台词是否符合各角色的说话习惯。
+// dialogues 需包含第1章至 toChapter 章的提取结果
+func (nm *NovelManager) CheckVoice(dialogues *DialogueReport, fromChapter, toChapter int) (*VoiceReport, error) {
+	if fromChapter <= 0 {
+		return nil, fmt.Errorf("chapter is required")
+	}
+	if toChapter < fromChapter {
+		toChapter = fromChapter
+	}
+	nm.mutex.RLock()
+	if nm.novelData == nil {
+		nm.mutex.RUnlock()
+		return nil, fmt.Errorf("novel project not initialized")
+	}
+	names := sortedCharacterNames(nm.novelData.Characters)
+	nm.mutex.RUnlock()
+
+	baseline := make([]*DialogueLine, 0)
+	checking := make([]*DialogueLine, 0)
+	report := &VoiceReport{FromChapter: fromChapter, ToChapter: toChapter, Profiles: make(map[string]*VoiceProfile), Lines: make(map[string]int)}
+	for _, line := range dialogues.Lines {
+		switch {
+		case line.Chapter < fromChapter:
+			baseline = append(baseline, line)
+		case line.Chapter <= toChapter:
+			if line.Speaker == "" {
+				report.Unattributed++
+				continue
+			}
+			checking = append(checking, line)
+			report.Lines[line.Speaker]++
+		}
+	}
+	for speaker, lines := range groupDialogues(baseline) {
+		if report.Lines[speaker] > 0 && len(lines) >= minVoiceLines {
+			report.Profiles[speaker] = buildVoiceProfile(lines, names)
+		}
+	}
+
+	catchphraseSeen := make(map[string]map[int]bool)
+	for _, line := range checking {
+		profile, ok := report.Profiles[line.Speaker]
+		if !ok {
+			continue
+		}
+		if issue := profile.checkSelfReference(line); issue != nil {
+			report.Issues = append(report.Issues, issue)
+		}
+		if issue := profile.checkFormality(line); issue != nil {
+			report.Issues = append(report.Issues, issue)
+		}
+		for _, phrase := range profile.Catchphrases {
+			if strings.Contains(line.Text, phrase.Phrase) {
+				if catchphraseSeen[line.Speaker] == nil {
+					catchphraseSeen[line.Speaker] = make(map[int]bool)
+				}
+				catchphraseSeen[line.Speaker][line.Chapter] = true
+			}
+		}
+	}
+
+	// 台词多的章节完全没有口头禅，可能是人物声音变淡了
+	perChapter := make(map[string]map[int]int)
+	for _, line := range checking {
+		if perChapter[line.Speaker] == nil {
+			perChapter[line.Speaker] = make(map[int]int)
+		}
+		perChapter[line.Speaker][line.Chapter]++
+	}
+	for speaker, chapters := range perChapter {
+		profile, ok := report.Profiles[speaker]
+		if !ok || len(profile.Catchphrases) == 0 {
+			continue
+		}
+		for chapter, count := range chapters {
+			if count < minVoiceLines*2 || catchphraseSeen[speaker][chapter] {
+				continue
+			}
+			report.Issues = append(report.Issues, &VoiceIssue{
+				Type:    VoiceCatchphrase,
+				Chapter: chapter,
+				Speaker: speaker,
+				Message: fmt.Sprintf("%s 本章有 %d 句台词，但没有出现其常用说法「%s」", speaker, count, profile.Catchphrases[0].Phrase),
+			})
+		}
+	}
+
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		if report.Issues[i].Chapter != report.Issues[j].Chapter {
+			return report.Issues[i].Chapter < report.Issues[j].Chapter
+		}
+		return report.Issues[i].Paragraph < report.Issues[j].Paragraph
+	})
+	return report, nil
+}
+
+// extractDialogues 提取单章台词，并依次按说话标记、同段动作、轮流对话推断说话人
+func (m *castMatcher) extractDialogues(chapterNum int, text string) []*DialogueLine {
+	lines := make([]*DialogueLine, 0)
+	cast := m.scan(text)
+	resolve := func(hit termHit) string {
+		if len(hit.owners) == 1 {
+			return hit.owners[0]
+		}
+		return resolveShared(hit.owners, cast)
+	}
+
+	// 最近两段独立台词的说话人，用于判断轮流对话
+	var prevSpeakers [2]string
+	prevParagraph := 0
+	paragraph := 0
+	for i, raw := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		paragraph++
+		quotes := quotePattern.FindAllStringSubmatchIndex(raw, -1)
+		found := make([]*DialogueLine, 0, len(quotes))
+		narration := make([]string, 0, len(quotes)+1)
+		start := 0
+		for qi, loc := range quotes {
+			content := ""
+			for g := 2; g < len(loc); g += 2 {
+				if loc[g] >= 0 {
+					content = strings.TrimSpace(raw[loc[g]:loc[g+1]])
+					break
+				}
+			}
+			before := raw[start:loc[0]]
+			narration = append(narration, before)
+			start = loc[1]
+			if !isSpokenQuote(content, before) {
+				continue
+			}
+			after := raw[loc[1]:]
+			if qi+1 < len(quotes) {
+				after = raw[loc[1]:quotes[qi+1][0]]
+			}
+			line := &DialogueLine{Chapter: chapterNum, Paragraph: paragraph, Line: i + 1, Text: content}
+			if speaker := m.taggedSpeaker(before, after, resolve); speaker != "" {
+				line.Speaker, line.Method = speaker, SpeakerByTag
+			}
+			found = append(found, line)
+		}
+		narration = append(narration, raw[start:])
+		if len(found) == 0 {
+			continue
+		}
+
+		// 同段其余台词沿用唯一的标记说话人，否则看叙述中是否只提到一个角色
+		paragraphSpeaker, method := "", ""
+		tagged := make([]string, 0)
+		for _, line := range found {
+			if line.Speaker != "" && !containsString(tagged, line.Speaker) {
+				tagged = append(tagged, line.Speaker)
+			}
+		}
+		mentioned := make([]string, 0)
+		for _, hit := range m.locate(strings.Join(narration, "\n")) {
+			if name := resolve(hit); name != "" && !containsString(mentioned, name) {
+				mentioned = append(mentioned, name)
+			}
+		}
+		switch {
+		case len(tagged) == 1:
+			paragraphSpeaker, method = tagged[0], SpeakerByTag
+		case len(tagged) == 0 && len(mentioned) == 1:
+			paragraphSpeaker, method = mentioned[0], SpeakerByAction
+		case len(tagged) == 0 && len(mentioned) == 0 && prevParagraph == paragraph-1 &&
+			prevSpeakers[0] != "" && prevSpeakers[1] != "" && prevSpeakers[0] != prevSpeakers[1]:
+			paragraphSpeaker, method = prevSpeakers[0], SpeakerByAlternation
+		}
+		for _, line := range found {
+			if line.Speaker == "" && paragraphSpeaker != "" {
+				line.Speaker, line.Method = paragraphSpeaker, method
+			}
+		}
+
+		// 只有单一说话人的段落才计入轮流对话
+		last := found[len(found)-1].Speaker
+		if len(tagged) <= 1 {
+			if prevParagraph == paragraph-1 {
+				prevSpeakers = [2]string{prevSpeakers[1], last}
+			} else {
+				prevSpeakers = [2]string{"", last}
+			}
+			prevParagraph = paragraph
+		}
+		lines = append(lines, found...)
+	}
+	return lines
+}
+
+// taggedSpeaker 从引号前后的说话标记中找说话人
+func (m *castMatcher) taggedSpeaker(before, after string, resolve func(termHit) string) string {
+	// “……”林动淡淡道。
+	head := strings.TrimLeft(after, "，,。 \t")
+	if runes := []rune(head); len(runes) > speechTagRunes {
+		head = string(runes[:speechTagRunes])
+	}
+	for _, hit := range m.locate(head) {
+		rest := head[hit.pos+len(hit.term):]
+		if match := speechVerbPattern.FindString(rest); match != "" && !strings.HasSuffix(match, "知道") {
+			if name := resolve(hit); name != "" {
+				return name
+			}
+		}
+	}
+	// 林动看了他一眼，冷冷道：“……”
+	if strings.TrimSpace(before) == "" {
+		return ""
+	}
+	trimmed := strings.TrimRight(before, " \t")
+	if !strings.HasSuffix(trimmed, "：") && !strings.HasSuffix(trimmed, ":") && speechLeadPattern.FindString(trimmed) == "" {
+		return ""
+	}
+	hits := m.locate(before)
+	for i := len(hits) - 1; i >= 0; i-- {
+		if name := resolve(hits[i]); name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// isSpokenQuote 区分台词与用引号标出的名词、书名：台词带句读，或由冒号引出
+func isSpokenQuote(content, before string) bool {
+	if content == "" {
+		return false
+	}
+	if strings.ContainsAny(content, "，。！？…,.!?~～—") {
+		return true
+	}
+	before = strings.TrimSpace(before)
+	return before == "" || strings.HasSuffix(before, "：") || strings.HasSuffix(before, ":")
+}
+
+// groupDialogues 按说话人分组
+func groupDialogues(lines []*DialogueLine) map[string][]*DialogueLine {
+	groups := make(map[string][]*DialogueLine)
+	for _, line := range lines {
+		if line.Speaker != "" {
+			groups[line.Speaker] = append(groups[line.Speaker], line)
+		}
+	}
+	return groups
+}
+
+// buildVoiceProfile 统计一组台词的自称、语气与口头禅
+func buildVoiceProfile(lines []*DialogueLine, names []string) *VoiceProfile {
+	profile := &VoiceProfile{Lines: len(lines)}
+	selfCounts := make(map[string]int)
+	texts := make([]string, 0, len(lines))
+	chars, formal, casual := 0, 0, 0
+	for _, line := range lines {
+		texts = append(texts, line.Text)
+		chars += countStyleChars(line.Text)
+		if containsAny(line.Text, formalMarkers) {
+			formal++
+		}
+		if containsAny(line.Text, casualMarkers) {
+			casual++
+		}
+		for _, term := range findTerms(line.Text, selfReferenceTerms) {
+			selfCounts[term]++
+		}
+	}
+	if len(lines) > 0 {
+		profile.AvgLength = float64(chars) / float64(len(lines))
+		profile.Formal = float64(formal) / float64(len(lines))
+		profile.Casual = float64(casual) / float64(len(lines))
+	}
+	for term, count := range selfCounts {
+		profile.SelfReference = append(profile.SelfReference, PhraseCount{Phrase: term, Count: count})
+	}
+	sortPhraseCounts(profile.SelfReference)
+
+	// 口头禅：在多句台词中反复出现的说法，自称与角色名一样先断开，避免“夫早就说过”这类残片
+	separators := append(append(make([]string, 0, len(names)+len(selfReferenceTerms)), names...), selfReferenceTerms...)
+	for _, phrase := range recurringPhrases(texts, separators) {
+		profile.Catchphrases = append(profile.Catchphrases, phrase)
+		if len(profile.Catchphrases) >= 5 {
+			break
+		}
+	}
+	return profile
+}
+
+// checkSelfReference 一贯使用某个自称的角色换了自称
+func (p *VoiceProfile) checkSelfReference(line *DialogueLine) *VoiceIssue {
+	if len(p.SelfReference) == 0 {
+		return nil
+	}
+	dominant := p.SelfReference[0]
+	total := 0
+	for _, item := range p.SelfReference {
+		total += item.Count
+	}
+	if dominant.Count < 3 || float64(dominant.Count) < float64(total)*0.8 {
+		return nil
+	}
+	for _, term := range findTerms(line.Text, selfReferenceTerms) {
+		if p.selfReferenceCount(term) == 0 {
+			return &VoiceIssue{
+				Type:      VoiceSelfReference,
+				Chapter:   line.Chapter,
+				Paragraph: line.Paragraph,
+				Line:      line.Line,
+				Speaker:   line.Speaker,
+				Message:   fmt.Sprintf("%s 一贯自称「%s」，此处自称「%s」", line.Speaker, dominant.Phrase, term),
+				Excerpt:   excerptAround(line.Text, term),
+			}
+		}
+	}
+	return nil
+}
+
+// checkFormality 说话一向文雅的角色突然用口语，或一向随意的角色突然用敬语
+func (p *VoiceProfile) checkFormality(line *DialogueLine) *VoiceIssue {
+	issue := &VoiceIssue{
+		Type:      VoiceFormality,
+		Chapter:   line.Chapter,
+		Paragraph: line.Paragraph,
+		Line:      line.Line,
+		Speaker:   line.Speaker,
+	}
+	if p.Formal >= 0.3 && p.Casual < 0.05 {
+		if terms := findTerms(line.Text, casualMarkers); len(terms) > 0 {
+			issue.Message = fmt.Sprintf("%s 说话一向文雅（%.0f%% 的台词用敬语），此句用了口语「%s」", line.Speaker, p.Formal*100, terms[0])
+			issue.Excerpt = excerptAround(line.Text, terms[0])
+			return issue
+		}
+	}
+	if p.Casual >= 0.3 && p.Formal < 0.05 {
+		if terms := findTerms(line.Text, formalMarkers); len(terms) > 0 {
+			issue.Message = fmt.Sprintf("%s 说话一向随意（%.0f%% 的台词带口语），此句用了敬语「%s」", line.Speaker, p.Casual*100, terms[0])
+			issue.Excerpt = excerptAround(line.Text, terms[0])
+			return issue
+		}
+	}
+	return nil
+}
+
+func (p *VoiceProfile) selfReferenceCount(term string) int {
+	for _, item := range p.SelfReference {
+		if item.Phrase == term {
+			return item.Count
+		}
+	}
+	return 0
+}
+
+// Summary 一句话概括说话习惯，用于写作提示
+func (p *VoiceProfile) Summary() string {
+	parts := make([]string, 0, 3)
+	if len(p.SelfReference) > 0 && p.SelfReference[0].Phrase != "我" {
+		parts = append(parts, "自称「"+p.SelfReference[0].Phrase+"」")
+	}
+	switch {
+	case p.Formal >= 0.3 && p.Casual < 0.1:
+		parts = append(parts, "言语文雅")
+	case p.Casual >= 0.3 && p.Formal < 0.1:
+		parts = append(parts, "说话随意")
+	}
+	if len(p.Catchphrases) > 0 {
+		phrases := make([]string, 0, 3)
+		for i, phrase := range p.Catchphrases {
+			if i >= 3 {
+				break
+			}
+			phrases = append(phrases, "「"+phrase.Phrase+"」")
+		}
+		parts = append(parts, "常说"+strings.Join(phrases, ""))
+	}
+	return strings.Join(parts, "，")
+}
+
+// keyDialogues 挑选代表台词：优先含口头禅和特殊自称、长度适中的句子，尽量来自不同章节
+func keyDialogues(lines []*DialogueLine, profile *VoiceProfile) []string {
+	type candidate struct {
+		line  *DialogueLine
+		score int
+	}
+	candidates := make([]candidate, 0, len(lines))
+	seen := make(map[string]bool)
+	for _, line := range lines {
+		if seen[line.Text] {
+			continue
+		}
+		seen[line.Text] = true
+		score := 0
+		for _, phrase := range profile.Catchphrases {
+			if strings.Contains(line.Text, phrase.Phrase) {
+				score += 3
+				break
+			}
+		}
+		for _, term := range findTerms(line.Text, selfReferenceTerms) {
+			if term != "我" {
+				score += 2
+				break
+			}
+		}
+		if n := countStyleChars(line.Text); n >= 8 && n <= 40 {
+			score++
+		}
+		candidates = append(candidates, candidate{line, score})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+
+	picked := make([]*DialogueLine, 0, maxKeyDialogues)
+	usedChapters := make(map[int]bool)
+	// 先每章至多一句，不够再补
+	for pass := 0; pass < 2 && len(picked) < maxKeyDialogues; pass++ {
+		for _, c := range candidates {
+			if len(picked) >= maxKeyDialogues {
+				break
+			}
+			if containsDialogue(picked, c.line) || (pass == 0 && usedChapters[c.line.Chapter]) {
+				continue
+			}
+			picked = append(picked, c.line)
+			usedChapters[c.line.Chapter] = true
+		}
+	}
+	sort.SliceStable(picked, func(i, j int) bool { return picked[i].Chapter < picked[j].Chapter })
+
+	result := make([]string, len(picked))
+	for i, line := range picked {
+		result[i] = fmt.Sprintf("「%s」（第%d章）", line.Text, line.Chapter)
+	}
+	return result
+}
+
+func containsDialogue(lines []*DialogueLine, target *DialogueLine) bool {
+	for _, line := range lines {
+		if line == target {
+			return true
+		}
+	}
+	return false
+}
+
+// findTerms 按列表顺序（长的在前）找出文本中出现的词，已匹配的部分不再重复匹配
+func findTerms(text string, terms []string) []string {
+	found := make([]string, 0)
+	for _, term := range terms {
+		if strings.Contains(text, term) {
+			found = append(found, term)
+			text = strings.ReplaceAll(text, term, "\x00")
+		}
+	}
+	return found
+}
+
+func sortPhraseCounts(items []PhraseCount) {
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Count != items[j].Count {
+			return items[i].Count > items[j].Count
+		}
+		return items[i].Phrase < items[j].Phrase
+	})
+}
+
+// Format 格式化台词提取结果
+func (r *DialogueReport) Format() string {
+	var result strings.Builder
+	result.WriteString("💬 === 台词提取 ===\n\n")
+	if len(r.Checked) == 0 {
+		result.WriteString("没有可提取的章节正文\n")
+		return result.String()
+	}
+	result.WriteString(fmt.Sprintf("提取范围: 第%d章 - 第%d章（共 %d 章）\n", r.Checked[0], r.Checked[len(r.Checked)-1], len(r.Checked)))
+
+	methods := make(map[string]int)
+	for _, line := range r.Lines {
+		methods[line.Method]++
+	}
+	attributed := len(r.Lines) - methods[""]
+	result.WriteString(fmt.Sprintf("台词 %d 句，已判定说话人 %d 句", len(r.Lines), attributed))
+	if len(r.Lines) > 0 {
+		result.WriteString(fmt.Sprintf("（%.0f%%）", float64(attributed)*100/float64(len(r.Lines))))
+	}
+	result.WriteString("\n")
+	labels := []struct{ method, label string }{
+		{SpeakerByTag, "说话标记"}, {SpeakerByAction, "同段动作"}, {SpeakerByAlternation, "轮流对话"}, {SpeakerByAI, "模型判断"},
+	}
+	details := make([]string, 0, len(labels))
+	for _, item := range labels {
+		if methods[item.method] > 0 {
+			details = append(details, fmt.Sprintf("%s %d", item.label, methods[item.method]))
+		}
+	}
+	if len(details) > 0 {
+		result.WriteString("判定方式: " + strings.Join(details, "，") + "\n")
+	}
+
+	groups := groupDialogues(r.Lines)
+	speakers := make([]string, 0, len(groups))
+	for speaker := range groups {
+		speakers = append(speakers, speaker)
+	}
+	sort.Slice(speakers, func(i, j int) bool {
+		if len(groups[speakers[i]]) != len(groups[speakers[j]]) {
+			return len(groups[speakers[i]]) > len(groups[speakers[j]])
+		}
+		return speakers[i] < speakers[j]
+	})
+	if len(speakers) > 0 {
+		result.WriteString("\n📊 各角色台词数:\n")
+		for _, speaker := range speakers {
+			result.WriteString(fmt.Sprintf("• %s: %d 句\n", speaker, len(groups[speaker])))
+		}
+	}
+	return result.String()
+}
+
+// Format 格式化台词一致性报告
+func (r *VoiceReport) Format() string {
+	var result strings.Builder
+	result.WriteString("🗣 === 台词一致性 ===\n\n")
+	if r.FromChapter == r.ToChapter {
+		result.WriteString(fmt.Sprintf("检查范围: 第%d章，对照此前章节的说话习惯\n", r.FromChapter))
+	} else {
+		result.WriteString(fmt.Sprintf("检查范围: 第%d章 - 第%d章，对照此前章节的说话习惯\n", r.FromChapter, r.ToChapter))
+	}
+
+	speakers := make([]string, 0, len(r.Lines))
+	for speaker := range r.Lines {
+		speakers = append(speakers, speaker)
+	}
+	sort.Strings(speakers)
+	for _, speaker := range speakers {
+		profile, ok := r.Profiles[speaker]
+		if !ok {
+			result.WriteString(fmt.Sprintf("• %s: %d 句（此前台词不足 %d 句，未比对）\n", speaker, r.Lines[speaker], minVoiceLines))
+			continue
+		}
+		line := fmt.Sprintf("• %s: %d 句，此前 %d 句", speaker, r.Lines[speaker], profile.Lines)
+		if summary := profile.Summary(); summary != "" {
+			line += "，" + summary
+		}
+		result.WriteString(line + "\n")
+	}
+	if r.Unattributed > 0 {
+		result.WriteString(fmt.Sprintf("未判定说话人的台词 %d 句（可加 use_ai=true 让模型判断）\n", r.Unattributed))
+	}
+
+	if len(r.Issues) == 0 {
+		result.WriteString("\n✅ 台词与人物的说话习惯一致\n")
+		return result.String()
+	}
+	result.WriteString(fmt.Sprintf("\n发现 %d 处不一致:\n", len(r.Issues)))
+	chapter := -1
+	for _, issue := range r.Issues {
+		if issue.Chapter != chapter {
+			chapter = issue.Chapter
+			result.WriteString(fmt.Sprintf("\n📄 第%d章\n", chapter))
+		}
+		location := "全章"
+		if issue.Paragraph > 0 {
+			location = fmt.Sprintf("第%d段（第%d行）", issue.Paragraph, issue.Line)
+		}
+		result.WriteString(fmt.Sprintf("  ⚠️ [%s] %s: %s\n", voiceIssueLabel(issue.Type), location, issue.Message))
+		if issue.Excerpt != "" {
+			result.WriteString(fmt.Sprintf("      「%s」\n", issue.Excerpt))
+		}
+	}
+	return result.String()
+}
+
+func voiceIssueLabel(issueType string) string {
+	switch issueType {
+	case VoiceSelfReference:
+		return "自称"
+	case VoiceFormality:
+		return "语气"
+	case VoiceCatchphrase:
+		return "口头禅"
+	default:
+		return issueType
+	}
+}
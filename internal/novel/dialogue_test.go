@@ -0,0 +1,113 @@
+package novel
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestExtractDialoguesSpeakers(t *testing.T) {
+	nm := newTestManager(t)
+	for _, name := range []string{"林动", "萧炎"} {
+		if _, err := nm.AddCharacter(&Character{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	text := "“你来了。”林动淡淡道。\n" +
+		"萧炎看了他一眼，冷冷道：“不该来吗？”\n" +
+		"“当然不该。”\n" +
+		"“那又如何。”\n" +
+		"\n" +
+		"林动握紧了拳头。“走着瞧。”\n" +
+		"他想起那本“焚诀”。\n"
+
+	lines := nm.newCastMatcher().extractDialogues(3, text)
+	got := make([]string, 0, len(lines))
+	for _, line := range lines {
+		got = append(got, fmt.Sprintf("%d:%d:%s:%s:%s", line.Paragraph, line.Line, line.Speaker, line.Method, line.Text))
+	}
+	want := []string{
+		"1:1:林动:tag:你来了。",
+		"2:2:萧炎:tag:不该来吗？",
+		"3:3:林动:alternation:当然不该。",
+		"4:4:萧炎:alternation:那又如何。",
+		"5:6:林动:action:走着瞧。",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("extractDialogues() =\n%v\nwant\n%v", got, want)
+	}
+	for _, line := range lines {
+		if line.Chapter != 3 {
+			t.Errorf("line %q chapter = %d", line.Text, line.Chapter)
+		}
+	}
+}
+
+func TestIsSpokenQuote(t *testing.T) {
+	tests := []struct {
+		content, before string
+		want            bool
+	}{
+		{content: "走吧。", before: "他说", want: true},
+		{content: "焚诀", before: "他想起那本", want: false},
+		{content: "走", before: "他喝道：", want: true},
+		{content: "走", before: "", want: true},
+		{content: "", before: "", want: false},
+	}
+	for _, tt := range tests {
+		if got := isSpokenQuote(tt.content, tt.before); got != tt.want {
+			t.Errorf("isSpokenQuote(%q, %q) = %v, want %v", tt.content, tt.before, got, tt.want)
+		}
+	}
+}
+
+func TestCheckVoice(t *testing.T) {
+	nm := newTestManager(t)
+	for _, name := range []string{"萧炎", "林动"} {
+		if _, err := nm.AddCharacter(&Character{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := nm.CheckVoice(&DialogueReport{}, 0, 0); err == nil {
+		t.Error("CheckVoice accepted chapter 0")
+	}
+
+	report := &DialogueReport{}
+	add := func(chapter int, speaker, text string, count int) {
+		for i := 0; i < count; i++ {
+			report.Lines = append(report.Lines, &DialogueLine{Chapter: chapter, Paragraph: len(report.Lines) + 1, Speaker: speaker, Text: text})
+		}
+	}
+	// 第一章建立说话习惯：萧炎一贯自称“老子”，说话随意；林动台词太少，不建立
+	add(1, "萧炎", "老子今天就要走，谁敢拦？", 6)
+	add(1, "林动", "请留步。", 2)
+	// 第二章：萧炎改了自称并用了敬语，台词很多却没有口头禅
+	add(2, "萧炎", "走吧。", 10)
+	add(2, "萧炎", "在下告辞。", 1)
+	add(2, "林动", "您慢走。", 1)
+	add(2, "", "谁在那里？", 2)
+
+	voice, err := nm.CheckVoice(report, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := voice.Profiles["林动"]; ok || voice.Profiles["萧炎"] == nil {
+		t.Fatalf("profiles = %v", voice.Profiles)
+	}
+	profile := voice.Profiles["萧炎"]
+	if profile.SelfReference[0].Phrase != "老子" || profile.Casual != 1 || len(profile.Catchphrases) == 0 {
+		t.Errorf("profile = %+v", profile)
+	}
+	if voice.Unattributed != 2 || voice.Lines["萧炎"] != 11 || voice.ToChapter != 2 {
+		t.Errorf("Unattributed = %d, Lines = %v, ToChapter = %d", voice.Unattributed, voice.Lines, voice.ToChapter)
+	}
+
+	got := make([]string, 0, len(voice.Issues))
+	for _, issue := range voice.Issues {
+		got = append(got, fmt.Sprintf("%s:%d:%s", issue.Type, issue.Paragraph, issue.Speaker))
+	}
+	want := []string{"catchphrase:0:萧炎", "self_reference:19:萧炎", "formality:19:萧炎"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("issues = %v, want %v", got, want)
+	}
+}
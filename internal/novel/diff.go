@@ -0,0 +1,334 @@
+package novel
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 版本比较的粒度
+const (
+	DiffParagraph = "paragraph"
+	DiffSentence  = "sentence"
+)
+
+const (
+	// 比较表的最大格数（旧单元数 × 新单元数），超出时按段落比较
+	maxDiffCells = 4000000
+	// 逐字标出句内修改的最大格数
+	maxInlineDiffCells = 1000000
+	// 改动前后保留的未改动单元数
+	diffContextUnits = 1
+)
+
+// 差异操作
+const (
+	DiffEqual   = "="
+	DiffDelete  = "-"
+	DiffInsert  = "+"
+	DiffReplace = "~" // 同一句或同一段被修改，Inline 为逐字标记
+)
+
+// sentenceEnds 句末标点，其后紧跟的引号、括号归入同一句
+const (
+	sentenceEnds    = "。！？!?…；;"
+	sentenceClosers = "」』”’）)》\"'"
+)
+
+// DiffOp 一处差异
+type DiffOp struct {
+	Kind   string
+	Old    string
+	New    string
+	Inline string // 修改时的逐字标记：[-删去-]{+新增+}
+}
+
+// ChapterDiff 章节两个版本的差异
+type ChapterDiff struct {
+	Chapter     int
+	From        int // 0 表示当前正文
+	To          int
+	Granularity string
+	OldWords    int
+	NewWords    int
+	Ops         []DiffOp
+}
+
+// DiffChapterVersions 比较章节的两个版本，to 为0时与当前正文比较
+func (nm *NovelManager) DiffChapterVersions(chapterNum, from, to int, granularity string) (*ChapterDiff, error) {
+	if granularity == "" {
+		granularity = DiffSentence
+	}
+	if granularity != DiffSentence && granularity != DiffParagraph {
+		return nil, fmt.Errorf("unknown diff granularity: %s", granularity)
+	}
+	oldText, err := nm.ReadChapterVersion(chapterNum, from)
+	if err != nil {
+		return nil, err
+	}
+	newText, err := nm.ReadChapterVersion(chapterNum, to)
+	if err != nil {
+		return nil, err
+	}
+	diff := &ChapterDiff{Chapter: chapterNum, From: from, To: to, OldWords: CountWords(oldText), NewWords: CountWords(newText)}
+	diff.Ops, diff.Granularity = DiffText(oldText, newText, granularity)
+	return diff, nil
+}
+
+// DiffText 按段落或句子比较两段正文，句子按中文标点切分；单元过多时退回按段落比较，返回实际使用的粒度
+func DiffText(oldText, newText, granularity string) ([]DiffOp, string) {
+	oldUnits, newUnits := splitDiffUnits(oldText, granularity), splitDiffUnits(newText, granularity)
+	if granularity == DiffSentence && len(oldUnits)*len(newUnits) > maxDiffCells {
+		granularity = DiffParagraph
+		oldUnits, newUnits = splitDiffUnits(oldText, granularity), splitDiffUnits(newText, granularity)
+	}
+	return pairReplacements(diffUnits(oldUnits, newUnits)), granularity
+}
+
+// splitDiffUnits 把正文切成段落或句子，去掉空白行和首尾空白
+func splitDiffUnits(text, granularity string) []string {
+	units := make([]string, 0)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if granularity == DiffParagraph {
+			units = append(units, line)
+			continue
+		}
+		runes := []rune(line)
+		start := 0
+		for i := 0; i < len(runes); i++ {
+			if !strings.ContainsRune(sentenceEnds, runes[i]) {
+				continue
+			}
+			for i+1 < len(runes) && (strings.ContainsRune(sentenceEnds, runes[i+1]) || strings.ContainsRune(sentenceClosers, runes[i+1])) {
+				i++
+			}
+			units = append(units, string(runes[start:i+1]))
+			start = i + 1
+		}
+		if start < len(runes) {
+			units = append(units, string(runes[start:]))
+		}
+	}
+	return units
+}
+
+// diffUnits 以最长公共子序列比较两组单元，先去掉相同的开头和结尾
+func diffUnits(a, b []string) []DiffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]DiffOp, 0, len(a)+len(b))
+	for _, unit := range a[:prefix] {
+		ops = append(ops, DiffOp{Kind: DiffEqual, Old: unit, New: unit})
+	}
+	ops = append(ops, lcsDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, unit := range a[len(a)-suffix:] {
+		ops = append(ops, DiffOp{Kind: DiffEqual, Old: unit, New: unit})
+	}
+	return ops
+}
+
+func lcsDiff(a, b []string) []DiffOp {
+	// lengths[i][j] 为 a[i:] 与 b[j:] 的最长公共子序列长度
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]DiffOp, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, DiffOp{Kind: DiffEqual, Old: a[i], New: b[j]})
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			ops = append(ops, DiffOp{Kind: DiffDelete, Old: a[i]})
+			i++
+		default:
+			ops = append(ops, DiffOp{Kind: DiffInsert, New: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, DiffOp{Kind: DiffDelete, Old: a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, DiffOp{Kind: DiffInsert, New: b[j]})
+	}
+	return ops
+}
+
+// pairReplacements 把相邻的删除和新增按顺序配对，相似度过半的视为修改并逐字标出改动
+func pairReplacements(ops []DiffOp) []DiffOp {
+	result := make([]DiffOp, 0, len(ops))
+	for i := 0; i < len(ops); {
+		if ops[i].Kind == DiffEqual {
+			result = append(result, ops[i])
+			i++
+			continue
+		}
+		deletes, inserts := make([]DiffOp, 0), make([]DiffOp, 0)
+		for ; i < len(ops) && ops[i].Kind != DiffEqual; i++ {
+			if ops[i].Kind == DiffDelete {
+				deletes = append(deletes, ops[i])
+			} else {
+				inserts = append(inserts, ops[i])
+			}
+		}
+		for k := 0; k < len(deletes) || k < len(inserts); k++ {
+			switch {
+			case k >= len(inserts):
+				result = append(result, deletes[k])
+			case k >= len(deletes):
+				result = append(result, inserts[k])
+			default:
+				if inline, ok := inlineDiff(deletes[k].Old, inserts[k].New); ok {
+					result = append(result, DiffOp{Kind: DiffReplace, Old: deletes[k].Old, New: inserts[k].New, Inline: inline})
+				} else {
+					result = append(result, deletes[k], inserts[k])
+				}
+			}
+		}
+	}
+	return result
+}
+
+// inlineDiff 逐字比较一句（段）的新旧写法，相同的字不足一半时不视为修改
+func inlineDiff(oldText, newText string) (string, bool) {
+	a, b := []rune(oldText), []rune(newText)
+	if len(a)*len(b) > maxInlineDiffCells {
+		return "", false
+	}
+	oldChars, newChars := make([]string, len(a)), make([]string, len(b))
+	for i, r := range a {
+		oldChars[i] = string(r)
+	}
+	for i, r := range b {
+		newChars[i] = string(r)
+	}
+	ops := lcsDiff(oldChars, newChars)
+	same := 0
+	for _, op := range ops {
+		if op.Kind == DiffEqual {
+			same++
+		}
+	}
+	if same*2 < max(len(a), len(b)) {
+		return "", false
+	}
+
+	var inline strings.Builder
+	for i := 0; i < len(ops); {
+		kind := ops[i].Kind
+		var run strings.Builder
+		for ; i < len(ops) && ops[i].Kind == kind; i++ {
+			if kind == DiffInsert {
+				run.WriteString(ops[i].New)
+			} else {
+				run.WriteString(ops[i].Old)
+			}
+		}
+		switch kind {
+		case DiffEqual:
+			inline.WriteString(run.String())
+		case DiffDelete:
+			inline.WriteString("[-" + run.String() + "-]")
+		case DiffInsert:
+			inline.WriteString("{+" + run.String() + "+}")
+		}
+	}
+	return inline.String(), true
+}
+
+// Counts 新增、删除、修改的单元数
+func (d *ChapterDiff) Counts() (added, removed, changed int) {
+	for _, op := range d.Ops {
+		switch op.Kind {
+		case DiffInsert:
+			added++
+		case DiffDelete:
+			removed++
+		case DiffReplace:
+			changed++
+		}
+	}
+	return added, removed, changed
+}
+
+// Format 格式化版本差异，只保留改动附近的未改动内容
+func (d *ChapterDiff) Format() string {
+	versionLabel := func(version int) string {
+		if version == 0 {
+			return "当前正文"
+		}
+		return fmt.Sprintf("v%d", version)
+	}
+	unit := labelOr(map[string]string{DiffSentence: "句", DiffParagraph: "段"}, d.Granularity)
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("🔍 === 第%d章 %s → %s ===\n\n", d.Chapter, versionLabel(d.From), versionLabel(d.To)))
+	added, removed, changed := d.Counts()
+	result.WriteString(fmt.Sprintf("字数: %d → %d（%+d）\n", d.OldWords, d.NewWords, d.NewWords-d.OldWords))
+	if added+removed+changed == 0 {
+		result.WriteString("\n✅ 两个版本内容相同\n")
+		return result.String()
+	}
+	result.WriteString(fmt.Sprintf("新增 %d %s，删除 %d %s，修改 %d %s\n", added, unit, removed, unit, changed, unit))
+	result.WriteString("说明: - 删除  + 新增  ~ 修改（[-删去-]{+新增+}）\n\n")
+
+	near := make([]bool, len(d.Ops))
+	for i, op := range d.Ops {
+		if op.Kind == DiffEqual {
+			continue
+		}
+		for k := max(0, i-diffContextUnits); k <= min(len(d.Ops)-1, i+diffContextUnits); k++ {
+			near[k] = true
+		}
+	}
+	skipped := 0
+	flush := func() {
+		if skipped > 0 {
+			result.WriteString(fmt.Sprintf("  ⋯ %d %s未改动\n", skipped, unit))
+			skipped = 0
+		}
+	}
+	for i, op := range d.Ops {
+		if !near[i] {
+			skipped++
+			continue
+		}
+		flush()
+		switch op.Kind {
+		case DiffEqual:
+			result.WriteString("  " + op.Old + "\n")
+		case DiffDelete:
+			result.WriteString("- " + op.Old + "\n")
+		case DiffInsert:
+			result.WriteString("+ " + op.New + "\n")
+		case DiffReplace:
+			result.WriteString("~ " + op.Inline + "\n")
+		}
+	}
+	flush()
+	return result.String()
+}
@@ -0,0 +1,174 @@
+package novel
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitDiffUnits(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		granularity string
+		want        []string
+	}{
+		{"句末标点切分", "天黑了。他回家了！", DiffSentence, []string{"天黑了。", "他回家了！"}},
+		{"引号随句末标点归入同一句", "他说：“走吧。”她点头。", DiffSentence, []string{"他说：“走吧。”", "她点头。"}},
+		{"连续标点和省略号", "真的吗？？……他愣住了", DiffSentence, []string{"真的吗？？……", "他愣住了"}},
+		{"英文标点", "Hi! OK? 好的;", DiffSentence, []string{"Hi!", " OK?", " 好的;"}},
+		{"跳过空行与首尾空白", "  第一段。\n\n\t第二段。  \n", DiffSentence, []string{"第一段。", "第二段。"}},
+		{"按段落", "第一句。第二句。\n\n第二段。", DiffParagraph, []string{"第一句。第二句。", "第二段。"}},
+		{"空文本", "\n\n", DiffSentence, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitDiffUnits(tt.text, tt.granularity); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitDiffUnits(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInlineDiff(t *testing.T) {
+	tests := []struct {
+		old, new string
+		want     string
+		wantOK   bool
+	}{
+		{"他慢慢地走了。", "他快快地走了。", "他[-慢慢-]{+快快+}地走了。", true},
+		{"她笑了。", "她轻轻地笑了。", "她{+轻轻地+}笑了。", true},
+		{"雨下了一整夜。", "雨下了一夜。", "雨下了一[-整-]夜。", true},
+		{"相同的句子。", "相同的句子。", "相同的句子。", true},
+		{"天亮了。", "他拔剑出鞘。", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := inlineDiff(tt.old, tt.new)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("inlineDiff(%q, %q) = %q, %v, want %q, %v", tt.old, tt.new, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestDiffText(t *testing.T) {
+	tests := []struct {
+		name        string
+		old, new    string
+		granularity string
+		want        []string // 每个操作的 Kind 加内容，修改时为逐字标记
+		wantGrain   string
+	}{
+		{
+			name:      "没有改动",
+			old:       "甲。乙。",
+			new:       "甲。乙。",
+			want:      []string{"=甲。", "=乙。"},
+			wantGrain: DiffSentence,
+		},
+		{
+			name:      "修改、新增和删除",
+			old:       "他推开门。屋里很暗。窗外下着雨。",
+			new:       "他轻轻推开门。窗外下着雨。远处传来钟声。",
+			want:      []string{"~他{+轻轻+}推开门。", "-屋里很暗。", "=窗外下着雨。", "+远处传来钟声。"},
+			wantGrain: DiffSentence,
+		},
+		{
+			name:      "差别太大不配对为修改",
+			old:       "天亮了。",
+			new:       "他拔剑出鞘。",
+			want:      []string{"-天亮了。", "+他拔剑出鞘。"},
+			wantGrain: DiffSentence,
+		},
+		{
+			name:        "按段落比较",
+			old:         "第一段。\n第二段。",
+			granularity: DiffParagraph,
+			new:         "第一段。\n第二段改。",
+			want:        []string{"=第一段。", "~第二段{+改+}。"},
+			wantGrain:   DiffParagraph,
+		},
+		{
+			name:      "句子过多时退回按段落",
+			old:       strings.Repeat("甲。", 2001) + "\n尾段。",
+			new:       strings.Repeat("乙。", 2001) + "\n尾段。",
+			want:      []string{"-" + strings.Repeat("甲。", 2001), "+" + strings.Repeat("乙。", 2001), "=尾段。"},
+			wantGrain: DiffParagraph,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			granularity := tt.granularity
+			if granularity == "" {
+				granularity = DiffSentence
+			}
+			ops, used := DiffText(tt.old, tt.new, granularity)
+			if used != tt.wantGrain {
+				t.Errorf("granularity = %s, want %s", used, tt.wantGrain)
+			}
+			got := make([]string, 0, len(ops))
+			for _, op := range ops {
+				switch op.Kind {
+				case DiffEqual, DiffDelete:
+					got = append(got, op.Kind+op.Old)
+				case DiffInsert:
+					got = append(got, op.Kind+op.New)
+				case DiffReplace:
+					got = append(got, op.Kind+op.Inline)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ops = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRollbackChapter(t *testing.T) {
+	nm := newTestManager(t)
+	writeTestChapter(t, nm, 1, "第一稿。")
+	if _, err := nm.SnapshotChapter(1, "初稿"); err != nil {
+		t.Fatal(err)
+	}
+	// 在编辑器里改过但还没有记录为版本
+	writeTestChapter(t, nm, 1, "改过的第二稿。")
+
+	if _, err := nm.RollbackChapter(1, 5); err == nil {
+		t.Error("expected rollback to a missing version to fail")
+	}
+	version, err := nm.RollbackChapter(1, 1)
+	if err != nil {
+		t.Fatalf("RollbackChapter: %v", err)
+	}
+	if version.Source != VersionRollback || version.Number != 3 {
+		t.Errorf("rollback version = %+v", *version)
+	}
+
+	if text, err := nm.ReadChapterText(1); err != nil || text != "第一稿。" {
+		t.Errorf("chapter text = %q, %v", text, err)
+	}
+	versions, err := nm.ChapterVersions(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources := make([]string, 0, len(versions))
+	for _, v := range versions {
+		sources = append(sources, v.Source)
+	}
+	if want := []string{VersionSnapshot, VersionEdit, VersionRollback}; !reflect.DeepEqual(sources, want) {
+		t.Errorf("version sources = %v, want %v", sources, want)
+	}
+	if text, err := nm.ReadChapterVersion(1, 2); err != nil || text != "改过的第二稿。" {
+		t.Errorf("unsaved edit should be kept as version 2, got %q, %v", text, err)
+	}
+	if chapter := nm.findChapter(1); chapter == nil || chapter.WordCount != 3 {
+		t.Errorf("chapter stats not synced after rollback: %+v", chapter)
+	}
+
+	diff, err := nm.DiffChapterVersions(1, 2, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added, removed, changed := diff.Counts(); added+removed+changed == 0 {
+		t.Errorf("diff between version 2 and current shows no changes: %+v", diff.Ops)
+	}
+}
@@ -0,0 +1,502 @@
+package novel
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 导出格式
+const (
+	ExportEPUB     = "epub"
+	ExportMarkdown = "markdown"
+	ExportTXT      = "txt"
+)
+
+// ExportDir 默认导出目录
+const ExportDir = "export"
+
+// 网文平台要求的段首缩进（两个全角空格）
+const fullWidthIndent = "　　"
+
+var chapterHeadingRe = regexp.MustCompile(DefaultChapterPattern)
+
+// ExportOptions 导出选项
+type ExportOptions struct {
+	Format     string // epub, markdown, txt
+	OutputPath string // 留空则写入 export/<书名>.<扩展名>
+	CoverImage string // EPUB封面图片路径（可选）
+	CompactTXT bool   // TXT段落之间不留空行
+}
+
+// ExportResult 导出结果
+type ExportResult struct {
+	Path      string
+	Chapters  int
+	WordCount int
+	Missing   []int // 没有正文文件的章节
+}
+
+// exportBook 导出用的书籍快照
+type exportBook struct {
+	Title    string
+	Author   string
+	Genre    string
+	Tags     []string
+	Volumes  []*Volume
+	Chapters []exportChapter
+}
+
+type exportChapter struct {
+	Number     int
+	Volume     int
+	Heading    string
+	Paragraphs []string
+}
+
+// Export 将项目导出为指定格式
+func (nm *NovelManager) Export(opts ExportOptions) (*ExportResult, error) {
+	format := normalizeExportFormat(opts.Format)
+	if format == "" {
+		return nil, fmt.Errorf("unsupported export format: %s (use epub, markdown or txt)", opts.Format)
+	}
+
+	book, result, err := nm.collectExportBook()
+	if err != nil {
+		return nil, err
+	}
+
+	outputPath := opts.OutputPath
+	if outputPath == "" {
+		ext := map[string]string{ExportEPUB: ".epub", ExportMarkdown: ".md", ExportTXT: ".txt"}[format]
+		outputPath = filepath.Join(nm.projectPath, ExportDir, sanitizeFileName(book.Title)+ext)
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	var data []byte
+	switch format {
+	case ExportEPUB:
+		data, err = renderEPUB(book, opts.CoverImage)
+	case ExportMarkdown:
+		data = []byte(renderMarkdown(book))
+	case ExportTXT:
+		data = []byte(renderTXT(book, !opts.CompactTXT))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(outputPath, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write export file: %w", err)
+	}
+
+	result.Path = outputPath
+	return result, nil
+}
+
+// collectExportBook 读取项目元数据与全部章节正文
+func (nm *NovelManager) collectExportBook() (*exportBook, *ExportResult, error) {
+	nm.mutex.RLock()
+	if nm.novelData == nil {
+		nm.mutex.RUnlock()
+		return nil, nil, fmt.Errorf("novel project not initialized")
+	}
+	book := &exportBook{
+		Title:   nm.novelData.Title,
+		Author:  nm.novelData.Author,
+		Genre:   nm.novelData.Genre,
+		Tags:    append([]string(nil), nm.novelData.Tags...),
+		Volumes: append([]*Volume(nil), nm.novelData.Volumes...),
+	}
+	chapters := make([]Chapter, 0, len(nm.novelData.Chapters))
+	for _, chapter := range nm.novelData.Chapters {
+		chapters = append(chapters, *chapter)
+	}
+	nm.mutex.RUnlock()
+
+	result := &ExportResult{Missing: make([]int, 0)}
+	for _, chapter := range chapters {
+		text, err := nm.ReadChapterText(chapter.Number)
+		if err != nil {
+			result.Missing = append(result.Missing, chapter.Number)
+			continue
+		}
+		book.Chapters = append(book.Chapters, exportChapter{
+			Number:     chapter.Number,
+			Volume:     chapter.Volume,
+			Heading:    exportHeading(&chapter),
+			Paragraphs: splitParagraphs(text),
+		})
+		result.Chapters++
+		result.WordCount += CountWords(text)
+	}
+
+	if result.Chapters == 0 {
+		return nil, nil, fmt.Errorf("no chapter files found in %s/", ChaptersDir)
+	}
+	return book, result, nil
+}
+
+// renderMarkdown 生成单文件Markdown
+func renderMarkdown(book *exportBook) string {
+	var md strings.Builder
+	md.WriteString(fmt.Sprintf("# %s\n\n", book.Title))
+	md.WriteString(fmt.Sprintf("**作者**: %s  \n", book.Author))
+	if book.Genre != "" {
+		md.WriteString(fmt.Sprintf("**类型**: %s  \n", book.Genre))
+	}
+	if len(book.Tags) > 0 {
+		md.WriteString(fmt.Sprintf("**标签**: %s  \n", strings.Join(book.Tags, "、")))
+	}
+	md.WriteString("\n")
+
+	chapterLevel := "##"
+	if len(book.Volumes) > 0 {
+		chapterLevel = "###"
+	}
+
+	volume := 0
+	for _, chapter := range book.Chapters {
+		if chapter.Volume != volume && chapter.Volume > 0 && chapter.Volume <= len(book.Volumes) {
+			volume = chapter.Volume
+			md.WriteString(fmt.Sprintf("## %s\n\n", book.Volumes[volume-1].Title))
+			for _, paragraph := range splitParagraphs(book.Volumes[volume-1].Intro) {
+				md.WriteString(paragraph)
+				md.WriteString("\n\n")
+			}
+		}
+		md.WriteString(fmt.Sprintf("%s %s\n\n", chapterLevel, chapter.Heading))
+		for _, paragraph := range chapter.Paragraphs {
+			md.WriteString(paragraph)
+			md.WriteString("\n\n")
+		}
+	}
+
+	return md.String()
+}
+
+// renderTXT 生成网文平台格式的TXT：段首两个全角空格，段间可选空行
+func renderTXT(book *exportBook, blankLines bool) string {
+	separator := "\n"
+	if blankLines {
+		separator = "\n\n"
+	}
+
+	var txt strings.Builder
+	txt.WriteString(book.Title)
+	txt.WriteString("\n")
+	txt.WriteString(fmt.Sprintf("作者：%s\n\n", book.Author))
+
+	volume := 0
+	for _, chapter := range book.Chapters {
+		if chapter.Volume != volume && chapter.Volume > 0 && chapter.Volume <= len(book.Volumes) {
+			volume = chapter.Volume
+			txt.WriteString(book.Volumes[volume-1].Title)
+			txt.WriteString("\n\n")
+			for _, paragraph := range splitParagraphs(book.Volumes[volume-1].Intro) {
+				txt.WriteString(fullWidthIndent)
+				txt.WriteString(paragraph)
+				txt.WriteString(separator)
+			}
+			if book.Volumes[volume-1].Intro != "" && !blankLines {
+				txt.WriteString("\n")
+			}
+		}
+		txt.WriteString(chapter.Heading)
+		txt.WriteString("\n\n")
+		for i, paragraph := range chapter.Paragraphs {
+			txt.WriteString(fullWidthIndent)
+			txt.WriteString(paragraph)
+			if i < len(chapter.Paragraphs)-1 {
+				txt.WriteString(separator)
+			}
+		}
+		txt.WriteString("\n\n\n")
+	}
+
+	return strings.TrimRight(txt.String(), "\n") + "\n"
+}
+
+// renderEPUB 生成EPUB 3电子书（包含nav目录与兼容旧阅读器的NCX）
+func renderEPUB(book *exportBook, coverPath string) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	// mimetype 必须是第一个且不压缩的条目
+	mimetype, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create epub: %w", err)
+	}
+	mimetype.Write([]byte("application/epub+zip"))
+
+	files := map[string]string{
+		"META-INF/container.xml": epubContainer,
+		"OEBPS/style.css":        epubStyle,
+	}
+
+	var coverData []byte
+	coverFile, coverType := "", ""
+	if coverPath != "" {
+		coverData, err = os.ReadFile(coverPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cover image: %w", err)
+		}
+		coverType = imageMediaType(coverPath)
+		if coverType == "" {
+			return nil, fmt.Errorf("unsupported cover image type: %s", filepath.Ext(coverPath))
+		}
+		coverFile = "images/cover" + strings.ToLower(filepath.Ext(coverPath))
+		files["OEBPS/cover.xhtml"] = xhtmlPage("封面", "style.css", fmt.Sprintf(`<div class="cover"><img src="%s" alt="%s"/></div>`, coverFile, xmlEscape(book.Title)))
+	}
+
+	var manifest, spine, nav, ncx strings.Builder
+	if coverFile != "" {
+		manifest.WriteString(fmt.Sprintf(`    <item id="cover-image" href="%s" media-type="%s" properties="cover-image"/>`+"\n", coverFile, coverType))
+		manifest.WriteString(`    <item id="cover" href="cover.xhtml" media-type="application/xhtml+xml"/>` + "\n")
+		spine.WriteString(`    <itemref idref="cover"/>` + "\n")
+	}
+
+	volume := 0
+	inVolume := false
+	for i, chapter := range book.Chapters {
+		id := fmt.Sprintf("chapter_%03d", chapter.Number)
+		href := fmt.Sprintf("text/%s.xhtml", id)
+
+		var body strings.Builder
+		body.WriteString(fmt.Sprintf("<h2>%s</h2>\n", xmlEscape(chapter.Heading)))
+		for _, paragraph := range chapter.Paragraphs {
+			body.WriteString(fmt.Sprintf("<p>%s</p>\n", xmlEscape(paragraph)))
+		}
+		files["OEBPS/"+href] = xhtmlPage(chapter.Heading, "../style.css", body.String())
+
+		manifest.WriteString(fmt.Sprintf(`    <item id="%s" href="%s" media-type="application/xhtml+xml"/>`+"\n", id, href))
+		spine.WriteString(fmt.Sprintf(`    <itemref idref="%s"/>`+"\n", id))
+
+		// 不属于任何分卷的章节（如卷外的尾声）回到目录顶层
+		chapterVolume := chapter.Volume
+		if chapterVolume < 0 || chapterVolume > len(book.Volumes) {
+			chapterVolume = 0
+		}
+		if chapterVolume != volume {
+			if inVolume {
+				nav.WriteString("      </ol></li>\n")
+			}
+			volume = chapterVolume
+			inVolume = volume > 0
+			if inVolume {
+				nav.WriteString(fmt.Sprintf("      <li><a href=\"%s\">%s</a><ol>\n", href, xmlEscape(book.Volumes[volume-1].Title)))
+			}
+		}
+		nav.WriteString(fmt.Sprintf("      <li><a href=\"%s\">%s</a></li>\n", href, xmlEscape(chapter.Heading)))
+		ncx.WriteString(fmt.Sprintf("    <navPoint id=\"nav_%d\" playOrder=\"%d\"><navLabel><text>%s</text></navLabel><content src=\"%s\"/></navPoint>\n",
+			i+1, i+1, xmlEscape(chapter.Heading), href))
+	}
+	if inVolume {
+		nav.WriteString("      </ol></li>\n")
+	}
+
+	bookID := "urn:uuid:" + uuid.New().String()
+
+	var metadata strings.Builder
+	metadata.WriteString(fmt.Sprintf("    <dc:identifier id=\"book-id\">%s</dc:identifier>\n", bookID))
+	metadata.WriteString(fmt.Sprintf("    <dc:title>%s</dc:title>\n", xmlEscape(book.Title)))
+	metadata.WriteString(fmt.Sprintf("    <dc:creator>%s</dc:creator>\n", xmlEscape(book.Author)))
+	metadata.WriteString("    <dc:language>zh-CN</dc:language>\n")
+	if book.Genre != "" {
+		metadata.WriteString(fmt.Sprintf("    <dc:subject>%s</dc:subject>\n", xmlEscape(book.Genre)))
+	}
+	for _, tag := range book.Tags {
+		metadata.WriteString(fmt.Sprintf("    <dc:subject>%s</dc:subject>\n", xmlEscape(tag)))
+	}
+	metadata.WriteString(fmt.Sprintf("    <meta property=\"dcterms:modified\">%s</meta>\n", time.Now().UTC().Format("2006-01-02T15:04:05Z")))
+
+	files["OEBPS/content.opf"] = fmt.Sprintf(epubPackage, metadata.String(), manifest.String(), spine.String())
+	files["OEBPS/nav.xhtml"] = fmt.Sprintf(epubNav, xmlEscape(book.Title), nav.String())
+	files["OEBPS/toc.ncx"] = fmt.Sprintf(epubNCX, bookID, xmlEscape(book.Title), ncx.String())
+
+	names := []string{"META-INF/container.xml", "OEBPS/content.opf", "OEBPS/nav.xhtml", "OEBPS/toc.ncx", "OEBPS/style.css"}
+	if coverFile != "" {
+		names = append(names, "OEBPS/cover.xhtml")
+	}
+	for _, chapter := range book.Chapters {
+		names = append(names, fmt.Sprintf("OEBPS/text/chapter_%03d.xhtml", chapter.Number))
+	}
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		if _, err := w.Write([]byte(files[name])); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	if coverFile != "" {
+		w, err := zw.Create("OEBPS/" + coverFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write cover image: %w", err)
+		}
+		if _, err := w.Write(coverData); err != nil {
+			return nil, fmt.Errorf("failed to write cover image: %w", err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize epub: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+const epubContainer = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+const epubPackage = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="zh-CN">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+%s  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    <item id="style" href="style.css" media-type="text/css"/>
+%s  </manifest>
+  <spine toc="ncx">
+%s  </spine>
+</package>
+`
+
+const epubNav = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="zh-CN">
+<head><title>%s</title></head>
+<body>
+  <nav epub:type="toc" id="toc">
+    <h1>目录</h1>
+    <ol>
+%s    </ol>
+  </nav>
+</body>
+</html>
+`
+
+const epubNCX = `<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+  <head><meta name="dtb:uid" content="%s"/></head>
+  <docTitle><text>%s</text></docTitle>
+  <navMap>
+%s  </navMap>
+</ncx>
+`
+
+const epubStyle = `body { font-family: serif; line-height: 1.8; }
+h2 { text-align: center; margin: 1em 0 1.5em; }
+p { text-indent: 2em; margin: 0 0 0.6em; }
+.cover { text-align: center; }
+.cover img { max-width: 100%; max-height: 100%; }
+`
+
+// xhtmlPage 生成EPUB内容页，cssHref 为相对当前页面的样式表路径
+func xhtmlPage(title, cssHref, body string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="zh-CN">
+<head>
+  <title>%s</title>
+  <link rel="stylesheet" type="text/css" href="%s"/>
+</head>
+<body>
+%s</body>
+</html>
+`, xmlEscape(title), cssHref, body)
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+func imageMediaType(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".png":
+		return "image/png"
+	case ".gif":
+		return "image/gif"
+	case ".webp":
+		return "image/webp"
+	}
+	return ""
+}
+
+// exportHeading 导入的章节沿用原文的标题行（如“楔子 风起”、分卷内重新编号的“第一章 回乡”）；
+// 在工具中新建或导入后改过章节名的按章节号生成标题
+func exportHeading(chapter *Chapter) string {
+	if chapter.Heading != "" && strings.HasSuffix(chapter.Heading, chapter.Title) {
+		return chapter.Heading
+	}
+	return chapterHeading(chapter.Number, chapter.Title)
+}
+
+// chapterHeading 生成章节标题，标题本身已含“第X章”时保持原样
+func chapterHeading(number int, title string) string {
+	if title != "" && chapterHeadingRe.MatchString(title) {
+		return title
+	}
+	if title == "" {
+		return fmt.Sprintf("第%d章", number)
+	}
+	return fmt.Sprintf("第%d章 %s", number, title)
+}
+
+// splitParagraphs 按行拆分段落，去掉原有缩进与空行
+func splitParagraphs(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	paragraphs := make([]string, 0)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.Trim(line, " \t　")
+		if line != "" {
+			paragraphs = append(paragraphs, line)
+		}
+	}
+	return paragraphs
+}
+
+func normalizeExportFormat(format string) string {
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case "epub":
+		return ExportEPUB
+	case "md", "markdown":
+		return ExportMarkdown
+	case "txt", "text":
+		return ExportTXT
+	}
+	return ""
+}
+
+func sanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`\/:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" {
+		return "novel"
+	}
+	return name
+}
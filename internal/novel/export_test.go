@@ -0,0 +1,182 @@
+package novel
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// navDepths 解析 nav.xhtml，返回每个目录项标题及其所在 <ol> 的嵌套层数
+func navDepths(t *testing.T, data []byte) map[string]int {
+	t.Helper()
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := reader.Open("OEBPS/nav.xhtml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	depths := make(map[string]int)
+	decoder := xml.NewDecoder(file)
+	decoder.Strict = false
+	depth, inLink := 0, false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("nav.xhtml is not well-formed: %v", err)
+		}
+		switch tok := token.(type) {
+		case xml.StartElement:
+			switch tok.Name.Local {
+			case "ol":
+				depth++
+			case "a":
+				inLink = true
+			}
+		case xml.EndElement:
+			switch tok.Name.Local {
+			case "ol":
+				depth--
+			case "a":
+				inLink = false
+			}
+		case xml.CharData:
+			if text := strings.TrimSpace(string(tok)); inLink && text != "" {
+				depths[text] = depth
+			}
+		}
+	}
+	if depth != 0 {
+		t.Errorf("unbalanced <ol> in nav.xhtml, depth = %d", depth)
+	}
+	return depths
+}
+
+func TestRenderEPUBNavVolumes(t *testing.T) {
+	volumes := []*Volume{{Number: 1, Title: "第一卷 初入江湖"}, {Number: 2, Title: "第二卷 风起云涌"}}
+	chapter := func(number, volume int, heading string) exportChapter {
+		return exportChapter{Number: number, Volume: volume, Heading: heading, Paragraphs: []string{"正文"}}
+	}
+
+	tests := []struct {
+		name     string
+		chapters []exportChapter
+		want     map[string]int
+	}{
+		{
+			name:     "没有分卷",
+			chapters: []exportChapter{chapter(1, 0, "第1章"), chapter(2, 0, "第2章")},
+			want:     map[string]int{"第1章": 1, "第2章": 1},
+		},
+		{
+			name:     "全部在卷内",
+			chapters: []exportChapter{chapter(1, 1, "第1章"), chapter(2, 2, "第2章")},
+			want:     map[string]int{"第一卷 初入江湖": 1, "第1章": 2, "第二卷 风起云涌": 1, "第2章": 2},
+		},
+		{
+			name:     "卷后的尾声回到顶层",
+			chapters: []exportChapter{chapter(1, 1, "第1章"), chapter(2, 1, "第2章"), chapter(3, 0, "尾声")},
+			want:     map[string]int{"第一卷 初入江湖": 1, "第1章": 2, "第2章": 2, "尾声": 1},
+		},
+		{
+			name:     "卷外章节夹在两卷之间",
+			chapters: []exportChapter{chapter(1, 0, "楔子"), chapter(2, 1, "第1章"), chapter(3, 0, "番外"), chapter(4, 2, "第2章")},
+			want:     map[string]int{"楔子": 1, "第一卷 初入江湖": 1, "第1章": 2, "番外": 1, "第二卷 风起云涌": 1, "第2章": 2},
+		},
+		{
+			name:     "无效卷号按卷外处理",
+			chapters: []exportChapter{chapter(1, 1, "第1章"), chapter(2, 9, "第2章")},
+			want:     map[string]int{"第一卷 初入江湖": 1, "第1章": 2, "第2章": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := &exportBook{Title: "测试小说", Author: "测试作者", Volumes: volumes, Chapters: tt.chapters}
+			data, err := renderEPUB(book, "")
+			if err != nil {
+				t.Fatalf("renderEPUB: %v", err)
+			}
+			if got := navDepths(t, data); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("nav depths = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportHeading(t *testing.T) {
+	tests := []struct {
+		name    string
+		chapter Chapter
+		want    string
+	}{
+		{"导入的楔子保持原样", Chapter{Number: 1, Title: "风起", Heading: "楔子 风起"}, "楔子 风起"},
+		{"分卷内重新编号的章节", Chapter{Number: 3, Title: "回乡", Heading: "第一章 回乡"}, "第一章 回乡"},
+		{"没有章名的标题", Chapter{Number: 2, Title: "尾声", Heading: "尾声"}, "尾声"},
+		{"工具中新建的章节", Chapter{Number: 5, Title: "夜雨"}, "第5章 夜雨"},
+		{"导入后改过章节名", Chapter{Number: 3, Title: "归来", Heading: "第一章 回乡"}, "第3章 归来"},
+	}
+	for _, tt := range tests {
+		if got := exportHeading(&tt.chapter); got != tt.want {
+			t.Errorf("%s: exportHeading() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestImportExportRoundTrip(t *testing.T) {
+	body := "他沿着山路走了很久。\n夜色渐深，远处传来钟声。"
+	manuscript := "楔子 风起\n" + body + "\n第一章 出山\n" + body + "\n第二卷 归途\n本卷讲少年回乡。\n第一章 回乡\n" + body
+
+	nm := newTestManager(t)
+	original, err := ParseManuscript(manuscript, ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := nm.ApplyImport(original, false); err != nil {
+		t.Fatalf("ApplyImport: %v", err)
+	}
+
+	for _, compact := range []bool{false, true} {
+		output := filepath.Join(t.TempDir(), "book.txt")
+		if _, err := nm.Export(ExportOptions{Format: ExportTXT, OutputPath: output, CompactTXT: compact}); err != nil {
+			t.Fatalf("Export: %v", err)
+		}
+		data, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, err := ParseManuscript(string(data), ImportOptions{})
+		if err != nil {
+			t.Fatalf("re-import (compact=%v): %v", compact, err)
+		}
+
+		if len(again.Chapters) != len(original.Chapters) {
+			t.Fatalf("compact=%v: got %d chapters, want %d", compact, len(again.Chapters), len(original.Chapters))
+		}
+		for i, chapter := range again.Chapters {
+			want := original.Chapters[i]
+			if chapter.Heading != want.Heading || chapter.Volume != want.Volume {
+				t.Errorf("compact=%v chapter %d = %q (volume %d), want %q (volume %d)",
+					compact, i+1, chapter.Heading, chapter.Volume, want.Heading, want.Volume)
+			}
+			if got := splitParagraphs(chapter.Content); !reflect.DeepEqual(got, splitParagraphs(want.Content)) {
+				t.Errorf("compact=%v chapter %d paragraphs = %q", compact, i+1, got)
+			}
+		}
+		if len(again.Volumes) != 1 || again.Volumes[0].Title != "第二卷 归途" || strings.TrimSpace(again.Volumes[0].Intro) != "本卷讲少年回乡。" {
+			t.Errorf("compact=%v volumes = %+v", compact, again.Volumes)
+		}
+	}
+}
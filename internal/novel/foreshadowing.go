@@ -0,0 +1,374 @@
+package novel
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 伏笔状态
+const (
+	ForeshadowPlanted   = "planted"
+	ForeshadowHinted    = "hinted"
+	ForeshadowPaid      = "paid"
+	ForeshadowAbandoned = "abandoned"
+)
+
+// 距离计划回收章节还有多少章时开始在章节上下文中提醒
+const foreshadowRemindAhead = 3
+
+// Foreshadowing 伏笔
+type Foreshadowing struct {
+	ID             string   `json:"id"`
+	Description    string   `json:"description"`
+	Status         string   `json:"status"` // planted, hinted, paid, abandoned
+	PlantedChapter int      `json:"planted_chapter"`
+	PayoffFrom     int      `json:"payoff_from,omitempty"` // 计划回收的章节范围
+	PayoffTo       int      `json:"payoff_to,omitempty"`
+	HintChapters   []int    `json:"hint_chapters,omitempty"` // 再次暗示的章节
+	PaidChapter    int      `json:"paid_chapter,omitempty"`  // 实际回收的章节
+	Characters     []string `json:"characters,omitempty"`
+	Items          []string `json:"items,omitempty"`
+	Note           string   `json:"note,omitempty"`
+}
+
+// ForeshadowingEntry 带所属情节线的伏笔
+type ForeshadowingEntry struct {
+	PlotLine string
+	*Foreshadowing
+}
+
+// UnmarshalJSON 兼容旧版本以字符串保存的伏笔
+func (f *Foreshadowing) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*f = Foreshadowing{Description: text, Status: ForeshadowPlanted}
+		return nil
+	}
+
+	type plain Foreshadowing
+	var decoded plain
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*f = Foreshadowing(decoded)
+	return nil
+}
+
+// Open 伏笔是否尚未回收或放弃
+func (f *Foreshadowing) Open() bool {
+	return f.Status != ForeshadowPaid && f.Status != ForeshadowAbandoned
+}
+
+// Overdue 截至 chapterNum 是否已超过计划回收窗口
+func (f *Foreshadowing) Overdue(chapterNum int) bool {
+	return f.Open() && f.PayoffTo > 0 && chapterNum > f.PayoffTo
+}
+
+// DueSoon 截至 chapterNum 是否已进入或临近回收窗口
+func (f *Foreshadowing) DueSoon(chapterNum int) bool {
+	if !f.Open() || f.Overdue(chapterNum) {
+		return false
+	}
+	start := f.PayoffFrom
+	if start <= 0 {
+		start = f.PayoffTo
+	}
+	return start > 0 && chapterNum >= start-foreshadowRemindAhead
+}
+
+// PlantForeshadowing 在情节线上埋下伏笔，返回伏笔ID
+func (nm *NovelManager) PlantForeshadowing(plotLine string, item *Foreshadowing) (string, error) {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	if nm.novelData == nil {
+		return "", fmt.Errorf("novel project not initialized")
+	}
+	plot, ok := nm.novelData.PlotLines[plotLine]
+	if !ok {
+		return "", fmt.Errorf("plot line not found: %s (add it with add_plot_line first)", plotLine)
+	}
+	item.Description = strings.TrimSpace(item.Description)
+	if item.Description == "" {
+		return "", fmt.Errorf("foreshadowing description is required")
+	}
+	if item.PayoffFrom > 0 && item.PayoffTo > 0 && item.PayoffFrom > item.PayoffTo {
+		return "", fmt.Errorf("invalid payoff window: %d-%d", item.PayoffFrom, item.PayoffTo)
+	}
+	if item.PlantedChapter > 0 && item.PayoffTo > 0 && item.PayoffTo < item.PlantedChapter {
+		return "", fmt.Errorf("payoff window ends before the planted chapter")
+	}
+
+	nm.assignForeshadowingIDs()
+	item.ID = fmt.Sprintf("fs_%d", nm.maxForeshadowingID()+1)
+	item.Status = ForeshadowPlanted
+	plot.Foreshadowing = append(plot.Foreshadowing, item)
+
+	return item.ID, nm.SaveProject()
+}
+
+// UpdateForeshadowing 更新伏笔状态：hinted 记录暗示章节，paid 记录回收章节，abandoned 放弃
+func (nm *NovelManager) UpdateForeshadowing(id, status string, chapterNum int, note string) (*ForeshadowingEntry, error) {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	if nm.novelData == nil {
+		return nil, fmt.Errorf("novel project not initialized")
+	}
+	nm.assignForeshadowingIDs()
+	entry := nm.findForeshadowing(id)
+	if entry == nil {
+		return nil, fmt.Errorf("foreshadowing not found: %s", id)
+	}
+
+	switch status {
+	case ForeshadowHinted:
+		if chapterNum > 0 && !containsInt(entry.HintChapters, chapterNum) {
+			entry.HintChapters = append(entry.HintChapters, chapterNum)
+			sort.Ints(entry.HintChapters)
+		}
+		if entry.Status == ForeshadowPlanted {
+			entry.Status = ForeshadowHinted
+		}
+	case ForeshadowPaid:
+		entry.Status = ForeshadowPaid
+		entry.PaidChapter = chapterNum
+	case ForeshadowAbandoned, ForeshadowPlanted:
+		entry.Status = status
+	default:
+		return nil, fmt.Errorf("unknown foreshadowing status: %s", status)
+	}
+	if note != "" {
+		entry.Note = note
+	}
+
+	copied := *entry.Foreshadowing
+	return &ForeshadowingEntry{PlotLine: entry.PlotLine, Foreshadowing: &copied}, nm.SaveProject()
+}
+
+// ListForeshadowing 列出伏笔，按埋下章节排序；status 为空表示全部
+func (nm *NovelManager) ListForeshadowing(status string) []*ForeshadowingEntry {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	entries := make([]*ForeshadowingEntry, 0)
+	for _, entry := range nm.foreshadowingEntries() {
+		if status == "" || entry.Status == status {
+			copied := *entry.Foreshadowing
+			entries = append(entries, &ForeshadowingEntry{PlotLine: entry.PlotLine, Foreshadowing: &copied})
+		}
+	}
+	return entries
+}
+
+// CurrentChapterNumber 当前写作进度所在章节：优先使用 CurrentChapter，否则取最大章节号
+func (nm *NovelManager) CurrentChapterNumber() int {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	return nm.currentChapterNumber()
+}
+
+// FormatForeshadowing 格式化伏笔列表
+func FormatForeshadowing(entries []*ForeshadowingEntry, chapterNum int) string {
+	if len(entries) == 0 {
+		return "（没有符合条件的伏笔）\n"
+	}
+
+	var result strings.Builder
+	for _, entry := range entries {
+		icon := "🌱"
+		switch {
+		case entry.Status == ForeshadowPaid:
+			icon = "✅"
+		case entry.Status == ForeshadowAbandoned:
+			icon = "🗑️"
+		case entry.Overdue(chapterNum):
+			icon = "⏰"
+		case entry.Status == ForeshadowHinted:
+			icon = "💡"
+		}
+		result.WriteString(fmt.Sprintf("%s [%s] %s（%s）\n", icon, entry.ID, entry.Description, foreshadowStatusLabel(entry.Status)))
+		result.WriteString(fmt.Sprintf("    情节线: %s | %s", entry.PlotLine, plantedLabel(entry.Foreshadowing)))
+		if window := payoffWindow(entry.Foreshadowing); window != "" {
+			result.WriteString(fmt.Sprintf(" | 计划回收: %s", window))
+		}
+		if entry.PaidChapter > 0 {
+			result.WriteString(fmt.Sprintf(" | 回收于第%d章", entry.PaidChapter))
+		}
+		if entry.Overdue(chapterNum) {
+			result.WriteString(fmt.Sprintf(" | 已超期 %d 章", chapterNum-entry.PayoffTo))
+		}
+		result.WriteString("\n")
+		if len(entry.HintChapters) > 0 {
+			result.WriteString(fmt.Sprintf("    暗示章节: %s\n", joinInts(entry.HintChapters, ", ")))
+		}
+		if links := append(append([]string{}, entry.Characters...), entry.Items...); len(links) > 0 {
+			result.WriteString(fmt.Sprintf("    关联: %s\n", strings.Join(links, "、")))
+		}
+		if entry.Note != "" {
+			result.WriteString(fmt.Sprintf("    备注: %s\n", entry.Note))
+		}
+	}
+	return result.String()
+}
+
+// foreshadowingReminders 章节上下文中的伏笔提醒，调用方需持有读锁
+func (nm *NovelManager) foreshadowingReminders(chapterNum int) string {
+	var overdue, due strings.Builder
+	for _, entry := range nm.foreshadowingEntries() {
+		line := fmt.Sprintf("• [%s] %s（%s，%s", entry.ID, entry.Description, entry.PlotLine, plantedLabel(entry.Foreshadowing))
+		if window := payoffWindow(entry.Foreshadowing); window != "" {
+			line += "，计划" + window + "回收"
+		}
+		line += "）\n"
+		switch {
+		case entry.Overdue(chapterNum):
+			overdue.WriteString(line)
+		case entry.DueSoon(chapterNum):
+			due.WriteString(line)
+		}
+	}
+
+	var result strings.Builder
+	if overdue.Len() > 0 {
+		result.WriteString("⏰ 已超期未回收:\n")
+		result.WriteString(overdue.String())
+	}
+	if due.Len() > 0 {
+		result.WriteString("🔔 临近回收窗口:\n")
+		result.WriteString(due.String())
+	}
+	return result.String()
+}
+
+// foreshadowingEntries 按埋下章节排序的全部伏笔，调用方需持有锁
+func (nm *NovelManager) foreshadowingEntries() []*ForeshadowingEntry {
+	entries := make([]*ForeshadowingEntry, 0)
+	if nm.novelData == nil {
+		return entries
+	}
+	for name, plot := range nm.novelData.PlotLines {
+		for _, item := range plot.Foreshadowing {
+			entries = append(entries, &ForeshadowingEntry{PlotLine: name, Foreshadowing: item})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].PlantedChapter != entries[j].PlantedChapter {
+			return entries[i].PlantedChapter < entries[j].PlantedChapter
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	return entries
+}
+
+func (nm *NovelManager) findForeshadowing(id string) *ForeshadowingEntry {
+	for _, entry := range nm.foreshadowingEntries() {
+		if entry.ID == id {
+			return entry
+		}
+	}
+	return nil
+}
+
+// assignForeshadowingIDs 为旧版本迁移来的伏笔补齐ID，调用方需持有写锁
+func (nm *NovelManager) assignForeshadowingIDs() {
+	next := nm.maxForeshadowingID() + 1
+	names := make([]string, 0, len(nm.novelData.PlotLines))
+	for name := range nm.novelData.PlotLines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, item := range nm.novelData.PlotLines[name].Foreshadowing {
+			if item.ID == "" {
+				item.ID = fmt.Sprintf("fs_%d", next)
+				next++
+			}
+			if item.Status == "" {
+				item.Status = ForeshadowPlanted
+			}
+		}
+	}
+}
+
+func (nm *NovelManager) maxForeshadowingID() int {
+	maxID := 0
+	for _, plot := range nm.novelData.PlotLines {
+		for _, item := range plot.Foreshadowing {
+			var n int
+			if _, err := fmt.Sscanf(item.ID, "fs_%d", &n); err == nil && n > maxID {
+				maxID = n
+			}
+		}
+	}
+	return maxID
+}
+
+// currentChapterNumber 调用方需持有锁
+func (nm *NovelManager) currentChapterNumber() int {
+	if nm.novelData == nil {
+		return 0
+	}
+	return projectCurrentChapter(nm.novelData)
+}
+
+// projectCurrentChapter 优先使用 CurrentChapter，否则取最大章节号
+func projectCurrentChapter(project *NovelProject) int {
+	if project.CurrentChapter > 0 {
+		return project.CurrentChapter
+	}
+	current := 0
+	for _, chapter := range project.Chapters {
+		if chapter.Number > current {
+			current = chapter.Number
+		}
+	}
+	return current
+}
+
+func payoffWindow(item *Foreshadowing) string {
+	switch {
+	case item.PayoffFrom > 0 && item.PayoffFrom == item.PayoffTo:
+		return fmt.Sprintf("第%d章", item.PayoffTo)
+	case item.PayoffFrom > 0 && item.PayoffTo > 0:
+		return fmt.Sprintf("第%d-%d章", item.PayoffFrom, item.PayoffTo)
+	case item.PayoffTo > 0:
+		return fmt.Sprintf("第%d章前", item.PayoffTo)
+	case item.PayoffFrom > 0:
+		return fmt.Sprintf("第%d章后", item.PayoffFrom)
+	}
+	return ""
+}
+
+func plantedLabel(item *Foreshadowing) string {
+	if item.PlantedChapter > 0 {
+		return fmt.Sprintf("埋于第%d章", item.PlantedChapter)
+	}
+	return "埋下章节未记录"
+}
+
+func foreshadowStatusLabel(status string) string {
+	switch status {
+	case ForeshadowPlanted:
+		return "已埋下"
+	case ForeshadowHinted:
+		return "已暗示"
+	case ForeshadowPaid:
+		return "已回收"
+	case ForeshadowAbandoned:
+		return "已放弃"
+	}
+	return status
+}
+
+func containsInt(values []int, target int) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package novel
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestForeshadowingWindow(t *testing.T) {
+	tests := []struct {
+		name        string
+		item        Foreshadowing
+		chapter     int
+		wantOverdue bool
+		wantDueSoon bool
+	}{
+		{name: "未设窗口", item: Foreshadowing{Status: ForeshadowPlanted}, chapter: 100},
+		{name: "距窗口尚远", item: Foreshadowing{Status: ForeshadowPlanted, PayoffFrom: 10, PayoffTo: 12}, chapter: 6},
+		{name: "临近窗口", item: Foreshadowing{Status: ForeshadowPlanted, PayoffFrom: 10, PayoffTo: 12}, chapter: 7, wantDueSoon: true},
+		{name: "窗口内", item: Foreshadowing{Status: ForeshadowHinted, PayoffFrom: 10, PayoffTo: 12}, chapter: 12, wantDueSoon: true},
+		{name: "超过窗口", item: Foreshadowing{Status: ForeshadowHinted, PayoffFrom: 10, PayoffTo: 12}, chapter: 13, wantOverdue: true},
+		{name: "只设截止章节", item: Foreshadowing{Status: ForeshadowPlanted, PayoffTo: 12}, chapter: 9, wantDueSoon: true},
+		{name: "已回收不再提醒", item: Foreshadowing{Status: ForeshadowPaid, PayoffTo: 12}, chapter: 20},
+		{name: "已放弃不再提醒", item: Foreshadowing{Status: ForeshadowAbandoned, PayoffFrom: 10}, chapter: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.item.Overdue(tt.chapter); got != tt.wantOverdue {
+				t.Errorf("Overdue(%d) = %v, want %v", tt.chapter, got, tt.wantOverdue)
+			}
+			if got := tt.item.DueSoon(tt.chapter); got != tt.wantDueSoon {
+				t.Errorf("DueSoon(%d) = %v, want %v", tt.chapter, got, tt.wantDueSoon)
+			}
+		})
+	}
+}
+
+func TestForeshadowingUnmarshalLegacy(t *testing.T) {
+	var items []*Foreshadowing
+	data := `["玉佩上的裂纹", {"id": "fs_2", "description": "神秘老人", "status": "hinted", "planted_chapter": 3}]`
+	if err := json.Unmarshal([]byte(data), &items); err != nil {
+		t.Fatal(err)
+	}
+	if items[0].Description != "玉佩上的裂纹" || items[0].Status != ForeshadowPlanted || items[0].ID != "" {
+		t.Errorf("legacy item = %+v", items[0])
+	}
+	if items[1].ID != "fs_2" || items[1].Status != ForeshadowHinted || items[1].PlantedChapter != 3 {
+		t.Errorf("structured item = %+v", items[1])
+	}
+}
+
+func TestPlantAndUpdateForeshadowing(t *testing.T) {
+	nm := newTestManager(t)
+	if _, err := nm.PlantForeshadowing("身世之谜", &Foreshadowing{Description: "玉佩"}); err == nil {
+		t.Error("planted on a missing plot line")
+	}
+	if _, err := nm.AddPlotLine(&PlotLine{Name: "身世之谜", Type: "mystery"}); err != nil {
+		t.Fatal(err)
+	}
+	// 旧版本迁移来的伏笔没有ID，下次写入时补齐
+	nm.novelData.PlotLines["身世之谜"].Foreshadowing = append(nm.novelData.PlotLines["身世之谜"].Foreshadowing,
+		&Foreshadowing{Description: "旧伏笔"})
+
+	invalid := []*Foreshadowing{
+		{Description: "  "},
+		{Description: "窗口颠倒", PayoffFrom: 10, PayoffTo: 8},
+		{Description: "回收早于埋下", PlantedChapter: 5, PayoffTo: 3},
+	}
+	for _, item := range invalid {
+		if _, err := nm.PlantForeshadowing("身世之谜", item); err == nil {
+			t.Errorf("PlantForeshadowing(%q) accepted", item.Description)
+		}
+	}
+
+	id, err := nm.PlantForeshadowing("身世之谜", &Foreshadowing{Description: " 玉佩上的裂纹 ", PlantedChapter: 1, PayoffFrom: 8, PayoffTo: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "fs_2" {
+		t.Errorf("id = %q, want fs_2 after the migrated item", id)
+	}
+
+	for _, chapter := range []int{6, 3, 6} {
+		if _, err := nm.UpdateForeshadowing(id, ForeshadowHinted, chapter, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entry, err := nm.UpdateForeshadowing(id, ForeshadowPaid, 9, "在宗门大比上揭晓")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.PlotLine != "身世之谜" || entry.Status != ForeshadowPaid || entry.PaidChapter != 9 || entry.Note != "在宗门大比上揭晓" {
+		t.Errorf("paid entry = %+v", entry.Foreshadowing)
+	}
+	if !reflect.DeepEqual(entry.HintChapters, []int{3, 6}) {
+		t.Errorf("HintChapters = %v, want [3 6]", entry.HintChapters)
+	}
+	// 已回收的伏笔再次暗示只记录章节，不改变状态
+	if entry, err = nm.UpdateForeshadowing(id, ForeshadowHinted, 11, ""); err != nil || entry.Status != ForeshadowPaid {
+		t.Errorf("hint after payoff: status %q, err %v", entry.Status, err)
+	}
+	if _, err := nm.UpdateForeshadowing(id, "forgotten", 0, ""); err == nil {
+		t.Error("accepted an unknown status")
+	}
+	if _, err := nm.UpdateForeshadowing("fs_99", ForeshadowPaid, 1, ""); err == nil {
+		t.Error("updated a missing item")
+	}
+
+	reloaded := reopenTestManager(t, nm)
+	entries := reloaded.ListForeshadowing("")
+	if len(entries) != 2 || entries[0].ID != "fs_1" || entries[0].Status != ForeshadowPlanted {
+		t.Fatalf("reloaded entries = %v", entries)
+	}
+	if paid := reloaded.ListForeshadowing(ForeshadowPaid); len(paid) != 1 || paid[0].PaidChapter != 9 {
+		t.Errorf("paid entries = %v", paid)
+	}
+}
+
+func TestForeshadowingReminders(t *testing.T) {
+	nm := newTestManager(t)
+	if _, err := nm.AddPlotLine(&PlotLine{Name: "主线"}); err != nil {
+		t.Fatal(err)
+	}
+	items := []*Foreshadowing{
+		{Description: "玉佩上的裂纹", PlantedChapter: 1, PayoffTo: 5},
+		{Description: "神秘老人", PlantedChapter: 2, PayoffFrom: 9, PayoffTo: 12},
+		{Description: "远方的钟声", PlantedChapter: 3, PayoffFrom: 30},
+	}
+	for _, item := range items {
+		if _, err := nm.PlantForeshadowing("主线", item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reminders := nm.foreshadowingReminders(6)
+	overdue, due, found := strings.Cut(reminders, "🔔 临近回收窗口:")
+	if !found {
+		t.Fatalf("reminders lack the due section:\n%s", reminders)
+	}
+	if !strings.Contains(overdue, "⏰ 已超期未回收") || !strings.Contains(overdue, "[fs_1] 玉佩上的裂纹（主线，埋于第1章，计划第5章前回收）") {
+		t.Errorf("overdue section = %q", overdue)
+	}
+	if !strings.Contains(due, "[fs_2] 神秘老人（主线，埋于第2章，计划第9-12章回收）") || strings.Contains(reminders, "远方的钟声") {
+		t.Errorf("due section = %q", due)
+	}
+	if got := nm.foreshadowingReminders(1); got != "" {
+		t.Errorf("reminders before any window = %q", got)
+	}
+}
@@ -0,0 +1,314 @@
+package novel
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// GenreTemplateDir 用户自定义题材模板相对于配置目录的位置，其中的 *.yaml 与内置模板同名时覆盖内置模板
+const GenreTemplateDir = "templates/genres"
+
+// 题材模板生成的初始文件
+const (
+	WorldFileName       = "世界观.md"
+	ProtagonistFileName = "主角设定.md"
+	OutlineFileName     = "大纲.md"
+)
+
+// GenreTemplate 题材模板：初始化项目时预置的世界观分类、角色定位、情节线、术语与初始文件
+type GenreTemplate struct {
+	Name          string            `yaml:"name"`
+	Title         string            `yaml:"title"`
+	Description   string            `yaml:"description"`
+	Aliases       []string          `yaml:"aliases"` // 项目题材包含其中任一词时匹配本模板
+	TargetWords   int               `yaml:"target_words"`
+	Outline       string            `yaml:"outline"` // 默认套用的节拍模板
+	WorldSettings []GenreSetting    `yaml:"world_settings"`
+	Characters    []ArchetypeSlot   `yaml:"characters"`
+	PlotLines     []GenrePlotLine   `yaml:"plot_lines"`
+	Glossary      []GlossaryTerm    `yaml:"glossary"`
+	Files         map[string]string `yaml:"files,omitempty"` // 额外的初始文件，文件名 -> 内容，可覆盖默认生成的文件
+	Source        string            `yaml:"-"`
+}
+
+// GenreSetting 模板中的世界观设定
+type GenreSetting struct {
+	Category    string   `yaml:"category"`
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Rules       []string `yaml:"rules,omitempty"`
+}
+
+// GenrePlotLine 模板中的情节线
+type GenrePlotLine struct {
+	Name        string `yaml:"name"`
+	Type        string `yaml:"type"`
+	Description string `yaml:"description"`
+}
+
+// ArchetypeSlot 角色定位，如“主角”“宿敌”，添加角色时可指定其填补的定位
+type ArchetypeSlot struct {
+	Role        string   `json:"role" yaml:"role"`
+	Description string   `json:"description,omitempty" yaml:"description"`
+	Personality []string `json:"personality,omitempty" yaml:"personality,omitempty"`
+	Character   string   `json:"character,omitempty" yaml:"-"` // 填补该定位的角色
+}
+
+// LoadGenreTemplates 加载内置题材模板和 userDir 中的自定义模板，无法解析的文件跳过并在 warnings 中说明
+func LoadGenreTemplates(userDir string) ([]*GenreTemplate, []string) {
+	files, warnings := readTemplateFiles("templates/genres", userDir)
+	templates := make(map[string]*GenreTemplate)
+	for _, file := range files {
+		var template GenreTemplate
+		if err := yaml.Unmarshal(file.data, &template); err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: invalid yaml: %v", file.source, err))
+			continue
+		}
+		if strings.TrimSpace(template.Name) == "" {
+			template.Name = strings.TrimSuffix(file.name, filepath.Ext(file.name))
+		}
+		if template.Title == "" {
+			template.Title = template.Name
+		}
+		template.Source = file.source
+		templates[template.Name] = &template
+	}
+
+	result := make([]*GenreTemplate, 0, len(templates))
+	for _, template := range templates {
+		result = append(result, template)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, warnings
+}
+
+// FindGenreTemplate 按模板名、标题或别名查找；name 为项目题材时取别名出现在题材中的模板
+func FindGenreTemplate(templates []*GenreTemplate, name string) *GenreTemplate {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil
+	}
+	for _, template := range templates {
+		if template.Name == name || template.Title == name {
+			return template
+		}
+	}
+	for _, template := range templates {
+		for _, alias := range template.Aliases {
+			if alias != "" && strings.Contains(name, alias) {
+				return template
+			}
+		}
+	}
+	return nil
+}
+
+// InitializeFromTemplate 初始化项目并按题材模板预置设定；outline 不为空时同时生成全书大纲。
+// 初始文件写入项目目录，已存在的文件不覆盖，返回新建的文件名
+func (nm *NovelManager) InitializeFromTemplate(title, author, genre string, template *GenreTemplate, outline *BeatTemplate) ([]string, error) {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	if nm.loadErr != nil {
+		return nil, fmt.Errorf("existing project could not be loaded: %w", nm.loadErr)
+	}
+	project := newNovelProject(title, author, genre)
+	template.seed(project)
+	if outline != nil {
+		project.Outline = outline.Instantiate((project.TargetWords + defaultChapterWords - 1) / defaultChapterWords)
+	}
+	nm.novelData = project
+	if err := nm.SaveProject(); err != nil {
+		return nil, err
+	}
+
+	files := template.starterFiles(project)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	created := make([]string, 0, len(names))
+	for _, name := range names {
+		path := filepath.Join(nm.projectPath, name)
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return created, fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(path, []byte(files[name]), 0644); err != nil {
+			return created, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		created = append(created, name)
+	}
+	return created, nil
+}
+
+// seed 把模板中的设定写入新项目
+func (t *GenreTemplate) seed(project *NovelProject) {
+	if t.TargetWords > 0 {
+		project.TargetWords = t.TargetWords
+	}
+	for _, setting := range t.WorldSettings {
+		project.WorldSettings[setting.Name] = &WorldSetting{
+			Category:     setting.Category,
+			Name:         setting.Name,
+			Description:  setting.Description,
+			Rules:        append([]string{}, setting.Rules...),
+			RelatedItems: make([]string, 0),
+		}
+	}
+	for _, plot := range t.PlotLines {
+		project.PlotLines[plot.Name] = &PlotLine{
+			Name:          plot.Name,
+			Type:          plot.Type,
+			Status:        "active",
+			Description:   plot.Description,
+			StartChapter:  1,
+			KeyEvents:     make([]PlotEvent, 0),
+			Foreshadowing: make([]*Foreshadowing, 0),
+		}
+	}
+	for _, slot := range t.Characters {
+		copied := slot
+		copied.Character = ""
+		project.Archetypes = append(project.Archetypes, &copied)
+	}
+	if len(t.Glossary) > 0 {
+		project.Glossary = make(map[string]*GlossaryTerm)
+		for _, term := range t.Glossary {
+			copied := term
+			project.Glossary[term.Term] = &copied
+		}
+	}
+}
+
+// starterFiles 根据预置设定生成 世界观.md、主角设定.md、大纲.md，模板中的 files 可追加或覆盖
+func (t *GenreTemplate) starterFiles(project *NovelProject) map[string]string {
+	files := map[string]string{
+		WorldFileName:       t.worldFile(project),
+		ProtagonistFileName: t.protagonistFile(project),
+		OutlineFileName:     t.outlineFile(project),
+	}
+	replacer := strings.NewReplacer("{{title}}", project.Title, "{{author}}", project.Author, "{{genre}}", project.Genre)
+	for name, content := range t.Files {
+		name = filepath.Clean(name)
+		if filepath.IsAbs(name) || strings.HasPrefix(name, "..") {
+			continue
+		}
+		files[name] = replacer.Replace(content)
+	}
+	return files
+}
+
+func (t *GenreTemplate) worldFile(project *NovelProject) string {
+	var doc strings.Builder
+	doc.WriteString(fmt.Sprintf("# %s · 世界观设定\n\n", project.Title))
+	doc.WriteString(fmt.Sprintf("> 题材: %s。%s\n", project.Genre, t.Description))
+
+	categories := make([]string, 0)
+	byCategory := make(map[string][]GenreSetting)
+	for _, setting := range t.WorldSettings {
+		if _, ok := byCategory[setting.Category]; !ok {
+			categories = append(categories, setting.Category)
+		}
+		byCategory[setting.Category] = append(byCategory[setting.Category], setting)
+	}
+	for _, category := range categories {
+		doc.WriteString(fmt.Sprintf("\n## %s\n", category))
+		for _, setting := range byCategory[category] {
+			doc.WriteString(fmt.Sprintf("\n### %s\n\n%s\n", setting.Name, setting.Description))
+			for _, rule := range setting.Rules {
+				doc.WriteString(fmt.Sprintf("- 规则: %s\n", rule))
+			}
+		}
+	}
+
+	if len(t.Glossary) > 0 {
+		doc.WriteString("\n## 术语表\n\n| 术语 | 分类 | 说明 |\n|---|---|---|\n")
+		for _, term := range t.Glossary {
+			doc.WriteString(fmt.Sprintf("| %s | %s | %s |\n", term.Term, term.Category, term.Description))
+		}
+	}
+	return doc.String()
+}
+
+func (t *GenreTemplate) protagonistFile(project *NovelProject) string {
+	var doc strings.Builder
+	doc.WriteString(fmt.Sprintf("# %s · 主角与角色设定\n", project.Title))
+	for _, slot := range t.Characters {
+		doc.WriteString(fmt.Sprintf("\n## %s\n\n> %s\n\n", slot.Role, slot.Description))
+		doc.WriteString("- 姓名: \n- 年龄: \n- 外貌: \n")
+		if len(slot.Personality) > 0 {
+			doc.WriteString(fmt.Sprintf("- 性格: %s\n", strings.Join(slot.Personality, "、")))
+		} else {
+			doc.WriteString("- 性格: \n")
+		}
+		doc.WriteString("- 背景: \n- 目标与动机: \n")
+	}
+	return doc.String()
+}
+
+func (t *GenreTemplate) outlineFile(project *NovelProject) string {
+	var doc strings.Builder
+	doc.WriteString(fmt.Sprintf("# %s · 大纲\n\n", project.Title))
+	doc.WriteString(fmt.Sprintf("目标字数: %d\n", project.TargetWords))
+
+	if len(t.PlotLines) > 0 {
+		doc.WriteString("\n## 情节线\n\n")
+		for _, plot := range t.PlotLines {
+			doc.WriteString(fmt.Sprintf("- **%s**（%s）: %s\n", plot.Name, plot.Type, plot.Description))
+		}
+	}
+	if outline := project.Outline; outline != nil {
+		doc.WriteString(fmt.Sprintf("\n## 结构节拍（%s，共 %d 章）\n\n", outline.Title, outline.TotalChapters))
+		for _, beat := range outline.Beats {
+			doc.WriteString(fmt.Sprintf("- **%s**（%s）: %s\n", beat.Name, beat.Span(), beat.Description))
+		}
+	}
+	doc.WriteString("\n## 分卷与章节\n\n- 第一卷: \n  - 第1章: \n")
+	return doc.String()
+}
+
+// AssignArchetype 让角色填补题材模板预置的角色定位
+func (nm *NovelManager) AssignArchetype(role, character string) error {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	if nm.novelData == nil {
+		return fmt.Errorf("novel project not initialized")
+	}
+	if _, ok := nm.novelData.Characters[character]; !ok {
+		return fmt.Errorf("character not found: %s", character)
+	}
+	for _, slot := range nm.novelData.Archetypes {
+		if slot.Role == strings.TrimSpace(role) {
+			slot.Character = character
+			return nm.SaveProject()
+		}
+	}
+	return fmt.Errorf("archetype not found: %s", role)
+}
+
+// OpenArchetypes 尚未有角色填补的定位
+func (nm *NovelManager) OpenArchetypes() []ArchetypeSlot {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	result := make([]ArchetypeSlot, 0)
+	if nm.novelData == nil {
+		return result
+	}
+	for _, slot := range nm.novelData.Archetypes {
+		if slot.Character == "" {
+			result = append(result, *slot)
+		}
+	}
+	return result
+}
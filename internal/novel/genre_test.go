@@ -0,0 +1,148 @@
+package novel
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinGenreTemplates(t *testing.T) {
+	templates, warnings := LoadGenreTemplates("")
+	if len(warnings) > 0 {
+		t.Fatalf("warnings: %v", warnings)
+	}
+	if len(templates) == 0 {
+		t.Fatal("no builtin genre templates")
+	}
+	beats, _ := LoadBeatTemplates("")
+	for _, template := range templates {
+		if template.Source != "builtin" || len(template.Aliases) == 0 || len(template.WorldSettings) == 0 || len(template.Characters) == 0 {
+			t.Errorf("%s: incomplete template", template.Name)
+		}
+		if template.Outline != "" && FindBeatTemplate(beats, template.Outline) == nil {
+			t.Errorf("%s: unknown outline %q", template.Name, template.Outline)
+		}
+	}
+}
+
+func TestFindGenreTemplate(t *testing.T) {
+	templates, _ := LoadGenreTemplates("")
+	tests := []struct {
+		name string
+		want string // 为空表示找不到
+	}{
+		{name: "xuanhuan", want: "xuanhuan"},
+		{name: "悬疑", want: "xuanyi"},
+		{name: "东方玄幻修仙", want: "xuanhuan"},
+		{name: "都市异能", want: "dushi"},
+		{name: "武侠"},
+		{name: "  "},
+	}
+	for _, tt := range tests {
+		got := ""
+		if template := FindGenreTemplate(templates, tt.name); template != nil {
+			got = template.Name
+		}
+		if got != tt.want {
+			t.Errorf("FindGenreTemplate(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLoadGenreTemplatesUserDir(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"xuanhuan.yaml": "name: xuanhuan\ntitle: 自定义玄幻\naliases: [玄幻]\n",
+		"wuxia.yml":     "description: 江湖恩怨\naliases: [武侠]\n",
+		"broken.yaml":   "name: [unclosed\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	templates, warnings := LoadGenreTemplates(dir)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "broken.yaml") {
+		t.Errorf("warnings = %v", warnings)
+	}
+	// 同名的自定义模板覆盖内置模板
+	if template := FindGenreTemplate(templates, "xuanhuan"); template == nil || template.Title != "自定义玄幻" || template.Source == "builtin" {
+		t.Errorf("xuanhuan = %+v", template)
+	}
+	// 未写名字时取文件名，标题默认同名
+	if template := FindGenreTemplate(templates, "武侠小说"); template == nil || template.Name != "wuxia" || template.Title != "wuxia" {
+		t.Errorf("wuxia = %+v", template)
+	}
+}
+
+func TestInitializeFromTemplate(t *testing.T) {
+	nm := NewNovelManager(t.TempDir())
+	t.Cleanup(func() { nm.Close() })
+	template := &GenreTemplate{
+		Name:          "test",
+		Description:   "测试题材",
+		TargetWords:   90000,
+		WorldSettings: []GenreSetting{{Category: "修炼体系", Name: "境界划分", Rules: []string{"四个小阶段"}}},
+		Characters:    []ArchetypeSlot{{Role: "主角"}, {Role: "宿敌"}},
+		PlotLines:     []GenrePlotLine{{Name: "主线", Type: "main"}},
+		Glossary:      []GlossaryTerm{{Term: "灵石", Category: "货币"}},
+		Files: map[string]string{
+			"笔记/灵感.md": "# {{title}} 灵感\n作者: {{author}}\n",
+			"../越界.md": "不应写入",
+		},
+	}
+	// 已存在的文件不覆盖
+	existing := filepath.Join(nm.ProjectPath(), OutlineFileName)
+	if err := os.WriteFile(existing, []byte("手写大纲"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	beats, _ := LoadBeatTemplates("")
+
+	created, err := nm.InitializeFromTemplate("测试小说", "测试作者", "玄幻", template, FindBeatTemplate(beats, "three_act"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{WorldFileName, ProtagonistFileName, filepath.Join("笔记", "灵感.md")}; !reflect.DeepEqual(created, want) {
+		t.Errorf("created = %v, want %v", created, want)
+	}
+	if data, _ := os.ReadFile(existing); string(data) != "手写大纲" {
+		t.Errorf("existing outline overwritten: %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(nm.ProjectPath(), "笔记", "灵感.md")); string(data) != "# 测试小说 灵感\n作者: 测试作者\n" {
+		t.Errorf("custom file = %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(nm.ProjectPath()), "越界.md")); err == nil {
+		t.Error("template wrote outside the project")
+	}
+	world, _ := os.ReadFile(filepath.Join(nm.ProjectPath(), WorldFileName))
+	if !strings.Contains(string(world), "- 规则: 四个小阶段") || !strings.Contains(string(world), "| 灵石 | 货币 |") {
+		t.Errorf("world file =\n%s", world)
+	}
+
+	project := nm.novelData
+	if project.TargetWords != 90000 || project.Outline == nil || project.Outline.TotalChapters != 30 {
+		t.Errorf("target words = %d, outline = %+v", project.TargetWords, project.Outline)
+	}
+	if project.WorldSettings["境界划分"] == nil || project.PlotLines["主线"] == nil || project.Glossary["灵石"] == nil {
+		t.Error("template settings not seeded")
+	}
+
+	if err := nm.AssignArchetype("主角", "林动"); err == nil {
+		t.Error("assigned a missing character")
+	}
+	if _, err := nm.AddCharacter(&Character{Name: "林动"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := nm.AssignArchetype("师父", "林动"); err == nil {
+		t.Error("assigned a missing archetype")
+	}
+	if err := nm.AssignArchetype(" 主角 ", "林动"); err != nil {
+		t.Fatal(err)
+	}
+	if open := nm.OpenArchetypes(); len(open) != 1 || open[0].Role != "宿敌" {
+		t.Errorf("OpenArchetypes() = %+v", open)
+	}
+}
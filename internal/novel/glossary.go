@@ -0,0 +1,769 @@
+package novel
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// 术语误写的类型
+const (
+	GlossaryVariant   = "variant"   // 已登记的错误写法或旧写法
+	GlossaryHomophone = "homophone" // 同音字误写，如“筑基”写成“筑机”
+	GlossaryTypo      = "typo"      // 错一字、颠倒或多漏一字
+)
+
+const (
+	// 生成提示中最多列出的术语数
+	maxPromptGlossaryTerms = 40
+	// 错一字、颠倒字序视为误写所需的最短术语长度，更短的术语只检查同音字
+	minTypoTermRunes = 3
+	// 多一字、少一字视为误写所需的最短术语长度
+	minIndelTermRunes = 4
+)
+
+// GlossaryTerm 术语表条目：境界、功法、法宝、地名等自创名词的标准写法
+type GlossaryTerm struct {
+	Term        string   `json:"term" yaml:"term"`
+	Category    string   `json:"category,omitempty" yaml:"category"`
+	Description string   `json:"description,omitempty" yaml:"description"`
+	Variants    []string `json:"variants,omitempty" yaml:"variants,omitempty"` // 需要统一为标准写法的错误写法或旧写法
+	Allowed     []string `json:"allowed,omitempty" yaml:"allowed,omitempty"`   // 形近音近但另有含义、不应报告的词
+}
+
+// GlossaryIssue 正文中疑似误写的术语，同一章同一写法合并为一条
+type GlossaryIssue struct {
+	Chapter int    `json:"chapter"`
+	Term    string `json:"term"`  // 标准写法
+	Found   string `json:"found"` // 正文中的写法
+	Kind    string `json:"kind"`
+	Count   int    `json:"count"`
+	Excerpt string `json:"excerpt,omitempty"`
+	Offsets []int  `json:"-"` // 各处写法在正文中的起始字位置，批量修正只替换这些位置
+}
+
+// GlossaryReport 术语检查结果
+type GlossaryReport struct {
+	Terms   int // 术语表中的术语数
+	Checked []int
+	Issues  []*GlossaryIssue
+}
+
+// GlossaryFix 一处批量修正：把某章中检出的 Found 替换为 Term
+type GlossaryFix struct {
+	Chapter int
+	Term    string
+	Found   string
+	Count   int
+}
+
+// GlossaryFixResult 批量修正结果；Applied 为 false 时只是预览
+type GlossaryFixResult struct {
+	Applied   bool
+	Fixes     []GlossaryFix
+	Unmatched []string // 指定了但未在正文中检出的写法
+}
+
+// AddGlossaryTerm 添加术语；已存在时合并，非空的分类和说明覆盖原值，错误写法和例外词追加。返回是否为新术语
+func (nm *NovelManager) AddGlossaryTerm(entry GlossaryTerm) (bool, error) {
+	entry.Term = strings.TrimSpace(entry.Term)
+	if entry.Term == "" {
+		return false, fmt.Errorf("term is required")
+	}
+
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	if nm.novelData == nil {
+		return false, fmt.Errorf("novel project not initialized")
+	}
+	if nm.novelData.Glossary == nil {
+		nm.novelData.Glossary = make(map[string]*GlossaryTerm)
+	}
+	existing, ok := nm.novelData.Glossary[entry.Term]
+	if !ok {
+		existing = &GlossaryTerm{Term: entry.Term}
+		nm.novelData.Glossary[entry.Term] = existing
+	}
+	if category := strings.TrimSpace(entry.Category); category != "" {
+		existing.Category = category
+	}
+	if description := strings.TrimSpace(entry.Description); description != "" {
+		existing.Description = description
+	}
+	existing.Variants = mergeGlossaryWords(existing.Term, existing.Variants, entry.Variants)
+	existing.Allowed = mergeGlossaryWords(existing.Term, existing.Allowed, entry.Allowed)
+	return !ok, nm.SaveProject()
+}
+
+// RemoveGlossaryTerm 删除术语
+func (nm *NovelManager) RemoveGlossaryTerm(term string) error {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	if nm.novelData == nil {
+		return fmt.Errorf("novel project not initialized")
+	}
+	term = strings.TrimSpace(term)
+	if _, ok := nm.novelData.Glossary[term]; !ok {
+		return fmt.Errorf("glossary term not found: %s", term)
+	}
+	delete(nm.novelData.Glossary, term)
+	return nm.SaveProject()
+}
+
+// AllowGlossaryWords 把另有含义的词加入术语的例外，之后检查不再报告
+func (nm *NovelManager) AllowGlossaryWords(term string, words []string) error {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	if nm.novelData == nil {
+		return fmt.Errorf("novel project not initialized")
+	}
+	entry, ok := nm.novelData.Glossary[strings.TrimSpace(term)]
+	if !ok {
+		return fmt.Errorf("glossary term not found: %s", term)
+	}
+	entry.Allowed = mergeGlossaryWords(entry.Term, entry.Allowed, words)
+	kept := make([]string, 0, len(entry.Variants))
+	for _, variant := range entry.Variants {
+		if !containsString(entry.Allowed, variant) {
+			kept = append(kept, variant)
+		}
+	}
+	entry.Variants = kept
+	return nm.SaveProject()
+}
+
+// GlossaryTerms 按分类和术语排序的术语表副本
+func (nm *NovelManager) GlossaryTerms() []GlossaryTerm {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	if nm.novelData == nil {
+		return nil
+	}
+	return nm.sortedGlossary()
+}
+
+// sortedGlossary 调用方需持有锁
+func (nm *NovelManager) sortedGlossary() []GlossaryTerm {
+	terms := make([]GlossaryTerm, 0, len(nm.novelData.Glossary))
+	for _, entry := range nm.novelData.Glossary {
+		copied := *entry
+		copied.Variants = append([]string{}, entry.Variants...)
+		copied.Allowed = append([]string{}, entry.Allowed...)
+		terms = append(terms, copied)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Category != terms[j].Category {
+			return terms[i].Category < terms[j].Category
+		}
+		return terms[i].Term < terms[j].Term
+	})
+	return terms
+}
+
+// LintGlossary 检查章节正文中的术语误写，from/to 为0时表示不限
+func (nm *NovelManager) LintGlossary(fromChapter, toChapter int) (*GlossaryReport, error) {
+	report, _, err := nm.lintGlossaryChapters(fromChapter, toChapter)
+	return report, err
+}
+
+// lintGlossaryChapters 检查章节正文，同时返回检查时读到的正文，供批量修正按位置替换
+func (nm *NovelManager) lintGlossaryChapters(fromChapter, toChapter int) (*GlossaryReport, map[int]string, error) {
+	matcher, err := nm.newGlossaryMatcher()
+	if err != nil {
+		return nil, nil, err
+	}
+	texts, err := nm.readChapterFiles()
+	if err != nil {
+		return nil, nil, err
+	}
+	numbers := make([]int, 0, len(texts))
+	for number := range texts {
+		if (fromChapter > 0 && number < fromChapter) || (toChapter > 0 && number > toChapter) {
+			continue
+		}
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+
+	report := &GlossaryReport{Terms: len(matcher.terms), Checked: numbers}
+	for _, number := range numbers {
+		report.Issues = append(report.Issues, matcher.lint(number, texts[number])...)
+	}
+	return report, texts, nil
+}
+
+// LintGlossaryText 检查尚未写入文件的单章正文（如生成的草稿）中的术语误写
+func (nm *NovelManager) LintGlossaryText(chapterNum int, text string) (*GlossaryReport, error) {
+	matcher, err := nm.newGlossaryMatcher()
+	if err != nil {
+		return nil, err
+	}
+	return &GlossaryReport{Terms: len(matcher.terms), Checked: []int{chapterNum}, Issues: matcher.lint(chapterNum, text)}, nil
+}
+
+// FixGlossary 把检出的误写批量替换为标准写法，只替换检查报告的位置，例外词和被更长的词覆盖的位置不动。
+// words 为空时修正已登记的错误写法和同音误写，疑似错字需在 words 中明确指定；apply 为 false 时只预览。
+// 修正过的写法登记为该术语的错误写法
+func (nm *NovelManager) FixGlossary(fromChapter, toChapter int, words []string, apply bool) (*GlossaryFixResult, error) {
+	report, texts, err := nm.lintGlossaryChapters(fromChapter, toChapter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &GlossaryFixResult{Applied: apply}
+	matched := make(map[string]bool)
+	byChapter := make(map[int][]*GlossaryIssue)
+	for _, issue := range report.Issues {
+		if len(words) > 0 {
+			if !containsString(words, issue.Found) {
+				continue
+			}
+		} else if issue.Kind == GlossaryTypo {
+			continue
+		}
+		matched[issue.Found] = true
+		byChapter[issue.Chapter] = append(byChapter[issue.Chapter], issue)
+	}
+	for _, word := range words {
+		if !matched[word] {
+			result.Unmatched = append(result.Unmatched, word)
+		}
+	}
+
+	variants := make(map[string][]string)
+	for _, number := range report.Checked {
+		issues := byChapter[number]
+		if len(issues) == 0 {
+			continue
+		}
+		for _, issue := range issues {
+			result.Fixes = append(result.Fixes, GlossaryFix{Chapter: number, Term: issue.Term, Found: issue.Found, Count: issue.Count})
+			if issue.Kind != GlossaryVariant {
+				variants[issue.Term] = append(variants[issue.Term], issue.Found)
+			}
+		}
+		if !apply {
+			continue
+		}
+		if err := nm.saveChapterFile(number, replaceGlossaryIssues(texts[number], issues), VersionGlossary, "统一术语写法"); err != nil {
+			return result, err
+		}
+	}
+	if !apply || len(result.Fixes) == 0 {
+		return result, nil
+	}
+
+	nm.mutex.Lock()
+	if nm.novelData != nil {
+		for term, found := range variants {
+			if entry, ok := nm.novelData.Glossary[term]; ok {
+				entry.Variants = mergeGlossaryWords(entry.Term, entry.Variants, found)
+			}
+		}
+		err = nm.SaveProject()
+	}
+	nm.mutex.Unlock()
+	if err != nil {
+		return result, err
+	}
+	_, err = nm.SyncChapterStats()
+	return result, err
+}
+
+// replaceGlossaryIssues 按检查报告的位置从后往前替换，前面的位置不受替换后长度变化的影响
+func replaceGlossaryIssues(text string, issues []*GlossaryIssue) string {
+	type edit struct {
+		start, end int
+		term       []rune
+	}
+	edits := make([]edit, 0)
+	for _, issue := range issues {
+		length := len([]rune(issue.Found))
+		for _, offset := range issue.Offsets {
+			edits = append(edits, edit{start: offset, end: offset + length, term: []rune(issue.Term)})
+		}
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+
+	runes := []rune(text)
+	limit := len(runes)
+	for _, e := range edits {
+		// 位置互相重叠或超出正文时跳过，检查结果不会出现这种情况
+		if e.start < 0 || e.end > limit {
+			continue
+		}
+		runes = append(runes[:e.start], append(append([]rune{}, e.term...), runes[e.end:]...)...)
+		limit = e.start
+	}
+	return string(runes)
+}
+
+// glossaryPrompt 生成提示中的术语表，focus 中提到的术语优先，调用方需持有锁
+func (nm *NovelManager) glossaryPrompt(focus string) string {
+	if len(nm.novelData.Glossary) == 0 {
+		return ""
+	}
+	terms := nm.sortedGlossary()
+	mentioned := func(term GlossaryTerm) bool {
+		return strings.Contains(focus, term.Term) || containsAny(focus, term.Variants)
+	}
+	sort.SliceStable(terms, func(i, j int) bool { return mentioned(terms[i]) && !mentioned(terms[j]) })
+
+	var prompt strings.Builder
+	prompt.WriteString("以下自创名词必须使用标准写法:\n")
+	for i, term := range terms {
+		if i == maxPromptGlossaryTerms {
+			prompt.WriteString(fmt.Sprintf("……另有 %d 个术语从略\n", len(terms)-i))
+			break
+		}
+		line := "• " + term.Term
+		if term.Category != "" {
+			line += fmt.Sprintf("（%s）", term.Category)
+		}
+		if term.Description != "" {
+			line += ": " + term.Description
+		}
+		if len(term.Variants) > 0 {
+			line += fmt.Sprintf("；不要写作 %s", strings.Join(term.Variants, "、"))
+		}
+		prompt.WriteString(line + "\n")
+	}
+	return prompt.String()
+}
+
+// glossaryMatcher 基于术语表副本的误写检查器
+type glossaryMatcher struct {
+	terms []GlossaryTerm
+	runes [][]rune
+	index map[string][]termPosition // 读音 -> 含该读音的术语位置
+	known [][]rune                  // 术语、错误写法、例外词以及角色和设定名，正文中出现时不参与模糊匹配
+}
+
+type termPosition struct {
+	term   int
+	offset int
+}
+
+// glossaryCandidate 一处模糊匹配
+type glossaryCandidate struct {
+	term  int
+	start int
+	end   int
+	kind  string
+}
+
+// newGlossaryMatcher 基于当前术语表创建检查器
+func (nm *NovelManager) newGlossaryMatcher() (*glossaryMatcher, error) {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	if nm.novelData == nil {
+		return nil, fmt.Errorf("novel project not initialized")
+	}
+	matcher := &glossaryMatcher{terms: nm.sortedGlossary(), index: make(map[string][]termPosition)}
+	addKnown := func(word string) {
+		if word = strings.TrimSpace(word); word != "" {
+			matcher.known = append(matcher.known, []rune(word))
+		}
+	}
+	for i, term := range matcher.terms {
+		runes := []rune(term.Term)
+		matcher.runes = append(matcher.runes, runes)
+		for offset, r := range runes {
+			for _, key := range soundKeys(r) {
+				matcher.index[key] = append(matcher.index[key], termPosition{term: i, offset: offset})
+			}
+		}
+		addKnown(term.Term)
+		for _, word := range append(append([]string{}, term.Variants...), term.Allowed...) {
+			addKnown(word)
+		}
+	}
+	for name, char := range nm.novelData.Characters {
+		addKnown(name)
+		for _, alias := range char.Aliases {
+			addKnown(alias)
+		}
+	}
+	for name := range nm.novelData.WorldSettings {
+		addKnown(name)
+	}
+	// 长词优先标记，短词出现在长词内部时不影响结果
+	sort.Slice(matcher.known, func(i, j int) bool { return len(matcher.known[i]) > len(matcher.known[j]) })
+	return matcher, nil
+}
+
+// lint 检查单章正文：先找已登记的错误写法，再在其余位置找同音字和错字。
+// 错误写法落在更长的已知词（例外词、角色名等）里时不算误写
+func (m *glossaryMatcher) lint(chapterNum int, text string) []*GlossaryIssue {
+	if len(m.terms) == 0 {
+		return nil
+	}
+	runes := []rune(text)
+	covered := make([]bool, len(runes))
+	spans := make([][2]int, 0)
+	for _, word := range m.known {
+		for _, start := range findOccurrences(runes, word) {
+			spans = append(spans, [2]int{start, start + len(word)})
+			for i := start; i < start+len(word); i++ {
+				covered[i] = true
+			}
+		}
+	}
+
+	issues := make([]*GlossaryIssue, 0)
+	found := make(map[string]*GlossaryIssue)
+	record := func(term, word, kind string, offsets ...int) {
+		if issue, ok := found[word]; ok {
+			issue.Count += len(offsets)
+			issue.Offsets = append(issue.Offsets, offsets...)
+			return
+		}
+		issue := &GlossaryIssue{Chapter: chapterNum, Term: term, Found: word, Kind: kind, Count: len(offsets),
+			Excerpt: excerptAround(text, word), Offsets: offsets}
+		found[word] = issue
+		issues = append(issues, issue)
+	}
+
+	type variantWord struct {
+		term  string
+		runes []rune
+	}
+	variants := make([]variantWord, 0)
+	for _, term := range m.terms {
+		for _, variant := range term.Variants {
+			if variant != "" {
+				variants = append(variants, variantWord{term: term.Term, runes: []rune(variant)})
+			}
+		}
+	}
+	// 长的错误写法优先，短写法不会再匹配长写法的一部分
+	sort.SliceStable(variants, func(i, j int) bool { return len(variants[i].runes) > len(variants[j].runes) })
+	used := make([]bool, len(runes))
+	for _, variant := range variants {
+		offsets := make([]int, 0)
+		for _, start := range findOccurrences(runes, variant.runes) {
+			end := start + len(variant.runes)
+			if anyMarked(used, start, end) || insideLongerSpan(spans, start, end) {
+				continue
+			}
+			for i := start; i < end; i++ {
+				used[i] = true
+			}
+			offsets = append(offsets, start)
+		}
+		if len(offsets) > 0 {
+			record(variant.term, string(variant.runes), GlossaryVariant, offsets...)
+		}
+	}
+
+	candidates := m.candidates(runes, covered)
+	// 同音优先于错字，等长优先于多字少字，同类按出现位置
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if (a.kind == GlossaryHomophone) != (b.kind == GlossaryHomophone) {
+			return a.kind == GlossaryHomophone
+		}
+		lenA, lenB := a.end-a.start == len(m.runes[a.term]), b.end-b.start == len(m.runes[b.term])
+		if lenA != lenB {
+			return lenA
+		}
+		return a.start < b.start
+	})
+	accepted := make([]glossaryCandidate, 0)
+	for _, candidate := range candidates {
+		if anyMarked(used, candidate.start, candidate.end) {
+			continue
+		}
+		for i := candidate.start; i < candidate.end; i++ {
+			used[i] = true
+		}
+		accepted = append(accepted, candidate)
+	}
+	sort.Slice(accepted, func(i, j int) bool { return accepted[i].start < accepted[j].start })
+	for _, candidate := range accepted {
+		record(m.terms[candidate.term].Term, string(runes[candidate.start:candidate.end]), candidate.kind, candidate.start)
+	}
+	return issues
+}
+
+// candidates 以术语中任一字的读音为锚点，找出与术语只差同音字、一个错字、颠倒或多漏一字的汉字片段
+func (m *glossaryMatcher) candidates(runes []rune, covered []bool) []glossaryCandidate {
+	result := make([]glossaryCandidate, 0)
+	seen := make(map[[3]int]bool)
+	for p, r := range runes {
+		if covered[p] || !unicode.Is(unicode.Han, r) {
+			continue
+		}
+		for _, position := range m.termPositions(r) {
+			term := m.runes[position.term]
+			lengths := []int{len(term)}
+			if len(term) >= minIndelTermRunes {
+				lengths = append(lengths, len(term)-1, len(term)+1)
+			}
+			for _, length := range lengths {
+				for _, start := range []int{p - position.offset, p - position.offset - 1, p - position.offset + 1} {
+					end := start + length
+					key := [3]int{position.term, start, end}
+					if start < 0 || end > len(runes) || seen[key] {
+						continue
+					}
+					seen[key] = true
+					if anyMarked(covered, start, end) || !allHan(runes[start:end]) {
+						continue
+					}
+					if kind := classifyNearMiss(term, runes[start:end]); kind != "" {
+						result = append(result, glossaryCandidate{term: position.term, start: start, end: end, kind: kind})
+					}
+				}
+			}
+		}
+	}
+	return result
+}
+
+// termPositions 与 r 有相同读音的术语位置，多音字的各个读音合并去重
+func (m *glossaryMatcher) termPositions(r rune) []termPosition {
+	keys := soundKeys(r)
+	if len(keys) == 1 {
+		return m.index[keys[0]]
+	}
+	result := make([]termPosition, 0)
+	seen := make(map[termPosition]bool)
+	for _, key := range keys {
+		for _, position := range m.index[key] {
+			if !seen[position] {
+				seen[position] = true
+				result = append(result, position)
+			}
+		}
+	}
+	return result
+}
+
+// classifyNearMiss 判断 word 是否为 term 的误写，不是时返回空
+func classifyNearMiss(term, word []rune) string {
+	if len(word) != len(term) {
+		if len(term) >= minIndelTermRunes && editDistance(term, word) == 1 {
+			return GlossaryTypo
+		}
+		return ""
+	}
+
+	diffs := make([]int, 0, 2)
+	for i := range term {
+		if term[i] != word[i] {
+			diffs = append(diffs, i)
+		}
+	}
+	if len(diffs) == 0 {
+		return ""
+	}
+	homophone := len(diffs)*2 <= len(term) || len(diffs) == 1
+	for _, i := range diffs {
+		if !sameSoundRune(term[i], word[i]) {
+			homophone = false
+		}
+	}
+	switch {
+	case homophone:
+		return GlossaryHomophone
+	case len(term) < minTypoTermRunes:
+		return ""
+	case len(diffs) == 1:
+		return GlossaryTypo
+	case len(diffs) == 2 && diffs[1] == diffs[0]+1 && term[diffs[0]] == word[diffs[1]] && term[diffs[1]] == word[diffs[0]]:
+		return GlossaryTypo
+	}
+	return ""
+}
+
+// editDistance 按字计算的编辑距离
+func editDistance(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// findOccurrences word 在正文中出现的起始位置，允许重叠
+func findOccurrences(runes, word []rune) []int {
+	if len(word) == 0 {
+		return nil
+	}
+	starts := make([]int, 0)
+	for start := 0; start+len(word) <= len(runes); start++ {
+		if runes[start] != word[0] {
+			continue
+		}
+		match := true
+		for i := 1; i < len(word); i++ {
+			if runes[start+i] != word[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			starts = append(starts, start)
+		}
+	}
+	return starts
+}
+
+// insideLongerSpan [start, end) 是否与更长的已知词重叠
+func insideLongerSpan(spans [][2]int, start, end int) bool {
+	for _, span := range spans {
+		if span[1]-span[0] > end-start && span[0] < end && start < span[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func anyMarked(marks []bool, start, end int) bool {
+	for i := start; i < end; i++ {
+		if marks[i] {
+			return true
+		}
+	}
+	return false
+}
+
+func allHan(runes []rune) bool {
+	for _, r := range runes {
+		if !unicode.Is(unicode.Han, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeGlossaryWords 追加去重后的词，忽略空白和标准写法本身
+func mergeGlossaryWords(term string, existing, words []string) []string {
+	result := append([]string{}, existing...)
+	for _, word := range words {
+		word = strings.TrimSpace(word)
+		if word != "" && word != term && !containsString(result, word) {
+			result = append(result, word)
+		}
+	}
+	return result
+}
+
+func glossaryKindLabel(kind string) string {
+	return labelOr(map[string]string{
+		GlossaryVariant:   "已登记的错误写法",
+		GlossaryHomophone: "同音误写",
+		GlossaryTypo:      "疑似错字",
+	}, kind)
+}
+
+// FormatGlossary 格式化术语表
+func FormatGlossary(terms []GlossaryTerm) string {
+	var result strings.Builder
+	result.WriteString("📖 === 术语表 ===\n\n")
+	if len(terms) == 0 {
+		result.WriteString("术语表为空，可用 glossary 工具添加境界、功法、地名等自创名词\n")
+		return result.String()
+	}
+	category := "\x00"
+	for _, term := range terms {
+		if term.Category != category {
+			category = term.Category
+			result.WriteString(fmt.Sprintf("\n【%s】\n", labelOr(map[string]string{"": "未分类"}, category)))
+		}
+		line := "• " + term.Term
+		if term.Description != "" {
+			line += ": " + term.Description
+		}
+		result.WriteString(line + "\n")
+		if len(term.Variants) > 0 {
+			result.WriteString(fmt.Sprintf("  ✏️ 统一替换: %s\n", strings.Join(term.Variants, "、")))
+		}
+		if len(term.Allowed) > 0 {
+			result.WriteString(fmt.Sprintf("  ✅ 例外: %s\n", strings.Join(term.Allowed, "、")))
+		}
+	}
+	result.WriteString(fmt.Sprintf("\n共 %d 个术语\n", len(terms)))
+	return result.String()
+}
+
+// Format 格式化术语检查结果
+func (r *GlossaryReport) Format() string {
+	var result strings.Builder
+	result.WriteString("📖 === 术语检查 ===\n\n")
+	if r.Terms == 0 {
+		result.WriteString("术语表为空，可用 glossary 工具添加术语后再检查\n")
+		return result.String()
+	}
+	if len(r.Checked) == 0 {
+		result.WriteString("没有可检查的章节正文\n")
+		return result.String()
+	}
+	result.WriteString(fmt.Sprintf("检查范围: 第%d章 - 第%d章（共 %d 章）\n", r.Checked[0], r.Checked[len(r.Checked)-1], len(r.Checked)))
+	if len(r.Issues) == 0 {
+		result.WriteString("\n✅ 未发现术语误写\n")
+		return result.String()
+	}
+	result.WriteString(fmt.Sprintf("发现疑似误写: %d 处\n", len(r.Issues)))
+
+	chapter := 0
+	for _, issue := range r.Issues {
+		if issue.Chapter != chapter {
+			chapter = issue.Chapter
+			result.WriteString(fmt.Sprintf("\n📄 第%d章\n", chapter))
+		}
+		result.WriteString(fmt.Sprintf("• 「%s」→ %s（%s，%d 处）\n", issue.Found, issue.Term, glossaryKindLabel(issue.Kind), issue.Count))
+		if issue.Excerpt != "" {
+			result.WriteString(fmt.Sprintf("  %s\n", issue.Excerpt))
+		}
+	}
+	result.WriteString("\n💡 用 fix_glossary 批量修正；确属其他含义的词可用 glossary 的 ignore 操作加入例外\n")
+	return result.String()
+}
+
+// Format 格式化批量修正结果
+func (r *GlossaryFixResult) Format() string {
+	var result strings.Builder
+	if r.Applied {
+		result.WriteString("✏️ === 术语批量修正 ===\n\n")
+	} else {
+		result.WriteString("👀 === 术语批量修正（预览） ===\n\n")
+	}
+	if len(r.Unmatched) > 0 {
+		result.WriteString(fmt.Sprintf("⚠️ 正文中未检出: %s\n", strings.Join(r.Unmatched, "、")))
+	}
+	if len(r.Fixes) == 0 {
+		result.WriteString("没有需要修正的写法\n")
+		return result.String()
+	}
+	total := 0
+	for _, fix := range r.Fixes {
+		result.WriteString(fmt.Sprintf("• 第%d章: 「%s」→「%s」%d 处\n", fix.Chapter, fix.Found, fix.Term, fix.Count))
+		total += fix.Count
+	}
+	if r.Applied {
+		result.WriteString(fmt.Sprintf("\n✅ 已替换 %d 处，修正过的写法已登记到术语表\n", total))
+	} else {
+		result.WriteString(fmt.Sprintf("\n共 %d 处，确认无误后设置 apply=true 执行替换\n", total))
+	}
+	return result.String()
+}
@@ -0,0 +1,212 @@
+package novel
+
+import (
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"太虚剑诀", "太虚剑诀", 0},
+		{"太虚剑诀", "太虚刀诀", 1},
+		{"太虚剑诀", "太虚诀", 1},
+		{"太虚剑诀", "太虚剑诀法", 1},
+		{"太虚剑诀", "太剑虚诀", 2},
+		{"", "筑基", 2},
+		{"筑基", "金丹", 2},
+	}
+	for _, tt := range tests {
+		if got := editDistance([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSameSoundRune(t *testing.T) {
+	tests := []struct {
+		a, b rune
+		want bool
+	}{
+		{'基', '机', true},
+		{'基', '墓', false},
+		{'长', '常', true}, // 长 chang
+		{'长', '张', true}, // 长 zhang
+		{'重', '众', true}, // 重 zhong
+		{'重', '虫', true}, // 重 chong
+		{'行', '形', true}, // 行 xing
+		{'行', '杭', true}, // 行 hang
+		{'乐', '月', true}, // 乐 yue
+		{'乐', '泪', false},
+		{'鑫', '鑫', true}, // 不在拼音表中的字只与自身同音
+	}
+	for _, tt := range tests {
+		if got := sameSoundRune(tt.a, tt.b); got != tt.want {
+			t.Errorf("sameSoundRune(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyNearMiss(t *testing.T) {
+	tests := []struct {
+		name       string
+		term, word string
+		want       string
+	}{
+		{"同音一字", "筑基", "筑机", GlossaryHomophone},
+		{"多音字的第二个读音", "长生诀", "张生诀", GlossaryHomophone},
+		{"多音字的第二个读音", "重楼", "虫楼", GlossaryHomophone},
+		{"两字同音且不过半", "万剑归宗", "万箭归踪", GlossaryHomophone},
+		{"短术语不报错字", "筑基", "筑墓", ""},
+		{"错一字", "太虚剑诀", "太虚刀诀", GlossaryTypo},
+		{"颠倒字序", "太虚剑诀", "太剑虚诀", GlossaryTypo},
+		{"少一字", "太虚剑诀", "太虚诀", GlossaryTypo},
+		{"短术语不报多漏字", "长生诀", "长生", ""},
+		{"相差太多", "太虚剑诀", "天罡地煞", ""},
+		{"完全相同", "筑基", "筑基", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyNearMiss([]rune(tt.term), []rune(tt.word)); got != tt.want {
+				t.Errorf("classifyNearMiss(%q, %q) = %q, want %q", tt.term, tt.word, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLintGlossaryText(t *testing.T) {
+	tests := []struct {
+		name      string
+		terms     []GlossaryTerm
+		character string
+		text      string
+		want      map[string]int // 检出的写法 -> 处数
+	}{
+		{
+			name:  "已登记的错误写法",
+			terms: []GlossaryTerm{{Term: "紫霄", Variants: []string{"紫宵"}}},
+			text:  "紫宵剑光一闪，紫宵二字刻在石上。",
+			want:  map[string]int{"紫宵": 2},
+		},
+		{
+			name:  "例外词中的错误写法不报告",
+			terms: []GlossaryTerm{{Term: "紫霄", Variants: []string{"紫宵"}, Allowed: []string{"紫宵宫"}}},
+			text:  "紫宵剑光一闪，他走进紫宵宫。",
+			want:  map[string]int{"紫宵": 1},
+		},
+		{
+			name:  "同音误写",
+			terms: []GlossaryTerm{{Term: "筑基"}},
+			text:  "他终于筑机成功。",
+			want:  map[string]int{"筑机": 1},
+		},
+		{
+			name:  "多音字同音误写",
+			terms: []GlossaryTerm{{Term: "长生诀"}},
+			text:  "他翻开张生诀，又合上了。",
+			want:  map[string]int{"张生诀": 1},
+		},
+		{
+			name:  "例外词不报告",
+			terms: []GlossaryTerm{{Term: "筑基", Allowed: []string{"竹几"}}},
+			text:  "竹几上放着一杯茶。",
+			want:  map[string]int{},
+		},
+		{
+			name:      "角色名覆盖的位置不报告",
+			terms:     []GlossaryTerm{{Term: "筑基"}},
+			character: "筑机子",
+			text:      "他终于筑机成功，筑机子笑了。",
+			want:      map[string]int{"筑机": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nm := newTestManager(t)
+			for _, term := range tt.terms {
+				if _, err := nm.AddGlossaryTerm(term); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.character != "" {
+				if _, err := nm.AddCharacter(&Character{Name: tt.character}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			report, err := nm.LintGlossaryText(1, tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]int)
+			for _, issue := range report.Issues {
+				got[issue.Found] = issue.Count
+				if len(issue.Offsets) != issue.Count {
+					t.Errorf("%s: %d offsets for count %d", issue.Found, len(issue.Offsets), issue.Count)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("issues = %v, want %v", got, tt.want)
+			}
+			for word, count := range tt.want {
+				if got[word] != count {
+					t.Errorf("issues = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFixGlossaryOnlyReplacesReportedOffsets(t *testing.T) {
+	tests := []struct {
+		name      string
+		term      GlossaryTerm
+		character string
+		text      string
+		want      string
+	}{
+		{
+			name: "例外词中的错误写法保持不变",
+			term: GlossaryTerm{Term: "紫霄", Variants: []string{"紫宵"}, Allowed: []string{"紫宵宫"}},
+			text: "紫宵剑光一闪，他走进紫宵宫。",
+			want: "紫霄剑光一闪，他走进紫宵宫。",
+		},
+		{
+			name:      "角色名中的同音写法保持不变",
+			term:      GlossaryTerm{Term: "筑基"},
+			character: "筑机子",
+			text:      "他终于筑机成功，筑机子笑了。",
+			want:      "他终于筑基成功，筑机子笑了。",
+		},
+		{
+			name: "替换后长度变化不影响前面的位置",
+			term: GlossaryTerm{Term: "太虚剑诀", Variants: []string{"太虚诀"}},
+			text: "太虚诀第一层，太虚诀第二层。",
+			want: "太虚剑诀第一层，太虚剑诀第二层。",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nm := newTestManager(t)
+			if _, err := nm.AddGlossaryTerm(tt.term); err != nil {
+				t.Fatal(err)
+			}
+			if tt.character != "" {
+				if _, err := nm.AddCharacter(&Character{Name: tt.character}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			writeTestChapter(t, nm, 1, tt.text)
+			if _, err := nm.FixGlossary(0, 0, nil, true); err != nil {
+				t.Fatal(err)
+			}
+			got, err := nm.ReadChapterText(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("text = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
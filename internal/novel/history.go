@@ -0,0 +1,276 @@
+package novel
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// HistoryDir 章节历史版本目录，每章一个子目录，包含 index.json 和各版本正文
+const HistoryDir = "history"
+
+// 版本来源
+const (
+	VersionEdit     = "edit"     // 对话中或编辑器里修改了章节文件，同步时发现
+	VersionGenerate = "generate" // 生成流水线写入
+	VersionImport   = "import"   // 导入文稿
+	VersionGlossary = "glossary" // 术语批量修正
+	VersionRollback = "rollback" // 回滚到旧版本
+	VersionSnapshot = "snapshot" // 手动保存
+)
+
+// 每章保留的版本数，超出时删除最早的版本
+const maxChapterVersions = 100
+
+// ChapterVersion 章节的一个历史版本
+type ChapterVersion struct {
+	Number int       `json:"number"`
+	Time   time.Time `json:"time"`
+	Words  int       `json:"words"`
+	Delta  int       `json:"delta"` // 相对上一版本的字数变化
+	Source string    `json:"source"`
+	Note   string    `json:"note,omitempty"`
+	Digest string    `json:"digest"`
+}
+
+// chapterHistory history/chapter_NNN/index.json
+type chapterHistory struct {
+	Chapter  int               `json:"chapter"`
+	Versions []*ChapterVersion `json:"versions"`
+}
+
+func (h *chapterHistory) latest() *ChapterVersion {
+	if len(h.Versions) == 0 {
+		return nil
+	}
+	return h.Versions[len(h.Versions)-1]
+}
+
+func (h *chapterHistory) find(number int) *ChapterVersion {
+	for _, version := range h.Versions {
+		if version.Number == number {
+			return version
+		}
+	}
+	return nil
+}
+
+// ChapterVersions 列出章节的历史版本，从旧到新
+func (nm *NovelManager) ChapterVersions(chapterNum int) ([]ChapterVersion, error) {
+	nm.historyMutex.Lock()
+	defer nm.historyMutex.Unlock()
+
+	history, err := nm.loadChapterHistory(chapterNum)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]ChapterVersion, 0, len(history.Versions))
+	for _, version := range history.Versions {
+		versions = append(versions, *version)
+	}
+	return versions, nil
+}
+
+// ReadChapterVersion 读取某个历史版本的正文；version 为0时读取当前章节文件
+func (nm *NovelManager) ReadChapterVersion(chapterNum, version int) (string, error) {
+	if version == 0 {
+		return nm.ReadChapterText(chapterNum)
+	}
+	data, err := os.ReadFile(nm.versionFilePath(chapterNum, version))
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("chapter %d has no version %d", chapterNum, version)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read chapter %d version %d: %w", chapterNum, version, err)
+	}
+	return string(data), nil
+}
+
+// SnapshotChapter 把章节当前正文保存为一个版本，内容与最新版本相同时只更新备注
+func (nm *NovelManager) SnapshotChapter(chapterNum int, note string) (*ChapterVersion, error) {
+	text, err := nm.ReadChapterText(chapterNum)
+	if err != nil {
+		return nil, err
+	}
+
+	nm.historyMutex.Lock()
+	defer nm.historyMutex.Unlock()
+
+	history, err := nm.loadChapterHistory(chapterNum)
+	if err != nil {
+		return nil, err
+	}
+	if latest := history.latest(); latest != nil && latest.Digest == versionDigest(text) {
+		if note == "" || note == latest.Note {
+			return latest, nil
+		}
+		latest.Note = note
+		return latest, nm.saveChapterHistory(history)
+	}
+	return nm.appendChapterVersion(history, text, VersionSnapshot, note)
+}
+
+// RollbackChapter 用历史版本覆盖章节正文；当前正文若尚未保存为版本会先保存，回滚本身也记为一个新版本
+func (nm *NovelManager) RollbackChapter(chapterNum, version int) (*ChapterVersion, error) {
+	text, err := nm.ReadChapterVersion(chapterNum, version)
+	if err != nil {
+		return nil, err
+	}
+	if err := nm.saveChapterFile(chapterNum, text, VersionRollback, fmt.Sprintf("回滚到 v%d", version)); err != nil {
+		return nil, err
+	}
+	if _, err := nm.SyncChapterStats(); err != nil {
+		return nil, err
+	}
+
+	nm.historyMutex.Lock()
+	defer nm.historyMutex.Unlock()
+
+	history, err := nm.loadChapterHistory(chapterNum)
+	if err != nil {
+		return nil, err
+	}
+	return history.latest(), nil
+}
+
+// saveChapterFile 写入章节正文并记录版本；写入前若文件已被修改且尚未记录，先把原内容存为一个版本
+func (nm *NovelManager) saveChapterFile(chapterNum int, content, source, note string) error {
+	nm.historyMutex.Lock()
+	defer nm.historyMutex.Unlock()
+
+	history, err := nm.loadChapterHistory(chapterNum)
+	if err != nil {
+		return err
+	}
+	if current, err := nm.ReadChapterText(chapterNum); err == nil {
+		if latest := history.latest(); latest == nil || latest.Digest != versionDigest(current) {
+			if _, err := nm.appendChapterVersion(history, current, VersionEdit, ""); err != nil {
+				return err
+			}
+		}
+	}
+	if err := nm.writeChapterFile(chapterNum, content); err != nil {
+		return err
+	}
+	_, err = nm.appendChapterVersion(history, content, source, note)
+	return err
+}
+
+// recordChapterEdit 同步时发现章节文件有变化，把当前内容记为一个版本；与最新版本相同时跳过
+func (nm *NovelManager) recordChapterEdit(chapterNum int, text string) error {
+	nm.historyMutex.Lock()
+	defer nm.historyMutex.Unlock()
+
+	history, err := nm.loadChapterHistory(chapterNum)
+	if err != nil {
+		return err
+	}
+	if latest := history.latest(); latest != nil && latest.Digest == versionDigest(text) {
+		return nil
+	}
+	_, err = nm.appendChapterVersion(history, text, VersionEdit, "")
+	return err
+}
+
+// appendChapterVersion 保存版本正文并更新索引，调用方需持有 historyMutex
+func (nm *NovelManager) appendChapterVersion(history *chapterHistory, text, source, note string) (*ChapterVersion, error) {
+	version := &ChapterVersion{Number: 1, Time: time.Now(), Words: CountWords(text), Source: source, Note: note, Digest: versionDigest(text)}
+	if latest := history.latest(); latest != nil {
+		version.Number = latest.Number + 1
+		version.Delta = version.Words - latest.Words
+	} else {
+		version.Delta = version.Words
+	}
+	if err := writeFileAtomic(nm.versionFilePath(history.Chapter, version.Number), []byte(text), false); err != nil {
+		return nil, fmt.Errorf("failed to save chapter %d version %d: %w", history.Chapter, version.Number, err)
+	}
+	history.Versions = append(history.Versions, version)
+	for len(history.Versions) > maxChapterVersions {
+		os.Remove(nm.versionFilePath(history.Chapter, history.Versions[0].Number))
+		history.Versions = history.Versions[1:]
+	}
+	return version, nm.saveChapterHistory(history)
+}
+
+// loadChapterHistory 读取章节版本索引，尚无历史时返回空索引，调用方需持有 historyMutex
+func (nm *NovelManager) loadChapterHistory(chapterNum int) (*chapterHistory, error) {
+	history := &chapterHistory{Chapter: chapterNum, Versions: make([]*ChapterVersion, 0)}
+	data, err := os.ReadFile(filepath.Join(nm.historyDir(chapterNum), "index.json"))
+	if os.IsNotExist(err) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chapter %d history: %w", chapterNum, err)
+	}
+	if err := json.Unmarshal(data, history); err != nil {
+		return nil, fmt.Errorf("failed to parse chapter %d history: %w", chapterNum, err)
+	}
+	history.Chapter = chapterNum
+	return history, nil
+}
+
+// saveChapterHistory 调用方需持有 historyMutex
+func (nm *NovelManager) saveChapterHistory(history *chapterHistory) error {
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode chapter %d history: %w", history.Chapter, err)
+	}
+	if err := writeFileAtomic(filepath.Join(nm.historyDir(history.Chapter), "index.json"), data, false); err != nil {
+		return fmt.Errorf("failed to save chapter %d history: %w", history.Chapter, err)
+	}
+	return nil
+}
+
+func (nm *NovelManager) historyDir(chapterNum int) string {
+	return filepath.Join(nm.projectPath, HistoryDir, fmt.Sprintf("chapter_%03d", chapterNum))
+}
+
+func (nm *NovelManager) versionFilePath(chapterNum, version int) string {
+	return filepath.Join(nm.historyDir(chapterNum), fmt.Sprintf("v%04d.txt", version))
+}
+
+// versionDigest 正文指纹，用于跳过内容未变化的保存
+func versionDigest(text string) string {
+	h := fnv.New64a()
+	h.Write([]byte(text))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+func versionSourceLabel(source string) string {
+	return labelOr(map[string]string{
+		VersionEdit:     "修改",
+		VersionGenerate: "生成",
+		VersionImport:   "导入",
+		VersionGlossary: "术语修正",
+		VersionRollback: "回滚",
+		VersionSnapshot: "手动保存",
+	}, source)
+}
+
+// FormatChapterVersions 格式化章节版本列表
+func FormatChapterVersions(chapterNum int, versions []ChapterVersion) string {
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("🕘 === 第%d章 历史版本 ===\n\n", chapterNum))
+	if len(versions) == 0 {
+		result.WriteString("还没有历史版本：章节写入、修改后同步或手动保存时会自动记录\n")
+		return result.String()
+	}
+	for i := len(versions) - 1; i >= 0; i-- {
+		version := versions[i]
+		line := fmt.Sprintf("v%-3d %s  %6d 字（%+d）  %s", version.Number, version.Time.Format("2006-01-02 15:04"),
+			version.Words, version.Delta, versionSourceLabel(version.Source))
+		if version.Note != "" {
+			line += "  " + version.Note
+		}
+		if i == len(versions)-1 {
+			line += "  ← 最新"
+		}
+		result.WriteString(line + "\n")
+	}
+	result.WriteString(fmt.Sprintf("\n共 %d 个版本，可用 chapter_history 的 diff 比较、rollback 回滚\n", len(versions)))
+	return result.String()
+}
@@ -0,0 +1,145 @@
+package novel
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// versionSummary 把版本列表压缩成“编号:来源:字数变化”，便于整体比较
+func versionSummary(t *testing.T, nm *NovelManager, chapterNum int) []string {
+	t.Helper()
+	versions, err := nm.ChapterVersions(chapterNum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := make([]string, 0, len(versions))
+	for _, version := range versions {
+		summary = append(summary, fmt.Sprintf("%d:%s:%+d", version.Number, version.Source, version.Delta))
+	}
+	return summary
+}
+
+func TestSnapshotChapterDedupe(t *testing.T) {
+	nm := newTestManager(t)
+	writeTestChapter(t, nm, 1, "第一稿。")
+	first, err := nm.SnapshotChapter(1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 内容未变时不新增版本，带备注时只更新备注
+	if again, err := nm.SnapshotChapter(1, ""); err != nil || again.Number != first.Number {
+		t.Errorf("SnapshotChapter(unchanged) = %+v, %v", again, err)
+	}
+	if noted, err := nm.SnapshotChapter(1, "初稿"); err != nil || noted.Number != first.Number || noted.Note != "初稿" {
+		t.Errorf("SnapshotChapter(note) = %+v, %v", noted, err)
+	}
+
+	writeTestChapter(t, nm, 1, "第一稿。加了一句。")
+	if _, err := nm.SnapshotChapter(1, "二稿"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := versionSummary(t, nm, 1), []string{"1:snapshot:+3", "2:snapshot:+4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("versions = %v, want %v", got, want)
+	}
+	versions, _ := nm.ChapterVersions(1)
+	if versions[0].Note != "初稿" || versions[1].Note != "二稿" {
+		t.Errorf("notes = %q, %q", versions[0].Note, versions[1].Note)
+	}
+	if _, err := nm.SnapshotChapter(2, ""); err == nil {
+		t.Error("snapshotted a missing chapter")
+	}
+}
+
+func TestSyncRecordsEditsOnce(t *testing.T) {
+	nm := newTestManager(t)
+	writeTestChapter(t, nm, 1, "第一稿。")
+	for i := 0; i < 2; i++ {
+		if _, err := nm.SyncChapterStats(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTestChapter(t, nm, 1, "第二稿，多写了几个字。")
+	if _, err := nm.SyncChapterStats(); err != nil {
+		t.Fatal(err)
+	}
+	// 改回已记录过的内容仍算一次修改，只与最新版本比较
+	writeTestChapter(t, nm, 1, "第一稿。")
+	if _, err := nm.SyncChapterStats(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := versionSummary(t, nm, 1), []string{"1:edit:+3", "2:edit:+6", "3:edit:-6"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("versions = %v, want %v", got, want)
+	}
+}
+
+func TestSaveChapterFileRecordsUnsavedEdit(t *testing.T) {
+	nm := newTestManager(t)
+	if err := nm.saveChapterFile(1, "生成的正文。", VersionGenerate, ""); err != nil {
+		t.Fatal(err)
+	}
+	// 当前正文已是最新版本，不重复记录
+	if err := nm.saveChapterFile(1, "修正后的正文。", VersionGlossary, "统一术语写法"); err != nil {
+		t.Fatal(err)
+	}
+	writeTestChapter(t, nm, 1, "手改过的正文。")
+	if err := nm.saveChapterFile(1, "重新生成的正文。", VersionGenerate, ""); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1:generate:+5", "2:glossary:+1", "3:edit:+0", "4:generate:+1"}
+	if got := versionSummary(t, nm, 1); !reflect.DeepEqual(got, want) {
+		t.Errorf("versions = %v, want %v", got, want)
+	}
+	if text, err := nm.ReadChapterVersion(1, 3); err != nil || text != "手改过的正文。" {
+		t.Errorf("version 3 = %q, %v", text, err)
+	}
+}
+
+func TestChapterHistoryPrunesOldVersions(t *testing.T) {
+	nm := newTestManager(t)
+	nm.historyMutex.Lock()
+	history, err := nm.loadChapterHistory(1)
+	if err == nil {
+		for i := 0; i < maxChapterVersions+2 && err == nil; i++ {
+			_, err = nm.appendChapterVersion(history, fmt.Sprintf("第%d稿。", i), VersionSnapshot, "")
+		}
+	}
+	nm.historyMutex.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := nm.ChapterVersions(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != maxChapterVersions || versions[0].Number != 3 {
+		t.Fatalf("kept %d versions starting at v%d", len(versions), versions[0].Number)
+	}
+	if _, err := nm.ReadChapterVersion(1, 2); err == nil {
+		t.Error("pruned version file still readable")
+	}
+	if text, err := nm.ReadChapterVersion(1, 3); err != nil || text != "第2稿。" {
+		t.Errorf("version 3 = %q, %v", text, err)
+	}
+}
+
+func TestLoadChapterHistoryCorrupt(t *testing.T) {
+	nm := newTestManager(t)
+	dir := nm.historyDir(1)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "index.json"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nm.ChapterVersions(1); err == nil {
+		t.Error("accepted a corrupt history index")
+	}
+	if versions, err := nm.ChapterVersions(2); err != nil || len(versions) != 0 {
+		t.Errorf("ChapterVersions(no history) = %v, %v", versions, err)
+	}
+}
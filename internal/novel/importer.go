@@ -0,0 +1,431 @@
+package novel
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultChapterPattern 默认章节标题：第一章/第12章/第三回/序章/楔子/Chapter 1
+	// 编号之后须是空白、标点或行尾，避免把“第一回合两人交手”“第一节课”之类的正文当成标题
+	DefaultChapterPattern = `^\s*(第[0-9０-９零〇一二两三四五六七八九十百千万]+[章回节]|序章|楔子|引子|尾声|番外[^\s:：]*|[Cc]hapter\s*\d+|CHAPTER\s*\d+)(?:[\s:：、.．\-—]+(.*))?$`
+	// DefaultVolumePattern 默认分卷标题：第一卷/卷一/Volume 1
+	DefaultVolumePattern = `^\s*(第[0-9０-９零〇一二两三四五六七八九十百千万]+卷|卷[0-9０-９零〇一二两三四五六七八九十百千万]+|[Vv]olume\s*\d+|VOLUME\s*\d+)(?:[\s:：、.．\-—]+(.*))?$`
+
+	// 标题行最大长度，超过的行视为正文
+	maxHeadingRunes = 50
+	// 字数低于此值的章节会在预览中提示可能误判
+	suspiciousChapterWords = 100
+)
+
+var headingNumberPattern = regexp.MustCompile(`[0-9０-９零〇一二两三四五六七八九十百千万]+`)
+
+// ImportOptions 导入选项
+type ImportOptions struct {
+	ChapterPattern string // 章节标题正则，第1个分组为标题编号，第2个分组为章节名
+	VolumePattern  string // 分卷标题正则，留空使用默认
+}
+
+// ImportedChapter 识别出的章节
+type ImportedChapter struct {
+	Number    int
+	Title     string
+	Heading   string // 原文的章节标题行，卷名与章名同行时不含卷名
+	Volume    int
+	Line      int // 标题所在行号（从1开始）
+	Content   string
+	WordCount int
+}
+
+// ImportPreview 导入预览结果
+type ImportPreview struct {
+	SourceFile string
+	Volumes    []*Volume
+	Chapters   []*ImportedChapter
+	Preamble   string // 第一个章节标题之前的内容
+	Warnings   []string
+}
+
+// PreviewImport 读取文稿并识别章节边界，不写入任何文件
+func PreviewImport(filePath string, opts ImportOptions) (*ImportPreview, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manuscript: %w", err)
+	}
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("manuscript is not valid UTF-8, please convert it (e.g. from GBK) first")
+	}
+
+	preview, err := ParseManuscript(string(data), opts)
+	if err != nil {
+		return nil, err
+	}
+	preview.SourceFile = filePath
+	return preview, nil
+}
+
+// ParseManuscript 按章节/分卷标题切分整本文稿
+func ParseManuscript(text string, opts ImportOptions) (*ImportPreview, error) {
+	chapterPattern := opts.ChapterPattern
+	if chapterPattern == "" {
+		chapterPattern = DefaultChapterPattern
+	}
+	volumePattern := opts.VolumePattern
+	if volumePattern == "" {
+		volumePattern = DefaultVolumePattern
+	}
+
+	chapterRe, err := regexp.Compile(chapterPattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid chapter pattern: %w", err)
+	}
+	volumeRe, err := regexp.Compile(volumePattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid volume pattern: %w", err)
+	}
+
+	text = strings.TrimPrefix(text, "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	preview := &ImportPreview{
+		Volumes:  make([]*Volume, 0),
+		Chapters: make([]*ImportedChapter, 0),
+		Warnings: make([]string, 0),
+	}
+
+	var current *ImportedChapter
+	var body []string
+	var preamble []string
+	var intro []string // 分卷标题与该卷第一章之间的内容
+	lastNumber := 0
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+		current.Content = strings.Trim(strings.Join(body, "\n"), "\n")
+		current.WordCount = CountWords(current.Content)
+		body = nil
+	}
+	// keep 把非标题行归入当前章节正文、当前分卷简介或全书前置内容
+	keep := func(line string) {
+		switch {
+		case current != nil:
+			body = append(body, line)
+		case len(preview.Volumes) > 0:
+			intro = append(intro, line)
+		default:
+			preamble = append(preamble, line)
+		}
+	}
+	flushIntro := func() {
+		if len(intro) > 0 {
+			preview.Volumes[len(preview.Volumes)-1].Intro = strings.Trim(strings.Join(intro, "\n"), "\n")
+		}
+		intro = nil
+	}
+
+	for i, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || utf8.RuneCountInString(trimmed) > maxHeadingRunes {
+			keep(line)
+			continue
+		}
+
+		rest := trimmed
+		if label, title, ok := matchHeading(volumeRe, trimmed); ok {
+			flush()
+			current = nil
+			flushIntro()
+			if len(preview.Volumes) > 0 {
+				preview.Volumes[len(preview.Volumes)-1].EndChapter = len(preview.Chapters)
+			}
+			volumeTitle := title
+			// 同一行既有卷名又有章节名，如“第一卷 第一章 出山”
+			if chapterRe.MatchString(title) {
+				rest = title
+				volumeTitle = ""
+			} else {
+				rest = ""
+			}
+			if volumeTitle == "" {
+				volumeTitle = label
+			} else {
+				volumeTitle = label + " " + volumeTitle
+			}
+			preview.Volumes = append(preview.Volumes, &Volume{
+				Number:       len(preview.Volumes) + 1,
+				Title:        volumeTitle,
+				StartChapter: len(preview.Chapters) + 1,
+			})
+			lastNumber = 0
+			if rest == "" {
+				continue
+			}
+		}
+
+		label, title, ok := matchHeading(chapterRe, rest)
+		if !ok {
+			keep(line)
+			continue
+		}
+
+		flush()
+		flushIntro()
+		if title == "" {
+			title = label
+		}
+		current = &ImportedChapter{
+			Number:  len(preview.Chapters) + 1,
+			Title:   title,
+			Heading: rest,
+			Volume:  len(preview.Volumes),
+			Line:    i + 1,
+		}
+		preview.Chapters = append(preview.Chapters, current)
+
+		if n, ok := headingNumber(label); ok {
+			if lastNumber > 0 && n != lastNumber+1 {
+				preview.Warnings = append(preview.Warnings,
+					fmt.Sprintf("第%d行「%s」编号不连续（上一章编号为%d）", i+1, trimmed, lastNumber))
+			}
+			lastNumber = n
+		}
+	}
+	flush()
+	flushIntro()
+
+	if len(preview.Volumes) > 0 {
+		preview.Volumes[len(preview.Volumes)-1].EndChapter = len(preview.Chapters)
+	}
+	preview.Preamble = strings.Trim(strings.Join(preamble, "\n"), "\n")
+
+	if len(preview.Chapters) == 0 {
+		return nil, fmt.Errorf("no chapter headings detected, try a custom chapter pattern")
+	}
+	for _, chapter := range preview.Chapters {
+		if chapter.WordCount < suspiciousChapterWords {
+			preview.Warnings = append(preview.Warnings,
+				fmt.Sprintf("第%d行「%s」仅%d字，可能是误识别的标题", chapter.Line, chapter.Heading, chapter.WordCount))
+		}
+	}
+
+	return preview, nil
+}
+
+// Format 生成预览文本，limit 为最多列出的章节数（0 表示全部）
+func (p *ImportPreview) Format(limit int) string {
+	var result strings.Builder
+	totalWords := 0
+	for _, chapter := range p.Chapters {
+		totalWords += chapter.WordCount
+	}
+
+	result.WriteString("📥 === 文稿导入预览 ===\n\n")
+	if p.SourceFile != "" {
+		result.WriteString(fmt.Sprintf("源文件: %s\n", p.SourceFile))
+	}
+	result.WriteString(fmt.Sprintf("识别结果: %d 卷, %d 章, 共 %d 字\n", len(p.Volumes), len(p.Chapters), totalWords))
+	if p.Preamble != "" {
+		result.WriteString(fmt.Sprintf("前置内容: %d 字（将保存为 %s/preamble.txt）\n", CountWords(p.Preamble), ChaptersDir))
+	}
+	result.WriteString("\n")
+
+	volume := -1
+	for i, chapter := range p.Chapters {
+		if limit > 0 && i >= limit {
+			result.WriteString(fmt.Sprintf("  ... 其余 %d 章省略\n", len(p.Chapters)-limit))
+			break
+		}
+		if chapter.Volume != volume {
+			volume = chapter.Volume
+			if volume > 0 {
+				result.WriteString(fmt.Sprintf("📚 %s\n", p.Volumes[volume-1].Title))
+			}
+		}
+		result.WriteString(fmt.Sprintf("  %3d. [行%d] %s（%d字）\n", chapter.Number, chapter.Line, chapter.Heading, chapter.WordCount))
+	}
+
+	if len(p.Warnings) > 0 {
+		result.WriteString("\n⚠️ 需要确认:\n")
+		for _, warning := range p.Warnings {
+			result.WriteString(fmt.Sprintf("  • %s\n", warning))
+		}
+	}
+
+	return result.String()
+}
+
+// ApplyImport 将预览结果写入章节文件并更新项目章节列表
+func (nm *NovelManager) ApplyImport(preview *ImportPreview, overwrite bool) error {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	if nm.loadErr != nil {
+		return fmt.Errorf("existing project could not be loaded: %w", nm.loadErr)
+	}
+	if nm.novelData == nil {
+		title := strings.TrimSuffix(filepath.Base(preview.SourceFile), filepath.Ext(preview.SourceFile))
+		if title == "" || title == "." {
+			title = "未命名小说"
+		}
+		nm.novelData = newNovelProject(title, "Unknown Author", "Fiction")
+	}
+	if len(nm.novelData.Chapters) > 0 && !overwrite {
+		return fmt.Errorf("project already has %d chapters, set overwrite to replace them", len(nm.novelData.Chapters))
+	}
+
+	if overwrite {
+		if err := nm.archiveStaleChapters(preview); err != nil {
+			return err
+		}
+	}
+
+	chapters := make([]*Chapter, 0, len(preview.Chapters))
+	for _, imported := range preview.Chapters {
+		if err := nm.saveChapterFile(imported.Number, imported.Content+"\n", VersionImport, ""); err != nil {
+			return err
+		}
+		chapters = append(chapters, &Chapter{
+			Number:     imported.Number,
+			Title:      imported.Title,
+			Heading:    imported.Heading,
+			Volume:     imported.Volume,
+			WordCount:  imported.WordCount,
+			Status:     "completed",
+			WrittenAt:  time.Now(),
+			Characters: make([]string, 0),
+			PlotLines:  make([]string, 0),
+			KeyEvents:  make([]string, 0),
+			Emotions:   make([]string, 0),
+		})
+	}
+
+	if preview.Preamble != "" {
+		preamblePath := filepath.Join(nm.projectPath, ChaptersDir, "preamble.txt")
+		if err := writeFileAtomic(preamblePath, []byte(preview.Preamble+"\n"), false); err != nil {
+			return fmt.Errorf("failed to write preamble: %w", err)
+		}
+	}
+
+	nm.novelData.Chapters = chapters
+	nm.novelData.Volumes = preview.Volumes
+	nm.novelData.CurrentChapter = len(chapters)
+
+	if err := nm.SaveProject(); err != nil {
+		return err
+	}
+	return nm.writer.flush()
+}
+
+// archiveStaleChapters 覆盖导入前移走新文稿中没有的旧章节文件：正文先存入章节历史版本再删除，
+// 避免同步时被重新登记成草稿、与新文稿混在一起。调用方需持有锁
+func (nm *NovelManager) archiveStaleChapters(preview *ImportPreview) error {
+	keep := make(map[int]bool, len(preview.Chapters))
+	for _, imported := range preview.Chapters {
+		keep[imported.Number] = true
+	}
+	numbers, err := nm.listChapterNumbers()
+	if err != nil {
+		return err
+	}
+	for _, number := range numbers {
+		if keep[number] {
+			continue
+		}
+		text, err := nm.ReadChapterText(number)
+		if err != nil {
+			return err
+		}
+		if err := nm.recordChapterEdit(number, text); err != nil {
+			return err
+		}
+		if err := os.Remove(nm.ChapterFilePath(number)); err != nil {
+			return fmt.Errorf("failed to remove stale chapter %d: %w", number, err)
+		}
+	}
+	if preview.Preamble == "" {
+		if err := os.Remove(filepath.Join(nm.projectPath, ChaptersDir, "preamble.txt")); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove stale preamble: %w", err)
+		}
+	}
+	return nil
+}
+
+// matchHeading 匹配标题行，返回编号部分与标题部分
+func matchHeading(re *regexp.Regexp, line string) (string, string, bool) {
+	match := re.FindStringSubmatchIndex(line)
+	if match == nil || match[0] != 0 {
+		return "", "", false
+	}
+
+	label := line[match[0]:match[1]]
+	title := strings.TrimSpace(line[match[1]:])
+	if re.NumSubexp() >= 1 && match[2] >= 0 {
+		label = line[match[2]:match[3]]
+	}
+	if re.NumSubexp() >= 2 && match[4] >= 0 {
+		title = line[match[4]:match[5]]
+	}
+
+	return strings.TrimSpace(label), strings.Trim(title, " \t:：、.．-—"), true
+}
+
+// headingNumber 从标题编号中解析数字（支持中文数字）
+func headingNumber(label string) (int, bool) {
+	digits := headingNumberPattern.FindString(label)
+	if digits == "" {
+		return 0, false
+	}
+	return parseChineseNumber(digits)
+}
+
+// parseChineseNumber 解析阿拉伯数字或中文数字，如“12”“十二”“一百零三”
+func parseChineseNumber(s string) (int, bool) {
+	s = strings.Map(func(r rune) rune {
+		if r >= '０' && r <= '９' {
+			return r - '０' + '0'
+		}
+		return r
+	}, s)
+	if n, err := strconv.Atoi(s); err == nil {
+		return n, true
+	}
+
+	digits := map[rune]int{
+		'零': 0, '〇': 0, '一': 1, '二': 2, '两': 2, '三': 3, '四': 4,
+		'五': 5, '六': 6, '七': 7, '八': 8, '九': 9,
+	}
+	units := map[rune]int{'十': 10, '百': 100, '千': 1000}
+
+	total, section, num := 0, 0, 0
+	for _, r := range s {
+		if d, ok := digits[r]; ok {
+			num = d
+			continue
+		}
+		if unit, ok := units[r]; ok {
+			if num == 0 {
+				num = 1
+			}
+			section += num * unit
+			num = 0
+			continue
+		}
+		if r == '万' {
+			total += (section + num) * 10000
+			section, num = 0, 0
+			continue
+		}
+		return 0, false
+	}
+
+	return total + section + num, true
+}
@@ -0,0 +1,287 @@
+package novel
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestParseChineseNumber(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   int
+		wantOK bool
+	}{
+		{"12", 12, true},
+		{"１２", 12, true},
+		{"零", 0, true},
+		{"十", 10, true},
+		{"十二", 12, true},
+		{"二十", 20, true},
+		{"一百零三", 103, true},
+		{"两千零一十", 2010, true},
+		{"一万二千", 12000, true},
+		{"三万零五", 30005, true},
+		{"第一", 0, false},
+		{"abc", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseChineseNumber(tt.in)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseChineseNumber(%q) = %d, %v, want %d, %v", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestParseManuscript(t *testing.T) {
+	// 足够长的正文，避免触发“可能是误识别的标题”提示
+	body := strings.Repeat("他沿着山路走了很久。", 12)
+
+	tests := []struct {
+		name         string
+		text         string
+		opts         ImportOptions
+		wantTitles   []string
+		wantVolumes  []string
+		wantChapterV []int // 每章所属分卷
+		wantPreamble string
+		wantIntros   []string // 每卷的卷首内容
+		wantGap      bool     // 是否提示编号不连续
+		wantErr      bool
+	}{
+		{
+			name:         "前置内容与章节",
+			text:         "作者的话\n\n第一章 出山\n" + body + "\n第二章：下山\n" + body,
+			wantTitles:   []string{"出山", "下山"},
+			wantChapterV: []int{0, 0},
+			wantPreamble: "作者的话",
+		},
+		{
+			name:         "分卷后章节编号重新开始",
+			text:         "第一卷 风起\n第一章 初见\n" + body + "\n第二卷\n第一章 重逢\n" + body,
+			wantTitles:   []string{"初见", "重逢"},
+			wantVolumes:  []string{"第一卷 风起", "第二卷"},
+			wantChapterV: []int{1, 2},
+		},
+		{
+			name:         "卷名和章名在同一行",
+			text:         "第一卷 第一章 出山\n" + body + "\n第二章 下山\n" + body,
+			wantTitles:   []string{"出山", "下山"},
+			wantVolumes:  []string{"第一卷"},
+			wantChapterV: []int{1, 1},
+		},
+		{
+			name:         "分卷标题与第一章之间的内容归入该卷",
+			text:         "作者的话\n第一卷 风起\n本卷讲少年出山。\n\n第一章 初见\n" + body + "\n第二卷 云涌\n第一章 重逢\n" + body,
+			wantTitles:   []string{"初见", "重逢"},
+			wantVolumes:  []string{"第一卷 风起", "第二卷 云涌"},
+			wantChapterV: []int{1, 2},
+			wantPreamble: "作者的话",
+			wantIntros:   []string{"本卷讲少年出山。", ""},
+		},
+		{
+			name:         "编号不连续",
+			text:         "第1章 开端\n" + body + "\n第3章 跳过\n" + body,
+			wantTitles:   []string{"开端", "跳过"},
+			wantChapterV: []int{0, 0},
+			wantGap:      true,
+		},
+		{
+			name:         "BOM、CRLF 与没有章名的标题",
+			text:         "\ufeff楔子\r\n" + body + "\r\nChapter 1\r\n" + body,
+			wantTitles:   []string{"楔子", "Chapter 1"},
+			wantChapterV: []int{0, 0},
+		},
+		{
+			name:         "自定义章节正则",
+			text:         "== 1 == 雨夜\n" + body + "\n== 2 == 天明\n" + body,
+			opts:         ImportOptions{ChapterPattern: `^==\s*(\d+)\s*==\s*(.*)$`},
+			wantTitles:   []string{"雨夜", "天明"},
+			wantChapterV: []int{0, 0},
+		},
+		{
+			name:    "没有章节标题",
+			text:    body,
+			wantErr: true,
+		},
+		{
+			name:    "无效的正则",
+			text:    body,
+			opts:    ImportOptions{ChapterPattern: `(`},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preview, err := ParseManuscript(tt.text, tt.opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %d chapters", len(preview.Chapters))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseManuscript: %v", err)
+			}
+
+			titles := make([]string, 0, len(preview.Chapters))
+			volumes := make([]int, 0, len(preview.Chapters))
+			for i, chapter := range preview.Chapters {
+				titles = append(titles, chapter.Title)
+				volumes = append(volumes, chapter.Volume)
+				if chapter.Number != i+1 {
+					t.Errorf("chapter %d numbered %d", i+1, chapter.Number)
+				}
+				if chapter.Content != body {
+					t.Errorf("chapter %d content = %q", i+1, chapter.Content)
+				}
+			}
+			if !reflect.DeepEqual(titles, tt.wantTitles) {
+				t.Errorf("titles = %q, want %q", titles, tt.wantTitles)
+			}
+			if !reflect.DeepEqual(volumes, tt.wantChapterV) {
+				t.Errorf("chapter volumes = %v, want %v", volumes, tt.wantChapterV)
+			}
+
+			volumeTitles := make([]string, 0, len(preview.Volumes))
+			intros := make([]string, 0, len(preview.Volumes))
+			for _, volume := range preview.Volumes {
+				volumeTitles = append(volumeTitles, volume.Title)
+				intros = append(intros, volume.Intro)
+			}
+			if tt.wantIntros != nil && !reflect.DeepEqual(intros, tt.wantIntros) {
+				t.Errorf("volume intros = %q, want %q", intros, tt.wantIntros)
+			}
+			if len(tt.wantVolumes) > 0 || len(volumeTitles) > 0 {
+				if !reflect.DeepEqual(volumeTitles, tt.wantVolumes) {
+					t.Errorf("volumes = %q, want %q", volumeTitles, tt.wantVolumes)
+				}
+			}
+			if preview.Preamble != tt.wantPreamble {
+				t.Errorf("preamble = %q, want %q", preview.Preamble, tt.wantPreamble)
+			}
+
+			gap := false
+			for _, warning := range preview.Warnings {
+				if strings.Contains(warning, "编号不连续") {
+					gap = true
+				} else {
+					t.Errorf("unexpected warning: %s", warning)
+				}
+			}
+			if gap != tt.wantGap {
+				t.Errorf("numbering gap warning = %v, want %v", gap, tt.wantGap)
+			}
+		})
+	}
+}
+
+func TestDefaultHeadingPatterns(t *testing.T) {
+	chapterRe := regexp.MustCompile(DefaultChapterPattern)
+	volumeRe := regexp.MustCompile(DefaultVolumePattern)
+	tests := []struct {
+		line      string
+		re        *regexp.Regexp
+		wantLabel string
+		wantTitle string
+		wantOK    bool
+	}{
+		{"第一章 出山", chapterRe, "第一章", "出山", true},
+		{"第12章：下山", chapterRe, "第12章", "下山", true},
+		{"第三回", chapterRe, "第三回", "", true},
+		{"楔子 风起", chapterRe, "楔子", "风起", true},
+		{"Chapter 7 - Rain", chapterRe, "Chapter 7", "Rain", true},
+		{"番外·师父", chapterRe, "番外·师父", "", true},
+		{"第一回合两人交手", chapterRe, "", "", false},
+		{"第一节课下课后", chapterRe, "", "", false},
+		{"第三章节里写道", chapterRe, "", "", false},
+		{"序章之后便是正文", chapterRe, "", "", false},
+		{"第二卷 第一章 回乡", volumeRe, "第二卷", "第一章 回乡", true},
+		{"卷一", volumeRe, "卷一", "", true},
+		{"第一卷书终于读完了", volumeRe, "", "", false},
+	}
+	for _, tt := range tests {
+		label, title, ok := matchHeading(tt.re, tt.line)
+		if label != tt.wantLabel || title != tt.wantTitle || ok != tt.wantOK {
+			t.Errorf("matchHeading(%q) = %q, %q, %v, want %q, %q, %v", tt.line, label, title, ok, tt.wantLabel, tt.wantTitle, tt.wantOK)
+		}
+	}
+
+	preview, err := ParseManuscript("第一章 出山\n第一回合两人交手，未分胜负。\n第一节课下课后，他去了后山。", ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(preview.Chapters) != 1 || !strings.Contains(preview.Chapters[0].Content, "第一节课下课后") {
+		t.Errorf("body lines were split into chapters: %d chapters", len(preview.Chapters))
+	}
+}
+
+func TestApplyImportOverwriteArchivesStaleChapters(t *testing.T) {
+	nm := newTestManager(t)
+	first, err := ParseManuscript("第一章 开端\n旧稿正文\n第二章 发展\n旧稿正文\n第三章 高潮\n旧稿正文", ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := nm.ApplyImport(first, false); err != nil {
+		t.Fatalf("ApplyImport: %v", err)
+	}
+
+	preview, err := ParseManuscript("第一章 新的开始\n新稿正文\n第二章 继续\n新稿正文", ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := nm.ApplyImport(preview, false); err == nil {
+		t.Fatal("expected import without overwrite to be refused")
+	}
+	if err := nm.ApplyImport(preview, true); err != nil {
+		t.Fatalf("ApplyImport: %v", err)
+	}
+
+	if _, err := os.Stat(nm.ChapterFilePath(3)); !os.IsNotExist(err) {
+		t.Errorf("stale chapter 3 should be removed, stat err = %v", err)
+	}
+	versions, err := nm.ChapterVersions(3)
+	if err != nil || len(versions) == 0 {
+		t.Fatalf("stale chapter 3 should be archived in history: %v, %d versions", err, len(versions))
+	}
+	if text, err := nm.ReadChapterVersion(3, versions[len(versions)-1].Number); err != nil || text != "旧稿正文\n" {
+		t.Errorf("archived chapter 3 = %q, %v", text, err)
+	}
+
+	if numbers, err := nm.listChapterNumbers(); err != nil || !reflect.DeepEqual(numbers, []int{1, 2}) {
+		t.Errorf("chapter files = %v, %v, want [1 2]", numbers, err)
+	}
+	if count := nm.ChapterCount(); count != 2 {
+		t.Errorf("chapter count = %d, want 2", count)
+	}
+	if text, err := nm.ReadChapterText(1); err != nil || text != "新稿正文\n" {
+		t.Errorf("chapter 1 = %q, %v", text, err)
+	}
+}
+
+func TestApplyImportKeepsHeadingsAndPreamble(t *testing.T) {
+	nm := newTestManager(t)
+	preview, err := ParseManuscript("作者的话\n楔子 风起\n正文\n第二卷 第一章 回乡\n正文", ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := nm.ApplyImport(preview, false); err != nil {
+		t.Fatalf("ApplyImport: %v", err)
+	}
+
+	headings := make([]string, 0)
+	for _, chapter := range nm.novelData.Chapters {
+		headings = append(headings, chapter.Heading)
+	}
+	if want := []string{"楔子 风起", "第一章 回乡"}; !reflect.DeepEqual(headings, want) {
+		t.Errorf("headings = %q, want %q", headings, want)
+	}
+	data, err := os.ReadFile(filepath.Join(nm.ProjectPath(), ChaptersDir, "preamble.txt"))
+	if err != nil || string(data) != "作者的话\n" {
+		t.Errorf("preamble.txt = %q, %v", data, err)
+	}
+}
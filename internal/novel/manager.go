@@ -1,9 +1,9 @@
 package novel
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -14,9 +14,16 @@ import (
 type NovelManager struct {
 	projectPath    string
 	novelData      *NovelProject
-	chatHistory    []ChatRecord
+	chatLog        *chatLog
+	legacyChat     []ChatRecord // 旧版本 chat_history.json，仅在升级时使用
 	contentIndex   *ContentIndex
 	mutex          sync.RWMutex
+	historyMutex   sync.Mutex // 章节历史版本的读写，与 mutex 同时持有时须先取 mutex
+	retrieval      *retrievalIndex
+	embedder       Embedder
+	writer         *projectWriter
+	recoveryNotes  []string
+	loadErr        error // 已有项目无法加载时阻止覆盖
 }
 
 // NovelProject 小说项目数据
@@ -31,18 +38,27 @@ type NovelProject struct {
 	Characters    map[string]*Character    `json:"characters"`
 	WorldSettings map[string]*WorldSetting `json:"world_settings"`
 	PlotLines     map[string]*PlotLine     `json:"plot_lines"`
+	Glossary      map[string]*GlossaryTerm `json:"glossary,omitempty"` // 标准术语 -> 条目
+	Archetypes    []*ArchetypeSlot         `json:"archetypes,omitempty"` // 题材模板预置的角色定位
+	Timeline      *Timeline                `json:"timeline,omitempty"`
+	Relationships []*RelationshipEdge      `json:"relationships,omitempty"` // 人物关系变化历史
 	
 	// 章节管理
 	Chapters      []*Chapter        `json:"chapters"`
+	Volumes       []*Volume         `json:"volumes"`
 	CurrentChapter int              `json:"current_chapter"`
 	
 	// 写作设置
 	WritingStyle  WritingStyle      `json:"writing_style"`
+	StyleProfile  *StyleProfile     `json:"style_profile,omitempty"` // 从样本章节提取的文风画像
 	TargetWords   int               `json:"target_words"`
+	Progress      *WritingProgress  `json:"progress,omitempty"`
+	Outline       *BookOutline      `json:"outline,omitempty"` // 由节拍模板生成的全书大纲
 	
 	// 元数据
 	Tags          []string          `json:"tags"`
 	Notes         []string          `json:"notes"`
+	SchemaVersion int               `json:"schema_version"` // 数据结构版本，用于升级旧项目
 }
 
 // Character 角色设定
@@ -54,13 +70,18 @@ type Character struct {
 	Personality   []string          `json:"personality"`
 	Appearance    string            `json:"appearance"`
 	Background    string            `json:"background"`
-	Relationships map[string]string `json:"relationships"`
+	Aliases       []string          `json:"aliases,omitempty"` // 别名与称谓，如 "林师兄"、"少主"
+	Relationships map[string]string `json:"relationships"` // 对方名字 -> 对方相对本角色的身份，如 "师父"
+	Status        string            `json:"status,omitempty"`        // alive, dead, missing
+	DeathChapter  int               `json:"death_chapter,omitempty"` // 死亡所在章节号
+	Birth         *int              `json:"birth,omitempty"`         // 出生日（故事内自纪元起的天数）
 	
 	// 角色发展
 	CharacterArc  []string          `json:"character_arc"`
-	KeyDialogues  []string          `json:"key_dialogues"`
-	FirstAppeared int               `json:"first_appeared"` // 章节号
-	LastAppeared  int               `json:"last_appeared"`
+	KeyDialogues  []string          `json:"key_dialogues"` // 由台词提取挑选的代表台词
+	Voice         *VoiceProfile     `json:"voice,omitempty"` // 由台词提取统计的说话习惯
+	FirstAppeared int               `json:"first_appeared"` // 章节号，未手动设定时由正文扫描得出
+	LastAppeared  int               `json:"last_appeared"`  // 由正文扫描得出
 }
 
 // WorldSetting 世界观设定
@@ -78,10 +99,11 @@ type PlotLine struct {
 	Name         string     `json:"name"`
 	Type         string     `json:"type"` // main, sub, romance, mystery等
 	Status       string     `json:"status"` // active, resolved, suspended
+	Description  string     `json:"description,omitempty"`
 	StartChapter int        `json:"start_chapter"`
 	EndChapter   int        `json:"end_chapter"`
 	KeyEvents    []PlotEvent `json:"key_events"`
-	Foreshadowing []string   `json:"foreshadowing"` // 伏笔
+	Foreshadowing []*Foreshadowing `json:"foreshadowing"` // 伏笔
 }
 
 // PlotEvent 情节事件
@@ -97,13 +119,17 @@ type PlotEvent struct {
 type Chapter struct {
 	Number      int       `json:"number"`
 	Title       string    `json:"title"`
+	Heading     string    `json:"heading,omitempty"` // 导入时原文的标题行，如 "楔子 风起"；导出时原样使用
+	Volume      int       `json:"volume,omitempty"` // 所属分卷编号
 	Summary     string    `json:"summary"`
 	WordCount   int       `json:"word_count"`
 	Status      string    `json:"status"` // draft, reviewing, completed
 	WrittenAt   time.Time `json:"written_at"`
 	
 	// 内容分析
-	Characters  []string  `json:"characters"` // 本章出现的角色
+	Characters  []string  `json:"characters"` // 本章出现的角色，有正文时由扫描得出
+	POVCharacter string   `json:"pov_character,omitempty"` // 第三人称有限视角下的视角人物
+	CastDigest  string    `json:"cast_digest,omitempty"` // 上次扫描出场角色时的正文指纹
 	PlotLines   []string  `json:"plot_lines"` // 本章涉及的情节线
 	KeyEvents   []string  `json:"key_events"` // 本章关键事件
 	Emotions    []string  `json:"emotions"`   // 情感基调
@@ -140,25 +166,22 @@ type Mentions struct {
 	Chapters      []int    `json:"chapters"`
 }
 
-// ContentIndex 内容索引
+// ContentIndex 内容索引，由聊天记录日志在加载时重建
 type ContentIndex struct {
-	CharacterIndex    map[string][]int `json:"character_index"`    // 角色名 -> 出现的聊天记录ID
-	SettingIndex      map[string][]int `json:"setting_index"`      // 设定 -> 相关聊天记录
-	PlotIndex         map[string][]int `json:"plot_index"`         // 情节 -> 相关记录
-	KeywordIndex      map[string][]int `json:"keyword_index"`      // 关键词 -> 记录
+	CharacterIndex    map[string][]string `json:"character_index"`    // 角色名 -> 出现的聊天记录ID
+	SettingIndex      map[string][]string `json:"setting_index"`      // 设定 -> 相关聊天记录ID
+	PlotIndex         map[string][]string `json:"plot_index"`         // 情节 -> 相关记录ID
 }
 
 // NewNovelManager 创建小说管理器
 func NewNovelManager(projectPath string) *NovelManager {
 	return &NovelManager{
 		projectPath:  projectPath,
-		chatHistory:  make([]ChatRecord, 0),
-		contentIndex: &ContentIndex{
-			CharacterIndex: make(map[string][]int),
-			SettingIndex:   make(map[string][]int),
-			PlotIndex:      make(map[string][]int),
-			KeywordIndex:   make(map[string][]int),
-		},
+		chatLog:      newChatLog(filepath.Join(projectPath, ChatDir)),
+		contentIndex: newContentIndex(),
+		retrieval: &retrievalIndex{},
+		embedder:  HashingEmbedder{},
+		writer:    newProjectWriter(),
 	}
 }
 
@@ -167,7 +190,17 @@ func (nm *NovelManager) InitializeProject(title, author, genre string) error {
 	nm.mutex.Lock()
 	defer nm.mutex.Unlock()
 	
-	nm.novelData = &NovelProject{
+	if nm.loadErr != nil {
+		return fmt.Errorf("existing project could not be loaded: %w", nm.loadErr)
+	}
+	nm.novelData = newNovelProject(title, author, genre)
+	
+	return nm.SaveProject()
+}
+
+// newNovelProject 创建带默认设置的空项目
+func newNovelProject(title, author, genre string) *NovelProject {
+	return &NovelProject{
 		Title:         title,
 		Author:        author,
 		Genre:         genre,
@@ -177,6 +210,7 @@ func (nm *NovelManager) InitializeProject(title, author, genre string) error {
 		WorldSettings: make(map[string]*WorldSetting),
 		PlotLines:     make(map[string]*PlotLine),
 		Chapters:      make([]*Chapter, 0),
+		Volumes:       make([]*Volume, 0),
 		CurrentChapter: 0,
 		WritingStyle: WritingStyle{
 			Perspective: "third_limited",
@@ -186,77 +220,70 @@ func (nm *NovelManager) InitializeProject(title, author, genre string) error {
 			ToneKeywords: make([]string, 0),
 		},
 		TargetWords: 100000,
+		Progress:    &WritingProgress{Daily: make([]*DailyProgress, 0)},
 		Tags:        make([]string, 0),
 		Notes:       make([]string, 0),
+		SchemaVersion: CurrentSchemaVersion,
 	}
-	
-	return nm.SaveProject()
 }
 
-// LoadProject 加载项目
+// LoadProject 加载项目，文件不完整时从临时文件或备份恢复，旧版本数据自动升级
 func (nm *NovelManager) LoadProject() error {
 	nm.mutex.Lock()
 	defer nm.mutex.Unlock()
 	
-	projectFile := filepath.Join(nm.projectPath, "novel_project.json")
-	historyFile := filepath.Join(nm.projectPath, "chat_history.json")
-	indexFile := filepath.Join(nm.projectPath, "content_index.json")
+	nm.recoveryNotes = nil
+	nm.loadErr = nil
+	// 重新加载（如版本回退后）时不沿用内存中的旧数据
+	nm.novelData = nil
 	
 	// 加载项目数据
-	if data, err := os.ReadFile(projectFile); err == nil {
+	data, err := nm.readProjectFile(ProjectFile)
+	if err != nil {
+		return err
+	}
+	if data != nil {
 		if err := json.Unmarshal(data, &nm.novelData); err != nil {
 			return fmt.Errorf("failed to parse project file: %w", err)
 		}
 	}
 	
-	// 加载聊天历史
-	if data, err := os.ReadFile(historyFile); err == nil {
-		if err := json.Unmarshal(data, &nm.chatHistory); err != nil {
-			return fmt.Errorf("failed to parse chat history: %w", err)
-		}
+	// 加载聊天记录日志
+	notes, err := nm.chatLog.open()
+	if err != nil {
+		return err
 	}
+	nm.recoveryNotes = append(nm.recoveryNotes, notes...)
 	
-	// 加载内容索引
-	if data, err := os.ReadFile(indexFile); err == nil {
-		if err := json.Unmarshal(data, &nm.contentIndex); err != nil {
-			return fmt.Errorf("failed to parse content index: %w", err)
+	// 旧版本的整体聊天历史，升级时迁入日志
+	nm.legacyChat = nil
+	if nm.novelData != nil && nm.novelData.SchemaVersion < chatLogSchemaVersion {
+		if data, err = nm.readProjectFile(ChatHistoryFile); err != nil {
+			return err
+		}
+		if data != nil {
+			if err := json.Unmarshal(data, &nm.legacyChat); err != nil {
+				return fmt.Errorf("failed to parse chat history: %w", err)
+			}
 		}
 	}
 	
-	return nil
-}
-
-// SaveProject 保存项目
-func (nm *NovelManager) SaveProject() error {
-	// 确保目录存在
-	if err := os.MkdirAll(nm.projectPath, 0755); err != nil {
-		return fmt.Errorf("failed to create project directory: %w", err)
-	}
-	
-	// 保存项目数据
 	if nm.novelData != nil {
-		nm.novelData.LastModified = time.Now()
-		projectFile := filepath.Join(nm.projectPath, "novel_project.json")
-		data, _ := json.MarshalIndent(nm.novelData, "", "  ")
-		if err := os.WriteFile(projectFile, data, 0644); err != nil {
-			return fmt.Errorf("failed to save project file: %w", err)
+		if err := nm.migrateProject(); err != nil {
+			// 不以无法识别的结构继续工作，避免保存时覆盖原项目
+			nm.novelData = nil
+			nm.loadErr = err
+			nm.recoveryNotes = append(nm.recoveryNotes, fmt.Sprintf("无法加载项目: %v", err))
+			return err
 		}
 	}
 	
-	// 保存聊天历史
-	historyFile := filepath.Join(nm.projectPath, "chat_history.json")
-	historyData, _ := json.MarshalIndent(nm.chatHistory, "", "  ")
-	if err := os.WriteFile(historyFile, historyData, 0644); err != nil {
-		return fmt.Errorf("failed to save chat history: %w", err)
-	}
-	
-	// 保存内容索引
-	indexFile := filepath.Join(nm.projectPath, "content_index.json")
-	indexData, _ := json.MarshalIndent(nm.contentIndex, "", "  ")
-	if err := os.WriteFile(indexFile, indexData, 0644); err != nil {
-		return fmt.Errorf("failed to save content index: %w", err)
+	if nm.chatLog.needsCompaction() {
+		if _, err := nm.compactChatLog(); err != nil {
+			return err
+		}
 	}
-	
+	nm.rebuildContentIndex()
 	return nil
 }
 
@@ -265,9 +292,10 @@ func (nm *NovelManager) AddChatRecord(userMsg, aiResponse string, chapterNum int
 	nm.mutex.Lock()
 	defer nm.mutex.Unlock()
 	
+	now := time.Now()
 	record := ChatRecord{
-		ID:          fmt.Sprintf("chat_%d", time.Now().Unix()),
-		Timestamp:   time.Now(),
+		ID:          nm.newChatID(now),
+		Timestamp:   now,
 		ChapterNum:  chapterNum,
 		UserMessage: userMsg,
 		AIResponse:  aiResponse,
@@ -276,57 +304,111 @@ func (nm *NovelManager) AddChatRecord(userMsg, aiResponse string, chapterNum int
 		Decisions:   nm.extractDecisions(aiResponse),
 	}
 	
-	nm.chatHistory = append(nm.chatHistory, record)
+	if err := nm.chatLog.put(record, false); err != nil {
+		return err
+	}
 	nm.updateContentIndex(record)
 	
-	// 自动保存
-	go nm.SaveProject()
-	
 	return nil
 }
 
-// GetRelevantHistory 获取相关历史记录
+// GetRelevantHistory 获取相关历史记录，优先使用检索索引，索引不可用时退回关键词匹配
 func (nm *NovelManager) GetRelevantHistory(query string, maxRecords int) []ChatRecord {
+	ctx, cancel := context.WithTimeout(context.Background(), relatedPassagesTimeout)
+	defer cancel()
+	
+	if hits, err := nm.Search(ctx, query, SearchOptions{Limit: maxRecords * 3, Sources: []string{SourceChat}}); err == nil {
+		nm.mutex.RLock()
+		defer nm.mutex.RUnlock()
+		
+		relevantRecords := make([]ChatRecord, 0)
+		seen := make(map[string]bool)
+		for _, hit := range hits {
+			id, ok := chatRecordID(hit.Chunk.Key)
+			if !ok || seen[id] {
+				continue
+			}
+			seen[id] = true
+			if record, ok, err := nm.chatLog.get(id); err == nil && ok {
+				relevantRecords = append(relevantRecords, record)
+			}
+			if len(relevantRecords) >= maxRecords {
+				break
+			}
+		}
+		return relevantRecords
+	}
+	
 	nm.mutex.RLock()
 	defer nm.mutex.RUnlock()
 	
-	relevantRecords := make([]ChatRecord, 0)
-	queryLower := strings.ToLower(query)
-	
 	// 搜索算法：关键词匹配 + 时间权重
-	for i := len(nm.chatHistory) - 1; i >= 0 && len(relevantRecords) < maxRecords; i-- {
-		record := nm.chatHistory[i]
-		
+	queryLower := strings.ToLower(query)
+	relevantRecords, _ := nm.chatLog.findRecords(func(record ChatRecord) bool {
 		content := strings.ToLower(record.UserMessage + " " + record.AIResponse)
-		if strings.Contains(content, queryLower) {
-			relevantRecords = append(relevantRecords, record)
-		}
-	}
+		return strings.Contains(content, queryLower)
+	}, maxRecords)
 	
 	return relevantRecords
 }
 
 // GetChapterContext 获取章节上下文
 func (nm *NovelManager) GetChapterContext(chapterNum int) string {
+	base, query, ok := nm.chapterContext(chapterNum)
+	if !ok {
+		return base
+	}
+	
+	// 检索与本章相关的前文，检索期间不持有项目锁
+	if related := nm.relatedPassages(chapterNum, query, 5); related != "" {
+		return base + "\n=== 相关前文 ===\n" + related
+	}
+	return base
+}
+
+// chapterContext 生成章节上下文的基础部分，并返回用于检索相关前文的查询
+func (nm *NovelManager) chapterContext(chapterNum int) (string, string, bool) {
 	nm.mutex.RLock()
 	defer nm.mutex.RUnlock()
 	
 	var context strings.Builder
 	context.WriteString(fmt.Sprintf("=== 第%d章 写作上下文 ===\n\n", chapterNum))
+	if nm.novelData == nil {
+		context.WriteString("小说项目尚未初始化\n")
+		return context.String(), "", false
+	}
 	
 	// 章节信息
+	query := ""
 	if chapterNum > 0 && chapterNum <= len(nm.novelData.Chapters) {
 		chapter := nm.novelData.Chapters[chapterNum-1]
+		query = strings.TrimSpace(strings.Join([]string{chapter.Title, chapter.Summary,
+			strings.Join(chapter.Characters, " "), strings.Join(chapter.PlotLines, " ")}, " "))
 		context.WriteString(fmt.Sprintf("章节标题: %s\n", chapter.Title))
 		context.WriteString(fmt.Sprintf("章节概要: %s\n", chapter.Summary))
 		context.WriteString(fmt.Sprintf("涉及角色: %s\n", strings.Join(chapter.Characters, ", ")))
+		if chapter.POVCharacter != "" {
+			context.WriteString(fmt.Sprintf("视角人物: %s\n", chapter.POVCharacter))
+		}
 		context.WriteString(fmt.Sprintf("相关情节: %s\n\n", strings.Join(chapter.PlotLines, ", ")))
 	}
 	
+	// 全书大纲中本章所处的节拍
+	if beats := nm.outlineBeatLines(chapterNum); beats != "" {
+		context.WriteString("=== 结构节拍 ===\n")
+		context.WriteString(beats + "\n")
+	}
+	
 	// 相关角色信息
 	context.WriteString("=== 相关角色 ===\n")
 	for name, char := range nm.novelData.Characters {
-		if char.FirstAppeared <= chapterNum && (char.LastAppeared == 0 || char.LastAppeared >= chapterNum) {
+		if recentlyAppeared(char, chapterNum) {
+			if timeline := nm.novelData.Timeline; timeline != nil {
+				if age, ok := timeline.AgeAt(char, chapterNum); ok {
+					context.WriteString(fmt.Sprintf("• %s（%d岁）: %s\n", name, age, char.Background))
+					continue
+				}
+			}
 			context.WriteString(fmt.Sprintf("• %s: %s\n", name, char.Background))
 		}
 	}
@@ -339,16 +421,40 @@ func (nm *NovelManager) GetChapterContext(chapterNum int) string {
 		}
 	}
 	
+	// 待回收的伏笔
+	if reminders := nm.foreshadowingReminders(chapterNum); reminders != "" {
+		context.WriteString("\n=== 伏笔提醒 ===\n")
+		context.WriteString(reminders)
+	}
+	
+	// 明确记录的创作决定
+	if decisions := nm.explicitDecisions(chapterNum, maxContextDecisions); len(decisions) > 0 {
+		context.WriteString("\n=== 创作决定 ===\n")
+		context.WriteString(FormatDecisions(decisions))
+	}
+	
+	// 术语表
+	if glossary := nm.glossaryPrompt(query); glossary != "" {
+		context.WriteString("\n=== 术语表 ===\n")
+		context.WriteString(glossary)
+	}
+	
+	// 文风画像
+	if profile := nm.novelData.StyleProfile; profile != nil {
+		context.WriteString("\n=== 文风要求 ===\n")
+		context.WriteString(profile.PromptGuide())
+	}
+	
 	// 最近的聊天记录
 	context.WriteString("\n=== 最近讨论 ===\n")
 	recentChats := nm.getChapterChats(chapterNum, 5)
 	for _, chat := range recentChats {
 		context.WriteString(fmt.Sprintf("• %s: %s\n", 
 			chat.Timestamp.Format("01-02 15:04"), 
-			truncateString(chat.UserMessage, 100)))
+			truncateRunes(chat.UserMessage, 100)))
 	}
 	
-	return context.String()
+	return context.String(), query, true
 }
 
 // 辅助函数
@@ -406,6 +512,9 @@ func (nm *NovelManager) extractMentions(text string) Mentions {
 		}
 	}
 	
+	// 提取章节提及
+	mentions.Chapters = append(mentions.Chapters, mentionedChapters(text)...)
+	
 	return mentions
 }
 
@@ -431,35 +540,26 @@ func (nm *NovelManager) updateContentIndex(record ChatRecord) {
 	// 更新角色索引
 	for _, char := range record.Mentions.Characters {
 		nm.contentIndex.CharacterIndex[char] = append(
-			nm.contentIndex.CharacterIndex[char], 
-			len(nm.chatHistory)-1)
+			nm.contentIndex.CharacterIndex[char], record.ID)
 	}
 	
 	// 更新设定索引
 	for _, setting := range record.Mentions.WorldSettings {
 		nm.contentIndex.SettingIndex[setting] = append(
-			nm.contentIndex.SettingIndex[setting], 
-			len(nm.chatHistory)-1)
+			nm.contentIndex.SettingIndex[setting], record.ID)
 	}
 	
 	// 更新情节索引
 	for _, plot := range record.Mentions.PlotLines {
 		nm.contentIndex.PlotIndex[plot] = append(
-			nm.contentIndex.PlotIndex[plot], 
-			len(nm.chatHistory)-1)
+			nm.contentIndex.PlotIndex[plot], record.ID)
 	}
 }
 
 func (nm *NovelManager) getChapterChats(chapterNum, limit int) []ChatRecord {
-	chats := make([]ChatRecord, 0)
-	count := 0
-	
-	for i := len(nm.chatHistory) - 1; i >= 0 && count < limit; i-- {
-		if nm.chatHistory[i].ChapterNum == chapterNum {
-			chats = append(chats, nm.chatHistory[i])
-			count++
-		}
-	}
+	chats, _ := nm.chatLog.find(func(entry *chatEntry) bool {
+		return entry.Chapter == chapterNum
+	}, limit)
 	
 	return chats
 }
@@ -470,10 +570,3 @@ func getLastEvent(plot *PlotLine) string {
 	}
 	return "暂无事件"
 }
-
-func truncateString(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
-}
\ No newline at end of file
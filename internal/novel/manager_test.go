@@ -0,0 +1,30 @@
+package novel
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestManager 在临时目录中创建已初始化的小说项目
+func newTestManager(t *testing.T) *NovelManager {
+	t.Helper()
+	nm := NewNovelManager(t.TempDir())
+	if err := nm.InitializeProject("测试小说", "测试作者", "玄幻"); err != nil {
+		t.Fatalf("InitializeProject: %v", err)
+	}
+	t.Cleanup(func() { nm.Close() })
+	return nm
+}
+
+// writeTestChapter 直接写入章节正文文件，模拟在编辑器中修改
+func writeTestChapter(t *testing.T, nm *NovelManager, number int, text string) {
+	t.Helper()
+	path := nm.ChapterFilePath(number)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,475 @@
+package novel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/AiNovelTools/internal/ai"
+)
+
+// 起名的对象
+const (
+	NameCharacter = "character" // 人名
+	NamePlace     = "place"     // 地名
+	NameSect      = "sect"      // 宗门、家族、组织
+	NameArtifact  = "artifact"  // 法宝、兵器、舰船等器物
+)
+
+// 命名风格，按项目题材选择
+const (
+	NameStyleAncient = "ancient" // 古风：玄幻、仙侠、武侠、历史
+	NameStyleModern  = "modern"  // 现代：都市、言情、悬疑
+	NameStyleSciFi   = "scifi"   // 科幻：星际、末世
+)
+
+const (
+	defaultNameCount = 10
+	maxNameCount     = 50
+	// 每个候选名最多尝试的次数，词库组合用尽或冲突过多时提前结束
+	nameAttemptsPerCandidate = 30
+	// 结果中列出的冲突候选数
+	maxShownConflicts = 10
+	// 交给模型参考的已有名字数
+	maxAIExistingNames = 100
+)
+
+// nameStyleGenres 题材关键词 -> 命名风格，未匹配时按古风处理
+var nameStyleGenres = map[string][]string{
+	NameStyleModern: {"都市", "言情", "悬疑", "推理", "校园", "职场", "现实", "娱乐", "体育", "灵异"},
+	NameStyleSciFi:  {"科幻", "星际", "末世", "赛博", "机甲", "未来"},
+}
+
+// nameSurnames 单姓与复姓，复姓只用于古风
+var (
+	nameSurnames         = splitRunes("林叶萧秦楚苏沈陆顾江白李王张刘陈杨赵周吴徐孙朱马胡郭何高罗郑梁谢宋唐韩冯许邓曹彭曾田董袁潘蒋蔡余杜程魏吕丁任姚卢姜崔钟谭汪范金石廖贾夏韦方邹孟熊邱尹薛段雷侯龙史陶黎贺毛郝龚邵万钱严武戴莫孔向汤温柳凌云宁洛燕岳霍殷卫纪")
+	nameCompoundSurnames = []string{"慕容", "上官", "欧阳", "司马", "诸葛", "南宫", "东方", "西门", "独孤", "令狐",
+		"皇甫", "公孙", "轩辕", "端木", "长孙", "宇文", "夏侯", "百里", "司徒", "尉迟"}
+)
+
+// nameGivenChars 名字用字，按风格和性别区分
+var nameGivenChars = map[string]map[string][]string{
+	NameStyleAncient: {
+		"male":   splitRunes("辰逸尘风云寒渊澈玄墨宸霄衍昊凌天羽轩炎岳珩瑾恒舟晏鸿烈霆川锋砚弈安修远啸"),
+		"female": splitRunes("雪瑶璃月霜烟婉清凝嫣柔薇灵芷若汐蝶兮鸢素绾黛音萱岚"),
+	},
+	NameStyleModern: {
+		"male":   splitRunes("浩宇轩博涛杰鹏晨明磊斌俊哲睿泽凯航然毅帆阳辉峰宁"),
+		"female": splitRunes("欣怡婷雨佳琪梦璐晴雅诗琳悦彤妍蕾薇静萌楠丹珊菲"),
+	},
+}
+
+// namePatterns 地名、组织名、器物名的“前缀字 + 前缀字 + 后缀”词库
+type namePattern struct {
+	first    []string
+	second   []string
+	suffixes []string
+	single   bool // 允许只用一个前缀字，如“青州”
+}
+
+var namePatterns = map[string]map[string]namePattern{
+	NamePlace: {
+		NameStyleAncient: {
+			first:    splitRunes("青苍落断天云玄幽赤白紫寒烈九万北南东西古黑碧风雷星月龙凤血雾"),
+			second:   splitRunes("霞云龙风雪月星魂剑岚枫木石泉霄渊阳凰灵烟沙光"),
+			suffixes: []string{"山", "峰", "谷", "城", "镇", "州", "海", "原", "岭", "渊", "林", "湖", "关", "岛", "崖", "域", "荒", "泽", "川"},
+			single:   true,
+		},
+		NameStyleModern: {
+			first:    splitRunes("江临云海宁滨南北清安长凤新华明锦东西嘉泰金"),
+			second:   splitRunes("州城川阳江海水安宁平山湖"),
+			suffixes: []string{"市", "区", "县", "镇", "路", "街", "大道", "广场", "大厦", "公园", "村"},
+		},
+		NameStyleSciFi: {
+			first:    splitRunes("天星辰银极曙深零赤北新远光冥"),
+			second:   splitRunes("穹河光渊环冕曜辉轨域港枢"),
+			suffixes: []string{"星", "空间站", "星港", "基地", "星域", "城", "殖民地", "要塞"},
+		},
+	},
+	NameSect: {
+		NameStyleAncient: {
+			first:    splitRunes("青苍天云玄幽赤紫太九万古碧星月龙凤灵逍无丹神"),
+			second:   splitRunes("云霄剑霞岚阳虚灵华元极玄鸿月星羽衍宸"),
+			suffixes: []string{"宗", "门", "阁", "派", "谷", "宫", "殿", "山庄", "盟", "教", "楼", "书院"},
+		},
+		NameStyleModern: {
+			first:    splitRunes("华天盛恒鼎宏锦瑞新远东嘉博腾星海金"),
+			second:   splitRunes("信达泰宇辉创科联丰安隆源誉"),
+			suffixes: []string{"集团", "公司", "商会", "俱乐部", "事务所", "基金会", "安保", "科技"},
+		},
+		NameStyleSciFi: {
+			first:    splitRunes("天星辰银极曙深零赤新远光冥"),
+			second:   splitRunes("穹河光渊环冕曜辉域枢盟"),
+			suffixes: []string{"联邦", "议会", "舰队", "公司", "同盟", "帝国", "军团", "研究所"},
+		},
+	},
+	NameArtifact: {
+		NameStyleAncient: {
+			first:    splitRunes("玄紫青赤天九幽昊太混乾坤雷冰炎血星月龙凤万阴阳金碧寒"),
+			second:   splitRunes("霄元冥灵魂天罡辰光霜焰雷龙凰虚鸣渊极"),
+			suffixes: []string{"剑", "刀", "鼎", "印", "珠", "镜", "塔", "钟", "扇", "环", "琴", "幡", "戟", "弓", "枪", "镯", "葫芦", "玉佩", "令", "旗", "尺"},
+		},
+		NameStyleModern: {
+			first:    splitRunes("玄紫青赤古乾坤龙凤阴阳金碧寒血"),
+			second:   splitRunes("玉铜纹龙凰虚鸣光"),
+			suffixes: []string{"玉佩", "古戒", "铜镜", "手串", "罗盘", "古卷", "怀表", "印章"},
+		},
+		NameStyleSciFi: {
+			first:    splitRunes("天星辰银极曙深零赤远光冥"),
+			second:   splitRunes("穹河光渊环冕曜辉轨锋刃"),
+			suffixes: []string{"号", "型机甲", "级战舰", "炮", "护盾", "芯片", "引擎", "光刃"},
+		},
+	},
+}
+
+// NameOptions 起名选项
+type NameOptions struct {
+	Kind    string
+	Genre   string // 为空时使用项目题材
+	Gender  string // male / female，人名有效，为空时男女各半
+	Surname string // 指定姓氏，人名有效
+	Include string // 名字中必须带的字
+	Count   int
+	Seed    int64 // 为0时使用当前时间
+}
+
+// NameCandidate 候选名
+type NameCandidate struct {
+	Name string
+	Note string // 模型挑选时给出的理由
+}
+
+// NameConflict 因与已有名字冲突而放弃的候选
+type NameConflict struct {
+	Name     string
+	Existing string
+	Reason   string
+}
+
+// NameSuggestions 起名结果
+type NameSuggestions struct {
+	Kind       string
+	Style      string
+	Genre      string
+	Candidates []NameCandidate
+	Rejected   []NameConflict
+	ByAI       bool
+	taken      []string
+}
+
+// GenerateNames 按题材风格从离线词库组合候选名，并排除与角色、别名、世界观设定、术语重名、互相包含、同音或只差一字的名字
+func (nm *NovelManager) GenerateNames(opts NameOptions) (*NameSuggestions, error) {
+	if opts.Kind == "" {
+		opts.Kind = NameCharacter
+	}
+	if opts.Kind != NameCharacter && namePatterns[opts.Kind] == nil {
+		return nil, fmt.Errorf("unknown name kind: %s", opts.Kind)
+	}
+	if opts.Gender != "" && opts.Gender != "male" && opts.Gender != "female" {
+		return nil, fmt.Errorf("unknown gender: %s", opts.Gender)
+	}
+	if opts.Count <= 0 {
+		opts.Count = defaultNameCount
+	}
+	opts.Count = clampInt(opts.Count, 1, maxNameCount)
+	if opts.Seed == 0 {
+		opts.Seed = time.Now().UnixNano()
+	}
+
+	nm.mutex.RLock()
+	taken := nm.takenNames()
+	if opts.Genre == "" && nm.novelData != nil {
+		opts.Genre = nm.novelData.Genre
+	}
+	nm.mutex.RUnlock()
+
+	suggestions := &NameSuggestions{Kind: opts.Kind, Style: NameStyleOf(opts.Genre), Genre: opts.Genre, taken: taken}
+	random := rand.New(rand.NewSource(opts.Seed))
+	seen := make(map[string]bool)
+	for attempt := 0; attempt < opts.Count*nameAttemptsPerCandidate && len(suggestions.Candidates) < opts.Count; attempt++ {
+		name := composeName(random, suggestions.Style, opts)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if conflict, ok := nameConflict(name, taken); ok {
+			suggestions.Rejected = append(suggestions.Rejected, conflict)
+			continue
+		}
+		suggestions.Candidates = append(suggestions.Candidates, NameCandidate{Name: name})
+	}
+	return suggestions, nil
+}
+
+// RefineNamesWithAI 让模型从候选中挑选并润色，hint 为对名字的额外要求；模型给出的名字同样做冲突检查
+func (nm *NovelManager) RefineNamesWithAI(ctx context.Context, client *ai.Client, suggestions *NameSuggestions, hint string, count int) error {
+	if client == nil {
+		return fmt.Errorf("AI client not available")
+	}
+	count = clampInt(count, 1, maxNameCount)
+	candidates := make([]string, 0, len(suggestions.Candidates))
+	for _, candidate := range suggestions.Candidates {
+		candidates = append(candidates, candidate.Name)
+	}
+	existing := suggestions.taken
+	if len(existing) > maxAIExistingNames {
+		existing = existing[:maxAIExistingNames]
+	}
+	requirement := ""
+	if hint != "" {
+		requirement = fmt.Sprintf("要求: %s\n", hint)
+	}
+	prompt := fmt.Sprintf(`你是网络小说起名顾问。小说题材: %s，需要为%s起名。
+%s候选: %s
+书中已有的名字（不要与之重名、谐音或过于相似）: %s
+
+请从候选中挑选，或在候选基础上修改，给出 %d 个最贴合题材、朗朗上口、不易混淆的名字。以JSON数组输出，不要输出其他内容，每项格式：
+{"name": "名字", "reason": "一句话说明寓意或用法"}`,
+		labelOr(map[string]string{"": "未指定"}, suggestions.Genre), nameKindLabel(suggestions.Kind), requirement,
+		strings.Join(candidates, "、"), labelOr(map[string]string{"": "无"}, strings.Join(existing, "、")), count)
+
+	response, _, err := client.Chat(ctx, []ai.Message{{Role: "user", Content: prompt}}, nil)
+	if err != nil {
+		return fmt.Errorf("AI name refinement failed: %w", err)
+	}
+	var answers []struct {
+		Name   string `json:"name"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(extractJSONArray(response)), &answers); err != nil {
+		return fmt.Errorf("failed to parse AI response: %w", err)
+	}
+
+	refined := make([]NameCandidate, 0, len(answers))
+	seen := make(map[string]bool)
+	for _, answer := range answers {
+		name := strings.TrimSpace(answer.Name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if conflict, ok := nameConflict(name, suggestions.taken); ok {
+			suggestions.Rejected = append(suggestions.Rejected, conflict)
+			continue
+		}
+		refined = append(refined, NameCandidate{Name: name, Note: strings.TrimSpace(answer.Reason)})
+	}
+	suggestions.Candidates = refined
+	suggestions.ByAI = true
+	return nil
+}
+
+// takenNames 已占用的名字：角色名与别名、世界观设定、情节线、术语及其错误写法，调用方需持有锁
+func (nm *NovelManager) takenNames() []string {
+	taken := make([]string, 0)
+	if nm.novelData == nil {
+		return taken
+	}
+	add := func(name string) {
+		if name = strings.TrimSpace(name); name != "" && !containsString(taken, name) {
+			taken = append(taken, name)
+		}
+	}
+	for name, char := range nm.novelData.Characters {
+		add(name)
+		for _, alias := range char.Aliases {
+			add(alias)
+		}
+	}
+	for name := range nm.novelData.WorldSettings {
+		add(name)
+	}
+	for name := range nm.novelData.PlotLines {
+		add(name)
+	}
+	for _, term := range nm.novelData.Glossary {
+		add(term.Term)
+		for _, variant := range term.Variants {
+			add(variant)
+		}
+	}
+	return taken
+}
+
+// nameConflict 检查候选名是否与已有名字重名、互相包含、同音或只差一字
+func nameConflict(name string, taken []string) (NameConflict, bool) {
+	for _, existing := range taken {
+		switch {
+		case name == existing:
+			return NameConflict{Name: name, Existing: existing, Reason: "重名"}, true
+		case len([]rune(existing)) >= 2 && strings.Contains(name, existing),
+			len([]rune(name)) >= 2 && strings.Contains(existing, name):
+			return NameConflict{Name: name, Existing: existing, Reason: "互相包含"}, true
+		case sameSound(name, existing):
+			return NameConflict{Name: name, Existing: existing, Reason: "同音"}, true
+		case len([]rune(name)) >= 3 && runeDiff(name, existing) == 1:
+			return NameConflict{Name: name, Existing: existing, Reason: "只差一字"}, true
+		}
+	}
+	return NameConflict{}, false
+}
+
+// sameSound 两个名字逐字读音相同
+func sameSound(a, b string) bool {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) != len(rb) {
+		return false
+	}
+	for i := range ra {
+		if !sameSoundRune(ra[i], rb[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// runeDiff 等长名字中不同的字数，长度不同时返回 -1
+func runeDiff(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) != len(rb) {
+		return -1
+	}
+	diff := 0
+	for i := range ra {
+		if ra[i] != rb[i] {
+			diff++
+		}
+	}
+	return diff
+}
+
+// NameStyleOf 按题材选择命名风格
+func NameStyleOf(genre string) string {
+	for _, style := range []string{NameStyleSciFi, NameStyleModern} {
+		if containsAny(genre, nameStyleGenres[style]) {
+			return style
+		}
+	}
+	return NameStyleAncient
+}
+
+// composeName 按风格组合一个名字
+func composeName(random *rand.Rand, style string, opts NameOptions) string {
+	if opts.Kind == NameCharacter {
+		return composePersonName(random, style, opts)
+	}
+	pattern, ok := namePatterns[opts.Kind][style]
+	if !ok {
+		pattern = namePatterns[opts.Kind][NameStyleAncient]
+	}
+	if opts.Kind == NameSect && style == NameStyleAncient && random.Intn(6) == 0 {
+		// 古风的家族势力，如“萧家”
+		return pickString(random, nameSurnames) + pickString(random, []string{"家", "氏"})
+	}
+	first := pickString(random, pattern.first)
+	if opts.Include != "" {
+		first = opts.Include
+	}
+	if pattern.single && random.Intn(3) == 0 {
+		return first + pickString(random, pattern.suffixes)
+	}
+	second := pickString(random, pattern.second)
+	if second == first {
+		return ""
+	}
+	return first + second + pickString(random, pattern.suffixes)
+}
+
+// composePersonName 组合人名：姓 + 一到两个名字用字
+func composePersonName(random *rand.Rand, style string, opts NameOptions) string {
+	surname := opts.Surname
+	if surname == "" {
+		surname = pickString(random, nameSurnames)
+		if style == NameStyleAncient && random.Intn(8) == 0 {
+			surname = pickString(random, nameCompoundSurnames)
+		}
+	}
+	gender := opts.Gender
+	if gender == "" {
+		gender = pickString(random, []string{"male", "female"})
+	}
+	chars, ok := nameGivenChars[style]
+	if !ok {
+		// 科幻题材的人名沿用现代用字
+		chars = nameGivenChars[NameStyleModern]
+	}
+	pool := chars[gender]
+
+	given := []string{pickString(random, pool)}
+	if random.Intn(4) != 0 || opts.Include != "" {
+		given = append(given, pickString(random, pool))
+	}
+	if opts.Include != "" {
+		given[random.Intn(len(given))] = opts.Include
+	}
+	if len(given) == 2 && given[0] == given[1] {
+		return ""
+	}
+	for _, char := range given {
+		if strings.Contains(surname, char) {
+			return ""
+		}
+	}
+	return surname + strings.Join(given, "")
+}
+
+func pickString(random *rand.Rand, values []string) string {
+	return values[random.Intn(len(values))]
+}
+
+func splitRunes(text string) []string {
+	result := make([]string, 0, len(text)/3)
+	for _, r := range text {
+		result = append(result, string(r))
+	}
+	return result
+}
+
+func nameKindLabel(kind string) string {
+	return labelOr(map[string]string{
+		NameCharacter: "人物",
+		NamePlace:     "地点",
+		NameSect:      "宗门或组织",
+		NameArtifact:  "法宝或器物",
+	}, kind)
+}
+
+// Format 格式化起名结果
+func (s *NameSuggestions) Format() string {
+	styles := map[string]string{NameStyleAncient: "古风", NameStyleModern: "现代", NameStyleSciFi: "科幻"}
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("🏷 === %s起名 ===\n\n", nameKindLabel(s.Kind)))
+	result.WriteString(fmt.Sprintf("风格: %s", labelOr(styles, s.Style)))
+	if s.Genre != "" {
+		result.WriteString(fmt.Sprintf("（题材: %s）", s.Genre))
+	}
+	if s.ByAI {
+		result.WriteString("，已由模型挑选润色")
+	}
+	result.WriteString("\n\n")
+
+	if len(s.Candidates) == 0 {
+		result.WriteString("没有可用的候选名，可换一个风格、去掉必带字或稍后重试\n")
+	}
+	for i, candidate := range s.Candidates {
+		line := fmt.Sprintf("%2d. %s", i+1, candidate.Name)
+		if candidate.Note != "" {
+			line += " — " + candidate.Note
+		}
+		result.WriteString(line + "\n")
+	}
+	if len(s.Rejected) > 0 {
+		result.WriteString(fmt.Sprintf("\n⚠️ 已排除 %d 个与书中名字冲突的候选:\n", len(s.Rejected)))
+		for i, conflict := range s.Rejected {
+			if i == maxShownConflicts {
+				result.WriteString(fmt.Sprintf("……另有 %d 个\n", len(s.Rejected)-i))
+				break
+			}
+			result.WriteString(fmt.Sprintf("• %s（与「%s」%s）\n", conflict.Name, conflict.Existing, conflict.Reason))
+		}
+	}
+	return result.String()
+}
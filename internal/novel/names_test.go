@@ -0,0 +1,132 @@
+package novel
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestNameConflict(t *testing.T) {
+	taken := []string{"林动", "萧炎", "慕容雪", "青"}
+	tests := []struct {
+		name         string
+		candidate    string
+		wantExisting string
+		wantReason   string // 为空表示没有冲突
+	}{
+		{name: "重名", candidate: "林动", wantExisting: "林动", wantReason: "重名"},
+		{name: "包含已有名字", candidate: "林动天", wantExisting: "林动", wantReason: "互相包含"},
+		{name: "被已有名字包含", candidate: "慕容", wantExisting: "慕容雪", wantReason: "互相包含"},
+		{name: "单字已有名字不算包含", candidate: "青云宗", wantReason: ""},
+		{name: "同音", candidate: "肖岩", wantExisting: "萧炎", wantReason: "同音"},
+		{name: "三字名只差一字", candidate: "慕容霜", wantExisting: "慕容雪", wantReason: "只差一字"},
+		{name: "两字名只差一字不算冲突", candidate: "林风", wantReason: ""},
+		{name: "没有冲突", candidate: "苏柔", wantReason: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conflict, ok := nameConflict(tt.candidate, taken)
+			if ok != (tt.wantReason != "") || conflict.Reason != tt.wantReason || conflict.Existing != tt.wantExisting {
+				t.Errorf("nameConflict(%q) = %+v, %v, want %q with %q", tt.candidate, conflict, ok, tt.wantReason, tt.wantExisting)
+			}
+		})
+	}
+}
+
+func TestTakenNames(t *testing.T) {
+	nm := newTestManager(t)
+	if _, err := nm.AddCharacter(&Character{Name: "林动", Aliases: []string{"林师兄", " 林动 "}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nm.AddGlossaryTerm(GlossaryTerm{Term: "筑基", Variants: []string{"筑机"}}); err != nil {
+		t.Fatal(err)
+	}
+	nm.novelData.WorldSettings["青阳镇"] = &WorldSetting{Name: "青阳镇"}
+	nm.novelData.PlotLines["灭门之仇"] = &PlotLine{Name: "灭门之仇"}
+
+	got := nm.takenNames()
+	sort.Strings(got)
+	want := []string{"林动", "林师兄", "灭门之仇", "筑基", "筑机", "青阳镇"}
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("takenNames() = %v, want %v", got, want)
+	}
+}
+
+func TestGenerateNames(t *testing.T) {
+	nm := newTestManager(t)
+	if _, err := nm.AddCharacter(&Character{Name: "林动"}); err != nil {
+		t.Fatal(err)
+	}
+	taken := nm.takenNames()
+
+	// 必带字“动”紧跟姓氏时与“林动”互相包含，这些候选都应被排除
+	opts := NameOptions{Surname: "林", Include: "动", Gender: "male", Count: 5, Seed: 7}
+	suggestions, err := nm.GenerateNames(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if suggestions.Kind != NameCharacter || suggestions.Style != NameStyleAncient {
+		t.Errorf("Kind = %s, Style = %s", suggestions.Kind, suggestions.Style)
+	}
+	if len(suggestions.Candidates) == 0 || len(suggestions.Rejected) == 0 {
+		t.Fatalf("candidates = %v, rejected = %v", suggestions.Candidates, suggestions.Rejected)
+	}
+	for _, candidate := range suggestions.Candidates {
+		if !strings.HasPrefix(candidate.Name, "林") || !strings.Contains(candidate.Name, "动") {
+			t.Errorf("candidate %q ignores surname or include", candidate.Name)
+		}
+		if conflict, ok := nameConflict(candidate.Name, taken); ok {
+			t.Errorf("candidate %q conflicts: %+v", candidate.Name, conflict)
+		}
+	}
+	for _, conflict := range suggestions.Rejected {
+		if conflict.Existing != "林动" || conflict.Reason == "" {
+			t.Errorf("rejected = %+v", conflict)
+		}
+	}
+
+	// 相同的种子得到相同的结果
+	again, err := nm.GenerateNames(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again.Candidates, suggestions.Candidates) {
+		t.Errorf("seeded results differ: %v vs %v", again.Candidates, suggestions.Candidates)
+	}
+
+	places, err := nm.GenerateNames(NameOptions{Kind: NamePlace, Genre: "星际", Count: 3, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if places.Style != NameStyleSciFi || len(places.Candidates) != 3 {
+		t.Errorf("places = %+v", places)
+	}
+
+	if _, err := nm.GenerateNames(NameOptions{Kind: "weapon"}); err == nil {
+		t.Error("accepted an unknown kind")
+	}
+	if _, err := nm.GenerateNames(NameOptions{Gender: "other"}); err == nil {
+		t.Error("accepted an unknown gender")
+	}
+}
+
+func TestNameStyleOf(t *testing.T) {
+	tests := []struct {
+		genre string
+		want  string
+	}{
+		{genre: "玄幻", want: NameStyleAncient},
+		{genre: "", want: NameStyleAncient},
+		{genre: "都市言情", want: NameStyleModern},
+		{genre: "末世", want: NameStyleSciFi},
+		// 科幻关键词优先于现代
+		{genre: "都市科幻", want: NameStyleSciFi},
+	}
+	for _, tt := range tests {
+		if got := NameStyleOf(tt.genre); got != tt.want {
+			t.Errorf("NameStyleOf(%q) = %s, want %s", tt.genre, got, tt.want)
+		}
+	}
+}
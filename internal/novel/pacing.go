@@ -0,0 +1,531 @@
+package novel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/AiNovelTools/internal/ai"
+)
+
+// 节奏图表可选的指标
+const (
+	PacingChartWords    = "words"
+	PacingChartDialogue = "dialogue"
+	PacingChartConflict = "conflict"
+	PacingChartHook     = "hook"
+)
+
+const (
+	// 判断章末钩子时查看的结尾字数
+	hookTailRunes = 200
+	// 钩子得分达到该值视为有钩子
+	hookThreshold = 50
+	// 情感基调每千字至少出现的次数
+	minEmotionDensity = 1.0
+	// 每章记录的情感基调数
+	maxChapterEmotions = 3
+	// 冲突低于全段均值该比例的章节视为平淡
+	lowConflictRatio = 0.5
+	// 连续几章平淡或没有钩子时提醒
+	pacingStreakLimit = 3
+	// ASCII 图表的最大宽度
+	pacingChartWidth = 40
+	// 每次请模型判断钩子的章节数
+	aiHookBatch = 20
+)
+
+var (
+	// 独占一行的场景分隔符，如“***”“——”“◆◆◆”
+	sceneBreakPattern = regexp.MustCompile(`^\s*(?:[*＊#＃~～\-—=·•◆◇○●☆★]\s*){3,}\s*$|^\s*(?:——|……)\s*$`)
+	// 段首的时间或地点转换
+	sceneShiftPrefixes = []string{"次日", "翌日", "第二天", "第二日", "当晚", "当夜", "入夜", "深夜", "黎明", "清晨", "傍晚", "与此同时", "另一边", "另一头", "数日后", "几天后", "半月后", "一个月后", "三天后", "半个时辰后", "一个时辰后", "不久后"}
+	// 章末悬念标记
+	hookMarkers = []string{"就在这时", "就在此时", "正在这时", "下一刻", "突然", "忽然", "猛然", "骤然", "竟然", "竟是", "没想到", "万万没想到", "脸色大变", "脸色一变", "瞳孔一缩", "瞳孔骤缩", "一道身影", "一个声音", "声音响起", "到底", "究竟", "难道", "然而", "只见", "却见", "不好", "糟了"}
+	// 章末收束标记，出现时钩子减弱
+	closureMarkers = []string{"睡去", "睡着", "入睡", "沉沉睡", "一夜无话", "一夜无事", "就此结束", "告一段落", "回到住处", "回到房间", "松了口气", "平静下来", "安心", "满意地"}
+	// 冲突标记
+	conflictMarkers = []string{"战斗", "交手", "出手", "厮杀", "激战", "大战", "杀意", "杀机", "击杀", "斩杀", "攻击", "围攻", "偷袭", "对峙", "挑衅", "威胁", "争吵", "冲突", "敌人", "仇人", "质问", "怒喝", "怒吼", "冷笑", "拳头", "一拳", "一掌", "一剑", "剑光", "刀光", "鲜血", "受伤", "重伤", "危机", "逃走", "追杀", "拦住"}
+	// 情感基调词表
+	emotionLexicon = []struct {
+		Tone  string
+		Words []string
+	}{
+		{"紧张", []string{"紧张", "屏住呼吸", "冷汗", "心跳加速", "不敢", "危机", "千钧一发", "生死", "咽了口唾沫", "绷紧"}},
+		{"热血", []string{"热血", "战意", "沸腾", "咆哮", "怒吼", "燃烧", "豪气", "斗志", "杀出", "不服"}},
+		{"愤怒", []string{"愤怒", "怒火", "大怒", "暴怒", "咬牙", "怒视", "恼怒", "可恶", "混账", "该死"}},
+		{"悲伤", []string{"悲伤", "泪水", "眼泪", "哭泣", "痛哭", "哽咽", "心痛", "悲痛", "失去", "离别"}},
+		{"喜悦", []string{"高兴", "欢喜", "开心", "兴奋", "欣喜", "笑容", "大笑", "哈哈", "喜悦", "激动"}},
+		{"恐惧", []string{"恐惧", "害怕", "颤抖", "毛骨悚然", "惊恐", "战栗", "脸色苍白", "头皮发麻", "惊骇", "胆寒"}},
+		{"温情", []string{"温柔", "温暖", "依偎", "轻声", "抚摸", "心疼", "守护", "陪伴", "牵挂", "微笑"}},
+		{"悬疑", []string{"疑惑", "诡异", "神秘", "秘密", "奇怪", "蹊跷", "线索", "真相", "莫名", "古怪"}},
+		{"轻松", []string{"打趣", "调侃", "玩笑", "嬉笑", "悠闲", "惬意", "闲聊", "懒洋洋", "哭笑不得", "无奈地笑"}},
+	}
+)
+
+// ChapterPacing 单章的节奏指标
+type ChapterPacing struct {
+	Chapter       int      `json:"chapter"`
+	Title         string   `json:"title,omitempty"`
+	Words         int      `json:"words"`
+	DialogueRatio float64  `json:"dialogue_ratio"`
+	Scenes        int      `json:"scenes"`
+	Conflict      float64  `json:"conflict"` // 冲突标记，每千字
+	HookScore     int      `json:"hook_score"`
+	Hook          bool     `json:"hook"`
+	HookReason    string   `json:"hook_reason,omitempty"`
+	HookByAI      bool     `json:"hook_by_ai,omitempty"`
+	Emotions      []string `json:"emotions,omitempty"`
+	Ending        string   `json:"-"` // 结尾片段，供模型判断钩子
+}
+
+// PacingReport 一段章节（一卷或一个情节段落）的节奏报告
+type PacingReport struct {
+	Chapters    []*ChapterPacing
+	Missing     []int
+	AvgWords    float64
+	AvgDialogue float64
+	AvgConflict float64
+	HookRate    float64
+	Notes       []string // 节奏提醒
+}
+
+// AnalyzePacing 分析章节节奏并把情感基调写入章节信息，from/to 为0时表示不限
+func (nm *NovelManager) AnalyzePacing(fromChapter, toChapter int) (*PacingReport, error) {
+	nm.mutex.RLock()
+	if nm.novelData == nil {
+		nm.mutex.RUnlock()
+		return nil, fmt.Errorf("novel project not initialized")
+	}
+	titles := make(map[int]string, len(nm.novelData.Chapters))
+	numbers := make([]int, 0, len(nm.novelData.Chapters))
+	for _, chapter := range nm.novelData.Chapters {
+		titles[chapter.Number] = chapter.Title
+		numbers = append(numbers, chapter.Number)
+	}
+	nm.mutex.RUnlock()
+
+	if files, err := nm.listChapterNumbers(); err == nil {
+		numbers = append(numbers, files...)
+	}
+	numbers = uniqueSortedInts(numbers)
+
+	report := &PacingReport{}
+	for _, number := range numbers {
+		if (fromChapter > 0 && number < fromChapter) || (toChapter > 0 && number > toChapter) {
+			continue
+		}
+		text, err := nm.ReadChapterText(number)
+		if err != nil {
+			report.Missing = append(report.Missing, number)
+			continue
+		}
+		pacing := AnalyzeChapterPacing(text)
+		pacing.Chapter = number
+		pacing.Title = titles[number]
+		report.Chapters = append(report.Chapters, pacing)
+	}
+	report.summarize()
+
+	if len(report.Chapters) > 0 {
+		if err := nm.saveChapterEmotions(report.Chapters); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// JudgeHooksWithAI 让模型判断章末是否留有悬念，覆盖启发式结果
+func (nm *NovelManager) JudgeHooksWithAI(ctx context.Context, client *ai.Client, report *PacingReport) error {
+	if client == nil {
+		return fmt.Errorf("AI client not available")
+	}
+	for start := 0; start < len(report.Chapters); start += aiHookBatch {
+		end := start + aiHookBatch
+		if end > len(report.Chapters) {
+			end = len(report.Chapters)
+		}
+		if err := judgeHookBatch(ctx, client, report.Chapters[start:end]); err != nil {
+			return err
+		}
+	}
+	report.summarize()
+	return nil
+}
+
+func judgeHookBatch(ctx context.Context, client *ai.Client, chapters []*ChapterPacing) error {
+	var endings strings.Builder
+	for _, chapter := range chapters {
+		endings.WriteString(fmt.Sprintf("【第%d章结尾】\n%s\n\n", chapter.Chapter, chapter.Ending))
+	}
+	prompt := fmt.Sprintf(`你是网络小说编辑，请判断以下每章结尾是否留有让读者想看下一章的钩子（悬念、反转、危机、未揭晓的信息等）。
+
+%s以JSON数组输出，不要输出其他内容，每项格式：
+{"chapter": 章节号, "hook": true或false, "score": 0-100的钩子强度, "reason": "一句话理由"}`, endings.String())
+
+	response, _, err := client.Chat(ctx, []ai.Message{{Role: "user", Content: prompt}}, nil)
+	if err != nil {
+		return fmt.Errorf("AI hook judgement failed: %w", err)
+	}
+	var answers []struct {
+		Chapter int    `json:"chapter"`
+		Hook    bool   `json:"hook"`
+		Score   int    `json:"score"`
+		Reason  string `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(extractJSONArray(response)), &answers); err != nil {
+		return fmt.Errorf("failed to parse AI response: %w", err)
+	}
+	for _, answer := range answers {
+		for _, chapter := range chapters {
+			if chapter.Chapter != answer.Chapter {
+				continue
+			}
+			chapter.Hook = answer.Hook
+			chapter.HookScore = clampInt(answer.Score, 0, 100)
+			chapter.HookReason = strings.TrimSpace(answer.Reason)
+			chapter.HookByAI = true
+		}
+	}
+	return nil
+}
+
+// AnalyzeChapterPacing 计算一章正文的节奏指标
+func AnalyzeChapterPacing(text string) *ChapterPacing {
+	pacing := &ChapterPacing{Words: CountWords(text), Scenes: 1}
+	metrics := AnalyzeStyle(text)
+	pacing.DialogueRatio = metrics.DialogueRatio
+
+	paragraphs := make([]string, 0)
+	pendingBreak := false
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if sceneBreakPattern.MatchString(line) {
+			pendingBreak = len(paragraphs) > 0
+			continue
+		}
+		if len(paragraphs) > 0 && (pendingBreak || hasPrefixAny(line, sceneShiftPrefixes)) {
+			pacing.Scenes++
+		}
+		pendingBreak = false
+		paragraphs = append(paragraphs, line)
+	}
+
+	if chars := countStyleChars(text); chars > 0 {
+		pacing.Conflict = float64(countMarkers(text, conflictMarkers)) * 1000 / float64(chars)
+		pacing.Emotions = chapterEmotions(text, chars)
+	}
+	pacing.Ending = tailParagraphs(strings.Join(paragraphs, "\n"), hookTailRunes)
+	pacing.HookScore, pacing.HookReason = scoreHook(pacing.Ending)
+	pacing.Hook = pacing.HookScore >= hookThreshold
+	return pacing
+}
+
+// scoreHook 按结尾的标点、悬念词和收束词给钩子打分
+func scoreHook(ending string) (int, string) {
+	ending = strings.TrimSpace(ending)
+	if ending == "" {
+		return 0, ""
+	}
+	lines := strings.Split(ending, "\n")
+	last := strings.TrimSpace(lines[len(lines)-1])
+	lastRunes := []rune(strings.TrimRight(last, "」』”\""))
+
+	score := 20
+	reasons := make([]string, 0, 3)
+	if len(lastRunes) > 0 {
+		switch {
+		case strings.HasSuffix(string(lastRunes), "……") || strings.HasSuffix(string(lastRunes), "——"):
+			score += 25
+			reasons = append(reasons, "结尾戛然而止")
+		case lastRunes[len(lastRunes)-1] == '？' || lastRunes[len(lastRunes)-1] == '?':
+			score += 25
+			reasons = append(reasons, "以疑问收尾")
+		case lastRunes[len(lastRunes)-1] == '！' || lastRunes[len(lastRunes)-1] == '!':
+			score += 15
+			reasons = append(reasons, "以感叹收尾")
+		}
+		// 最后一段很短，通常是刻意留下的一句
+		if len(lastRunes) <= 20 {
+			score += 10
+		}
+	}
+	if found := findTerms(ending, hookMarkers); len(found) > 0 {
+		score += 15 * int(math.Min(float64(len(found)), 2))
+		reasons = append(reasons, "出现「"+found[0]+"」")
+	}
+	if found := findTerms(ending, closureMarkers); len(found) > 0 {
+		score -= 30
+		reasons = append(reasons, "以「"+found[0]+"」收束")
+	}
+	return clampInt(score, 0, 100), strings.Join(reasons, "，")
+}
+
+// chapterEmotions 按词表密度选出本章最突出的情感基调
+func chapterEmotions(text string, chars int) []string {
+	type tone struct {
+		name    string
+		density float64
+	}
+	tones := make([]tone, 0, len(emotionLexicon))
+	for _, entry := range emotionLexicon {
+		density := float64(countMarkers(text, entry.Words)) * 1000 / float64(chars)
+		if density >= minEmotionDensity {
+			tones = append(tones, tone{entry.Tone, density})
+		}
+	}
+	sort.SliceStable(tones, func(i, j int) bool { return tones[i].density > tones[j].density })
+	emotions := make([]string, 0, maxChapterEmotions)
+	for i, t := range tones {
+		if i >= maxChapterEmotions {
+			break
+		}
+		emotions = append(emotions, t.name)
+	}
+	return emotions
+}
+
+// saveChapterEmotions 把情感基调写入章节信息
+func (nm *NovelManager) saveChapterEmotions(chapters []*ChapterPacing) error {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	changed := false
+	for _, pacing := range chapters {
+		chapter := nm.findChapter(pacing.Chapter)
+		if chapter == nil || strings.Join(chapter.Emotions, "、") == strings.Join(pacing.Emotions, "、") {
+			continue
+		}
+		chapter.Emotions = append(make([]string, 0, len(pacing.Emotions)), pacing.Emotions...)
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return nm.SaveProject()
+}
+
+// summarize 计算全段均值并找出节奏问题
+func (r *PacingReport) summarize() {
+	r.AvgWords, r.AvgDialogue, r.AvgConflict, r.HookRate = 0, 0, 0, 0
+	r.Notes = nil
+	n := float64(len(r.Chapters))
+	if n == 0 {
+		return
+	}
+	hooks := 0
+	for _, chapter := range r.Chapters {
+		r.AvgWords += float64(chapter.Words)
+		r.AvgDialogue += chapter.DialogueRatio
+		r.AvgConflict += chapter.Conflict
+		if chapter.Hook {
+			hooks++
+		}
+	}
+	r.AvgWords /= n
+	r.AvgDialogue /= n
+	r.AvgConflict /= n
+	r.HookRate = float64(hooks) / n
+
+	r.Notes = append(r.Notes, r.streakNotes("章末没有钩子", func(c *ChapterPacing) bool { return !c.Hook })...)
+	if r.AvgConflict > 0 {
+		r.Notes = append(r.Notes, r.streakNotes("冲突明显偏少", func(c *ChapterPacing) bool {
+			return c.Conflict < r.AvgConflict*lowConflictRatio
+		})...)
+	}
+	for _, chapter := range r.Chapters {
+		if r.AvgWords > 0 && float64(chapter.Words) < r.AvgWords*0.6 {
+			r.Notes = append(r.Notes, fmt.Sprintf("第%d章只有 %d 字，明显短于平均的 %.0f 字", chapter.Chapter, chapter.Words, r.AvgWords))
+		}
+	}
+	if len(r.Chapters) >= pacingStreakLimit*2 {
+		half := len(r.Chapters) / 2
+		early, late := 0.0, 0.0
+		for i, chapter := range r.Chapters {
+			if i < half {
+				early += chapter.Conflict
+			} else {
+				late += chapter.Conflict
+			}
+		}
+		early /= float64(half)
+		late /= float64(len(r.Chapters) - half)
+		if early > 0 && late < early*0.7 {
+			r.Notes = append(r.Notes, fmt.Sprintf("后半段冲突密度（%.1f）低于前半段（%.1f），高潮可能后继乏力", late, early))
+		}
+	}
+}
+
+// streakNotes 找出连续若干章满足条件的区间
+func (r *PacingReport) streakNotes(label string, match func(*ChapterPacing) bool) []string {
+	notes := make([]string, 0)
+	start := -1
+	flush := func(end int) {
+		if start >= 0 && end-start >= pacingStreakLimit {
+			notes = append(notes, fmt.Sprintf("第%d章 - 第%d章连续 %d 章%s", r.Chapters[start].Chapter, r.Chapters[end-1].Chapter, end-start, label))
+		}
+		start = -1
+	}
+	for i, chapter := range r.Chapters {
+		// 章节号不连续时断开
+		if match(chapter) && (start < 0 || chapter.Chapter == r.Chapters[i-1].Chapter+1) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		flush(i)
+		if match(chapter) {
+			start = i
+		}
+	}
+	flush(len(r.Chapters))
+	return notes
+}
+
+// Format 格式化节奏表格与提醒
+func (r *PacingReport) Format() string {
+	var result strings.Builder
+	result.WriteString("📈 === 章节节奏分析 ===\n\n")
+	if len(r.Chapters) == 0 {
+		result.WriteString("没有可分析的章节正文\n")
+		return result.String()
+	}
+	first, last := r.Chapters[0].Chapter, r.Chapters[len(r.Chapters)-1].Chapter
+	result.WriteString(fmt.Sprintf("分析范围: 第%d章 - 第%d章（共 %d 章）\n", first, last, len(r.Chapters)))
+	if len(r.Missing) > 0 {
+		result.WriteString(fmt.Sprintf("⚠️ 缺少正文文件: %s\n", joinInts(r.Missing, ", ")))
+	}
+	result.WriteString(fmt.Sprintf("平均 %.0f 字/章，对话占比 %.0f%%，冲突密度 %.1f/千字，章末钩子 %.0f%%\n\n",
+		r.AvgWords, r.AvgDialogue*100, r.AvgConflict, r.HookRate*100))
+
+	result.WriteString("| 章节 | 字数 | 对话 | 场景 | 冲突 | 钩子 | 情感基调 |\n|---|---|---|---|---|---|---|\n")
+	for _, chapter := range r.Chapters {
+		hook := fmt.Sprintf("❌ %d", chapter.HookScore)
+		if chapter.Hook {
+			hook = fmt.Sprintf("✅ %d", chapter.HookScore)
+		}
+		emotions := strings.Join(chapter.Emotions, "、")
+		if emotions == "" {
+			emotions = "平稳"
+		}
+		result.WriteString(fmt.Sprintf("| %d | %d | %.0f%% | %d | %.1f | %s | %s |\n",
+			chapter.Chapter, chapter.Words, chapter.DialogueRatio*100, chapter.Scenes, chapter.Conflict, hook, emotions))
+	}
+
+	if len(r.Notes) > 0 {
+		result.WriteString("\n💡 节奏提醒:\n")
+		for _, note := range r.Notes {
+			result.WriteString("• " + note + "\n")
+		}
+	}
+	return result.String()
+}
+
+// Chart 用 ASCII 条形图展示某项指标
+func (r *PacingReport) Chart(metric string) string {
+	if len(r.Chapters) == 0 {
+		return ""
+	}
+	labels := map[string]string{
+		PacingChartWords:    "字数",
+		PacingChartDialogue: "对话占比（%）",
+		PacingChartConflict: "冲突密度（每千字）",
+		PacingChartHook:     "章末钩子得分",
+	}
+	label, ok := labels[metric]
+	if !ok {
+		metric, label = PacingChartConflict, labels[PacingChartConflict]
+	}
+
+	values := make([]float64, len(r.Chapters))
+	maxValue := 0.0
+	for i, chapter := range r.Chapters {
+		switch metric {
+		case PacingChartWords:
+			values[i] = float64(chapter.Words)
+		case PacingChartDialogue:
+			values[i] = chapter.DialogueRatio * 100
+		case PacingChartHook:
+			values[i] = float64(chapter.HookScore)
+		default:
+			values[i] = chapter.Conflict
+		}
+		maxValue = math.Max(maxValue, values[i])
+	}
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("\n📊 %s\n", label))
+	for i, chapter := range r.Chapters {
+		width := 0
+		if maxValue > 0 {
+			width = int(math.Round(values[i] / maxValue * pacingChartWidth))
+		}
+		marker := ""
+		if metric == PacingChartHook && chapter.Hook {
+			marker = " ✓"
+		}
+		result.WriteString(fmt.Sprintf("%4d │%s %s%s\n", chapter.Chapter, strings.Repeat("█", width), formatChartValue(values[i]), marker))
+	}
+	return result.String()
+}
+
+// CSV 导出节奏指标，便于用表格软件作图
+func (r *PacingReport) CSV() string {
+	var result strings.Builder
+	result.WriteString("chapter,title,words,dialogue_ratio,scenes,conflict,hook_score,hook,emotions\n")
+	for _, chapter := range r.Chapters {
+		result.WriteString(fmt.Sprintf("%d,%s,%d,%.3f,%d,%.2f,%d,%t,%s\n",
+			chapter.Chapter, csvField(chapter.Title), chapter.Words, chapter.DialogueRatio, chapter.Scenes,
+			chapter.Conflict, chapter.HookScore, chapter.Hook, csvField(strings.Join(chapter.Emotions, "、"))))
+	}
+	return result.String()
+}
+
+func formatChartValue(value float64) string {
+	if value >= 100 || value == math.Trunc(value) {
+		return fmt.Sprintf("%.0f", value)
+	}
+	return fmt.Sprintf("%.1f", value)
+}
+
+func csvField(value string) string {
+	if strings.ContainsAny(value, ",\"\n") {
+		return `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+	}
+	return value
+}
+
+func countMarkers(text string, markers []string) int {
+	count := 0
+	for _, marker := range markers {
+		count += strings.Count(text, marker)
+	}
+	return count
+}
+
+func hasPrefixAny(text string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(text, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func clampInt(value, low, high int) int {
+	if value < low {
+		return low
+	}
+	if value > high {
+		return high
+	}
+	return value
+}
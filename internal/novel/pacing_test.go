@@ -0,0 +1,101 @@
+package novel
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScoreHook(t *testing.T) {
+	tests := []struct {
+		name       string
+		ending     string
+		wantScore  int
+		wantReason string
+	}{
+		{name: "空结尾", ending: "", wantScore: 0},
+		{name: "悬念词加戛然而止", ending: "他推开门。\n就在这时，一道身影出现在门口……", wantScore: 85, wantReason: "结尾戛然而止，出现「就在这时」"},
+		{name: "引号内的疑问", ending: "“你到底是谁？”", wantScore: 70, wantReason: "以疑问收尾，出现「到底」"},
+		{name: "感叹收尾", ending: "杀！", wantScore: 45, wantReason: "以感叹收尾"},
+		{name: "平铺直叙", ending: "他慢慢地走回了山下的小镇，路边的灯火一盏盏亮了起来，炊烟在暮色里升起。", wantScore: 20},
+		{name: "收束词削弱钩子", ending: "他回到房间，沉沉睡去。", wantScore: 0, wantReason: "以「睡去」收束"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score, reason := scoreHook(tt.ending)
+			if score != tt.wantScore || reason != tt.wantReason {
+				t.Errorf("scoreHook() = %d, %q, want %d, %q", score, reason, tt.wantScore, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestAnalyzeChapterPacing(t *testing.T) {
+	text := "***\n" +
+		"林动与岩老对峙，杀意弥漫。\n" +
+		"***\n" +
+		"他屏住呼吸，冷汗直流，紧张得不敢动。\n" +
+		"次日清晨，他离开了小镇。\n" +
+		"就在这时，一道身影拦住了他……\n"
+	pacing := AnalyzeChapterPacing(text)
+	// 开头的分隔符不算新场景
+	if pacing.Scenes != 3 {
+		t.Errorf("Scenes = %d, want 3", pacing.Scenes)
+	}
+	if pacing.Conflict <= 0 || !pacing.Hook {
+		t.Errorf("Conflict = %.1f, Hook = %v", pacing.Conflict, pacing.Hook)
+	}
+	if len(pacing.Emotions) == 0 || pacing.Emotions[0] != "紧张" {
+		t.Errorf("Emotions = %v, want 紧张 first", pacing.Emotions)
+	}
+}
+
+func TestPacingReportSummarize(t *testing.T) {
+	report := &PacingReport{}
+	for number := 1; number <= 7; number++ {
+		report.Chapters = append(report.Chapters, &ChapterPacing{Chapter: number, Words: 3000, Conflict: 6, Hook: number == 4})
+	}
+	// 第2-3章没有钩子但不足三章，不提醒；第5-7章连续没有钩子、冲突骤降，第7章还很短
+	report.Chapters[0].Hook = true
+	report.Chapters[6].Words = 1000
+	report.Chapters[4].Conflict, report.Chapters[5].Conflict, report.Chapters[6].Conflict = 0, 0, 0
+	report.summarize()
+
+	want := []string{
+		"第5章 - 第7章连续 3 章章末没有钩子",
+		"第5章 - 第7章连续 3 章冲突明显偏少",
+		"第7章只有 1000 字，明显短于平均的 2714 字",
+		"后半段冲突密度（1.5）低于前半段（6.0），高潮可能后继乏力",
+	}
+	if !reflect.DeepEqual(report.Notes, want) {
+		t.Errorf("Notes =\n%s\nwant\n%s", strings.Join(report.Notes, "\n"), strings.Join(want, "\n"))
+	}
+	if report.HookRate != 2.0/7 {
+		t.Errorf("HookRate = %v, want 2/7", report.HookRate)
+	}
+}
+
+func TestAnalyzePacingSavesEmotions(t *testing.T) {
+	nm := newTestManager(t)
+	nm.novelData.Chapters = append(nm.novelData.Chapters, &Chapter{Number: 1, Title: "对峙, 上"})
+	writeTestChapter(t, nm, 1, "他屏住呼吸，冷汗直流，紧张得不敢动。\n“你到底是谁？”\n")
+	writeTestChapter(t, nm, 2, "他回到房间，沉沉睡去。\n")
+
+	report, err := nm.AnalyzePacing(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Chapters) != 2 || report.Chapters[0].Title != "对峙, 上" {
+		t.Fatalf("chapters = %+v", report.Chapters)
+	}
+	if got := nm.findChapter(1).Emotions; len(got) == 0 || got[0] != "紧张" {
+		t.Errorf("saved emotions = %v", got)
+	}
+	csv := report.CSV()
+	if !strings.Contains(csv, `1,"对峙, 上",`) || !strings.HasSuffix(csv, ",0,false,\n") {
+		t.Errorf("CSV =\n%s", csv)
+	}
+	if only, err := nm.AnalyzePacing(2, 2); err != nil || len(only.Chapters) != 1 || only.Chapters[0].Chapter != 2 {
+		t.Errorf("AnalyzePacing(2, 2) = %+v, %v", only, err)
+	}
+}
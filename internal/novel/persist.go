@@ -0,0 +1,440 @@
+package novel
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 项目数据文件
+const (
+	ProjectFile = "novel_project.json"
+	// 旧版本整体保存的聊天历史和内容索引，升级后改为 chat/ 下的分段日志
+	ChatHistoryFile  = "chat_history.json"
+	ContentIndexFile = "content_index.json"
+)
+
+// chatLogSchemaVersion 聊天记录改为分段日志的结构版本
+const chatLogSchemaVersion = 2
+
+// backupSuffix 原子写入时保留的上一版本
+const backupSuffix = ".bak"
+
+// saveDebounce 首次保存请求后最多等待的时间，期间的多次保存合并为一次写入
+const saveDebounce = 500 * time.Millisecond
+
+// projectMigrations 按顺序升级项目数据，第 i 项把结构版本从 i 升到 i+1
+var projectMigrations = []func(nm *NovelManager) error{
+	// 0 -> 1: 伏笔由字符串改为带ID的结构；旧版本按秒生成的聊天记录ID去重
+	func(nm *NovelManager) error {
+		nm.assignForeshadowingIDs()
+		nm.dedupeChatIDs()
+		return nil
+	},
+	// 1 -> 2: 聊天历史迁入 chat/ 下按章节分段的追加式日志，内容索引改存记录ID
+	func(nm *NovelManager) error {
+		if err := nm.migrateLegacyChat(); err != nil {
+			return err
+		}
+		for _, name := range []string{ChatHistoryFile, ContentIndexFile} {
+			path := filepath.Join(nm.projectPath, name)
+			for _, file := range []string{path, path + backupSuffix} {
+				if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+		}
+		return nil
+	},
+}
+
+// CurrentSchemaVersion 项目数据的当前结构版本
+var CurrentSchemaVersion = len(projectMigrations)
+
+// projectWriter 项目文件的唯一写入者，合并短时间内的多次保存并原子写入
+type projectWriter struct {
+	requests chan writeRequest
+	done     chan struct{}
+
+	sendMutex sync.RWMutex // 保护 closed 与向 requests 发送
+	closed    bool
+
+	errMutex sync.Mutex
+	lastErr  error
+}
+
+type writeRequest struct {
+	files   map[string][]byte
+	flushed chan error // 非空时立即写入并回传结果
+}
+
+func newProjectWriter() *projectWriter {
+	w := &projectWriter{
+		requests: make(chan writeRequest),
+		done:     make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *projectWriter) run() {
+	defer close(w.done)
+
+	pending := make(map[string][]byte)
+	var timer *time.Timer
+	var timeout <-chan time.Time
+
+	flush := func() error {
+		if timer != nil {
+			timer.Stop()
+			timer, timeout = nil, nil
+		}
+		if len(pending) == 0 {
+			return w.err()
+		}
+		paths := make([]string, 0, len(pending))
+		for path := range pending {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		var firstErr error
+		for _, path := range paths {
+			if err := writeFileAtomic(path, pending[path], true); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		pending = make(map[string][]byte)
+		w.setErr(firstErr)
+		return firstErr
+	}
+
+	for {
+		select {
+		case req, ok := <-w.requests:
+			if !ok {
+				flush()
+				return
+			}
+			for path, data := range req.files {
+				pending[path] = data
+			}
+			if req.flushed != nil {
+				req.flushed <- flush()
+				continue
+			}
+			if timer == nil {
+				timer = time.NewTimer(saveDebounce)
+				timeout = timer.C
+			}
+		case <-timeout:
+			timer, timeout = nil, nil
+			flush()
+		}
+	}
+}
+
+// submit 提交待写入的文件，返回之前写入失败的错误
+func (w *projectWriter) submit(files map[string][]byte) error {
+	w.sendMutex.RLock()
+	defer w.sendMutex.RUnlock()
+
+	if w.closed {
+		// 关闭后仍有保存请求时同步写入
+		for path, data := range files {
+			if err := writeFileAtomic(path, data, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	w.requests <- writeRequest{files: files}
+	return w.err()
+}
+
+// flush 立即写入所有待保存的文件
+func (w *projectWriter) flush() error {
+	w.sendMutex.RLock()
+	if w.closed {
+		w.sendMutex.RUnlock()
+		return w.err()
+	}
+	flushed := make(chan error, 1)
+	w.requests <- writeRequest{flushed: flushed}
+	w.sendMutex.RUnlock()
+
+	return <-flushed
+}
+
+// close 写入剩余内容并停止写入协程
+func (w *projectWriter) close() error {
+	w.sendMutex.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.requests)
+	}
+	w.sendMutex.Unlock()
+
+	<-w.done
+	return w.err()
+}
+
+func (w *projectWriter) err() error {
+	w.errMutex.Lock()
+	defer w.errMutex.Unlock()
+
+	return w.lastErr
+}
+
+func (w *projectWriter) setErr(err error) {
+	w.errMutex.Lock()
+	defer w.errMutex.Unlock()
+
+	w.lastErr = err
+}
+
+// SaveProject 序列化项目数据并交给写入协程保存，调用方需持有锁
+func (nm *NovelManager) SaveProject() error {
+	files := make(map[string][]byte, 1)
+
+	if nm.novelData != nil {
+		nm.novelData.LastModified = time.Now()
+		data, err := json.MarshalIndent(nm.novelData, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal project: %w", err)
+		}
+		files[filepath.Join(nm.projectPath, ProjectFile)] = data
+	}
+
+	if len(files) == 0 {
+		return nil
+	}
+	if err := nm.writer.submit(files); err != nil {
+		return fmt.Errorf("failed to save project: %w", err)
+	}
+	return nil
+}
+
+// Flush 立即写入尚未落盘的项目数据和聊天记录索引
+func (nm *NovelManager) Flush() error {
+	nm.mutex.RLock()
+	err := nm.saveChatIndex()
+	nm.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+	return nm.writer.flush()
+}
+
+// Close 写入剩余数据并停止后台写入，退出程序前调用
+func (nm *NovelManager) Close() error {
+	nm.mutex.RLock()
+	err := nm.saveChatIndex()
+	nm.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+	return nm.writer.close()
+}
+
+// RecoveryNotes 加载项目时的恢复和升级说明
+func (nm *NovelManager) RecoveryNotes() []string {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	return append([]string(nil), nm.recoveryNotes...)
+}
+
+// readProjectFile 读取项目数据文件；文件写了一半时依次尝试最新的临时文件和备份，调用方需持有锁
+func (nm *NovelManager) readProjectFile(name string) ([]byte, error) {
+	path := filepath.Join(nm.projectPath, name)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	exists := err == nil
+	if exists && json.Valid(data) {
+		removeStaleTemps(path)
+		return data, nil
+	}
+
+	for _, candidate := range recoveryCandidates(path) {
+		recovered, err := os.ReadFile(candidate)
+		if err != nil || !json.Valid(recovered) {
+			continue
+		}
+		if exists {
+			if err := preserveCorrupt(path); err != nil {
+				return nil, err
+			}
+		}
+		if err := writeFileAtomic(path, recovered, false); err != nil {
+			return nil, err
+		}
+		removeStaleTemps(path)
+		nm.recoveryNotes = append(nm.recoveryNotes, fmt.Sprintf("%s 不完整，已从 %s 恢复", name, filepath.Base(candidate)))
+		return recovered, nil
+	}
+
+	if !exists {
+		return nil, nil
+	}
+	// 无法恢复时保留损坏的文件，避免之后的保存覆盖掉它
+	if err := preserveCorrupt(path); err != nil {
+		return nil, err
+	}
+	nm.recoveryNotes = append(nm.recoveryNotes, fmt.Sprintf("%s 已损坏且没有可用备份，原文件已另存为 %s.corrupt-*", name, name))
+	return nil, fmt.Errorf("%s is corrupted and no backup is available", name)
+}
+
+// migrateProject 把旧版本项目数据升级到当前结构版本，升级前备份原文件，调用方需持有锁
+func (nm *NovelManager) migrateProject() error {
+	version := nm.novelData.SchemaVersion
+	if version > CurrentSchemaVersion {
+		return fmt.Errorf("project schema version %d is newer than supported version %d, please upgrade the tool", version, CurrentSchemaVersion)
+	}
+	if version == CurrentSchemaVersion {
+		return nil
+	}
+
+	for _, name := range []string{ProjectFile, ChatHistoryFile, ContentIndexFile} {
+		path := filepath.Join(nm.projectPath, name)
+		if err := copyFile(path, fmt.Sprintf("%s.v%d%s", path, version, backupSuffix)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to back up %s before migration: %w", name, err)
+		}
+	}
+	for v := version; v < CurrentSchemaVersion; v++ {
+		if err := projectMigrations[v](nm); err != nil {
+			return fmt.Errorf("failed to migrate project from version %d: %w", v, err)
+		}
+	}
+	nm.novelData.SchemaVersion = CurrentSchemaVersion
+	nm.recoveryNotes = append(nm.recoveryNotes, fmt.Sprintf("项目数据已从版本 %d 升级到 %d，原文件备份为 *.v%d%s", version, CurrentSchemaVersion, version, backupSuffix))
+
+	if err := nm.SaveProject(); err != nil {
+		return err
+	}
+	return nm.writer.flush()
+}
+
+// writeFileAtomic 先写临时文件并落盘，再替换目标文件；backup 为 true 时保留上一版本
+func writeFileAtomic(path string, data []byte, backup bool) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, tempPrefix(path)+"*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file for %s: %w", filepath.Base(path), err)
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to sync %s: %w", filepath.Base(path), err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close %s: %w", filepath.Base(path), err)
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to set permissions on %s: %w", filepath.Base(path), err)
+	}
+
+	if backup {
+		// 硬链接保留上一版本，目标文件在替换过程中始终存在
+		backupPath := path + backupSuffix
+		os.Remove(backupPath)
+		if err := os.Link(path, backupPath); err != nil && !os.IsNotExist(err) {
+			if err := copyFile(path, backupPath); err != nil && !os.IsNotExist(err) {
+				os.Remove(tmpPath)
+				return fmt.Errorf("failed to back up %s: %w", filepath.Base(path), err)
+			}
+		}
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace %s: %w", filepath.Base(path), err)
+	}
+	syncDir(dir)
+	return nil
+}
+
+// recoveryCandidates 按新旧顺序返回可用于恢复的文件：最新的临时文件在前，备份在后
+func recoveryCandidates(path string) []string {
+	temps, _ := filepath.Glob(filepath.Join(filepath.Dir(path), tempPrefix(path)+"*"))
+	sort.Slice(temps, func(i, j int) bool {
+		return modTime(temps[i]).After(modTime(temps[j]))
+	})
+	return append(temps, path+backupSuffix)
+}
+
+func removeStaleTemps(path string) {
+	temps, _ := filepath.Glob(filepath.Join(filepath.Dir(path), tempPrefix(path)+"*"))
+	for _, temp := range temps {
+		os.Remove(temp)
+	}
+}
+
+func preserveCorrupt(path string) error {
+	target := fmt.Sprintf("%s.corrupt-%s", path, time.Now().Format("20060102-150405"))
+	if err := os.Rename(path, target); err != nil {
+		return fmt.Errorf("failed to preserve corrupted %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+func tempPrefix(path string) string {
+	return "." + filepath.Base(path) + ".tmp-"
+}
+
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// syncDir 确保重命名写入目录项；部分平台不支持对目录 fsync，忽略错误
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
@@ -0,0 +1,255 @@
+package novel
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	tests := []struct {
+		name       string
+		existing   string // 为空表示目标文件不存在
+		backup     bool
+		wantBackup string // 为空表示不应有备份
+	}{
+		{"新文件", "", false, ""},
+		{"新文件要求备份", "", true, ""},
+		{"覆盖不备份", "旧内容", false, ""},
+		{"覆盖并备份", "旧内容", true, "旧内容"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "sub", "data.json")
+			if tt.existing != "" {
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(tt.existing), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := writeFileAtomic(path, []byte("新内容"), tt.backup); err != nil {
+				t.Fatalf("writeFileAtomic: %v", err)
+			}
+			if data, err := os.ReadFile(path); err != nil || string(data) != "新内容" {
+				t.Errorf("content = %q, %v", data, err)
+			}
+			if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0644 {
+				t.Errorf("mode = %v, %v, want 0644", info.Mode().Perm(), err)
+			}
+			backup, err := os.ReadFile(path + backupSuffix)
+			if tt.wantBackup == "" {
+				if err == nil {
+					t.Errorf("unexpected backup %q", backup)
+				}
+			} else if string(backup) != tt.wantBackup {
+				t.Errorf("backup = %q, %v, want %q", backup, err, tt.wantBackup)
+			}
+			if temps, _ := filepath.Glob(filepath.Join(filepath.Dir(path), tempPrefix(path)+"*")); len(temps) > 0 {
+				t.Errorf("temp files left behind: %v", temps)
+			}
+		})
+	}
+}
+
+func TestReadProjectFile(t *testing.T) {
+	const (
+		valid    = `{"title":"当前"}`
+		fromTemp = `{"title":"临时文件"}`
+		fromBak  = `{"title":"备份"}`
+		torn     = `{"title":"写到一`
+	)
+
+	tests := []struct {
+		name        string
+		main        string   // 为空表示文件不存在
+		temps       []string // 从新到旧
+		backup      string
+		want        string
+		wantErr     bool
+		wantCorrupt bool // 原文件是否另存为 .corrupt-*
+		wantNote    string
+	}{
+		{name: "文件完整", main: valid, temps: []string{fromTemp}, backup: fromBak, want: valid},
+		{name: "文件不存在", want: ""},
+		{name: "从最新的临时文件恢复", main: torn, temps: []string{fromTemp, `{"title":"更旧"}`}, backup: fromBak,
+			want: fromTemp, wantCorrupt: true, wantNote: "已从 ." + ProjectFile + ".tmp-"},
+		{name: "临时文件也损坏时用备份", main: torn, temps: []string{torn}, backup: fromBak,
+			want: fromBak, wantCorrupt: true, wantNote: "已从 " + ProjectFile + backupSuffix},
+		{name: "替换前中断只剩备份", backup: fromBak, want: fromBak, wantNote: "恢复"},
+		{name: "没有可用备份", main: torn, wantErr: true, wantCorrupt: true, wantNote: "没有可用备份"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			nm := NewNovelManager(dir)
+			t.Cleanup(func() { nm.Close() })
+			path := filepath.Join(dir, ProjectFile)
+
+			if tt.main != "" {
+				if err := os.WriteFile(path, []byte(tt.main), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			now := time.Now()
+			for i, content := range tt.temps {
+				temp := filepath.Join(dir, tempPrefix(path)+string(rune('a'+i)))
+				if err := os.WriteFile(temp, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+				modified := now.Add(-time.Duration(i) * time.Minute)
+				if err := os.Chtimes(temp, modified, modified); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.backup != "" {
+				if err := os.WriteFile(path+backupSuffix, []byte(tt.backup), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			data, err := nm.readProjectFile(ProjectFile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readProjectFile error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(data) != tt.want {
+				t.Errorf("data = %q, want %q", data, tt.want)
+			}
+			if tt.want != "" {
+				if onDisk, err := os.ReadFile(path); err != nil || string(onDisk) != tt.want {
+					t.Errorf("project file = %q, %v, want %q", onDisk, err, tt.want)
+				}
+				if temps, _ := filepath.Glob(filepath.Join(dir, tempPrefix(path)+"*")); len(temps) > 0 {
+					t.Errorf("stale temp files left: %v", temps)
+				}
+			}
+
+			corrupt, _ := filepath.Glob(path + ".corrupt-*")
+			if (len(corrupt) > 0) != tt.wantCorrupt {
+				t.Errorf("corrupt copies = %v, want %v", corrupt, tt.wantCorrupt)
+			}
+			if len(corrupt) > 0 {
+				if saved, _ := os.ReadFile(corrupt[0]); string(saved) != tt.main {
+					t.Errorf("corrupt copy = %q, want original %q", saved, tt.main)
+				}
+			}
+
+			notes := strings.Join(nm.recoveryNotes, "\n")
+			if tt.wantNote == "" && notes != "" {
+				t.Errorf("unexpected notes: %s", notes)
+			}
+			if !strings.Contains(notes, tt.wantNote) {
+				t.Errorf("notes = %q, want to contain %q", notes, tt.wantNote)
+			}
+		})
+	}
+}
+
+func TestLoadProjectMigration(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		wantErr bool
+	}{
+		{"从版本0升级", 0, false},
+		{"从版本1升级", 1, false},
+		{"当前版本不升级", CurrentSchemaVersion, false},
+		{"更新的版本拒绝加载", CurrentSchemaVersion + 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			project := map[string]interface{}{
+				"title":          "旧项目",
+				"schema_version": tt.version,
+				"plot_lines": map[string]interface{}{
+					"主线": map[string]interface{}{
+						"name":          "主线",
+						"foreshadowing": []interface{}{"玉佩的来历", map[string]interface{}{"id": "fs_7", "description": "断剑"}},
+					},
+				},
+			}
+			writeJSON(t, filepath.Join(dir, ProjectFile), project)
+			stamp := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+			writeJSON(t, filepath.Join(dir, ChatHistoryFile), []ChatRecord{
+				{ID: "chat_1", Timestamp: stamp, ChapterNum: 1, UserMessage: "第一条"},
+				{ID: "chat_1", Timestamp: stamp, ChapterNum: 2, UserMessage: "同一秒的第二条"},
+			})
+
+			nm := NewNovelManager(dir)
+			t.Cleanup(func() { nm.Close() })
+			err := nm.LoadProject()
+			if tt.wantErr {
+				if err == nil || nm.HasProject() {
+					t.Fatalf("expected newer schema to be refused, err = %v", err)
+				}
+				if err := nm.InitializeProject("覆盖", "", ""); err == nil {
+					t.Error("InitializeProject should refuse to overwrite an unloadable project")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadProject: %v", err)
+			}
+			if nm.novelData.SchemaVersion != CurrentSchemaVersion {
+				t.Errorf("schema version = %d, want %d", nm.novelData.SchemaVersion, CurrentSchemaVersion)
+			}
+			if tt.version == CurrentSchemaVersion {
+				if len(nm.RecoveryNotes()) > 0 {
+					t.Errorf("unexpected notes: %q", nm.RecoveryNotes())
+				}
+				return
+			}
+
+			if _, err := os.Stat(filepath.Join(dir, fmt.Sprintf("%s.v%d%s", ProjectFile, tt.version, backupSuffix))); err != nil {
+				t.Errorf("pre-migration backup missing: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, ChatHistoryFile)); !os.IsNotExist(err) {
+				t.Errorf("legacy chat history should be removed, stat err = %v", err)
+			}
+
+			items := nm.novelData.PlotLines["主线"].Foreshadowing
+			if tt.version == 0 {
+				if items[0].ID != "fs_8" || items[0].Status != ForeshadowPlanted || items[1].ID != "fs_7" {
+					t.Errorf("foreshadowing = %+v, %+v", *items[0], *items[1])
+				}
+				if len(nm.chatLog.entries) != 2 || nm.chatLog.entries["chat_1_2"] == nil {
+					t.Errorf("duplicate chat IDs not resolved: %d entries", len(nm.chatLog.entries))
+				}
+			}
+
+			// 升级后的文件应能直接加载，不再重复升级
+			nm.Close()
+			reloaded := NewNovelManager(dir)
+			t.Cleanup(func() { reloaded.Close() })
+			if err := reloaded.LoadProject(); err != nil {
+				t.Fatal(err)
+			}
+			if notes := reloaded.RecoveryNotes(); len(notes) > 0 {
+				t.Errorf("unexpected notes after reload: %q", notes)
+			}
+			if reloaded.novelData.Title != "旧项目" {
+				t.Errorf("title = %q", reloaded.novelData.Title)
+			}
+		})
+	}
+}
+
+func writeJSON(t *testing.T, path string, value interface{}) {
+	t.Helper()
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
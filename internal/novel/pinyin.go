@@ -0,0 +1,135 @@
+package novel
+
+import "strings"
+
+// pinyinTable 常用汉字的无声调拼音，用于发现术语的同音误写（如“筑基”写成“筑机”）。
+// 多音字在每个读音下各列一次，比较时任一读音相同即视为同音
+var pinyinTable = []string{
+	"a:阿啊", "ai:爱哀埃艾碍癌挨矮蔼", "an:安按暗岸案俺庵鞍", "ang:昂", "ao:奥傲澳熬凹敖遨",
+	"ba:八巴把爸吧拔霸罢坝芭疤", "bai:白百摆败拜柏佰", "ban:班般板半办伴搬版扮斑瓣颁", "bang:帮邦棒榜傍绑",
+	"bao:包宝保报抱暴豹饱爆堡褒薄", "bei:北被背杯备悲贝辈碑卑倍", "ben:本奔笨", "beng:崩泵蹦绷",
+	"bi:比必笔毕闭避壁碧币彼鼻逼弊臂璧毙", "bian:边变便编遍辩鞭辨扁", "biao:表标彪镖飙", "bie:别憋鳖",
+	"bin:宾彬斌滨濒鬓", "bing:兵冰病并饼丙秉炳", "bo:波博播伯薄拨勃驳泊玻搏帛", "bu:不部步布补捕簿",
+	"ca:擦", "cai:才材财彩菜采猜裁蔡踩", "can:参残惨蚕灿餐", "cang:苍藏仓沧舱", "cao:草操曹槽",
+	"ce:策册测侧厕", "cen:岑参", "ceng:层曾蹭", "cha:查茶差插察叉刹岔", "chai:柴拆豺差",
+	"chan:产缠禅蝉馋铲颤单", "chang:长常场唱厂肠尝畅昌倡猖", "chao:超朝潮炒吵巢钞", "che:车彻撤扯澈",
+	"chen:陈沉晨尘臣辰衬趁称", "cheng:成城程称承诚呈乘撑澄橙惩盛", "chi:吃持池迟尺齿赤斥驰痴翅炽",
+	"chong:冲虫崇充宠重", "chou:抽愁仇丑臭筹酬绸", "chu:出初处除楚础触储畜厨锄雏", "chuan:川传船穿串喘",
+	"chuang:创窗床闯疮", "chui:吹垂锤炊", "chun:春纯唇醇淳椿", "ci:次此词辞刺赐慈磁瓷雌差",
+	"cong:从聪丛匆葱", "cou:凑", "cu:粗促醋簇", "cuan:窜篡", "cui:催脆翠崔粹摧", "cun:村存寸",
+	"cuo:错措挫搓", "da:大达打答搭", "dai:代带待戴袋贷呆殆黛大", "dan:但单担丹蛋淡胆旦弹诞",
+	"dang:当党挡档荡", "dao:到道倒导刀岛盗稻蹈悼", "de:得德的地", "deng:等灯登邓瞪凳",
+	"di:地第帝底低敌弟抵递滴笛蒂迪的", "dian:点电店典殿甸垫颠奠淀", "diao:调掉钓吊雕刁", "die:跌蝶叠碟爹",
+	"ding:定顶丁订鼎钉盯", "diu:丢", "dong:东动懂冬洞董冻栋", "dou:都斗豆抖逗陡",
+	"du:度读独毒渡杜督堵肚镀都", "duan:段断短端锻", "dui:对队堆兑", "dun:顿盾吨蹲敦墩",
+	"duo:多夺朵躲堕舵", "e:恶饿额俄鹅娥厄", "en:恩", "er:而二儿尔耳", "fa:发法罚伐乏阀筏",
+	"fan:反范饭犯翻凡烦繁返泛帆番", "fang:方放房防访芳仿纺", "fei:非飞费肥废肺妃菲匪沸",
+	"fen:分份奋粉坟愤纷芬焚", "feng:风封丰峰锋疯奉逢凤枫蜂", "fo:佛", "fou:否",
+	"fu:服府父复富副付福负妇扶浮伏符附腹抚辅覆斧甫", "ga:嘎", "gai:该改盖概钙", "gan:干感敢赶甘肝杆竿",
+	"gang:刚钢港岗纲缸罡", "gao:高告搞稿糕膏", "ge:个各歌格哥割革阁隔葛戈鸽", "gei:给", "gen:根跟",
+	"geng:更耕庚梗", "gong:工公共功攻宫供恭弓贡躬", "gou:够构狗购沟钩勾苟", "gu:古故顾骨谷股鼓固孤姑菇辜",
+	"gua:挂瓜刮寡", "guai:怪乖拐", "guan:关官观管馆冠贯惯灌罐棺", "guang:光广逛",
+	"gui:规归贵鬼柜轨龟桂跪瑰", "gun:滚棍", "guo:国过果锅郭裹", "ha:哈", "hai:还海害孩骸亥",
+	"han:汉含寒喊汗韩函罕翰憾", "hang:航杭行", "hao:好号浩豪毫耗皓", "he:和合河何喝核荷贺赫禾鹤盒",
+	"hei:黑嘿", "hen:很恨痕狠", "heng:横恒衡哼", "hong:红洪宏虹鸿轰哄弘", "hou:后候厚侯喉吼猴",
+	"hu:湖户护呼虎忽胡互乎壶狐蝴葫弧和", "hua:话花化华画划滑哗", "huai:怀坏淮槐", "huan:环换欢缓唤幻患焕寰还",
+	"huang:黄皇荒慌煌晃凰恍", "hui:会回灰挥辉毁慧惠徽汇悔绘卉", "hun:婚魂混昏浑", "huo:活火或获货伙祸惑霍豁和",
+	"ji:机几级记计济即基急集击技及积纪吉极寄季绩忌既迹激疾祭姬籍鸡肌寂辑给奇系", "jia:家加价假甲佳架驾嘉夹",
+	"jian:见间件建简坚检剑肩渐践箭监艰健尖兼剪鉴舰", "jiang:将讲江奖降酱疆姜僵浆强",
+	"jiao:教交角较叫脚焦娇骄郊胶椒蕉矫觉", "jie:结界节解接街阶姐届截洁杰劫竭戒借介",
+	"jin:进今金近尽紧仅禁斤劲锦晋津襟巾筋", "jing:经京精境竟景静镜警惊井晶敬径净荆鲸", "jiong:窘迥",
+	"jiu:就九久究旧酒救纠揪鸠", "ju:局具据举句居巨剧聚拒俱菊鞠矩", "juan:卷捐倦绢娟眷", "jue:决绝觉掘爵诀角",
+	"jun:军均君俊峻骏菌", "ka:卡咖", "kai:开凯慨楷", "kan:看刊砍堪勘", "kang:康抗扛慷", "kao:考靠烤",
+	"ke:可科克客刻课颗壳渴柯", "ken:肯恳啃", "keng:坑", "kong:空控孔恐", "kou:口扣寇", "ku:苦库哭酷枯窟",
+	"kua:夸跨垮", "kuai:快块筷会", "kuan:宽款", "kuang:况狂矿框旷眶", "kui:亏愧溃魁葵窥", "kun:困昆坤",
+	"kuo:扩阔括", "la:拉啦蜡辣落", "lai:来莱赖", "lan:蓝兰烂拦篮览懒栏岚澜", "lang:浪狼郎朗廊琅",
+	"lao:老劳牢捞姥落", "le:了乐勒", "lei:类泪雷累垒磊蕾", "leng:冷愣",
+	"li:里理力利立李历例离丽礼黎厉励莉璃粒梨隶狸漓", "lian:连联练脸恋莲炼廉链怜帘涟",
+	"liang:两量良亮粮梁凉谅辆", "liao:料疗聊辽僚燎寥了", "lie:列烈裂猎劣", "lin:林临邻琳淋磷鳞麟凛",
+	"ling:领另令灵零龄铃岭玲凌陵菱伶翎", "liu:六流留刘柳溜琉", "long:龙隆笼聋拢陇", "lou:楼漏露搂",
+	"lu:路陆录鲁卢炉鹿禄芦露绿", "lv:绿律旅虑率吕履缕驴", "luan:乱卵", "lue:略掠", "lun:论轮伦",
+	"luo:落罗络洛逻螺骆萝", "ma:马妈吗码麻骂", "mai:买卖麦迈埋脉", "man:满慢漫蛮曼瞒", "mang:忙盲茫芒莽",
+	"mao:毛猫帽冒貌矛茅", "mei:没每美妹梅媒煤眉魅", "men:门们闷", "meng:梦猛蒙盟孟萌",
+	"mi:米密迷秘蜜弥谜觅", "mian:面免棉眠绵勉", "miao:妙秒苗庙描渺瞄", "mie:灭蔑", "min:民敏闽皿",
+	"ming:明名命鸣铭冥", "miu:谬", "mo:莫模末磨摸墨默魔膜陌漠没", "mou:某谋", "mu:目母木幕牧墓慕暮穆模",
+	"na:那拿纳娜", "nai:乃奶耐", "nan:南男难", "nao:脑恼闹", "nei:内", "nen:嫩", "neng:能",
+	"ni:你尼泥逆拟倪霓", "nian:年念粘", "niang:娘", "niao:鸟尿", "nie:捏涅孽", "ning:宁凝拧",
+	"niu:牛扭纽", "nong:农浓弄", "nu:怒奴努", "nv:女", "nuan:暖", "nuo:诺挪", "ou:欧偶鸥区",
+	"pa:怕爬帕", "pai:派排拍牌", "pan:判盘盼攀叛潘", "pang:旁胖庞", "pao:跑炮泡袍", "pei:配培陪佩沛裴",
+	"pen:喷盆", "peng:朋碰蓬彭鹏棚", "pi:批皮披疲脾匹僻譬劈", "pian:片篇偏骗便", "piao:票飘漂朴",
+	"pin:品贫拼频", "ping:平评凭瓶屏萍", "po:破迫坡婆魄泼泊", "pu:普铺朴谱扑仆浦蒲",
+	"qi:其起气期七器奇齐旗企妻启弃骑棋祈崎琪麒岐", "qia:恰掐卡", "qian:前钱千签潜迁浅欠牵谦乾虔",
+	"qiang:强墙枪腔抢羌", "qiao:桥巧瞧乔敲悄翘", "qie:切且窃", "qin:亲勤琴侵秦禽钦",
+	"qing:情清青轻请庆晴倾顷卿", "qiong:穷琼穹", "qiu:求球秋丘囚仇", "qu:去区取曲趣渠驱屈躯",
+	"quan:全权泉劝圈拳犬", "que:却确缺雀鹊", "qun:群裙", "ran:然燃染冉", "rang:让嚷壤", "rao:绕扰饶",
+	"re:热惹", "ren:人任认仁忍刃", "reng:仍扔", "ri:日", "rong:容荣融溶蓉绒熔戎", "rou:肉柔揉",
+	"ru:如入乳儒辱汝", "ruan:软阮", "rui:瑞锐蕊", "run:润闰", "ruo:若弱", "sa:撒洒萨", "sai:赛塞腮",
+	"san:三散伞", "sang:桑丧嗓", "sao:扫嫂骚", "se:色涩瑟", "sen:森", "sha:沙杀傻纱煞",
+	"shan:山善闪衫扇陕杉珊单禅", "shang:上商伤尚赏裳", "shao:少烧绍稍哨勺", "she:设社射舍蛇摄涉赦折",
+	"shen:身深神申审伸甚慎沈绅参", "sheng:生声胜圣省升盛绳剩笙乘",
+	"shi:是时事使式市世师始失施石实识十史士示室视试势释诗湿狮拾氏誓逝饰似", "shou:手受收首守授兽寿瘦售",
+	"shu:书数术树属输熟束述鼠殊舒叔疏淑蜀枢", "shua:刷耍", "shuai:帅衰摔甩率", "shuan:拴栓",
+	"shuang:双霜爽", "shui:水谁睡税", "shun:顺瞬舜", "shuo:说硕烁朔", "si:四思死私司斯丝寺似肆撕嘶",
+	"song:送松宋颂诵", "sou:搜艘", "su:素速苏诉宿塑俗肃酥溯", "suan:算酸蒜", "sui:随岁虽碎遂穗隋",
+	"sun:孙损笋", "suo:所索锁缩梭", "ta:他她它塔踏", "tai:太台态泰抬胎", "tan:谈探坦叹炭滩贪潭檀弹",
+	"tang:堂汤糖唐躺趟塘", "tao:讨逃套桃陶涛淘", "te:特", "teng:腾疼藤", "ti:提体题替梯踢蹄啼",
+	"tian:天田填甜添", "tiao:条跳挑调", "tie:铁贴", "ting:听停庭厅挺亭婷", "tong:同通统痛童铜桶筒瞳",
+	"tou:头投透偷", "tu:图土突途徒涂吐兔屠", "tuan:团", "tui:推退腿", "tun:吞屯", "tuo:托脱拖妥驼",
+	"wa:挖娃瓦蛙", "wai:外歪", "wan:万完晚玩弯碗湾婉宛", "wang:王往望网忘亡旺汪",
+	"wei:为位委未维卫微危威围味伟尾谓唯慰魏薇巍", "wen:文问温闻稳纹吻", "weng:翁", "wo:我握卧窝沃",
+	"wu:无物务五武误屋午吴舞悟雾巫乌污伍恶", "xi:西系息希细席习喜洗吸戏溪惜夕悉熙曦昔析稀",
+	"xia:下夏吓侠峡霞狭瞎", "xian:先现线限县显险鲜闲献仙贤弦纤衔", "xiang:向相想象香乡详响项祥箱湘翔降",
+	"xiao:小笑校消效晓销萧霄肖孝啸", "xie:些写谢协鞋斜邪血胁械泄解", "xin:心新信欣辛薪馨芯",
+	"xing:行性形星兴型醒幸刑姓邢省", "xiong:雄兄胸熊凶", "xiu:修休秀袖锈羞宿", "xu:需许续须序徐虚绪叙蓄旭",
+	"xuan:选宣悬旋玄轩萱炫", "xue:学雪穴薛血", "xun:寻训讯迅巡循逊熏", "ya:压呀亚牙鸭崖雅押涯",
+	"yan:研言严眼演验烟沿延岩炎颜盐艳燕宴焰衍妍彦", "yang:样养洋阳扬央仰杨羊痒", "yao:要药摇腰遥邀妖耀瑶尧",
+	"ye:也业夜叶野爷页液耶冶", "yi:一以已意义议医移衣依易异益艺亿疑遗仪宜亦忆役毅伊翼逸",
+	"yin:因音引银印隐饮阴殷吟", "ying:应英影营迎硬映赢鹰婴樱盈莹颖", "yong:用永勇拥涌庸咏",
+	"you:有又由友游右油优幽悠尤忧犹佑", "yu:于与语育遇雨鱼余玉预域誉宇羽愈欲御狱愚渔予郁裕",
+	"yuan:元员原远院源愿园圆缘援袁怨苑渊", "yue:月越约跃岳悦阅粤乐", "yun:云运允孕韵晕匀蕴", "za:杂砸扎",
+	"zai:在再载灾仔宰", "zan:咱赞暂", "zang:脏葬藏", "zao:造早遭燥糟灶", "ze:则责择泽", "zei:贼", "zen:怎",
+	"zeng:增赠憎曾", "zha:扎炸渣闸诈", "zhai:宅窄债摘寨", "zhan:战站展占斩沾盏詹湛颤",
+	"zhang:张章掌丈障帐杖彰长", "zhao:找照招赵召罩兆昭朝着", "zhe:这着者折哲浙遮", "zhen:真阵针震镇珍诊贞侦枕振",
+	"zheng:正政证争整征郑蒸挣筝", "zhi:之只知制治直至指支志质职止值置纸织智执旨植枝致芝脂",
+	"zhong:中种重众终钟忠仲衷", "zhou:周州洲舟昼宙皱骤", "zhu:主住注助著猪珠竹祝逐筑株烛诸朱柱铸驻属",
+	"zhua:抓", "zhuan:专转砖撰传", "zhuang:装状庄壮撞妆", "zhui:追坠缀", "zhun:准", "zhuo:捉桌卓灼浊琢着",
+	"zi:子自字资紫姿滋籽", "zong:总宗综纵踪棕", "zou:走奏邹", "zu:组族足祖阻租", "zuan:钻",
+	"zui:最罪醉嘴", "zun:尊遵", "zuo:做作左座坐昨",
+}
+
+// pinyinOf 汉字 -> 全部读音
+var pinyinOf = buildPinyinIndex()
+
+func buildPinyinIndex() map[rune][]string {
+	index := make(map[rune][]string)
+	for _, entry := range pinyinTable {
+		syllable, chars, _ := strings.Cut(entry, ":")
+		for _, r := range chars {
+			if !containsString(index[r], syllable) {
+				index[r] = append(index[r], syllable)
+			}
+		}
+	}
+	return index
+}
+
+// soundKeys 用于比较读音的键：表中有的汉字取全部读音，否则取字本身
+func soundKeys(r rune) []string {
+	if syllables, ok := pinyinOf[r]; ok {
+		return syllables
+	}
+	return []string{string(r)}
+}
+
+// sameSoundRune 两个字是否有相同的读音
+func sameSoundRune(a, b rune) bool {
+	if a == b {
+		return true
+	}
+	for _, key := range soundKeys(a) {
+		if containsString(soundKeys(b), key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,935 @@
+package novel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AiNovelTools/internal/ai"
+)
+
+// PipelineDir 章节生成流水线的状态目录
+const PipelineDir = "pipeline"
+
+// 流水线阶段，按执行顺序排列
+const (
+	StageContext = "context"
+	StagePlan    = "plan"
+	StageDraft   = "draft"
+	StageCheck   = "check"
+	StageWrite   = "write"
+)
+
+var pipelineStages = []string{StageContext, StagePlan, StageDraft, StageCheck, StageWrite}
+
+const (
+	// 未指定时的单章目标字数
+	defaultChapterWords = 3000
+	// 场景计划的最大场景数
+	maxPlanScenes = 8
+	// 上下文中附带的上一章结尾字数
+	previousEndingRunes = 800
+	// 起草场景时附带的已写内容结尾字数
+	sceneTailRunes = 600
+)
+
+// ScenePlan 场景计划及其草稿
+type ScenePlan struct {
+	Number      int       `json:"number"`
+	Title       string    `json:"title"`
+	Summary     string    `json:"summary"`
+	Characters  []string  `json:"characters,omitempty"`
+	Location    string    `json:"location,omitempty"`
+	TargetWords int       `json:"target_words"`
+	Draft       string    `json:"draft,omitempty"`
+	DraftedAt   time.Time `json:"drafted_at,omitempty"`
+}
+
+// PipelineRun 单章生成流水线的状态；每完成一步都写入 pipeline/chapter_NNN.json，可随时查看并从中断处继续
+type PipelineRun struct {
+	Chapter     int                 `json:"chapter"`
+	Title       string              `json:"title,omitempty"`
+	Beat        string              `json:"beat"`            // 本章大纲
+	Recap       string              `json:"recap,omitempty"` // 前情提要
+	TargetWords int                 `json:"target_words"`
+	Completed   []string            `json:"completed"` // 已完成的阶段
+	Context     string              `json:"context,omitempty"`
+	Scenes      []*ScenePlan        `json:"scenes,omitempty"`
+	Issues      []*ConsistencyIssue `json:"issues,omitempty"`
+	POV         *POVReport          `json:"pov,omitempty"`         // 视角与时态检查结果
+	Glossary    []*GlossaryIssue    `json:"glossary,omitempty"`    // 术语误写
+	StyleScore  float64             `json:"style_score,omitempty"` // 对照文风画像的贴合度，未建立画像时为0
+	WordCount   int                 `json:"word_count,omitempty"`
+	LastError   string              `json:"last_error,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+}
+
+// PipelineOptions 流水线运行选项
+type PipelineOptions struct {
+	Beat        string // 本章大纲，为空时使用章节概要
+	Recap       string // 前情提要，如批量生成时上一章的摘要
+	Title       string
+	TargetWords int
+	Until       string       // 执行到该阶段为止（含），为空时执行全部阶段
+	Rerun       string       // 从该阶段起重新执行，丢弃该阶段及之后的结果
+	Restart     bool         // 丢弃已有状态从头开始
+	Overwrite   bool         // 章节正文已存在时允许覆盖
+	Budget      func() error // 每次调用模型前检查预算，返回错误时保存进度并停止，可为空
+}
+
+// chatModel 流水线与批量生成调用模型所需的接口，*ai.Client 即其实现，测试中可换成离线桩
+type chatModel interface {
+	Chat(ctx context.Context, messages []ai.Message, tools []map[string]interface{}) (string, []ai.ToolCall, error)
+	Usage() ai.Usage
+	Cost(u ai.Usage) float64
+}
+
+// RunPipeline 按“组装上下文 → 场景计划 → 逐场景起草 → 一致性检查 → 写入章节”生成一章，
+// 已完成的阶段直接跳过，出错或取消时保留进度，再次调用即从中断处继续
+func (nm *NovelManager) RunPipeline(ctx context.Context, client *ai.Client, chapterNum int, opts PipelineOptions) (*PipelineRun, error) {
+	var model chatModel
+	if client != nil { // 空指针不能直接转成接口，否则判空失效
+		model = client
+	}
+	return nm.runPipeline(ctx, model, chapterNum, opts)
+}
+
+func (nm *NovelManager) runPipeline(ctx context.Context, model chatModel, chapterNum int, opts PipelineOptions) (*PipelineRun, error) {
+	if chapterNum <= 0 {
+		return nil, fmt.Errorf("invalid chapter number: %d", chapterNum)
+	}
+	for _, stage := range []string{opts.Until, opts.Rerun} {
+		if stage != "" && stageIndex(stage) < 0 {
+			return nil, fmt.Errorf("unknown pipeline stage: %s", stage)
+		}
+	}
+	if !nm.HasProject() {
+		return nil, fmt.Errorf("novel project not initialized")
+	}
+
+	var run *PipelineRun
+	if !opts.Restart {
+		loaded, err := nm.LoadPipelineRun(chapterNum)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		run = loaded
+	}
+	if run == nil {
+		run = nm.newPipelineRun(chapterNum)
+	}
+	run.applyOptions(opts)
+	if strings.TrimSpace(run.Beat) == "" {
+		return run, fmt.Errorf("第%d章没有大纲：请先填写章节概要，或提供本章大纲", chapterNum)
+	}
+
+	for _, stage := range pipelineStages {
+		if run.Done(stage) {
+			if stage == opts.Until {
+				break
+			}
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return run, nm.failPipeline(run, err)
+		}
+
+		var err error
+		switch stage {
+		case StageContext:
+			run.Context, err = nm.pipelineContext(run)
+		case StagePlan:
+			err = nm.planScenes(ctx, model, run, opts.Budget)
+		case StageDraft:
+			err = nm.draftScenes(ctx, model, run, opts.Budget)
+		case StageCheck:
+			err = nm.checkDraft(run)
+		case StageWrite:
+			err = nm.writeDraft(run, opts.Overwrite)
+		}
+		if err != nil {
+			return run, nm.failPipeline(run, err)
+		}
+
+		run.Completed = append(run.Completed, stage)
+		run.LastError = ""
+		if err := nm.savePipelineRun(run); err != nil {
+			return run, err
+		}
+		if stage == opts.Until {
+			break
+		}
+	}
+	return run, nil
+}
+
+// LoadPipelineRun 读取某章的流水线状态，不存在时返回 os.ErrNotExist
+func (nm *NovelManager) LoadPipelineRun(chapterNum int) (*PipelineRun, error) {
+	data, err := os.ReadFile(nm.pipelinePath(chapterNum))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no pipeline run for chapter %d: %w", chapterNum, os.ErrNotExist)
+		}
+		return nil, fmt.Errorf("failed to read pipeline state: %w", err)
+	}
+	var run PipelineRun
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("failed to parse pipeline state: %w", err)
+	}
+	return &run, nil
+}
+
+// PipelineStatePath 返回某章流水线状态文件的路径
+func (nm *NovelManager) PipelineStatePath(chapterNum int) string {
+	return nm.pipelinePath(chapterNum)
+}
+
+// Done 阶段是否已完成
+func (r *PipelineRun) Done(stage string) bool {
+	for _, completed := range r.Completed {
+		if completed == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// NextStage 下一个待执行的阶段，全部完成时返回空字符串
+func (r *PipelineRun) NextStage() string {
+	for _, stage := range pipelineStages {
+		if !r.Done(stage) {
+			return stage
+		}
+	}
+	return ""
+}
+
+// Text 按场景顺序拼接已起草的正文
+func (r *PipelineRun) Text() string {
+	parts := make([]string, 0, len(r.Scenes))
+	for _, scene := range r.Scenes {
+		if draft := strings.TrimSpace(scene.Draft); draft != "" {
+			parts = append(parts, draft)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// Format 生成流水线进度概览
+func (r *PipelineRun) Format() string {
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("🛠️ === 第%d章 生成流水线 ===\n\n", r.Chapter))
+	if r.Title != "" {
+		result.WriteString(fmt.Sprintf("标题: %s\n", r.Title))
+	}
+	result.WriteString(fmt.Sprintf("大纲: %s\n", truncateRunes(r.Beat, 200)))
+	result.WriteString(fmt.Sprintf("目标字数: %d\n\n", r.TargetWords))
+
+	drafted := 0
+	for _, scene := range r.Scenes {
+		if scene.Draft != "" {
+			drafted++
+		}
+	}
+	for _, stage := range pipelineStages {
+		icon := "⬜"
+		if r.Done(stage) {
+			icon = "✅"
+		} else if stage == r.NextStage() {
+			icon = "⏳"
+		}
+		detail := ""
+		switch stage {
+		case StagePlan:
+			if len(r.Scenes) > 0 {
+				detail = fmt.Sprintf("（%d 个场景）", len(r.Scenes))
+			}
+		case StageDraft:
+			if len(r.Scenes) > 0 {
+				detail = fmt.Sprintf("（%d/%d）", drafted, len(r.Scenes))
+			}
+		case StageCheck:
+			if r.Done(stage) {
+				detail = fmt.Sprintf("（%d 个问题）", r.IssueCount())
+				if r.StyleScore > 0 {
+					detail = fmt.Sprintf("（%d 个问题，文风贴合度 %.0f）", r.IssueCount(), r.StyleScore)
+				}
+			}
+		case StageWrite:
+			if r.Done(stage) {
+				detail = fmt.Sprintf("（%d 字，状态 draft）", r.WordCount)
+			}
+		}
+		result.WriteString(fmt.Sprintf("  %s %s%s\n", icon, stageLabel(stage), detail))
+	}
+
+	if r.LastError != "" {
+		result.WriteString(fmt.Sprintf("\n⚠️ 上次中断: %s\n", r.LastError))
+	}
+	if next := r.NextStage(); next != "" {
+		result.WriteString(fmt.Sprintf("\n下一步: %s，再次运行即从此处继续\n", stageLabel(next)))
+	}
+	return result.String()
+}
+
+// IssueCount 检查阶段发现的一致性、视角与术语问题总数
+func (r *PipelineRun) IssueCount() int {
+	count := len(r.Issues) + len(r.Glossary)
+	if r.POV != nil {
+		count += len(r.POV.Issues)
+	}
+	return count
+}
+
+// FormatStage 查看某一阶段的产出
+func (r *PipelineRun) FormatStage(stage string) string {
+	if stageIndex(stage) < 0 {
+		return fmt.Sprintf("未知阶段: %s（可选: %s）\n", stage, strings.Join(pipelineStages, ", "))
+	}
+	if !r.Done(stage) && !(stage == StageDraft && r.Text() != "") {
+		return fmt.Sprintf("「%s」阶段尚未完成\n", stageLabel(stage))
+	}
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("=== 第%d章 %s ===\n\n", r.Chapter, stageLabel(stage)))
+	switch stage {
+	case StageContext:
+		result.WriteString(r.Context)
+	case StagePlan:
+		result.WriteString(formatScenePlan(r.Scenes))
+	case StageDraft:
+		result.WriteString(r.Text())
+		result.WriteString("\n")
+	case StageCheck:
+		report := &ConsistencyReport{FromChapter: r.Chapter, ToChapter: r.Chapter, Checked: []int{r.Chapter}, Issues: r.Issues}
+		result.WriteString(report.Format())
+		if r.POV != nil && len(r.POV.Issues) > 0 {
+			result.WriteString("\n")
+			result.WriteString(r.POV.Format())
+		}
+		if len(r.Glossary) > 0 {
+			glossary := &GlossaryReport{Terms: len(r.Glossary), Checked: []int{r.Chapter}, Issues: r.Glossary}
+			result.WriteString("\n")
+			result.WriteString(glossary.Format())
+		}
+	case StageWrite:
+		result.WriteString(fmt.Sprintf("已写入 %s/chapter_%03d.txt，%d 字，状态 draft\n", ChaptersDir, r.Chapter, r.WordCount))
+	}
+	return result.String()
+}
+
+// newPipelineRun 以登记的章节信息创建新的流水线状态
+func (nm *NovelManager) newPipelineRun(chapterNum int) *PipelineRun {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	now := time.Now()
+	run := &PipelineRun{Chapter: chapterNum, Completed: make([]string, 0), CreatedAt: now, UpdatedAt: now}
+	if chapter := nm.findChapter(chapterNum); chapter != nil {
+		run.Title = chapter.Title
+		run.Beat = chapter.Summary
+	}
+	return run
+}
+
+// applyOptions 合并运行选项；大纲、标题或字数变化时作废受影响的阶段
+func (r *PipelineRun) applyOptions(opts PipelineOptions) {
+	if beat := strings.TrimSpace(opts.Beat); beat != "" && beat != r.Beat {
+		r.Beat = beat
+		r.resetFrom(StageContext)
+	}
+	if recap := strings.TrimSpace(opts.Recap); recap != "" && recap != r.Recap {
+		r.Recap = recap
+		r.resetFrom(StageContext)
+	}
+	if title := strings.TrimSpace(opts.Title); title != "" && title != r.Title {
+		r.Title = title
+		r.resetFrom(StageContext)
+	}
+	if opts.TargetWords > 0 && opts.TargetWords != r.TargetWords {
+		if r.TargetWords > 0 {
+			r.resetFrom(StagePlan)
+		}
+		r.TargetWords = opts.TargetWords
+	}
+	if r.TargetWords <= 0 {
+		r.TargetWords = defaultChapterWords
+	}
+	if opts.Rerun != "" {
+		r.resetFrom(opts.Rerun)
+	}
+}
+
+// resetFrom 丢弃指定阶段及之后的结果
+func (r *PipelineRun) resetFrom(stage string) {
+	from := stageIndex(stage)
+	if from < 0 {
+		return
+	}
+	kept := make([]string, 0, len(r.Completed))
+	for _, completed := range r.Completed {
+		if stageIndex(completed) < from {
+			kept = append(kept, completed)
+		}
+	}
+	r.Completed = kept
+
+	for _, later := range pipelineStages[from:] {
+		switch later {
+		case StageContext:
+			r.Context = ""
+		case StagePlan:
+			r.Scenes = nil
+		case StageDraft:
+			for _, scene := range r.Scenes {
+				scene.Draft = ""
+				scene.DraftedAt = time.Time{}
+			}
+		case StageCheck:
+			r.Issues = nil
+			r.POV = nil
+			r.Glossary = nil
+			r.StyleScore = 0
+		case StageWrite:
+			r.WordCount = 0
+		}
+	}
+}
+
+// pipelineContext 组装本章大纲、上一章结尾、出场角色与写作风格
+func (nm *NovelManager) pipelineContext(run *PipelineRun) (string, error) {
+	previous := ""
+	if run.Chapter > 1 {
+		if text, err := nm.ReadChapterText(run.Chapter - 1); err == nil {
+			previous = tailParagraphs(text, previousEndingRunes)
+		}
+	}
+
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	if nm.novelData == nil {
+		return "", fmt.Errorf("novel project not initialized")
+	}
+
+	var context strings.Builder
+	context.WriteString("=== 本章大纲 ===\n")
+	if run.Title != "" {
+		context.WriteString(fmt.Sprintf("第%d章 %s\n", run.Chapter, run.Title))
+	} else {
+		context.WriteString(fmt.Sprintf("第%d章\n", run.Chapter))
+	}
+	context.WriteString(strings.TrimSpace(run.Beat) + "\n")
+	if chapter := nm.findChapter(run.Chapter); chapter != nil {
+		if len(chapter.PlotLines) > 0 {
+			context.WriteString(fmt.Sprintf("相关情节: %s\n", strings.Join(chapter.PlotLines, "、")))
+		}
+		if chapter.POVCharacter != "" {
+			context.WriteString(fmt.Sprintf("视角人物: %s（只写%s能看到、听到和想到的内容）\n", chapter.POVCharacter, chapter.POVCharacter))
+		}
+	}
+	if beats := nm.outlineBeatLines(run.Chapter); beats != "" {
+		context.WriteString("结构节拍:\n" + beats)
+	}
+
+	if run.Recap != "" {
+		context.WriteString("\n=== 前情提要 ===\n")
+		context.WriteString(run.Recap + "\n")
+	}
+
+	context.WriteString("\n=== 上一章结尾 ===\n")
+	if previous != "" {
+		context.WriteString(previous + "\n")
+	} else if run.Chapter > 1 {
+		context.WriteString("（上一章正文不存在）\n")
+	} else {
+		context.WriteString("（本章为开篇）\n")
+	}
+
+	context.WriteString("\n=== 出场角色 ===\n")
+	names := nm.activeCharacters(run)
+	if len(names) == 0 {
+		context.WriteString("（未指定，可按大纲需要安排）\n")
+	}
+	for _, name := range names {
+		context.WriteString(nm.characterBrief(nm.novelData.Characters[name], run.Chapter) + "\n")
+	}
+
+	style := formatWritingStyle(nm.novelData.WritingStyle)
+	if profile := nm.novelData.StyleProfile; profile != nil {
+		style += profile.PromptGuide()
+	}
+	if style != "" {
+		context.WriteString("\n=== 写作风格 ===\n")
+		context.WriteString(style)
+	}
+	if glossary := nm.glossaryPrompt(run.Beat + run.Recap + previous); glossary != "" {
+		context.WriteString("\n=== 术语表 ===\n")
+		context.WriteString(glossary)
+	}
+
+	if reminders := nm.foreshadowingReminders(run.Chapter); reminders != "" {
+		context.WriteString("\n=== 伏笔提醒 ===\n")
+		context.WriteString(reminders)
+	}
+	if decisions := nm.explicitDecisions(run.Chapter, maxContextDecisions); len(decisions) > 0 {
+		context.WriteString("\n=== 创作决定 ===\n")
+		context.WriteString(FormatDecisions(decisions))
+	}
+	return context.String(), nil
+}
+
+// activeCharacters 本章出场角色：章节登记的角色、大纲中提到的角色，以及上一章仍在场的角色。调用方需持有锁
+func (nm *NovelManager) activeCharacters(run *PipelineRun) []string {
+	names := make([]string, 0)
+	add := func(name string) {
+		if _, ok := nm.novelData.Characters[name]; ok && !containsString(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	if chapter := nm.findChapter(run.Chapter); chapter != nil {
+		for _, name := range chapter.Characters {
+			add(name)
+		}
+	}
+	mentioned := findCharacterNames(run.Beat, sortedCharacterNames(nm.novelData.Characters))
+	for _, name := range mentioned {
+		add(name)
+	}
+	if previous := nm.findChapter(run.Chapter - 1); previous != nil {
+		for _, name := range previous.Characters {
+			// 已死亡的角色只在大纲明确提到时出场（如回忆）
+			if char, ok := nm.novelData.Characters[name]; ok && char.IsDead() && !containsString(mentioned, name) {
+				continue
+			}
+			add(name)
+		}
+	}
+	return names
+}
+
+// characterBrief 角色简介，调用方需持有锁
+func (nm *NovelManager) characterBrief(char *Character, chapterNum int) string {
+	parts := make([]string, 0, 6)
+	if nm.novelData.Timeline != nil {
+		if age, ok := nm.novelData.Timeline.AgeAt(char, chapterNum); ok {
+			parts = append(parts, fmt.Sprintf("%d岁", age))
+		}
+	} else if char.Age > 0 {
+		parts = append(parts, fmt.Sprintf("%d岁", char.Age))
+	}
+	if char.Occupation != "" {
+		parts = append(parts, char.Occupation)
+	}
+	if len(char.Personality) > 0 {
+		parts = append(parts, "性格"+strings.Join(char.Personality, "、"))
+	}
+	if char.Appearance != "" {
+		parts = append(parts, "外貌: "+char.Appearance)
+	}
+	if char.IsDead() {
+		parts = append(parts, fmt.Sprintf("已于第%d章死亡", char.DeathChapter))
+	}
+	brief := fmt.Sprintf("• %s", char.Name)
+	if len(parts) > 0 {
+		brief += "（" + strings.Join(parts, "，") + "）"
+	}
+	if char.Background != "" {
+		brief += ": " + truncateRunes(char.Background, 120)
+	}
+	if char.Voice != nil {
+		if summary := char.Voice.Summary(); summary != "" {
+			brief += "\n  说话习惯: " + summary
+		}
+	}
+	return brief
+}
+
+// planScenes 让模型把本章大纲拆成场景计划
+func (nm *NovelManager) planScenes(ctx context.Context, model chatModel, run *PipelineRun, budget func() error) error {
+	if model == nil {
+		return fmt.Errorf("AI client not available")
+	}
+	if err := checkPipelineBudget(budget); err != nil {
+		return err
+	}
+	prompt := fmt.Sprintf(`你是小说作者的写作助手，请为第%d章设计场景计划。
+
+%s
+要求：
+- 拆分为 2-%d 个场景，按发生顺序排列，覆盖本章大纲的全部要点
+- 紧接上一章结尾，不要重复已经发生的情节
+- 全章约 %d 字，按场景分量分配字数
+以JSON数组输出，不要输出其他内容，每项格式：
+{"title": "场景标题", "summary": "场景内容与作用", "characters": ["出场角色"], "location": "地点", "target_words": 字数}`,
+		run.Chapter, run.Context, maxPlanScenes, run.TargetWords)
+
+	response, _, err := model.Chat(ctx, []ai.Message{{Role: "user", Content: prompt}}, nil)
+	if err != nil {
+		return fmt.Errorf("scene planning failed: %w", err)
+	}
+
+	var scenes []*ScenePlan
+	if err := json.Unmarshal([]byte(extractJSONArray(response)), &scenes); err != nil {
+		return fmt.Errorf("failed to parse scene plan: %w", err)
+	}
+	planned := make([]*ScenePlan, 0, len(scenes))
+	for _, scene := range scenes {
+		if scene == nil || strings.TrimSpace(scene.Summary) == "" {
+			continue
+		}
+		scene.Draft = ""
+		planned = append(planned, scene)
+		if len(planned) == maxPlanScenes {
+			break
+		}
+	}
+	if len(planned) == 0 {
+		return fmt.Errorf("scene plan is empty")
+	}
+	distributeSceneWords(planned, run.TargetWords)
+	run.Scenes = planned
+	return nil
+}
+
+// draftScenes 逐场景起草，每写完一个场景就保存进度
+func (nm *NovelManager) draftScenes(ctx context.Context, model chatModel, run *PipelineRun, budget func() error) error {
+	if model == nil {
+		return fmt.Errorf("AI client not available")
+	}
+	if len(run.Scenes) == 0 {
+		return fmt.Errorf("scene plan is empty")
+	}
+
+	for i, scene := range run.Scenes {
+		if scene.Draft != "" {
+			continue
+		}
+		// 上一个场景已经保存，预算用尽时停在这里，续跑从本场景开始
+		if err := checkPipelineBudget(budget); err != nil {
+			return err
+		}
+		written := "（本章尚未开始，紧接上一章结尾写起）"
+		if text := run.Text(); text != "" {
+			written = tailParagraphs(text, sceneTailRunes)
+		}
+
+		var sceneDesc strings.Builder
+		sceneDesc.WriteString(fmt.Sprintf("标题: %s\n内容: %s\n", scene.Title, scene.Summary))
+		if len(scene.Characters) > 0 {
+			sceneDesc.WriteString(fmt.Sprintf("出场角色: %s\n", strings.Join(scene.Characters, "、")))
+		}
+		if scene.Location != "" {
+			sceneDesc.WriteString(fmt.Sprintf("地点: %s\n", scene.Location))
+		}
+
+		prompt := fmt.Sprintf(`你是小说作者，正在写第%d章的第%d/%d个场景。
+
+%s
+=== 本章场景计划 ===
+%s
+=== 已写内容结尾 ===
+%s
+
+=== 本场景 ===
+%s
+要求：约%d字，紧接已写内容，只写本场景，保持人物性格与写作风格一致。
+直接输出正文，不要写场景标题、说明或总结。`,
+			run.Chapter, i+1, len(run.Scenes), run.Context, formatScenePlan(run.Scenes), written, sceneDesc.String(), scene.TargetWords)
+
+		response, _, err := model.Chat(ctx, []ai.Message{{Role: "user", Content: prompt}}, nil)
+		if err != nil {
+			return fmt.Errorf("drafting scene %d failed: %w", i+1, err)
+		}
+		draft := cleanDraft(response)
+		if draft == "" {
+			return fmt.Errorf("scene %d draft is empty", i+1)
+		}
+		scene.Draft = draft
+		scene.DraftedAt = time.Now()
+		if err := nm.savePipelineRun(run); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDraft 对拼接后的草稿进行本地一致性检查；问题只记录不阻断，写入后可再修改
+func (nm *NovelManager) checkDraft(run *PipelineRun) error {
+	report, err := nm.CheckChapterText(run.Chapter, run.Text())
+	if err != nil {
+		return err
+	}
+	run.Issues = report.Issues
+	if run.POV, err = nm.CheckPOVText(run.Chapter, run.Text()); err != nil {
+		return err
+	}
+	glossary, err := nm.LintGlossaryText(run.Chapter, run.Text())
+	if err != nil {
+		return err
+	}
+	run.Glossary = glossary.Issues
+	run.StyleScore = 0
+	if drift, err := nm.StyleDriftOf(run.Chapter, run.Text()); err == nil {
+		run.StyleScore = drift.Score
+	}
+	return nil
+}
+
+// writeDraft 写入章节正文，并把章节登记为草稿
+func (nm *NovelManager) writeDraft(run *PipelineRun, overwrite bool) error {
+	text := run.Text()
+	if text == "" {
+		return fmt.Errorf("chapter draft is empty")
+	}
+	content := text + "\n"
+	// 已有正文且内容不同才需要确认覆盖，写入后中断重跑时内容相同可直接继续
+	if existing, err := nm.ReadChapterText(run.Chapter); err == nil && existing != content && !overwrite {
+		return fmt.Errorf("第%d章正文已存在，确认覆盖后重试", run.Chapter)
+	}
+	if err := os.MkdirAll(filepath.Join(nm.projectPath, ChaptersDir), 0755); err != nil {
+		return fmt.Errorf("failed to create chapters directory: %w", err)
+	}
+	if err := nm.saveChapterFile(run.Chapter, content, VersionGenerate, ""); err != nil {
+		return err
+	}
+
+	characters := make([]string, 0)
+	for _, scene := range run.Scenes {
+		for _, name := range scene.Characters {
+			if !containsString(characters, name) {
+				characters = append(characters, name)
+			}
+		}
+	}
+
+	nm.mutex.Lock()
+	if nm.novelData == nil {
+		nm.mutex.Unlock()
+		return fmt.Errorf("novel project not initialized")
+	}
+	chapter := nm.findChapter(run.Chapter)
+	if chapter == nil {
+		chapter = &Chapter{
+			Number:     run.Chapter,
+			Characters: make([]string, 0),
+			PlotLines:  make([]string, 0),
+			KeyEvents:  make([]string, 0),
+			Emotions:   make([]string, 0),
+		}
+		nm.novelData.Chapters = append(nm.novelData.Chapters, chapter)
+		sort.Slice(nm.novelData.Chapters, func(i, j int) bool {
+			return nm.novelData.Chapters[i].Number < nm.novelData.Chapters[j].Number
+		})
+	}
+	if run.Title != "" {
+		chapter.Title = run.Title
+	}
+	if chapter.Summary == "" {
+		chapter.Summary = run.Beat
+	}
+	for _, name := range characters {
+		if _, ok := nm.novelData.Characters[name]; ok && !containsString(chapter.Characters, name) {
+			chapter.Characters = append(chapter.Characters, name)
+		}
+	}
+	chapter.Status = "draft"
+	if run.Chapter > nm.novelData.CurrentChapter {
+		nm.novelData.CurrentChapter = run.Chapter
+	}
+	err := nm.SaveProject()
+	nm.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// 字数与当日进度交给统计同步处理
+	if _, err := nm.SyncChapterStats(); err != nil {
+		return err
+	}
+	run.WordCount = CountWords(text)
+	return nil
+}
+
+// checkPipelineBudget 调用模型前检查预算，未设置预算时不限制
+func checkPipelineBudget(budget func() error) error {
+	if budget == nil {
+		return nil
+	}
+	return budget()
+}
+
+// failPipeline 记录中断原因并保存进度
+func (nm *NovelManager) failPipeline(run *PipelineRun, err error) error {
+	run.LastError = err.Error()
+	if saveErr := nm.savePipelineRun(run); saveErr != nil {
+		return fmt.Errorf("%w (failed to save pipeline state: %v)", err, saveErr)
+	}
+	return err
+}
+
+func (nm *NovelManager) savePipelineRun(run *PipelineRun) error {
+	run.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal pipeline state: %w", err)
+	}
+	path := nm.pipelinePath(run.Chapter)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create pipeline directory: %w", err)
+	}
+	return writeFileAtomic(path, data, false)
+}
+
+func (nm *NovelManager) pipelinePath(chapterNum int) string {
+	return filepath.Join(nm.projectPath, PipelineDir, fmt.Sprintf("chapter_%03d.json", chapterNum))
+}
+
+// distributeSceneWords 补全或按比例缩放场景字数，使总数接近目标
+func distributeSceneWords(scenes []*ScenePlan, target int) {
+	total := 0
+	for i, scene := range scenes {
+		scene.Number = i + 1
+		if scene.TargetWords < 0 {
+			scene.TargetWords = 0
+		}
+		total += scene.TargetWords
+	}
+	for _, scene := range scenes {
+		if total == 0 {
+			scene.TargetWords = target / len(scenes)
+		} else {
+			scene.TargetWords = scene.TargetWords * target / total
+		}
+		if scene.TargetWords < 200 {
+			scene.TargetWords = 200
+		}
+	}
+}
+
+// formatScenePlan 格式化场景计划
+func formatScenePlan(scenes []*ScenePlan) string {
+	var result strings.Builder
+	for _, scene := range scenes {
+		result.WriteString(fmt.Sprintf("%d. %s（约%d字）: %s\n", scene.Number, scene.Title, scene.TargetWords, scene.Summary))
+		details := make([]string, 0, 2)
+		if len(scene.Characters) > 0 {
+			details = append(details, "角色: "+strings.Join(scene.Characters, "、"))
+		}
+		if scene.Location != "" {
+			details = append(details, "地点: "+scene.Location)
+		}
+		if len(details) > 0 {
+			result.WriteString("   " + strings.Join(details, " | ") + "\n")
+		}
+	}
+	return result.String()
+}
+
+// formatWritingStyle 格式化写作风格设置
+func formatWritingStyle(style WritingStyle) string {
+	var result strings.Builder
+	perspectives := map[string]string{"first": "第一人称", "third_limited": "第三人称有限视角", "third_omniscient": "第三人称全知视角"}
+	tenses := map[string]string{"past": "过去时", "present": "现在时"}
+	voices := map[string]string{"formal": "正式", "casual": "口语化", "poetic": "诗意"}
+	if style.Perspective != "" {
+		result.WriteString(fmt.Sprintf("视角: %s\n", labelOr(perspectives, style.Perspective)))
+	}
+	if style.Tense != "" {
+		result.WriteString(fmt.Sprintf("时态: %s\n", labelOr(tenses, style.Tense)))
+	}
+	if style.Voice != "" {
+		result.WriteString(fmt.Sprintf("语气: %s\n", labelOr(voices, style.Voice)))
+	}
+	if len(style.Themes) > 0 {
+		result.WriteString(fmt.Sprintf("主题: %s\n", strings.Join(style.Themes, "、")))
+	}
+	if len(style.ToneKeywords) > 0 {
+		result.WriteString(fmt.Sprintf("基调: %s\n", strings.Join(style.ToneKeywords, "、")))
+	}
+	return result.String()
+}
+
+func labelOr(labels map[string]string, key string) string {
+	if label, ok := labels[key]; ok {
+		return label
+	}
+	return key
+}
+
+// tailParagraphs 取正文末尾不超过 maxRunes 的完整段落；最后一段过长时截取其结尾
+func tailParagraphs(text string, maxRunes int) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	kept := make([]string, 0)
+	total := 0
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			continue
+		}
+		length := len([]rune(line))
+		if total+length > maxRunes {
+			if len(kept) == 0 {
+				runes := []rune(line)
+				kept = append(kept, "…"+string(runes[len(runes)-maxRunes:]))
+			}
+			break
+		}
+		kept = append(kept, line)
+		total += length
+	}
+	for i, j := 0, len(kept)-1; i < j; i, j = i+1, j-1 {
+		kept[i], kept[j] = kept[j], kept[i]
+	}
+	return strings.Join(kept, "\n")
+}
+
+// cleanDraft 去掉模型输出中的代码块围栏和开头的标题行
+func cleanDraft(text string) string {
+	lines := strings.Split(strings.ReplaceAll(strings.TrimSpace(text), "\r\n", "\n"), "\n")
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			continue
+		}
+		if len(kept) == 0 && (trimmed == "" || strings.HasPrefix(trimmed, "#")) {
+			continue
+		}
+		kept = append(kept, strings.TrimRight(line, " \t"))
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
+
+func stageIndex(stage string) int {
+	for i, s := range pipelineStages {
+		if s == stage {
+			return i
+		}
+	}
+	return -1
+}
+
+func stageLabel(stage string) string {
+	switch stage {
+	case StageContext:
+		return "组装上下文"
+	case StagePlan:
+		return "场景计划"
+	case StageDraft:
+		return "逐场景起草"
+	case StageCheck:
+		return "一致性检查"
+	case StageWrite:
+		return "写入章节"
+	}
+	return stage
+}
@@ -0,0 +1,326 @@
+package novel
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/AiNovelTools/internal/ai"
+)
+
+// stubChat 离线模型桩，由 respond 按提示词返回回复，并记录每次收到的提示词；
+// 每次调用计 100 输入 + 100 输出 token，每千 token 费用为 1
+type stubChat struct {
+	respond func(prompt string) (string, error)
+	prompts []string
+	usage   ai.Usage
+}
+
+func (s *stubChat) Chat(ctx context.Context, messages []ai.Message, tools []map[string]interface{}) (string, []ai.ToolCall, error) {
+	prompt := messages[len(messages)-1].Content
+	s.prompts = append(s.prompts, prompt)
+	s.usage = s.usage.Add(ai.Usage{Calls: 1, PromptTokens: 100, CompletionTokens: 100})
+	response, err := s.respond(prompt)
+	return response, nil, err
+}
+
+func (s *stubChat) Usage() ai.Usage { return s.usage }
+
+func (s *stubChat) Cost(u ai.Usage) float64 { return float64(u.TotalTokens()) / 1000 }
+
+const stubScenePlan = `好的，场景计划如下：
+[{"title": "夜探", "summary": "林风夜探藏经阁", "characters": ["林风"], "target_words": 1000},
+ {"title": "对峙", "summary": "林风与守阁长老对峙", "characters": ["林风"], "target_words": 1000}]`
+
+// stubPipelineReply 根据提示词返回场景计划或对应场景的正文
+func stubPipelineReply(prompt string) (string, error) {
+	switch {
+	case strings.Contains(prompt, "设计场景计划"):
+		return stubScenePlan, nil
+	case strings.Contains(prompt, "第1/2个场景"):
+		return "```\n林风趁夜色潜入藏经阁。\n```", nil
+	case strings.Contains(prompt, "第2/2个场景"):
+		return "# 对峙\n长老拦住了林风的去路。", nil
+	}
+	return "", errors.New("unexpected prompt")
+}
+
+func TestPipelineApplyOptions(t *testing.T) {
+	// completedRun 各阶段均已完成的流水线状态
+	completedRun := func() *PipelineRun {
+		return &PipelineRun{
+			Chapter:     1,
+			Title:       "夜探",
+			Beat:        "林风夜探藏经阁",
+			TargetWords: 3000,
+			Completed:   append([]string(nil), pipelineStages...),
+			Context:     "上下文",
+			Scenes:      []*ScenePlan{{Number: 1, Summary: "夜探", TargetWords: 3000, Draft: "正文"}},
+			Issues:      []*ConsistencyIssue{{Type: "character"}},
+			StyleScore:  0.8,
+			WordCount:   2,
+		}
+	}
+
+	tests := []struct {
+		name          string
+		opts          PipelineOptions
+		wantCompleted []string
+		wantContext   bool // 上下文保留
+		wantScenes    bool // 场景计划保留
+		wantDraft     bool // 场景草稿保留
+		wantIssues    bool // 检查结果保留
+		wantWords     int
+	}{
+		{
+			name:          "选项未变化时保留全部结果",
+			opts:          PipelineOptions{Beat: "林风夜探藏经阁", Title: "夜探", TargetWords: 3000},
+			wantCompleted: pipelineStages,
+			wantContext:   true, wantScenes: true, wantDraft: true, wantIssues: true,
+			wantWords: 3000,
+		},
+		{
+			name:          "大纲变化从上下文起重做",
+			opts:          PipelineOptions{Beat: "林风闯入禁地"},
+			wantCompleted: []string{},
+			wantWords:     3000,
+		},
+		{
+			name:          "前情变化从上下文起重做",
+			opts:          PipelineOptions{Recap: "上一章林风拜入宗门"},
+			wantCompleted: []string{},
+			wantWords:     3000,
+		},
+		{
+			name:          "字数变化从场景计划起重做",
+			opts:          PipelineOptions{TargetWords: 5000},
+			wantCompleted: []string{StageContext},
+			wantContext:   true,
+			wantWords:     5000,
+		},
+		{
+			name:          "从起草重做保留场景计划",
+			opts:          PipelineOptions{Rerun: StageDraft},
+			wantCompleted: []string{StageContext, StagePlan},
+			wantContext:   true, wantScenes: true,
+			wantWords: 3000,
+		},
+		{
+			name:          "从检查重做保留草稿",
+			opts:          PipelineOptions{Rerun: StageCheck},
+			wantCompleted: []string{StageContext, StagePlan, StageDraft},
+			wantContext:   true, wantScenes: true, wantDraft: true,
+			wantWords: 3000,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := completedRun()
+			run.applyOptions(tt.opts)
+
+			if !reflect.DeepEqual(run.Completed, tt.wantCompleted) {
+				t.Errorf("Completed = %v, want %v", run.Completed, tt.wantCompleted)
+			}
+			if got := run.Context != ""; got != tt.wantContext {
+				t.Errorf("context kept = %v, want %v", got, tt.wantContext)
+			}
+			if got := len(run.Scenes) > 0; got != tt.wantScenes {
+				t.Errorf("scenes kept = %v, want %v", got, tt.wantScenes)
+			}
+			if got := run.Text() != ""; got != tt.wantDraft {
+				t.Errorf("draft kept = %v, want %v", got, tt.wantDraft)
+			}
+			if got := run.Issues != nil && run.StyleScore > 0; got != tt.wantIssues {
+				t.Errorf("check results kept = %v, want %v", got, tt.wantIssues)
+			}
+			if got := run.WordCount > 0; got != tt.wantIssues {
+				t.Errorf("word count kept = %v, want %v", got, tt.wantIssues)
+			}
+			if run.TargetWords != tt.wantWords {
+				t.Errorf("TargetWords = %d, want %d", run.TargetWords, tt.wantWords)
+			}
+		})
+	}
+
+	t.Run("首次设置字数不作废已有结果", func(t *testing.T) {
+		run := completedRun()
+		run.TargetWords = 0
+		run.applyOptions(PipelineOptions{TargetWords: 5000})
+		if run.TargetWords != 5000 || len(run.Completed) != len(pipelineStages) {
+			t.Errorf("TargetWords = %d, Completed = %v", run.TargetWords, run.Completed)
+		}
+	})
+
+	t.Run("未设置字数时使用默认值", func(t *testing.T) {
+		run := &PipelineRun{}
+		run.applyOptions(PipelineOptions{})
+		if run.TargetWords != defaultChapterWords {
+			t.Errorf("TargetWords = %d, want %d", run.TargetWords, defaultChapterWords)
+		}
+	})
+}
+
+func TestDistributeSceneWords(t *testing.T) {
+	tests := []struct {
+		name   string
+		words  []int
+		target int
+		want   []int
+	}{
+		{name: "按比例放大", words: []int{1000, 500}, target: 3000, want: []int{2000, 1000}},
+		{name: "按比例缩小", words: []int{3000, 3000}, target: 3000, want: []int{1500, 1500}},
+		{name: "未给字数时平分", words: []int{0, 0, 0}, target: 3000, want: []int{1000, 1000, 1000}},
+		{name: "负数按零处理", words: []int{-500, 1000}, target: 2000, want: []int{200, 2000}},
+		{name: "每个场景至少200字", words: []int{100, 9900}, target: 1000, want: []int{200, 990}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scenes := make([]*ScenePlan, len(tt.words))
+			for i, words := range tt.words {
+				scenes[i] = &ScenePlan{Number: 9, TargetWords: words}
+			}
+			distributeSceneWords(scenes, tt.target)
+
+			got := make([]int, len(scenes))
+			for i, scene := range scenes {
+				got[i] = scene.TargetWords
+				if scene.Number != i+1 {
+					t.Errorf("scene %d numbered %d", i, scene.Number)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("distributeSceneWords = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTailParagraphs(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		maxRunes int
+		want     string
+	}{
+		{name: "全文不超限", text: "第一段\n\n第二段", maxRunes: 10, want: "第一段\n第二段"},
+		{name: "只取末尾的完整段落", text: "第一段\n第二段\n第三段", maxRunes: 7, want: "第二段\n第三段"},
+		{name: "最后一段过长时截取结尾", text: "第一段\n天色渐暗风雨欲来", maxRunes: 4, want: "…风雨欲来"},
+		{name: "兼容CRLF", text: "第一段\r\n第二段\r\n", maxRunes: 3, want: "第二段"},
+		{name: "空文本", text: "\n\n", maxRunes: 10, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tailParagraphs(tt.text, tt.maxRunes); got != tt.want {
+				t.Errorf("tailParagraphs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanDraft(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "去掉代码块围栏", text: "```text\n林风推开门。\n```", want: "林风推开门。"},
+		{name: "去掉开头的标题行", text: "\n# 第一章 夜探\n\n林风推开门。", want: "林风推开门。"},
+		{name: "正文中的井号保留", text: "林风推开门。\n# 门上刻着字", want: "林风推开门。\n# 门上刻着字"},
+		{name: "去掉行尾空白保留缩进", text: "　　林风推开门。  \n　　夜色正浓。\t", want: "林风推开门。\n　　夜色正浓。"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cleanDraft(tt.text); got != tt.want {
+				t.Errorf("cleanDraft() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunPipelineResumesAfterFailure(t *testing.T) {
+	nm := newTestManager(t)
+	ctx := context.Background()
+	opts := PipelineOptions{Beat: "林风夜探藏经阁", Title: "夜探", TargetWords: 2000}
+
+	// 第二个场景起草失败：场景计划和第一个场景的草稿应已保存
+	model := &stubChat{respond: func(prompt string) (string, error) {
+		if strings.Contains(prompt, "第2/2个场景") {
+			return "", errors.New("connection reset")
+		}
+		return stubPipelineReply(prompt)
+	}}
+	run, err := nm.runPipeline(ctx, model, 1, opts)
+	if err == nil || !strings.Contains(err.Error(), "drafting scene 2 failed") {
+		t.Fatalf("runPipeline error = %v, want scene 2 failure", err)
+	}
+	if len(model.prompts) != 3 {
+		t.Fatalf("got %d model calls, want plan and two scenes", len(model.prompts))
+	}
+
+	saved, err := nm.LoadPipelineRun(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{StageContext, StagePlan}; !reflect.DeepEqual(saved.Completed, want) {
+		t.Errorf("saved Completed = %v, want %v", saved.Completed, want)
+	}
+	if saved.NextStage() != StageDraft || !strings.Contains(saved.LastError, "connection reset") {
+		t.Errorf("saved NextStage = %q, LastError = %q", saved.NextStage(), saved.LastError)
+	}
+	if len(saved.Scenes) != 2 || saved.Scenes[0].Draft != "林风趁夜色潜入藏经阁。" || saved.Scenes[1].Draft != "" {
+		t.Fatalf("saved scenes = %+v", saved.Scenes)
+	}
+	if run.Scenes[0].TargetWords != 1000 {
+		t.Errorf("scene words = %d, want 1000", run.Scenes[0].TargetWords)
+	}
+	if _, err := nm.ReadChapterText(1); err == nil {
+		t.Error("chapter written before the draft was complete")
+	}
+
+	// 续跑只起草第二个场景，不重做计划和第一个场景
+	model = &stubChat{respond: stubPipelineReply}
+	run, err = nm.runPipeline(ctx, model, 1, opts)
+	if err != nil {
+		t.Fatalf("resumed runPipeline: %v", err)
+	}
+	if len(model.prompts) != 1 || !strings.Contains(model.prompts[0], "第2/2个场景") {
+		t.Fatalf("resumed run prompts = %d, want only scene 2", len(model.prompts))
+	}
+	if !strings.Contains(model.prompts[0], "林风趁夜色潜入藏经阁。") {
+		t.Error("scene 2 prompt does not continue from scene 1")
+	}
+	if !reflect.DeepEqual(run.Completed, pipelineStages) || run.LastError != "" {
+		t.Errorf("Completed = %v, LastError = %q", run.Completed, run.LastError)
+	}
+
+	text, err := nm.ReadChapterText(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "林风趁夜色潜入藏经阁。\n\n长老拦住了林风的去路。\n"; text != want {
+		t.Errorf("chapter text = %q, want %q", text, want)
+	}
+	if chapter := nm.findChapter(1); chapter == nil || chapter.Title != "夜探" || chapter.Status != "draft" {
+		t.Errorf("chapter record = %+v", chapter)
+	}
+
+	// 全部完成后再次运行不再调用模型
+	model = &stubChat{respond: stubPipelineReply}
+	if _, err := nm.runPipeline(ctx, model, 1, opts); err != nil || len(model.prompts) != 0 {
+		t.Errorf("completed run: err = %v, %d model calls", err, len(model.prompts))
+	}
+}
+
+func TestRunPipelineWithoutClient(t *testing.T) {
+	nm := newTestManager(t)
+	run, err := nm.RunPipeline(context.Background(), nil, 1, PipelineOptions{Beat: "林风夜探藏经阁"})
+	if err == nil || !strings.Contains(err.Error(), "AI client not available") {
+		t.Fatalf("RunPipeline error = %v, want missing client", err)
+	}
+	// 不需要模型的阶段照常完成并保存
+	if !run.Done(StageContext) || run.NextStage() != StagePlan {
+		t.Errorf("Completed = %v", run.Completed)
+	}
+}
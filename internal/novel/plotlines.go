@@ -0,0 +1,55 @@
+package novel
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AddPlotLine 添加情节线；同名情节线已存在时更新其非空字段，已有事件和伏笔保持不变
+func (nm *NovelManager) AddPlotLine(plot *PlotLine) (bool, error) {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	if nm.novelData == nil {
+		return false, fmt.Errorf("novel project not initialized")
+	}
+	plot.Name = strings.TrimSpace(plot.Name)
+	if plot.Name == "" {
+		return false, fmt.Errorf("plot line name is required")
+	}
+	if nm.novelData.PlotLines == nil {
+		nm.novelData.PlotLines = make(map[string]*PlotLine)
+	}
+
+	existing, exists := nm.novelData.PlotLines[plot.Name]
+	if !exists {
+		if plot.Status == "" {
+			plot.Status = "active"
+		}
+		if plot.KeyEvents == nil {
+			plot.KeyEvents = make([]PlotEvent, 0)
+		}
+		if plot.Foreshadowing == nil {
+			plot.Foreshadowing = make([]*Foreshadowing, 0)
+		}
+		nm.novelData.PlotLines[plot.Name] = plot
+		return false, nm.SaveProject()
+	}
+
+	if plot.Type != "" {
+		existing.Type = plot.Type
+	}
+	if plot.Status != "" {
+		existing.Status = plot.Status
+	}
+	if plot.Description != "" {
+		existing.Description = plot.Description
+	}
+	if plot.StartChapter > 0 {
+		existing.StartChapter = plot.StartChapter
+	}
+	if plot.EndChapter > 0 {
+		existing.EndChapter = plot.EndChapter
+	}
+	return true, nm.SaveProject()
+}
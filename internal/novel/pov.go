@@ -0,0 +1,479 @@
+package novel
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 视角与时态问题类型
+const (
+	POVHeadHopping     = "head_hopping"      // 有限视角下写了非视角人物的内心
+	POVFirstPersonSlip = "first_person_slip" // 第三人称叙述中混入“我”
+	POVNarratorInsight = "narrator_insight"  // 第一人称叙述者写出了他人的内心
+	POVTenseShift      = "tense_shift"       // 叙述时间参照与时态设定不符
+)
+
+// POVIssue 视角或时态问题，定位到章节与段落（段落、行号均从1开始，段落不计空行）
+type POVIssue struct {
+	Type      string `json:"type"`
+	Severity  string `json:"severity"`
+	Chapter   int    `json:"chapter"`
+	Paragraph int    `json:"paragraph"`
+	Line      int    `json:"line"`
+	Subject   string `json:"subject"` // 涉及的角色或时间词
+	Message   string `json:"message"`
+	Excerpt   string `json:"excerpt"`
+}
+
+// POVChapter 单章的视角检查概况
+type POVChapter struct {
+	Chapter      int    `json:"chapter"`
+	Perspective  string `json:"perspective"`
+	POVCharacter string `json:"pov_character,omitempty"`
+	Inferred     bool   `json:"inferred,omitempty"` // 视角人物未指定，按内心描写最多的角色推断
+	Paragraphs   int    `json:"paragraphs"`
+}
+
+// POVReport 视角与时态检查报告
+type POVReport struct {
+	Perspective string       `json:"perspective"`
+	Tense       string       `json:"tense"`
+	Chapters    []POVChapter `json:"chapters"`
+	Missing     []int        `json:"missing,omitempty"`
+	Issues      []*POVIssue  `json:"issues,omitempty"`
+}
+
+var (
+	// 内心活动标记：紧跟在角色名后出现时，说明叙述进入了该角色的内心
+	innerStateMarkers  = `心中|心里|心头|心底|内心|心想|心道|心说|暗想|暗道|暗忖|心忖|暗自|暗暗|觉得|感到|感觉到|意识到|想起|想到|不禁想`
+	innerMarkerPattern = regexp.MustCompile(innerStateMarkers)
+	// 内心独白的引出语，其后的“我”属于独白而非叙述
+	innerSpeechPattern = regexp.MustCompile(`(?:心想|心道|心说|暗想|暗道|暗忖|心忖|想道|自语道|默念)[：:，,]`)
+	// 含“我”但不是第一人称的固定词
+	firstPersonWords = []string{"自我", "忘我", "无我", "唯我", "敌我", "物我", "我行我素"}
+	// 以“此时此地”为参照的时间词，过去时叙述中应改为“当天”“次日”“此时”等
+	presentDeictics = []string{"今天", "今晚", "今早", "昨天", "昨晚", "明天", "明早", "明晚", "这会儿", "现在"}
+	// 以“彼时”为参照的时间词，现在时叙述中应改为“今天”“明天”“现在”等
+	pastDeictics = []string{"次日", "翌日", "前一天", "当天", "当晚", "那天"}
+)
+
+// SetChapterPOV 指定章节的视角人物，name 为空时清除
+func (nm *NovelManager) SetChapterPOV(chapterNum int, name string) error {
+	name = strings.TrimSpace(name)
+
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	if nm.novelData == nil {
+		return fmt.Errorf("novel project not initialized")
+	}
+	chapter := nm.findChapter(chapterNum)
+	if chapter == nil {
+		return fmt.Errorf("chapter %d not found", chapterNum)
+	}
+	if name != "" {
+		if _, ok := nm.novelData.Characters[name]; !ok {
+			return fmt.Errorf("character not found: %s", name)
+		}
+	}
+	chapter.POVCharacter = name
+	return nm.SaveProject()
+}
+
+// CheckPOV 检查章节正文的视角与时态，from/to 为0时表示不限
+func (nm *NovelManager) CheckPOV(fromChapter, toChapter int) (*POVReport, error) {
+	analyzer, numbers, err := nm.newPOVAnalyzer()
+	if err != nil {
+		return nil, err
+	}
+	if files, err := nm.listChapterNumbers(); err == nil {
+		numbers = append(numbers, files...)
+	}
+	numbers = uniqueSortedInts(numbers)
+
+	report := analyzer.newReport()
+	for _, number := range numbers {
+		if (fromChapter > 0 && number < fromChapter) || (toChapter > 0 && number > toChapter) {
+			continue
+		}
+		text, err := nm.ReadChapterText(number)
+		if err != nil {
+			report.Missing = append(report.Missing, number)
+			continue
+		}
+		analyzer.checkChapter(report, number, text)
+	}
+	return report, nil
+}
+
+// CheckPOVText 检查尚未写入文件的单章正文（如生成的草稿）的视角与时态
+func (nm *NovelManager) CheckPOVText(chapterNum int, text string) (*POVReport, error) {
+	analyzer, _, err := nm.newPOVAnalyzer()
+	if err != nil {
+		return nil, err
+	}
+	report := analyzer.newReport()
+	analyzer.checkChapter(report, chapterNum, text)
+	return report, nil
+}
+
+// povAnalyzer 基于项目设定副本的视角检查器
+type povAnalyzer struct {
+	perspective string // first, third_limited, third_omniscient，未设定时按文风画像或正文推断
+	tense       string
+	names       []string
+	assigned    map[int]string // 章节指定的视角人物
+	innerState  *regexp.Regexp
+}
+
+// newPOVAnalyzer 基于当前设定创建视角检查器，并返回已登记的章节号
+func (nm *NovelManager) newPOVAnalyzer() (*povAnalyzer, []int, error) {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	if nm.novelData == nil {
+		return nil, nil, fmt.Errorf("novel project not initialized")
+	}
+	analyzer := &povAnalyzer{
+		perspective: nm.novelData.WritingStyle.Perspective,
+		tense:       nm.novelData.WritingStyle.Tense,
+		names:       sortedCharacterNames(nm.novelData.Characters),
+		assigned:    make(map[int]string),
+	}
+	if analyzer.perspective == "" && nm.novelData.StyleProfile != nil {
+		analyzer.perspective = nm.novelData.StyleProfile.POV
+	}
+	if analyzer.tense == "" {
+		analyzer.tense = "past"
+	}
+	numbers := make([]int, 0, len(nm.novelData.Chapters))
+	for _, chapter := range nm.novelData.Chapters {
+		numbers = append(numbers, chapter.Number)
+		if chapter.POVCharacter != "" {
+			analyzer.assigned[chapter.Number] = chapter.POVCharacter
+		}
+	}
+	if len(analyzer.names) > 0 {
+		quoted := make([]string, len(analyzer.names))
+		for i, name := range analyzer.names {
+			quoted[i] = regexp.QuoteMeta(name)
+		}
+		analyzer.innerState = regexp.MustCompile(`(` + strings.Join(quoted, "|") + `)(?:的|也|却|则|顿时|不禁|忍不住)?(?:` + innerStateMarkers + `)`)
+	}
+	return analyzer, numbers, nil
+}
+
+func (a *povAnalyzer) newReport() *POVReport {
+	return &POVReport{Perspective: a.perspective, Tense: a.tense}
+}
+
+// povParagraph 去掉对话与内心独白后的段落叙述
+type povParagraph struct {
+	number    int
+	line      int
+	text      string
+	narration string
+}
+
+// checkChapter 检查单章并把结果追加到报告
+func (a *povAnalyzer) checkChapter(report *POVReport, chapterNum int, text string) {
+	paragraphs := narrationParagraphs(text)
+	perspective := a.perspective
+	if perspective == "" {
+		perspective = AnalyzeStyle(text).pov()
+	}
+	summary := POVChapter{Chapter: chapterNum, Perspective: perspective, Paragraphs: len(paragraphs)}
+
+	// 每段中进入了哪些角色的内心
+	inner := make([][]string, len(paragraphs))
+	for i, paragraph := range paragraphs {
+		inner[i] = a.innerCharacters(paragraph.narration)
+	}
+
+	if perspective == "first" {
+		summary.POVCharacter = a.assigned[chapterNum]
+		for i, paragraph := range paragraphs {
+			for _, name := range inner[i] {
+				if name == summary.POVCharacter {
+					continue
+				}
+				report.add(&POVIssue{
+					Type:      POVNarratorInsight,
+					Severity:  SeverityWarning,
+					Chapter:   chapterNum,
+					Paragraph: paragraph.number,
+					Line:      paragraph.line,
+					Subject:   name,
+					Message:   fmt.Sprintf("第一人称叙述者无法直接知道「%s」的内心，可改为通过神情、动作推测", name),
+					Excerpt:   excerptAround(paragraph.text, name),
+				})
+			}
+		}
+	} else {
+		for _, paragraph := range paragraphs {
+			if slip := firstPersonSlip(paragraph.narration); slip != "" {
+				report.add(&POVIssue{
+					Type:      POVFirstPersonSlip,
+					Severity:  SeverityError,
+					Chapter:   chapterNum,
+					Paragraph: paragraph.number,
+					Line:      paragraph.line,
+					Subject:   slip,
+					Message:   fmt.Sprintf("第三人称叙述中出现了“%s”（对话与内心独白之外）", slip),
+					Excerpt:   excerptAround(paragraph.text, slip),
+				})
+			}
+		}
+		if perspective == "third_limited" {
+			a.checkHeadHopping(report, &summary, paragraphs, inner)
+		}
+	}
+
+	for _, paragraph := range paragraphs {
+		if marker := a.tenseShift(paragraph.narration); marker != "" {
+			report.add(&POVIssue{
+				Type:      POVTenseShift,
+				Severity:  SeverityWarning,
+				Chapter:   chapterNum,
+				Paragraph: paragraph.number,
+				Line:      paragraph.line,
+				Subject:   marker,
+				Message:   tenseShiftMessage(a.tense, marker),
+				Excerpt:   excerptAround(paragraph.text, marker),
+			})
+		}
+	}
+	report.Chapters = append(report.Chapters, summary)
+}
+
+// checkHeadHopping 有限视角下只能进入视角人物的内心；未指定视角人物时取内心描写最多的角色
+func (a *povAnalyzer) checkHeadHopping(report *POVReport, summary *POVChapter, paragraphs []povParagraph, inner [][]string) {
+	pov := a.assigned[summary.Chapter]
+	if pov == "" {
+		counts := make(map[string]int)
+		first := make(map[string]int)
+		for i, names := range inner {
+			for _, name := range names {
+				if _, ok := first[name]; !ok {
+					first[name] = i
+				}
+				counts[name]++
+			}
+		}
+		for name, count := range counts {
+			if pov == "" || count > counts[pov] || (count == counts[pov] && first[name] < first[pov]) {
+				pov = name
+			}
+		}
+		summary.Inferred = pov != ""
+	}
+	summary.POVCharacter = pov
+	if pov == "" {
+		return
+	}
+
+	for i, paragraph := range paragraphs {
+		for _, name := range inner[i] {
+			if name == pov {
+				continue
+			}
+			message := fmt.Sprintf("本章视角人物是「%s」，此段却写了「%s」的内心", pov, name)
+			if containsString(inner[i], pov) {
+				message = fmt.Sprintf("同一段中既写了「%s」又写了「%s」的内心", pov, name)
+			}
+			report.add(&POVIssue{
+				Type:      POVHeadHopping,
+				Severity:  SeverityWarning,
+				Chapter:   summary.Chapter,
+				Paragraph: paragraph.number,
+				Line:      paragraph.line,
+				Subject:   name,
+				Message:   message,
+				Excerpt:   excerptAround(paragraph.text, name),
+			})
+		}
+	}
+}
+
+// innerCharacters 叙述中被写到内心活动的角色，按出现顺序去重。
+// 内心标记紧跟角色名时归于该角色，否则归于同一句中标记之前最先出现的角色（通常是句子主语）
+func (a *povAnalyzer) innerCharacters(narration string) []string {
+	names := make([]string, 0)
+	if a.innerState == nil {
+		return names
+	}
+	add := func(name string) {
+		if name != "" && !containsString(names, name) {
+			names = append(names, name)
+		}
+	}
+	for _, sentence := range sentenceEndPattern.FindAllString(narration, -1) {
+		attributed := make(map[int]bool)
+		for _, match := range a.innerState.FindAllStringSubmatchIndex(sentence, -1) {
+			add(sentence[match[2]:match[3]])
+			attributed[match[1]] = true
+		}
+		for _, loc := range innerMarkerPattern.FindAllStringIndex(sentence, -1) {
+			if attributed[loc[1]] {
+				continue
+			}
+			if found := findCharacterNames(sentence[:loc[0]], a.names); len(found) > 0 {
+				add(firstOccurring(sentence, found))
+			}
+		}
+	}
+	return names
+}
+
+// firstOccurring 返回在文本中最先出现的名字
+func firstOccurring(text string, names []string) string {
+	first, firstIdx := "", -1
+	for _, name := range names {
+		if idx := strings.Index(text, name); idx >= 0 && (firstIdx < 0 || idx < firstIdx) {
+			first, firstIdx = name, idx
+		}
+	}
+	return first
+}
+
+// tenseShift 返回叙述中与时态设定不符的第一个时间词
+func (a *povAnalyzer) tenseShift(narration string) string {
+	markers := presentDeictics
+	if a.tense == "present" {
+		markers = pastDeictics
+	}
+	return firstOccurring(narration, markers)
+}
+
+func tenseShiftMessage(tense, marker string) string {
+	if tense == "present" {
+		return fmt.Sprintf("现在时叙述中出现以过去为参照的“%s”", marker)
+	}
+	return fmt.Sprintf("过去时叙述中出现以当下为参照的“%s”，可改为“当天”“次日”“此时”等", marker)
+}
+
+// narrationParagraphs 按非空行切分段落，并去掉对话与内心独白
+func narrationParagraphs(text string) []povParagraph {
+	paragraphs := make([]povParagraph, 0)
+	for i, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		narration, _ := splitDialogue(line)
+		var kept strings.Builder
+		for _, sentence := range sentenceEndPattern.FindAllString(narration, -1) {
+			if loc := innerSpeechPattern.FindStringIndex(sentence); loc != nil {
+				sentence = sentence[:loc[0]]
+			}
+			kept.WriteString(sentence)
+		}
+		paragraphs = append(paragraphs, povParagraph{number: len(paragraphs) + 1, line: i + 1, text: line, narration: kept.String()})
+	}
+	return paragraphs
+}
+
+// firstPersonSlip 叙述中出现的第一人称代词
+func firstPersonSlip(narration string) string {
+	for _, word := range firstPersonWords {
+		narration = strings.ReplaceAll(narration, word, "")
+	}
+	if strings.Contains(narration, "我们") {
+		return "我们"
+	}
+	if strings.Contains(narration, "我") {
+		return "我"
+	}
+	return ""
+}
+
+func (r *POVReport) add(issue *POVIssue) {
+	r.Issues = append(r.Issues, issue)
+}
+
+// Format 格式化视角检查报告
+func (r *POVReport) Format() string {
+	var result strings.Builder
+	result.WriteString("👁 === 视角与时态检查 ===\n\n")
+
+	if len(r.Chapters) == 0 {
+		result.WriteString("没有可检查的章节正文\n")
+		return result.String()
+	}
+	tense := labelOr(map[string]string{"past": "过去时", "present": "现在时"}, r.Tense)
+	if r.Perspective != "" {
+		result.WriteString(fmt.Sprintf("视角设定: %s，时态: %s\n", perspectiveLabel(r.Perspective), tense))
+	} else {
+		result.WriteString(fmt.Sprintf("视角设定: 未设定（按正文推断），时态: %s\n", tense))
+	}
+	result.WriteString(fmt.Sprintf("检查范围: 第%d章 - 第%d章（共 %d 章）\n", r.Chapters[0].Chapter, r.Chapters[len(r.Chapters)-1].Chapter, len(r.Chapters)))
+	if len(r.Missing) > 0 {
+		result.WriteString(fmt.Sprintf("⚠️ 缺少正文文件: %s\n", joinInts(r.Missing, ", ")))
+	}
+
+	issues := make(map[int][]*POVIssue)
+	for _, issue := range r.Issues {
+		issues[issue.Chapter] = append(issues[issue.Chapter], issue)
+	}
+	if len(r.Issues) == 0 {
+		result.WriteString("\n✅ 未发现视角或时态问题\n")
+	} else {
+		result.WriteString(fmt.Sprintf("发现问题: %d 个\n", len(r.Issues)))
+	}
+
+	for _, chapter := range r.Chapters {
+		chapterIssues := issues[chapter.Chapter]
+		if len(chapterIssues) == 0 && len(r.Chapters) > 1 {
+			continue
+		}
+		header := fmt.Sprintf("\n📄 第%d章（%s", chapter.Chapter, perspectiveLabel(chapter.Perspective))
+		if chapter.POVCharacter != "" {
+			if chapter.Inferred {
+				header += fmt.Sprintf("，推断视角人物: %s", chapter.POVCharacter)
+			} else {
+				header += fmt.Sprintf("，视角人物: %s", chapter.POVCharacter)
+			}
+		}
+		result.WriteString(header + "）\n")
+		sort.SliceStable(chapterIssues, func(i, j int) bool {
+			return chapterIssues[i].Paragraph < chapterIssues[j].Paragraph
+		})
+		for _, issue := range chapterIssues {
+			icon := "⚠️"
+			if issue.Severity == SeverityError {
+				icon = "❌"
+			}
+			result.WriteString(fmt.Sprintf("  %s [%s] 第%d段（第%d行）: %s\n", icon, povIssueLabel(issue.Type), issue.Paragraph, issue.Line, issue.Message))
+			if issue.Excerpt != "" {
+				result.WriteString(fmt.Sprintf("      「%s」\n", issue.Excerpt))
+			}
+		}
+	}
+	return result.String()
+}
+
+func perspectiveLabel(perspective string) string {
+	return labelOr(map[string]string{
+		"first":            "第一人称",
+		"third":            "第三人称",
+		"third_limited":    "第三人称有限视角",
+		"third_omniscient": "第三人称全知视角",
+	}, perspective)
+}
+
+func povIssueLabel(issueType string) string {
+	switch issueType {
+	case POVHeadHopping:
+		return "视角跳跃"
+	case POVFirstPersonSlip:
+		return "人称混用"
+	case POVNarratorInsight:
+		return "越界内心"
+	case POVTenseShift:
+		return "时态"
+	default:
+		return issueType
+	}
+}
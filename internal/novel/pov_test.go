@@ -0,0 +1,103 @@
+package novel
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCheckPOVText(t *testing.T) {
+	tests := []struct {
+		name        string
+		perspective string
+		tense       string
+		text        string
+		want        []string // 类型:段落:涉及的角色或词
+		wantPOV     string
+	}{
+		{
+			name:        "第三人称叙述混入我",
+			perspective: "third_limited",
+			text:        "林风推开门。\n“我来了。”林风说。\n林风心想：我一定要赢。\n忘我之境难求。\n我看见远山起了雾。",
+			want:        []string{"first_person_slip:5:我"},
+		},
+		{
+			name:        "有限视角跳到其他角色的内心",
+			perspective: "third_limited",
+			text:        "林风心中一紧。\n林风暗想这次凶多吉少。\n苏雨心里却很平静。",
+			want:        []string{"head_hopping:3:苏雨"},
+			wantPOV:     "林风",
+		},
+		{
+			name:        "全知视角可以写多人内心",
+			perspective: "third_omniscient",
+			text:        "林风心中一紧。\n苏雨心里却很平静。",
+			want:        []string{},
+		},
+		{
+			name:        "第一人称叙述者写出他人内心",
+			perspective: "first",
+			text:        "我推开门。\n苏雨心里很害怕，我看得出来。",
+			want:        []string{"narrator_insight:2:苏雨"},
+		},
+		{
+			name:        "过去时叙述出现以当下为参照的时间词",
+			perspective: "third_omniscient",
+			tense:       "past",
+			text:        "“明天见。”林风说。\n林风决定明天出城。",
+			want:        []string{"tense_shift:2:明天"},
+		},
+		{
+			name:        "现在时叙述出现以过去为参照的时间词",
+			perspective: "third_omniscient",
+			tense:       "present",
+			text:        "林风明天出城。\n次日林风出城了。",
+			want:        []string{"tense_shift:2:次日"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nm := newTestManager(t)
+			for _, name := range []string{"林风", "苏雨"} {
+				if _, err := nm.AddCharacter(&Character{Name: name}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			nm.novelData.WritingStyle.Perspective = tt.perspective
+			nm.novelData.WritingStyle.Tense = tt.tense
+
+			report, err := nm.CheckPOVText(1, tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, 0, len(report.Issues))
+			for _, issue := range report.Issues {
+				got = append(got, fmt.Sprintf("%s:%d:%s", issue.Type, issue.Paragraph, issue.Subject))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("issues = %v, want %v", got, tt.want)
+			}
+			if pov := report.Chapters[0].POVCharacter; pov != tt.wantPOV {
+				t.Errorf("POV character = %q, want %q", pov, tt.wantPOV)
+			}
+		})
+	}
+}
+
+func TestFirstPersonSlip(t *testing.T) {
+	tests := []struct {
+		narration string
+		want      string
+	}{
+		{"他推开门。", ""},
+		{"我推开门。", "我"},
+		{"我们推开门。", "我们"},
+		{"他进入了忘我之境，我行我素惯了。", ""},
+		{"敌我双方都停了手，我却没停。", "我"},
+	}
+	for _, tt := range tests {
+		if got := firstPersonSlip(tt.narration); got != tt.want {
+			t.Errorf("firstPersonSlip(%q) = %q, want %q", tt.narration, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package novel
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	progressDateLayout = "2006-01-02"
+	// 预测完成日期时参考的最近天数
+	projectionWindowDays = 14
+)
+
+// WritingProgress 写作进度记录
+type WritingProgress struct {
+	Daily []*DailyProgress `json:"daily"`
+}
+
+// DailyProgress 单日字数变化
+type DailyProgress struct {
+	Date     string         `json:"date"`     // 2006-01-02
+	Added    int            `json:"added"`    // 新增字数
+	Removed  int            `json:"removed"`  // 删减字数
+	Chapters map[string]int `json:"chapters"` // 章节号 -> 当日净变化
+}
+
+// Net 当日净增字数
+func (d *DailyProgress) Net() int {
+	return d.Added - d.Removed
+}
+
+// ProgressReport 进度报告
+type ProgressReport struct {
+	Today          int
+	TodayAdded     int
+	TodayRemoved   int
+	DailyTarget    int
+	Streak         int
+	TodayMet       bool
+	TotalWords     int
+	TargetWords    int
+	Chapters       int
+	AveragePerDay  float64
+	ProjectionDays int // 计算日均所用的天数
+	ProjectedDate  time.Time
+	RecentDays     []*DailyProgress
+	ChangedThisRun int // 本次同步检测到的净变化
+}
+
+// SyncChapterStats 扫描章节文件，更新章节字数和出场角色并记录当日字数变化，返回本次同步的净变化
+func (nm *NovelManager) SyncChapterStats() (int, error) {
+	if !nm.HasProject() {
+		return 0, fmt.Errorf("novel project not initialized")
+	}
+
+	// 读盘和写历史版本都在锁外进行，持锁只用于合并统计结果
+	texts, err := nm.readChapterFiles()
+	if err != nil {
+		return 0, err
+	}
+
+	net, edited, err := nm.mergeChapterStats(texts)
+	if err != nil {
+		return 0, err
+	}
+	// 指纹变化说明正文可能被修改过，记入历史版本
+	for _, number := range edited {
+		if err := nm.recordChapterEdit(number, texts[number]); err != nil {
+			return net, err
+		}
+	}
+	return net, nil
+}
+
+// mergeChapterStats 把读到的正文合并进章节字数、出场角色和当日字数，返回净变化和指纹变化的章节
+func (nm *NovelManager) mergeChapterStats(texts map[int]string) (int, []int, error) {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	if nm.novelData == nil {
+		return 0, nil, fmt.Errorf("novel project not initialized")
+	}
+
+	chapters := make(map[int]*Chapter, len(nm.novelData.Chapters))
+	for _, chapter := range nm.novelData.Chapters {
+		chapters[chapter.Number] = chapter
+	}
+
+	// 出场角色按正文和角色表的指纹增量扫描，只处理有变化的章节
+	previousFirst := nm.scannedFirstAppearances()
+	matcher := nm.newCastMatcher()
+	castChanged := false
+
+	// 首次启用统计时只建立基线，避免把已有全文算作当天字数
+	firstRun := nm.novelData.Progress == nil
+	if firstRun {
+		nm.novelData.Progress = &WritingProgress{Daily: make([]*DailyProgress, 0)}
+	}
+
+	var today *DailyProgress
+	net := 0
+	changed := false
+	edited := make([]int, 0)
+	record := func(number, delta int) {
+		if firstRun {
+			return
+		}
+		if today == nil {
+			today = nm.todayProgress()
+		}
+		if delta > 0 {
+			today.Added += delta
+		} else {
+			today.Removed -= delta
+		}
+		today.Chapters[strconv.Itoa(number)] += delta
+		net += delta
+	}
+
+	// 章节文件已删除：清除出场角色指纹，字数清零并计入当日删减
+	for number, chapter := range chapters {
+		if _, ok := texts[number]; ok {
+			continue
+		}
+		if chapter.CastDigest != "" {
+			chapter.CastDigest = ""
+			castChanged = true
+		}
+		if chapter.WordCount != 0 {
+			record(number, -chapter.WordCount)
+			chapter.WordCount = 0
+			changed = true
+		}
+	}
+
+	for number, text := range texts {
+		words := CountWords(text)
+		chapter, exists := chapters[number]
+		if !exists {
+			chapter = &Chapter{
+				Number:     number,
+				Status:     "draft",
+				WrittenAt:  time.Now(),
+				Characters: make([]string, 0),
+				PlotLines:  make([]string, 0),
+				KeyEvents:  make([]string, 0),
+				Emotions:   make([]string, 0),
+			}
+			nm.novelData.Chapters = append(nm.novelData.Chapters, chapter)
+			chapters[number] = chapter
+			changed = true
+		}
+
+		if matcher.updateCast(chapter, text) {
+			castChanged = true
+			changed = true
+			edited = append(edited, number)
+		}
+
+		delta := words - chapter.WordCount
+		if delta == 0 {
+			continue
+		}
+		chapter.WordCount = words
+		chapter.WrittenAt = time.Now()
+		changed = true
+		record(number, delta)
+	}
+
+	if castChanged {
+		nm.refreshAppearances(previousFirst)
+		changed = true
+	}
+	if !changed {
+		return 0, edited, nil
+	}
+
+	sort.Slice(nm.novelData.Chapters, func(i, j int) bool {
+		return nm.novelData.Chapters[i].Number < nm.novelData.Chapters[j].Number
+	})
+
+	return net, edited, nm.SaveProject()
+}
+
+// GetProgressReport 生成写作进度报告，dailyTarget 为每日目标字数（0 表示未设置）
+func (nm *NovelManager) GetProgressReport(dailyTarget int) (*ProgressReport, error) {
+	changed, err := nm.SyncChapterStats()
+	if err != nil {
+		return nil, err
+	}
+
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	report := &ProgressReport{
+		DailyTarget:    dailyTarget,
+		TargetWords:    nm.novelData.TargetWords,
+		Chapters:       len(nm.novelData.Chapters),
+		ChangedThisRun: changed,
+	}
+	for _, chapter := range nm.novelData.Chapters {
+		report.TotalWords += chapter.WordCount
+	}
+
+	days := make(map[string]*DailyProgress)
+	if nm.novelData.Progress != nil {
+		for _, day := range nm.novelData.Progress.Daily {
+			days[day.Date] = day
+		}
+	}
+
+	now := time.Now()
+	todayKey := now.Format(progressDateLayout)
+	if today, ok := days[todayKey]; ok {
+		report.Today = today.Net()
+		report.TodayAdded = today.Added
+		report.TodayRemoved = today.Removed
+	}
+	report.TodayMet = metTarget(report.Today, dailyTarget)
+
+	// 连续达标天数：今天未达标时从昨天开始往前数
+	start := now
+	if !report.TodayMet {
+		start = now.AddDate(0, 0, -1)
+	}
+	for d := start; ; d = d.AddDate(0, 0, -1) {
+		day, ok := days[d.Format(progressDateLayout)]
+		if !ok || !metTarget(day.Net(), dailyTarget) {
+			break
+		}
+		report.Streak++
+	}
+
+	// 以最近两周（项目开始记录不足两周时按实际天数）的日均净增预测完成日期
+	window := projectionWindowDays
+	if daily := nm.novelData.Progress; daily != nil && len(daily.Daily) > 0 {
+		if first, err := time.ParseInLocation(progressDateLayout, daily.Daily[0].Date, now.Location()); err == nil {
+			if tracked := int(now.Sub(first).Hours()/24) + 1; tracked < window {
+				window = tracked
+			}
+		}
+	}
+	windowTotal := 0
+	for i := 0; i < window; i++ {
+		key := now.AddDate(0, 0, -i).Format(progressDateLayout)
+		if day, ok := days[key]; ok {
+			windowTotal += day.Net()
+			report.RecentDays = append(report.RecentDays, day)
+		}
+	}
+	report.ProjectionDays = window
+	report.AveragePerDay = float64(windowTotal) / float64(window)
+	remaining := report.TargetWords - report.TotalWords
+	if remaining <= 0 {
+		report.ProjectedDate = now
+	} else if report.AveragePerDay > 0 {
+		daysLeft := int(float64(remaining)/report.AveragePerDay + 0.999)
+		report.ProjectedDate = now.AddDate(0, 0, daysLeft)
+	}
+
+	return report, nil
+}
+
+// Format 生成进度报告文本
+func (r *ProgressReport) Format() string {
+	var result strings.Builder
+	result.WriteString("📈 === 写作进度 ===\n\n")
+
+	if r.DailyTarget > 0 {
+		status := "⏳ 未达标"
+		if r.TodayMet {
+			status = "✅ 已达标"
+		}
+		result.WriteString(fmt.Sprintf("今日字数: %d / %d %s\n", r.Today, r.DailyTarget, status))
+		result.WriteString(fmt.Sprintf("  %s\n", progressBar(r.Today, r.DailyTarget, 30)))
+	} else {
+		result.WriteString(fmt.Sprintf("今日字数: %d（未设置每日目标）\n", r.Today))
+	}
+	if r.TodayRemoved > 0 {
+		result.WriteString(fmt.Sprintf("  新增 %d 字，删改 %d 字\n", r.TodayAdded, r.TodayRemoved))
+	}
+	result.WriteString(fmt.Sprintf("连续达标: %d 天\n\n", r.Streak))
+
+	if r.TargetWords > 0 {
+		result.WriteString(fmt.Sprintf("全书进度: %d / %d 字（%d 章）\n", r.TotalWords, r.TargetWords, r.Chapters))
+		result.WriteString(fmt.Sprintf("  %s\n", progressBar(r.TotalWords, r.TargetWords, 30)))
+	} else {
+		result.WriteString(fmt.Sprintf("全书字数: %d 字（%d 章）\n", r.TotalWords, r.Chapters))
+	}
+
+	switch {
+	case r.TargetWords > 0 && r.TotalWords >= r.TargetWords:
+		result.WriteString("预计完成: 🎉 已达到目标字数\n")
+	case !r.ProjectedDate.IsZero():
+		result.WriteString(fmt.Sprintf("预计完成: %s（近%d天日均 %.0f 字）\n",
+			r.ProjectedDate.Format(progressDateLayout), r.ProjectionDays, r.AveragePerDay))
+	default:
+		result.WriteString(fmt.Sprintf("预计完成: 近%d天没有净增字数，暂无法预测\n", r.ProjectionDays))
+	}
+
+	if len(r.RecentDays) > 0 {
+		result.WriteString("\n最近记录:\n")
+		for _, day := range r.RecentDays {
+			result.WriteString(fmt.Sprintf("  %s  %+d\n", day.Date, day.Net()))
+		}
+	}
+
+	return result.String()
+}
+
+// todayProgress 获取（必要时创建）今天的进度记录，调用方需持有写锁
+func (nm *NovelManager) todayProgress() *DailyProgress {
+	date := time.Now().Format(progressDateLayout)
+	daily := nm.novelData.Progress.Daily
+	if len(daily) > 0 && daily[len(daily)-1].Date == date {
+		return daily[len(daily)-1]
+	}
+
+	today := &DailyProgress{Date: date, Chapters: make(map[string]int)}
+	nm.novelData.Progress.Daily = append(daily, today)
+	return today
+}
+
+// readChapterFiles 读取 chapters/ 下所有章节文件的正文
+func (nm *NovelManager) readChapterFiles() (map[int]string, error) {
+	numbers, err := nm.listChapterNumbers()
+	if err != nil {
+		return nil, err
+	}
+
+	texts := make(map[int]string, len(numbers))
+	for _, number := range numbers {
+		data, err := os.ReadFile(nm.ChapterFilePath(number))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read chapter %d: %w", number, err)
+		}
+		texts[number] = string(data)
+	}
+
+	return texts, nil
+}
+
+func metTarget(words, target int) bool {
+	if target > 0 {
+		return words >= target
+	}
+	return words > 0
+}
+
+func progressBar(current, total, width int) string {
+	if total <= 0 {
+		return ""
+	}
+	ratio := float64(current) / float64(total)
+	if ratio < 0 {
+		ratio = 0
+	}
+	filled := int(ratio * float64(width))
+	if filled > width {
+		filled = width
+	}
+	return fmt.Sprintf("[%s%s] %.1f%%", strings.Repeat("█", filled), strings.Repeat("░", width-filled), ratio*100)
+}
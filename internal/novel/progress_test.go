@@ -0,0 +1,77 @@
+package novel
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSyncChapterStats(t *testing.T) {
+	nm := newTestManager(t)
+
+	steps := []struct {
+		name      string
+		apply     func()
+		wantNet   int
+		wantWords map[int]int
+	}{
+		{
+			name: "新增章节",
+			apply: func() {
+				writeTestChapter(t, nm, 1, "天色渐暗，他推门而入。")
+				writeTestChapter(t, nm, 2, "第二天清晨。")
+			},
+			wantNet:   14,
+			wantWords: map[int]int{1: 9, 2: 5},
+		},
+		{
+			name:      "未改动时没有变化",
+			apply:     func() {},
+			wantNet:   0,
+			wantWords: map[int]int{1: 9, 2: 5},
+		},
+		{
+			name:      "修改正文",
+			apply:     func() { writeTestChapter(t, nm, 1, "天色渐暗。") },
+			wantNet:   -5,
+			wantWords: map[int]int{1: 4, 2: 5},
+		},
+		{
+			name: "删除章节文件后字数清零",
+			apply: func() {
+				if err := os.Remove(nm.ChapterFilePath(2)); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantNet:   -5,
+			wantWords: map[int]int{1: 4, 2: 0},
+		},
+	}
+
+	for _, step := range steps {
+		step.apply()
+		net, err := nm.SyncChapterStats()
+		if err != nil {
+			t.Fatalf("%s: SyncChapterStats: %v", step.name, err)
+		}
+		if net != step.wantNet {
+			t.Errorf("%s: net = %d, want %d", step.name, net, step.wantNet)
+		}
+		for number, want := range step.wantWords {
+			chapter := nm.findChapter(number)
+			if chapter == nil {
+				t.Fatalf("%s: chapter %d not registered", step.name, number)
+			}
+			if chapter.WordCount != want {
+				t.Errorf("%s: chapter %d WordCount = %d, want %d", step.name, number, chapter.WordCount, want)
+			}
+		}
+	}
+
+	report, err := nm.GetProgressReport(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.TotalWords != 4 || report.TodayAdded != 14 || report.TodayRemoved != 10 {
+		t.Errorf("report total=%d added=%d removed=%d, want 4/14/10", report.TotalWords, report.TodayAdded, report.TodayRemoved)
+	}
+}
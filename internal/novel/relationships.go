@@ -0,0 +1,341 @@
+package novel
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RelationshipEdge 有向关系：From 视 To 为 Type（如 林动 -> 岩老: 师父），自 SinceChapter 起生效
+type RelationshipEdge struct {
+	From         string `json:"from"`
+	To           string `json:"to"`
+	Type         string `json:"type"`
+	SinceChapter int    `json:"since_chapter"`
+	Note         string `json:"note,omitempty"`
+}
+
+// RelationshipConflict 关系图中互相矛盾的一对关系
+type RelationshipConflict struct {
+	A, B     string
+	AToB     string // A 视 B 为
+	BToA     string // B 视 A 为
+	Chapter  int    // 矛盾开始的章节
+	Severity string
+	Message  string
+}
+
+// 敌对类称谓，与亲友、师徒类称谓同时出现时视为矛盾
+var hostileRelations = map[string]bool{"仇人": true, "敌人": true, "对手": true}
+
+// 对方应有的称谓：A 视 B 为 key 时，B 视 A 应为其中之一
+var inverseRelations = map[string][]string{
+	"师父":  {"徒弟"},
+	"徒弟":  {"师父"},
+	"师兄":  {"师弟", "师妹"},
+	"师姐":  {"师弟", "师妹"},
+	"师弟":  {"师兄", "师姐"},
+	"师妹":  {"师兄", "师姐"},
+	"父亲":  {"儿子", "女儿"},
+	"母亲":  {"儿子", "女儿"},
+	"儿子":  {"父亲", "母亲"},
+	"女儿":  {"父亲", "母亲"},
+	"哥哥":  {"弟弟", "妹妹"},
+	"姐姐":  {"弟弟", "妹妹"},
+	"弟弟":  {"哥哥", "姐姐"},
+	"妹妹":  {"哥哥", "姐姐"},
+	"爷爷":  {"孙子", "孙女"},
+	"奶奶":  {"孙子", "孙女"},
+	"孙子":  {"爷爷", "奶奶"},
+	"孙女":  {"爷爷", "奶奶"},
+	"丈夫":  {"妻子"},
+	"妻子":  {"丈夫"},
+	"未婚夫": {"未婚妻"},
+	"未婚妻": {"未婚夫"},
+	"道侣":  {"道侣"},
+	"恋人":  {"恋人"},
+	"好友":  {"好友"},
+	"仇人":  {"仇人"},
+	"主人":  {"侍女", "护卫", "仆人"},
+	"侍女":  {"主人"},
+	"护卫":  {"主人"},
+	"仆人":  {"主人"},
+}
+
+// SetRelationship 记录一条关系状态，自 edge.SinceChapter 起生效；同一对角色同一章节的旧记录会被替换
+func (nm *NovelManager) SetRelationship(edge RelationshipEdge) error {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	if nm.novelData == nil {
+		return fmt.Errorf("novel project not initialized")
+	}
+	edge.From, edge.To, edge.Type = strings.TrimSpace(edge.From), strings.TrimSpace(edge.To), strings.TrimSpace(edge.Type)
+	if edge.From == "" || edge.To == "" || edge.Type == "" {
+		return fmt.Errorf("from, to and type are required")
+	}
+	if edge.From == edge.To {
+		return fmt.Errorf("a character cannot have a relationship with itself")
+	}
+	for _, name := range []string{edge.From, edge.To} {
+		if _, ok := nm.novelData.Characters[name]; !ok {
+			return fmt.Errorf("character not found: %s", name)
+		}
+	}
+
+	// 首次为这对角色建立历史时，把旧版 map 中的关系作为初始状态保留下来
+	edges := nm.novelData.Relationships
+	hasHistory := false
+	for _, existing := range edges {
+		if existing.From == edge.From && existing.To == edge.To {
+			hasHistory = true
+			break
+		}
+	}
+	if legacy := nm.novelData.Characters[edge.From].Relationships[edge.To]; !hasHistory && legacy != "" && edge.SinceChapter > 0 {
+		edges = append(edges, &RelationshipEdge{From: edge.From, To: edge.To, Type: legacy})
+	}
+
+	replaced := false
+	for _, existing := range edges {
+		if existing.From == edge.From && existing.To == edge.To && existing.SinceChapter == edge.SinceChapter {
+			*existing = edge
+			replaced = true
+		}
+	}
+	if !replaced {
+		copied := edge
+		edges = append(edges, &copied)
+	}
+	sortRelationshipEdges(edges)
+	nm.novelData.Relationships = edges
+
+	// Character.Relationships 保持为最新状态
+	from := nm.novelData.Characters[edge.From]
+	if from.Relationships == nil {
+		from.Relationships = make(map[string]string)
+	}
+	if latest := relationshipAt(edges, edge.From, edge.To, 1<<30); latest != nil {
+		from.Relationships[edge.To] = latest.Type
+	}
+
+	return nm.SaveProject()
+}
+
+// RelationshipGraph 返回关系图快照：已记录的关系历史，加上仅存在于角色设定中的关系（视为从头生效）
+func (nm *NovelManager) RelationshipGraph() (*RelationshipGraph, error) {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	if nm.novelData == nil {
+		return nil, fmt.Errorf("novel project not initialized")
+	}
+	return newRelationshipGraph(nm.novelData), nil
+}
+
+// RelationshipGraph 关系图
+type RelationshipGraph struct {
+	Characters []string
+	Edges      []*RelationshipEdge
+}
+
+func newRelationshipGraph(project *NovelProject) *RelationshipGraph {
+	graph := &RelationshipGraph{Characters: sortedCharacterNames(project.Characters)}
+	sort.Strings(graph.Characters)
+
+	recorded := make(map[string]bool)
+	for _, edge := range project.Relationships {
+		copied := *edge
+		graph.Edges = append(graph.Edges, &copied)
+		recorded[edge.From+"\x00"+edge.To] = true
+	}
+	for _, name := range graph.Characters {
+		for other, relation := range project.Characters[name].Relationships {
+			if relation == "" || recorded[name+"\x00"+other] {
+				continue
+			}
+			graph.Edges = append(graph.Edges, &RelationshipEdge{From: name, To: other, Type: relation})
+		}
+	}
+	sortRelationshipEdges(graph.Edges)
+	return graph
+}
+
+// At 返回 from 视 to 在某章时的关系，没有记录时返回 nil
+func (g *RelationshipGraph) At(from, to string, chapterNum int) *RelationshipEdge {
+	return relationshipAt(g.Edges, from, to, chapterNum)
+}
+
+// History 返回 from 视 to 的关系变化历史
+func (g *RelationshipGraph) History(from, to string) []*RelationshipEdge {
+	history := make([]*RelationshipEdge, 0)
+	for _, edge := range g.Edges {
+		if edge.From == from && edge.To == to {
+			history = append(history, edge)
+		}
+	}
+	return history
+}
+
+// Snapshot 某章时的全部有效关系
+func (g *RelationshipGraph) Snapshot(chapterNum int) []*RelationshipEdge {
+	latest := make(map[string]*RelationshipEdge)
+	keys := make([]string, 0)
+	for _, edge := range g.Edges {
+		if edge.SinceChapter > chapterNum {
+			continue
+		}
+		key := edge.From + "\x00" + edge.To
+		if _, ok := latest[key]; !ok {
+			keys = append(keys, key)
+		}
+		latest[key] = edge
+	}
+	snapshot := make([]*RelationshipEdge, 0, len(keys))
+	for _, key := range keys {
+		snapshot = append(snapshot, latest[key])
+	}
+	return snapshot
+}
+
+// Conflicts 检查双向关系是否矛盾：在每个关系发生变化的章节比较双方的称谓
+func (g *RelationshipGraph) Conflicts() []*RelationshipConflict {
+	checkpoints := make(map[string][]int)
+	for _, edge := range g.Edges {
+		a, b := edge.From, edge.To
+		if a > b {
+			a, b = b, a
+		}
+		key := a + "\x00" + b
+		if !containsInt(checkpoints[key], edge.SinceChapter) {
+			checkpoints[key] = append(checkpoints[key], edge.SinceChapter)
+		}
+	}
+
+	keys := make([]string, 0, len(checkpoints))
+	for key := range checkpoints {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	conflicts := make([]*RelationshipConflict, 0)
+	for _, key := range keys {
+		names := strings.SplitN(key, "\x00", 2)
+		a, b := names[0], names[1]
+		chapters := checkpoints[key]
+		sort.Ints(chapters)
+
+		lastMessage := ""
+		for _, chapter := range chapters {
+			ab, ba := g.At(a, b, chapter), g.At(b, a, chapter)
+			if ab == nil || ba == nil {
+				continue
+			}
+			conflict := relationConflict(a, b, ab.Type, ba.Type)
+			if conflict == nil || conflict.Message == lastMessage {
+				continue
+			}
+			conflict.Chapter = chapter
+			lastMessage = conflict.Message
+			conflicts = append(conflicts, conflict)
+		}
+	}
+	return conflicts
+}
+
+// FormatDOT 导出 Graphviz DOT，敌对关系标红
+func (g *RelationshipGraph) FormatDOT(chapterNum int) string {
+	var result strings.Builder
+	result.WriteString("digraph relationships {\n")
+	result.WriteString("  rankdir=LR;\n")
+	result.WriteString("  node [shape=box, style=rounded, fontname=\"Noto Sans CJK SC\"];\n")
+	result.WriteString("  edge [fontname=\"Noto Sans CJK SC\"];\n")
+	for _, name := range g.Characters {
+		result.WriteString(fmt.Sprintf("  %s;\n", dotQuote(name)))
+	}
+	for _, edge := range g.Snapshot(chapterNum) {
+		attrs := fmt.Sprintf("label=%s", dotQuote(edge.Type))
+		if hostileRelations[canonicalRelation(edge.Type)] {
+			attrs += ", color=red, fontcolor=red"
+		}
+		result.WriteString(fmt.Sprintf("  %s -> %s [%s];\n", dotQuote(edge.From), dotQuote(edge.To), attrs))
+	}
+	result.WriteString("}\n")
+	return result.String()
+}
+
+// FormatMermaid 导出 Mermaid flowchart
+func (g *RelationshipGraph) FormatMermaid(chapterNum int) string {
+	ids := make(map[string]string, len(g.Characters))
+	var result strings.Builder
+	result.WriteString("graph LR\n")
+	for i, name := range g.Characters {
+		ids[name] = fmt.Sprintf("c%d", i)
+		result.WriteString(fmt.Sprintf("    %s[\"%s\"]\n", ids[name], mermaidLabel(name)))
+	}
+	for _, edge := range g.Snapshot(chapterNum) {
+		from, ok1 := ids[edge.From]
+		to, ok2 := ids[edge.To]
+		if !ok1 || !ok2 {
+			continue
+		}
+		arrow := "-->"
+		if hostileRelations[canonicalRelation(edge.Type)] {
+			arrow = "-.->"
+		}
+		result.WriteString(fmt.Sprintf("    %s %s|%s| %s\n", from, arrow, mermaidLabel(edge.Type), to))
+	}
+	return result.String()
+}
+
+// relationshipAt 在按章节排序的关系中找出某章生效的那一条
+func relationshipAt(edges []*RelationshipEdge, from, to string, chapterNum int) *RelationshipEdge {
+	var found *RelationshipEdge
+	for _, edge := range edges {
+		if edge.From == from && edge.To == to && edge.SinceChapter <= chapterNum {
+			found = edge
+		}
+	}
+	return found
+}
+
+// relationConflict 判断 A 视 B 为 ab、B 视 A 为 ba 是否矛盾
+func relationConflict(a, b, ab, ba string) *RelationshipConflict {
+	cab, cba := canonicalRelation(ab), canonicalRelation(ba)
+	conflict := &RelationshipConflict{A: a, B: b, AToB: ab, BToA: ba}
+
+	if hostileRelations[cab] != hostileRelations[cba] {
+		conflict.Severity = SeverityError
+		conflict.Message = fmt.Sprintf("%s 视 %s 为「%s」，%s 却视 %s 为「%s」", a, b, ab, b, a, ba)
+		return conflict
+	}
+	if expected, ok := inverseRelations[cab]; ok {
+		if _, known := inverseRelations[cba]; known && !containsString(expected, cba) {
+			conflict.Severity = SeverityWarning
+			conflict.Message = fmt.Sprintf("%s 视 %s 为「%s」，%s 应视 %s 为「%s」，但记录为「%s」",
+				a, b, ab, b, a, strings.Join(expected, "/"), ba)
+			return conflict
+		}
+	}
+	return nil
+}
+
+func sortRelationshipEdges(edges []*RelationshipEdge) {
+	sort.SliceStable(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		if edges[i].To != edges[j].To {
+			return edges[i].To < edges[j].To
+		}
+		return edges[i].SinceChapter < edges[j].SinceChapter
+	})
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", " ").Replace(s) + `"`
+}
+
+func mermaidLabel(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "|", "／", "\n", " ").Replace(s)
+}
@@ -0,0 +1,136 @@
+package novel
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSetRelationshipHistory(t *testing.T) {
+	nm := newTestManager(t)
+	characters := []*Character{
+		{Name: "林动", Relationships: map[string]string{"岩老": "恩人"}},
+		{Name: "岩老"},
+	}
+	for _, char := range characters {
+		if _, err := nm.AddCharacter(char); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	invalid := []RelationshipEdge{
+		{From: "林动", To: "林动", Type: "师父"},
+		{From: "林动", To: "萧炎", Type: "好友"},
+		{From: "林动", To: "岩老", Type: " "},
+	}
+	for _, edge := range invalid {
+		if err := nm.SetRelationship(edge); err == nil {
+			t.Errorf("SetRelationship(%+v) accepted", edge)
+		}
+	}
+
+	steps := []RelationshipEdge{
+		{From: "林动", To: "岩老", Type: "师父", SinceChapter: 5},
+		{From: "林动", To: "岩老", Type: "师尊", SinceChapter: 5, Note: "改称"}, // 同一章替换
+		{From: "林动", To: "岩老", Type: "好友", SinceChapter: 2},
+	}
+	for _, edge := range steps {
+		if err := nm.SetRelationship(edge); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	graph, err := nm.RelationshipGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := graph.History("林动", "岩老")
+	got := make([]string, 0, len(history))
+	for _, edge := range history {
+		got = append(got, edge.Type)
+	}
+	// 旧版设定中的关系作为第0章起的初始状态保留
+	if strings.Join(got, ",") != "恩人,好友,师尊" {
+		t.Errorf("history = %v, want [恩人 好友 师尊]", got)
+	}
+	for chapter, want := range map[int]string{1: "恩人", 3: "好友", 5: "师尊", 100: "师尊"} {
+		if edge := graph.At("林动", "岩老", chapter); edge == nil || edge.Type != want {
+			t.Errorf("At(%d) = %v, want %s", chapter, edge, want)
+		}
+	}
+	if graph.At("岩老", "林动", 10) != nil {
+		t.Error("reverse relationship should be unrecorded")
+	}
+	// 角色设定中保存最新状态，补录较早的章节不会覆盖
+	if got := nm.novelData.Characters["林动"].Relationships["岩老"]; got != "师尊" {
+		t.Errorf("character relationship = %q, want 师尊", got)
+	}
+}
+
+func TestRelationConflict(t *testing.T) {
+	tests := []struct {
+		name         string
+		ab, ba       string
+		wantSeverity string // 为空表示没有矛盾
+	}{
+		{name: "师徒互相对应", ab: "师父", ba: "徒弟"},
+		{name: "同义称谓", ab: "师尊", ba: "弟子"},
+		{name: "兄妹", ab: "哥哥", ba: "妹妹"},
+		{name: "双方都敌对", ab: "仇人", ba: "宿敌"},
+		{name: "称谓不对应", ab: "师父", ba: "师兄", wantSeverity: SeverityWarning},
+		{name: "一方敌对一方亲近", ab: "挚友", ba: "仇人", wantSeverity: SeverityError},
+		{name: "未知称谓不检查", ab: "师父", ba: "债主"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conflict := relationConflict("甲", "乙", tt.ab, tt.ba)
+			got := ""
+			if conflict != nil {
+				got = conflict.Severity
+			}
+			if got != tt.wantSeverity {
+				t.Errorf("relationConflict(%s, %s) severity = %q, want %q", tt.ab, tt.ba, got, tt.wantSeverity)
+			}
+		})
+	}
+}
+
+func TestRelationshipGraphConflictsOverTime(t *testing.T) {
+	graph := newRelationshipGraph(&NovelProject{
+		Characters: map[string]*Character{
+			"林动": {Name: "林动"},
+			"岩老": {Name: "岩老"},
+			"林霞": {Name: "林霞", Relationships: map[string]string{"林动": "姐姐"}},
+		},
+		Relationships: []*RelationshipEdge{
+			{From: "岩老", To: "林动", Type: "仇人", SinceChapter: 10},
+			{From: "林动", To: "岩老", Type: "师父", SinceChapter: 1},
+			{From: "岩老", To: "林动", Type: "徒弟", SinceChapter: 1},
+			{From: "林动", To: "林霞", Type: "弟弟"},
+		},
+	})
+
+	conflicts := graph.Conflicts()
+	if len(conflicts) != 1 {
+		t.Fatalf("got %d conflicts, want 1: %+v", len(conflicts), conflicts)
+	}
+	if c := conflicts[0]; c.Chapter != 10 || c.Severity != SeverityError || c.A != "岩老" || c.AToB != "仇人" || c.BToA != "师父" {
+		t.Errorf("conflict = %+v", c)
+	}
+
+	// 仅存在于角色设定中的关系视为从头生效
+	if edge := graph.At("林霞", "林动", 0); edge == nil || edge.Type != "姐姐" {
+		t.Errorf("legacy edge = %v", edge)
+	}
+	if snapshot := graph.Snapshot(5); len(snapshot) != 4 {
+		t.Errorf("Snapshot(5) has %d edges, want 4", len(snapshot))
+	}
+
+	dot := graph.FormatDOT(10)
+	if !strings.Contains(dot, `"岩老" -> "林动" [label="仇人", color=red, fontcolor=red];`) ||
+		!strings.Contains(dot, `"林动" -> "岩老" [label="师父"];`) {
+		t.Errorf("FormatDOT(10) =\n%s", dot)
+	}
+	if mermaid := graph.FormatMermaid(5); !strings.Contains(mermaid, "-->|徒弟|") || strings.Contains(mermaid, "-.->") {
+		t.Errorf("FormatMermaid(5) =\n%s", mermaid)
+	}
+}
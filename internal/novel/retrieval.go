@@ -0,0 +1,1130 @@
+package novel
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/AiNovelTools/internal/ai"
+)
+
+// RetrievalIndexFile 检索索引文件名
+const RetrievalIndexFile = "retrieval_index.json"
+
+// RetrievalLogFile 检索索引的增量日志，加载时在快照之上按顺序重放
+const RetrievalLogFile = "retrieval_index.log"
+
+const (
+	retrievalIndexVersion = 2
+	// 正文切块的目标长度与上限（按字符计）
+	chunkTargetRunes = 300
+	chunkMaxRunes    = 600
+	// BM25 参数
+	bm25K1 = 1.5
+	bm25B  = 0.75
+	// 本地哈希向量维度
+	hashingDims = 256
+	// 混合打分中语义相似度的权重
+	semanticWeight = 0.4
+	// 没有词面命中时，语义相似度至少达到该值才算相关
+	minSemanticOnly = 0.35
+	// 章节上下文中检索相关前文的超时
+	relatedPassagesTimeout = 15 * time.Second
+	// 每次向量请求的检索单元数；每批完成后即落盘，超时中断时已完成的批次不会丢失
+	embeddingBatchSize = 64
+	// 增量日志达到该大小且超过快照时重写快照
+	retrievalCompactMinBytes = 256 * 1024
+)
+
+// 检索来源
+const (
+	SourceChapter   = "chapter"
+	SourceSetting   = "setting"
+	SourceCharacter = "character"
+	SourcePlot      = "plot"
+	SourceChat      = "chat"
+)
+
+// Embedder 文本向量化
+type Embedder interface {
+	Name() string
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// HashingEmbedder 本地特征哈希向量，离线可用
+type HashingEmbedder struct{}
+
+func (HashingEmbedder) Name() string { return fmt.Sprintf("local/hashing-%d", hashingDims) }
+
+func (HashingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector := make([]float32, hashingDims)
+		for _, token := range tokenize(text) {
+			h := fnv.New32a()
+			h.Write([]byte(token))
+			sum := h.Sum32()
+			if sum&0x80000000 != 0 {
+				vector[sum%hashingDims]--
+			} else {
+				vector[sum%hashingDims]++
+			}
+		}
+		vectors[i] = normalizeVector(vector)
+	}
+	return vectors, nil
+}
+
+// ProviderEmbedder 使用当前模型提供商的向量接口
+type ProviderEmbedder struct {
+	client *ai.Client
+}
+
+// NewProviderEmbedder 创建提供商向量化器
+func NewProviderEmbedder(client *ai.Client) *ProviderEmbedder {
+	return &ProviderEmbedder{client: client}
+}
+
+func (p *ProviderEmbedder) Name() string {
+	if model := p.client.EmbeddingModel(); model != "" {
+		return model
+	}
+	return "provider/unsupported"
+}
+
+func (p *ProviderEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors, err := p.client.Embed(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range vectors {
+		vectors[i] = normalizeVector(vectors[i])
+	}
+	return vectors, nil
+}
+
+// RetrievalChunk 检索单元
+type RetrievalChunk struct {
+	ID      string    `json:"id"`
+	Source  string    `json:"source"`
+	Key     string    `json:"key"` // 来源标识，如 chapter:12、setting:青阳镇
+	Chapter int       `json:"chapter,omitempty"`
+	Line    int       `json:"line,omitempty"` // 正文起始行号
+	Title   string    `json:"title"`
+	Text    string    `json:"text"`
+	Vector  []float32 `json:"vector,omitempty"`
+
+	terms  map[string]int
+	length int
+}
+
+// SearchOptions 检索选项
+type SearchOptions struct {
+	Limit          int
+	Sources        []string // 为空表示全部来源
+	BeforeChapter  int      // >0 时只检索该章之前的正文
+	ExcludeChapter int
+}
+
+// SearchHit 检索结果
+type SearchHit struct {
+	Chunk    *RetrievalChunk
+	Score    float64
+	Lexical  float64 // 归一化后的 BM25 分数
+	Semantic float64 // 向量余弦相似度
+}
+
+// retrievalIndex 持久化在项目目录中的检索索引
+type retrievalIndex struct {
+	mutex    sync.Mutex
+	loaded   bool
+	fallback bool // 提供商向量化失败，已退回本地哈希向量
+	paused   bool // 提供商向量化失败但索引已有其向量，本次会话不再请求，保留已有向量
+
+	Version  int               `json:"version"`
+	Embedder string            `json:"embedder"`
+	Sources  map[string]string `json:"sources"` // 来源标识 -> 内容哈希
+	Chunks   []*RetrievalChunk `json:"chunks"`
+
+	df     map[string]int
+	avgLen float64
+	stamps map[string]fileStamp // 章节来源标识 -> 上次切块时的文件状态，只在内存中保存
+
+	journal  []*retrievalLogLine // 尚未落盘的改动
+	rewrite  bool                // 下次落盘时重写快照：新建、更换向量化器或退回本地向量后
+	snapSize int64
+	logSize  int64
+}
+
+// retrievalLogLine 增量日志中的一行：来源重新切块（Hash 非空）、来源删除（Hash 为空）或一批补上的向量（Key 为空）
+type retrievalLogLine struct {
+	Embedder string               `json:"embedder"` // 写入时索引使用的向量化器，与快照不符的向量不再使用
+	Key      string               `json:"key,omitempty"`
+	Hash     string               `json:"hash,omitempty"`
+	Chunks   []*RetrievalChunk    `json:"chunks,omitempty"`
+	Vectors  map[string][]float32 `json:"vectors,omitempty"` // 检索单元ID -> 向量
+}
+
+// fileStamp 章节文件的修改时间、大小和章节标题，都未变化时检索不再读取正文
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	title   string
+}
+
+// retrievalSource 待索引的原始内容
+type retrievalSource struct {
+	Key     string
+	Source  string
+	Chapter int
+	Title   string
+	Text    string
+	Stable  bool // 内容不可变或未变化且已索引，无需读取正文比较
+	stamp   fileStamp
+}
+
+// SetEmbedder 设置检索使用的向量化器，nil 表示使用本地哈希向量
+func (nm *NovelManager) SetEmbedder(embedder Embedder) {
+	nm.retrieval.mutex.Lock()
+	defer nm.retrieval.mutex.Unlock()
+
+	if embedder == nil {
+		embedder = HashingEmbedder{}
+	}
+	nm.embedder = embedder
+	nm.retrieval.fallback = false
+	nm.retrieval.paused = false
+}
+
+// RefreshRetrievalIndex 增量更新检索索引，返回重新切块的来源数
+func (nm *NovelManager) RefreshRetrievalIndex(ctx context.Context) (int, error) {
+	return nm.refreshRetrieval(ctx)
+}
+
+// Search 在正文、设定和历史讨论中检索，BM25 与向量相似度混合打分
+func (nm *NovelManager) Search(ctx context.Context, query string, opts SearchOptions) ([]*SearchHit, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("search query is empty")
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = 10
+	}
+
+	// 补向量最多用去剩余时间的三分之二，留出查询向量化的时间；没补完的下次继续
+	refreshCtx := ctx
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		refreshCtx, cancel = context.WithDeadline(ctx, time.Now().Add(time.Until(deadline)*2/3))
+		defer cancel()
+	}
+	if _, err := nm.refreshRetrieval(refreshCtx); err != nil {
+		return nil, err
+	}
+
+	// 语义打分：查询向量化在锁外进行，失败时退回纯词面检索
+	nm.retrieval.mutex.Lock()
+	embedder, embedderName := nm.indexEmbedder(), nm.retrieval.Embedder
+	nm.retrieval.mutex.Unlock()
+	var queryVector []float32
+	if embedder != nil {
+		if vectors, err := embedder.Embed(ctx, []string{query}); err == nil && len(vectors) == 1 {
+			queryVector = vectors[0]
+		}
+	}
+
+	nm.retrieval.mutex.Lock()
+	defer nm.retrieval.mutex.Unlock()
+
+	index := nm.retrieval
+	if index.Embedder != embedderName {
+		// 等待期间索引改用了别的向量化器，查询向量不再可比
+		queryVector = nil
+	}
+
+	candidates := make([]*RetrievalChunk, 0, len(index.Chunks))
+	for _, chunk := range index.Chunks {
+		if len(opts.Sources) > 0 && !containsString(opts.Sources, chunk.Source) {
+			continue
+		}
+		if chunk.Source == SourceChapter {
+			if opts.BeforeChapter > 0 && chunk.Chapter >= opts.BeforeChapter {
+				continue
+			}
+			if opts.ExcludeChapter > 0 && chunk.Chapter == opts.ExcludeChapter {
+				continue
+			}
+		}
+		candidates = append(candidates, chunk)
+	}
+
+	// 词面打分
+	queryTerms := uniqueStrings(tokenize(query))
+	lexical := make([]float64, len(candidates))
+	maxLexical := 0.0
+	for i, chunk := range candidates {
+		lexical[i] = index.bm25(chunk, queryTerms)
+		if lexical[i] > maxLexical {
+			maxLexical = lexical[i]
+		}
+	}
+
+	hits := make([]*SearchHit, 0)
+	for i, chunk := range candidates {
+		hit := &SearchHit{Chunk: chunk}
+		if maxLexical > 0 {
+			hit.Lexical = lexical[i] / maxLexical
+		}
+		if queryVector != nil && len(chunk.Vector) == len(queryVector) {
+			hit.Semantic = math.Max(0, cosine(queryVector, chunk.Vector))
+			hit.Score = (1-semanticWeight)*hit.Lexical + semanticWeight*hit.Semantic
+		} else {
+			hit.Score = hit.Lexical
+		}
+		if hit.Lexical > 0 || hit.Semantic >= minSemanticOnly {
+			hits = append(hits, hit)
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Score > hits[j].Score
+	})
+	if len(hits) > opts.Limit {
+		hits = hits[:opts.Limit]
+	}
+	return hits, nil
+}
+
+// RetrievalEmbedderName 当前索引使用的向量化器
+func (nm *NovelManager) RetrievalEmbedderName() string {
+	nm.retrieval.mutex.Lock()
+	defer nm.retrieval.mutex.Unlock()
+
+	return nm.retrieval.Embedder
+}
+
+// FormatSearchHits 格式化检索结果
+func FormatSearchHits(hits []*SearchHit, maxRunes int) string {
+	if len(hits) == 0 {
+		return "（没有找到相关内容）\n"
+	}
+	var result strings.Builder
+	for i, hit := range hits {
+		result.WriteString(fmt.Sprintf("%d. [%s] 相关度 %.2f\n", i+1, hit.Chunk.Location(), hit.Score))
+		result.WriteString(fmt.Sprintf("   %s\n", truncateRunes(strings.Join(strings.Fields(hit.Chunk.Text), " "), maxRunes)))
+	}
+	return result.String()
+}
+
+// Location 检索单元的出处描述
+func (c *RetrievalChunk) Location() string {
+	switch c.Source {
+	case SourceChapter:
+		if c.Line > 0 {
+			return fmt.Sprintf("第%d章 第%d行", c.Chapter, c.Line)
+		}
+		return fmt.Sprintf("第%d章", c.Chapter)
+	case SourceSetting:
+		return "设定 " + c.Title
+	case SourceCharacter:
+		return "角色 " + c.Title
+	case SourcePlot:
+		return "情节线 " + c.Title
+	case SourceChat:
+		return "讨论 " + c.Title
+	}
+	return c.Title
+}
+
+// embeddingJob 一次向量化请求：待补向量的检索单元及其文本
+type embeddingJob struct {
+	embedder Embedder
+	name     string
+	pending  []*RetrievalChunk
+	texts    []string
+}
+
+// refreshRetrieval 增量更新检索索引，返回重新切块的来源数。切块后先落盘，再按批补向量，每批完成后落盘；
+// 调用向量接口时释放锁，避免网络请求阻塞其他检索
+func (nm *NovelManager) refreshRetrieval(ctx context.Context) (int, error) {
+	index := nm.retrieval
+	index.mutex.Lock()
+	changed, dirty, err := nm.rechunkLocked()
+	if err == nil && dirty {
+		index.computeStats()
+		err = nm.persistLocked()
+	}
+	index.mutex.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		index.mutex.Lock()
+		job := nm.pendingEmbeddingsLocked(embeddingBatchSize)
+		index.mutex.Unlock()
+		if job == nil {
+			return changed, nil
+		}
+
+		vectors, err := job.embedder.Embed(ctx, job.texts)
+		if err == nil && len(vectors) != len(job.texts) {
+			err = fmt.Errorf("embedder returned %d vectors for %d texts", len(vectors), len(job.texts))
+		}
+
+		index.mutex.Lock()
+		progressed, err := nm.applyEmbeddingsLocked(ctx, job, vectors, err)
+		if err == nil {
+			err = nm.persistLocked()
+		}
+		index.mutex.Unlock()
+		if err != nil {
+			return 0, err
+		}
+		if !progressed {
+			return changed, nil
+		}
+	}
+}
+
+// rechunkLocked 按内容哈希重新切分有变化的来源并移除已删除的来源，返回变化的来源数和索引是否改动；
+// 调用方需持有索引锁
+func (nm *NovelManager) rechunkLocked() (int, bool, error) {
+	index := nm.retrieval
+	if !index.loaded {
+		index.load(nm.projectPath)
+	}
+
+	sources, err := nm.retrievalSources(index.Sources, index.stamps)
+	if err != nil {
+		return 0, false, err
+	}
+
+	seen := make(map[string]bool, len(sources))
+	kept := make([]*RetrievalChunk, 0, len(index.Chunks))
+	changedKeys := make(map[string]bool)
+	for _, source := range sources {
+		seen[source.Key] = true
+		if source.Stable {
+			continue
+		}
+		if index.Sources[source.Key] != contentHash(source.Title+"\x00"+source.Text) {
+			changedKeys[source.Key] = true
+		}
+	}
+	for _, chunk := range index.Chunks {
+		if seen[chunk.Key] && !changedKeys[chunk.Key] {
+			kept = append(kept, chunk)
+		}
+	}
+	removed := 0
+	for key := range index.Sources {
+		if !seen[key] {
+			delete(index.Sources, key)
+			delete(index.stamps, key)
+			index.journal = append(index.journal, &retrievalLogLine{Key: key})
+			removed++
+		}
+	}
+
+	for _, source := range sources {
+		if source.Source == SourceChapter && !source.Stable {
+			index.stamps[source.Key] = source.stamp
+		}
+		if !changedKeys[source.Key] {
+			continue
+		}
+		line := &retrievalLogLine{Key: source.Key, Hash: contentHash(source.Title + "\x00" + source.Text)}
+		for i, piece := range chunkText(source.Text) {
+			chunk := &RetrievalChunk{
+				ID:      fmt.Sprintf("%s#%d", source.Key, i),
+				Source:  source.Source,
+				Key:     source.Key,
+				Chapter: source.Chapter,
+				Title:   source.Title,
+				Text:    piece.Text,
+			}
+			if source.Source == SourceChapter {
+				chunk.Line = piece.Line
+			}
+			chunk.analyze()
+			kept = append(kept, chunk)
+			line.Chunks = append(line.Chunks, chunk)
+		}
+		index.Sources[source.Key] = line.Hash
+		index.journal = append(index.journal, line)
+	}
+	index.Chunks = kept
+	return len(changedKeys), len(changedKeys) > 0 || removed > 0, nil
+}
+
+// pendingEmbeddingsLocked 收集至多 limit 个缺少向量的检索单元；向量化器变化时先清空旧向量。调用方需持有索引锁
+func (nm *NovelManager) pendingEmbeddingsLocked(limit int) *embeddingJob {
+	index := nm.retrieval
+	if index.paused {
+		return nil
+	}
+	embedder := nm.embedder
+	if embedder == nil || index.fallback {
+		embedder = HashingEmbedder{}
+	}
+
+	// 向量化器变化后旧向量不可比较，需要全部重建
+	if index.Embedder != embedder.Name() {
+		for _, chunk := range index.Chunks {
+			chunk.Vector = nil
+		}
+		index.Embedder = embedder.Name()
+		index.rewrite = true
+	}
+
+	job := &embeddingJob{embedder: embedder, name: embedder.Name()}
+	for _, chunk := range index.Chunks {
+		if len(job.pending) >= limit {
+			break
+		}
+		if len(chunk.Vector) == 0 {
+			job.pending = append(job.pending, chunk)
+			job.texts = append(job.texts, chunk.Title+"\n"+chunk.Text)
+		}
+	}
+	if len(job.pending) == 0 {
+		return nil
+	}
+	return job
+}
+
+// applyEmbeddingsLocked 写回一批向量化结果，返回是否有进展，没有进展时停止本次补向量。
+// 超时或取消只是中断，已完成的批次保留，下次检索继续；提供商出错时，索引已有其向量则本次会话不再请求，
+// 保留这些向量，否则整个索引退回本地哈希向量。释放锁期间索引可能已被其他检索更新，
+// 只写回仍在索引中、仍在等待向量且向量化器未变的单元。调用方需持有索引锁
+func (nm *NovelManager) applyEmbeddingsLocked(ctx context.Context, job *embeddingJob, vectors [][]float32, embedErr error) (bool, error) {
+	index := nm.retrieval
+	if embedErr != nil {
+		if ctx.Err() != nil || errors.Is(embedErr, context.DeadlineExceeded) || errors.Is(embedErr, context.Canceled) {
+			return false, nil
+		}
+		if _, isLocal := job.embedder.(HashingEmbedder); isLocal {
+			return false, fmt.Errorf("failed to embed chunks: %w", embedErr)
+		}
+		if index.Embedder == job.name && index.hasVectors() {
+			index.paused = true
+			return false, nil
+		}
+		// 离线或提供商不支持时，本次会话内整个索引改用本地哈希向量（本地计算，无需释放锁）
+		local := HashingEmbedder{}
+		index.fallback = true
+		index.Embedder = local.Name()
+		index.rewrite = true
+		texts := make([]string, len(index.Chunks))
+		for i, chunk := range index.Chunks {
+			texts[i] = chunk.Title + "\n" + chunk.Text
+		}
+		vectors, err := local.Embed(ctx, texts)
+		if err != nil {
+			return false, err
+		}
+		for i, chunk := range index.Chunks {
+			chunk.Vector = vectors[i]
+		}
+		return true, nil
+	}
+
+	if index.Embedder != job.name {
+		return true, nil
+	}
+	current := make(map[*RetrievalChunk]bool, len(index.Chunks))
+	for _, chunk := range index.Chunks {
+		current[chunk] = true
+	}
+	line := &retrievalLogLine{Vectors: make(map[string][]float32)}
+	for i, chunk := range job.pending {
+		if current[chunk] && len(chunk.Vector) == 0 && len(vectors[i]) > 0 {
+			chunk.Vector = vectors[i]
+			line.Vectors[chunk.ID] = vectors[i]
+		}
+	}
+	if len(line.Vectors) == 0 {
+		return false, nil
+	}
+	index.journal = append(index.journal, line)
+	return true, nil
+}
+
+// persistLocked 把索引改动落盘：平时只向增量日志追加本次改动；新建、更换向量化器、退回本地向量，
+// 或日志超过快照大小时重写快照并删除日志。调用方需持有索引锁
+func (nm *NovelManager) persistLocked() error {
+	index := nm.retrieval
+	if index.rewrite || (index.logSize >= retrievalCompactMinBytes && index.logSize > index.snapSize) {
+		return index.writeSnapshot(nm.projectPath)
+	}
+	if len(index.journal) == 0 {
+		return nil
+	}
+	return index.appendLog(filepath.Join(nm.projectPath, RetrievalLogFile))
+}
+
+// indexEmbedder 返回与索引向量一致的向量化器，调用方需持有索引锁
+func (nm *NovelManager) indexEmbedder() Embedder {
+	if nm.embedder != nil && !nm.retrieval.fallback && nm.embedder.Name() == nm.retrieval.Embedder {
+		return nm.embedder
+	}
+	if local := (HashingEmbedder{}); local.Name() == nm.retrieval.Embedder {
+		return local
+	}
+	return nil
+}
+
+// retrievalSources 收集待索引的正文、设定、角色、情节线和历史讨论；已索引的聊天记录，
+// 以及修改时间、大小和标题都与上次切块时相同的章节不再读取
+func (nm *NovelManager) retrievalSources(indexed map[string]string, stamps map[string]fileStamp) ([]retrievalSource, error) {
+	nm.mutex.RLock()
+	if nm.novelData == nil {
+		nm.mutex.RUnlock()
+		return nil, fmt.Errorf("novel project not initialized")
+	}
+	project := nm.novelData
+	sources := make([]retrievalSource, 0)
+	titles := make(map[int]string)
+	for _, chapter := range project.Chapters {
+		titles[chapter.Number] = chapter.Title
+	}
+	for name, setting := range project.WorldSettings {
+		text := fmt.Sprintf("%s（%s）\n%s", name, setting.Category, setting.Description)
+		if len(setting.Rules) > 0 {
+			text += "\n规则: " + strings.Join(setting.Rules, "；")
+		}
+		sources = append(sources, retrievalSource{Key: SourceSetting + ":" + name, Source: SourceSetting, Title: name, Text: text})
+	}
+	for name, char := range project.Characters {
+		sources = append(sources, retrievalSource{Key: SourceCharacter + ":" + name, Source: SourceCharacter, Title: name, Text: characterDocument(char)})
+	}
+	for name, plot := range project.PlotLines {
+		sources = append(sources, retrievalSource{Key: SourcePlot + ":" + name, Source: SourcePlot, Title: name, Text: plotDocument(plot)})
+	}
+	reader := nm.chatLog.newReader()
+	for _, entry := range nm.chatLog.order {
+		key := SourceChat + ":" + entry.ID
+		if _, ok := indexed[key]; ok {
+			sources = append(sources, retrievalSource{Key: key, Source: SourceChat, Stable: true})
+			continue
+		}
+		record, err := reader.read(entry)
+		if err != nil {
+			reader.close()
+			nm.mutex.RUnlock()
+			return nil, err
+		}
+		sources = append(sources, retrievalSource{
+			Key:     key,
+			Source:  SourceChat,
+			Chapter: record.ChapterNum,
+			Title:   record.Timestamp.Format("01-02 15:04"),
+			Text:    "用户: " + record.UserMessage + "\nAI: " + record.AIResponse,
+		})
+	}
+	reader.close()
+	nm.mutex.RUnlock()
+
+	numbers, err := nm.listChapterNumbers()
+	if err != nil {
+		return nil, err
+	}
+	for _, number := range numbers {
+		key := fmt.Sprintf("%s:%d", SourceChapter, number)
+		title := chapterHeading(number, titles[number])
+		info, err := os.Stat(nm.ChapterFilePath(number))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat chapter %d: %w", number, err)
+		}
+		stamp := fileStamp{modTime: info.ModTime(), size: info.Size(), title: title}
+		if _, ok := indexed[key]; ok && stamps[key] == stamp {
+			sources = append(sources, retrievalSource{Key: key, Source: SourceChapter, Chapter: number, Stable: true})
+			continue
+		}
+		text, err := nm.ReadChapterText(number)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, retrievalSource{
+			Key:     key,
+			Source:  SourceChapter,
+			Chapter: number,
+			Title:   title,
+			Text:    text,
+			stamp:   stamp,
+		})
+	}
+
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Key < sources[j].Key })
+	return sources, nil
+}
+
+// relatedPassages 章节上下文中的相关前文：以本章概要（或上一章结尾）检索之前的内容
+func (nm *NovelManager) relatedPassages(chapterNum int, query string, limit int) string {
+	if strings.TrimSpace(query) == "" {
+		if text, err := nm.ReadChapterText(chapterNum - 1); err == nil {
+			runes := []rune(strings.TrimSpace(text))
+			if len(runes) > chunkTargetRunes {
+				runes = runes[len(runes)-chunkTargetRunes:]
+			}
+			query = string(runes)
+		}
+	}
+	if strings.TrimSpace(query) == "" {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), relatedPassagesTimeout)
+	defer cancel()
+	hits, err := nm.Search(ctx, query, SearchOptions{Limit: limit, BeforeChapter: chapterNum})
+	if err != nil || len(hits) == 0 {
+		return ""
+	}
+	return FormatSearchHits(hits, 120)
+}
+
+func (index *retrievalIndex) load(dir string) {
+	index.loaded = true
+	index.Sources = make(map[string]string)
+	index.stamps = make(map[string]fileStamp)
+	index.Chunks = make([]*RetrievalChunk, 0)
+	index.rewrite = true
+
+	data, err := os.ReadFile(filepath.Join(dir, RetrievalIndexFile))
+	if err != nil {
+		return
+	}
+	var stored retrievalIndex
+	// 索引损坏或版本不符时直接重建，增量日志随之作废
+	if err := json.Unmarshal(data, &stored); err != nil || stored.Version != retrievalIndexVersion {
+		return
+	}
+	index.Embedder = stored.Embedder
+	if stored.Sources != nil {
+		index.Sources = stored.Sources
+	}
+	index.Chunks = stored.Chunks
+	index.snapSize = int64(len(data))
+	index.rewrite = index.replayLog(filepath.Join(dir, RetrievalLogFile)) != nil
+	for _, chunk := range index.Chunks {
+		chunk.analyze()
+	}
+	index.computeStats()
+}
+
+// replayLog 在快照之上按顺序重放增量日志；末尾写了一半的行会被截断
+func (index *retrievalIndex) replayLog(path string) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open retrieval log: %w", err)
+	}
+	defer file.Close()
+
+	byKey := make(map[string][]*RetrievalChunk)
+	keys := make([]string, 0)
+	for _, chunk := range index.Chunks {
+		if _, ok := byKey[chunk.Key]; !ok {
+			keys = append(keys, chunk.Key)
+		}
+		byKey[chunk.Key] = append(byKey[chunk.Key], chunk)
+	}
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	var readErr error
+	torn := false
+	for {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			torn = len(data) > 0
+			break
+		}
+		if err != nil {
+			readErr = fmt.Errorf("failed to read retrieval log: %w", err)
+			break
+		}
+		var line retrievalLogLine
+		if json.Unmarshal(data, &line) != nil {
+			torn = true
+			break
+		}
+		stale := line.Embedder != index.Embedder
+		if line.Key != "" {
+			if _, ok := byKey[line.Key]; !ok {
+				keys = append(keys, line.Key)
+			}
+			if line.Hash == "" {
+				delete(index.Sources, line.Key)
+			} else {
+				index.Sources[line.Key] = line.Hash
+			}
+			for _, chunk := range line.Chunks {
+				if stale {
+					chunk.Vector = nil
+				}
+			}
+			byKey[line.Key] = line.Chunks
+		}
+		if !stale {
+			for id, vector := range line.Vectors {
+				if sep := strings.LastIndex(id, "#"); sep > 0 {
+					for _, chunk := range byKey[id[:sep]] {
+						if chunk.ID == id {
+							chunk.Vector = vector
+						}
+					}
+				}
+			}
+		}
+		offset += int64(len(data))
+	}
+
+	index.Chunks = make([]*RetrievalChunk, 0, len(index.Chunks))
+	for _, key := range keys {
+		index.Chunks = append(index.Chunks, byKey[key]...)
+	}
+	index.logSize = offset
+	if readErr != nil {
+		return readErr
+	}
+	if torn {
+		if err := os.Truncate(path, offset); err != nil {
+			return fmt.Errorf("failed to truncate retrieval log: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeSnapshot 重写完整的索引快照并删除增量日志。先删日志再写快照：中途失败时留下的旧快照与
+// 其来源哈希仍然一致，下次刷新会重新切块
+func (index *retrievalIndex) writeSnapshot(dir string) error {
+	if err := os.Remove(filepath.Join(dir, RetrievalLogFile)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove retrieval log: %w", err)
+	}
+	index.logSize = 0
+
+	index.Version = retrievalIndexVersion
+	data, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("failed to marshal retrieval index: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(dir, RetrievalIndexFile), data, false); err != nil {
+		return fmt.Errorf("failed to save retrieval index: %w", err)
+	}
+	index.snapSize = int64(len(data))
+	index.journal = nil
+	index.rewrite = false
+	return nil
+}
+
+// appendLog 把尚未落盘的改动追加到增量日志；写入失败时下次改为重写快照
+func (index *retrievalIndex) appendLog(path string) error {
+	var data []byte
+	for _, line := range index.journal {
+		line.Embedder = index.Embedder
+		encoded, err := json.Marshal(line)
+		if err != nil {
+			return fmt.Errorf("failed to marshal retrieval log: %w", err)
+		}
+		data = append(append(data, encoded...), '\n')
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		index.rewrite = true
+		return fmt.Errorf("failed to open retrieval log: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(data); err != nil {
+		index.rewrite = true
+		return fmt.Errorf("failed to append retrieval log: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		index.rewrite = true
+		return fmt.Errorf("failed to sync retrieval log: %w", err)
+	}
+	index.logSize += int64(len(data))
+	index.journal = nil
+	return nil
+}
+
+// hasVectors 索引中是否已有检索单元带向量
+func (index *retrievalIndex) hasVectors() bool {
+	for _, chunk := range index.Chunks {
+		if len(chunk.Vector) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (index *retrievalIndex) computeStats() {
+	index.df = make(map[string]int)
+	total := 0
+	for _, chunk := range index.Chunks {
+		for term := range chunk.terms {
+			index.df[term]++
+		}
+		total += chunk.length
+	}
+	index.avgLen = 0
+	if len(index.Chunks) > 0 {
+		index.avgLen = float64(total) / float64(len(index.Chunks))
+	}
+}
+
+func (index *retrievalIndex) bm25(chunk *RetrievalChunk, queryTerms []string) float64 {
+	if index.avgLen == 0 {
+		return 0
+	}
+	n := float64(len(index.Chunks))
+	score := 0.0
+	for _, term := range queryTerms {
+		tf := float64(chunk.terms[term])
+		if tf == 0 {
+			continue
+		}
+		df := float64(index.df[term])
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		norm := tf + bm25K1*(1-bm25B+bm25B*float64(chunk.length)/index.avgLen)
+		score += idf * tf * (bm25K1 + 1) / norm
+	}
+	return score
+}
+
+func (c *RetrievalChunk) analyze() {
+	tokens := tokenize(c.Title + "\n" + c.Text)
+	c.terms = make(map[string]int, len(tokens))
+	for _, token := range tokens {
+		c.terms[token]++
+	}
+	c.length = len(tokens)
+}
+
+// textPiece 切块结果
+type textPiece struct {
+	Text string
+	Line int // 起始行号（从1开始）
+}
+
+// chunkText 按段落累积切块，单段过长时按句子拆分
+func chunkText(text string) []textPiece {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	pieces := make([]textPiece, 0)
+	var current strings.Builder
+	currentRunes, startLine := 0, 0
+
+	flush := func() {
+		if currentRunes > 0 {
+			pieces = append(pieces, textPiece{Text: current.String(), Line: startLine})
+		}
+		current.Reset()
+		currentRunes, startLine = 0, 0
+	}
+
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		for _, part := range splitLongLine(line) {
+			partRunes := len([]rune(part))
+			if currentRunes > 0 && currentRunes+partRunes > chunkMaxRunes {
+				flush()
+			}
+			if startLine == 0 {
+				startLine = i + 1
+			}
+			if currentRunes > 0 {
+				current.WriteString("\n")
+			}
+			current.WriteString(part)
+			currentRunes += partRunes
+			if currentRunes >= chunkTargetRunes {
+				flush()
+			}
+		}
+	}
+	flush()
+	return pieces
+}
+
+func splitLongLine(line string) []string {
+	if len([]rune(line)) <= chunkMaxRunes {
+		return []string{line}
+	}
+	parts := make([]string, 0)
+	var current strings.Builder
+	count := 0
+	for _, sentence := range sentenceEndPattern.FindAllString(line, -1) {
+		n := len([]rune(sentence))
+		if count > 0 && count+n > chunkMaxRunes {
+			parts = append(parts, current.String())
+			current.Reset()
+			count = 0
+		}
+		current.WriteString(sentence)
+		count += n
+	}
+	if count > 0 {
+		parts = append(parts, current.String())
+	}
+	return parts
+}
+
+// tokenize 中文按相邻二字切分（单字成词时保留单字），拉丁字母和数字按词切分并转小写
+func tokenize(text string) []string {
+	tokens := make([]string, 0)
+	var han, word []rune
+
+	flushHan := func() {
+		if len(han) == 1 {
+			tokens = append(tokens, string(han))
+		}
+		for i := 0; i+1 < len(han); i++ {
+			tokens = append(tokens, string(han[i:i+2]))
+		}
+		han = han[:0]
+	}
+	flushWord := func() {
+		if len(word) > 1 || (len(word) == 1 && unicode.IsDigit(word[0])) {
+			tokens = append(tokens, string(word))
+		}
+		word = word[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushHan()
+			word = append(word, unicode.ToLower(r))
+		default:
+			flushHan()
+			flushWord()
+		}
+	}
+	flushHan()
+	flushWord()
+	return tokens
+}
+
+func characterDocument(char *Character) string {
+	var doc strings.Builder
+	doc.WriteString(char.Name)
+	if char.Gender != "" || char.Age > 0 {
+		doc.WriteString(fmt.Sprintf("，%s %d岁", char.Gender, char.Age))
+	}
+	if char.Occupation != "" {
+		doc.WriteString("，" + char.Occupation)
+	}
+	doc.WriteString("\n")
+	if len(char.Personality) > 0 {
+		doc.WriteString("性格: " + strings.Join(char.Personality, "、") + "\n")
+	}
+	if char.Appearance != "" {
+		doc.WriteString("外貌: " + char.Appearance + "\n")
+	}
+	if char.Background != "" {
+		doc.WriteString("背景: " + char.Background + "\n")
+	}
+	for other, relation := range char.Relationships {
+		doc.WriteString(fmt.Sprintf("%s是其%s\n", other, relation))
+	}
+	if len(char.CharacterArc) > 0 {
+		doc.WriteString("成长: " + strings.Join(char.CharacterArc, "；") + "\n")
+	}
+	return doc.String()
+}
+
+func plotDocument(plot *PlotLine) string {
+	var doc strings.Builder
+	doc.WriteString(fmt.Sprintf("%s（%s，%s）\n", plot.Name, plot.Type, plot.Status))
+	if plot.Description != "" {
+		doc.WriteString(plot.Description + "\n")
+	}
+	for _, event := range plot.KeyEvents {
+		doc.WriteString(fmt.Sprintf("第%d章: %s\n", event.Chapter, event.Description))
+	}
+	for _, item := range plot.Foreshadowing {
+		doc.WriteString("伏笔: " + item.Description + "\n")
+	}
+	return doc.String()
+}
+
+func contentHash(text string) string {
+	sum := sha1.Sum([]byte(text))
+	return hex.EncodeToString(sum[:8])
+}
+
+func normalizeVector(vector []float32) []float32 {
+	norm := 0.0
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return vector
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] = float32(float64(vector[i]) / norm)
+	}
+	return vector
+}
+
+// cosine 向量均已归一化，点积即余弦相似度
+func cosine(a, b []float32) float64 {
+	sum := 0.0
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func truncateRunes(s string, maxRunes int) string {
+	runes := []rune(s)
+	if maxRunes <= 0 || len(runes) <= maxRunes {
+		return s
+	}
+	return string(runes[:maxRunes]) + "…"
+}
+
+// chatRecordID 从历史讨论的来源标识中取出聊天记录ID
+func chatRecordID(key string) (string, bool) {
+	id := strings.TrimPrefix(key, SourceChat+":")
+	return id, id != key && id != ""
+}
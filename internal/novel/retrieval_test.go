@@ -0,0 +1,358 @@
+package novel
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeEmbedder 模拟向量提供商；count 为返回向量数相对请求文本数的偏移，err 非空时前 okCalls 次请求成功
+type fakeEmbedder struct {
+	count   int
+	err     error
+	okCalls int
+	batches []int // 每次请求的文本数
+	started chan struct{}
+	release chan struct{}
+}
+
+func (e *fakeEmbedder) Name() string { return "fake" }
+
+func (e *fakeEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if e.started != nil {
+		close(e.started)
+		e.started = nil
+		<-e.release
+	}
+	e.batches = append(e.batches, len(texts))
+	if e.err != nil && len(e.batches) > e.okCalls {
+		return nil, e.err
+	}
+	vectors := make([][]float32, 0, len(texts))
+	for i := 0; i < len(texts)+e.count; i++ {
+		vectors = append(vectors, []float32{1, 0, 0})
+	}
+	return vectors, nil
+}
+
+func TestRefreshRetrievalIndexFallback(t *testing.T) {
+	local := HashingEmbedder{}.Name()
+	tests := []struct {
+		name         string
+		embedder     *fakeEmbedder
+		wantEmbedder string
+	}{
+		{"提供商正常", &fakeEmbedder{}, "fake"},
+		{"向量数少于文本数时退回本地向量", &fakeEmbedder{count: -1}, local},
+		{"向量数多于文本数时退回本地向量", &fakeEmbedder{count: 1}, local},
+		{"提供商出错时退回本地向量", &fakeEmbedder{err: errors.New("offline")}, local},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nm := newTestManager(t)
+			writeTestChapter(t, nm, 1, "林舟在雨夜推开了客栈的门。\n\n掌柜抬头看了他一眼。")
+			if _, err := nm.SyncChapterStats(); err != nil {
+				t.Fatal(err)
+			}
+			nm.SetEmbedder(tt.embedder)
+
+			if _, err := nm.RefreshRetrievalIndex(context.Background()); err != nil {
+				t.Fatalf("RefreshRetrievalIndex: %v", err)
+			}
+			index := nm.retrieval
+			if index.Embedder != tt.wantEmbedder {
+				t.Errorf("index embedder = %q, want %q", index.Embedder, tt.wantEmbedder)
+			}
+			if len(index.Chunks) == 0 {
+				t.Fatal("no chunks indexed")
+			}
+			for _, chunk := range index.Chunks {
+				if len(chunk.Vector) == 0 {
+					t.Errorf("chunk %s has no vector", chunk.ID)
+				}
+			}
+			hits, err := nm.Search(context.Background(), "客栈", SearchOptions{})
+			if err != nil || len(hits) == 0 {
+				t.Errorf("Search = %d hits, %v", len(hits), err)
+			}
+		})
+	}
+}
+
+func TestRefreshRetrievalIndexEmbedsOutsideLock(t *testing.T) {
+	nm := newTestManager(t)
+	writeTestChapter(t, nm, 1, "林舟在雨夜推开了客栈的门。")
+	if _, err := nm.SyncChapterStats(); err != nil {
+		t.Fatal(err)
+	}
+	embedder := &fakeEmbedder{started: make(chan struct{}), release: make(chan struct{})}
+	started := embedder.started
+	nm.SetEmbedder(embedder)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := nm.RefreshRetrievalIndex(context.Background())
+		done <- err
+	}()
+	<-started
+
+	// 向量请求进行中，索引锁应当可用
+	locked := make(chan struct{})
+	go func() {
+		nm.retrieval.mutex.Lock()
+		nm.retrieval.mutex.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(2 * time.Second):
+		t.Error("retrieval lock held while waiting for the embedder")
+	}
+
+	close(embedder.release)
+	if err := <-done; err != nil {
+		t.Fatalf("RefreshRetrievalIndex: %v", err)
+	}
+	if nm.retrieval.Embedder != "fake" {
+		t.Errorf("index embedder = %q, want fake", nm.retrieval.Embedder)
+	}
+}
+
+func TestRefreshRetrievalIndexSkipsUnchangedChapters(t *testing.T) {
+	nm := newTestManager(t)
+	writeTestChapter(t, nm, 1, "林舟在雨夜推开了客栈的门。")
+	writeTestChapter(t, nm, 2, "掌柜抬头看了他一眼。")
+	if _, err := nm.SyncChapterStats(); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := nm.RefreshRetrievalIndex(ctx); err != nil {
+		t.Fatalf("RefreshRetrievalIndex: %v", err)
+	}
+
+	indexPath := filepath.Join(nm.ProjectPath(), RetrievalIndexFile)
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(indexPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	stableChapters := func() map[int]bool {
+		t.Helper()
+		sources, err := nm.retrievalSources(nm.retrieval.Sources, nm.retrieval.stamps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stable := make(map[int]bool)
+		for _, source := range sources {
+			if source.Source == SourceChapter {
+				stable[source.Chapter] = source.Stable
+			}
+		}
+		return stable
+	}
+
+	// 没有改动：不重新读取章节，也不重写索引文件
+	if changed, err := nm.RefreshRetrievalIndex(ctx); err != nil || changed != 0 {
+		t.Fatalf("refresh without changes = %d, %v", changed, err)
+	}
+	if got := stableChapters(); !got[1] || !got[2] {
+		t.Errorf("unchanged chapters should be skipped, stable = %v", got)
+	}
+	if info, err := os.Stat(indexPath); err != nil || !info.ModTime().Equal(old) {
+		t.Errorf("index file rewritten without changes: %v", err)
+	}
+
+	// 只有改动过的章节重新切块
+	writeTestChapter(t, nm, 2, "掌柜抬头看了他一眼，又低头拨起了算盘。")
+	if got := stableChapters(); !got[1] || got[2] {
+		t.Errorf("only chapter 2 should be re-read, stable = %v", got)
+	}
+	if changed, err := nm.RefreshRetrievalIndex(ctx); err != nil || changed != 1 {
+		t.Fatalf("refresh after editing chapter 2 = %d, %v", changed, err)
+	}
+	hits, err := nm.Search(ctx, "算盘", SearchOptions{Sources: []string{SourceChapter}})
+	if err != nil || len(hits) == 0 || hits[0].Chunk.Chapter != 2 {
+		t.Errorf("search after edit = %v, %v", hits, err)
+	}
+}
+
+// writeLongChapter 写入每行自成一个检索单元的章节
+func writeLongChapter(t *testing.T, nm *NovelManager, number, lines int) {
+	t.Helper()
+	paragraph := strings.Repeat("林舟沿着山路走了很久，", 28)
+	writeTestChapter(t, nm, number, strings.Repeat(paragraph+"\n", lines))
+}
+
+// reopenTestManager 从磁盘重新加载同一个项目
+func reopenTestManager(t *testing.T, nm *NovelManager) *NovelManager {
+	t.Helper()
+	nm.Close()
+	reloaded := NewNovelManager(nm.ProjectPath())
+	t.Cleanup(func() { reloaded.Close() })
+	if err := reloaded.LoadProject(); err != nil {
+		t.Fatal(err)
+	}
+	return reloaded
+}
+
+func countVectors(index *retrievalIndex) int {
+	count := 0
+	for _, chunk := range index.Chunks {
+		if len(chunk.Vector) > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+func TestRefreshRetrievalIndexEmbedsInBatches(t *testing.T) {
+	nm := newTestManager(t)
+	writeLongChapter(t, nm, 1, 70)
+	writeLongChapter(t, nm, 2, 70)
+	if _, err := nm.SyncChapterStats(); err != nil {
+		t.Fatal(err)
+	}
+	embedder := &fakeEmbedder{}
+	nm.SetEmbedder(embedder)
+	if _, err := nm.RefreshRetrievalIndex(context.Background()); err != nil {
+		t.Fatalf("RefreshRetrievalIndex: %v", err)
+	}
+
+	total := len(nm.retrieval.Chunks)
+	if total < 140 {
+		t.Fatalf("got %d chunks, want at least 140", total)
+	}
+	sum := 0
+	for _, size := range embedder.batches {
+		if size > embeddingBatchSize {
+			t.Errorf("batch of %d texts exceeds %d", size, embeddingBatchSize)
+		}
+		sum += size
+	}
+	if sum != total || len(embedder.batches) != (total+embeddingBatchSize-1)/embeddingBatchSize {
+		t.Errorf("batches = %v for %d chunks", embedder.batches, total)
+	}
+}
+
+func TestRefreshRetrievalIndexInterrupted(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantPaused bool
+	}{
+		{"超时后下次继续", context.DeadlineExceeded, false},
+		{"已有向量时提供商出错，保留向量并暂停请求", errors.New("offline"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nm := newTestManager(t)
+			writeLongChapter(t, nm, 1, 100)
+			if _, err := nm.SyncChapterStats(); err != nil {
+				t.Fatal(err)
+			}
+			embedder := &fakeEmbedder{err: tt.err, okCalls: 1}
+			nm.SetEmbedder(embedder)
+			if _, err := nm.RefreshRetrievalIndex(context.Background()); err != nil {
+				t.Fatalf("RefreshRetrievalIndex: %v", err)
+			}
+
+			index := nm.retrieval
+			if index.Embedder != "fake" || index.fallback || index.paused != tt.wantPaused {
+				t.Errorf("embedder = %q, fallback = %v, paused = %v", index.Embedder, index.fallback, index.paused)
+			}
+			if got := countVectors(index); got != embeddingBatchSize {
+				t.Errorf("%d chunks have vectors, want the first batch of %d", got, embeddingBatchSize)
+			}
+			total := len(index.Chunks)
+
+			// 重新启动后沿用已落盘的向量，只补剩下的单元
+			nm = reopenTestManager(t, nm)
+			embedder = &fakeEmbedder{}
+			nm.SetEmbedder(embedder)
+			if _, err := nm.RefreshRetrievalIndex(context.Background()); err != nil {
+				t.Fatalf("RefreshRetrievalIndex after reopen: %v", err)
+			}
+			sum := 0
+			for _, size := range embedder.batches {
+				sum += size
+			}
+			if sum != total-embeddingBatchSize {
+				t.Errorf("re-embedded %d texts after reopen, want %d", sum, total-embeddingBatchSize)
+			}
+			if got := countVectors(nm.retrieval); got != total {
+				t.Errorf("%d of %d chunks have vectors", got, total)
+			}
+		})
+	}
+}
+
+func TestRetrievalIndexLog(t *testing.T) {
+	nm := newTestManager(t)
+	writeTestChapter(t, nm, 1, "林舟在雨夜推开了客栈的门。")
+	writeTestChapter(t, nm, 2, "掌柜抬头看了他一眼。")
+	if _, err := nm.SyncChapterStats(); err != nil {
+		t.Fatal(err)
+	}
+	nm.SetEmbedder(&fakeEmbedder{})
+	ctx := context.Background()
+	if _, err := nm.RefreshRetrievalIndex(ctx); err != nil {
+		t.Fatalf("RefreshRetrievalIndex: %v", err)
+	}
+
+	indexPath := filepath.Join(nm.ProjectPath(), RetrievalIndexFile)
+	logPath := filepath.Join(nm.ProjectPath(), RetrievalLogFile)
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(indexPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	// 改动一章只追加日志，不重写快照
+	writeTestChapter(t, nm, 2, "掌柜抬头看了他一眼，又低头拨起了算盘。")
+	if _, err := nm.RefreshRetrievalIndex(ctx); err != nil {
+		t.Fatalf("RefreshRetrievalIndex: %v", err)
+	}
+	if info, err := os.Stat(indexPath); err != nil || !info.ModTime().Equal(old) {
+		t.Errorf("snapshot rewritten for a single changed chapter: %v", err)
+	}
+	info, err := os.Stat(logPath)
+	if err != nil {
+		t.Fatalf("retrieval log not written: %v", err)
+	}
+
+	want := make(map[string]string)
+	for _, chunk := range nm.retrieval.Chunks {
+		want[chunk.ID] = chunk.Text
+	}
+
+	// 日志末尾写了一半的行在加载时截断，之前的改动仍然有效
+	file, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"embedder":"fake","key":"chapter:3","hash":`)
+	file.Close()
+
+	var loaded retrievalIndex
+	loaded.load(nm.ProjectPath())
+	got := make(map[string]string)
+	for _, chunk := range loaded.Chunks {
+		got[chunk.ID] = chunk.Text
+		if len(chunk.Vector) == 0 {
+			t.Errorf("chunk %s lost its vector after reload", chunk.ID)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("reloaded chunks = %v, want %v", got, want)
+	}
+	if loaded.Sources["chapter:2"] != nm.retrieval.Sources["chapter:2"] {
+		t.Errorf("reloaded hash for chapter 2 = %q", loaded.Sources["chapter:2"])
+	}
+	if truncated, err := os.Stat(logPath); err != nil || truncated.Size() != info.Size() {
+		t.Errorf("torn log tail not truncated: %v", err)
+	}
+}
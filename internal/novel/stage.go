@@ -0,0 +1,180 @@
+package novel
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 创作阶段
+const (
+	StageConcept  = "concept"  // 构思：还没有角色、设定或大纲
+	StageOutline  = "outline"  // 大纲：有设定和章节规划，正文尚少
+	StageDrafting = "drafting" // 写作：持续产出正文
+	StageRevising = "revising" // 修改：达到目标字数，或最近以删改为主
+)
+
+const (
+	// 判断阶段时参考的最近天数与最近章节数
+	stageRecentDays     = 7
+	stageRecentChapters = 5
+)
+
+// CreativeStageReport 创作阶段判断结果
+type CreativeStageReport struct {
+	Stage       string
+	Evidence    []string      // 判断依据
+	Suggestions []string      // 针对当前阶段和最近章节节奏的建议
+	Tools       []string      // 推荐使用的工具
+	Pacing      *PacingReport // 最近几章的节奏，尚无正文时为空
+}
+
+// DetectCreativeStage 根据项目设定、正文进度、最近的字数变化和章节节奏判断当前创作阶段
+func (nm *NovelManager) DetectCreativeStage() (*CreativeStageReport, error) {
+	if !nm.HasProject() {
+		return &CreativeStageReport{
+			Stage:       StageConcept,
+			Evidence:    []string{"当前目录还没有小说项目"},
+			Suggestions: []string{"先确定题材、主角和核心冲突，再初始化项目"},
+			Tools:       []string{"init_novel_project"},
+		}, nil
+	}
+	if _, err := nm.SyncChapterStats(); err != nil {
+		return nil, err
+	}
+
+	nm.mutex.RLock()
+	characters := len(nm.novelData.Characters)
+	settings := len(nm.novelData.WorldSettings)
+	plots := len(nm.novelData.PlotLines)
+	target := nm.novelData.TargetWords
+	outlined, written, total, reviewed := 0, make([]int, 0), 0, 0
+	for _, chapter := range nm.novelData.Chapters {
+		if strings.TrimSpace(chapter.Summary) != "" {
+			outlined++
+		}
+		if chapter.WordCount > 0 {
+			written = append(written, chapter.Number)
+			total += chapter.WordCount
+		}
+		if chapter.Status == "reviewing" || chapter.Status == "completed" {
+			reviewed++
+		}
+	}
+	added, removed := 0, 0
+	if progress := nm.novelData.Progress; progress != nil {
+		since := time.Now().AddDate(0, 0, -stageRecentDays).Format(progressDateLayout)
+		for _, day := range progress.Daily {
+			if day.Date > since {
+				added += day.Added
+				removed += day.Removed
+			}
+		}
+	}
+	nm.mutex.RUnlock()
+
+	report := &CreativeStageReport{}
+	report.Evidence = append(report.Evidence, fmt.Sprintf("角色 %d 个，世界观设定 %d 项，情节线 %d 条", characters, settings, plots))
+	report.Evidence = append(report.Evidence, fmt.Sprintf("已写正文 %d 章共 %d 字，有大纲的章节 %d 章", len(written), total, outlined))
+	if added+removed > 0 {
+		report.Evidence = append(report.Evidence, fmt.Sprintf("最近 %d 天新增 %d 字，删减 %d 字", stageRecentDays, added, removed))
+	}
+
+	switch {
+	case characters == 0 && settings == 0 && len(written) == 0:
+		report.Stage = StageConcept
+		report.Suggestions = append(report.Suggestions, "先登记主角和核心设定，后续生成与检查都依赖这些信息")
+		report.Tools = []string{"add_character", "add_plot_line", "get_novel_context"}
+	case len(written) == 0:
+		report.Stage = StageOutline
+		if outlined == 0 {
+			report.Suggestions = append(report.Suggestions, "为前几章写下章节概要，生成章节时会以概要作为大纲")
+		} else {
+			report.Suggestions = append(report.Suggestions, fmt.Sprintf("已有 %d 章大纲，可以开始生成或撰写第一章", outlined))
+		}
+		report.Tools = []string{"add_plot_line", "plant_foreshadowing", "set_relationship", "generate_chapter"}
+	case (target > 0 && total >= target) || (removed > 0 && removed >= added) || reviewed*2 > len(written):
+		report.Stage = StageRevising
+		switch {
+		case target > 0 && total >= target:
+			report.Evidence = append(report.Evidence, fmt.Sprintf("已达到目标字数 %d", target))
+		case removed > 0 && removed >= added:
+			report.Evidence = append(report.Evidence, "最近的删减不少于新增，以修改为主")
+		default:
+			report.Evidence = append(report.Evidence, fmt.Sprintf("%d 章已进入审阅或完成状态", reviewed))
+		}
+		report.Suggestions = append(report.Suggestions, "集中检查设定矛盾、视角与人物声音，再逐章打磨")
+		report.Tools = []string{"check_consistency", "check_pov", "analyze_dialogue", "style_profile", "analyze_pacing"}
+	default:
+		report.Stage = StageDrafting
+		if target > 0 {
+			report.Evidence = append(report.Evidence, fmt.Sprintf("完成目标字数的 %.0f%%", float64(total)*100/float64(target)))
+		}
+		report.Tools = []string{"get_chapter_context", "generate_chapter", "list_foreshadowing", "analyze_pacing"}
+	}
+
+	if len(written) > 0 {
+		from := written[0]
+		if len(written) > stageRecentChapters {
+			from = written[len(written)-stageRecentChapters]
+		}
+		pacing, err := nm.AnalyzePacing(from, written[len(written)-1])
+		if err != nil {
+			return nil, err
+		}
+		report.Pacing = pacing
+		report.Suggestions = append(report.Suggestions, pacing.suggestions()...)
+	}
+	return report, nil
+}
+
+// suggestions 根据节奏指标给出写作建议
+func (r *PacingReport) suggestions() []string {
+	suggestions := make([]string, 0)
+	if len(r.Chapters) == 0 {
+		return suggestions
+	}
+	if r.HookRate < 0.5 {
+		suggestions = append(suggestions, fmt.Sprintf("最近 %d 章只有 %.0f%% 在章末留了钩子，可在结尾抛出危机、反转或未揭晓的信息", len(r.Chapters), r.HookRate*100))
+	}
+	if r.AvgDialogue < 0.1 {
+		suggestions = append(suggestions, fmt.Sprintf("对话占比只有 %.0f%%，大段叙述容易让读者疲劳，可用对话推进情节", r.AvgDialogue*100))
+	} else if r.AvgDialogue > 0.7 {
+		suggestions = append(suggestions, fmt.Sprintf("对话占比达 %.0f%%，可补充动作和环境描写", r.AvgDialogue*100))
+	}
+	suggestions = append(suggestions, r.Notes...)
+	return suggestions
+}
+
+// Format 格式化创作阶段判断结果
+func (r *CreativeStageReport) Format() string {
+	labels := map[string]string{
+		StageConcept:  "💡 构思阶段",
+		StageOutline:  "🗺 大纲阶段",
+		StageDrafting: "✍️ 写作阶段",
+		StageRevising: "🔧 修改阶段",
+	}
+	var result strings.Builder
+	result.WriteString("🎯 === 创作阶段 ===\n\n")
+	result.WriteString(fmt.Sprintf("当前阶段: %s\n", labelOr(labels, r.Stage)))
+	if len(r.Evidence) > 0 {
+		result.WriteString("\n📋 判断依据:\n")
+		for _, item := range r.Evidence {
+			result.WriteString("• " + item + "\n")
+		}
+	}
+	if r.Pacing != nil && len(r.Pacing.Chapters) > 0 {
+		result.WriteString(fmt.Sprintf("\n📈 最近 %d 章: 平均 %.0f 字，对话占比 %.0f%%，冲突密度 %.1f/千字，章末钩子 %.0f%%\n",
+			len(r.Pacing.Chapters), r.Pacing.AvgWords, r.Pacing.AvgDialogue*100, r.Pacing.AvgConflict, r.Pacing.HookRate*100))
+	}
+	if len(r.Suggestions) > 0 {
+		result.WriteString("\n💡 建议:\n")
+		for _, item := range r.Suggestions {
+			result.WriteString("• " + item + "\n")
+		}
+	}
+	if len(r.Tools) > 0 {
+		result.WriteString(fmt.Sprintf("\n🛠 推荐工具: %s\n", strings.Join(r.Tools, "、")))
+	}
+	return result.String()
+}
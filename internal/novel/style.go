@@ -0,0 +1,581 @@
+package novel
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// 短句与长句的字数界限
+	shortSentenceRunes = 10
+	longSentenceRunes  = 40
+	// 常用表达的最少出现次数与保留条数
+	minPhraseCount    = 3
+	maxProfilePhrases = 15
+	// 叙述中第一人称代词每千字超过该值时判定为第一人称
+	firstPersonThreshold = 8.0
+)
+
+// 常见四字成语，补充逗号句读切不出的嵌在长句中的成语
+var commonIdioms = []string{
+	"一心一意", "一模一样", "一如既往", "一无所知", "一言不发", "一动不动", "一触即发", "一帆风顺", "一目了然", "一针见血",
+	"不知不觉", "不由自主", "不可思议", "不言而喻", "不慌不忙", "不约而同", "不屑一顾", "不动声色", "不可一世", "不寒而栗",
+	"千钧一发", "千方百计", "万无一失", "万众瞩目", "小心翼翼", "心惊胆战", "心平气和", "心不在焉", "心急如焚", "心有余悸",
+	"目瞪口呆", "面面相觑", "面不改色", "若无其事", "若有所思", "若隐若现", "莫名其妙", "无影无踪", "无可奈何", "无能为力",
+	"理所当然", "自言自语", "自作自受", "咬牙切齿", "气急败坏", "惊慌失措", "手足无措", "毛骨悚然", "鸦雀无声", "悄无声息",
+	"天崩地裂", "惊天动地", "排山倒海", "翻江倒海", "铺天盖地", "震耳欲聋", "风驰电掣", "电光火石", "转瞬即逝", "眨眼之间",
+	"神出鬼没", "无影无形", "恍然大悟", "半信半疑", "将信将疑", "犹豫不决", "当机立断", "义无反顾", "破釜沉舟", "背水一战",
+	"势如破竹", "所向披靡", "锐不可当", "摧枯拉朽", "坚不可摧", "固若金汤", "刀光剑影", "血流成河", "尸横遍野", "生死攸关",
+	"九死一生", "死里逃生", "劫后余生", "有惊无险", "化险为夷", "转危为安", "出人意料", "意料之中", "情理之中", "匪夷所思",
+	"沉默不语", "哑口无言", "滔滔不绝", "娓娓道来", "侃侃而谈", "喃喃自语", "轻描淡写", "一笑置之", "似笑非笑", "笑容可掬",
+	"眉开眼笑", "喜出望外", "欣喜若狂", "悲痛欲绝", "泪流满面", "怒不可遏", "火冒三丈", "咬紧牙关", "忍无可忍", "怒发冲冠",
+	"风和日丽", "月明星稀", "夜深人静", "万籁俱寂", "鸟语花香", "山清水秀", "云雾缭绕", "电闪雷鸣", "狂风暴雨", "寒风刺骨",
+}
+
+// StyleMetrics 一段正文的可量化文风指标
+type StyleMetrics struct {
+	Chars          int     `json:"chars"`           // 计入统计的字数
+	Sentences      int     `json:"sentences"`       // 句子数
+	AvgSentence    float64 `json:"avg_sentence"`    // 平均句长
+	MedianSentence int     `json:"median_sentence"` // 句长中位数
+	P90Sentence    int     `json:"p90_sentence"`    // 九成句子不超过的句长
+	ShortRatio     float64 `json:"short_ratio"`     // 短句占比
+	LongRatio      float64 `json:"long_ratio"`      // 长句占比
+	DialogueRatio  float64 `json:"dialogue_ratio"`  // 对话字数占比
+	FirstPerson    float64 `json:"first_person"`    // 叙述中第一人称代词，每千字
+	SecondPerson   float64 `json:"second_person"`   // 叙述中第二人称代词，每千字
+	ThirdPerson    float64 `json:"third_person"`    // 叙述中第三人称代词，每千字
+	IdiomDensity   float64 `json:"idiom_density"`   // 四字格与成语，每千字
+}
+
+// PhraseCount 样本中反复出现的表达
+type PhraseCount struct {
+	Phrase string `json:"phrase"`
+	Count  int    `json:"count"`
+}
+
+// StyleProfile 从样本章节提取的文风画像
+type StyleProfile struct {
+	Chapters []int         `json:"chapters"` // 样本章节
+	Metrics  StyleMetrics  `json:"metrics"`  // 样本整体指标
+	Spread   StyleMetrics  `json:"spread"`   // 各章指标的标准差，用于判断偏离程度
+	POV      string        `json:"pov"`      // first, third
+	Phrases  []PhraseCount `json:"phrases"`  // 常用表达
+	BuiltAt  time.Time     `json:"built_at"`
+}
+
+// StyleDriftItem 单项指标的偏离
+type StyleDriftItem struct {
+	Name      string  `json:"name"`
+	Expected  float64 `json:"expected"`
+	Actual    float64 `json:"actual"`
+	Deviation float64 `json:"deviation"` // 偏离了多少个标准差
+	Percent   bool    `json:"percent"`   // 按百分比显示
+}
+
+// StyleDrift 新章节相对文风画像的偏离报告
+type StyleDrift struct {
+	Chapter      int              `json:"chapter"`
+	Score        float64          `json:"score"` // 0-100，越高越接近画像
+	Items        []StyleDriftItem `json:"items"`
+	POV          string           `json:"pov"`
+	ProfilePOV   string           `json:"profile_pov"`
+	PhrasesUsed  []string         `json:"phrases_used"`
+	PhrasesTotal int              `json:"phrases_total"`
+}
+
+// styleDimension 参与评分的指标：取值函数、最小容差与权重
+type styleDimension struct {
+	name    string
+	value   func(m StyleMetrics) float64
+	floor   float64
+	weight  float64
+	percent bool
+}
+
+var styleDimensions = []styleDimension{
+	{"平均句长", func(m StyleMetrics) float64 { return m.AvgSentence }, 2, 2, false},
+	{"短句占比", func(m StyleMetrics) float64 { return m.ShortRatio }, 0.05, 1, true},
+	{"长句占比", func(m StyleMetrics) float64 { return m.LongRatio }, 0.05, 1, true},
+	{"对话占比", func(m StyleMetrics) float64 { return m.DialogueRatio }, 0.06, 2, true},
+	{"第一人称/千字", func(m StyleMetrics) float64 { return m.FirstPerson }, 3, 1.5, false},
+	{"第三人称/千字", func(m StyleMetrics) float64 { return m.ThirdPerson }, 3, 1, false},
+	{"四字格/千字", func(m StyleMetrics) float64 { return m.IdiomDensity }, 2, 1, false},
+}
+
+// BuildStyleProfile 分析样本章节生成文风画像并保存；chapters 为空时取全部已完成章节，没有已完成章节时取全部有正文的章节
+func (nm *NovelManager) BuildStyleProfile(chapters []int) (*StyleProfile, error) {
+	nm.mutex.RLock()
+	if nm.novelData == nil {
+		nm.mutex.RUnlock()
+		return nil, fmt.Errorf("novel project not initialized")
+	}
+	if len(chapters) == 0 {
+		for _, chapter := range nm.novelData.Chapters {
+			if chapter.Status == "completed" {
+				chapters = append(chapters, chapter.Number)
+			}
+		}
+	}
+	names := sortedCharacterNames(nm.novelData.Characters)
+	nm.mutex.RUnlock()
+
+	if len(chapters) == 0 {
+		numbers, err := nm.listChapterNumbers()
+		if err != nil {
+			return nil, err
+		}
+		chapters = numbers
+	}
+	chapters = uniqueSortedInts(chapters)
+
+	texts := make([]string, 0, len(chapters))
+	sampled := make([]int, 0, len(chapters))
+	for _, number := range chapters {
+		text, err := nm.ReadChapterText(number)
+		if err != nil || strings.TrimSpace(text) == "" {
+			continue
+		}
+		texts = append(texts, text)
+		sampled = append(sampled, number)
+	}
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("no chapter text to analyze")
+	}
+
+	perChapter := make([]StyleMetrics, len(texts))
+	for i, text := range texts {
+		perChapter[i] = AnalyzeStyle(text)
+	}
+	profile := &StyleProfile{
+		Chapters: sampled,
+		Metrics:  AnalyzeStyle(strings.Join(texts, "\n")),
+		Spread:   metricsSpread(perChapter),
+		Phrases:  recurringPhrases(texts, names),
+		BuiltAt:  time.Now(),
+	}
+	profile.POV = profile.Metrics.pov()
+
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	if nm.novelData == nil {
+		return nil, fmt.Errorf("novel project not initialized")
+	}
+	nm.novelData.StyleProfile = profile
+	// 样本明显是第一人称时同步写作风格中的视角，第三人称的细分保留原设置
+	if profile.POV == "first" {
+		nm.novelData.WritingStyle.Perspective = "first"
+	} else if nm.novelData.WritingStyle.Perspective == "first" || nm.novelData.WritingStyle.Perspective == "" {
+		nm.novelData.WritingStyle.Perspective = "third_limited"
+	}
+	return profile, nm.SaveProject()
+}
+
+// StyleProfile 返回已保存的文风画像
+func (nm *NovelManager) StyleProfile() (*StyleProfile, bool) {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	if nm.novelData == nil || nm.novelData.StyleProfile == nil {
+		return nil, false
+	}
+	return nm.novelData.StyleProfile, true
+}
+
+// StyleDriftReport 对照文风画像给某章打分
+func (nm *NovelManager) StyleDriftReport(chapterNum int) (*StyleDrift, error) {
+	text, err := nm.ReadChapterText(chapterNum)
+	if err != nil {
+		return nil, err
+	}
+	return nm.StyleDriftOf(chapterNum, text)
+}
+
+// StyleDriftOf 对照文风画像给一段尚未写入文件的正文打分
+func (nm *NovelManager) StyleDriftOf(chapterNum int, text string) (*StyleDrift, error) {
+	profile, ok := nm.StyleProfile()
+	if !ok {
+		return nil, fmt.Errorf("style profile not built")
+	}
+	return profile.Drift(chapterNum, text), nil
+}
+
+// AnalyzeStyle 计算一段正文的文风指标
+func AnalyzeStyle(text string) StyleMetrics {
+	var metrics StyleMetrics
+	narration, dialogueChars := splitDialogue(text)
+	total := countStyleChars(text)
+	metrics.Chars = total
+	if total == 0 {
+		return metrics
+	}
+	metrics.DialogueRatio = float64(dialogueChars) / float64(total)
+
+	lengths := make([]int, 0)
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		for _, sentence := range sentenceEndPattern.FindAllString(strings.TrimSpace(line), -1) {
+			if n := countStyleChars(sentence); n > 0 {
+				lengths = append(lengths, n)
+			}
+		}
+	}
+	if len(lengths) > 0 {
+		sort.Ints(lengths)
+		sum, short, long := 0, 0, 0
+		for _, n := range lengths {
+			sum += n
+			if n <= shortSentenceRunes {
+				short++
+			}
+			if n > longSentenceRunes {
+				long++
+			}
+		}
+		metrics.Sentences = len(lengths)
+		metrics.AvgSentence = float64(sum) / float64(len(lengths))
+		metrics.MedianSentence = lengths[len(lengths)/2]
+		metrics.P90Sentence = lengths[(len(lengths)*9)/10]
+		metrics.ShortRatio = float64(short) / float64(len(lengths))
+		metrics.LongRatio = float64(long) / float64(len(lengths))
+	}
+
+	if narrationChars := countStyleChars(narration); narrationChars > 0 {
+		first, second, third := 0, 0, 0
+		for _, r := range narration {
+			switch r {
+			case '我', '咱', '俺':
+				first++
+			case '你', '您':
+				second++
+			case '他', '她':
+				third++
+			}
+		}
+		perThousand := 1000 / float64(narrationChars)
+		metrics.FirstPerson = float64(first) * perThousand
+		metrics.SecondPerson = float64(second) * perThousand
+		metrics.ThirdPerson = float64(third) * perThousand
+	}
+
+	metrics.IdiomDensity = float64(countIdioms(text)) * 1000 / float64(total)
+	return metrics
+}
+
+// Drift 计算正文相对画像的偏离
+func (p *StyleProfile) Drift(chapterNum int, text string) *StyleDrift {
+	metrics := AnalyzeStyle(text)
+	drift := &StyleDrift{Chapter: chapterNum, POV: metrics.pov(), ProfilePOV: p.POV, PhrasesTotal: len(p.Phrases)}
+
+	penalty, weights := 0.0, 0.0
+	for _, dim := range styleDimensions {
+		expected, actual := dim.value(p.Metrics), dim.value(metrics)
+		sigma := math.Max(dim.value(p.Spread), dim.floor)
+		deviation := math.Abs(actual-expected) / sigma
+		drift.Items = append(drift.Items, StyleDriftItem{Name: dim.name, Expected: expected, Actual: actual, Deviation: deviation, Percent: dim.percent})
+		penalty += math.Min(deviation, 4) / 4 * dim.weight
+		weights += dim.weight
+	}
+	drift.Score = 100 * (1 - penalty/weights)
+	if drift.POV != drift.ProfilePOV {
+		drift.Score = math.Max(0, drift.Score-20)
+	}
+
+	for _, phrase := range p.Phrases {
+		if strings.Contains(text, phrase.Phrase) {
+			drift.PhrasesUsed = append(drift.PhrasesUsed, phrase.Phrase)
+		}
+	}
+	return drift
+}
+
+// PromptGuide 写入生成提示词的文风要求
+func (p *StyleProfile) PromptGuide() string {
+	m := p.Metrics
+	var guide strings.Builder
+	guide.WriteString(fmt.Sprintf("句长: 平均约%.0f字，中位数%d字，短句（≤%d字）约占%.0f%%，长句（>%d字）约占%.0f%%\n",
+		m.AvgSentence, m.MedianSentence, shortSentenceRunes, m.ShortRatio*100, longSentenceRunes, m.LongRatio*100))
+	guide.WriteString(fmt.Sprintf("对话: 约占全文%.0f%%\n", m.DialogueRatio*100))
+	if p.POV == "first" {
+		guide.WriteString("人称: 第一人称叙述\n")
+	} else {
+		guide.WriteString("人称: 第三人称叙述\n")
+	}
+	guide.WriteString(fmt.Sprintf("四字词语: 每千字约%.0f个\n", m.IdiomDensity))
+	if len(p.Phrases) > 0 {
+		phrases := make([]string, 0, len(p.Phrases))
+		for _, phrase := range p.Phrases {
+			phrases = append(phrases, phrase.Phrase)
+		}
+		guide.WriteString(fmt.Sprintf("惯用表达（可自然使用，不要堆砌）: %s\n", strings.Join(phrases, "、")))
+	}
+	return guide.String()
+}
+
+// Format 格式化文风画像
+func (p *StyleProfile) Format() string {
+	m := p.Metrics
+	var result strings.Builder
+	result.WriteString("🎨 === 文风画像 ===\n\n")
+	result.WriteString(fmt.Sprintf("样本: %d 章（%s），共 %d 字，%d 句\n", len(p.Chapters), compactChapterList(p.Chapters), m.Chars, m.Sentences))
+	result.WriteString(fmt.Sprintf("生成时间: %s\n\n", p.BuiltAt.Format("2006-01-02 15:04")))
+	result.WriteString(fmt.Sprintf("句长: 平均 %.1f ±%.1f 字，中位数 %d，90%% 的句子不超过 %d 字\n", m.AvgSentence, p.Spread.AvgSentence, m.MedianSentence, m.P90Sentence))
+	result.WriteString(fmt.Sprintf("短句占比: %.1f%%  长句占比: %.1f%%\n", m.ShortRatio*100, m.LongRatio*100))
+	result.WriteString(fmt.Sprintf("对话占比: %.1f%% ±%.1f%%\n", m.DialogueRatio*100, p.Spread.DialogueRatio*100))
+	pov := "第三人称"
+	if p.POV == "first" {
+		pov = "第一人称"
+	}
+	result.WriteString(fmt.Sprintf("叙述人称: %s（每千字 我 %.1f / 你 %.1f / 他她 %.1f）\n", pov, m.FirstPerson, m.SecondPerson, m.ThirdPerson))
+	result.WriteString(fmt.Sprintf("四字格密度: 每千字 %.1f 个\n", m.IdiomDensity))
+	if len(p.Phrases) > 0 {
+		result.WriteString("\n常用表达:\n")
+		for _, phrase := range p.Phrases {
+			result.WriteString(fmt.Sprintf("  • %s ×%d\n", phrase.Phrase, phrase.Count))
+		}
+	}
+	return result.String()
+}
+
+// Format 格式化文风偏离报告
+func (d *StyleDrift) Format() string {
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("🎨 === 第%d章 文风偏离 ===\n\n", d.Chapter))
+	result.WriteString(fmt.Sprintf("贴合度: %.0f/100 %s\n\n", d.Score, driftVerdict(d.Score)))
+	result.WriteString("指标           画像      本章      偏离\n")
+	for _, item := range d.Items {
+		icon := "✅"
+		if item.Deviation >= 2 {
+			icon = "❌"
+		} else if item.Deviation >= 1 {
+			icon = "⚠️"
+		}
+		result.WriteString(fmt.Sprintf("%s %-12s %8s  %8s  %.1fσ\n", icon, item.Name, formatStyleValue(item.Expected, item.Percent),
+			formatStyleValue(item.Actual, item.Percent), item.Deviation))
+	}
+	if d.POV != d.ProfilePOV {
+		result.WriteString(fmt.Sprintf("\n❌ 叙述人称不一致: 画像为%s，本章为%s\n", povLabel(d.ProfilePOV), povLabel(d.POV)))
+	}
+	if d.PhrasesTotal > 0 {
+		result.WriteString(fmt.Sprintf("\n惯用表达出现 %d/%d 个", len(d.PhrasesUsed), d.PhrasesTotal))
+		if len(d.PhrasesUsed) > 0 {
+			result.WriteString(": " + strings.Join(d.PhrasesUsed, "、"))
+		}
+		result.WriteString("\n")
+	}
+	return result.String()
+}
+
+func (m StyleMetrics) pov() string {
+	if m.FirstPerson >= firstPersonThreshold {
+		return "first"
+	}
+	return "third"
+}
+
+// splitDialogue 去掉引号内的对话，返回叙述文本和对话字数
+func splitDialogue(text string) (string, int) {
+	var narration strings.Builder
+	dialogue := 0
+	depth := 0
+	straightOpen := false
+	for _, r := range text {
+		switch r {
+		case '「', '『', '“':
+			depth++
+			continue
+		case '」', '』', '”':
+			if depth > 0 {
+				depth--
+			}
+			continue
+		case '"':
+			straightOpen = !straightOpen
+			continue
+		}
+		if depth > 0 || straightOpen {
+			if isStyleChar(r) {
+				dialogue++
+			}
+			continue
+		}
+		narration.WriteRune(r)
+	}
+	return narration.String(), dialogue
+}
+
+// countIdioms 统计以标点断开的四字短句，以及嵌在长句中的常见成语
+func countIdioms(text string) int {
+	count := 0
+	for _, clause := range strings.FieldsFunc(text, func(r rune) bool { return !unicode.Is(unicode.Han, r) }) {
+		runes := []rune(clause)
+		if len(runes) == 4 {
+			count++
+			continue
+		}
+		for _, idiom := range commonIdioms {
+			count += strings.Count(clause, idiom)
+		}
+	}
+	return count
+}
+
+// recurringPhrases 找出样本中反复出现的三到五字表达，排除角色名和被更长表达覆盖的片段
+func recurringPhrases(texts []string, names []string) []PhraseCount {
+	counts := make(map[string]int)
+	// 先把角色名替换成分隔符，避免“动抬起头”这类名字残片
+	pairs := make([]string, 0, len(names)*2)
+	for _, name := range names {
+		pairs = append(pairs, name, " ")
+	}
+	replacer := strings.NewReplacer(pairs...)
+	for _, text := range texts {
+		for _, run := range strings.FieldsFunc(replacer.Replace(text), func(r rune) bool { return !unicode.Is(unicode.Han, r) }) {
+			runes := []rune(run)
+			for n := 3; n <= 5; n++ {
+				for i := 0; i+n <= len(runes); i++ {
+					counts[string(runes[i:i+n])]++
+				}
+			}
+		}
+	}
+
+	candidates := make([]PhraseCount, 0)
+	for phrase, count := range counts {
+		if count < minPhraseCount || isFunctionEdge(phrase) {
+			continue
+		}
+		candidates = append(candidates, PhraseCount{Phrase: phrase, Count: count})
+	}
+	// 长的优先；去掉一个边缘字后几乎总是出现在已选表达中的片段，是同一表达的错位或截断，跳过
+	sort.Slice(candidates, func(i, j int) bool {
+		li, lj := len([]rune(candidates[i].Phrase)), len([]rune(candidates[j].Phrase))
+		if li != lj {
+			return li > lj
+		}
+		if candidates[i].Count != candidates[j].Count {
+			return candidates[i].Count > candidates[j].Count
+		}
+		return candidates[i].Phrase < candidates[j].Phrase
+	})
+	kept := make([]PhraseCount, 0)
+	for _, candidate := range candidates {
+		covered := false
+		runes := []rune(candidate.Phrase)
+		head, tail := string(runes[:len(runes)-1]), string(runes[1:])
+		for _, longer := range kept {
+			if longer.Count*5 < candidate.Count*4 {
+				continue
+			}
+			if strings.Contains(longer.Phrase, head) || strings.Contains(longer.Phrase, tail) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			kept = append(kept, candidate)
+		}
+	}
+
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].Count > kept[j].Count })
+	if len(kept) > maxProfilePhrases {
+		kept = kept[:maxProfilePhrases]
+	}
+	return kept
+}
+
+// isFunctionEdge 以虚词开头或结尾的片段多半不是完整表达
+func isFunctionEdge(phrase string) bool {
+	const edges = "的了着是在和就也都而与及把被对这那之一个"
+	runes := []rune(phrase)
+	return strings.ContainsRune(edges, runes[0]) || strings.ContainsRune(edges, runes[len(runes)-1])
+}
+
+// metricsSpread 各章指标的标准差
+func metricsSpread(samples []StyleMetrics) StyleMetrics {
+	if len(samples) < 2 {
+		return StyleMetrics{}
+	}
+	std := func(value func(m StyleMetrics) float64) float64 {
+		mean := 0.0
+		for _, m := range samples {
+			mean += value(m)
+		}
+		mean /= float64(len(samples))
+		variance := 0.0
+		for _, m := range samples {
+			variance += (value(m) - mean) * (value(m) - mean)
+		}
+		return math.Sqrt(variance / float64(len(samples)-1))
+	}
+	return StyleMetrics{
+		AvgSentence:   std(func(m StyleMetrics) float64 { return m.AvgSentence }),
+		ShortRatio:    std(func(m StyleMetrics) float64 { return m.ShortRatio }),
+		LongRatio:     std(func(m StyleMetrics) float64 { return m.LongRatio }),
+		DialogueRatio: std(func(m StyleMetrics) float64 { return m.DialogueRatio }),
+		FirstPerson:   std(func(m StyleMetrics) float64 { return m.FirstPerson }),
+		SecondPerson:  std(func(m StyleMetrics) float64 { return m.SecondPerson }),
+		ThirdPerson:   std(func(m StyleMetrics) float64 { return m.ThirdPerson }),
+		IdiomDensity:  std(func(m StyleMetrics) float64 { return m.IdiomDensity }),
+	}
+}
+
+func countStyleChars(text string) int {
+	count := 0
+	for _, r := range text {
+		if isStyleChar(r) {
+			count++
+		}
+	}
+	return count
+}
+
+func isStyleChar(r rune) bool {
+	return isCJK(r) || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// compactChapterList 把连续章节号压缩为区间，如 1-5, 8
+func compactChapterList(chapters []int) string {
+	parts := make([]string, 0)
+	for i := 0; i < len(chapters); {
+		j := i
+		for j+1 < len(chapters) && chapters[j+1] == chapters[j]+1 {
+			j++
+		}
+		if j > i {
+			parts = append(parts, fmt.Sprintf("%d-%d", chapters[i], chapters[j]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d", chapters[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatStyleValue(value float64, percent bool) string {
+	if percent {
+		return fmt.Sprintf("%.1f%%", value*100)
+	}
+	return fmt.Sprintf("%.1f", value)
+}
+
+func driftVerdict(score float64) string {
+	switch {
+	case score >= 80:
+		return "✅ 与既有文风一致"
+	case score >= 60:
+		return "⚠️ 略有偏离"
+	}
+	return "❌ 明显偏离既有文风"
+}
+
+func povLabel(pov string) string {
+	if pov == "first" {
+		return "第一人称"
+	}
+	return "第三人称"
+}
@@ -0,0 +1,146 @@
+package novel
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeStyle(t *testing.T) {
+	metrics := AnalyzeStyle("林风推开门。\n“你来晚了。”老人说。")
+	if metrics.Chars != 12 || metrics.Sentences != 3 {
+		t.Fatalf("Chars = %d, Sentences = %d, want 12 and 3", metrics.Chars, metrics.Sentences)
+	}
+	if metrics.AvgSentence != 4 || metrics.MedianSentence != 4 || metrics.ShortRatio != 1 || metrics.LongRatio != 0 {
+		t.Errorf("sentence metrics = %+v", metrics)
+	}
+	if math.Abs(metrics.DialogueRatio-4.0/12) > 1e-9 {
+		t.Errorf("DialogueRatio = %v, want 1/3", metrics.DialogueRatio)
+	}
+	// 对话中的“你”不计入叙述人称
+	if metrics.SecondPerson != 0 || metrics.pov() != "third" {
+		t.Errorf("SecondPerson = %v, pov = %s", metrics.SecondPerson, metrics.pov())
+	}
+
+	first := AnalyzeStyle("我推开门。我看见他站在院子里。")
+	if first.pov() != "first" || first.FirstPerson <= first.ThirdPerson {
+		t.Errorf("first person metrics = %+v", first)
+	}
+	if empty := AnalyzeStyle("……\n"); empty != (StyleMetrics{}) {
+		t.Errorf("AnalyzeStyle(punctuation only) = %+v", empty)
+	}
+}
+
+func TestSplitDialogue(t *testing.T) {
+	tests := []struct {
+		name          string
+		text          string
+		wantNarration string
+		wantDialogue  int
+	}{
+		{name: "中文引号", text: "他说：“走吧。”", wantNarration: "他说：", wantDialogue: 2},
+		{name: "嵌套引号", text: "「他说『走』了」她笑", wantNarration: "她笑", wantDialogue: 4},
+		{name: "直引号成对出现", text: `他说:"go now"然后走了`, wantNarration: "他说:然后走了", wantDialogue: 5},
+		{name: "多余的右引号", text: "”他走了", wantNarration: "他走了", wantDialogue: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			narration, dialogue := splitDialogue(tt.text)
+			if narration != tt.wantNarration || dialogue != tt.wantDialogue {
+				t.Errorf("splitDialogue() = %q, %d, want %q, %d", narration, dialogue, tt.wantNarration, tt.wantDialogue)
+			}
+		})
+	}
+}
+
+func TestCountIdioms(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{text: "他不由自主地后退，鸦雀无声。", want: 2},
+		{text: "风起云涌，电闪雷鸣。", want: 2},
+		{text: "他走进院子，看见一棵老树。", want: 0},
+		{text: "四下里鸦雀无声鸦雀无声", want: 2},
+	}
+	for _, tt := range tests {
+		if got := countIdioms(tt.text); got != tt.want {
+			t.Errorf("countIdioms(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestRecurringPhrases(t *testing.T) {
+	texts := []string{
+		"林风握紧长剑。林风握紧长剑。",
+		"林风握紧长剑，苏雨的眼睛。",
+		"苏雨的眼睛，苏雨的眼睛。",
+	}
+	got := recurringPhrases(texts, []string{"林风", "苏雨"})
+	// 角色名不会并入表达，被“握紧长剑”覆盖的三字片段和以虚词开头的片段都被去掉
+	want := []PhraseCount{{Phrase: "握紧长剑", Count: 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("recurringPhrases() = %v, want %v", got, want)
+	}
+}
+
+func TestCompactChapterList(t *testing.T) {
+	tests := []struct {
+		chapters []int
+		want     string
+	}{
+		{chapters: []int{1, 2, 3, 5, 7, 8}, want: "1-3, 5, 7-8"},
+		{chapters: []int{4}, want: "4"},
+		{chapters: nil, want: ""},
+	}
+	for _, tt := range tests {
+		if got := compactChapterList(tt.chapters); got != tt.want {
+			t.Errorf("compactChapterList(%v) = %q, want %q", tt.chapters, got, tt.want)
+		}
+	}
+}
+
+func TestBuildStyleProfileAndDrift(t *testing.T) {
+	nm := newTestManager(t)
+	if _, err := nm.StyleDriftOf(1, "正文"); err == nil {
+		t.Error("drift scored without a profile")
+	}
+
+	first := "我推开院门，院子里空无一人。\n我握紧长剑，慢慢走向正屋。\n“有人吗？”我问。\n"
+	writeTestChapter(t, nm, 1, strings.Repeat(first, 3))
+	writeTestChapter(t, nm, 2, strings.Repeat(first, 2))
+	writeTestChapter(t, nm, 3, "   \n")
+
+	profile, err := nm.BuildStyleProfile(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 没有已完成章节时取全部有正文的章节，空白章节不计入
+	if !reflect.DeepEqual(profile.Chapters, []int{1, 2}) || profile.POV != "first" {
+		t.Errorf("profile chapters = %v, pov = %s", profile.Chapters, profile.POV)
+	}
+	if nm.novelData.WritingStyle.Perspective != "first" {
+		t.Errorf("Perspective = %q, want first", nm.novelData.WritingStyle.Perspective)
+	}
+
+	same, err := nm.StyleDriftOf(4, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same.Score < 99 || same.POV != "first" {
+		t.Errorf("same style drift = %.1f (%s)", same.Score, same.POV)
+	}
+	if !containsString(same.PhrasesUsed, "我握紧长剑") || len(same.PhrasesUsed) != same.PhrasesTotal {
+		t.Errorf("PhrasesUsed = %v", same.PhrasesUsed)
+	}
+
+	third := "他推开院门，院子里空无一人，他看见她正坐在台阶上，低头擦拭着一柄旧剑，剑身映出灰白的天光。\n她没有抬头。\n"
+	drift, err := nm.StyleDriftOf(4, third)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if drift.POV != "third" || drift.ProfilePOV != "first" || drift.Score > same.Score-20 {
+		t.Errorf("third person drift = %.1f (%s vs %s)", drift.Score, drift.POV, drift.ProfilePOV)
+	}
+}
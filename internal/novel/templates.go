@@ -0,0 +1,52 @@
+package novel
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// 内置的节拍模板和题材模板，用户可在配置目录的同名子目录中添加或覆盖
+//
+//go:embed templates
+var builtinTemplates embed.FS
+
+// templateFile 一个待解析的 YAML 模板文件
+type templateFile struct {
+	name   string // 文件名
+	source string // 内置模板为 "builtin"，否则为文件路径
+	data   []byte
+}
+
+// readTemplateFiles 读取内置目录 builtinDir 和用户目录 userDir 中的 YAML 文件，内置模板在前；
+// 用户目录中无法读取的文件跳过并在 warnings 中说明
+func readTemplateFiles(builtinDir, userDir string) ([]templateFile, []string) {
+	files := make([]templateFile, 0)
+	warnings := make([]string, 0)
+
+	entries, _ := builtinTemplates.ReadDir(builtinDir)
+	for _, entry := range entries {
+		data, err := builtinTemplates.ReadFile(path.Join(builtinDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		files = append(files, templateFile{name: entry.Name(), source: "builtin", data: data})
+	}
+
+	if userDir == "" {
+		return files, warnings
+	}
+	paths, _ := filepath.Glob(filepath.Join(userDir, "*.yaml"))
+	more, _ := filepath.Glob(filepath.Join(userDir, "*.yml"))
+	for _, p := range append(paths, more...) {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: %v", p, err))
+			continue
+		}
+		files = append(files, templateFile{name: filepath.Base(p), source: p, data: data})
+	}
+	return files, warnings
+}
@@ -0,0 +1,477 @@
+package novel
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultDaysPerYear   = 360
+	defaultMonthsPerYear = 12
+)
+
+var (
+	storyDatePattern     = regexp.MustCompile(`^(.*?)(-?[0-9０-９]+|[零〇一二两三四五六七八九十百千万]+|元)年(?:([0-9０-９]+|[一二三四五六七八九十正冬腊]+)月(?:([0-9０-９]+|[一二三四五六七八九十廿卅初]+)[日号]?)?)?$`)
+	storyDateDashPattern = regexp.MustCompile(`^(-?\d+)[-/.](\d+)(?:[-/.](\d+))?$`)
+	storyDayPattern      = regexp.MustCompile(`^(?:第|day\s*)(-?\d+)(?:天|日)?$`)
+	durationPattern      = regexp.MustCompile(`([0-9０-９]+|[零一二两三四五六七八九十百千万半]+)\s*(年|个月|月|旬|天|日)(半)?`)
+)
+
+// Calendar 故事内历法，日期以自纪元起的天数表示（纪元元年一月一日为第0天）
+type Calendar struct {
+	Era           string `json:"era"`             // 纪年名，如 "天元"
+	DaysPerYear   int    `json:"days_per_year"`   // 每年天数，默认360
+	MonthsPerYear int    `json:"months_per_year"` // 每年月数，0 表示不分月
+}
+
+// Timeline 故事时间线
+type Timeline struct {
+	Calendar Calendar         `json:"calendar"`
+	Events   []*TimelineEvent `json:"events"`
+}
+
+// TimelineEvent 故事内事件
+type TimelineEvent struct {
+	ID           string   `json:"id"`
+	Title        string   `json:"title"`
+	Description  string   `json:"description,omitempty"`
+	Day          int      `json:"day"`                     // 开始日（自纪元起的天数）
+	DurationDays int      `json:"duration_days,omitempty"` // 持续天数，0 表示当天
+	Chapter      int      `json:"chapter,omitempty"`       // 叙述该事件的章节
+	Participants []string `json:"participants,omitempty"`
+}
+
+// End 事件结束日
+func (e *TimelineEvent) End() int {
+	if e.DurationDays > 1 {
+		return e.Day + e.DurationDays - 1
+	}
+	return e.Day
+}
+
+// normalized 补齐未设置的历法参数
+func (c Calendar) normalized() Calendar {
+	if c.DaysPerYear <= 0 {
+		c.DaysPerYear = defaultDaysPerYear
+	}
+	if c.MonthsPerYear < 0 || c.MonthsPerYear > c.DaysPerYear {
+		c.MonthsPerYear = 0
+	}
+	return c
+}
+
+func (c Calendar) daysPerMonth() int {
+	if c.MonthsPerYear <= 0 {
+		return 0
+	}
+	return c.DaysPerYear / c.MonthsPerYear
+}
+
+// FormatDay 将天数格式化为故事内日期，如“天元123年3月5日”
+func (c Calendar) FormatDay(day int) string {
+	c = c.normalized()
+	year := floorDiv(day, c.DaysPerYear) + 1
+	dayOfYear := day - (year-1)*c.DaysPerYear
+
+	if dpm := c.daysPerMonth(); dpm > 0 {
+		month := dayOfYear/dpm + 1
+		if month > c.MonthsPerYear {
+			month = c.MonthsPerYear
+		}
+		return fmt.Sprintf("%s%d年%d月%d日", c.Era, year, month, dayOfYear-(month-1)*dpm+1)
+	}
+	return fmt.Sprintf("%s%d年第%d日", c.Era, year, dayOfYear+1)
+}
+
+// ParseDate 解析故事内日期：“天元123年3月5日”“123年”“123-3-5”“第45000天”
+func (c Calendar) ParseDate(text string) (int, error) {
+	c = c.normalized()
+	text = strings.TrimSpace(strings.ReplaceAll(text, " ", ""))
+	if text == "" {
+		return 0, fmt.Errorf("empty story date")
+	}
+
+	if match := storyDayPattern.FindStringSubmatch(strings.ToLower(text)); match != nil {
+		// 第1天即纪元元年的第一天
+		var day int
+		fmt.Sscanf(match[1], "%d", &day)
+		return day - 1, nil
+	}
+
+	var yearText, monthText, dayText string
+	if match := storyDateDashPattern.FindStringSubmatch(text); match != nil {
+		yearText, monthText, dayText = match[1], match[2], match[3]
+	} else if match := storyDatePattern.FindStringSubmatch(text); match != nil {
+		if era := match[1]; era != "" && era != c.Era {
+			return 0, fmt.Errorf("unknown era %q (calendar era is %q)", era, c.Era)
+		}
+		yearText, monthText, dayText = match[2], match[3], match[4]
+	} else {
+		return 0, fmt.Errorf("unrecognized story date %q", text)
+	}
+
+	year, ok := parseStoryNumber(yearText)
+	if !ok {
+		return 0, fmt.Errorf("invalid year in %q", text)
+	}
+	day := (year - 1) * c.DaysPerYear
+
+	if monthText != "" {
+		dpm := c.daysPerMonth()
+		month, ok := parseStoryNumber(monthText)
+		if dpm == 0 || !ok || month < 1 || month > c.MonthsPerYear {
+			return 0, fmt.Errorf("invalid month in %q", text)
+		}
+		day += (month - 1) * dpm
+	}
+	if dayText != "" {
+		d, ok := parseStoryNumber(dayText)
+		if !ok || d < 1 {
+			return 0, fmt.Errorf("invalid day in %q", text)
+		}
+		day += d - 1
+	}
+	return day, nil
+}
+
+// ParseDuration 解析时长，如“3天”“两个月”“十年”“半年”，纯数字按天计
+func (c Calendar) ParseDuration(text string) (int, error) {
+	c = c.normalized()
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0, nil
+	}
+	if days, ok := parseStoryNumber(text); ok {
+		return days, nil
+	}
+
+	matches := durationPattern.FindAllStringSubmatch(text, -1)
+	if len(matches) == 0 {
+		return 0, fmt.Errorf("unrecognized duration %q", text)
+	}
+	monthDays := c.daysPerMonth()
+	if monthDays == 0 {
+		monthDays = c.DaysPerYear / defaultMonthsPerYear
+	}
+
+	total := 0
+	for _, match := range matches {
+		var unit int
+		switch match[2] {
+		case "年":
+			unit = c.DaysPerYear
+		case "个月", "月":
+			unit = monthDays
+		case "旬":
+			unit = 10
+		default:
+			unit = 1
+		}
+		if match[1] == "半" {
+			total += unit / 2
+			continue
+		}
+		n, ok := parseStoryNumber(match[1])
+		if !ok {
+			return 0, fmt.Errorf("unrecognized duration %q", text)
+		}
+		total += n * unit
+		if match[3] != "" {
+			total += unit / 2
+		}
+	}
+	return total, nil
+}
+
+// FormatDuration 格式化时长
+func (c Calendar) FormatDuration(days int) string {
+	c = c.normalized()
+	if days >= c.DaysPerYear && days%c.DaysPerYear == 0 {
+		return fmt.Sprintf("%d年", days/c.DaysPerYear)
+	}
+	if dpm := c.daysPerMonth(); dpm > 0 && days >= dpm && days%dpm == 0 {
+		return fmt.Sprintf("%d个月", days/dpm)
+	}
+	return fmt.Sprintf("%d天", days)
+}
+
+// ChapterDay 章节所处的故事时间：取该章（或之前最近一个有事件的章节）最晚的事件日期
+func (t *Timeline) ChapterDay(chapterNum int) (int, bool) {
+	best, bestChapter, found := 0, 0, false
+	for _, event := range t.Events {
+		if event.Chapter <= 0 || event.Chapter > chapterNum {
+			continue
+		}
+		if !found || event.Chapter > bestChapter || (event.Chapter == bestChapter && event.End() > best) {
+			best, bestChapter, found = event.End(), event.Chapter, true
+		}
+	}
+	return best, found
+}
+
+// AgeAt 推算角色在某章的年龄：优先使用出生日期，否则以设定年龄对应首次登场章节推算
+func (t *Timeline) AgeAt(char *Character, chapterNum int) (int, bool) {
+	calendar := t.Calendar.normalized()
+	day, ok := t.ChapterDay(chapterNum)
+	if !ok {
+		return 0, false
+	}
+
+	var birth int
+	switch {
+	case char.Birth != nil:
+		birth = *char.Birth
+	case char.Age > 0:
+		ref := char.FirstAppeared
+		if ref <= 0 {
+			ref = 1
+		}
+		refDay, ok := t.ChapterDay(ref)
+		if !ok {
+			return 0, false
+		}
+		birth = refDay - char.Age*calendar.DaysPerYear
+	default:
+		return 0, false
+	}
+	return floorDiv(day-birth, calendar.DaysPerYear), true
+}
+
+// Sorted 按故事时间排序的事件副本
+func (t *Timeline) Sorted() []*TimelineEvent {
+	events := make([]*TimelineEvent, len(t.Events))
+	copy(events, t.Events)
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Day != events[j].Day {
+			return events[i].Day < events[j].Day
+		}
+		return events[i].Chapter < events[j].Chapter
+	})
+	return events
+}
+
+// FormatText 文本形式的时间线
+func (t *Timeline) FormatText(events []*TimelineEvent) string {
+	if len(events) == 0 {
+		return "（时间线暂无事件）\n"
+	}
+	var result strings.Builder
+	for _, event := range events {
+		result.WriteString(fmt.Sprintf("• %s  %s", t.Calendar.FormatDay(event.Day), event.Title))
+		if event.DurationDays > 1 {
+			result.WriteString(fmt.Sprintf("（持续%s，至%s）", t.Calendar.FormatDuration(event.DurationDays), t.Calendar.FormatDay(event.End())))
+		}
+		if event.Chapter > 0 {
+			result.WriteString(fmt.Sprintf(" [第%d章]", event.Chapter))
+		}
+		result.WriteString("\n")
+		if len(event.Participants) > 0 {
+			result.WriteString(fmt.Sprintf("    参与: %s\n", strings.Join(event.Participants, "、")))
+		}
+		if event.Description != "" {
+			result.WriteString(fmt.Sprintf("    %s\n", event.Description))
+		}
+	}
+	return result.String()
+}
+
+// FormatMermaid 生成 Mermaid timeline 图，按年分段
+func (t *Timeline) FormatMermaid(events []*TimelineEvent, title string) string {
+	calendar := t.Calendar.normalized()
+	var result strings.Builder
+	result.WriteString("timeline\n")
+	if title != "" {
+		result.WriteString(fmt.Sprintf("    title %s\n", mermaidText(title)))
+	}
+
+	section, period := "", ""
+	for _, event := range events {
+		year := floorDiv(event.Day, calendar.DaysPerYear) + 1
+		if name := fmt.Sprintf("%s%d年", calendar.Era, year); name != section {
+			section, period = name, ""
+			result.WriteString(fmt.Sprintf("    section %s\n", section))
+		}
+		date := strings.TrimPrefix(calendar.FormatDay(event.Day), section)
+		label := mermaidText(event.Title)
+		if event.Chapter > 0 {
+			label += fmt.Sprintf("（第%d章）", event.Chapter)
+		}
+		if date == period {
+			result.WriteString(fmt.Sprintf("        : %s\n", label))
+			continue
+		}
+		period = date
+		result.WriteString(fmt.Sprintf("        %s : %s\n", date, label))
+	}
+	return result.String()
+}
+
+// SetCalendar 设置故事历法
+func (nm *NovelManager) SetCalendar(calendar Calendar) error {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	if nm.novelData == nil {
+		return fmt.Errorf("novel project not initialized")
+	}
+	timeline := nm.timeline()
+	timeline.Calendar = calendar.normalized()
+	return nm.SaveProject()
+}
+
+// GetTimeline 返回时间线副本
+func (nm *NovelManager) GetTimeline() (*Timeline, error) {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	if nm.novelData == nil {
+		return nil, fmt.Errorf("novel project not initialized")
+	}
+	return copyTimeline(nm.novelData.Timeline), nil
+}
+
+// AddTimelineEvent 在时间线上放置事件，返回事件ID
+func (nm *NovelManager) AddTimelineEvent(event *TimelineEvent) (string, error) {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	if nm.novelData == nil {
+		return "", fmt.Errorf("novel project not initialized")
+	}
+	event.Title = strings.TrimSpace(event.Title)
+	if event.Title == "" {
+		return "", fmt.Errorf("event title is required")
+	}
+	if event.DurationDays < 0 {
+		return "", fmt.Errorf("event duration cannot be negative")
+	}
+
+	timeline := nm.timeline()
+	maxID := 0
+	for _, existing := range timeline.Events {
+		var n int
+		if _, err := fmt.Sscanf(existing.ID, "evt_%d", &n); err == nil && n > maxID {
+			maxID = n
+		}
+	}
+	event.ID = fmt.Sprintf("evt_%d", maxID+1)
+	timeline.Events = append(timeline.Events, event)
+
+	return event.ID, nm.SaveProject()
+}
+
+// ResolveStoryTime 将事件ID、事件标题或日期解析为故事内天数
+func (t *Timeline) ResolveStoryTime(ref string) (int, *TimelineEvent, error) {
+	ref = strings.TrimSpace(ref)
+	for _, event := range t.Events {
+		if event.ID == ref || event.Title == ref {
+			return event.Day, event, nil
+		}
+	}
+	var partial *TimelineEvent
+	for _, event := range t.Events {
+		if strings.Contains(event.Title, ref) {
+			if partial != nil {
+				return 0, nil, fmt.Errorf("%q matches multiple events, use the event ID", ref)
+			}
+			partial = event
+		}
+	}
+	if partial != nil {
+		return partial.Day, partial, nil
+	}
+	day, err := t.Calendar.ParseDate(ref)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%q is neither an event nor a story date: %w", ref, err)
+	}
+	return day, nil, nil
+}
+
+// EventsBetween 返回在 [from, to) 之间开始的事件；participant 非空时只保留该角色参与的事件
+func (t *Timeline) EventsBetween(from, to *int, participant string) []*TimelineEvent {
+	result := make([]*TimelineEvent, 0)
+	for _, event := range t.Sorted() {
+		if from != nil && event.Day < *from {
+			continue
+		}
+		if to != nil && event.Day >= *to {
+			continue
+		}
+		if participant != "" && !containsString(event.Participants, participant) {
+			continue
+		}
+		result = append(result, event)
+	}
+	return result
+}
+
+// timeline 获取（必要时创建）时间线，调用方需持有写锁
+func (nm *NovelManager) timeline() *Timeline {
+	if nm.novelData.Timeline == nil {
+		nm.novelData.Timeline = &Timeline{
+			Calendar: Calendar{DaysPerYear: defaultDaysPerYear, MonthsPerYear: defaultMonthsPerYear},
+			Events:   make([]*TimelineEvent, 0),
+		}
+	}
+	return nm.novelData.Timeline
+}
+
+func copyTimeline(src *Timeline) *Timeline {
+	if src == nil {
+		return &Timeline{
+			Calendar: Calendar{DaysPerYear: defaultDaysPerYear, MonthsPerYear: defaultMonthsPerYear},
+			Events:   make([]*TimelineEvent, 0),
+		}
+	}
+	copied := &Timeline{Calendar: src.Calendar, Events: make([]*TimelineEvent, len(src.Events))}
+	for i, event := range src.Events {
+		e := *event
+		e.Participants = append([]string(nil), event.Participants...)
+		copied.Events[i] = &e
+	}
+	return copied
+}
+
+// parseStoryNumber 解析年月日中的数字，支持“元年”“正月”“初五”“廿三”
+func parseStoryNumber(s string) (int, bool) {
+	switch s {
+	case "元", "正":
+		return 1, true
+	case "冬":
+		return 11, true
+	case "腊":
+		return 12, true
+	}
+	s = strings.TrimPrefix(s, "初")
+	s = strings.Replace(s, "廿", "二十", 1)
+	s = strings.Replace(s, "卅", "三十", 1)
+	if strings.HasPrefix(s, "-") {
+		n, ok := parseChineseNumber(s[1:])
+		return -n, ok
+	}
+	return parseChineseNumber(s)
+}
+
+// floorDiv 向下取整的整数除法，纪元前的日期也能得到正确的年份
+func floorDiv(a, b int) int {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
+
+func mermaidText(s string) string {
+	return strings.NewReplacer(":", "：", "\n", " ", "#", "＃", ";", "；").Replace(s)
+}
+
+func containsString(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
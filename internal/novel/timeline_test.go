@@ -0,0 +1,145 @@
+package novel
+
+import "testing"
+
+func TestCalendarParseDate(t *testing.T) {
+	calendar := Calendar{Era: "天元", DaysPerYear: 360, MonthsPerYear: 12}
+	tests := []struct {
+		text    string
+		want    int
+		wantErr bool
+	}{
+		{text: "天元123年3月5日", want: 122*360 + 2*30 + 4},
+		{text: "123年", want: 122 * 360},
+		{text: "123-3-5", want: 122*360 + 2*30 + 4},
+		{text: "第45000天", want: 44999},
+		{text: "元年正月初五", want: 4},
+		{text: "二年腊月廿三", want: 360 + 11*30 + 22},
+		{text: "-1年", want: -2 * 360},
+		{text: "太初5年", wantErr: true},
+		{text: "5年13月", wantErr: true},
+		{text: "明天", wantErr: true},
+		{text: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := calendar.ParseDate(tt.text)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseDate(%q) error = %v, wantErr %v", tt.text, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseDate(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCalendarFormatDay(t *testing.T) {
+	tests := []struct {
+		calendar Calendar
+		day      int
+		want     string
+	}{
+		{Calendar{Era: "天元", DaysPerYear: 360, MonthsPerYear: 12}, 122*360 + 2*30 + 4, "天元123年3月5日"},
+		{Calendar{Era: "天元", DaysPerYear: 360, MonthsPerYear: 12}, 0, "天元1年1月1日"},
+		{Calendar{DaysPerYear: 360, MonthsPerYear: 12}, -720, "-1年1月1日"},
+		{Calendar{DaysPerYear: 100}, 105, "2年第6日"},
+		{Calendar{}, 359, "1年第360日"},
+	}
+	for _, tt := range tests {
+		if got := tt.calendar.FormatDay(tt.day); got != tt.want {
+			t.Errorf("%+v.FormatDay(%d) = %q, want %q", tt.calendar, tt.day, got, tt.want)
+		}
+	}
+}
+
+func TestCalendarDuration(t *testing.T) {
+	calendar := Calendar{DaysPerYear: 360, MonthsPerYear: 12}
+	tests := []struct {
+		text    string
+		want    int
+		wantErr bool
+	}{
+		{text: "3天", want: 3},
+		{text: "两个月", want: 60},
+		{text: "十年", want: 3600},
+		{text: "半年", want: 180},
+		{text: "一年半", want: 540},
+		{text: "1旬", want: 10},
+		{text: "7", want: 7},
+		{text: "", want: 0},
+		{text: "很久", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := calendar.ParseDuration(tt.text)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseDuration(%q) error = %v, wantErr %v", tt.text, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseDuration(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+
+	formats := []struct {
+		days int
+		want string
+	}{
+		{720, "2年"},
+		{60, "2个月"},
+		{45, "45天"},
+		{0, "0天"},
+	}
+	for _, tt := range formats {
+		if got := calendar.FormatDuration(tt.days); got != tt.want {
+			t.Errorf("FormatDuration(%d) = %q, want %q", tt.days, got, tt.want)
+		}
+	}
+}
+
+func TestTimelineAgeAt(t *testing.T) {
+	timeline := &Timeline{
+		Calendar: Calendar{DaysPerYear: 360, MonthsPerYear: 12},
+		Events: []*TimelineEvent{
+			{ID: "e1", Day: 1000, Chapter: 1},
+			{ID: "e2", Day: 1000 + 5*360, DurationDays: 30, Chapter: 10},
+		},
+	}
+	birth := 0
+	tests := []struct {
+		name    string
+		char    *Character
+		chapter int
+		want    int
+		wantOK  bool
+	}{
+		{"首次登场时为设定年龄", &Character{Age: 16, FirstAppeared: 1}, 1, 16, true},
+		{"按事件推算后来的年龄", &Character{Age: 16, FirstAppeared: 1}, 10, 21, true},
+		{"没有事件的章节沿用之前最近的章节", &Character{Age: 16, FirstAppeared: 1}, 5, 16, true},
+		{"出生日期优先", &Character{Age: 16, Birth: &birth}, 10, (1000 + 5*360 + 29) / 360, true},
+		{"章节之前没有事件", &Character{Age: 16}, 0, 0, false},
+		{"没有年龄和出生日期", &Character{}, 10, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := timeline.AgeAt(tt.char, tt.chapter)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("AgeAt(chapter %d) = %d, %v, want %d, %v", tt.chapter, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestFloorDiv(t *testing.T) {
+	tests := []struct{ a, b, want int }{
+		{7, 360, 0},
+		{360, 360, 1},
+		{-1, 360, -1},
+		{-360, 360, -1},
+		{-361, 360, -2},
+	}
+	for _, tt := range tests {
+		if got := floorDiv(tt.a, tt.b); got != tt.want {
+			t.Errorf("floorDiv(%d, %d) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package novel
+
+import (
+	"unicode"
+)
+
+// WordStats 文本字数统计
+//
+// 中文按字计数：每个汉字（含日文假名、韩文音节）算一个字，
+// 连续的拉丁字母算一个词，连续的数字算一个词，标点单独统计、不计入字数。
+type WordStats struct {
+	Hanzi       int `json:"hanzi"`
+	LatinWords  int `json:"latin_words"`
+	Numbers     int `json:"numbers"`
+	Punctuation int `json:"punctuation"`
+}
+
+// Words 计入字数的总数（不含标点）
+func (s WordStats) Words() int {
+	return s.Hanzi + s.LatinWords + s.Numbers
+}
+
+// CountText 统计文本的汉字、拉丁单词、数字与标点
+func CountText(text string) WordStats {
+	var stats WordStats
+	inLatin, inNumber := false, false
+
+	runes := []rune(text)
+	for i, r := range runes {
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+
+		switch {
+		case isCJK(r):
+			stats.Hanzi++
+			inLatin, inNumber = false, false
+		case unicode.IsLetter(r):
+			if !inLatin {
+				stats.LatinWords++
+				inLatin = true
+			}
+			inNumber = false
+		case unicode.IsDigit(r):
+			// 数字紧跟字母时视为同一个词，如 “MP5”
+			if !inNumber && !inLatin {
+				stats.Numbers++
+				inNumber = true
+			}
+		case (r == '\'' || r == '-' || r == '’') && inLatin && unicode.IsLetter(next) && !isCJK(next):
+			// don't、well-known 视为一个词
+		case (r == '.' || r == ',') && inNumber && unicode.IsDigit(next):
+			// 3.14、1,000 视为一个数字
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			stats.Punctuation++
+			inLatin, inNumber = false, false
+		default:
+			inLatin, inNumber = false, false
+		}
+	}
+
+	return stats
+}
+
+// CountWords 返回计入字数的总数
+func CountWords(text string) int {
+	return CountText(text).Words()
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
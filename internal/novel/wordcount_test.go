@@ -0,0 +1,44 @@
+package novel
+
+import "testing"
+
+func TestCountText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want WordStats
+	}{
+		{"空文本", "", WordStats{}},
+		{"汉字与标点", "你好，世界。", WordStats{Hanzi: 4, Punctuation: 2}},
+		{"英文单词", "Hello world", WordStats{LatinWords: 2}},
+		{"撇号和连字符不拆词", "don't stop, well-known", WordStats{LatinWords: 3, Punctuation: 1}},
+		{"小数和千分位算一个数字", "3.14 和 1,000", WordStats{Hanzi: 1, Numbers: 2}},
+		{"字母后的数字并入单词", "MP5冲锋枪", WordStats{Hanzi: 3, LatinWords: 1}},
+		{"假名和谚文按字计数", "こんにちは한국어", WordStats{Hanzi: 8}},
+		{"中英混排", "他说：“OK！”", WordStats{Hanzi: 2, LatinWords: 1, Punctuation: 4}},
+		{"空白不计数", " \t第一章\n\n", WordStats{Hanzi: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CountText(tt.text); got != tt.want {
+				t.Errorf("CountText(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountWords(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"他拔出MP5，打了3.5秒。", 8},
+		{"The quick brown fox", 4},
+	}
+	for _, tt := range tests {
+		if got := CountWords(tt.text); got != tt.want {
+			t.Errorf("CountWords(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
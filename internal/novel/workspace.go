@@ -0,0 +1,283 @@
+package novel
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WorkspaceFile 工作区根目录下记录上次打开的小说
+const WorkspaceFile = "novel_workspace.json"
+
+// RootProjectName 工作区根目录本身就是小说项目时使用的名称
+const RootProjectName = "."
+
+// Workspace 包含多个小说项目的目录，每个直接子目录是一部小说；根目录本身也可以是一部小说
+type Workspace struct {
+	Root string
+}
+
+// workspaceState novel_workspace.json
+type workspaceState struct {
+	Active string `json:"active"`
+}
+
+// ProjectSummary 小说项目概况
+type ProjectSummary struct {
+	Title          string
+	Author         string
+	Genre          string
+	Chapters       int
+	Words          int
+	TargetWords    int
+	CurrentChapter int
+	LastModified   time.Time
+}
+
+// WorkspaceProject 工作区中的一部小说
+type WorkspaceProject struct {
+	Name string // 子目录名，根目录为 "."
+	Path string
+	ProjectSummary
+	Err error // 项目文件无法读取时的错误
+}
+
+// NewWorkspace 创建工作区，相对路径转为绝对路径，使列出的项目路径能与已打开项目的路径直接比较
+func NewWorkspace(root string) *Workspace {
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	return &Workspace{Root: root}
+}
+
+// Projects 列出工作区中的小说，根目录排在最前，其余按最近修改时间排序
+func (w *Workspace) Projects() ([]*WorkspaceProject, error) {
+	projects := make([]*WorkspaceProject, 0)
+	if isProjectDir(w.Root) {
+		projects = append(projects, readWorkspaceProject(RootProjectName, w.Root))
+	}
+
+	entries, err := os.ReadDir(w.Root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workspace: %w", err)
+	}
+	children := make([]*WorkspaceProject, 0)
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(w.Root, entry.Name())
+		if isProjectDir(path) {
+			children = append(children, readWorkspaceProject(entry.Name(), path))
+		}
+	}
+	sort.SliceStable(children, func(i, j int) bool {
+		if !children[i].LastModified.Equal(children[j].LastModified) {
+			return children[i].LastModified.After(children[j].LastModified)
+		}
+		return children[i].Name < children[j].Name
+	})
+	return append(projects, children...), nil
+}
+
+// Find 按目录名或书名查找小说：先精确匹配，再找唯一包含该名称的
+func (w *Workspace) Find(name string) (*WorkspaceProject, error) {
+	projects, err := w.Projects()
+	if err != nil {
+		return nil, err
+	}
+	name = strings.TrimSpace(name)
+	for _, project := range projects {
+		if project.Name == name || project.Title == name {
+			return project, nil
+		}
+	}
+	matches := make([]*WorkspaceProject, 0)
+	for _, project := range projects {
+		if strings.Contains(project.Name, name) || (project.Title != "" && strings.Contains(project.Title, name)) {
+			matches = append(matches, project)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("novel not found in workspace: %s", name)
+	case 1:
+		return matches[0], nil
+	default:
+		names := make([]string, 0, len(matches))
+		for _, project := range matches {
+			names = append(names, project.Name)
+		}
+		return nil, fmt.Errorf("ambiguous novel name %q matches: %s", name, strings.Join(names, ", "))
+	}
+}
+
+// Create 为新小说创建子目录，返回目录路径；目录已是小说项目时报错
+func (w *Workspace) Create(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\:*?"<>|`) {
+		return "", fmt.Errorf("invalid novel directory name: %q", name)
+	}
+	path := filepath.Join(w.Root, name)
+	if isProjectDir(path) {
+		return "", fmt.Errorf("novel already exists: %s", name)
+	}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return "", fmt.Errorf("failed to create novel directory: %w", err)
+	}
+	return path, nil
+}
+
+// Path 小说名称对应的目录
+func (w *Workspace) Path(name string) string {
+	if name == RootProjectName {
+		return w.Root
+	}
+	return filepath.Join(w.Root, name)
+}
+
+// NameOf 项目目录在工作区中的名称，不在工作区中时返回目录名
+func (w *Workspace) NameOf(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if path == filepath.Clean(w.Root) {
+		return RootProjectName
+	}
+	return filepath.Base(path)
+}
+
+// Active 上次打开的小说名称，记录的小说已不存在时返回空
+func (w *Workspace) Active() string {
+	data, err := os.ReadFile(filepath.Join(w.Root, WorkspaceFile))
+	if err != nil {
+		return ""
+	}
+	var state workspaceState
+	if json.Unmarshal(data, &state) != nil || state.Active == "" {
+		return ""
+	}
+	if !isProjectDir(w.Path(state.Active)) {
+		return ""
+	}
+	return state.Active
+}
+
+// SetActive 记录当前打开的小说，下次启动时自动打开
+func (w *Workspace) SetActive(name string) error {
+	data, err := json.MarshalIndent(workspaceState{Active: name}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode workspace state: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(w.Root, WorkspaceFile), data, false); err != nil {
+		return fmt.Errorf("failed to save workspace state: %w", err)
+	}
+	return nil
+}
+
+// isProjectDir 目录中是否有小说项目文件（含写了一半留下的临时文件或备份）
+func isProjectDir(dir string) bool {
+	path := filepath.Join(dir, ProjectFile)
+	for _, candidate := range append([]string{path}, recoveryCandidates(path)...) {
+		if _, err := os.Stat(candidate); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// readWorkspaceProject 读取未打开项目的概况，不加载聊天记录和索引
+func readWorkspaceProject(name, path string) *WorkspaceProject {
+	project := &WorkspaceProject{Name: name, Path: path}
+	data, err := os.ReadFile(filepath.Join(path, ProjectFile))
+	if err != nil {
+		project.Err = err
+		return project
+	}
+	var novelData NovelProject
+	if err := json.Unmarshal(data, &novelData); err != nil {
+		project.Err = fmt.Errorf("failed to parse project file: %w", err)
+		return project
+	}
+	project.ProjectSummary = summarizeProject(&novelData)
+	return project
+}
+
+// ProjectSummary 当前项目概况，没有项目时返回 nil
+func (nm *NovelManager) ProjectSummary() *ProjectSummary {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	if nm.novelData == nil {
+		return nil
+	}
+	summary := summarizeProject(nm.novelData)
+	return &summary
+}
+
+func summarizeProject(project *NovelProject) ProjectSummary {
+	summary := ProjectSummary{
+		Title:          project.Title,
+		Author:         project.Author,
+		Genre:          project.Genre,
+		Chapters:       len(project.Chapters),
+		TargetWords:    project.TargetWords,
+		CurrentChapter: projectCurrentChapter(project),
+		LastModified:   project.LastModified,
+	}
+	for _, chapter := range project.Chapters {
+		summary.Words += chapter.WordCount
+	}
+	return summary
+}
+
+// FormatWorkspaceProjects 格式化工作区小说列表，active 为当前打开的小说名称
+func FormatWorkspaceProjects(root string, projects []*WorkspaceProject, active string) string {
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("📚 === 工作区 %s ===\n\n", root))
+	if len(projects) == 0 {
+		result.WriteString("还没有小说：使用 /novel new <目录名> 创建\n")
+		return result.String()
+	}
+	for _, project := range projects {
+		marker := "  "
+		if project.Name == active {
+			marker = "👉"
+		}
+		if project.Err != nil {
+			result.WriteString(fmt.Sprintf("%s %-12s ⚠️ 无法读取: %v\n", marker, project.Name, project.Err))
+			continue
+		}
+		line := fmt.Sprintf("%s %-12s 《%s》 %s  %d 章  %d 字", marker, project.Name, project.Title,
+			project.Genre, project.Chapters, project.Words)
+		if !project.LastModified.IsZero() {
+			line += "  " + project.LastModified.Format("2006-01-02 15:04")
+		}
+		result.WriteString(line + "\n")
+	}
+	result.WriteString(fmt.Sprintf("\n共 %d 部，使用 /novel open <名称> 切换\n", len(projects)))
+	return result.String()
+}
+
+// Format 格式化项目概况
+func (s *ProjectSummary) Format(path string) string {
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("📖 === 《%s》 ===\n\n", s.Title))
+	result.WriteString(fmt.Sprintf("作者: %s\n", s.Author))
+	result.WriteString(fmt.Sprintf("类型: %s\n", s.Genre))
+	result.WriteString(fmt.Sprintf("目录: %s\n", path))
+	if s.TargetWords > 0 {
+		result.WriteString(fmt.Sprintf("字数: %d / %d（%.1f%%）\n", s.Words, s.TargetWords, float64(s.Words)*100/float64(s.TargetWords)))
+	} else {
+		result.WriteString(fmt.Sprintf("字数: %d\n", s.Words))
+	}
+	result.WriteString(fmt.Sprintf("章节: %d 章，当前第%d章\n", s.Chapters, s.CurrentChapter))
+	if !s.LastModified.IsZero() {
+		result.WriteString(fmt.Sprintf("最后修改: %s\n", s.LastModified.Format("2006-01-02 15:04")))
+	}
+	return result.String()
+}
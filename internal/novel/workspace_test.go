@@ -0,0 +1,160 @@
+package novel
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newWorkspaceProject 在目录中初始化一部小说并写盘
+func newWorkspaceProject(t *testing.T, dir, title string) {
+	t.Helper()
+	nm := NewNovelManager(dir)
+	if err := nm.InitializeProject(title, "测试作者", "玄幻"); err != nil {
+		t.Fatal(err)
+	}
+	if err := nm.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// chdir 切换工作目录，测试结束后恢复
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+}
+
+func TestWorkspaceRelativeRoot(t *testing.T) {
+	chdir(t, t.TempDir())
+	workspace := NewWorkspace(".")
+	if !filepath.IsAbs(workspace.Root) {
+		t.Fatalf("Root = %q, want an absolute path", workspace.Root)
+	}
+
+	path, err := workspace.Create("长夜")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(workspace.Root, "长夜") {
+		t.Errorf("Create() = %q", path)
+	}
+	newWorkspaceProject(t, path, "长夜将明")
+
+	// 按目录名和书名都能找到，路径与打开后的项目路径一致
+	for _, name := range []string{"长夜", "长夜将明", "将明"} {
+		project, err := workspace.Find(name)
+		if err != nil {
+			t.Fatalf("Find(%q): %v", name, err)
+		}
+		if project.Path != path || project.Title != "长夜将明" || project.Err != nil {
+			t.Errorf("Find(%q) = %+v", name, project)
+		}
+	}
+	if got := workspace.NameOf("长夜"); got != "长夜" {
+		t.Errorf("NameOf(relative) = %q", got)
+	}
+	if got := workspace.NameOf("."); got != RootProjectName {
+		t.Errorf("NameOf(.) = %q, want %q", got, RootProjectName)
+	}
+	if got := workspace.NameOf(workspace.Root); got != RootProjectName {
+		t.Errorf("NameOf(root) = %q, want %q", got, RootProjectName)
+	}
+
+	if err := workspace.SetActive("长夜"); err != nil {
+		t.Fatal(err)
+	}
+	if got := NewWorkspace(".").Active(); got != "长夜" {
+		t.Errorf("Active() = %q, want 长夜", got)
+	}
+	// 记录的小说被删除后不再自动打开
+	if err := os.RemoveAll(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := workspace.Active(); got != "" {
+		t.Errorf("Active() after removal = %q", got)
+	}
+}
+
+func TestWorkspaceProjects(t *testing.T) {
+	root := t.TempDir()
+	workspace := NewWorkspace(root)
+	if projects, err := workspace.Projects(); err != nil || len(projects) != 0 {
+		t.Fatalf("Projects(empty) = %v, %v", projects, err)
+	}
+
+	newWorkspaceProject(t, root, "根目录小说")
+	newWorkspaceProject(t, filepath.Join(root, "old"), "旧书")
+	newWorkspaceProject(t, filepath.Join(root, "new"), "新书")
+	newWorkspaceProject(t, filepath.Join(root, ".hidden"), "隐藏")
+	if err := os.MkdirAll(filepath.Join(root, "notes"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "broken"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "broken", ProjectFile), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	projects, err := workspace.Projects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 根目录在最前，其余按最近修改排序，读不出修改时间的排在最后；隐藏目录和非项目目录跳过
+	names := make([]string, 0, len(projects))
+	for _, project := range projects {
+		names = append(names, project.Name)
+	}
+	if want := []string{RootProjectName, "new", "old", "broken"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("projects = %v, want %v", names, want)
+	}
+	if projects[0].Path != root || projects[0].Title != "根目录小说" {
+		t.Errorf("root project = %+v", projects[0])
+	}
+	if projects[3].Err == nil {
+		t.Error("broken project has no error")
+	}
+	listing := FormatWorkspaceProjects(workspace.Root, projects, "new")
+	if !strings.Contains(listing, "👉 new") || !strings.Contains(listing, "无法读取") {
+		t.Errorf("listing =\n%s", listing)
+	}
+
+	if _, err := workspace.Find("书"); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("Find(书) err = %v", err)
+	}
+	if _, err := workspace.Find("武侠"); err == nil {
+		t.Error("found a missing novel")
+	}
+}
+
+func TestWorkspaceCreate(t *testing.T) {
+	root := t.TempDir()
+	workspace := NewWorkspace(root)
+	for _, name := range []string{"", " ", ".", "..", ".git", "a/b", `a\b`, "a:b"} {
+		if _, err := workspace.Create(name); err == nil {
+			t.Errorf("Create(%q) accepted an invalid name", name)
+		}
+	}
+
+	path, err := workspace.Create(" 新书 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 目录已存在但还不是小说项目时可以继续使用
+	if again, err := workspace.Create("新书"); err != nil || again != path {
+		t.Errorf("Create(existing dir) = %q, %v", again, err)
+	}
+	newWorkspaceProject(t, path, "新书")
+	if _, err := workspace.Create("新书"); err == nil {
+		t.Error("created over an existing novel")
+	}
+}
@@ -127,6 +127,14 @@ func (m *Manager) NewSession(name string) *Session {
 	return session
 }
 
+// SetWorkingDirectory 切换工作目录（如打开另一部小说）后更新会话上下文并重新分析项目
+func (s *Session) SetWorkingDirectory(dir string) {
+	s.Context.WorkingDirectory = dir
+	s.Context.ProjectInfo = ProjectInfo{}
+	s.analyzeProject()
+	s.UpdatedAt = time.Now()
+}
+
 func (s *Session) AddMessage(role, content string) {
 	s.Messages = append(s.Messages, ai.Message{
 		Role:    role,
@@ -10,10 +10,12 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/AiNovelTools/internal/ai"
+	"github.com/AiNovelTools/internal/config"
 	contextmgr "github.com/AiNovelTools/internal/context"
 	"github.com/AiNovelTools/internal/novel"
 )
@@ -22,6 +24,9 @@ type Manager struct {
 	tools          map[string]Tool
 	contextManager *contextmgr.ContextManager
 	novelManager   *novel.NovelManager
+	workspace      *novel.Workspace
+	aiClient       *ai.Client
+	root           string // 当前项目根目录，文件工具的相对路径以它为准
 }
 
 type Tool interface {
@@ -43,9 +48,10 @@ type ToolResult struct {
 	Result     string
 	Error      error
 	ToolCallID string
+	Summary    string // 改动摘要，用于版本提交说明；只读工具为空
 }
 
-func NewManager() *Manager {
+func NewManager(aiClient *ai.Client) *Manager {
 	contextManager := contextmgr.NewContextManager()
 	// 尝试加载已保存的上下文
 	contextManager.LoadContext()
@@ -59,35 +65,45 @@ func NewManager() *Manager {
 		tools:          make(map[string]Tool),
 		contextManager: contextManager,
 		novelManager:   novelManager,
+		workspace:      novel.NewWorkspace(currentDir),
+		aiClient:       aiClient,
+		root:           currentDir,
 	}
 	
 	// 注册内置工具
 	m.RegisterTool(&ReadFileTool{})
 	m.RegisterTool(&WriteFileTool{})
 	m.RegisterTool(&EditFileTool{})
-	m.RegisterTool(&ListFilesTool{})
+	m.RegisterTool(&ListFilesTool{dir: m.Root})
 	m.RegisterTool(&CreateDirectoryTool{})
 	m.RegisterTool(&DeleteFileTool{})
 	m.RegisterTool(&RenameFileTool{})
 	m.RegisterTool(&CopyFileTool{})
 	m.RegisterTool(&MoveFileTool{})
 	m.RegisterTool(&FileInfoTool{})
-	m.RegisterTool(&ExecuteCommandTool{})
-	m.RegisterTool(&SearchTool{})
+	m.RegisterTool(&ExecuteCommandTool{dir: m.Root})
+	m.RegisterTool(&SearchTool{dir: m.Root})
 	m.RegisterTool(&ReplaceTextTool{})
 	
 	// 环境感知工具
-	m.RegisterTool(&GetCurrentDirectoryTool{})
-	m.RegisterTool(&GetSystemInfoTool{})
-	m.RegisterTool(&GetProjectInfoTool{})
-	m.RegisterTool(&GetWorkingContextTool{})
-	m.RegisterTool(&GetSmartContextTool{contextManager: m.contextManager})
-	m.RegisterTool(&SmartTaskPlannerTool{})
+	m.RegisterTool(&GetCurrentDirectoryTool{dir: m.Root})
+	m.RegisterTool(&GetSystemInfoTool{dir: m.Root})
+	m.RegisterTool(&GetProjectInfoTool{dir: m.Root})
+	m.RegisterTool(&GetWorkingContextTool{dir: m.Root})
+	m.RegisterTool(&GetSmartContextTool{contextManager: m.contextManager, dir: m.Root})
+	m.RegisterTool(&FileRelationshipAnalyzerTool{dir: m.Root})
+	
+	m.registerNovelTools()
+	return m
+}
+
+// registerNovelTools 注册依赖小说项目的工具；切换项目后重新注册，使其指向新项目
+func (m *Manager) registerNovelTools() {
+	m.RegisterTool(&SmartTaskPlannerTool{novelManager: m.novelManager})
 	
 	// 智能分析工具
-	m.RegisterTool(&FileRelationshipAnalyzerTool{})
-	m.RegisterTool(&ConsistencyCheckerTool{})
-	m.RegisterTool(&CreativeStageDetectorTool{})
+	m.RegisterTool(&ConsistencyCheckerTool{novelManager: m.novelManager, aiClient: m.aiClient})
+	m.RegisterTool(&CreativeStageDetectorTool{novelManager: m.novelManager})
 	
 	// 小说写作工具
 	m.RegisterTool(&InitNovelProjectTool{novelManager: m.novelManager})
@@ -96,8 +112,85 @@ func NewManager() *Manager {
 	m.RegisterTool(&AddPlotLineTool{novelManager: m.novelManager})
 	m.RegisterTool(&GetChapterContextTool{novelManager: m.novelManager})
 	m.RegisterTool(&SearchNovelHistoryTool{novelManager: m.novelManager})
+	m.RegisterTool(&ImportManuscriptTool{novelManager: m.novelManager})
+	m.RegisterTool(&ExportNovelTool{novelManager: m.novelManager})
+	m.RegisterTool(&SetStoryCalendarTool{novelManager: m.novelManager})
+	m.RegisterTool(&AddTimelineEventTool{novelManager: m.novelManager})
+	m.RegisterTool(&QueryTimelineTool{novelManager: m.novelManager})
+	m.RegisterTool(&CharacterAgeTool{novelManager: m.novelManager})
+	m.RegisterTool(&PlantForeshadowingTool{novelManager: m.novelManager})
+	m.RegisterTool(&UpdateForeshadowingTool{novelManager: m.novelManager})
+	m.RegisterTool(&ListForeshadowingTool{novelManager: m.novelManager})
+	m.RegisterTool(&SetRelationshipTool{novelManager: m.novelManager})
+	m.RegisterTool(&QueryRelationshipTool{novelManager: m.novelManager})
+	m.RegisterTool(&ExportRelationshipGraphTool{novelManager: m.novelManager})
+	m.RegisterTool(&GenerateChapterTool{novelManager: m.novelManager, aiClient: m.aiClient})
+	m.RegisterTool(&StyleProfileTool{novelManager: m.novelManager})
+	m.RegisterTool(&POVCheckTool{novelManager: m.novelManager})
+	m.RegisterTool(&CharacterAppearancesTool{novelManager: m.novelManager})
+	m.RegisterTool(&AnalyzeDialogueTool{novelManager: m.novelManager, aiClient: m.aiClient})
+	m.RegisterTool(&AnalyzePacingTool{novelManager: m.novelManager, aiClient: m.aiClient})
+	m.RegisterTool(&OutlineTemplateTool{novelManager: m.novelManager})
+	m.RegisterTool(&GlossaryTool{novelManager: m.novelManager})
+	m.RegisterTool(&FixGlossaryTool{novelManager: m.novelManager})
+	m.RegisterTool(&GenerateNamesTool{novelManager: m.novelManager, aiClient: m.aiClient})
+	m.RegisterTool(&ChapterHistoryTool{novelManager: m.novelManager})
+}
+
+// NovelManager 返回当前绑定的小说管理器
+func (m *Manager) NovelManager() *novel.NovelManager {
+	return m.novelManager
+}
+
+// Workspace 返回启动目录对应的小说工作区
+func (m *Manager) Workspace() *novel.Workspace {
+	return m.workspace
+}
+
+// Root 当前项目根目录
+func (m *Manager) Root() string {
+	return m.root
+}
+
+// OpenNovelProject 切换到另一个小说项目目录：加载新项目后写入并关闭当前项目，小说工具随之指向新项目，
+// 文件工具的相对路径也改以新项目目录为准。进程的工作目录保持不变，失败时当前项目不受影响
+func (m *Manager) OpenNovelProject(path string) error {
+	root, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve project path: %w", err)
+	}
+	novelManager := novel.NewNovelManager(root)
+	if err := novelManager.LoadProject(); err != nil {
+		novelManager.Close()
+		return fmt.Errorf("failed to load novel project: %w", err)
+	}
+	if err := m.novelManager.Close(); err != nil {
+		novelManager.Close()
+		return fmt.Errorf("failed to save current novel project: %w", err)
+	}
 	
-	return m
+	m.novelManager = novelManager
+	m.root = root
+	m.registerNovelTools()
+	m.contextManager.UpdateCurrentProject(root)
+	return nil
+}
+
+// 文件工具的路径参数
+var pathParams = []string{"path", "file_path", "src_path", "dst_path", "old_path", "new_path", "output_path", "cover_image"}
+
+// resolvePaths 返回把相对路径参数换成项目目录下绝对路径的参数副本
+func (m *Manager) resolvePaths(params map[string]interface{}) map[string]interface{} {
+	resolved := make(map[string]interface{}, len(params))
+	for key, value := range params {
+		resolved[key] = value
+	}
+	for _, key := range pathParams {
+		if path, ok := resolved[key].(string); ok && path != "" && !filepath.IsAbs(path) {
+			resolved[key] = filepath.Join(m.root, path)
+		}
+	}
+	return resolved
 }
 
 func (m *Manager) RegisterTool(tool Tool) {
@@ -163,7 +256,7 @@ func getToolCategory(toolName string) string {
 	searchOps := []string{"search", "replace_text"}
 	sysOps := []string{"execute_command"}
 	envOps := []string{"get_current_directory", "get_system_info", "get_project_info", "get_working_context", "get_smart_context"}
-	novelOps := []string{"init_novel_project", "get_novel_context", "add_character", "add_plot_line", "get_chapter_context", "search_novel_history"}
+	novelOps := []string{"init_novel_project", "get_novel_context", "add_character", "add_plot_line", "get_chapter_context", "search_novel_history", "import_manuscript", "export_novel", "check_consistency", "set_story_calendar", "add_timeline_event", "query_timeline", "character_age", "plant_foreshadowing", "update_foreshadowing", "list_foreshadowing", "set_relationship", "query_relationship", "export_relationship_graph", "generate_chapter", "style_profile", "check_pov", "character_appearances", "analyze_dialogue", "analyze_pacing", "detect_creative_stage", "outline_template", "glossary", "fix_glossary", "generate_names", "chapter_history"}
 	
 	for _, op := range fileOps {
 		if op == toolName {
@@ -240,12 +333,13 @@ func (m *Manager) ExecuteTools(ctx context.Context, toolCalls []ai.ToolCall) ([]
 			continue
 		}
 		
-		result, err := tool.Execute(ctx, params)
+		result, err := tool.Execute(ctx, m.resolvePaths(params))
 		results = append(results, ToolResult{
 			ToolName:   funcName,
 			Result:     result,
 			Error:      err,
 			ToolCallID: call.ID,
+			Summary:    describeToolCall(funcName, params),
 		})
 	}
 	
@@ -305,7 +399,9 @@ func (t *WriteFileTool) Execute(ctx context.Context, params map[string]interface
 }
 
 // ListFilesTool - 列出目录内容
-type ListFilesTool struct{}
+type ListFilesTool struct {
+	dir func() string
+}
 
 func (t *ListFilesTool) Name() string { return "list_files" }
 func (t *ListFilesTool) Description() string { 
@@ -315,7 +411,7 @@ func (t *ListFilesTool) Description() string {
 func (t *ListFilesTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
 	path, ok := params["path"].(string)
 	if !ok {
-		path = "."
+		path = t.dir()
 	}
 	
 	entries, err := os.ReadDir(path)
@@ -336,7 +432,9 @@ func (t *ListFilesTool) Execute(ctx context.Context, params map[string]interface
 }
 
 // ExecuteCommandTool - 执行系统命令
-type ExecuteCommandTool struct{}
+type ExecuteCommandTool struct {
+	dir func() string
+}
 
 func (t *ExecuteCommandTool) Name() string { return "execute_command" }
 func (t *ExecuteCommandTool) Description() string { return "Execute a system command" }
@@ -353,6 +451,7 @@ func (t *ExecuteCommandTool) Execute(ctx context.Context, params map[string]inte
 	}
 	
 	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = t.dir()
 	output, err := cmd.CombinedOutput()
 	
 	result := string(output)
@@ -364,7 +463,9 @@ func (t *ExecuteCommandTool) Execute(ctx context.Context, params map[string]inte
 }
 
 // SearchTool - 搜索文件内容
-type SearchTool struct{}
+type SearchTool struct {
+	dir func() string
+}
 
 func (t *SearchTool) Name() string { return "search" }
 func (t *SearchTool) Description() string { return "Search for text in files" }
@@ -377,7 +478,7 @@ func (t *SearchTool) Execute(ctx context.Context, params map[string]interface{})
 	
 	path, ok := params["path"].(string)
 	if !ok {
-		path = "."
+		path = t.dir()
 	}
 	
 	filePattern, _ := params["file_pattern"].(string)
@@ -830,7 +931,9 @@ func (t *ReplaceTextTool) Execute(ctx context.Context, params map[string]interfa
 // ======================== 环境感知工具 ========================
 
 // GetCurrentDirectoryTool - 获取当前工作目录
-type GetCurrentDirectoryTool struct{}
+type GetCurrentDirectoryTool struct {
+	dir func() string
+}
 
 func (t *GetCurrentDirectoryTool) Name() string { return "get_current_directory" }
 func (t *GetCurrentDirectoryTool) Description() string { 
@@ -838,10 +941,7 @@ func (t *GetCurrentDirectoryTool) Description() string {
 }
 
 func (t *GetCurrentDirectoryTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
-	currentDir, err := os.Getwd()
-	if err != nil {
-		return "", fmt.Errorf("failed to get current directory: %w", err)
-	}
+	currentDir := t.dir()
 	
 	// 获取目录信息
 	info, err := os.Stat(currentDir)
@@ -884,7 +984,9 @@ func (t *GetCurrentDirectoryTool) Execute(ctx context.Context, params map[string
 }
 
 // GetSystemInfoTool - 获取系统信息
-type GetSystemInfoTool struct{}
+type GetSystemInfoTool struct {
+	dir func() string
+}
 
 func (t *GetSystemInfoTool) Name() string { return "get_system_info" }
 func (t *GetSystemInfoTool) Description() string { 
@@ -933,7 +1035,7 @@ func (t *GetSystemInfoTool) Execute(ctx context.Context, params map[string]inter
 	}
 	
 	// 磁盘空间信息（当前目录）
-	currentDir, _ := os.Getwd()
+	currentDir := t.dir()
 	result.WriteString(fmt.Sprintf("\n=== Current Directory Context ===\n"))
 	result.WriteString(fmt.Sprintf("Working Directory: %s\n", currentDir))
 	
@@ -941,7 +1043,9 @@ func (t *GetSystemInfoTool) Execute(ctx context.Context, params map[string]inter
 }
 
 // GetProjectInfoTool - 获取项目信息
-type GetProjectInfoTool struct{}
+type GetProjectInfoTool struct {
+	dir func() string
+}
 
 func (t *GetProjectInfoTool) Name() string { return "get_project_info" }
 func (t *GetProjectInfoTool) Description() string { 
@@ -951,11 +1055,7 @@ func (t *GetProjectInfoTool) Description() string {
 func (t *GetProjectInfoTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
 	projectPath, ok := params["path"].(string)
 	if !ok {
-		currentDir, err := os.Getwd()
-		if err != nil {
-			return "", fmt.Errorf("failed to get current directory: %w", err)
-		}
-		projectPath = currentDir
+		projectPath = t.dir()
 	}
 	
 	var result strings.Builder
@@ -991,7 +1091,9 @@ func (t *GetProjectInfoTool) Execute(ctx context.Context, params map[string]inte
 }
 
 // GetWorkingContextTool - 获取完整工作上下文
-type GetWorkingContextTool struct{}
+type GetWorkingContextTool struct {
+	dir func() string
+}
 
 func (t *GetWorkingContextTool) Name() string { return "get_working_context" }
 func (t *GetWorkingContextTool) Description() string { 
@@ -1003,14 +1105,14 @@ func (t *GetWorkingContextTool) Execute(ctx context.Context, params map[string]i
 	result.WriteString("🤖 === AI Assistant Working Context === 🤖\n\n")
 	
 	// 获取当前目录信息
-	currentDirTool := &GetCurrentDirectoryTool{}
+	currentDirTool := &GetCurrentDirectoryTool{dir: t.dir}
 	dirInfo, err := currentDirTool.Execute(ctx, nil)
 	if err == nil {
 		result.WriteString("📍 " + dirInfo + "\n")
 	}
 	
 	// 获取项目信息
-	projectTool := &GetProjectInfoTool{}
+	projectTool := &GetProjectInfoTool{dir: t.dir}
 	projectInfo, err := projectTool.Execute(ctx, nil)
 	if err == nil {
 		result.WriteString(projectInfo + "\n")
@@ -1024,8 +1126,7 @@ func (t *GetWorkingContextTool) Execute(ctx context.Context, params map[string]i
 		result.WriteString(fmt.Sprintf("Host: %s | ", hostname))
 	}
 	
-	currentDir, _ := os.Getwd()
-	result.WriteString(fmt.Sprintf("PWD: %s\n\n", currentDir))
+	result.WriteString(fmt.Sprintf("PWD: %s\n\n", t.dir()))
 	
 	// AI工作建议
 	result.WriteString("🎯 AI Assistant Ready!\n")
@@ -1307,6 +1408,7 @@ func fileExists(filePath string) bool {
 // GetSmartContextTool - 智能上下文感知工具
 type GetSmartContextTool struct {
 	contextManager *contextmgr.ContextManager
+	dir            func() string
 }
 
 func (t *GetSmartContextTool) Name() string { return "get_smart_context" }
@@ -1316,7 +1418,7 @@ func (t *GetSmartContextTool) Description() string {
 
 func (t *GetSmartContextTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
 	// 更新当前项目上下文
-	currentDir, _ := os.Getwd()
+	currentDir := t.dir()
 	t.contextManager.UpdateCurrentProject(currentDir)
 	
 	var result strings.Builder
@@ -1337,7 +1439,7 @@ func (t *GetSmartContextTool) Execute(ctx context.Context, params map[string]int
 	}
 	
 	// 项目分析（结合基础工具）
-	projectTool := &GetProjectInfoTool{}
+	projectTool := &GetProjectInfoTool{dir: t.dir}
 	projectInfo, err := projectTool.Execute(ctx, nil)
 	if err == nil {
 		result.WriteString("🔍 当前项目分析:\n")
@@ -1390,7 +1492,7 @@ type InitNovelProjectTool struct {
 
 func (t *InitNovelProjectTool) Name() string { return "init_novel_project" }
 func (t *InitNovelProjectTool) Description() string { 
-	return "Initialize a new novel writing project with title, author, genre, and basic settings. Creates the foundation for consistent novel writing with character and plot tracking."
+	return "Initialize a new novel writing project with title, author, genre, and basic settings. Creates the foundation for consistent novel writing with character and plot tracking. When the genre matches a template (built-in: 玄幻/修仙, 都市, 悬疑, 科幻, 言情; more can be added as YAML files under templates/genres in the config directory), it also seeds world-setting categories, character roles, typical plot lines, glossary terms and a whole-book beat outline, and writes 世界观.md, 主角设定.md and 大纲.md."
 }
 
 func (t *InitNovelProjectTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
@@ -1409,11 +1511,57 @@ func (t *InitNovelProjectTool) Execute(ctx context.Context, params map[string]in
 		genre = "Fiction"
 	}
 	
-	if err := t.novelManager.InitializeProject(title, author, genre); err != nil {
+	// 按题材或指定名称选择题材模板，none 表示创建空项目
+	templates, warnings := novel.LoadGenreTemplates(genreTemplateDir())
+	var template *novel.GenreTemplate
+	switch name := stringParam(params, "template"); name {
+	case "none":
+	case "":
+		template = novel.FindGenreTemplate(templates, genre)
+	default:
+		if template = novel.FindGenreTemplate(templates, name); template == nil {
+			return "", fmt.Errorf("genre template not found: %s", name)
+		}
+	}
+	
+	if template == nil {
+		if err := t.novelManager.InitializeProject(title, author, genre); err != nil {
+			return "", fmt.Errorf("failed to initialize novel project: %w", err)
+		}
+		return fmt.Sprintf("✅ 小说项目初始化成功！\n标题: %s\n作者: %s\n类型: %s\n\n现在可以开始添加角色、情节线和章节内容了。", title, author, genre), nil
+	}
+	
+	if _, ok := params["genre"].(string); !ok {
+		genre = template.Title
+	}
+	beatTemplates, _ := novel.LoadBeatTemplates(beatTemplateDir())
+	outline := novel.FindBeatTemplate(beatTemplates, template.Outline)
+	created, err := t.novelManager.InitializeFromTemplate(title, author, genre, template, outline)
+	if err != nil {
 		return "", fmt.Errorf("failed to initialize novel project: %w", err)
 	}
 	
-	return fmt.Sprintf("✅ 小说项目初始化成功！\n标题: %s\n作者: %s\n类型: %s\n\n现在可以开始添加角色、情节线和章节内容了。", title, author, genre), nil
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("✅ 小说项目初始化成功！\n标题: %s\n作者: %s\n类型: %s\n题材模板: %s\n\n", title, author, genre, template.Title))
+	result.WriteString(fmt.Sprintf("🌍 预置世界观设定 %d 项，情节线 %d 条，术语 %d 个\n", len(template.WorldSettings), len(template.PlotLines), len(template.Glossary)))
+	if outline != nil {
+		result.WriteString(fmt.Sprintf("📐 已按「%s」生成全书节拍大纲，可用 outline_template action=\"status\" 查看\n", outline.Title))
+	}
+	if slots := t.novelManager.OpenArchetypes(); len(slots) > 0 {
+		roles := make([]string, 0, len(slots))
+		for _, slot := range slots {
+			roles = append(roles, slot.Role)
+		}
+		result.WriteString(fmt.Sprintf("🎭 待填补的角色定位: %s（add_character 时用 archetype 指定）\n", strings.Join(roles, "、")))
+	}
+	if len(created) > 0 {
+		result.WriteString(fmt.Sprintf("📄 已生成: %s\n", strings.Join(created, "、")))
+	}
+	for _, warning := range warnings {
+		result.WriteString(fmt.Sprintf("⚠️ 无法加载模板 %s\n", warning))
+	}
+	result.WriteString("\n先补全主角设定和世界观中的待定内容，再开始写第一章。")
+	return result.String(), nil
 }
 
 // GetNovelContextTool - 获取小说上下文
@@ -1448,10 +1596,61 @@ func (t *AddCharacterTool) Execute(ctx context.Context, params map[string]interf
 		return "", fmt.Errorf("character name is required")
 	}
 	
-	background, _ := params["background"].(string)
-	personality, _ := params["personality"].(string)
+	char := &novel.Character{
+		Name:          name,
+		Age:           intParam(params, "age"),
+		Gender:        stringParam(params, "gender"),
+		Occupation:    stringParam(params, "occupation"),
+		Personality:   splitListParam(stringParam(params, "personality")),
+		Appearance:    stringParam(params, "appearance"),
+		Background:    stringParam(params, "background"),
+		Relationships: make(map[string]string),
+		Status:        stringParam(params, "status"),
+		DeathChapter:  intParam(params, "death_chapter"),
+		FirstAppeared: intParam(params, "first_appeared"),
+		Aliases:       splitListParam(stringParam(params, "aliases")),
+		CharacterArc:  make([]string, 0),
+		KeyDialogues:  make([]string, 0),
+	}
+	if relationships, ok := params["relationships"].(map[string]interface{}); ok {
+		for other, relation := range relationships {
+			if label, ok := relation.(string); ok {
+				char.Relationships[other] = label
+			}
+		}
+	}
+	if birthDate := stringParam(params, "birth_date"); birthDate != "" {
+		timeline, err := t.novelManager.GetTimeline()
+		if err != nil {
+			return "", err
+		}
+		birth, err := timeline.Calendar.ParseDate(birthDate)
+		if err != nil {
+			return "", fmt.Errorf("invalid birth_date: %w", err)
+		}
+		char.Birth = &birth
+	}
+	if char.DeathChapter > 0 && char.Status == "" {
+		char.Status = novel.CharacterDead
+	}
+	
+	updated, err := t.novelManager.AddCharacter(char)
+	if err != nil {
+		return "", fmt.Errorf("failed to add character: %w", err)
+	}
 	
-	return fmt.Sprintf("🎭 角色添加功能正在开发中...\n将添加角色: %s\n背景: %s\n性格: %s", name, background, personality), nil
+	action := "添加"
+	if updated {
+		action = "更新"
+	}
+	result := fmt.Sprintf("🎭 已%s角色: %s", action, char.Name)
+	if archetype := stringParam(params, "archetype"); archetype != "" {
+		if err := t.novelManager.AssignArchetype(archetype, char.Name); err != nil {
+			return "", fmt.Errorf("failed to assign archetype: %w", err)
+		}
+		result += fmt.Sprintf("（定位: %s）", archetype)
+	}
+	return result, nil
 }
 
 // AddPlotLineTool - 添加情节线
@@ -1470,10 +1669,24 @@ func (t *AddPlotLineTool) Execute(ctx context.Context, params map[string]interfa
 		return "", fmt.Errorf("plot line name is required")
 	}
 	
-	plotType, _ := params["type"].(string)
-	description, _ := params["description"].(string)
+	plot := &novel.PlotLine{
+		Name:         name,
+		Type:         stringParam(params, "type"),
+		Status:       stringParam(params, "status"),
+		Description:  stringParam(params, "description"),
+		StartChapter: intParam(params, "start_chapter"),
+		EndChapter:   intParam(params, "end_chapter"),
+	}
+	updated, err := t.novelManager.AddPlotLine(plot)
+	if err != nil {
+		return "", fmt.Errorf("failed to add plot line: %w", err)
+	}
 	
-	return fmt.Sprintf("📖 情节线添加功能正在开发中...\n将添加情节线: %s\n类型: %s\n描述: %s", name, plotType, description), nil
+	action := "添加"
+	if updated {
+		action = "更新"
+	}
+	return fmt.Sprintf("📖 已%s情节线: %s", action, plot.Name), nil
 }
 
 // GetChapterContextTool - 获取章节上下文
@@ -1492,7 +1705,7 @@ func (t *GetChapterContextTool) Execute(ctx context.Context, params map[string]i
 		return "", fmt.Errorf("chapter number is required")
 	}
 	
-	return fmt.Sprintf("📄 第%d章上下文获取功能正在开发中...", int(chapterNum)), nil
+	return t.novelManager.GetChapterContext(int(chapterNum)), nil
 }
 
 // SearchNovelHistoryTool - 搜索小说历史
@@ -1502,208 +1715,2335 @@ type SearchNovelHistoryTool struct {
 
 func (t *SearchNovelHistoryTool) Name() string { return "search_novel_history" }
 func (t *SearchNovelHistoryTool) Description() string { 
-	return "Search through novel writing history to find previous discussions about characters, plots, or specific content. Crucial for maintaining story consistency and avoiding contradictions."
+	return "Search through novel writing history to find previous discussions about characters, plots, or specific content. Crucial for maintaining story consistency and avoiding contradictions. Runs semantic search over written chapters, world settings, characters, plot lines and past discussions, and returns each match with its source (chapter and line number)."
 }
 
 func (t *SearchNovelHistoryTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
-	query, ok := params["query"].(string)
-	if !ok {
-		return "", fmt.Errorf("search query is required")
+	query := stringParam(params, "query")
+	if !t.novelManager.HasProject() {
+		return "", fmt.Errorf("novel project not initialized, please run init_novel_project first")
 	}
 	
-	maxResults, _ := params["max_results"].(float64)
-	if maxResults == 0 {
+	maxResults := intParam(params, "max_results")
+	if maxResults <= 0 {
 		maxResults = 10
 	}
 	
-	return fmt.Sprintf("🔍 搜索小说历史功能正在开发中...\n查询: %s\n最大结果数: %d", query, int(maxResults)), nil
+	// 按角色、设定或情节线直接查找提到它的讨论
+	for _, filter := range []struct{ param, kind, label string }{
+		{"character", novel.MentionCharacter, "角色"},
+		{"setting", novel.MentionSetting, "设定"},
+		{"plot_line", novel.MentionPlot, "情节线"},
+	} {
+		name := stringParam(params, filter.param)
+		if name == "" || query != "" {
+			continue
+		}
+		records, err := t.novelManager.ChatRecordsMentioning(filter.kind, name, maxResults)
+		if err != nil {
+			return "", err
+		}
+		var result strings.Builder
+		result.WriteString(fmt.Sprintf("🔍 提到%s「%s」的讨论（共 %d 条）\n\n", filter.label, name, len(records)))
+		for i, record := range records {
+			result.WriteString(fmt.Sprintf("%d. [%s 第%d章] %s\n", i+1, record.Timestamp.Format("01-02 15:04"), record.ChapterNum, truncateRunes(record.UserMessage, 100)))
+			if record.AIResponse != "" {
+				result.WriteString(fmt.Sprintf("   %s\n", truncateRunes(strings.Join(strings.Fields(record.AIResponse), " "), 200)))
+			}
+		}
+		return result.String(), nil
+	}
+	if query == "" {
+		return "", fmt.Errorf("search query is required")
+	}
+	opts := novel.SearchOptions{
+		Limit:         maxResults,
+		Sources:       splitListParam(stringParam(params, "sources")),
+		BeforeChapter: intParam(params, "before_chapter"),
+	}
+	
+	hits, err := t.novelManager.Search(ctx, query, opts)
+	if err != nil {
+		return "", err
+	}
+	
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("🔍 检索: %s（%s）\n\n", query, t.novelManager.RetrievalEmbedderName()))
+	result.WriteString(novel.FormatSearchHits(hits, 200))
+	return result.String(), nil
 }
 
-// GetToolDefinitions 获取所有工具的定义，供AI模型使用
-func (m *Manager) GetToolDefinitions() []map[string]interface{} {
-	var tools []map[string]interface{}
+// ImportManuscriptTool - 导入整本文稿并按章节拆分
+type ImportManuscriptTool struct {
+	novelManager *novel.NovelManager
+}
+
+func (t *ImportManuscriptTool) Name() string { return "import_manuscript" }
+func (t *ImportManuscriptTool) Description() string {
+	return "导入已有的整本小说txt文稿，自动识别章节标题（第一章/第1章/Chapter 1）和分卷（第一卷），拆分为 chapters/ 下的章节文件并写入项目章节列表。默认只返回识别预览，确认无误后再以 confirm=true 调用执行导入。"
+}
+
+func (t *ImportManuscriptTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	filePath, ok := params["file_path"].(string)
+	if !ok {
+		return "", fmt.Errorf("file_path parameter is required")
+	}
 	
-	for name, tool := range m.tools {
-		parameters := getToolParameters(name)
-		required := getRequiredParameters(name)
-		
-		toolDef := map[string]interface{}{
-			"type": "function",
-			"function": map[string]interface{}{
-				"name":        name,
-				"description": tool.Description(),
-				"parameters": map[string]interface{}{
-					"type":       "object",
-					"properties": parameters,
-					"required":   required,
-				},
-			},
-		}
-		tools = append(tools, toolDef)
+	chapterPattern, _ := params["chapter_pattern"].(string)
+	volumePattern, _ := params["volume_pattern"].(string)
+	confirm, _ := params["confirm"].(bool)
+	overwrite, _ := params["overwrite"].(bool)
+	
+	preview, err := novel.PreviewImport(filePath, novel.ImportOptions{
+		ChapterPattern: chapterPattern,
+		VolumePattern:  volumePattern,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to parse manuscript: %w", err)
 	}
 	
-	return tools
+	if !confirm {
+		return preview.Format(50) + "\n💡 确认章节边界无误后，使用 confirm=true 再次调用以执行导入。", nil
+	}
+	
+	if err := t.novelManager.ApplyImport(preview, overwrite); err != nil {
+		return "", fmt.Errorf("failed to import manuscript: %w", err)
+	}
+	
+	return fmt.Sprintf("✅ 导入完成: %d 卷, %d 章，章节文件已写入 %s/", len(preview.Volumes), len(preview.Chapters), novel.ChaptersDir), nil
 }
 
-// getToolParameters 获取工具的参数定义
-func getToolParameters(toolName string) map[string]interface{} {
-	switch toolName {
-	case "read_file":
-		return map[string]interface{}{
-			"file_path": map[string]interface{}{
-				"type":        "string",
-				"description": "要读取的文件路径",
-			},
-		}
-	case "write_file":
-		return map[string]interface{}{
-			"file_path": map[string]interface{}{
-				"type":        "string", 
-				"description": "要写入的文件路径",
-			},
-			"content": map[string]interface{}{
-				"type":        "string",
-				"description": "要写入的文件内容",
-			},
+// ExportNovelTool - 导出成书
+type ExportNovelTool struct {
+	novelManager *novel.NovelManager
+}
+
+func (t *ExportNovelTool) Name() string { return "export_novel" }
+func (t *ExportNovelTool) Description() string {
+	return "将小说项目的全部章节导出为成书文件：epub（EPUB 3，含目录、元数据和可选封面）、markdown（单个md文件）或 txt（网文平台格式，段首全角缩进）。默认输出到 export/ 目录。"
+}
+
+func (t *ExportNovelTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	format, ok := params["format"].(string)
+	if !ok {
+		return "", fmt.Errorf("format parameter is required")
+	}
+	
+	outputPath, _ := params["output_path"].(string)
+	coverImage, _ := params["cover_image"].(string)
+	compact, _ := params["compact"].(bool)
+	
+	result, err := t.novelManager.Export(novel.ExportOptions{
+		Format:     format,
+		OutputPath: outputPath,
+		CoverImage: coverImage,
+		CompactTXT: compact,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to export novel: %w", err)
+	}
+	
+	return formatExportResult(result), nil
+}
+
+// formatExportResult 格式化导出结果
+func formatExportResult(result *novel.ExportResult) string {
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("📦 导出完成: %s\n", result.Path))
+	output.WriteString(fmt.Sprintf("章节: %d 章, 共 %d 字\n", result.Chapters, result.WordCount))
+	if len(result.Missing) > 0 {
+		missing := make([]string, len(result.Missing))
+		for i, num := range result.Missing {
+			missing[i] = fmt.Sprintf("%d", num)
 		}
-	case "list_files":
-		return map[string]interface{}{
-			"directory": map[string]interface{}{
-				"type":        "string",
-				"description": "要列出的目录路径（可选，默认为当前目录）",
-			},
+		output.WriteString(fmt.Sprintf("⚠️ 以下章节缺少正文文件，已跳过: %s\n", strings.Join(missing, ", ")))
+	}
+	return output.String()
+}
+
+// SetStoryCalendarTool - 设置故事历法
+type SetStoryCalendarTool struct {
+	novelManager *novel.NovelManager
+}
+
+func (t *SetStoryCalendarTool) Name() string { return "set_story_calendar" }
+func (t *SetStoryCalendarTool) Description() string {
+	return "设置故事内历法：纪年名（如“天元”）、每年天数和每年月数。时间线上的日期都以自纪元元年起的天数保存，修改历法只影响日期的显示和解析。"
+}
+
+func (t *SetStoryCalendarTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	timeline, err := t.novelManager.GetTimeline()
+	if err != nil {
+		return "", err
+	}
+	
+	calendar := timeline.Calendar
+	if era, ok := params["era"].(string); ok {
+		calendar.Era = strings.TrimSpace(era)
+	}
+	if days := intParam(params, "days_per_year"); days > 0 {
+		calendar.DaysPerYear = days
+	}
+	if _, ok := params["months_per_year"]; ok {
+		calendar.MonthsPerYear = intParam(params, "months_per_year")
+	}
+	
+	if err := t.novelManager.SetCalendar(calendar); err != nil {
+		return "", fmt.Errorf("failed to set calendar: %w", err)
+	}
+	
+	months := "不分月"
+	if calendar.MonthsPerYear > 0 {
+		months = fmt.Sprintf("%d个月", calendar.MonthsPerYear)
+	}
+	return fmt.Sprintf("📅 历法已设置: 纪年「%s」，每年%d天，%s\n示例日期: %s", calendar.Era, calendar.DaysPerYear, months, calendar.FormatDay(0)), nil
+}
+
+// AddTimelineEventTool - 在时间线上放置事件
+type AddTimelineEventTool struct {
+	novelManager *novel.NovelManager
+}
+
+func (t *AddTimelineEventTool) Name() string { return "add_timeline_event" }
+func (t *AddTimelineEventTool) Description() string {
+	return "在故事时间线上放置一个事件：故事内日期（如“天元123年3月5日”“123年”“123-3-5”，或相对已有事件的“after”偏移）、持续时间、参与角色和叙述该事件的章节。章节关联的事件决定该章的故事时间，用于推算角色年龄。"
+}
+
+func (t *AddTimelineEventTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	title := stringParam(params, "title")
+	if title == "" {
+		return "", fmt.Errorf("title parameter is required")
+	}
+	
+	timeline, err := t.novelManager.GetTimeline()
+	if err != nil {
+		return "", err
+	}
+	calendar := timeline.Calendar
+	
+	var day int
+	date := stringParam(params, "date")
+	after := stringParam(params, "after")
+	switch {
+	case date != "":
+		if day, err = calendar.ParseDate(date); err != nil {
+			return "", err
 		}
-	case "search":
-		return map[string]interface{}{
-			"query": map[string]interface{}{
-				"type":        "string",
-				"description": "要搜索的文本内容或正则表达式",
-			},
-			"path": map[string]interface{}{
-				"type":        "string",
-				"description": "搜索路径（可选，默认当前目录）",
-			},
-			"file_pattern": map[string]interface{}{
-				"type":        "string", 
-				"description": "文件匹配模式（可选，如*.txt）",
-			},
-			"use_regex": map[string]interface{}{
-				"type":        "boolean",
-				"description": "是否使用正则表达式搜索",
-			},
-			"case_sensitive": map[string]interface{}{
-				"type":        "boolean",
-				"description": "是否区分大小写",
-			},
-			"show_line_numbers": map[string]interface{}{
-				"type":        "boolean",
-				"description": "是否显示行号",
-			},
-			"max_results": map[string]interface{}{
-				"type":        "integer",
-				"description": "最大结果数量（默认50）",
-			},
+	case after != "":
+		// 相对已有事件放置：after 事件结束后再过 gap
+		ref, event, err := timeline.ResolveStoryTime(after)
+		if err != nil {
+			return "", err
 		}
-	case "execute_command":
-		return map[string]interface{}{
-			"command": map[string]interface{}{
-				"type":        "string",
-				"description": "要执行的系统命令",
-			},
+		if event != nil {
+			ref = event.End()
 		}
-	case "file_info":
-		return map[string]interface{}{
-			"file_path": map[string]interface{}{
-				"type":        "string",
-				"description": "要获取信息的文件或目录路径",
-			},
+		gap, err := calendar.ParseDuration(stringParam(params, "gap"))
+		if err != nil {
+			return "", err
 		}
-	case "edit_file":
-		return map[string]interface{}{
-			"file_path": map[string]interface{}{
-				"type":        "string",
-				"description": "要编辑的文件路径",
-			},
-			"start_line": map[string]interface{}{
-				"type":        "integer",
-				"description": "开始编辑的行号（可选）",
+		day = ref + gap
+	default:
+		return "", fmt.Errorf("either date or after is required")
+	}
+	
+	duration, err := calendar.ParseDuration(stringParam(params, "duration"))
+	if err != nil {
+		return "", err
+	}
+	
+	event := &novel.TimelineEvent{
+		Title:        title,
+		Description:  stringParam(params, "description"),
+		Day:          day,
+		DurationDays: duration,
+		Chapter:      intParam(params, "chapter"),
+		Participants: splitListParam(stringParam(params, "participants")),
+	}
+	id, err := t.novelManager.AddTimelineEvent(event)
+	if err != nil {
+		return "", fmt.Errorf("failed to add timeline event: %w", err)
+	}
+	
+	return fmt.Sprintf("🕰️ 已添加事件 %s: %s\n%s", id, title, timeline.FormatText([]*novel.TimelineEvent{event})), nil
+}
+
+// QueryTimelineTool - 查询时间线
+type QueryTimelineTool struct {
+	novelManager *novel.NovelManager
+}
+
+func (t *QueryTimelineTool) Name() string { return "query_timeline" }
+func (t *QueryTimelineTool) Description() string {
+	return "查询故事时间线：列出全部事件，或查询某个事件/日期之前（before）、之后（after）发生了什么，可按参与角色过滤，支持文本或 Mermaid timeline 图输出。"
+}
+
+func (t *QueryTimelineTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	timeline, err := t.novelManager.GetTimeline()
+	if err != nil {
+		return "", err
+	}
+	
+	var from, to *int
+	var heading strings.Builder
+	heading.WriteString("🕰️ === 故事时间线 ===\n")
+	if before := stringParam(params, "before"); before != "" {
+		day, _, err := timeline.ResolveStoryTime(before)
+		if err != nil {
+			return "", err
+		}
+		to = &day
+		heading.WriteString(fmt.Sprintf("早于: %s（%s）\n", before, timeline.Calendar.FormatDay(day)))
+	}
+	if after := stringParam(params, "after"); after != "" {
+		day, event, err := timeline.ResolveStoryTime(after)
+		if err != nil {
+			return "", err
+		}
+		if event != nil {
+			day = event.Day + 1
+		}
+		from = &day
+		heading.WriteString(fmt.Sprintf("晚于: %s\n", after))
+	}
+	participant := stringParam(params, "participant")
+	if participant != "" {
+		heading.WriteString(fmt.Sprintf("参与角色: %s\n", participant))
+	}
+	
+	events := timeline.EventsBetween(from, to, participant)
+	// before 查询时最近的事件最相关，只保留最后 limit 条
+	if limit := intParam(params, "limit"); limit > 0 && len(events) > limit {
+		if to != nil && from == nil {
+			events = events[len(events)-limit:]
+		} else {
+			events = events[:limit]
+		}
+	}
+	
+	if stringParam(params, "format") == "mermaid" {
+		return "```mermaid\n" + timeline.FormatMermaid(events, "故事时间线") + "```\n", nil
+	}
+	return heading.String() + "\n" + timeline.FormatText(events), nil
+}
+
+// CharacterAgeTool - 推算角色在某章的年龄
+type CharacterAgeTool struct {
+	novelManager *novel.NovelManager
+}
+
+func (t *CharacterAgeTool) Name() string { return "character_age" }
+func (t *CharacterAgeTool) Description() string {
+	return "根据时间线推算角色在指定章节时的年龄。优先使用角色出生日期，否则以设定年龄对应其首次登场章节的故事时间推算。"
+}
+
+func (t *CharacterAgeTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	name := stringParam(params, "character")
+	chapter := intParam(params, "chapter")
+	if name == "" || chapter <= 0 {
+		return "", fmt.Errorf("character and chapter parameters are required")
+	}
+	
+	char, ok := t.novelManager.GetCharacter(name)
+	if !ok {
+		return "", fmt.Errorf("character not found: %s", name)
+	}
+	timeline, err := t.novelManager.GetTimeline()
+	if err != nil {
+		return "", err
+	}
+	
+	day, ok := timeline.ChapterDay(chapter)
+	if !ok {
+		return "", fmt.Errorf("chapter %d has no timeline events before it, add events with add_timeline_event first", chapter)
+	}
+	age, ok := timeline.AgeAt(char, chapter)
+	if !ok {
+		return "", fmt.Errorf("cannot determine %s's age: set birth_date, or age together with a timeline event in the first_appeared chapter", name)
+	}
+	
+	return fmt.Sprintf("🎂 第%d章（%s）时，%s %d 岁", chapter, timeline.Calendar.FormatDay(day), name, age), nil
+}
+
+// PlantForeshadowingTool - 埋下伏笔
+type PlantForeshadowingTool struct {
+	novelManager *novel.NovelManager
+}
+
+func (t *PlantForeshadowingTool) Name() string { return "plant_foreshadowing" }
+func (t *PlantForeshadowingTool) Description() string {
+	return "在某条情节线上登记一个伏笔：埋下的章节、计划回收的章节窗口，以及关联的角色和道具。临近或超过回收窗口时，章节写作上下文会自动提醒。"
+}
+
+func (t *PlantForeshadowingTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	plotLine := stringParam(params, "plot_line")
+	description := stringParam(params, "description")
+	if plotLine == "" || description == "" {
+		return "", fmt.Errorf("plot_line and description parameters are required")
+	}
+	
+	planted := intParam(params, "planted_chapter")
+	if planted == 0 {
+		planted = t.novelManager.CurrentChapterNumber()
+	}
+	item := &novel.Foreshadowing{
+		Description:    description,
+		PlantedChapter: planted,
+		PayoffFrom:     intParam(params, "payoff_from"),
+		PayoffTo:       intParam(params, "payoff_to"),
+		Characters:     splitListParam(stringParam(params, "characters")),
+		Items:          splitListParam(stringParam(params, "items")),
+		Note:           stringParam(params, "note"),
+	}
+	id, err := t.novelManager.PlantForeshadowing(plotLine, item)
+	if err != nil {
+		return "", fmt.Errorf("failed to plant foreshadowing: %w", err)
+	}
+	
+	return fmt.Sprintf("🌱 已登记伏笔 %s\n%s", id, novel.FormatForeshadowing([]*novel.ForeshadowingEntry{{PlotLine: plotLine, Foreshadowing: item}}, planted)), nil
+}
+
+// UpdateForeshadowingTool - 更新伏笔状态
+type UpdateForeshadowingTool struct {
+	novelManager *novel.NovelManager
+}
+
+func (t *UpdateForeshadowingTool) Name() string { return "update_foreshadowing" }
+func (t *UpdateForeshadowingTool) Description() string {
+	return "更新伏笔状态：paid 标记已在某章回收，hinted 记录在某章再次暗示，abandoned 标记放弃该伏笔。"
+}
+
+func (t *UpdateForeshadowingTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	id := stringParam(params, "id")
+	if id == "" {
+		return "", fmt.Errorf("id parameter is required")
+	}
+	status := stringParam(params, "status")
+	if status == "" {
+		status = novel.ForeshadowPaid
+	}
+	chapter := intParam(params, "chapter")
+	if chapter == 0 && status != novel.ForeshadowAbandoned {
+		chapter = t.novelManager.CurrentChapterNumber()
+	}
+	
+	entry, err := t.novelManager.UpdateForeshadowing(id, status, chapter, stringParam(params, "note"))
+	if err != nil {
+		return "", fmt.Errorf("failed to update foreshadowing: %w", err)
+	}
+	
+	return "✅ 伏笔已更新\n" + novel.FormatForeshadowing([]*novel.ForeshadowingEntry{entry}, chapter), nil
+}
+
+// ListForeshadowingTool - 列出伏笔
+type ListForeshadowingTool struct {
+	novelManager *novel.NovelManager
+}
+
+func (t *ListForeshadowingTool) Name() string { return "list_foreshadowing" }
+func (t *ListForeshadowingTool) Description() string {
+	return "列出已登记的伏笔，可按状态过滤，或只列出截至某章已超过回收窗口仍未回收的伏笔（overdue_only=true）。"
+}
+
+func (t *ListForeshadowingTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	chapter := intParam(params, "chapter")
+	if chapter == 0 {
+		chapter = t.novelManager.CurrentChapterNumber()
+	}
+	overdueOnly, _ := params["overdue_only"].(bool)
+	
+	entries := t.novelManager.ListForeshadowing(stringParam(params, "status"))
+	title := "🌱 === 伏笔列表 ==="
+	if overdueOnly {
+		title = fmt.Sprintf("⏰ === 截至第%d章超期未回收的伏笔 ===", chapter)
+		overdue := make([]*novel.ForeshadowingEntry, 0)
+		for _, entry := range entries {
+			if entry.Overdue(chapter) {
+				overdue = append(overdue, entry)
+			}
+		}
+		entries = overdue
+	}
+	
+	return title + "\n\n" + novel.FormatForeshadowing(entries, chapter), nil
+}
+
+// SetRelationshipTool - 记录人物关系变化
+type SetRelationshipTool struct {
+	novelManager *novel.NovelManager
+}
+
+func (t *SetRelationshipTool) Name() string { return "set_relationship" }
+func (t *SetRelationshipTool) Description() string {
+	return "记录人物关系及其变化：from 视 to 为 type（如 林动 视 岩老 为“师父”），从 since_chapter 章起生效。关系会随章节演变（对手→盟友→恋人），旧状态保留为历史。可用 reverse_type 同时记录反向关系。"
+}
+
+func (t *SetRelationshipTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	edge := novel.RelationshipEdge{
+		From:         stringParam(params, "from"),
+		To:           stringParam(params, "to"),
+		Type:         stringParam(params, "type"),
+		SinceChapter: intParam(params, "since_chapter"),
+		Note:         stringParam(params, "note"),
+	}
+	if err := t.novelManager.SetRelationship(edge); err != nil {
+		return "", fmt.Errorf("failed to set relationship: %w", err)
+	}
+	
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("🔗 %s → %s: %s（自第%d章起）\n", edge.From, edge.To, edge.Type, edge.SinceChapter))
+	if reverse := stringParam(params, "reverse_type"); reverse != "" {
+		back := edge
+		back.From, back.To, back.Type = edge.To, edge.From, reverse
+		if err := t.novelManager.SetRelationship(back); err != nil {
+			return "", fmt.Errorf("failed to set reverse relationship: %w", err)
+		}
+		result.WriteString(fmt.Sprintf("🔗 %s → %s: %s（自第%d章起）\n", back.From, back.To, back.Type, back.SinceChapter))
+	}
+	
+	graph, err := t.novelManager.RelationshipGraph()
+	if err != nil {
+		return "", err
+	}
+	for _, conflict := range graph.Conflicts() {
+		if (conflict.A == edge.From && conflict.B == edge.To) || (conflict.A == edge.To && conflict.B == edge.From) {
+			result.WriteString(fmt.Sprintf("⚠️ 第%d章起: %s\n", conflict.Chapter, conflict.Message))
+		}
+	}
+	return result.String(), nil
+}
+
+// QueryRelationshipTool - 查询某章时两人的关系
+type QueryRelationshipTool struct {
+	novelManager *novel.NovelManager
+}
+
+func (t *QueryRelationshipTool) Name() string { return "query_relationship" }
+func (t *QueryRelationshipTool) Description() string {
+	return "查询两个角色在指定章节时的双向关系，以及两人关系的完整变化历史。不指定章节时按当前章节查询。"
+}
+
+func (t *QueryRelationshipTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	a, b := stringParam(params, "a"), stringParam(params, "b")
+	if a == "" || b == "" {
+		return "", fmt.Errorf("a and b parameters are required")
+	}
+	chapter := intParam(params, "chapter")
+	if chapter == 0 {
+		chapter = t.novelManager.CurrentChapterNumber()
+	}
+	
+	graph, err := t.novelManager.RelationshipGraph()
+	if err != nil {
+		return "", err
+	}
+	
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("🔗 === 第%d章时 %s 与 %s 的关系 ===\n", chapter, a, b))
+	for _, pair := range [][2]string{{a, b}, {b, a}} {
+		if edge := graph.At(pair[0], pair[1], chapter); edge != nil {
+			result.WriteString(fmt.Sprintf("%s 视 %s 为: %s（自第%d章起）\n", pair[0], pair[1], edge.Type, edge.SinceChapter))
+		} else {
+			result.WriteString(fmt.Sprintf("%s 视 %s 为: （未记录）\n", pair[0], pair[1]))
+		}
+	}
+	
+	result.WriteString("\n变化历史:\n")
+	empty := true
+	for _, pair := range [][2]string{{a, b}, {b, a}} {
+		for _, edge := range graph.History(pair[0], pair[1]) {
+			empty = false
+			result.WriteString(fmt.Sprintf("  第%d章起 %s → %s: %s", edge.SinceChapter, edge.From, edge.To, edge.Type))
+			if edge.Note != "" {
+				result.WriteString("（" + edge.Note + "）")
+			}
+			result.WriteString("\n")
+		}
+	}
+	if empty {
+		result.WriteString("  （无）\n")
+	}
+	return result.String(), nil
+}
+
+// ExportRelationshipGraphTool - 导出人物关系图
+type ExportRelationshipGraphTool struct {
+	novelManager *novel.NovelManager
+}
+
+func (t *ExportRelationshipGraphTool) Name() string { return "export_relationship_graph" }
+func (t *ExportRelationshipGraphTool) Description() string {
+	return "导出某章时的人物关系图，格式为 Graphviz DOT 或 Mermaid，敌对关系会特别标出，同时列出双方称谓互相矛盾的关系。指定 output_path 时写入文件。"
+}
+
+func (t *ExportRelationshipGraphTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	chapter := intParam(params, "chapter")
+	if chapter == 0 {
+		chapter = t.novelManager.CurrentChapterNumber()
+	}
+	graph, err := t.novelManager.RelationshipGraph()
+	if err != nil {
+		return "", err
+	}
+	
+	format := stringParam(params, "format")
+	var content string
+	switch format {
+	case "", "mermaid":
+		format = "mermaid"
+		content = graph.FormatMermaid(chapter)
+	case "dot":
+		content = graph.FormatDOT(chapter)
+	default:
+		return "", fmt.Errorf("unsupported graph format: %s", format)
+	}
+	
+	var result strings.Builder
+	if outputPath := stringParam(params, "output_path"); outputPath != "" {
+		if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+			return "", fmt.Errorf("failed to create output directory: %w", err)
+		}
+		if err := os.WriteFile(outputPath, []byte(content), 0644); err != nil {
+			return "", fmt.Errorf("failed to write graph: %w", err)
+		}
+		result.WriteString(fmt.Sprintf("🕸️ 第%d章人物关系图已导出: %s\n", chapter, outputPath))
+	} else {
+		result.WriteString(fmt.Sprintf("🕸️ 第%d章人物关系图:\n```%s\n%s```\n", chapter, format, content))
+	}
+	
+	for _, conflict := range graph.Conflicts() {
+		if conflict.Chapter <= chapter {
+			result.WriteString(fmt.Sprintf("⚠️ 第%d章起: %s\n", conflict.Chapter, conflict.Message))
+		}
+	}
+	return result.String(), nil
+}
+
+// GenerateChapterTool - 按大纲分阶段生成章节
+type GenerateChapterTool struct {
+	novelManager *novel.NovelManager
+	aiClient     *ai.Client
+}
+
+func (t *GenerateChapterTool) Name() string { return "generate_chapter" }
+func (t *GenerateChapterTool) Description() string {
+	return "按本章大纲分阶段生成章节：组装上下文（大纲、上一章结尾、出场角色、写作风格）→ 场景计划 → 逐场景起草 → 一致性检查 → 写入章节文件（状态 draft）。每个阶段的结果保存在 pipeline/chapter_NNN.json，中断后再次调用从未完成的阶段继续；show 查看进度或某阶段的产出，until 只执行到指定阶段，rerun 从指定阶段重做。"
+}
+
+func (t *GenerateChapterTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	chapter := intParam(params, "chapter")
+	if chapter <= 0 {
+		return "", fmt.Errorf("chapter is required")
+	}
+	
+	if show := stringParam(params, "show"); show != "" {
+		run, err := t.novelManager.LoadPipelineRun(chapter)
+		if err != nil {
+			return "", err
+		}
+		if show == "status" {
+			return run.Format(), nil
+		}
+		return run.FormatStage(show), nil
+	}
+	
+	restart, _ := params["restart"].(bool)
+	overwrite, _ := params["overwrite"].(bool)
+	run, err := t.novelManager.RunPipeline(ctx, t.aiClient, chapter, novel.PipelineOptions{
+		Beat:        stringParam(params, "beat"),
+		Title:       stringParam(params, "title"),
+		TargetWords: intParam(params, "target_words"),
+		Until:       stringParam(params, "until"),
+		Rerun:       stringParam(params, "rerun"),
+		Restart:     restart,
+		Overwrite:   overwrite,
+	})
+	if run == nil {
+		return "", err
+	}
+	
+	var result strings.Builder
+	result.WriteString(run.Format())
+	if err != nil {
+		result.WriteString(fmt.Sprintf("\n❌ 生成中断: %v\n", err))
+		return result.String(), nil
+	}
+	if run.Done(novel.StageCheck) && run.IssueCount() > 0 {
+		result.WriteString("\n")
+		result.WriteString(run.FormatStage(novel.StageCheck))
+	}
+	if run.Done(novel.StageWrite) {
+		result.WriteString(fmt.Sprintf("\n📄 已写入 %s\n", t.novelManager.ChapterFilePath(chapter)))
+	}
+	return result.String(), nil
+}
+
+// StyleProfileTool - 文风画像与偏离检查
+type StyleProfileTool struct {
+	novelManager *novel.NovelManager
+}
+
+func (t *StyleProfileTool) Name() string { return "style_profile" }
+func (t *StyleProfileTool) Description() string {
+	return "文风画像：action=build 分析样本章节（句长分布、对话占比、叙述人称、四字成语密度、常用表达）生成画像，之后生成章节时自动写入提示词；action=show 查看画像；action=drift 给指定章节打文风贴合度并列出偏离的指标。"
+}
+
+func (t *StyleProfileTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	switch action := stringParam(params, "action"); action {
+	case "build":
+		chapters, err := parseChapterList(stringParam(params, "chapters"))
+		if err != nil {
+			return "", err
+		}
+		profile, err := t.novelManager.BuildStyleProfile(chapters)
+		if err != nil {
+			return "", fmt.Errorf("failed to build style profile: %w", err)
+		}
+		return profile.Format(), nil
+	case "", "show":
+		profile, ok := t.novelManager.StyleProfile()
+		if !ok {
+			return "还没有文风画像，请先用 action=build 分析样本章节", nil
+		}
+		return profile.Format(), nil
+	case "drift":
+		chapter := intParam(params, "chapter")
+		if chapter <= 0 {
+			return "", fmt.Errorf("chapter is required for drift")
+		}
+		drift, err := t.novelManager.StyleDriftReport(chapter)
+		if err != nil {
+			return "", err
+		}
+		return drift.Format(), nil
+	default:
+		return "", fmt.Errorf("unknown action: %s", action)
+	}
+}
+
+// POVCheckTool - 视角与时态检查
+type POVCheckTool struct {
+	novelManager *novel.NovelManager
+}
+
+func (t *POVCheckTool) Name() string { return "check_pov" }
+func (t *POVCheckTool) Description() string {
+	return "检查章节正文的叙述视角与时态：第三人称有限视角下进入非视角人物内心（视角跳跃）、第三人称叙述混入“我”、第一人称写出他人内心、叙述时间词与时态设定不符，问题定位到段落。可用 pov_character 为章节指定视角人物。"
+}
+
+func (t *POVCheckTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	fromChapter := intParam(params, "from_chapter")
+	toChapter := intParam(params, "to_chapter")
+	chapter := intParam(params, "chapter")
+	if chapter > 0 {
+		fromChapter, toChapter = chapter, chapter
+	}
+
+	var result strings.Builder
+	if pov := stringParam(params, "pov_character"); pov != "" {
+		if chapter <= 0 {
+			return "", fmt.Errorf("chapter is required when setting pov_character")
+		}
+		if pov == "none" {
+			pov = ""
+		}
+		if err := t.novelManager.SetChapterPOV(chapter, pov); err != nil {
+			return "", err
+		}
+		if pov == "" {
+			result.WriteString(fmt.Sprintf("✅ 已清除第%d章的视角人物\n\n", chapter))
+		} else {
+			result.WriteString(fmt.Sprintf("✅ 第%d章视角人物: %s\n\n", chapter, pov))
+		}
+	}
+
+	report, err := t.novelManager.CheckPOV(fromChapter, toChapter)
+	if err != nil {
+		return "", fmt.Errorf("pov check failed: %w", err)
+	}
+	result.WriteString(report.Format())
+	return result.String(), nil
+}
+
+// CharacterAppearancesTool - 角色出场统计
+type CharacterAppearancesTool struct {
+	novelManager *novel.NovelManager
+}
+
+func (t *CharacterAppearancesTool) Name() string { return "character_appearances" }
+func (t *CharacterAppearancesTool) Description() string {
+	return "扫描章节正文中的角色名、别名与称谓（如师兄、少主、老祖），统计每个角色的出场章节，列出久未出场和从未出场的角色。出场记录在每轮对话后按变化的章节自动更新；可用 character + aliases 设置角色别名。"
+}
+
+func (t *CharacterAppearancesTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	var result strings.Builder
+	if name := stringParam(params, "character"); name != "" {
+		if _, ok := params["aliases"]; !ok {
+			return "", fmt.Errorf("aliases is required when character is given")
+		}
+		aliases := stringParam(params, "aliases")
+		if aliases == "none" {
+			aliases = ""
+		}
+		if err := t.novelManager.SetCharacterAliases(name, splitListParam(aliases)); err != nil {
+			return "", err
+		}
+		if char, ok := t.novelManager.GetCharacter(name); ok && len(char.Aliases) > 0 {
+			result.WriteString(fmt.Sprintf("✅ %s 的别名: %s\n\n", name, strings.Join(char.Aliases, "、")))
+		} else {
+			result.WriteString(fmt.Sprintf("✅ 已清空 %s 的别名\n\n", name))
+		}
+	}
+
+	report, err := t.novelManager.AppearanceReport(intParam(params, "absent_after"))
+	if err != nil {
+		return "", fmt.Errorf("failed to track appearances: %w", err)
+	}
+	result.WriteString(report.Format())
+	return result.String(), nil
+}
+
+// AnalyzeDialogueTool - 台词提取与人物说话习惯检查
+type AnalyzeDialogueTool struct {
+	novelManager *novel.NovelManager
+	aiClient     *ai.Client
+}
+
+func (t *AnalyzeDialogueTool) Name() string { return "analyze_dialogue" }
+func (t *AnalyzeDialogueTool) Description() string {
+	return "提取章节中「」“”\"\"引号内的台词，按说话标记、同段动作和轮流对话推断说话人（可选 use_ai 由模型补判）。action=extract 更新每个角色的说话习惯（自称、文雅或随意、口头禅）和代表台词；action=voice 对照此前章节检查指定章节的台词是否换了自称、语气突变或丢了口头禅。"
+}
+
+func (t *AnalyzeDialogueTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	fromChapter := intParam(params, "from_chapter")
+	toChapter := intParam(params, "to_chapter")
+	if chapter := intParam(params, "chapter"); chapter > 0 {
+		fromChapter, toChapter = chapter, chapter
+	}
+	useAI, _ := params["use_ai"].(bool)
+
+	action := stringParam(params, "action")
+	extractFrom := fromChapter
+	switch action {
+	case "extract":
+	case "voice":
+		if fromChapter <= 0 {
+			return "", fmt.Errorf("chapter or from_chapter is required for voice")
+		}
+		if toChapter < fromChapter {
+			toChapter = fromChapter
+		}
+		// 比对需要此前章节的台词作为基准
+		extractFrom = 0
+	default:
+		return "", fmt.Errorf("unknown action: %s", action)
+	}
+
+	dialogues, err := t.novelManager.ExtractDialogues(extractFrom, toChapter)
+	if err != nil {
+		return "", fmt.Errorf("failed to extract dialogues: %w", err)
+	}
+
+	var notes strings.Builder
+	if useAI {
+		targets := make([]int, 0)
+		for _, chapter := range dialogues.Checked {
+			if chapter >= fromChapter {
+				targets = append(targets, chapter)
+			}
+		}
+		if len(targets) > maxAIConsistencyChapters {
+			notes.WriteString(fmt.Sprintf("\n💡 模型补判每次最多 %d 章，请用 chapter 或 from_chapter/to_chapter 缩小范围\n", maxAIConsistencyChapters))
+		} else {
+			for _, chapter := range targets {
+				if _, err := t.novelManager.AttributeDialoguesWithAI(ctx, t.aiClient, dialogues, chapter); err != nil {
+					notes.WriteString(fmt.Sprintf("\n⚠️ 第%d章模型补判失败: %v\n", chapter, err))
+				}
+			}
+		}
+	}
+
+	if action == "voice" {
+		report, err := t.novelManager.CheckVoice(dialogues, fromChapter, toChapter)
+		if err != nil {
+			return "", err
+		}
+		return report.Format() + notes.String(), nil
+	}
+
+	updated, err := t.novelManager.SaveDialogues(dialogues)
+	if err != nil {
+		return "", fmt.Errorf("failed to save dialogues: %w", err)
+	}
+	var result strings.Builder
+	result.WriteString(dialogues.Format())
+	if len(updated) > 0 {
+		result.WriteString(fmt.Sprintf("\n✅ 已更新说话习惯与代表台词: %s\n", strings.Join(updated, "、")))
+	}
+	result.WriteString(notes.String())
+	return result.String(), nil
+}
+
+// AnalyzePacingTool - 章节节奏分析
+type AnalyzePacingTool struct {
+	novelManager *novel.NovelManager
+	aiClient     *ai.Client
+}
+
+func (t *AnalyzePacingTool) Name() string { return "analyze_pacing" }
+func (t *AnalyzePacingTool) Description() string {
+	return "分析章节节奏：每章字数、对话与叙述占比、场景数、冲突密度、章末是否留有钩子（规则打分，可选 use_ai 由模型判断）和情感基调（写入章节信息），并给出连续无钩子、冲突偏少等提醒。输出表格与 ASCII 条形图，或 CSV 数据。"
+}
+
+func (t *AnalyzePacingTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	fromChapter := intParam(params, "from_chapter")
+	toChapter := intParam(params, "to_chapter")
+	if chapter := intParam(params, "chapter"); chapter > 0 {
+		fromChapter, toChapter = chapter, chapter
+	}
+
+	report, err := t.novelManager.AnalyzePacing(fromChapter, toChapter)
+	if err != nil {
+		return "", fmt.Errorf("pacing analysis failed: %w", err)
+	}
+	var notes strings.Builder
+	if useAI, _ := params["use_ai"].(bool); useAI {
+		if err := t.novelManager.JudgeHooksWithAI(ctx, t.aiClient, report); err != nil {
+			notes.WriteString(fmt.Sprintf("\n⚠️ 模型判断章末钩子失败，以下为规则打分: %v\n", err))
+		}
+	}
+
+	if outputPath := stringParam(params, "output_path"); outputPath != "" {
+		if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+			return "", fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(outputPath, []byte(report.CSV()), 0644); err != nil {
+			return "", fmt.Errorf("failed to write csv: %w", err)
+		}
+		notes.WriteString(fmt.Sprintf("\n📄 CSV 已写入 %s\n", outputPath))
+	}
+
+	if stringParam(params, "format") == "csv" {
+		return report.CSV() + notes.String(), nil
+	}
+	var result strings.Builder
+	result.WriteString(report.Format())
+	if chart := stringParam(params, "chart"); chart != "none" {
+		result.WriteString(report.Chart(chart))
+	}
+	result.WriteString(notes.String())
+	return result.String(), nil
+}
+
+// OutlineTemplateTool - 大纲节拍模板
+type OutlineTemplateTool struct {
+	novelManager *novel.NovelManager
+}
+
+func (t *OutlineTemplateTool) Name() string { return "outline_template" }
+func (t *OutlineTemplateTool) Description() string {
+	return "全书结构模板：三幕结构、救猫咪节拍表、起承转合、升级流，可在配置目录的 templates/beats 下添加 YAML 模板。list 列出模板；apply 按全书章节数把各节拍映射到章节范围并写入项目大纲；status 查看各节拍的写作进度（如“中点反转计划于第45章，尚未写到”）；adjust 调整节拍的章节范围或写下具体安排。生成章节时会附带本章所处的节拍。"
+}
+
+func (t *OutlineTemplateTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	templates, warnings := novel.LoadBeatTemplates(beatTemplateDir())
+
+	switch action := stringParam(params, "action"); action {
+	case "list":
+		return formatBeatTemplates(templates, warnings, t.novelManager.ProjectOutline()), nil
+	case "apply":
+		var template *novel.BeatTemplate
+		if name := stringParam(params, "template"); name != "" {
+			if template = novel.FindBeatTemplate(templates, name); template == nil {
+				return "", fmt.Errorf("beat template not found: %s", name)
+			}
+		} else if preview := t.novelManager.SuggestOutline(templates); preview != nil {
+			template = novel.FindBeatTemplate(templates, preview.Template)
+		}
+		if template == nil {
+			return "", fmt.Errorf("no beat templates available")
+		}
+		total := intParam(params, "total_chapters")
+		if total <= 0 {
+			total = t.novelManager.EstimateTotalChapters()
+		}
+		overwrite, _ := params["overwrite"].(bool)
+		if _, err := t.novelManager.ApplyBeatTemplate(template, total, overwrite); err != nil {
+			return "", fmt.Errorf("failed to apply beat template: %w", err)
+		}
+		coverage, err := t.novelManager.OutlineCoverage(nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to check outline coverage: %w", err)
+		}
+		return fmt.Sprintf("✅ 已按「%s」生成 %d 章的全书大纲\n\n%s", template.Title, total, coverage.Format()), nil
+	case "status":
+		coverage, err := t.novelManager.OutlineCoverage(nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to check outline coverage: %w", err)
+		}
+		return coverage.Format(), nil
+	case "adjust":
+		name := stringParam(params, "beat")
+		if name == "" {
+			return "", fmt.Errorf("beat is required for adjust")
+		}
+		beat, err := t.novelManager.AdjustBeat(name, intParam(params, "from_chapter"), intParam(params, "to_chapter"), stringParam(params, "note"))
+		if err != nil {
+			return "", fmt.Errorf("failed to adjust beat: %w", err)
+		}
+		result := fmt.Sprintf("✅ 节拍「%s」调整为%s", beat.Name, beat.Span())
+		if beat.Note != "" {
+			result += fmt.Sprintf("\n安排: %s", beat.Note)
+		}
+		return result, nil
+	default:
+		return "", fmt.Errorf("unknown action: %s", action)
+	}
+}
+
+// formatBeatTemplates 列出可用的节拍模板，标出项目当前使用的模板
+func formatBeatTemplates(templates []*novel.BeatTemplate, warnings []string, current *novel.BookOutline) string {
+	var result strings.Builder
+	result.WriteString("📐 === 大纲节拍模板 ===\n\n")
+	for _, template := range templates {
+		marker := "•"
+		if current != nil && current.Template == template.Name {
+			marker = "✅"
+		}
+		result.WriteString(fmt.Sprintf("%s %s（%s）: %d 个节拍", marker, template.Title, template.Name, len(template.Beats)))
+		if len(template.Genres) > 0 {
+			result.WriteString(fmt.Sprintf("，适用题材: %s", strings.Join(template.Genres, "、")))
+		}
+		if template.Source != "builtin" {
+			result.WriteString(fmt.Sprintf("，来自 %s", template.Source))
+		}
+		result.WriteString("\n")
+		if template.Description != "" {
+			result.WriteString(fmt.Sprintf("   %s\n", template.Description))
+		}
+	}
+	if dir := beatTemplateDir(); dir != "" {
+		result.WriteString(fmt.Sprintf("\n💡 自定义模板放在 %s（YAML，同名覆盖内置模板）\n", dir))
+	}
+	for _, warning := range warnings {
+		result.WriteString(fmt.Sprintf("⚠️ 无法加载模板 %s\n", warning))
+	}
+	return result.String()
+}
+
+// GlossaryTool - 术语表
+type GlossaryTool struct {
+	novelManager *novel.NovelManager
+}
+
+func (t *GlossaryTool) Name() string { return "glossary" }
+func (t *GlossaryTool) Description() string {
+	return "管理境界、功法、法宝、地名等自创名词的术语表，并检查正文中的误写：已登记的错误写法、同音字（如“筑基”写成“筑机”）、错一字或字序颠倒。生成章节时会附带术语表。确属其他含义的词可用 ignore 加入例外，批量修正用 fix_glossary。"
+}
+
+func (t *GlossaryTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	term := stringParam(params, "term")
+	switch action := stringParam(params, "action"); action {
+	case "add":
+		created, err := t.novelManager.AddGlossaryTerm(novel.GlossaryTerm{
+			Term:        term,
+			Category:    stringParam(params, "category"),
+			Description: stringParam(params, "description"),
+			Variants:    splitListParam(stringParam(params, "variants")),
+			Allowed:     splitListParam(stringParam(params, "allowed")),
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to add glossary term: %w", err)
+		}
+		if created {
+			return fmt.Sprintf("✅ 已添加术语: %s", term), nil
+		}
+		return fmt.Sprintf("✅ 已更新术语: %s", term), nil
+	case "remove":
+		if err := t.novelManager.RemoveGlossaryTerm(term); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("🗑 已删除术语: %s", term), nil
+	case "list":
+		return novel.FormatGlossary(t.novelManager.GlossaryTerms()), nil
+	case "lint":
+		fromChapter, toChapter := intParam(params, "from_chapter"), intParam(params, "to_chapter")
+		if chapter := intParam(params, "chapter"); chapter > 0 {
+			fromChapter, toChapter = chapter, chapter
+		}
+		report, err := t.novelManager.LintGlossary(fromChapter, toChapter)
+		if err != nil {
+			return "", fmt.Errorf("glossary lint failed: %w", err)
+		}
+		return report.Format(), nil
+	case "ignore":
+		words := splitListParam(stringParam(params, "allowed"))
+		if len(words) == 0 {
+			return "", fmt.Errorf("allowed is required for ignore")
+		}
+		if err := t.novelManager.AllowGlossaryWords(term, words); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("✅ 检查「%s」时不再报告: %s", term, strings.Join(words, "、")), nil
+	default:
+		return "", fmt.Errorf("unknown action: %s", action)
+	}
+}
+
+// FixGlossaryTool - 术语批量修正
+type FixGlossaryTool struct {
+	novelManager *novel.NovelManager
+}
+
+func (t *FixGlossaryTool) Name() string { return "fix_glossary" }
+func (t *FixGlossaryTool) Description() string {
+	return "把章节正文中检出的术语误写批量替换为标准写法。默认只预览，apply=true 时写入；默认修正已登记的错误写法和同音误写，疑似错字需在 words 中明确指定。修正过的写法会登记到术语表，之后直接按错误写法检出。"
+}
+
+func (t *FixGlossaryTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	fromChapter, toChapter := intParam(params, "from_chapter"), intParam(params, "to_chapter")
+	if chapter := intParam(params, "chapter"); chapter > 0 {
+		fromChapter, toChapter = chapter, chapter
+	}
+	apply, _ := params["apply"].(bool)
+	result, err := t.novelManager.FixGlossary(fromChapter, toChapter, splitListParam(stringParam(params, "words")), apply)
+	if err != nil {
+		return "", fmt.Errorf("glossary fix failed: %w", err)
+	}
+	return result.Format(), nil
+}
+
+const (
+	// 起名的默认候选数
+	defaultNameCandidates = 10
+	// use_ai 时交给模型挑选的候选数是最终数量的倍数
+	aiNameCandidateFactor = 3
+)
+
+// GenerateNamesTool - 起名
+type GenerateNamesTool struct {
+	novelManager *novel.NovelManager
+	aiClient     *ai.Client
+}
+
+func (t *GenerateNamesTool) Name() string { return "generate_names" }
+func (t *GenerateNamesTool) Description() string {
+	return "按项目题材的风格（古风、现代、科幻）离线生成人名、地名、宗门组织名（如以宗、门、阁结尾）和法宝器物名，自动排除与已有角色、别名、世界观设定、术语重名、互相包含、同音或只差一字的候选。可指定性别、姓氏和必带的字；use_ai 时由模型挑选润色并说明寓意。"
+}
+
+func (t *GenerateNamesTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	count := intParam(params, "count")
+	if count <= 0 {
+		count = defaultNameCandidates
+	}
+	useAI, _ := params["use_ai"].(bool)
+	opts := novel.NameOptions{
+		Kind:    stringParam(params, "kind"),
+		Genre:   stringParam(params, "genre"),
+		Gender:  stringParam(params, "gender"),
+		Surname: stringParam(params, "surname"),
+		Include: stringParam(params, "include"),
+		Count:   count,
+	}
+	if useAI {
+		// 多生成一些供模型挑选
+		opts.Count = count * aiNameCandidateFactor
+	}
+	suggestions, err := t.novelManager.GenerateNames(opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate names: %w", err)
+	}
+	if useAI {
+		if err := t.novelManager.RefineNamesWithAI(ctx, t.aiClient, suggestions, stringParam(params, "hint"), count); err != nil {
+			return "", err
+		}
+	}
+	return suggestions.Format(), nil
+}
+
+// ChapterHistoryTool - 章节历史版本
+type ChapterHistoryTool struct {
+	novelManager *novel.NovelManager
+}
+
+func (t *ChapterHistoryTool) Name() string { return "chapter_history" }
+func (t *ChapterHistoryTool) Description() string {
+	return "章节的历史版本：生成、导入、术语修正写入章节时，以及每轮对话后发现章节文件被修改时，都会自动保存一个版本（时间、字数变化、来源和备注）。list 列出版本；diff 按句或按段比较两个版本，句内改动逐字标出；show 查看旧版本正文；rollback 回滚（当前正文也会保留为一个版本）；save 手动保存并写备注。"
+}
+
+func (t *ChapterHistoryTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
+	chapter := intParam(params, "chapter")
+	if chapter <= 0 {
+		return "", fmt.Errorf("chapter is required")
+	}
+	version := intParam(params, "version")
+	// 先同步，把本轮对话中刚改过的正文记入历史
+	if _, err := t.novelManager.SyncChapterStats(); err != nil {
+		return "", err
+	}
+
+	switch action := stringParam(params, "action"); action {
+	case "list":
+		versions, err := t.novelManager.ChapterVersions(chapter)
+		if err != nil {
+			return "", err
+		}
+		return novel.FormatChapterVersions(chapter, versions), nil
+	case "diff":
+		from, to := intParam(params, "from_version"), intParam(params, "to_version")
+		if from == 0 {
+			versions, err := t.novelManager.ChapterVersions(chapter)
+			if err != nil {
+				return "", err
+			}
+			from = previousVersion(versions, to)
+			if from == 0 {
+				return "", fmt.Errorf("chapter %d has no earlier version to compare", chapter)
+			}
+		}
+		diff, err := t.novelManager.DiffChapterVersions(chapter, from, to, stringParam(params, "granularity"))
+		if err != nil {
+			return "", err
+		}
+		return diff.Format(), nil
+	case "show":
+		if version <= 0 {
+			return "", fmt.Errorf("version is required for show")
+		}
+		text, err := t.novelManager.ReadChapterVersion(chapter, version)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("=== 第%d章 v%d ===\n\n%s", chapter, version, text), nil
+	case "rollback":
+		if version <= 0 {
+			return "", fmt.Errorf("version is required for rollback")
+		}
+		restored, err := t.novelManager.RollbackChapter(chapter, version)
+		if err != nil {
+			return "", fmt.Errorf("rollback failed: %w", err)
+		}
+		return fmt.Sprintf("⏪ 第%d章已回滚到 v%d（记为 v%d，%d 字）；回滚前的正文仍在历史版本中", chapter, version, restored.Number, restored.Words), nil
+	case "save":
+		saved, err := t.novelManager.SnapshotChapter(chapter, stringParam(params, "note"))
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("💾 第%d章已保存为 v%d（%d 字）", chapter, saved.Number, saved.Words), nil
+	default:
+		return "", fmt.Errorf("unknown action: %s", action)
+	}
+}
+
+// previousVersion 与 to 比较时默认的旧版本：to 为当前正文时取最新版本之前的一个（当前正文通常就是最新版本），否则取 to 的上一个
+func previousVersion(versions []novel.ChapterVersion, to int) int {
+	for i := len(versions) - 1; i > 0; i-- {
+		if to == 0 || versions[i].Number == to {
+			return versions[i-1].Number
+		}
+	}
+	return 0
+}
+
+// TurnCommitMessage 由工具调用生成提交说明：标题列出本轮的改动，正文附上用户请求；
+// 没有改动类工具调用（如手动改了文件）时以请求内容作标题
+func TurnCommitMessage(userInput string, toolResults []ToolResult) string {
+	const maxSubjectChanges = 3
+	
+	changes := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, result := range toolResults {
+		if result.Error != nil || result.Summary == "" || seen[result.Summary] {
+			continue
+		}
+		seen[result.Summary] = true
+		changes = append(changes, result.Summary)
+	}
+	
+	request := strings.Join(strings.Fields(userInput), " ")
+	var subject string
+	switch {
+	case len(changes) == 0:
+		subject = "对话修改: " + truncateRunes(request, 40)
+	case len(changes) <= maxSubjectChanges:
+		subject = strings.Join(changes, "；")
+	default:
+		subject = fmt.Sprintf("%s 等%d项改动", strings.Join(changes[:maxSubjectChanges], "；"), len(changes))
+	}
+	
+	var message strings.Builder
+	message.WriteString(subject + "\n\n")
+	message.WriteString("请求: " + truncateRunes(request, 200) + "\n")
+	if len(changes) > maxSubjectChanges {
+		message.WriteString("\n")
+		for _, change := range changes {
+			message.WriteString("- " + change + "\n")
+		}
+	}
+	return message.String()
+}
+
+// describeToolCall 用一句话描述工具调用做的改动，供自动提交生成说明；只读工具返回空
+func describeToolCall(name string, params map[string]interface{}) string {
+	chapter := intParam(params, "chapter")
+	action := stringParam(params, "action")
+	switch name {
+	case "write_file":
+		return "写入 " + stringParam(params, "file_path")
+	case "edit_file", "replace_text":
+		return "修改 " + stringParam(params, "file_path")
+	case "delete_file":
+		return "删除 " + stringParam(params, "path")
+	case "rename_file":
+		return fmt.Sprintf("重命名 %s → %s", stringParam(params, "old_path"), stringParam(params, "new_path"))
+	case "move_file":
+		return fmt.Sprintf("移动 %s → %s", stringParam(params, "src_path"), stringParam(params, "dst_path"))
+	case "copy_file":
+		return fmt.Sprintf("复制 %s → %s", stringParam(params, "src_path"), stringParam(params, "dst_path"))
+	case "create_directory":
+		return "创建目录 " + stringParam(params, "path")
+	case "execute_command":
+		return "执行命令 " + truncateRunes(stringParam(params, "command"), 40)
+	case "init_novel_project":
+		return fmt.Sprintf("创建小说项目《%s》", stringParam(params, "title"))
+	case "add_character":
+		return "添加角色 " + stringParam(params, "name")
+	case "add_plot_line":
+		return "添加情节线 " + stringParam(params, "name")
+	case "add_timeline_event":
+		return "添加时间线事件 " + stringParam(params, "title")
+	case "set_story_calendar":
+		return "设置故事历法"
+	case "plant_foreshadowing":
+		return "埋下伏笔 " + truncateRunes(stringParam(params, "description"), 20)
+	case "update_foreshadowing":
+		return "更新伏笔 " + stringParam(params, "id")
+	case "set_relationship":
+		return fmt.Sprintf("设置关系 %s → %s（%s）", stringParam(params, "from"), stringParam(params, "to"), stringParam(params, "type"))
+	case "import_manuscript":
+		return "导入文稿 " + filepath.Base(stringParam(params, "file_path"))
+	case "export_novel":
+		return "导出小说 " + stringParam(params, "format")
+	case "generate_chapter":
+		return fmt.Sprintf("生成第%d章", chapter)
+	case "outline_template":
+		if action == "apply" || action == "adjust" {
+			return "大纲模板 " + action + " " + stringParam(params, "template")
+		}
+	case "style_profile":
+		if action == "build" {
+			return "生成文风画像"
+		}
+	case "glossary":
+		if action == "add" || action == "remove" || action == "ignore" {
+			return fmt.Sprintf("术语表 %s %s", action, stringParam(params, "term"))
+		}
+	case "fix_glossary":
+		if apply, _ := params["apply"].(bool); apply {
+			return "统一术语写法"
+		}
+	case "chapter_history":
+		switch action {
+		case "rollback":
+			return fmt.Sprintf("第%d章回滚到 v%d", chapter, intParam(params, "version"))
+		case "save":
+			return fmt.Sprintf("保存第%d章版本", chapter)
+		}
+	}
+	return ""
+}
+
+// intParam 读取整数参数，兼容JSON数字和数字字符串
+func intParam(params map[string]interface{}, key string) int {
+	switch v := params[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case string:
+		n, _ := strconv.Atoi(strings.TrimSpace(v))
+		return n
+	}
+	return 0
+}
+
+// stringParam 读取字符串参数并去除首尾空白
+func stringParam(params map[string]interface{}, key string) string {
+	v, _ := params[key].(string)
+	return strings.TrimSpace(v)
+}
+
+// parseChapterList 解析章节列表，如 "1-10,15"；空字符串返回 nil
+func parseChapterList(value string) ([]int, error) {
+	chapters := make([]int, 0)
+	for _, item := range splitListParam(value) {
+		bounds := strings.SplitN(item, "-", 2)
+		from, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid chapter: %s", item)
+		}
+		to := from
+		if len(bounds) == 2 {
+			if to, err = strconv.Atoi(strings.TrimSpace(bounds[1])); err != nil || to < from {
+				return nil, fmt.Errorf("invalid chapter range: %s", item)
+			}
+		}
+		for n := from; n <= to; n++ {
+			chapters = append(chapters, n)
+		}
+	}
+	if len(chapters) == 0 {
+		return nil, nil
+	}
+	return chapters, nil
+}
+
+// splitListParam 按中英文逗号、顿号拆分列表参数
+func splitListParam(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == '，' || r == '、' || r == ';' || r == '；'
+	}) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// truncateRunes 按字符截断文本
+func truncateRunes(s string, maxRunes int) string {
+	runes := []rune(s)
+	if len(runes) <= maxRunes {
+		return s
+	}
+	return string(runes[:maxRunes]) + "…"
+}
+
+// GetToolDefinitions 获取所有工具的定义，供AI模型使用
+func (m *Manager) GetToolDefinitions() []map[string]interface{} {
+	var tools []map[string]interface{}
+	
+	for name, tool := range m.tools {
+		parameters := getToolParameters(name)
+		required := getRequiredParameters(name)
+		
+		toolDef := map[string]interface{}{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        name,
+				"description": tool.Description(),
+				"parameters": map[string]interface{}{
+					"type":       "object",
+					"properties": parameters,
+					"required":   required,
+				},
+			},
+		}
+		tools = append(tools, toolDef)
+	}
+	
+	return tools
+}
+
+// getToolParameters 获取工具的参数定义
+func getToolParameters(toolName string) map[string]interface{} {
+	switch toolName {
+	case "read_file":
+		return map[string]interface{}{
+			"file_path": map[string]interface{}{
+				"type":        "string",
+				"description": "要读取的文件路径",
+			},
+		}
+	case "write_file":
+		return map[string]interface{}{
+			"file_path": map[string]interface{}{
+				"type":        "string", 
+				"description": "要写入的文件路径",
+			},
+			"content": map[string]interface{}{
+				"type":        "string",
+				"description": "要写入的文件内容",
+			},
+		}
+	case "list_files":
+		return map[string]interface{}{
+			"directory": map[string]interface{}{
+				"type":        "string",
+				"description": "要列出的目录路径（可选，默认为当前目录）",
+			},
+		}
+	case "search":
+		return map[string]interface{}{
+			"query": map[string]interface{}{
+				"type":        "string",
+				"description": "要搜索的文本内容或正则表达式",
+			},
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "搜索路径（可选，默认当前目录）",
+			},
+			"file_pattern": map[string]interface{}{
+				"type":        "string", 
+				"description": "文件匹配模式（可选，如*.txt）",
+			},
+			"use_regex": map[string]interface{}{
+				"type":        "boolean",
+				"description": "是否使用正则表达式搜索",
+			},
+			"case_sensitive": map[string]interface{}{
+				"type":        "boolean",
+				"description": "是否区分大小写",
+			},
+			"show_line_numbers": map[string]interface{}{
+				"type":        "boolean",
+				"description": "是否显示行号",
+			},
+			"max_results": map[string]interface{}{
+				"type":        "integer",
+				"description": "最大结果数量（默认50）",
+			},
+		}
+	case "execute_command":
+		return map[string]interface{}{
+			"command": map[string]interface{}{
+				"type":        "string",
+				"description": "要执行的系统命令",
+			},
+		}
+	case "file_info":
+		return map[string]interface{}{
+			"file_path": map[string]interface{}{
+				"type":        "string",
+				"description": "要获取信息的文件或目录路径",
+			},
+		}
+	case "edit_file":
+		return map[string]interface{}{
+			"file_path": map[string]interface{}{
+				"type":        "string",
+				"description": "要编辑的文件路径",
+			},
+			"start_line": map[string]interface{}{
+				"type":        "integer",
+				"description": "开始编辑的行号（可选）",
+			},
+			"end_line": map[string]interface{}{
+				"type":        "integer", 
+				"description": "结束编辑的行号（可选）",
+			},
+			"content": map[string]interface{}{
+				"type":        "string",
+				"description": "新的文件内容",
+			},
+		}
+	case "create_directory":
+		return map[string]interface{}{
+			"directory_path": map[string]interface{}{
+				"type":        "string",
+				"description": "要创建的目录路径",
+			},
+		}
+	case "delete_file":
+		return map[string]interface{}{
+			"file_path": map[string]interface{}{
+				"type":        "string",
+				"description": "要删除的文件或目录路径",
+			},
+		}
+	case "copy_file":
+		return map[string]interface{}{
+			"source_path": map[string]interface{}{
+				"type":        "string",
+				"description": "源文件路径",
+			},
+			"destination_path": map[string]interface{}{
+				"type":        "string",
+				"description": "目标文件路径",
+			},
+		}
+	case "move_file", "rename_file":
+		return map[string]interface{}{
+			"old_path": map[string]interface{}{
+				"type":        "string",
+				"description": "原文件路径",
+			},
+			"new_path": map[string]interface{}{
+				"type":        "string",
+				"description": "新文件路径",
+			},
+		}
+	case "replace_text":
+		return map[string]interface{}{
+			"file_path": map[string]interface{}{
+				"type":        "string",
+				"description": "要替换文本的文件路径",
+			},
+			"old_text": map[string]interface{}{
+				"type":        "string",
+				"description": "要被替换的文本",
+			},
+			"new_text": map[string]interface{}{
+				"type":        "string",
+				"description": "新的替换文本",
+			},
+		}
+	case "get_project_info":
+		return map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "项目路径（可选，默认为当前目录）",
+			},
+		}
+	case "smart_task_planner":
+		return map[string]interface{}{
+			"task_description": map[string]interface{}{
+				"type":        "string",
+				"description": "需要规划的任务描述",
+			},
+		}
+	case "analyze_file_relationships":
+		return map[string]interface{}{} // 无需参数，自动分析当前目录
+	case "export_novel":
+		return map[string]interface{}{
+			"format": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"epub", "markdown", "txt"},
+				"description": "导出格式",
+			},
+			"output_path": map[string]interface{}{
+				"type":        "string",
+				"description": "输出文件路径（可选，默认 export/<书名>.<扩展名>）",
+			},
+			"cover_image": map[string]interface{}{
+				"type":        "string",
+				"description": "EPUB封面图片路径（可选，支持jpg/png/gif/webp）",
+			},
+			"compact": map[string]interface{}{
+				"type":        "boolean",
+				"description": "TXT格式段落之间不留空行（默认留一个空行）",
+			},
+		}
+	case "init_novel_project":
+		return map[string]interface{}{
+			"title": map[string]interface{}{
+				"type":        "string",
+				"description": "小说标题",
+			},
+			"author": map[string]interface{}{
+				"type":        "string",
+				"description": "作者",
+			},
+			"genre": map[string]interface{}{
+				"type":        "string",
+				"description": "题材，如 玄幻、修仙、都市、悬疑、科幻、言情",
+			},
+			"template": map[string]interface{}{
+				"type":        "string",
+				"description": "题材模板名（可选，默认按题材匹配，如 xuanhuan、dushi、xuanyi、kehuan、yanqing；none 表示创建空项目）",
+			},
+		}
+	case "add_character":
+		return map[string]interface{}{
+			"name": map[string]interface{}{
+				"type":        "string",
+				"description": "角色名（已存在时更新该角色）",
+			},
+			"age": map[string]interface{}{
+				"type":        "integer",
+				"description": "年龄",
+			},
+			"gender": map[string]interface{}{
+				"type":        "string",
+				"description": "性别",
+			},
+			"occupation": map[string]interface{}{
+				"type":        "string",
+				"description": "身份/职业",
+			},
+			"personality": map[string]interface{}{
+				"type":        "string",
+				"description": "性格特点，多个用逗号或顿号分隔",
+			},
+			"appearance": map[string]interface{}{
+				"type":        "string",
+				"description": "外貌描述，如“银发紫眸，身形瘦削”",
+			},
+			"background": map[string]interface{}{
+				"type":        "string",
+				"description": "背景经历",
+			},
+			"relationships": map[string]interface{}{
+				"type":        "object",
+				"description": "初始人物关系，键为对方名字，值为对方相对本角色的身份，如 {\"岩老\": \"师父\"}；之后的关系变化用 set_relationship 记录",
+			},
+			"status": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"alive", "dead", "missing"},
+				"description": "角色状态",
+			},
+			"death_chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "死亡所在章节号",
+			},
+			"first_appeared": map[string]interface{}{
+				"type":        "integer",
+				"description": "计划的首次登场章节号（不填则按正文自动统计）",
+			},
+			"aliases": map[string]interface{}{
+				"type":        "string",
+				"description": "别名与称谓，多个用逗号或顿号分隔，如“林师兄、少主”，用于统计出场章节",
+			},
+			"archetype": map[string]interface{}{
+				"type":        "string",
+				"description": "该角色填补的题材模板角色定位，如“主角”“宿敌”（可选）",
+			},
+			"birth_date": map[string]interface{}{
+				"type":        "string",
+				"description": "故事内出生日期，如“天元100年3月5日”，用于按时间线推算年龄",
+			},
+		}
+	case "generate_chapter":
+		return map[string]interface{}{
+			"chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "要生成的章节号",
+			},
+			"beat": map[string]interface{}{
+				"type":        "string",
+				"description": "本章大纲（可选，默认使用章节概要；修改后从头重新生成）",
+			},
+			"title": map[string]interface{}{
+				"type":        "string",
+				"description": "章节标题（可选）",
+			},
+			"target_words": map[string]interface{}{
+				"type":        "integer",
+				"description": "目标字数（默认3000）",
+			},
+			"until": map[string]interface{}{
+				"type":        "string",
+				"description": "只执行到该阶段为止，便于逐步检查",
+				"enum":        []string{"context", "plan", "draft", "check", "write"},
+			},
+			"rerun": map[string]interface{}{
+				"type":        "string",
+				"description": "从该阶段起重新执行，丢弃该阶段及之后的结果",
+				"enum":        []string{"context", "plan", "draft", "check", "write"},
+			},
+			"restart": map[string]interface{}{
+				"type":        "boolean",
+				"description": "丢弃已有进度从头开始",
+			},
+			"overwrite": map[string]interface{}{
+				"type":        "boolean",
+				"description": "章节正文已存在时允许覆盖",
+			},
+			"show": map[string]interface{}{
+				"type":        "string",
+				"description": "只查看不执行：status 为进度概览，或阶段名查看该阶段产出",
+				"enum":        []string{"status", "context", "plan", "draft", "check", "write"},
+			},
+		}
+	case "style_profile":
+		return map[string]interface{}{
+			"action": map[string]interface{}{
+				"type":        "string",
+				"description": "build 生成画像，show 查看画像，drift 检查某章的文风偏离",
+				"enum":        []string{"build", "show", "drift"},
+			},
+			"chapters": map[string]interface{}{
+				"type":        "string",
+				"description": "build 时的样本章节，如“1-10,15”（默认全部已完成章节）",
+			},
+			"chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "drift 时要检查的章节号",
+			},
+		}
+	case "outline_template":
+		return map[string]interface{}{
+			"action": map[string]interface{}{
+				"type":        "string",
+				"description": "list 列出模板，apply 套用模板生成全书大纲，status 查看各节拍的写作进度，adjust 调整节拍",
+				"enum":        []string{"list", "apply", "status", "adjust"},
+			},
+			"template": map[string]interface{}{
+				"type":        "string",
+				"description": "模板名或标题，如 three_act、save_the_cat、qichengzhuanhe、shengjiliu（apply 时可选，默认按题材推荐）",
+			},
+			"total_chapters": map[string]interface{}{
+				"type":        "integer",
+				"description": "全书章节数（apply 时可选，默认按目标字数估算）",
+			},
+			"overwrite": map[string]interface{}{
+				"type":        "boolean",
+				"description": "替换已有的大纲（apply）",
+			},
+			"beat": map[string]interface{}{
+				"type":        "string",
+				"description": "要调整的节拍名（adjust）",
+			},
+			"from_chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "节拍的起始章节（adjust，可选）",
+			},
+			"to_chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "节拍的结束章节（adjust，可选）",
+			},
+			"note": map[string]interface{}{
+				"type":        "string",
+				"description": "本节拍的具体安排，如“秘境中得知父亲未死”（adjust，可选）",
+			},
+		}
+	case "glossary":
+		return map[string]interface{}{
+			"action": map[string]interface{}{
+				"type":        "string",
+				"description": "add 添加或更新术语，remove 删除，list 查看术语表，lint 检查正文中的误写，ignore 把另有含义的词加入例外",
+				"enum":        []string{"add", "remove", "list", "lint", "ignore"},
+			},
+			"term": map[string]interface{}{
+				"type":        "string",
+				"description": "术语的标准写法，如“筑基”“青云宗”（add、remove、ignore 时必填）",
+			},
+			"category": map[string]interface{}{
+				"type":        "string",
+				"description": "分类，如境界、功法、法宝、地名、组织（add，可选）",
+			},
+			"description": map[string]interface{}{
+				"type":        "string",
+				"description": "术语说明（add，可选）",
+			},
+			"variants": map[string]interface{}{
+				"type":        "string",
+				"description": "需要统一为标准写法的错误写法或旧写法，多个用逗号或顿号分隔（add，可选）",
+			},
+			"allowed": map[string]interface{}{
+				"type":        "string",
+				"description": "形近音近但另有含义、不应报告的词，多个用逗号或顿号分隔（add、ignore）",
+			},
+			"chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "只检查指定章节（lint，可选）",
+			},
+			"from_chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "起始章节号（lint，可选）",
+			},
+			"to_chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "结束章节号（lint，可选）",
+			},
+		}
+	case "fix_glossary":
+		return map[string]interface{}{
+			"words": map[string]interface{}{
+				"type":        "string",
+				"description": "只修正这些写法，多个用逗号或顿号分隔（可选；默认修正已登记的错误写法和同音误写，疑似错字需明确指定）",
+			},
+			"chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "只修正指定章节（可选）",
+			},
+			"from_chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "起始章节号（可选）",
+			},
+			"to_chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "结束章节号（可选）",
+			},
+			"apply": map[string]interface{}{
+				"type":        "boolean",
+				"description": "执行替换；默认只预览",
+			},
+		}
+	case "chapter_history":
+		return map[string]interface{}{
+			"action": map[string]interface{}{
+				"type":        "string",
+				"description": "list 列出历史版本，diff 比较两个版本，show 查看某个版本的正文，rollback 回滚到某个版本，save 把当前正文保存为一个版本",
+				"enum":        []string{"list", "diff", "show", "rollback", "save"},
+			},
+			"chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "章节号",
+			},
+			"version": map[string]interface{}{
+				"type":        "integer",
+				"description": "版本号（show、rollback 时必填）",
+			},
+			"from_version": map[string]interface{}{
+				"type":        "integer",
+				"description": "diff 的旧版本（默认当前正文的上一个版本）",
+			},
+			"to_version": map[string]interface{}{
+				"type":        "integer",
+				"description": "diff 的新版本（默认当前正文）",
+			},
+			"granularity": map[string]interface{}{
+				"type":        "string",
+				"description": "diff 的粒度：sentence 按句（默认），paragraph 按段",
+				"enum":        []string{"sentence", "paragraph"},
+			},
+			"note": map[string]interface{}{
+				"type":        "string",
+				"description": "版本备注，如“二稿：删去支线”（save）",
+			},
+		}
+	case "generate_names":
+		return map[string]interface{}{
+			"kind": map[string]interface{}{
+				"type":        "string",
+				"description": "起名对象：character 人名，place 地名，sect 宗门/家族/组织，artifact 法宝/兵器/舰船（默认 character）",
+				"enum":        []string{"character", "place", "sect", "artifact"},
+			},
+			"count": map[string]interface{}{
+				"type":        "integer",
+				"description": "候选数量（默认10，最多50）",
+			},
+			"gender": map[string]interface{}{
+				"type":        "string",
+				"description": "人名的性别（可选，默认男女各半）",
+				"enum":        []string{"male", "female"},
+			},
+			"surname": map[string]interface{}{
+				"type":        "string",
+				"description": "指定姓氏（人名，可选）",
+			},
+			"include": map[string]interface{}{
+				"type":        "string",
+				"description": "名字中必须带的字，如“雪”（可选）",
+			},
+			"genre": map[string]interface{}{
+				"type":        "string",
+				"description": "按该题材的风格起名（可选，默认项目题材）",
+			},
+			"use_ai": map[string]interface{}{
+				"type":        "boolean",
+				"description": "由模型从候选中挑选润色并说明寓意",
+			},
+			"hint": map[string]interface{}{
+				"type":        "string",
+				"description": "对名字的额外要求，如“反派，听起来阴冷”（use_ai 时使用）",
+			},
+		}
+	case "analyze_pacing":
+		return map[string]interface{}{
+			"chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "只分析指定章节（可选）",
+			},
+			"from_chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "起始章节号（可选，默认第一章）",
+			},
+			"to_chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "结束章节号（可选，默认最后一章）",
+			},
+			"chart": map[string]interface{}{
+				"type":        "string",
+				"description": "附加的 ASCII 条形图指标（默认 conflict）",
+				"enum":        []string{"conflict", "words", "dialogue", "hook", "none"},
+			},
+			"format": map[string]interface{}{
+				"type":        "string",
+				"description": "table 为表格与提醒（默认），csv 输出逗号分隔数据",
+				"enum":        []string{"table", "csv"},
+			},
+			"output_path": map[string]interface{}{
+				"type":        "string",
+				"description": "把 CSV 数据写入该文件（可选）",
+			},
+			"use_ai": map[string]interface{}{
+				"type":        "boolean",
+				"description": "让模型判断章末是否有钩子，覆盖规则打分",
+			},
+		}
+	case "analyze_dialogue":
+		return map[string]interface{}{
+			"action": map[string]interface{}{
+				"type":        "string",
+				"description": "extract 提取台词、判断说话人并更新角色的说话习惯与代表台词；voice 对照此前章节检查指定章节的台词是否符合人物说话习惯",
+				"enum":        []string{"extract", "voice"},
+			},
+			"chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "只处理指定章节（voice 时必填，或用 from_chapter/to_chapter）",
+			},
+			"from_chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "起始章节号（可选）",
+			},
+			"to_chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "结束章节号（可选）",
+			},
+			"use_ai": map[string]interface{}{
+				"type":        "boolean",
+				"description": "让模型判断规则无法确定说话人的台词（每次最多5章）",
+			},
+		}
+	case "character_appearances":
+		return map[string]interface{}{
+			"absent_after": map[string]interface{}{
+				"type":        "integer",
+				"description": "超过多少章未出场时提醒（默认10）",
+			},
+			"character": map[string]interface{}{
+				"type":        "string",
+				"description": "要设置别名的角色（与 aliases 一起使用）",
 			},
-			"end_line": map[string]interface{}{
-				"type":        "integer", 
-				"description": "结束编辑的行号（可选）",
+			"aliases": map[string]interface{}{
+				"type":        "string",
+				"description": "替换该角色的全部别名与称谓，多个用逗号或顿号分隔，如“林师兄、少主”；传“none”清空",
 			},
-			"content": map[string]interface{}{
+		}
+	case "check_pov":
+		return map[string]interface{}{
+			"chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "只检查指定章节（可选；设置视角人物时必填）",
+			},
+			"from_chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "起始章节号（可选，默认第一章）",
+			},
+			"to_chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "结束章节号（可选，默认最后一章）",
+			},
+			"pov_character": map[string]interface{}{
 				"type":        "string",
-				"description": "新的文件内容",
+				"description": "为 chapter 指定视角人物后再检查；传“none”清除",
 			},
 		}
-	case "create_directory":
+	case "check_consistency":
 		return map[string]interface{}{
-			"directory_path": map[string]interface{}{
+			"chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "只检查指定章节（可选）",
+			},
+			"from_chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "起始章节号（可选，默认第一章）",
+			},
+			"to_chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "结束章节号（可选，默认最后一章）",
+			},
+			"use_ai": map[string]interface{}{
+				"type":        "boolean",
+				"description": "是否追加AI审读（较慢，每次最多5章）",
+			},
+		}
+	case "set_story_calendar":
+		return map[string]interface{}{
+			"era": map[string]interface{}{
 				"type":        "string",
-				"description": "要创建的目录路径",
+				"description": "纪年名，如“天元”（可为空）",
+			},
+			"days_per_year": map[string]interface{}{
+				"type":        "integer",
+				"description": "每年天数（默认360）",
+			},
+			"months_per_year": map[string]interface{}{
+				"type":        "integer",
+				"description": "每年月数（默认12，0表示不分月）",
 			},
 		}
-	case "delete_file":
+	case "add_timeline_event":
 		return map[string]interface{}{
-			"file_path": map[string]interface{}{
+			"title": map[string]interface{}{
 				"type":        "string",
-				"description": "要删除的文件或目录路径",
+				"description": "事件标题",
+			},
+			"date": map[string]interface{}{
+				"type":        "string",
+				"description": "故事内日期，如“天元123年3月5日”“123年”“123-3-5”",
+			},
+			"after": map[string]interface{}{
+				"type":        "string",
+				"description": "不指定date时，相对此事件（ID或标题）结束后放置",
+			},
+			"gap": map[string]interface{}{
+				"type":        "string",
+				"description": "与after事件之间的间隔，如“三个月”“10天”",
+			},
+			"duration": map[string]interface{}{
+				"type":        "string",
+				"description": "持续时间，如“3天”“两年”",
+			},
+			"chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "叙述该事件的章节号",
+			},
+			"participants": map[string]interface{}{
+				"type":        "string",
+				"description": "参与角色，多个用逗号或顿号分隔",
+			},
+			"description": map[string]interface{}{
+				"type":        "string",
+				"description": "事件描述",
 			},
 		}
-	case "copy_file":
+	case "query_timeline":
 		return map[string]interface{}{
-			"source_path": map[string]interface{}{
+			"before": map[string]interface{}{
 				"type":        "string",
-				"description": "源文件路径",
+				"description": "查询早于此事件（ID或标题）或日期发生的事件",
 			},
-			"destination_path": map[string]interface{}{
+			"after": map[string]interface{}{
 				"type":        "string",
-				"description": "目标文件路径",
+				"description": "查询晚于此事件或日期发生的事件",
+			},
+			"participant": map[string]interface{}{
+				"type":        "string",
+				"description": "只显示该角色参与的事件",
+			},
+			"limit": map[string]interface{}{
+				"type":        "integer",
+				"description": "最多返回的事件数",
+			},
+			"format": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"text", "mermaid"},
+				"description": "输出格式（默认text）",
 			},
 		}
-	case "move_file", "rename_file":
+	case "character_age":
 		return map[string]interface{}{
-			"old_path": map[string]interface{}{
+			"character": map[string]interface{}{
 				"type":        "string",
-				"description": "原文件路径",
+				"description": "角色名",
 			},
-			"new_path": map[string]interface{}{
+			"chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "章节号",
+			},
+		}
+	case "add_plot_line":
+		return map[string]interface{}{
+			"name": map[string]interface{}{
 				"type":        "string",
-				"description": "新文件路径",
+				"description": "情节线名称（已存在时更新）",
+			},
+			"type": map[string]interface{}{
+				"type":        "string",
+				"description": "类型，如 main、sub、romance、mystery",
+			},
+			"status": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"active", "resolved", "suspended"},
+				"description": "状态（默认active）",
+			},
+			"description": map[string]interface{}{
+				"type":        "string",
+				"description": "情节描述",
+			},
+			"start_chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "起始章节号",
+			},
+			"end_chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "结束章节号",
 			},
 		}
-	case "replace_text":
+	case "get_chapter_context":
 		return map[string]interface{}{
-			"file_path": map[string]interface{}{
+			"chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "章节号",
+			},
+		}
+	case "search_novel_history":
+		return map[string]interface{}{
+			"query": map[string]interface{}{
 				"type":        "string",
-				"description": "要替换文本的文件路径",
+				"description": "检索内容，可以是一句话描述，如 \"林风第一次见到师父的场景\"",
 			},
-			"old_text": map[string]interface{}{
+			"max_results": map[string]interface{}{
+				"type":        "integer",
+				"description": "最多返回的结果数，默认10",
+			},
+			"sources": map[string]interface{}{
 				"type":        "string",
-				"description": "要被替换的文本",
+				"description": "限定来源，逗号分隔：chapter, setting, character, plot, chat，默认全部",
 			},
-			"new_text": map[string]interface{}{
+			"before_chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "只检索该章之前的正文",
+			},
+			"character": map[string]interface{}{
 				"type":        "string",
-				"description": "新的替换文本",
+				"description": "不填 query 时，列出提到该角色的历史讨论",
+			},
+			"setting": map[string]interface{}{
+				"type":        "string",
+				"description": "不填 query 时，列出提到该设定的历史讨论",
+			},
+			"plot_line": map[string]interface{}{
+				"type":        "string",
+				"description": "不填 query 时，列出提到该情节线的历史讨论",
 			},
 		}
-	case "get_project_info":
+	case "plant_foreshadowing":
 		return map[string]interface{}{
-			"path": map[string]interface{}{
+			"plot_line": map[string]interface{}{
 				"type":        "string",
-				"description": "项目路径（可选，默认为当前目录）",
+				"description": "所属情节线名称",
+			},
+			"description": map[string]interface{}{
+				"type":        "string",
+				"description": "伏笔内容",
+			},
+			"planted_chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "埋下伏笔的章节号（默认当前章节）",
+			},
+			"payoff_from": map[string]interface{}{
+				"type":        "integer",
+				"description": "计划最早回收的章节号",
+			},
+			"payoff_to": map[string]interface{}{
+				"type":        "integer",
+				"description": "计划最晚回收的章节号，超过后视为超期",
+			},
+			"characters": map[string]interface{}{
+				"type":        "string",
+				"description": "关联角色，多个用逗号或顿号分隔",
+			},
+			"items": map[string]interface{}{
+				"type":        "string",
+				"description": "关联道具或设定，多个用逗号或顿号分隔",
+			},
+			"note": map[string]interface{}{
+				"type":        "string",
+				"description": "备注",
 			},
 		}
-	case "smart_task_planner":
+	case "update_foreshadowing":
 		return map[string]interface{}{
-			"task_description": map[string]interface{}{
+			"id": map[string]interface{}{
 				"type":        "string",
-				"description": "需要规划的任务描述",
+				"description": "伏笔ID，如 fs_3",
+			},
+			"status": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"paid", "hinted", "abandoned", "planted"},
+				"description": "新状态（默认paid）",
+			},
+			"chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "回收或暗示所在的章节号（默认当前章节）",
+			},
+			"note": map[string]interface{}{
+				"type":        "string",
+				"description": "备注",
+			},
+		}
+	case "list_foreshadowing":
+		return map[string]interface{}{
+			"status": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"planted", "hinted", "paid", "abandoned"},
+				"description": "按状态过滤（可选）",
+			},
+			"overdue_only": map[string]interface{}{
+				"type":        "boolean",
+				"description": "只列出超期未回收的伏笔",
+			},
+			"chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "以该章节判断是否超期（默认当前章节）",
+			},
+		}
+	case "set_relationship":
+		return map[string]interface{}{
+			"from": map[string]interface{}{
+				"type":        "string",
+				"description": "角色A",
+			},
+			"to": map[string]interface{}{
+				"type":        "string",
+				"description": "角色B",
+			},
+			"type": map[string]interface{}{
+				"type":        "string",
+				"description": "A 视 B 为什么，如 师父、仇人、盟友、恋人",
+			},
+			"since_chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "该关系从第几章开始（默认0，即故事开始时）",
+			},
+			"reverse_type": map[string]interface{}{
+				"type":        "string",
+				"description": "同时记录 B 视 A 为什么（可选），如 徒弟",
+			},
+			"note": map[string]interface{}{
+				"type":        "string",
+				"description": "关系变化的原因或备注",
+			},
+		}
+	case "query_relationship":
+		return map[string]interface{}{
+			"a": map[string]interface{}{
+				"type":        "string",
+				"description": "角色A",
+			},
+			"b": map[string]interface{}{
+				"type":        "string",
+				"description": "角色B",
+			},
+			"chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "章节号（默认当前章节）",
+			},
+		}
+	case "export_relationship_graph":
+		return map[string]interface{}{
+			"format": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"mermaid", "dot"},
+				"description": "输出格式（默认mermaid）",
+			},
+			"chapter": map[string]interface{}{
+				"type":        "integer",
+				"description": "按该章节时的关系绘制（默认当前章节）",
+			},
+			"output_path": map[string]interface{}{
+				"type":        "string",
+				"description": "写入的文件路径（可选），如 export/relationships.dot",
+			},
+		}
+	case "import_manuscript":
+		return map[string]interface{}{
+			"file_path": map[string]interface{}{
+				"type":        "string",
+				"description": "要导入的整本小说txt文件路径（UTF-8编码）",
+			},
+			"chapter_pattern": map[string]interface{}{
+				"type":        "string",
+				"description": "自定义章节标题正则（可选），第1个分组为编号，第2个分组为章节名",
+			},
+			"volume_pattern": map[string]interface{}{
+				"type":        "string",
+				"description": "自定义分卷标题正则（可选）",
+			},
+			"confirm": map[string]interface{}{
+				"type":        "boolean",
+				"description": "是否执行导入（默认false，仅返回预览）",
+			},
+			"overwrite": map[string]interface{}{
+				"type":        "boolean",
+				"description": "项目已有章节时是否覆盖",
 			},
 		}
-	case "analyze_file_relationships":
-		return map[string]interface{}{} // 无需参数，自动分析当前目录
 	default:
 		return map[string]interface{}{}
 	}
@@ -1736,17 +4076,52 @@ func getRequiredParameters(toolName string) []string {
 		return []string{"file_path", "old_text", "new_text"}
 	case "smart_task_planner":
 		return []string{"task_description"}
+	case "outline_template", "glossary":
+		return []string{"action"}
+	case "chapter_history":
+		return []string{"action", "chapter"}
+	case "init_novel_project":
+		return []string{"title"}
+	case "add_character":
+		return []string{"name"}
+	case "add_timeline_event":
+		return []string{"title"}
+	case "add_plot_line":
+		return []string{"name"}
+	case "get_chapter_context", "generate_chapter":
+		return []string{"chapter"}
+	case "plant_foreshadowing":
+		return []string{"plot_line", "description"}
+	case "style_profile", "analyze_dialogue":
+		return []string{"action"}
+	case "update_foreshadowing":
+		return []string{"id"}
+	case "set_relationship":
+		return []string{"from", "to", "type"}
+	case "query_relationship":
+		return []string{"a", "b"}
+	case "character_age":
+		return []string{"character", "chapter"}
+	case "import_manuscript":
+		return []string{"file_path"}
+	case "export_novel":
+		return []string{"format"}
 	default:
 		return []string{}
 	}
 }
 
+// 任务规划中每次列出的节拍数
+const maxPlannedBeats = 3
+
 // SmartTaskPlannerTool - 智能任务规划工具
-type SmartTaskPlannerTool struct{}
+type SmartTaskPlannerTool struct {
+	novelManager *novel.NovelManager
+}
 
 func (t *SmartTaskPlannerTool) Name() string { return "smart_task_planner" }
 func (t *SmartTaskPlannerTool) Description() string {
-	return "智能任务规划工具。⚠️重要限制：只能在已经获取了完整项目信息（通过list_files和read_file）后使用。禁止在不了解具体项目内容时使用此工具给出通用建议。"
+	return "智能任务规划工具：按任务类型和当前项目的大纲节拍模板制定计划，列出各节拍的章节范围与写作进度，并针对尚未写完或需要检查的节拍给出具体步骤和工具。项目未选用模板时按题材推荐的模板预估。"
 }
 
 func (t *SmartTaskPlannerTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
@@ -1755,11 +4130,10 @@ func (t *SmartTaskPlannerTool) Execute(ctx context.Context, params map[string]in
 		return "", fmt.Errorf("task_description parameter is required")
 	}
 	
-	plan := analyzeAndPlanTask(taskDesc)
-	return plan, nil
+	return t.analyzeAndPlanTask(taskDesc)
 }
 
-func analyzeAndPlanTask(taskDesc string) string {
+func (t *SmartTaskPlannerTool) analyzeAndPlanTask(taskDesc string) (string, error) {
 	taskLower := strings.ToLower(taskDesc)
 	var plan strings.Builder
 	
@@ -1770,27 +4144,39 @@ func analyzeAndPlanTask(taskDesc string) string {
 	taskType := identifyTaskType(taskLower)
 	plan.WriteString(fmt.Sprintf("📋 任务类型: %s\n\n", taskType))
 	
-	// 根据任务类型生成具体计划
-	switch {
-	case strings.Contains(taskLower, "小说") || strings.Contains(taskLower, "写作") || strings.Contains(taskLower, "大纲"):
-		plan.WriteString(generateNovelWritingPlan(taskDesc))
-	case strings.Contains(taskLower, "分析") || strings.Contains(taskLower, "检查") || strings.Contains(taskLower, "评价"):
-		plan.WriteString(generateAnalysisPlan(taskDesc))
-	case strings.Contains(taskLower, "修改") || strings.Contains(taskLower, "改进") || strings.Contains(taskLower, "优化"):
-		plan.WriteString(generateImprovementPlan(taskDesc))
-	case strings.Contains(taskLower, "创建") || strings.Contains(taskLower, "生成") || strings.Contains(taskLower, "制作"):
-		plan.WriteString(generateCreationPlan(taskDesc))
-	default:
-		plan.WriteString(generateGeneralPlan(taskDesc))
+	// 计划来自项目选用的节拍模板，未选用时按题材推荐的模板预估
+	templates, _ := novel.LoadBeatTemplates(beatTemplateDir())
+	if !t.novelManager.HasProject() {
+		plan.WriteString(planWithoutProject(templates))
+		return plan.String(), nil
+	}
+	var preview *novel.BookOutline
+	if t.novelManager.ProjectOutline() == nil {
+		if preview = t.novelManager.SuggestOutline(templates); preview == nil {
+			return "", fmt.Errorf("no beat templates available")
+		}
+	}
+	coverage, err := t.novelManager.OutlineCoverage(preview)
+	if err != nil {
+		return "", fmt.Errorf("failed to check outline coverage: %w", err)
+	}
+	plan.WriteString(coverage.Format())
+	if coverage.Preview {
+		plan.WriteString(fmt.Sprintf("\n💡 项目尚未选用大纲模板，以上按「%s」预估，可执行 outline_template action=\"apply\" template=\"%s\" total_chapters=%d 写入项目\n",
+			coverage.Title, coverage.Template, coverage.TotalChapters))
+	}
+	
+	plan.WriteString("\n🗺 执行步骤:\n")
+	for i, step := range planSteps(taskType, coverage) {
+		plan.WriteString(fmt.Sprintf("%d. %s\n", i+1, step))
 	}
 	
 	plan.WriteString("\n🎯 执行建议:\n")
 	plan.WriteString("• 按顺序执行各步骤，确保每步完成后再进行下一步\n")
-	plan.WriteString("• 在关键节点进行质量检查和用户确认\n")
-	plan.WriteString("• 保持文件备份，便于回滚修改\n")
-	plan.WriteString("• 及时记录重要发现和改进想法\n")
+	plan.WriteString("• 每写完一个节拍执行 outline_template action=\"status\" 核对进度\n")
+	plan.WriteString("• 节拍的实际安排有变化时用 outline_template action=\"adjust\" 更新，保持大纲与正文一致\n")
 	
-	return plan.String()
+	return plan.String(), nil
 }
 
 func identifyTaskType(taskDesc string) string {
@@ -1808,135 +4194,108 @@ func identifyTaskType(taskDesc string) string {
 	}
 }
 
-func generateNovelWritingPlan(taskDesc string) string {
-	plan := `🔥 小说创作专业流程:
-
-📚 第一阶段：基础准备
-1. 【环境分析】使用 list_files 了解现有文件结构
-2. 【设定收集】读取所有相关设定文件（世界观、角色、门派等）
-3. 【一致性检查】交叉验证各设定文件的逻辑一致性
-4. 【缺失识别】发现设定中的空白和不足之处
-
-📖 第二阶段：内容规划  
-5. 【大纲框架】基于现有设定构建故事主线
-6. 【角色弧线】设计主要角色的成长轨迹
-7. 【冲突设计】安排主要矛盾和转折点
-8. 【章节划分】将故事分解为具体章节
-
-✍️ 第三阶段：创作执行
-9. 【详细大纲】为每个章节制定详细内容
-10. 【写作风格】确定叙述风格和文字特色  
-11. 【质量把控】设置检查点和评估标准
-12. 【迭代完善】根据反馈持续优化
-
-`
-	return plan
+// planWithoutProject 还没有小说项目时的准备步骤
+func planWithoutProject(templates []*novel.BeatTemplate) string {
+	names := make([]string, 0, len(templates))
+	for _, template := range templates {
+		names = append(names, fmt.Sprintf("%s（%s）", template.Title, template.Name))
+	}
+	var plan strings.Builder
+	plan.WriteString("当前目录还没有小说项目，先完成基础准备:\n")
+	plan.WriteString("1. init_novel_project 初始化项目，写明题材与目标字数\n")
+	plan.WriteString("2. add_character、add_plot_line 登记主角和主线\n")
+	plan.WriteString(fmt.Sprintf("3. outline_template action=\"apply\" 选用结构模板: %s\n", strings.Join(names, "、")))
+	plan.WriteString("4. 重新执行 smart_task_planner，按节拍制定写作计划\n")
+	return plan.String()
 }
 
-func generateAnalysisPlan(taskDesc string) string {
-	plan := `🔍 深度分析专业流程:
-
-🎯 第一阶段：信息收集
-1. 【目标文件识别】确定需要分析的具体文件
-2. 【相关文件发现】查找所有相关联的支持文件
-3. 【完整内容读取】获取所有相关文件的完整内容
-4. 【背景信息整理】建立完整的上下文环境
-
-🧠 第二阶段：深度分析
-5. 【结构分析】评估内容的组织结构和逻辑
-6. 【质量评估】从多个维度进行质量判断
-7. 【一致性检查】发现内部矛盾和逻辑问题
-8. 【完整性验证】识别遗漏和不足之处
-
-💡 第三阶段：洞察提炼
-9. 【优势识别】发现内容的亮点和特色
-10. 【问题诊断】准确定位具体问题所在
-11. 【改进建议】提供针对性的改进方案
-12. 【最佳实践】结合行业标准给出专业建议
-
-`
-	return plan
+// planSteps 按任务类型从节拍进度推出执行步骤
+func planSteps(taskType string, coverage *novel.OutlineCoverage) []string {
+	steps := make([]string, 0)
+	if coverage.Preview {
+		steps = append(steps, fmt.Sprintf("outline_template action=\"apply\" template=\"%s\" 确定全书结构，必要时用 adjust 调整节拍所在章节", coverage.Template))
+	}
+	
+	switch taskType {
+	case "分析评估类", "改进优化类":
+		for _, beat := range coverage.Beats {
+			if beat.Status == novel.BeatSkipped {
+				steps = append(steps, fmt.Sprintf("【%s】补写空缺的第%d章 → generate_chapter chapter=%d", beat.Name, beat.NextChapter, beat.NextChapter))
+			}
+		}
+		written := make([]novel.BeatCoverage, 0)
+		for _, beat := range coverage.Beats {
+			if beat.Written > 0 {
+				written = append(written, beat)
+			}
+		}
+		if len(written) > maxPlannedBeats {
+			written = written[len(written)-maxPlannedBeats:]
+		}
+		for _, beat := range written {
+			end := beat.EndChapter
+			if end > coverage.LatestChapter {
+				end = coverage.LatestChapter
+			}
+			span := novel.OutlineBeat{StartChapter: beat.StartChapter, EndChapter: end}.Span()
+			step := fmt.Sprintf("【%s】%s：对照“%s”检查节奏 → analyze_pacing from_chapter=%d to_chapter=%d", beat.Name, span, beatIntent(beat), beat.StartChapter, end)
+			if taskType == "改进优化类" {
+				step += fmt.Sprintf("，再用 style_profile action=\"drift\" chapter=%d 检查文风", end)
+			}
+			steps = append(steps, step)
+		}
+		if coverage.LatestChapter > 0 {
+			steps = append(steps, fmt.Sprintf("check_consistency 与 check_pov from_chapter=1 to_chapter=%d 检查设定和视角", coverage.LatestChapter))
+		}
+	default:
+		unfinished := coverage.Unfinished()
+		if len(unfinished) > maxPlannedBeats {
+			unfinished = unfinished[:maxPlannedBeats]
+		}
+		for _, beat := range unfinished {
+			steps = append(steps, fmt.Sprintf("【%s】%s（已写 %d/%d 章）：%s → get_chapter_context chapter=%d，再 generate_chapter chapter=%d",
+				beat.Name, beat.Span(), beat.Written, beat.EndChapter-beat.StartChapter+1, beatIntent(beat), beat.NextChapter, beat.NextChapter))
+		}
+	}
+	
+	if len(steps) == 0 {
+		steps = append(steps, "所有节拍均已写完，通读全书后执行 check_consistency 和 analyze_pacing 做整体检查")
+	}
+	return steps
 }
 
-func generateImprovementPlan(taskDesc string) string {
-	plan := `🚀 改进优化专业流程:
-
-📊 第一阶段：现状评估
-1. 【现有内容审视】全面了解当前状况
-2. 【问题识别定位】准确诊断具体问题  
-3. 【影响范围分析】评估修改的连锁效应
-4. 【优先级排序】确定改进的先后顺序
-
-🔧 第二阶段：方案设计
-5. 【改进策略制定】设计具体的优化方案
-6. 【风险评估】预判可能的问题和风险
-7. 【备份准备】确保可以安全回滚
-8. 【测试计划】设计验证改进效果的方法
-
-⚡ 第三阶段：执行验证
-9. 【分步实施】按计划逐步执行改进
-10. 【实时监控】跟踪改进过程和效果
-11. 【质量验证】确保改进达到预期目标
-12. 【文档更新】记录改进过程和结果
-
-`
-	return plan
+// beatIntent 节拍的具体安排，没有时使用模板说明
+func beatIntent(beat novel.BeatCoverage) string {
+	if beat.Note != "" {
+		return beat.Note
+	}
+	return beat.Description
 }
 
-func generateCreationPlan(taskDesc string) string {
-	plan := `🎨 创建生成专业流程:
-
-🎯 第一阶段：需求分析
-1. 【目标明确】准确理解创建要求和目标
-2. 【规格定义】确定具体的格式和标准
-3. 【素材收集】收集所需的参考资料
-4. 【约束识别】了解限制条件和边界
-
-🏗️ 第二阶段：设计规划
-5. 【结构设计】规划内容的整体架构
-6. 【模板选择】确定最适合的创建模式
-7. 【质量标准】设定评估和验收标准
-8. 【时间规划】合理安排创建进度
-
-🚀 第三阶段：创建执行
-9. 【内容生成】按设计创建具体内容
-10. 【质量检查】验证内容的准确性和完整性
-11. 【格式调整】确保符合规范要求
-12. 【最终验收】完成创建并交付成果
-
-`
-	return plan
+// beatTemplateDir 用户节拍模板目录，无法确定配置目录时只使用内置模板
+func beatTemplateDir() string {
+	return userTemplateDir(novel.BeatTemplateDir)
 }
 
-func generateGeneralPlan(taskDesc string) string {
-	plan := `⚙️ 综合处理标准流程:
-
-🔍 第一阶段：需求理解
-1. 【任务分解】将复杂任务拆分为子任务
-2. 【信息收集】获取完成任务所需的所有信息
-3. 【依赖分析】识别任务间的依赖关系
-4. 【资源评估】确定所需的工具和资源
-
-📋 第二阶段：执行规划
-5. 【优先级排序】确定最优的执行顺序
-6. 【方法选择】为每个子任务选择最佳方法
-7. 【质量控制】设置检查点和验证标准
-8. 【风险管控】识别并准备应对潜在问题
-
-✅ 第三阶段：执行验证
-9. 【分步执行】按计划逐步完成各子任务
-10. 【进度跟踪】监控执行进度和质量
-11. 【结果验证】确保每步都达到预期效果
-12. 【总结反馈】记录经验和改进建议
+// genreTemplateDir 用户题材模板目录
+func genreTemplateDir() string {
+	return userTemplateDir(novel.GenreTemplateDir)
+}
 
-`
-	return plan
+func userTemplateDir(relative string) string {
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, filepath.FromSlash(relative))
 }
 
 // ========== 智能分析工具 ==========
 
 // FileRelationshipAnalyzerTool - 智能文件关联分析工具
-type FileRelationshipAnalyzerTool struct{}
+type FileRelationshipAnalyzerTool struct {
+	dir func() string
+}
 
 func (t *FileRelationshipAnalyzerTool) Name() string { return "analyze_file_relationships" }
 func (t *FileRelationshipAnalyzerTool) Description() string {
@@ -1944,12 +4303,11 @@ func (t *FileRelationshipAnalyzerTool) Description() string {
 }
 
 func (t *FileRelationshipAnalyzerTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
-	return analyzeFileRelationships()
+	return analyzeFileRelationships(t.dir())
 }
 
-func analyzeFileRelationships() (string, error) {
+func analyzeFileRelationships(currentDir string) (string, error) {
 	// 获取当前目录所有文件
-	currentDir, _ := os.Getwd()
 	files, err := os.ReadDir(currentDir)
 	if err != nil {
 		return "", fmt.Errorf("无法读取目录: %w", err)
@@ -1991,7 +4349,7 @@ func analyzeFileRelationships() (string, error) {
 	
 	if len(novelFiles) == 0 {
 		result.WriteString("❌ 未检测到小说创作相关文件\n")
-		result.WriteString("💡 建议创建：世界观.txt、主角设定.txt、大纲.txt 等文件\n")
+		result.WriteString("💡 建议创建：世界观.md、主角设定.md、大纲.md 等文件（init_novel_project 按题材模板自动生成）\n")
 		return result.String(), nil
 	}
 	
@@ -2275,26 +4633,66 @@ func generateSmartSuggestions(categories map[string][]string, relationships map[
 	return suggestions
 }
 
+// AI审读逐章调用模型，限制单次章节数以控制耗时
+const maxAIConsistencyChapters = 5
+
 // ConsistencyCheckerTool - 内容一致性检查器
-type ConsistencyCheckerTool struct{}
+type ConsistencyCheckerTool struct {
+	novelManager *novel.NovelManager
+	aiClient     *ai.Client
+}
 
 func (t *ConsistencyCheckerTool) Name() string { return "check_consistency" }
 func (t *ConsistencyCheckerTool) Description() string {
-	return "检查小说设定的一致性，发现角色性格、世界观、情节等方面的矛盾和不合理之处"
+	return "对照项目设定检查章节正文的一致性：已死亡角色再次出场、角色在首次登场章节之前出现、年龄与外貌矛盾、违反世界观规则、人物关系称谓前后冲突，每个问题定位到章节和行号。可选 use_ai 对指定章节追加模型审读。"
 }
 
 func (t *ConsistencyCheckerTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
-	return "🔍 一致性检查功能正在开发中...\n该功能将检查:\n• 角色性格前后一致性\n• 世界观设定逻辑性\n• 时间线合理性\n• 人物关系合理性", nil
+	fromChapter := intParam(params, "from_chapter")
+	toChapter := intParam(params, "to_chapter")
+	if chapter := intParam(params, "chapter"); chapter > 0 {
+		fromChapter, toChapter = chapter, chapter
+	}
+	useAI, _ := params["use_ai"].(bool)
+	
+	report, err := t.novelManager.CheckConsistency(fromChapter, toChapter)
+	if err != nil {
+		return "", fmt.Errorf("consistency check failed: %w", err)
+	}
+	
+	var notes strings.Builder
+	if useAI {
+		if len(report.Checked) > maxAIConsistencyChapters {
+			notes.WriteString(fmt.Sprintf("\n💡 AI审读每次最多 %d 章，请用 chapter 或 from_chapter/to_chapter 缩小范围\n", maxAIConsistencyChapters))
+		} else {
+			for _, chapter := range report.Checked {
+				issues, err := t.novelManager.CheckChapterWithAI(ctx, t.aiClient, chapter)
+				if err != nil {
+					notes.WriteString(fmt.Sprintf("\n⚠️ 第%d章AI审读失败: %v\n", chapter, err))
+					continue
+				}
+				report.AddIssues(issues)
+			}
+		}
+	}
+	
+	return report.Format() + notes.String(), nil
 }
 
 // CreativeStageDetectorTool - 创作阶段智能识别器
-type CreativeStageDetectorTool struct{}
+type CreativeStageDetectorTool struct {
+	novelManager *novel.NovelManager
+}
 
 func (t *CreativeStageDetectorTool) Name() string { return "detect_creative_stage" }
 func (t *CreativeStageDetectorTool) Description() string {
-	return "智能识别用户当前处于创作的哪个阶段，并提供针对性的建议和工具推荐"
+	return "根据项目设定、正文进度、最近的字数增删和最近几章的节奏（章末钩子、对话占比、冲突密度）识别当前处于构思、大纲、写作还是修改阶段，并给出针对性的建议和工具推荐"
 }
 
 func (t *CreativeStageDetectorTool) Execute(ctx context.Context, params map[string]interface{}) (string, error) {
-	return "🎯 创作阶段检测功能正在开发中...\n该功能将识别:\n• 构思阶段 → 提供灵感和框架\n• 大纲阶段 → 协助结构优化\n• 写作阶段 → 实时创作建议\n• 修改阶段 → 质量提升建议", nil
+	report, err := t.novelManager.DetectCreativeStage()
+	if err != nil {
+		return "", fmt.Errorf("failed to detect creative stage: %w", err)
+	}
+	return report.Format(), nil
 }
\ No newline at end of file
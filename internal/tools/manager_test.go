@@ -0,0 +1,172 @@
+package tools
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/AiNovelTools/internal/novel"
+)
+
+func TestPlanSteps(t *testing.T) {
+	beat := func(name string, start, end, written int, status string) novel.BeatCoverage {
+		next := 0
+		if written < end-start+1 {
+			next = start + written
+		}
+		return novel.BeatCoverage{
+			OutlineBeat: novel.OutlineBeat{Name: name, StartChapter: start, EndChapter: end},
+			Written:     written,
+			Status:      status,
+			NextChapter: next,
+		}
+	}
+	progress := &novel.OutlineCoverage{Template: "three_act", LatestChapter: 25, Beats: []novel.BeatCoverage{
+		beat("开端", 1, 5, 5, novel.BeatWritten),
+		beat("铺垫", 6, 10, 5, novel.BeatWritten),
+		beat("转折", 11, 15, 3, novel.BeatSkipped),
+		beat("对抗", 16, 20, 5, novel.BeatWritten),
+		beat("危机", 21, 30, 5, novel.BeatPartial),
+		beat("高潮", 31, 35, 0, novel.BeatPending),
+		beat("结局", 36, 40, 0, novel.BeatPending),
+	}}
+	preview := *progress
+	preview.Preview = true
+	finished := &novel.OutlineCoverage{Template: "three_act", LatestChapter: 5, Beats: []novel.BeatCoverage{
+		beat("开端", 1, 5, 5, novel.BeatWritten),
+	}}
+
+	tests := []struct {
+		name     string
+		taskType string
+		coverage *novel.OutlineCoverage
+		want     []string // 各步骤开头
+	}{
+		{
+			name:     "写作任务只列出前几个未写完的节拍，越过的优先",
+			taskType: "小说创作类",
+			coverage: progress,
+			want:     []string{"【转折】第11-15章", "【危机】第21-30章", "【高潮】第31-35章"},
+		},
+		{
+			name:     "未选用模板时先写入大纲",
+			taskType: "小说创作类",
+			coverage: &preview,
+			want:     []string{"outline_template action=\"apply\" template=\"three_act\"", "【转折】", "【危机】", "【高潮】"},
+		},
+		{
+			name:     "检查任务补写空缺并只检查最近写过的节拍",
+			taskType: "分析评估类",
+			coverage: progress,
+			want:     []string{"【转折】补写空缺的第14章", "【转折】第11-15章", "【对抗】第16-20章", "【危机】第21-25章", "check_consistency"},
+		},
+		{
+			name:     "全部写完",
+			taskType: "小说创作类",
+			coverage: finished,
+			want:     []string{"所有节拍均已写完"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps := planSteps(tt.taskType, tt.coverage)
+			if len(steps) != len(tt.want) {
+				t.Fatalf("got %d steps, want %d:\n%s", len(steps), len(tt.want), strings.Join(steps, "\n"))
+			}
+			for i, prefix := range tt.want {
+				if !strings.HasPrefix(steps[i], prefix) {
+					t.Errorf("step %d = %q, want prefix %q", i+1, steps[i], prefix)
+				}
+			}
+		})
+	}
+}
+
+func TestResolvePaths(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "青云志")
+	m := &Manager{root: root}
+	params := map[string]interface{}{
+		"file_path":   "chapters/chapter_1.txt",
+		"output_path": filepath.Join(root, "export", "book.epub"),
+		"path":        "",
+		"format":      "epub",
+		"chapter":     float64(3),
+	}
+	resolved := m.resolvePaths(params)
+
+	want := map[string]interface{}{
+		"file_path":   filepath.Join(root, "chapters", "chapter_1.txt"),
+		"output_path": filepath.Join(root, "export", "book.epub"),
+		"path":        "",
+		"format":      "epub",
+		"chapter":     float64(3),
+	}
+	if !reflect.DeepEqual(resolved, want) {
+		t.Errorf("resolvePaths() = %v, want %v", resolved, want)
+	}
+	if params["file_path"] != "chapters/chapter_1.txt" {
+		t.Errorf("resolvePaths modified the original params: %v", params["file_path"])
+	}
+}
+
+func TestDescribeToolCall(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+		want   string
+	}{
+		{"write_file", map[string]interface{}{"file_path": "notes.md"}, "写入 notes.md"},
+		{"delete_file", map[string]interface{}{"path": "old.txt"}, "删除 old.txt"},
+		{"rename_file", map[string]interface{}{"old_path": "a.txt", "new_path": "b.txt"}, "重命名 a.txt → b.txt"},
+		{"move_file", map[string]interface{}{"src_path": "a.txt", "dst_path": "drafts/a.txt"}, "移动 a.txt → drafts/a.txt"},
+		{"copy_file", map[string]interface{}{"src_path": "a.txt", "dst_path": "a.bak"}, "复制 a.txt → a.bak"},
+		{"create_directory", map[string]interface{}{"path": "drafts"}, "创建目录 drafts"},
+		{"generate_chapter", map[string]interface{}{"chapter": float64(12)}, "生成第12章"},
+		{"chapter_history", map[string]interface{}{"action": "rollback", "chapter": "3", "version": float64(2)}, "第3章回滚到 v2"},
+		{"fix_glossary", map[string]interface{}{"apply": false}, ""},
+		{"read_file", map[string]interface{}{"file_path": "notes.md"}, ""},
+	}
+	for _, tt := range tests {
+		if got := describeToolCall(tt.name, tt.params); got != tt.want {
+			t.Errorf("describeToolCall(%q, %v) = %q, want %q", tt.name, tt.params, got, tt.want)
+		}
+	}
+}
+
+func TestTurnCommitMessage(t *testing.T) {
+	result := func(summary string) ToolResult { return ToolResult{Summary: summary} }
+	tests := []struct {
+		name    string
+		input   string
+		results []ToolResult
+		want    string
+	}{
+		{
+			name:    "改动作标题，重复和失败的调用不计",
+			input:   "写第12章\n并添加角色",
+			results: []ToolResult{result("生成第12章"), result("添加角色 林动"), result("生成第12章"), {Summary: "删除 a.txt", Error: errors.New("not found")}, result("")},
+			want:    "生成第12章；添加角色 林动\n\n请求: 写第12章 并添加角色\n",
+		},
+		{
+			name:    "没有改动类调用时以请求作标题",
+			input:   "帮我润色一下",
+			results: []ToolResult{result("")},
+			want:    "对话修改: 帮我润色一下\n\n请求: 帮我润色一下\n",
+		},
+		{
+			name:    "改动较多时标题只列前三项，正文列出全部",
+			input:   "整理",
+			results: []ToolResult{result("删除 a"), result("删除 b"), result("删除 c"), result("删除 d")},
+			want:    "删除 a；删除 b；删除 c 等4项改动\n\n请求: 整理\n\n- 删除 a\n- 删除 b\n- 删除 c\n- 删除 d\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TurnCommitMessage(tt.input, tt.results); got != tt.want {
+				t.Errorf("TurnCommitMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package vcs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrGitNotFound 本机没有安装 git
+var ErrGitNotFound = errors.New("git executable not found in PATH")
+
+// 本机没有配置 git 身份时提交使用的作者
+const (
+	fallbackAuthorName  = "ai-assistant"
+	fallbackAuthorEmail = "ai-assistant@localhost"
+)
+
+// DefaultIgnore 新建仓库时写入的 .gitignore：原子写入的临时文件（.<文件>.tmp-N）、备份、
+// 恢复时隔离的损坏文件（<文件>.corrupt-时间）、生成流水线的中间状态和可重建的索引不进版本库
+const DefaultIgnore = `.*.tmp-*
+*.bak
+*.corrupt-*
+retrieval_index.json
+retrieval_index.log
+history/
+pipeline/
+`
+
+// Repo 以小说项目目录为范围的 git 仓库，所有操作都调用本机的 git 命令
+type Repo struct {
+	dir string
+	git string
+}
+
+// Status 工作区状态
+type Status struct {
+	Branch string // 当前分支，分离 HEAD 时为短提交号
+	Dirty  int    // 项目目录内未提交的文件数
+	NoHead bool   // 仓库还没有任何提交
+}
+
+// Commit 一条提交记录
+type Commit struct {
+	Hash    string
+	Date    string
+	Subject string
+	Files   int
+}
+
+// Open 打开 dir 所在的仓库；没有 git 时返回的 Repo 不可用，各操作返回 ErrGitNotFound
+func Open(dir string) *Repo {
+	path, _ := exec.LookPath("git")
+	return &Repo{dir: dir, git: path}
+}
+
+// Available 本机是否有 git
+func (r *Repo) Available() bool {
+	return r.git != ""
+}
+
+// Dir 仓库操作的目录
+func (r *Repo) Dir() string {
+	return r.dir
+}
+
+// IsRepo 项目目录是否位于某个 git 工作区内（可以是上层目录的仓库）
+func (r *Repo) IsRepo() bool {
+	if !r.Available() {
+		return false
+	}
+	out, err := r.run("rev-parse", "--is-inside-work-tree")
+	return err == nil && strings.TrimSpace(out) == "true"
+}
+
+// Init 在项目目录新建仓库，并在没有 .gitignore 时写入 ignore 内容
+func (r *Repo) Init(ignore string) error {
+	if !r.Available() {
+		return ErrGitNotFound
+	}
+	if _, err := r.run("init"); err != nil {
+		return err
+	}
+	path := filepath.Join(r.dir, ".gitignore")
+	if _, err := os.Stat(path); ignore == "" || err == nil {
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(ignore), 0644); err != nil {
+		return fmt.Errorf("failed to write .gitignore: %w", err)
+	}
+	return nil
+}
+
+// Status 当前分支和项目目录内未提交的文件数
+func (r *Repo) Status() (*Status, error) {
+	if !r.Available() {
+		return nil, ErrGitNotFound
+	}
+	out, err := r.run("status", "--porcelain", "--branch", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+	status := &Status{}
+	for _, line := range strings.Split(out, "\n") {
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "## ") {
+			status.Branch, status.NoHead = parseBranchLine(strings.TrimPrefix(line, "## "))
+			continue
+		}
+		status.Dirty++
+	}
+	if status.Branch == "HEAD (no branch)" {
+		if hash, err := r.run("rev-parse", "--short", "HEAD"); err == nil {
+			status.Branch = strings.TrimSpace(hash)
+		}
+	}
+	return status, nil
+}
+
+// parseBranchLine 解析 "main...origin/main [ahead 1]"、"No commits yet on main" 等分支行
+func parseBranchLine(line string) (string, bool) {
+	for _, prefix := range []string{"No commits yet on ", "Initial commit on "} {
+		if strings.HasPrefix(line, prefix) {
+			return strings.TrimPrefix(line, prefix), true
+		}
+	}
+	if i := strings.Index(line, "..."); i >= 0 {
+		line = line[:i]
+	}
+	if i := strings.Index(line, " ["); i >= 0 {
+		line = line[:i]
+	}
+	return line, false
+}
+
+// Changed 项目目录内有改动的文件（相对仓库根目录）
+func (r *Repo) Changed() ([]string, error) {
+	if !r.Available() {
+		return nil, ErrGitNotFound
+	}
+	out, err := r.run("status", "--porcelain", "--untracked-files=all", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0)
+	for _, line := range strings.Split(out, "\n") {
+		if len(line) < 4 {
+			continue
+		}
+		path := line[3:]
+		if i := strings.Index(path, " -> "); i >= 0 {
+			path = path[i+4:]
+		}
+		files = append(files, strings.Trim(path, `"`))
+	}
+	return files, nil
+}
+
+// CommitAll 提交项目目录内的全部改动，只涉及项目目录，不会带上仓库其他位置已暂存的内容；
+// 没有改动时返回空提交号
+func (r *Repo) CommitAll(message string) (string, error) {
+	files, err := r.Changed()
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 0 {
+		return "", nil
+	}
+	if _, err := r.run("add", "-A", "--", "."); err != nil {
+		return "", err
+	}
+	if _, err := r.runAs(r.identityEnv(), "commit", "-q", "-m", message, "--", "."); err != nil {
+		return "", err
+	}
+	hash, err := r.run("rev-parse", "--short", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(hash), nil
+}
+
+// Log 最近 limit 条涉及项目目录的提交，从新到旧
+func (r *Repo) Log(limit int) ([]Commit, error) {
+	if !r.Available() {
+		return nil, ErrGitNotFound
+	}
+	if status, err := r.Status(); err != nil {
+		return nil, err
+	} else if status.NoHead {
+		return []Commit{}, nil
+	}
+	out, err := r.run("log", "-n", strconv.Itoa(limit), "--date=format:%Y-%m-%d %H:%M",
+		"--pretty=format:\x1e%h\x1f%ad\x1f%s", "--shortstat", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+	commits := make([]Commit, 0, limit)
+	for _, record := range strings.Split(out, "\x1e") {
+		lines := strings.Split(strings.TrimSpace(record), "\n")
+		fields := strings.Split(lines[0], "\x1f")
+		if len(fields) != 3 {
+			continue
+		}
+		commit := Commit{Hash: fields[0], Date: fields[1], Subject: fields[2]}
+		if len(lines) > 1 {
+			stat := strings.Fields(lines[len(lines)-1])
+			if len(stat) > 0 {
+				commit.Files, _ = strconv.Atoi(stat[0])
+			}
+		}
+		commits = append(commits, commit)
+	}
+	return commits, nil
+}
+
+// Resolve 把提交号、分支名或 HEAD~1 之类的写法解析为短提交号
+func (r *Repo) Resolve(rev string) (string, error) {
+	if !r.Available() {
+		return "", ErrGitNotFound
+	}
+	out, err := r.run("rev-parse", "--verify", "--quiet", "--short", rev+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("unknown commit: %s", rev)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// IsRoot 项目目录是否就是仓库根目录
+func (r *Repo) IsRoot() bool {
+	out, err := r.run("rev-parse", "--show-toplevel")
+	if err != nil {
+		return false
+	}
+	top, err := filepath.EvalSymlinks(strings.TrimSpace(out))
+	if err != nil {
+		return false
+	}
+	dir, err := filepath.EvalSymlinks(r.dir)
+	return err == nil && filepath.Clean(top) == filepath.Clean(dir)
+}
+
+// Revert 生成一个撤销指定提交的新提交，原有历史保留；有冲突或提交失败时还原工作区并返回错误。
+// git revert 作用于整个仓库，因此只在项目目录就是仓库根目录、且没有未提交改动时执行
+func (r *Repo) Revert(rev string) (string, error) {
+	commit, err := r.Resolve(rev)
+	if err != nil {
+		return "", err
+	}
+	if !r.IsRoot() {
+		return "", fmt.Errorf("project is not at the repository root; revert would affect files outside the project")
+	}
+	if files, err := r.Changed(); err != nil {
+		return "", err
+	} else if len(files) > 0 {
+		return "", fmt.Errorf("project has %d uncommitted changes", len(files))
+	}
+	subject, err := r.run("log", "-1", "--format=%s", commit)
+	if err != nil {
+		return "", err
+	}
+	if _, err := r.run("revert", "--no-commit", commit); err != nil {
+		r.run("reset", "-q", "--merge")
+		return "", fmt.Errorf("failed to revert %s: %w", commit, err)
+	}
+	message := fmt.Sprintf("撤销 %s: %s", commit, strings.TrimSpace(subject))
+	if _, err := r.runAs(r.identityEnv(), "commit", "-q", "-m", message); err != nil {
+		// --no-commit 之后没有进行中的 revert 可以 abort，直接把暂存区和工作区还原到 HEAD
+		r.run("reset", "-q", "--merge")
+		return "", fmt.Errorf("failed to revert %s: %w", commit, err)
+	}
+	hash, err := r.run("rev-parse", "--short", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(hash), nil
+}
+
+// identityEnv 本机没有配置提交身份时临时指定作者，避免提交失败
+func (r *Repo) identityEnv() []string {
+	if out, err := r.run("config", "user.email"); err == nil && strings.TrimSpace(out) != "" {
+		return nil
+	}
+	return []string{
+		"GIT_AUTHOR_NAME=" + fallbackAuthorName, "GIT_AUTHOR_EMAIL=" + fallbackAuthorEmail,
+		"GIT_COMMITTER_NAME=" + fallbackAuthorName, "GIT_COMMITTER_EMAIL=" + fallbackAuthorEmail,
+	}
+}
+
+// run 在项目目录执行 git 命令，失败时把 git 的错误输出带进错误信息
+func (r *Repo) run(args ...string) (string, error) {
+	return r.runAs(nil, args...)
+}
+
+// runAs 带额外环境变量执行 git 命令；中文路径按原样输出，不转义
+func (r *Repo) runAs(env []string, args ...string) (string, error) {
+	if !r.Available() {
+		return "", ErrGitNotFound
+	}
+	cmd := exec.Command(r.git, append([]string{"-c", "core.quotepath=false"}, args...)...)
+	cmd.Dir = r.dir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = strings.TrimSpace(stdout.String())
+		}
+		if message == "" {
+			return "", fmt.Errorf("git %s: %w", args[0], err)
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], message)
+	}
+	return stdout.String(), nil
+}
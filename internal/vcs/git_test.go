@@ -0,0 +1,245 @@
+package vcs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// newTestRepo 在临时目录中新建仓库，不读取本机的 git 配置
+func newTestRepo(t *testing.T, dir string) *Repo {
+	t.Helper()
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	repo := Open(dir)
+	if !repo.Available() {
+		t.Skip("git not installed")
+	}
+	if err := repo.Init(DefaultIgnore); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	return repo
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestParseBranchLine(t *testing.T) {
+	tests := []struct {
+		line       string
+		wantBranch string
+		wantNoHead bool
+	}{
+		{"main", "main", false},
+		{"main...origin/main", "main", false},
+		{"main...origin/main [ahead 1, behind 2]", "main", false},
+		{"feature [gone]", "feature", false},
+		{"No commits yet on main", "main", true},
+		{"Initial commit on master", "master", true},
+		{"HEAD (no branch)", "HEAD (no branch)", false},
+	}
+	for _, tt := range tests {
+		branch, noHead := parseBranchLine(tt.line)
+		if branch != tt.wantBranch || noHead != tt.wantNoHead {
+			t.Errorf("parseBranchLine(%q) = %q, %v, want %q, %v", tt.line, branch, noHead, tt.wantBranch, tt.wantNoHead)
+		}
+	}
+}
+
+func TestDefaultIgnore(t *testing.T) {
+	dir := t.TempDir()
+	repo := newTestRepo(t, dir)
+
+	files := map[string]bool{
+		"novel_project.json":                         true,
+		"chapters/chapter_001.txt":                   true,
+		"设定/人物.md":                                   true,
+		".novel_project.json.tmp-123456":             false,
+		"novel_project.json.bak":                     false,
+		"novel_project.json.corrupt-20240501-120000": false,
+		"retrieval_index.json":                       false,
+		"retrieval_index.log":                        false,
+		"history/chapter_001/v1.txt":                 false,
+		"pipeline/chapter_001.json":                  false,
+	}
+	want := make([]string, 0)
+	for name, tracked := range files {
+		writeFile(t, filepath.Join(dir, name), "内容")
+		if tracked {
+			want = append(want, name)
+		}
+	}
+	want = append(want, ".gitignore")
+	sort.Strings(want)
+
+	changed, err := repo.Changed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(changed)
+	if !reflect.DeepEqual(changed, want) {
+		t.Errorf("Changed() = %q, want %q", changed, want)
+	}
+}
+
+func TestCommitAndRevert(t *testing.T) {
+	dir := t.TempDir()
+	repo := newTestRepo(t, dir)
+	chapter := filepath.Join(dir, "chapter_001.txt")
+
+	if status, err := repo.Status(); err != nil || !status.NoHead {
+		t.Fatalf("new repo status = %+v, %v", status, err)
+	}
+	if commits, err := repo.Log(10); err != nil || len(commits) != 0 {
+		t.Fatalf("new repo log = %v, %v", commits, err)
+	}
+
+	writeFile(t, chapter, "第一稿\n")
+	first, err := repo.CommitAll("写第一章")
+	if err != nil || first == "" {
+		t.Fatalf("CommitAll: %q, %v", first, err)
+	}
+	if hash, err := repo.CommitAll("没有改动"); err != nil || hash != "" {
+		t.Errorf("CommitAll without changes = %q, %v", hash, err)
+	}
+
+	writeFile(t, chapter, "第二稿\n")
+	second, err := repo.CommitAll("改写第一章")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved, err := repo.Resolve("HEAD"); err != nil || resolved != second {
+		t.Errorf("Resolve(HEAD) = %q, %v, want %q", resolved, err, second)
+	}
+	if _, err := repo.Resolve("不存在"); err == nil {
+		t.Error("expected unknown revision to fail")
+	}
+
+	// 有未提交的改动时拒绝撤销
+	writeFile(t, chapter, "还没提交\n")
+	if _, err := repo.Revert(second); err == nil {
+		t.Error("expected revert with uncommitted changes to fail")
+	}
+	writeFile(t, chapter, "第二稿\n")
+
+	reverted, err := repo.Revert(second)
+	if err != nil {
+		t.Fatalf("Revert: %v", err)
+	}
+	if got := readFile(t, chapter); got != "第一稿\n" {
+		t.Errorf("chapter after revert = %q", got)
+	}
+
+	commits, err := repo.Log(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subjects := make([]string, 0, len(commits))
+	for _, commit := range commits {
+		subjects = append(subjects, commit.Subject)
+	}
+	want := []string{"撤销 " + second + ": 改写第一章", "改写第一章", "写第一章"}
+	if !reflect.DeepEqual(subjects, want) {
+		t.Errorf("log subjects = %q, want %q", subjects, want)
+	}
+	if commits[0].Hash != reverted || commits[2].Files != 2 {
+		t.Errorf("log = %+v", commits)
+	}
+	if status, err := repo.Status(); err != nil || status.Dirty != 0 || status.NoHead || status.Branch == "" {
+		t.Errorf("status after revert = %+v, %v", status, err)
+	}
+}
+
+func TestRevertConflictRestoresWorktree(t *testing.T) {
+	dir := t.TempDir()
+	repo := newTestRepo(t, dir)
+	chapter := filepath.Join(dir, "chapter_001.txt")
+
+	writeFile(t, chapter, "初稿\n")
+	if _, err := repo.CommitAll("初稿"); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, chapter, "二稿\n")
+	second, err := repo.CommitAll("二稿")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, chapter, "三稿\n")
+	if _, err := repo.CommitAll("三稿"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 撤销二稿与三稿冲突，失败后工作区应回到 HEAD
+	if _, err := repo.Revert(second); err == nil {
+		t.Fatal("expected conflicting revert to fail")
+	}
+	if got := readFile(t, chapter); got != "三稿\n" {
+		t.Errorf("chapter after failed revert = %q", got)
+	}
+	if changed, err := repo.Changed(); err != nil || len(changed) != 0 {
+		t.Errorf("worktree not clean after failed revert: %v, %v", changed, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".git", "REVERT_HEAD")); !os.IsNotExist(err) {
+		t.Errorf("revert still in progress, stat err = %v", err)
+	}
+}
+
+func TestNestedProject(t *testing.T) {
+	root := t.TempDir()
+	outer := newTestRepo(t, root)
+	writeFile(t, filepath.Join(root, "README.md"), "工作区\n")
+	if _, err := outer.CommitAll("工作区"); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(root, "novel")
+	writeFile(t, filepath.Join(dir, "chapter_001.txt"), "正文\n")
+	writeFile(t, filepath.Join(root, "README.md"), "工作区外的改动\n")
+	repo := Open(dir)
+	if !repo.IsRepo() || repo.IsRoot() {
+		t.Fatalf("nested project IsRepo = %v, IsRoot = %v", repo.IsRepo(), repo.IsRoot())
+	}
+	if !outer.IsRoot() {
+		t.Error("workspace root should be the repository root")
+	}
+
+	// 只提交项目目录内的改动
+	hash, err := repo.CommitAll("写第一章")
+	if err != nil || hash == "" {
+		t.Fatalf("CommitAll: %q, %v", hash, err)
+	}
+	outside, err := outer.Changed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outside) != 1 || outside[0] != "README.md" {
+		t.Errorf("changes outside the project = %q, want README.md only", outside)
+	}
+
+	// 撤销会影响项目以外的文件，拒绝执行
+	if _, err := repo.Revert(hash); err == nil || !strings.Contains(err.Error(), "repository root") {
+		t.Errorf("Revert in nested project error = %v", err)
+	}
+	if got := readFile(t, filepath.Join(dir, "chapter_001.txt")); got != "正文\n" {
+		t.Errorf("chapter changed by refused revert: %q", got)
+	}
+}
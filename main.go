@@ -2,24 +2,35 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/AiNovelTools/internal/ai"
 	"github.com/AiNovelTools/internal/config"
 	"github.com/AiNovelTools/internal/input"
+	"github.com/AiNovelTools/internal/novel"
 	"github.com/AiNovelTools/internal/session"
 	"github.com/AiNovelTools/internal/tools"
+	"github.com/AiNovelTools/internal/vcs"
 )
 
 func main() {
+	// 无界面批量生成：ai-assistant generate chapters 21-30 [选项]
+	if len(os.Args) > 1 && os.Args[1] == "generate" {
+		os.Exit(runGenerateCommand(os.Args[2:]))
+	}
+	
 	ctx := context.Background()
 	
 	// 初始化输入管理器
@@ -39,19 +50,30 @@ func main() {
 	aiClient := ai.NewClient(cfg.AI)
 	
 	// 初始化工具管理器
-	toolManager := tools.NewManager()
+	toolManager := tools.NewManager(aiClient)
+	configureNovelManager(toolManager, aiClient, cfg)
 	
 	// 初始化会话管理器
 	sessionManager := session.NewManager()
 
 	// 显示欢迎信息
 	inputManager.PrintWelcome()
-	printStatusLine(cfg, inputManager)
+	for _, note := range toolManager.NovelManager().RecoveryNotes() {
+		inputManager.PrintWarning(note)
+	}
+	// 启动目录不是小说项目时，打开工作区上次使用的小说
+	if !toolManager.NovelManager().HasProject() {
+		if name := toolManager.Workspace().Active(); name != "" && name != novel.RootProjectName {
+			openNovel(name, toolManager, sessionManager, aiClient, cfg, inputManager)
+		}
+	}
+	printStatusLine(cfg, toolManager, inputManager)
 	
 	// 设置初始模型提示符
 	updatePrompt(cfg, inputManager)
 	
 	for {
+		updateNovelPrompt(toolManager, inputManager)
 		line, err := inputManager.ReadLine()
 		if err == io.EOF {
 			break
@@ -66,7 +88,7 @@ func main() {
 		}
 		
 		// 处理特殊命令
-		if handled := handleSpecialCommands(line, aiClient, sessionManager, cfg, inputManager); handled {
+		if handled := handleSpecialCommands(line, aiClient, toolManager, sessionManager, cfg, inputManager); handled {
 			continue
 		}
 
@@ -74,34 +96,41 @@ func main() {
 		inputManager.ShowLoading("正在处理请求")
 		
 		// 处理用户输入
-		response, err := processInput(ctx, aiClient, toolManager, sessionManager, inputManager, line)
+		response, toolResults, err := processInput(ctx, aiClient, toolManager, sessionManager, inputManager, line)
 		
 		// 隐藏加载动画
 		inputManager.HideLoading()
 		
 		if err != nil {
+			// 出错的一轮不自动提交，已改动的文件留到下一轮一并提交
 			inputManager.PrintError(err.Error())
 			continue
 		}
 		
 		inputManager.PrintAIResponse(response)
+		reportWordCountChange(toolManager, cfg, inputManager)
+		autoCommit(line, toolResults, toolManager, cfg, inputManager)
 	}
 	
 	// 保存会话
 	if err := sessionManager.SaveSession(sessionManager.GetCurrentSession()); err != nil {
 		inputManager.PrintWarning(fmt.Sprintf("保存会话失败: %v", err))
 	}
+	closeNovelProject(toolManager, inputManager)
 	
 	fmt.Println("\n\033[36m再见! 👋\033[0m")
 }
 
-func printStatusLine(cfg *config.Config, inputManager *input.Manager) {
+func printStatusLine(cfg *config.Config, toolManager *tools.Manager, inputManager *input.Manager) {
 	currentModel := "未知"
 	if model, exists := cfg.AI.Models[cfg.AI.Provider]; exists {
 		currentModel = model.Model
 	}
 	
 	statusMsg := fmt.Sprintf("当前模型: %s | 版本: %s", cfg.AI.Provider, currentModel)
+	if state := gitState(projectRepo(toolManager)); state != "" {
+		statusMsg += " | git: " + state
+	}
 	inputManager.PrintInfo(statusMsg)
 	fmt.Println()
 }
@@ -115,7 +144,7 @@ func updatePrompt(cfg *config.Config, inputManager *input.Manager) {
 	inputManager.SetModelPrompt(currentModel)
 }
 
-func handleSpecialCommands(input string, aiClient *ai.Client, sessionManager *session.Manager, cfg *config.Config, inputManager *input.Manager) bool {
+func handleSpecialCommands(input string, aiClient *ai.Client, toolManager *tools.Manager, sessionManager *session.Manager, cfg *config.Config, inputManager *input.Manager) bool {
 	// 检查是否以 / 开头的命令
 	if !strings.HasPrefix(input, "/") {
 		return false
@@ -130,6 +159,7 @@ func handleSpecialCommands(input string, aiClient *ai.Client, sessionManager *se
 	
 	switch command {
 	case "/exit", "/quit":
+		closeNovelProject(toolManager, inputManager)
 		inputManager.PrintInfo("再见! 👋")
 		os.Exit(0)
 		return true
@@ -141,12 +171,12 @@ func handleSpecialCommands(input string, aiClient *ai.Client, sessionManager *se
 	case "/clear":
 		inputManager.ClearScreen()
 		inputManager.PrintWelcome()
-		printStatusLine(cfg, inputManager)
+		printStatusLine(cfg, toolManager, inputManager)
 		updatePrompt(cfg, inputManager)
 		return true
 		
 	case "/status":
-		printStatus(sessionManager, cfg, inputManager)
+		printStatus(sessionManager, toolManager, cfg, inputManager)
 		return true
 		
 	case "/sessions":
@@ -196,6 +226,54 @@ func handleSpecialCommands(input string, aiClient *ai.Client, sessionManager *se
 			inputManager.PrintError("用法: /deletesession <会话ID>")
 		}
 		return true
+		
+	case "/import":
+		if len(parts) > 1 {
+			importManuscript(parts[1], strings.Join(parts[2:], " "), toolManager, cfg, inputManager)
+		} else {
+			inputManager.PrintError("用法: /import <文稿.txt> [章节标题正则]")
+		}
+		return true
+		
+	case "/export":
+		if len(parts) > 1 {
+			outputPath := ""
+			if len(parts) > 2 {
+				outputPath = parts[2]
+			}
+			exportNovel(parts[1], outputPath, toolManager, inputManager)
+		} else {
+			inputManager.PrintError("用法: /export <epub|markdown|txt> [输出路径]")
+		}
+		return true
+		
+	case "/progress":
+		showProgress(toolManager, cfg, inputManager)
+		return true
+		
+	case "/decide":
+		recordDecision(strings.TrimSpace(strings.TrimPrefix(input, command)), toolManager, inputManager)
+		return true
+		
+	case "/generate":
+		generateChapters(parts[1:], aiClient, toolManager, inputManager)
+		return true
+		
+	case "/history":
+		showGitHistory(parts[1:], toolManager, inputManager)
+		return true
+		
+	case "/revert":
+		if len(parts) > 1 {
+			revertCommit(parts[1], toolManager, cfg, inputManager)
+		} else {
+			inputManager.PrintError("用法: /revert <提交号>")
+		}
+		return true
+		
+	case "/novel":
+		handleNovelCommand(parts[1:], aiClient, toolManager, sessionManager, cfg, inputManager)
+		return true
 	}
 	
 	return false
@@ -346,6 +424,20 @@ func printHelp(inputManager *input.Manager) {
 	fmt.Println("  \033[33m/deletesession\033[0m <ID> - 删除指定会话")
 	fmt.Println("  \033[90m注: 会话ID可使用前8位短ID\033[0m")
 	fmt.Println()
+	fmt.Println("\033[1;36m📚 小说项目:\033[0m")
+	fmt.Println("  \033[33m/novel list\033[0m  - 列出工作区（启动目录）中的小说")
+	fmt.Println("  \033[33m/novel open\033[0m <名称> - 切换到另一部小说（目录名或书名）")
+	fmt.Println("  \033[33m/novel new\033[0m <目录名> [书名] [题材] - 在工作区新建一部小说并打开")
+	fmt.Println("  \033[33m/novel info\033[0m [名称] - 查看小说概况（默认当前小说）")
+	fmt.Println("  \033[33m/import\033[0m <文件> [正则] - 导入整本txt文稿并拆分章节")
+	fmt.Println("  \033[33m/export\033[0m <格式> [路径] - 导出成书 (epub|markdown|txt)")
+	fmt.Println("  \033[33m/progress\033[0m   - 查看今日字数、连续达标天数和完成预测")
+	fmt.Println("  \033[33m/decide\033[0m [内容] - 记录创作决定（不带内容时列出已有决定，/decide rm <序号> 删除）")
+	fmt.Println("  \033[33m/generate chapters\033[0m <起-止> [-tokens N] [-cost X] [-words N] [-overwrite] - 按大纲批量生成章节，中断后重跑同一范围即可继续")
+	fmt.Println("  \033[33m/history\033[0m [条数] - 查看项目的 git 提交记录（/history init 为项目创建仓库）")
+	fmt.Println("  \033[33m/revert\033[0m <提交号> - 撤销某次提交的改动，生成新的提交，原有记录保留")
+	fmt.Println("  \033[90m注: 项目在 git 仓库中时，每轮对话改动了文件会自动提交\033[0m")
+	fmt.Println()
 	fmt.Println("\033[1;36m🤖 AI对话:\033[0m")
 	fmt.Println("  直接输入你的问题或请求，我会帮助你！")
 	fmt.Println("  \033[90m示例:\033[0m")
@@ -360,7 +452,7 @@ func printHelp(inputManager *input.Manager) {
 	fmt.Println("  • 使用 \033[33mCtrl+C\033[0m 中断，\033[33mCtrl+D\033[0m 退出")
 }
 
-func printStatus(sessionManager *session.Manager, cfg *config.Config, inputManager *input.Manager) {
+func printStatus(sessionManager *session.Manager, toolManager *tools.Manager, cfg *config.Config, inputManager *input.Manager) {
 	session := sessionManager.GetCurrentSession()
 	fmt.Printf("\033[1;36m📊 当前状态:\033[0m\n")
 	fmt.Printf("  \033[36m会话:\033[0m %s (ID: %s)\n", session.Name, session.ID[:8])
@@ -387,6 +479,12 @@ func printStatus(sessionManager *session.Manager, cfg *config.Config, inputManag
 	
 	fmt.Printf("  \033[36m工作目录:\033[0m %s\n", session.Context.WorkingDirectory)
 	fmt.Printf("  \033[36m消息数量:\033[0m %d\n", len(session.Messages))
+	if summary := toolManager.NovelManager().ProjectSummary(); summary != nil {
+		fmt.Printf("  \033[36m小说:\033[0m 《%s》 第%d章 (%s)\n", summary.Title, summary.CurrentChapter, toolManager.NovelManager().ProjectPath())
+	}
+	if state := gitState(projectRepo(toolManager)); state != "" {
+		fmt.Printf("  \033[36m版本库:\033[0m %s\n", state)
+	}
 	if session.Context.ProjectInfo.Name != "" {
 		fmt.Printf("  \033[36m项目:\033[0m %s (%s)\n", session.Context.ProjectInfo.Name, session.Context.ProjectInfo.Language)
 	}
@@ -558,6 +656,7 @@ func showConfigHelp(inputManager *input.Manager) {
 	fmt.Println("  \033[90m/config set zhipu.api_key sk-xxx\033[0m")
 	fmt.Println("  \033[90m/config set deepseek.api_key sk-xxx\033[0m")
 	fmt.Println("  \033[90m/config set ai.provider zhipu\033[0m")
+	fmt.Println("  \033[90m/config set writing.target_words_per_day 3000\033[0m")
 }
 
 func handleConfigCommand(args []string, cfg *config.Config, inputManager *input.Manager) {
@@ -639,6 +738,29 @@ func setConfigValue(key, value string, cfg *config.Config, inputManager *input.M
 			inputManager.PrintError(fmt.Sprintf("未知的AI字段: %s", field))
 			return
 		}
+	case "writing":
+		switch field {
+		case "target_words_per_day":
+			target, err := strconv.Atoi(value)
+			if err != nil || target < 0 {
+				inputManager.PrintError("每日目标字数必须是非负整数")
+				return
+			}
+			cfg.Writing.TargetWordsPerDay = target
+			inputManager.PrintSuccess(fmt.Sprintf("已设置每日目标字数为: %d", target))
+		case "show_word_count":
+			cfg.Writing.ShowWordCount = value == "true" || value == "on" || value == "1"
+			inputManager.PrintSuccess(fmt.Sprintf("字数显示: %v", cfg.Writing.ShowWordCount))
+		case "use_embeddings":
+			cfg.Writing.UseEmbeddings = value == "true" || value == "on" || value == "1"
+			inputManager.PrintSuccess(fmt.Sprintf("检索使用向量嵌入: %v（重启后生效）", cfg.Writing.UseEmbeddings))
+		case "auto_commit":
+			cfg.Writing.DisableAutoCommit = !(value == "true" || value == "on" || value == "1")
+			inputManager.PrintSuccess(fmt.Sprintf("git 自动提交: %v", !cfg.Writing.DisableAutoCommit))
+		default:
+			inputManager.PrintError(fmt.Sprintf("未知的写作字段: %s", field))
+			return
+		}
 	case "zhipu", "deepseek":
 		provider := ai.Provider(section)
 		
@@ -730,7 +852,7 @@ func editConfig(inputManager *input.Manager) {
 	}
 }
 
-func processInput(ctx context.Context, aiClient *ai.Client, toolManager *tools.Manager, sessionManager *session.Manager, inputManager *input.Manager, userInput string) (string, error) {
+func processInput(ctx context.Context, aiClient *ai.Client, toolManager *tools.Manager, sessionManager *session.Manager, inputManager *input.Manager, userInput string) (string, []tools.ToolResult, error) {
 	// 获取当前会话
 	currentSession := sessionManager.GetCurrentSession()
 	
@@ -746,10 +868,11 @@ func processInput(ctx context.Context, aiClient *ai.Client, toolManager *tools.M
 	// 调用AI模型
 	response, toolCalls, err := aiClient.Chat(ctx, messages, toolDefinitions)
 	if err != nil {
-		return "", fmt.Errorf("AI request failed: %w", err)
+		return "", nil, fmt.Errorf("AI request failed: %w", err)
 	}
 	
 	// 执行工具调用
+	var toolResults []tools.ToolResult
 	if len(toolCalls) > 0 {
 		inputManager.PrintInfo(fmt.Sprintf("🔧 正在执行 %d 个工具调用...", len(toolCalls)))
 		
@@ -761,9 +884,9 @@ func processInput(ctx context.Context, aiClient *ai.Client, toolManager *tools.M
 		}
 		currentSession.Messages = append(currentSession.Messages, assistantMessage)
 		
-		toolResults, err := toolManager.ExecuteTools(ctx, toolCalls)
+		toolResults, err = toolManager.ExecuteTools(ctx, toolCalls)
 		if err != nil {
-			return "", fmt.Errorf("tool execution failed: %w", err)
+			return "", toolResults, fmt.Errorf("tool execution failed: %w", err)
 		}
 		
 		// 统计执行结果
@@ -799,7 +922,7 @@ func processInput(ctx context.Context, aiClient *ai.Client, toolManager *tools.M
 		}
 		
 		if err != nil {
-			return "", fmt.Errorf("AI follow-up request failed after %d retries: %w", maxRetries, err)
+			return "", toolResults, fmt.Errorf("AI follow-up request failed after %d retries: %w", maxRetries, err)
 		}
 		
 		// 添加最终的AI响应到会话历史
@@ -809,7 +932,601 @@ func processInput(ctx context.Context, aiClient *ai.Client, toolManager *tools.M
 		currentSession.AddMessage("assistant", response)
 	}
 	
-	return response, nil
+	// 小说项目中的每轮对话记入创作历史
+	if novelManager := toolManager.NovelManager(); novelManager.HasProject() {
+		if err := novelManager.AddChatRecord(userInput, response, novelManager.TurnChapter(userInput)); err != nil {
+			inputManager.PrintWarning(fmt.Sprintf("记录创作历史失败: %v", err))
+		}
+	}
+	
+	return response, toolResults, nil
+}
+
+// importManuscript 预览文稿章节识别结果，确认后导入到小说项目
+func importManuscript(filePath, chapterPattern string, toolManager *tools.Manager, cfg *config.Config, inputManager *input.Manager) {
+	preview, err := novel.PreviewImport(filePath, novel.ImportOptions{ChapterPattern: chapterPattern})
+	if err != nil {
+		inputManager.PrintError(fmt.Sprintf("解析文稿失败: %v", err))
+		return
+	}
+	
+	fmt.Println(preview.Format(0))
+	
+	novelManager := toolManager.NovelManager()
+	overwrite := false
+	if count := novelManager.ChapterCount(); count > 0 {
+		inputManager.PrintWarning(fmt.Sprintf("当前项目已有 %d 章，导入将覆盖章节列表，新文稿中没有的旧章节文件会存入历史版本后移除", count))
+		overwrite = true
+	}
+	
+	inputManager.SetPrompt("\033[33m确认导入? (y/N) ❯ \033[0m")
+	answer, _ := inputManager.ReadLine()
+	updatePrompt(cfg, inputManager)
+	if strings.ToLower(answer) != "y" && strings.ToLower(answer) != "yes" {
+		inputManager.PrintInfo("已取消导入")
+		return
+	}
+	
+	if err := novelManager.ApplyImport(preview, overwrite); err != nil {
+		inputManager.PrintError(fmt.Sprintf("导入失败: %v", err))
+		return
+	}
+	
+	inputManager.PrintSuccess(fmt.Sprintf("导入完成: %d 卷, %d 章", len(preview.Volumes), len(preview.Chapters)))
+}
+
+// closeNovelProject 退出前写入尚未落盘的小说项目数据
+func closeNovelProject(toolManager *tools.Manager, inputManager *input.Manager) {
+	if err := toolManager.NovelManager().Close(); err != nil {
+		inputManager.PrintWarning(fmt.Sprintf("保存小说项目失败: %v", err))
+	}
+}
+
+// recordDecision 记录一条明确的创作决定；不带内容时列出已有决定
+func recordDecision(decision string, toolManager *tools.Manager, inputManager *input.Manager) {
+	novelManager := toolManager.NovelManager()
+	if !novelManager.HasProject() {
+		inputManager.PrintError("当前目录没有小说项目，请先初始化")
+		return
+	}
+	
+	if decision == "" {
+		fmt.Println("\033[1;36m📌 创作决定:\033[0m")
+		fmt.Print(novel.FormatDecisions(novelManager.ListDecisions(0)))
+		return
+	}
+	
+	// /decide rm <序号> 删除列表中的某条决定
+	if fields := strings.Fields(decision); len(fields) == 2 && (fields[0] == "rm" || fields[0] == "remove") {
+		decisions := novelManager.ListDecisions(0)
+		n, err := strconv.Atoi(fields[1])
+		if err != nil || n < 1 || n > len(decisions) {
+			inputManager.PrintError(fmt.Sprintf("序号必须在 1-%d 之间", len(decisions)))
+			return
+		}
+		if err := novelManager.DeleteChatRecord(decisions[n-1].ID); err != nil {
+			inputManager.PrintError(fmt.Sprintf("删除决定失败: %v", err))
+			return
+		}
+		inputManager.PrintSuccess(fmt.Sprintf("已删除决定: %s", strings.Join(decisions[n-1].Decisions, "；")))
+		return
+	}
+	
+	record, err := novelManager.AddDecision(decision, novelManager.TurnChapter(decision))
+	if err != nil {
+		inputManager.PrintError(fmt.Sprintf("记录决定失败: %v", err))
+		return
+	}
+	if record.ChapterNum > 0 {
+		inputManager.PrintSuccess(fmt.Sprintf("已记录第%d章的创作决定: %s", record.ChapterNum, decision))
+	} else {
+		inputManager.PrintSuccess(fmt.Sprintf("已记录创作决定: %s", decision))
+	}
+}
+
+// parseGenerateArgs 解析批量生成参数：chapters <起-止> [选项]
+func parseGenerateArgs(args []string, output io.Writer) (novel.BatchOptions, string, error) {
+	var opts novel.BatchOptions
+	usage := "用法: generate chapters <起-止> [-tokens N] [-cost X] [-words N] [-overwrite]"
+	if len(args) < 2 || (args[0] != "chapters" && args[0] != "chapter") {
+		return opts, "", fmt.Errorf("%s", usage)
+	}
+	
+	bounds := strings.SplitN(args[1], "-", 2)
+	from, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+	if err != nil {
+		return opts, "", fmt.Errorf("章节范围无效: %s", args[1])
+	}
+	to := from
+	if len(bounds) == 2 {
+		if to, err = strconv.Atoi(strings.TrimSpace(bounds[1])); err != nil {
+			return opts, "", fmt.Errorf("章节范围无效: %s", args[1])
+		}
+	}
+	if from <= 0 || to < from {
+		return opts, "", fmt.Errorf("章节范围无效: %s", args[1])
+	}
+	
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	fs.SetOutput(output)
+	maxTokens := fs.Int("tokens", 0, "token 预算（0 为不限）")
+	maxCost := fs.Float64("cost", 0, "费用预算，按模型配置的 input_price/output_price 估算（0 为不限）")
+	words := fs.Int("words", 0, "每章目标字数（默认3000）")
+	overwrite := fs.Bool("overwrite", false, "覆盖已存在的章节正文")
+	dir := fs.String("dir", "", "小说项目目录（默认当前目录）")
+	if err := fs.Parse(args[2:]); err != nil {
+		return opts, "", fmt.Errorf("%s", usage)
+	}
+	
+	opts = novel.BatchOptions{
+		From:        from,
+		To:          to,
+		TargetWords: *words,
+		MaxTokens:   *maxTokens,
+		MaxCost:     *maxCost,
+		Overwrite:   *overwrite,
+	}
+	return opts, *dir, nil
+}
+
+// generateChapters 在对话中批量生成章节，Ctrl+C 中断后保留检查点
+func generateChapters(args []string, aiClient *ai.Client, toolManager *tools.Manager, inputManager *input.Manager) {
+	opts, _, err := parseGenerateArgs(args, os.Stdout)
+	if err != nil {
+		inputManager.PrintError(err.Error())
+		return
+	}
+	novelManager := toolManager.NovelManager()
+	if !novelManager.HasProject() {
+		inputManager.PrintError("当前目录没有小说项目，请先初始化")
+		return
+	}
+	
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	opts.Progress = inputManager.PrintInfo
+	inputManager.PrintInfo(fmt.Sprintf("开始批量生成第%d-%d章，按 Ctrl+C 中断", opts.From, opts.To))
+	
+	batch, err := novelManager.RunBatch(ctx, aiClient, opts)
+	if batch != nil {
+		fmt.Println(batch.Format())
+	}
+	if err != nil {
+		inputManager.PrintWarning(fmt.Sprintf("批量生成停止: %v", err))
+	} else {
+		inputManager.PrintSuccess(fmt.Sprintf("批量生成完成，报告: %s", novelManager.BatchReportPath(opts.From, opts.To)))
+	}
+}
+
+// runGenerateCommand 不进入交互界面直接批量生成，适合夜间挂机；返回进程退出码
+func runGenerateCommand(args []string) int {
+	opts, dir, err := parseGenerateArgs(args, os.Stderr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if dir == "" {
+		if dir, err = os.Getwd(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		return 1
+	}
+	aiClient := ai.NewClient(cfg.AI)
+	
+	novelManager := novel.NewNovelManager(dir)
+	if err := novelManager.LoadProject(); err != nil {
+		fmt.Fprintf(os.Stderr, "加载小说项目失败: %v\n", err)
+		return 1
+	}
+	defer novelManager.Close()
+	for _, note := range novelManager.RecoveryNotes() {
+		fmt.Fprintln(os.Stderr, "⚠️ "+note)
+	}
+	
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	opts.Progress = func(message string) {
+		fmt.Printf("[%s] %s\n", time.Now().Format("15:04:05"), message)
+	}
+	
+	batch, err := novelManager.RunBatch(ctx, aiClient, opts)
+	if batch != nil {
+		fmt.Printf("\n运行报告: %s\n", novelManager.BatchReportPath(opts.From, opts.To))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "批量生成停止: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// exportNovel 导出当前小说项目
+func exportNovel(format, outputPath string, toolManager *tools.Manager, inputManager *input.Manager) {
+	result, err := toolManager.NovelManager().Export(novel.ExportOptions{
+		Format:     format,
+		OutputPath: outputPath,
+	})
+	if err != nil {
+		inputManager.PrintError(fmt.Sprintf("导出失败: %v", err))
+		return
+	}
+	
+	inputManager.PrintSuccess(fmt.Sprintf("已导出 %d 章（%d 字）到 %s", result.Chapters, result.WordCount, result.Path))
+	if len(result.Missing) > 0 {
+		inputManager.PrintWarning(fmt.Sprintf("%d 个章节缺少正文文件，已跳过: %v", len(result.Missing), result.Missing))
+	}
+}
+
+// showProgress 显示写作进度
+func showProgress(toolManager *tools.Manager, cfg *config.Config, inputManager *input.Manager) {
+	report, err := toolManager.NovelManager().GetProgressReport(cfg.Writing.TargetWordsPerDay)
+	if err != nil {
+		inputManager.PrintError(fmt.Sprintf("获取写作进度失败: %v", err))
+		return
+	}
+	
+	fmt.Print(report.Format())
+	if cfg.Writing.TargetWordsPerDay == 0 {
+		fmt.Println("\033[90m提示: 使用 /config set writing.target_words_per_day 3000 设置每日目标\033[0m")
+	}
+}
+
+// reportWordCountChange 同步本轮对话改动的章节（字数、出场角色），在开启字数显示时提示字数变化
+func reportWordCountChange(toolManager *tools.Manager, cfg *config.Config, inputManager *input.Manager) {
+	novelManager := toolManager.NovelManager()
+	if !novelManager.HasProject() {
+		return
+	}
+	
+	delta, err := novelManager.SyncChapterStats()
+	if err != nil || delta == 0 || !cfg.Writing.ShowWordCount {
+		return
+	}
+	
+	report, err := novelManager.GetProgressReport(cfg.Writing.TargetWordsPerDay)
+	if err != nil {
+		return
+	}
+	if report.DailyTarget > 0 {
+		inputManager.PrintInfo(fmt.Sprintf("📝 本轮字数 %+d | 今日 %d/%d", delta, report.Today, report.DailyTarget))
+	} else {
+		inputManager.PrintInfo(fmt.Sprintf("📝 本轮字数 %+d | 今日 %d", delta, report.Today))
+	}
+}
+
+// configureNovelManager 按配置设置当前小说管理器，启动和切换小说后调用
+func configureNovelManager(toolManager *tools.Manager, aiClient *ai.Client, cfg *config.Config) {
+	if cfg.Writing.UseEmbeddings {
+		toolManager.NovelManager().SetEmbedder(novel.NewProviderEmbedder(aiClient))
+	}
+}
+
+// updateNovelPrompt 在提示符中显示当前小说和章节
+func updateNovelPrompt(toolManager *tools.Manager, inputManager *input.Manager) {
+	summary := toolManager.NovelManager().ProjectSummary()
+	if summary == nil {
+		inputManager.SetNovelPrompt("")
+		return
+	}
+	label := "《" + truncateRunes(summary.Title, 12) + "》"
+	if summary.CurrentChapter > 0 {
+		label += fmt.Sprintf("第%d章", summary.CurrentChapter)
+	}
+	inputManager.SetNovelPrompt(label)
+}
+
+// handleNovelCommand 处理 /novel 命令：list、open、new、info
+func handleNovelCommand(args []string, aiClient *ai.Client, toolManager *tools.Manager, sessionManager *session.Manager, cfg *config.Config, inputManager *input.Manager) {
+	workspace := toolManager.Workspace()
+	if len(args) == 0 {
+		inputManager.PrintError("用法: /novel list | open <名称> | new <目录名> [书名] [题材] | info [名称]")
+		return
+	}
+	
+	switch args[0] {
+	case "list", "ls":
+		projects, err := workspace.Projects()
+		if err != nil {
+			inputManager.PrintError(fmt.Sprintf("读取工作区失败: %v", err))
+			return
+		}
+		active := ""
+		if toolManager.NovelManager().HasProject() {
+			active = workspace.NameOf(toolManager.NovelManager().ProjectPath())
+		}
+		fmt.Print(novel.FormatWorkspaceProjects(workspace.Root, projects, active))
+		
+	case "open":
+		if len(args) < 2 {
+			inputManager.PrintError("用法: /novel open <名称>")
+			return
+		}
+		openNovel(strings.Join(args[1:], " "), toolManager, sessionManager, aiClient, cfg, inputManager)
+		
+	case "new":
+		if len(args) < 2 {
+			inputManager.PrintError("用法: /novel new <目录名> [书名] [题材]")
+			return
+		}
+		createNovel(args[1:], aiClient, toolManager, sessionManager, cfg, inputManager)
+		
+	case "info":
+		if len(args) > 1 {
+			project, err := workspace.Find(strings.Join(args[1:], " "))
+			if err != nil {
+				inputManager.PrintError(err.Error())
+				return
+			}
+			if project.Err != nil {
+				inputManager.PrintError(fmt.Sprintf("无法读取 %s: %v", project.Name, project.Err))
+				return
+			}
+			fmt.Print(project.ProjectSummary.Format(project.Path))
+			return
+		}
+		novelManager := toolManager.NovelManager()
+		summary := novelManager.ProjectSummary()
+		if summary == nil {
+			inputManager.PrintWarning("当前没有打开小说，使用 /novel open <名称> 打开或 /novel new <目录名> 新建")
+			return
+		}
+		fmt.Print(summary.Format(novelManager.ProjectPath()))
+		if state := gitState(projectRepo(toolManager)); state != "" {
+			fmt.Printf("版本库: %s\n", state)
+		}
+		
+	default:
+		inputManager.PrintError(fmt.Sprintf("未知的子命令: %s（可用 list、open、new、info）", args[0]))
+	}
+}
+
+// openNovel 按目录名或书名打开工作区中的小说
+func openNovel(name string, toolManager *tools.Manager, sessionManager *session.Manager, aiClient *ai.Client, cfg *config.Config, inputManager *input.Manager) {
+	project, err := toolManager.Workspace().Find(name)
+	if err != nil {
+		inputManager.PrintError(err.Error())
+		return
+	}
+	if filepath.Clean(project.Path) == filepath.Clean(toolManager.NovelManager().ProjectPath()) {
+		inputManager.PrintInfo(fmt.Sprintf("《%s》已经打开", project.Title))
+		return
+	}
+	if err := switchNovel(project.Path, toolManager, sessionManager, aiClient, cfg, inputManager); err != nil {
+		inputManager.PrintError(fmt.Sprintf("打开小说失败: %v", err))
+		return
+	}
+	summary := toolManager.NovelManager().ProjectSummary()
+	if summary == nil {
+		inputManager.PrintWarning(fmt.Sprintf("已切换到 %s，但没有加载到小说项目", project.Path))
+		return
+	}
+	inputManager.PrintSuccess(fmt.Sprintf("📖 已打开《%s》: %d 章，%d 字，当前第%d章", summary.Title, summary.Chapters, summary.Words, summary.CurrentChapter))
+	inputManager.PrintInfo("提示: 会话中仍保留上一部小说的对话，可用 /new 开启新会话")
+}
+
+// createNovel 在工作区新建小说目录，切换过去后按题材模板初始化项目
+func createNovel(args []string, aiClient *ai.Client, toolManager *tools.Manager, sessionManager *session.Manager, cfg *config.Config, inputManager *input.Manager) {
+	workspace := toolManager.Workspace()
+	path, err := workspace.Create(args[0])
+	if err != nil {
+		inputManager.PrintError(fmt.Sprintf("新建小说失败: %v", err))
+		return
+	}
+	if err := switchNovel(path, toolManager, sessionManager, aiClient, cfg, inputManager); err != nil {
+		inputManager.PrintError(fmt.Sprintf("打开小说失败: %v", err))
+		return
+	}
+	
+	params := map[string]interface{}{"title": args[0]}
+	if len(args) > 1 {
+		params["title"] = args[1]
+	}
+	if len(args) > 2 {
+		params["genre"] = strings.Join(args[2:], " ")
+	} else if cfg.Writing.DefaultGenre != "" {
+		params["genre"] = cfg.Writing.DefaultGenre
+	}
+	tool, _ := toolManager.GetTool("init_novel_project")
+	result, err := tool.Execute(context.Background(), params)
+	if err != nil {
+		inputManager.PrintError(fmt.Sprintf("初始化小说项目失败: %v", err))
+		return
+	}
+	fmt.Println(result)
+}
+
+// switchNovel 把工具和会话上下文切换到另一个小说目录，并记为工作区当前小说
+func switchNovel(path string, toolManager *tools.Manager, sessionManager *session.Manager, aiClient *ai.Client, cfg *config.Config, inputManager *input.Manager) error {
+	if err := toolManager.OpenNovelProject(path); err != nil {
+		return err
+	}
+	sessionManager.GetCurrentSession().SetWorkingDirectory(path)
+	configureNovelManager(toolManager, aiClient, cfg)
+	for _, note := range toolManager.NovelManager().RecoveryNotes() {
+		inputManager.PrintWarning(note)
+	}
+	workspace := toolManager.Workspace()
+	if err := workspace.SetActive(workspace.NameOf(path)); err != nil {
+		inputManager.PrintWarning(fmt.Sprintf("记录当前小说失败: %v", err))
+	}
+	return nil
+}
+
+// projectRepo 当前小说项目目录对应的 git 仓库
+func projectRepo(toolManager *tools.Manager) *vcs.Repo {
+	return vcs.Open(toolManager.NovelManager().ProjectPath())
+}
+
+// gitState 状态栏显示的分支和未提交文件数，如 "main*3"；没有 git 或不在仓库中时返回空
+func gitState(repo *vcs.Repo) string {
+	if !repo.IsRepo() {
+		return ""
+	}
+	status, err := repo.Status()
+	if err != nil {
+		return ""
+	}
+	if status.Dirty > 0 {
+		return fmt.Sprintf("%s*%d", status.Branch, status.Dirty)
+	}
+	return status.Branch
+}
+
+// autoCommit 本轮对话改动了项目文件时自动提交，说明取自本轮的工具调用。只在项目目录就是仓库根目录时提交，
+// 项目不在仓库中或位于上层目录的仓库（如用户主目录的配置仓库）中时不做任何事
+func autoCommit(userInput string, toolResults []tools.ToolResult, toolManager *tools.Manager, cfg *config.Config, inputManager *input.Manager) {
+	novelManager := toolManager.NovelManager()
+	if cfg.Writing.DisableAutoCommit || !novelManager.HasProject() {
+		return
+	}
+	repo := projectRepo(toolManager)
+	if !repo.IsRepo() || !repo.IsRoot() {
+		return
+	}
+	
+	// 先写入后台尚未落盘的项目数据，提交的才是本轮结束时的状态
+	if err := novelManager.Flush(); err != nil {
+		inputManager.PrintWarning(fmt.Sprintf("保存小说项目失败: %v", err))
+	}
+	message := tools.TurnCommitMessage(userInput, toolResults)
+	hash, err := repo.CommitAll(message)
+	if err != nil {
+		inputManager.PrintWarning(fmt.Sprintf("自动提交失败: %v", err))
+		return
+	}
+	if hash != "" {
+		inputManager.PrintInfo(fmt.Sprintf("📦 已提交 %s: %s", hash, strings.SplitN(message, "\n", 2)[0]))
+	}
+}
+
+// truncateRunes 按字符截断文本
+func truncateRunes(s string, maxRunes int) string {
+	runes := []rune(s)
+	if len(runes) <= maxRunes {
+		return s
+	}
+	return string(runes[:maxRunes]) + "…"
+}
+
+// showGitHistory 列出涉及项目目录的最近提交；/history init 为项目创建仓库并提交现有文件
+func showGitHistory(args []string, toolManager *tools.Manager, inputManager *input.Manager) {
+	repo := projectRepo(toolManager)
+	if !repo.Available() {
+		inputManager.PrintWarning("未找到 git，版本管理不可用；安装 git 后即可使用 /history 和自动提交")
+		return
+	}
+	
+	if len(args) > 0 && args[0] == "init" {
+		if repo.IsRoot() {
+			inputManager.PrintInfo("项目已在 git 仓库中")
+			return
+		}
+		if err := repo.Init(vcs.DefaultIgnore); err != nil {
+			inputManager.PrintError(fmt.Sprintf("创建仓库失败: %v", err))
+			return
+		}
+		if err := toolManager.NovelManager().Flush(); err != nil {
+			inputManager.PrintWarning(fmt.Sprintf("保存小说项目失败: %v", err))
+		}
+		hash, err := repo.CommitAll("初始化版本库")
+		if err != nil {
+			inputManager.PrintError(fmt.Sprintf("首次提交失败: %v", err))
+			return
+		}
+		inputManager.PrintSuccess(fmt.Sprintf("✅ 已创建 git 仓库 %s", repo.Dir()))
+		if hash != "" {
+			inputManager.PrintInfo(fmt.Sprintf("📦 已提交现有文件 %s", hash))
+		}
+		return
+	}
+	
+	if !repo.IsRepo() {
+		inputManager.PrintWarning("当前项目不在 git 仓库中，使用 /history init 创建仓库")
+		return
+	}
+	limit := 20
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			inputManager.PrintError("用法: /history [条数] 或 /history init")
+			return
+		}
+		limit = n
+	}
+	commits, err := repo.Log(limit)
+	if err != nil {
+		inputManager.PrintError(fmt.Sprintf("读取提交记录失败: %v", err))
+		return
+	}
+	
+	fmt.Printf("\033[1;36m🕘 版本历史 (%s):\033[0m\n", gitState(repo))
+	if len(commits) == 0 {
+		fmt.Println("  还没有提交")
+		return
+	}
+	for _, commit := range commits {
+		fmt.Printf("  \033[33m%s\033[0m \033[90m%s\033[0m %s \033[90m(%d 个文件)\033[0m\n", commit.Hash, commit.Date, commit.Subject, commit.Files)
+	}
+	fmt.Println("\033[90m提示: /revert <提交号> 撤销某次提交的改动\033[0m")
+}
+
+// revertCommit 撤销一次提交：先提交未保存的改动，再生成撤销提交，最后重新加载项目数据
+func revertCommit(rev string, toolManager *tools.Manager, cfg *config.Config, inputManager *input.Manager) {
+	repo := projectRepo(toolManager)
+	if !repo.Available() {
+		inputManager.PrintWarning("未找到 git，版本管理不可用")
+		return
+	}
+	if !repo.IsRepo() {
+		inputManager.PrintWarning("当前项目不在 git 仓库中，使用 /history init 创建仓库")
+		return
+	}
+	if !repo.IsRoot() {
+		inputManager.PrintWarning("项目位于上层目录的仓库中，撤销会影响项目以外的文件；请在仓库根目录用 git revert 处理")
+		return
+	}
+	commit, err := repo.Resolve(rev)
+	if err != nil {
+		inputManager.PrintError(fmt.Sprintf("找不到提交: %s", rev))
+		return
+	}
+	
+	inputManager.SetPrompt(fmt.Sprintf("\033[33m撤销提交 %s 的改动? (y/N) ❯ \033[0m", commit))
+	answer, _ := inputManager.ReadLine()
+	updatePrompt(cfg, inputManager)
+	if strings.ToLower(answer) != "y" && strings.ToLower(answer) != "yes" {
+		inputManager.PrintInfo("已取消撤销")
+		return
+	}
+	
+	novelManager := toolManager.NovelManager()
+	if err := novelManager.Flush(); err != nil {
+		inputManager.PrintWarning(fmt.Sprintf("保存小说项目失败: %v", err))
+	}
+	if hash, err := repo.CommitAll("撤销前保存未提交的改动"); err != nil {
+		inputManager.PrintError(fmt.Sprintf("保存未提交的改动失败: %v", err))
+		return
+	} else if hash != "" {
+		inputManager.PrintInfo(fmt.Sprintf("📦 已先提交未保存的改动 %s", hash))
+	}
+	
+	hash, err := repo.Revert(commit)
+	if err != nil {
+		inputManager.PrintError(fmt.Sprintf("撤销失败（与之后的修改冲突时需要手动处理）: %v", err))
+		return
+	}
+	if err := novelManager.LoadProject(); err != nil {
+		inputManager.PrintWarning(fmt.Sprintf("重新加载小说项目失败: %v", err))
+	} else if novelManager.HasProject() {
+		novelManager.SyncChapterStats()
+	}
+	inputManager.PrintSuccess(fmt.Sprintf("✅ 已撤销提交 %s，新提交 %s", commit, hash))
 }
 
 // handleInitCommand 处理 /init 命令